@@ -0,0 +1,95 @@
+package apikey
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/justinas/alice"
+
+	"github.com/myshkin5/effective-octo-garbanzo/api/handlers"
+	"github.com/myshkin5/effective-octo-garbanzo/persistence"
+	"github.com/myshkin5/effective-octo-garbanzo/persistence/data"
+)
+
+type APIKey struct {
+	Link       string     `json:"link"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created-at"`
+	LastUsedAt *time.Time `json:"last-used-at,omitempty"`
+	// Key is only present in the response minting the key
+	Key string `json:"key,omitempty"`
+}
+
+var fieldMapping = map[string]string{
+	"Link":       "link",
+	"Name":       "name",
+	"Prefix":     "prefix",
+	"Scopes":     "scopes",
+	"CreatedAt":  "created-at",
+	"LastUsedAt": "last-used-at",
+	"Key":        "key",
+}
+
+type APIKeyService interface {
+	FetchByOrgName(ctx context.Context, orgName string) (apiKeys []data.APIKey, err error)
+	Create(ctx context.Context, orgName string, apiKeyIn data.APIKey) (apiKeyOut data.APIKey, key string, err error)
+	DeleteByPrefixAndOrgName(ctx context.Context, prefix, orgName string) (err error)
+}
+
+type apiKey struct {
+	apiKeyService APIKeyService
+	baseURL       string
+}
+
+func MapRoutes(baseURL string, router *mux.Router, middleware alice.Chain, authorize handlers.Authorizer, apiKeyService APIKeyService) {
+	handler := &apiKey{
+		apiKeyService: apiKeyService,
+		baseURL:       baseURL,
+	}
+	methodHandler := make(handlers.MethodHandler)
+	methodHandler[http.MethodDelete] = authorize(handlers.ScopeAPIKeysDelete)(http.HandlerFunc(handler.delete))
+	router.Handle("/orgs/{orgName}/api-keys/{prefix}", middleware.Then(methodHandler))
+}
+
+// delete revokes the key immediately.
+func (g *apiKey) delete(w http.ResponseWriter, req *http.Request) {
+	vars := mux.Vars(req)
+	orgName := vars["orgName"]
+	prefix := vars["prefix"]
+
+	err := g.apiKeyService.DeleteByPrefixAndOrgName(req.Context(), prefix, orgName)
+	if err == persistence.ErrNotFound {
+		handlers.Error(req.Context(), w, fmt.Sprintf("API key %s not found in org %s", prefix, orgName), http.StatusNotFound, err, fieldMapping)
+		return
+	} else if err != nil {
+		handlers.Error(req.Context(), w, "Error deleting API key", http.StatusInternalServerError, err, fieldMapping)
+		return
+	}
+
+	handlers.Respond(w, http.StatusNoContent, nil)
+}
+
+func fromPersistence(apiKey data.APIKey, baseURL string) APIKey {
+	dto := APIKey{
+		Link:      fmt.Sprintf("%sorgs/%s/api-keys/%s", baseURL, apiKey.OrgName, apiKey.Prefix),
+		Name:      apiKey.Name,
+		Prefix:    apiKey.Prefix,
+		Scopes:    apiKey.Scopes,
+		CreatedAt: apiKey.CreatedAt,
+	}
+	if dto.Scopes == nil {
+		// Intentionally an empty slice so scopes are present in output even when empty
+		dto.Scopes = []string{}
+	}
+	if !apiKey.LastUsedAt.IsZero() {
+		lastUsedAt := apiKey.LastUsedAt
+		dto.LastUsedAt = &lastUsedAt
+	}
+
+	return dto
+}
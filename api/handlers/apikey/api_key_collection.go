@@ -0,0 +1,88 @@
+package apikey
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/justinas/alice"
+
+	"github.com/myshkin5/effective-octo-garbanzo/api/handlers"
+	"github.com/myshkin5/effective-octo-garbanzo/persistence"
+	"github.com/myshkin5/effective-octo-garbanzo/persistence/data"
+)
+
+type APIKeyList struct {
+	Link    string   `json:"link"`
+	APIKeys []APIKey `json:"api-keys"`
+}
+
+type apiKeyCollection struct {
+	apiKeyService APIKeyService
+	baseURL       string
+}
+
+func MapCollectionRoutes(baseURL string, router *mux.Router, middleware alice.Chain, authorize handlers.Authorizer, apiKeyService APIKeyService) {
+	handler := &apiKeyCollection{
+		apiKeyService: apiKeyService,
+		baseURL:       baseURL,
+	}
+	methodHandler := make(handlers.MethodHandler)
+	methodHandler[http.MethodGet] = authorize(handlers.ScopeAPIKeysRead)(http.HandlerFunc(handler.get))
+	methodHandler[http.MethodPost] = authorize(handlers.ScopeAPIKeysWrite)(http.HandlerFunc(handler.post))
+	router.Handle("/orgs/{orgName}/api-keys", middleware.Then(methodHandler))
+}
+
+// get lists the keys of the org without the keys themselves.
+func (g *apiKeyCollection) get(w http.ResponseWriter, req *http.Request) {
+	orgName := mux.Vars(req)["orgName"]
+
+	apiKeys, err := g.apiKeyService.FetchByOrgName(req.Context(), orgName)
+	if err == persistence.ErrOrgNotFound {
+		handlers.Error(req.Context(), w, fmt.Sprintf("Org %s not found", orgName), http.StatusNotFound, err, fieldMapping)
+		return
+	} else if err != nil {
+		handlers.Error(req.Context(), w, "Error fetching API keys", http.StatusInternalServerError, err, fieldMapping)
+		return
+	}
+
+	list := APIKeyList{
+		Link: fmt.Sprintf("%sorgs/%s/api-keys", g.baseURL, orgName),
+		// Intentionally an empty slice so list is present in output even when empty
+		APIKeys: []APIKey{},
+	}
+	for _, apiKey := range apiKeys {
+		list.APIKeys = append(list.APIKeys, fromPersistence(apiKey, g.baseURL))
+	}
+
+	handlers.Respond(w, http.StatusOK, list)
+}
+
+// post mints a key. The response is the only time the key is revealed.
+func (g *apiKeyCollection) post(w http.ResponseWriter, req *http.Request) {
+	orgName := mux.Vars(req)["orgName"]
+
+	var dto APIKey
+	err := json.NewDecoder(req.Body).Decode(&dto)
+	if err != nil {
+		handlers.Error(req.Context(), w, handlers.InvalidJSON, http.StatusBadRequest, err, fieldMapping)
+		return
+	}
+
+	apiKey, key, err := g.apiKeyService.Create(req.Context(), orgName, data.APIKey{
+		Name:   dto.Name,
+		Scopes: dto.Scopes,
+	})
+	if err == persistence.ErrOrgNotFound {
+		handlers.Error(req.Context(), w, fmt.Sprintf("Org %s not found", orgName), http.StatusNotFound, err, fieldMapping)
+		return
+	} else if err != nil {
+		handlers.Error(req.Context(), w, "Error creating new API key", http.StatusInternalServerError, err, fieldMapping)
+		return
+	}
+
+	dto = fromPersistence(apiKey, g.baseURL)
+	dto.Key = key
+	handlers.Respond(w, http.StatusCreated, dto)
+}
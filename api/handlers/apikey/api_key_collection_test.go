@@ -0,0 +1,237 @@
+package apikey_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/justinas/alice"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/myshkin5/effective-octo-garbanzo/api/handlers/apikey"
+	"github.com/myshkin5/effective-octo-garbanzo/persistence"
+	"github.com/myshkin5/effective-octo-garbanzo/persistence/data"
+	"github.com/myshkin5/effective-octo-garbanzo/services"
+)
+
+var _ = Describe("APIKeyCollection", func() {
+	var (
+		recorder    *httptest.ResponseRecorder
+		request     *http.Request
+		mockService *mockAPIKeyService
+		router      *mux.Router
+		grantScopes bool
+		createdAt   time.Time
+		lastUsedAt  time.Time
+	)
+
+	BeforeEach(func() {
+		recorder = httptest.NewRecorder()
+		recorder.Code = 0
+
+		mockService = newMockAPIKeyService()
+
+		createdAt = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+		lastUsedAt = time.Date(2024, 3, 2, 8, 30, 0, 0, time.UTC)
+
+		grantScopes = true
+		authorize := func(scope string) alice.Constructor {
+			return func(h http.Handler) http.Handler {
+				return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					if !grantScopes {
+						w.Header().Set("X-Required-Scope", scope)
+						w.WriteHeader(http.StatusForbidden)
+						return
+					}
+					h.ServeHTTP(w, r)
+				})
+			}
+		}
+
+		router = mux.NewRouter()
+		apikey.MapCollectionRoutes("http://here/", router, alice.Chain{}, authorize, mockService)
+	})
+
+	Describe("GET", func() {
+		BeforeEach(func() {
+			var err error
+			request, err = http.NewRequest(http.MethodGet, "/orgs/org1/api-keys", nil)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("lists the keys of the org without revealing them", func() {
+			mockService.FetchByOrgNameOutput.ApiKeys <- []data.APIKey{
+				{
+					Id:        3,
+					Prefix:    "0123456789ab",
+					OrgName:   "org1",
+					Name:      "feeder",
+					Scopes:    []string{"garbanzos:write"},
+					CreatedAt: createdAt,
+				},
+				{
+					Id:         4,
+					Prefix:     "ba9876543210",
+					OrgName:    "org1",
+					Name:       "reporter",
+					CreatedAt:  createdAt,
+					LastUsedAt: lastUsedAt,
+				},
+			}
+			mockService.FetchByOrgNameOutput.Err <- nil
+
+			router.ServeHTTP(recorder, request)
+
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(mockService.FetchByOrgNameInput.OrgName).To(Receive(Equal("org1")))
+			Expect(recorder.Body).To(MatchJSON(`{
+				"link": "http://here/orgs/org1/api-keys",
+				"api-keys": [
+					{
+						"link": "http://here/orgs/org1/api-keys/0123456789ab",
+						"name": "feeder",
+						"prefix": "0123456789ab",
+						"scopes": ["garbanzos:write"],
+						"created-at": "2024-03-01T12:00:00Z"
+					},
+					{
+						"link": "http://here/orgs/org1/api-keys/ba9876543210",
+						"name": "reporter",
+						"prefix": "ba9876543210",
+						"scopes": [],
+						"created-at": "2024-03-01T12:00:00Z",
+						"last-used-at": "2024-03-02T08:30:00Z"
+					}
+				]
+			}`))
+		})
+
+		It("returns not found for an unknown org", func() {
+			mockService.FetchByOrgNameOutput.ApiKeys <- nil
+			mockService.FetchByOrgNameOutput.Err <- persistence.ErrOrgNotFound
+
+			router.ServeHTTP(recorder, request)
+
+			Expect(recorder.Code).To(Equal(http.StatusNotFound))
+			Expect(recorder.Body).To(MatchJSON(`{
+				"code": 404,
+				"error": "Org org1 not found",
+				"status": "Not Found"
+			}`))
+		})
+
+		It("returns a JSON error when the keys can't be fetched", func() {
+			mockService.FetchByOrgNameOutput.ApiKeys <- nil
+			mockService.FetchByOrgNameOutput.Err <- errors.New("bad stuff")
+
+			router.ServeHTTP(recorder, request)
+
+			Expect(recorder.Code).To(Equal(http.StatusInternalServerError))
+		})
+	})
+
+	Describe("POST", func() {
+		BeforeEach(func() {
+			var err error
+			request, err = http.NewRequest(http.MethodPost, "/orgs/org1/api-keys", strings.NewReader(`{
+				"name": "feeder",
+				"scopes": ["garbanzos:write"]
+			}`))
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("mints a key and reveals it once", func() {
+			mockService.CreateOutput.ApiKeyOut <- data.APIKey{
+				Id:        3,
+				Prefix:    "0123456789ab",
+				Hash:      "hash",
+				OrgName:   "org1",
+				Name:      "feeder",
+				Scopes:    []string{"garbanzos:write"},
+				CreatedAt: createdAt,
+			}
+			mockService.CreateOutput.Key <- "0123456789ab.secret"
+			mockService.CreateOutput.Err <- nil
+
+			router.ServeHTTP(recorder, request)
+
+			Expect(recorder.Code).To(Equal(http.StatusCreated))
+			Expect(mockService.CreateInput.OrgName).To(Receive(Equal("org1")))
+			Expect(mockService.CreateInput.ApiKeyIn).To(Receive(Equal(data.APIKey{
+				Name:   "feeder",
+				Scopes: []string{"garbanzos:write"},
+			})))
+			Expect(recorder.Body).To(MatchJSON(`{
+				"link": "http://here/orgs/org1/api-keys/0123456789ab",
+				"name": "feeder",
+				"prefix": "0123456789ab",
+				"scopes": ["garbanzos:write"],
+				"created-at": "2024-03-01T12:00:00Z",
+				"key": "0123456789ab.secret"
+			}`))
+		})
+
+		It("returns a bad request status code for invalid json", func() {
+			var err error
+			request, err = http.NewRequest(http.MethodPost, "/orgs/org1/api-keys", strings.NewReader("not json"))
+			Expect(err).NotTo(HaveOccurred())
+
+			router.ServeHTTP(recorder, request)
+
+			Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+			Expect(mockService.CreateCalled).NotTo(Receive())
+		})
+
+		It("returns validation errors", func() {
+			mockService.CreateOutput.ApiKeyOut <- data.APIKey{}
+			mockService.CreateOutput.Key <- ""
+			mockService.CreateOutput.Err <- services.NewValidationError(map[string][]string{
+				"Name": {"must be present"},
+			})
+
+			router.ServeHTTP(recorder, request)
+
+			Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+			Expect(recorder.Body).To(MatchJSON(`{
+				"code": 400,
+				"error": "Error creating new API key",
+				"errors": ["name must be present"],
+				"status": "Bad Request"
+			}`))
+		})
+
+		It("returns not found for an unknown org", func() {
+			mockService.CreateOutput.ApiKeyOut <- data.APIKey{}
+			mockService.CreateOutput.Key <- ""
+			mockService.CreateOutput.Err <- persistence.ErrOrgNotFound
+
+			router.ServeHTTP(recorder, request)
+
+			Expect(recorder.Code).To(Equal(http.StatusNotFound))
+		})
+	})
+
+	Describe("scopes", func() {
+		It("requires a scope for each method", func() {
+			grantScopes = false
+			for _, route := range []struct{ method, path, scope string }{
+				{http.MethodGet, "/orgs/org1/api-keys", "api-keys:read"},
+				{http.MethodPost, "/orgs/org1/api-keys", "api-keys:write"},
+			} {
+				recorder = httptest.NewRecorder()
+				var err error
+				request, err = http.NewRequest(route.method, route.path, nil)
+				Expect(err).NotTo(HaveOccurred())
+
+				router.ServeHTTP(recorder, request)
+
+				Expect(recorder.Code).To(Equal(http.StatusForbidden), route.method)
+				Expect(recorder.Header().Get("X-Required-Scope")).To(Equal(route.scope), route.method)
+			}
+		})
+	})
+})
@@ -0,0 +1,114 @@
+package apikey_test
+
+//go:generate hel
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/gorilla/mux"
+	"github.com/justinas/alice"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/myshkin5/effective-octo-garbanzo/api/handlers/apikey"
+	"github.com/myshkin5/effective-octo-garbanzo/persistence"
+)
+
+var _ = Describe("APIKey", func() {
+	var (
+		recorder    *httptest.ResponseRecorder
+		request     *http.Request
+		mockService *mockAPIKeyService
+		router      *mux.Router
+		grantScopes bool
+	)
+
+	BeforeEach(func() {
+		recorder = httptest.NewRecorder()
+		recorder.Code = 0
+
+		mockService = newMockAPIKeyService()
+
+		grantScopes = true
+		authorize := func(scope string) alice.Constructor {
+			return func(h http.Handler) http.Handler {
+				return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					if !grantScopes {
+						w.Header().Set("X-Required-Scope", scope)
+						w.WriteHeader(http.StatusForbidden)
+						return
+					}
+					h.ServeHTTP(w, r)
+				})
+			}
+		}
+
+		router = mux.NewRouter()
+		apikey.MapRoutes("http://here/", router, alice.Chain{}, authorize, mockService)
+	})
+
+	Describe("DELETE", func() {
+		BeforeEach(func() {
+			var err error
+			request, err = http.NewRequest(http.MethodDelete, "/orgs/org1/api-keys/0123456789ab", nil)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("revokes the key", func() {
+			mockService.DeleteByPrefixAndOrgNameOutput.Err <- nil
+
+			router.ServeHTTP(recorder, request)
+
+			Expect(recorder.Code).To(Equal(http.StatusNoContent))
+			Expect(mockService.DeleteByPrefixAndOrgNameInput.Prefix).To(Receive(Equal("0123456789ab")))
+			Expect(mockService.DeleteByPrefixAndOrgNameInput.OrgName).To(Receive(Equal("org1")))
+		})
+
+		It("returns not found for an unknown key", func() {
+			mockService.DeleteByPrefixAndOrgNameOutput.Err <- persistence.ErrNotFound
+
+			router.ServeHTTP(recorder, request)
+
+			Expect(recorder.Code).To(Equal(http.StatusNotFound))
+			Expect(recorder.Body).To(MatchJSON(`{
+				"code": 404,
+				"error": "API key 0123456789ab not found in org org1",
+				"status": "Not Found"
+			}`))
+		})
+
+		It("returns a JSON error when the key can't be deleted", func() {
+			mockService.DeleteByPrefixAndOrgNameOutput.Err <- errors.New("bad stuff")
+
+			router.ServeHTTP(recorder, request)
+
+			Expect(recorder.Code).To(Equal(http.StatusInternalServerError))
+			Expect(recorder.Body).To(MatchJSON(`{
+				"code": 500,
+				"error": "Error deleting API key",
+				"status": "Internal Server Error"
+			}`))
+		})
+	})
+
+	Describe("scopes", func() {
+		It("requires a scope for each method", func() {
+			grantScopes = false
+			for _, route := range []struct{ method, path, scope string }{
+				{http.MethodDelete, "/orgs/org1/api-keys/0123456789ab", "api-keys:delete"},
+			} {
+				recorder = httptest.NewRecorder()
+				var err error
+				request, err = http.NewRequest(route.method, route.path, nil)
+				Expect(err).NotTo(HaveOccurred())
+
+				router.ServeHTTP(recorder, request)
+
+				Expect(recorder.Code).To(Equal(http.StatusForbidden), route.method)
+				Expect(recorder.Header().Get("X-Required-Scope")).To(Equal(route.scope), route.method)
+			}
+		})
+	})
+})
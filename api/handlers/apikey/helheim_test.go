@@ -0,0 +1,86 @@
+// This file was generated by github.com/nelsam/hel.  Do not
+// edit this code by hand unless you *really* know what you're
+// doing.  Expect any changes made manually to be overwritten
+// the next time hel regenerates this file.
+
+package apikey_test
+
+import (
+	"context"
+
+	"github.com/myshkin5/effective-octo-garbanzo/persistence/data"
+)
+
+type mockAPIKeyService struct {
+	FetchByOrgNameCalled chan bool
+	FetchByOrgNameInput  struct {
+		Ctx     chan context.Context
+		OrgName chan string
+	}
+	FetchByOrgNameOutput struct {
+		ApiKeys chan []data.APIKey
+		Err     chan error
+	}
+	CreateCalled chan bool
+	CreateInput  struct {
+		Ctx      chan context.Context
+		OrgName  chan string
+		ApiKeyIn chan data.APIKey
+	}
+	CreateOutput struct {
+		ApiKeyOut chan data.APIKey
+		Key       chan string
+		Err       chan error
+	}
+	DeleteByPrefixAndOrgNameCalled chan bool
+	DeleteByPrefixAndOrgNameInput  struct {
+		Ctx     chan context.Context
+		Prefix  chan string
+		OrgName chan string
+	}
+	DeleteByPrefixAndOrgNameOutput struct {
+		Err chan error
+	}
+}
+
+func newMockAPIKeyService() *mockAPIKeyService {
+	m := &mockAPIKeyService{}
+	m.FetchByOrgNameCalled = make(chan bool, 100)
+	m.FetchByOrgNameInput.Ctx = make(chan context.Context, 100)
+	m.FetchByOrgNameInput.OrgName = make(chan string, 100)
+	m.FetchByOrgNameOutput.ApiKeys = make(chan []data.APIKey, 100)
+	m.FetchByOrgNameOutput.Err = make(chan error, 100)
+	m.CreateCalled = make(chan bool, 100)
+	m.CreateInput.Ctx = make(chan context.Context, 100)
+	m.CreateInput.OrgName = make(chan string, 100)
+	m.CreateInput.ApiKeyIn = make(chan data.APIKey, 100)
+	m.CreateOutput.ApiKeyOut = make(chan data.APIKey, 100)
+	m.CreateOutput.Key = make(chan string, 100)
+	m.CreateOutput.Err = make(chan error, 100)
+	m.DeleteByPrefixAndOrgNameCalled = make(chan bool, 100)
+	m.DeleteByPrefixAndOrgNameInput.Ctx = make(chan context.Context, 100)
+	m.DeleteByPrefixAndOrgNameInput.Prefix = make(chan string, 100)
+	m.DeleteByPrefixAndOrgNameInput.OrgName = make(chan string, 100)
+	m.DeleteByPrefixAndOrgNameOutput.Err = make(chan error, 100)
+	return m
+}
+func (m *mockAPIKeyService) FetchByOrgName(ctx context.Context, orgName string) (apiKeys []data.APIKey, err error) {
+	m.FetchByOrgNameCalled <- true
+	m.FetchByOrgNameInput.Ctx <- ctx
+	m.FetchByOrgNameInput.OrgName <- orgName
+	return <-m.FetchByOrgNameOutput.ApiKeys, <-m.FetchByOrgNameOutput.Err
+}
+func (m *mockAPIKeyService) Create(ctx context.Context, orgName string, apiKeyIn data.APIKey) (apiKeyOut data.APIKey, key string, err error) {
+	m.CreateCalled <- true
+	m.CreateInput.Ctx <- ctx
+	m.CreateInput.OrgName <- orgName
+	m.CreateInput.ApiKeyIn <- apiKeyIn
+	return <-m.CreateOutput.ApiKeyOut, <-m.CreateOutput.Key, <-m.CreateOutput.Err
+}
+func (m *mockAPIKeyService) DeleteByPrefixAndOrgName(ctx context.Context, prefix, orgName string) (err error) {
+	m.DeleteByPrefixAndOrgNameCalled <- true
+	m.DeleteByPrefixAndOrgNameInput.Ctx <- ctx
+	m.DeleteByPrefixAndOrgNameInput.Prefix <- prefix
+	m.DeleteByPrefixAndOrgNameInput.OrgName <- orgName
+	return <-m.DeleteByPrefixAndOrgNameOutput.Err
+}
@@ -0,0 +1,140 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/justinas/alice"
+
+	"github.com/myshkin5/effective-octo-garbanzo/api/handlers"
+	"github.com/myshkin5/effective-octo-garbanzo/persistence"
+	"github.com/myshkin5/effective-octo-garbanzo/persistence/data"
+	"github.com/myshkin5/effective-octo-garbanzo/services"
+)
+
+type AuditEvent struct {
+	Actor     string          `json:"actor"`
+	Action    string          `json:"action"`
+	Resource  string          `json:"resource"`
+	Before    json.RawMessage `json:"before,omitempty"`
+	After     json.RawMessage `json:"after,omitempty"`
+	RequestID string          `json:"request-id,omitempty"`
+	CreatedAt time.Time       `json:"created-at"`
+}
+
+type AuditEventList struct {
+	Link   string       `json:"link"`
+	Next   string       `json:"next,omitempty"`
+	Prev   string       `json:"prev,omitempty"`
+	Events []AuditEvent `json:"events"`
+}
+
+var fieldMapping = map[string]string{
+	"From":     "from",
+	"To":       "to",
+	"Resource": "resource",
+}
+
+type AuditLog interface {
+	FetchAll(ctx context.Context, filter persistence.AuditFilter, page persistence.Page) (events []data.AuditEvent, more bool, err error)
+}
+
+type audit struct {
+	auditLog AuditLog
+	baseURL  string
+}
+
+func MapRoutes(baseURL string, router *mux.Router, middleware alice.Chain, authorize handlers.Authorizer, auditLog AuditLog) {
+	handler := &audit{
+		auditLog: auditLog,
+		baseURL:  baseURL,
+	}
+	methodHandler := make(handlers.MethodHandler)
+	methodHandler[http.MethodGet] = authorize(handlers.ScopeAuditRead)(http.HandlerFunc(handler.get))
+	router.Handle("/audit", middleware.Then(methodHandler))
+}
+
+func (a *audit) get(w http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+	page, err := handlers.ParsePage(query)
+	if err != nil {
+		handlers.Error(req.Context(), w, "Invalid page", http.StatusBadRequest, err, fieldMapping)
+		return
+	}
+	// The audit log is always newest first
+	page.Sort = persistence.Sort{Descending: true}
+
+	filter, err := a.parseFilter(query.Get("from"), query.Get("to"), query.Get("resource"))
+	if err != nil {
+		handlers.Error(req.Context(), w, "Invalid filter", http.StatusBadRequest, err, fieldMapping)
+		return
+	}
+
+	events, more, err := a.auditLog.FetchAll(req.Context(), filter, page)
+	if err != nil {
+		handlers.Error(req.Context(), w, "Error fetching audit events", http.StatusInternalServerError, err, fieldMapping)
+		return
+	}
+
+	collectionURL := a.baseURL + "audit"
+	list := AuditEventList{
+		Link: collectionURL,
+		// Intentionally an empty slice so list is present in output even when empty
+		Events: []AuditEvent{},
+	}
+	var keys []handlers.PageKey
+	for _, event := range events {
+		list.Events = append(list.Events, a.fromPersistence(event))
+		keys = append(keys, handlers.PageKey{Id: event.Id})
+	}
+	list.Next, list.Prev = handlers.PageLinks(collectionURL, query, page, keys, more)
+
+	handlers.Respond(w, http.StatusOK, list)
+}
+
+// parseFilter reads the RFC 3339 bounds of the time range and the resource,
+// which may be either the link of a resource or its path.
+func (a *audit) parseFilter(from, to, resource string) (persistence.AuditFilter, error) {
+	var filter persistence.AuditFilter
+	errors := make(map[string][]string)
+	if from != "" {
+		var err error
+		filter.From, err = time.Parse(time.RFC3339, from)
+		if err != nil {
+			errors["From"] = append(errors["From"], "must be an RFC 3339 timestamp")
+		}
+	}
+	if to != "" {
+		var err error
+		filter.To, err = time.Parse(time.RFC3339, to)
+		if err != nil {
+			errors["To"] = append(errors["To"], "must be an RFC 3339 timestamp")
+		}
+	}
+	if !filter.From.IsZero() && !filter.To.IsZero() && !filter.From.Before(filter.To) {
+		errors["To"] = append(errors["To"], "must be after from")
+	}
+	filter.Resource = strings.Trim(strings.TrimPrefix(resource, a.baseURL), "/")
+
+	if len(errors) > 0 {
+		return persistence.AuditFilter{}, services.NewValidationError(errors)
+	}
+
+	return filter, nil
+}
+
+func (a *audit) fromPersistence(event data.AuditEvent) AuditEvent {
+	return AuditEvent{
+		Actor:     event.Actor,
+		Action:    event.Action,
+		Resource:  a.baseURL + event.Resource,
+		Before:    event.Before,
+		After:     event.After,
+		RequestID: event.RequestID,
+		CreatedAt: event.CreatedAt,
+	}
+}
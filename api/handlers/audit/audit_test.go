@@ -0,0 +1,290 @@
+package audit_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/justinas/alice"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/myshkin5/effective-octo-garbanzo/api/handlers/audit"
+	"github.com/myshkin5/effective-octo-garbanzo/persistence"
+	"github.com/myshkin5/effective-octo-garbanzo/persistence/data"
+)
+
+var _ = Describe("Audit", func() {
+	var (
+		recorder     *httptest.ResponseRecorder
+		request      *http.Request
+		mockAuditLog *mockAuditLog
+		router       *mux.Router
+		grantScopes  bool
+	)
+
+	BeforeEach(func() {
+		recorder = httptest.NewRecorder()
+		recorder.Code = 0
+
+		mockAuditLog = newMockAuditLog()
+
+		grantScopes = true
+		authorize := func(scope string) alice.Constructor {
+			return func(h http.Handler) http.Handler {
+				return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					if !grantScopes {
+						w.Header().Set("X-Required-Scope", scope)
+						w.WriteHeader(http.StatusForbidden)
+						return
+					}
+					h.ServeHTTP(w, r)
+				})
+			}
+		}
+
+		router = mux.NewRouter()
+		audit.MapRoutes("http://here/", router, alice.Chain{}, authorize, mockAuditLog)
+	})
+
+	Describe("GET", func() {
+		Context("happy path - no events", func() {
+			BeforeEach(func() {
+				var err error
+				request, err = http.NewRequest(http.MethodGet, "/audit", nil)
+				Expect(err).NotTo(HaveOccurred())
+
+				mockAuditLog.FetchAllOutput.Events <- []data.AuditEvent{}
+				mockAuditLog.FetchAllOutput.More <- false
+				mockAuditLog.FetchAllOutput.Err <- nil
+
+				router.ServeHTTP(recorder, request)
+			})
+
+			It("returns an ok status code", func() {
+				Expect(recorder.Code).To(Equal(http.StatusOK))
+			})
+
+			It("fetches the first page, newest first, without a filter", func() {
+				Expect(mockAuditLog.FetchAllInput.Filter).To(Receive(BeZero()))
+				Expect(mockAuditLog.FetchAllInput.Page).To(Receive(Equal(persistence.Page{
+					Limit: 100,
+					Sort:  persistence.Sort{Descending: true},
+				})))
+			})
+
+			It("returns an empty list in the body", func() {
+				Expect(recorder.Body).To(MatchJSON(`{
+					"link":   "http://here/audit",
+					"events": []
+				}`))
+			})
+		})
+
+		Context("happy path", func() {
+			BeforeEach(func() {
+				var err error
+				request, err = http.NewRequest(http.MethodGet, "/audit?limit=2", nil)
+				Expect(err).NotTo(HaveOccurred())
+
+				mockAuditLog.FetchAllOutput.Events <- []data.AuditEvent{
+					{
+						Id:        8,
+						Actor:     "user1",
+						Action:    "update",
+						Resource:  "octos/kraken",
+						Before:    []byte(`{"name":"kraken","version":1}`),
+						After:     []byte(`{"name":"kraken","version":2}`),
+						RequestID: "req2",
+						CreatedAt: time.Date(2020, 3, 4, 5, 6, 8, 0, time.UTC),
+					},
+					{
+						Id:        5,
+						Actor:     "api-key:abc",
+						Action:    "create",
+						Resource:  "octos/kraken",
+						After:     []byte(`{"name":"kraken","version":1}`),
+						RequestID: "req1",
+						CreatedAt: time.Date(2020, 3, 4, 5, 6, 7, 0, time.UTC),
+					},
+				}
+				mockAuditLog.FetchAllOutput.More <- true
+				mockAuditLog.FetchAllOutput.Err <- nil
+
+				router.ServeHTTP(recorder, request)
+			})
+
+			It("returns an ok status code", func() {
+				Expect(recorder.Code).To(Equal(http.StatusOK))
+			})
+
+			It("returns the events and a next link in the body", func() {
+				Expect(recorder.Body).To(MatchJSON(`{
+					"link":   "http://here/audit",
+					"next":   "http://here/audit?cursor=eyJhIjo1fQ&limit=2",
+					"events": [
+						{
+							"actor":      "user1",
+							"action":     "update",
+							"resource":   "http://here/octos/kraken",
+							"before":     {"name": "kraken", "version": 1},
+							"after":      {"name": "kraken", "version": 2},
+							"request-id": "req2",
+							"created-at": "2020-03-04T05:06:08Z"
+						},
+						{
+							"actor":      "api-key:abc",
+							"action":     "create",
+							"resource":   "http://here/octos/kraken",
+							"after":      {"name": "kraken", "version": 1},
+							"request-id": "req1",
+							"created-at": "2020-03-04T05:06:07Z"
+						}
+					]
+				}`))
+			})
+		})
+
+		Context("filters", func() {
+			BeforeEach(func() {
+				mockAuditLog.FetchAllOutput.Events <- []data.AuditEvent{}
+				mockAuditLog.FetchAllOutput.More <- false
+				mockAuditLog.FetchAllOutput.Err <- nil
+			})
+
+			It("fetches events in the time range", func() {
+				var err error
+				request, err = http.NewRequest(http.MethodGet,
+					"/audit?from=2020-03-04T05:06:07Z&to=2020-03-05T00:00:00%2B02:00", nil)
+				Expect(err).NotTo(HaveOccurred())
+
+				router.ServeHTTP(recorder, request)
+
+				Expect(recorder.Code).To(Equal(http.StatusOK))
+				var filter persistence.AuditFilter
+				Expect(mockAuditLog.FetchAllInput.Filter).To(Receive(&filter))
+				Expect(filter.From).To(BeTemporally("==", time.Date(2020, 3, 4, 5, 6, 7, 0, time.UTC)))
+				Expect(filter.To).To(BeTemporally("==", time.Date(2020, 3, 4, 22, 0, 0, 0, time.UTC)))
+				Expect(filter.Resource).To(BeEmpty())
+			})
+
+			It("fetches events of a resource given its path", func() {
+				var err error
+				request, err = http.NewRequest(http.MethodGet, "/audit?resource=octos/kraken", nil)
+				Expect(err).NotTo(HaveOccurred())
+
+				router.ServeHTTP(recorder, request)
+
+				Expect(recorder.Code).To(Equal(http.StatusOK))
+				Expect(mockAuditLog.FetchAllInput.Filter).To(Receive(Equal(persistence.AuditFilter{
+					Resource: "octos/kraken",
+				})))
+			})
+
+			It("fetches events of a resource given its link", func() {
+				var err error
+				request, err = http.NewRequest(http.MethodGet, "/audit?resource=http://here/octos/kraken/", nil)
+				Expect(err).NotTo(HaveOccurred())
+
+				router.ServeHTTP(recorder, request)
+
+				Expect(recorder.Code).To(Equal(http.StatusOK))
+				Expect(mockAuditLog.FetchAllInput.Filter).To(Receive(Equal(persistence.AuditFilter{
+					Resource: "octos/kraken",
+				})))
+			})
+		})
+
+		Context("invalid filter", func() {
+			It("returns a bad request status code for timestamps that aren't RFC 3339", func() {
+				var err error
+				request, err = http.NewRequest(http.MethodGet, "/audit?from=yesterday", nil)
+				Expect(err).NotTo(HaveOccurred())
+
+				router.ServeHTTP(recorder, request)
+
+				Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+				Expect(recorder.Body).To(MatchJSON(`{
+					"code": 400,
+					"error": "Invalid filter",
+					"errors": ["from must be an RFC 3339 timestamp"],
+					"status": "Bad Request"
+				}`))
+				Expect(mockAuditLog.FetchAllCalled).NotTo(Receive())
+			})
+
+			It("returns a bad request status code for an empty time range", func() {
+				var err error
+				request, err = http.NewRequest(http.MethodGet,
+					"/audit?from=2020-03-04T05:06:07Z&to=2020-03-04T05:06:07Z", nil)
+				Expect(err).NotTo(HaveOccurred())
+
+				router.ServeHTTP(recorder, request)
+
+				Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+				Expect(recorder.Body).To(MatchJSON(`{
+					"code": 400,
+					"error": "Invalid filter",
+					"errors": ["to must be after from"],
+					"status": "Bad Request"
+				}`))
+				Expect(mockAuditLog.FetchAllCalled).NotTo(Receive())
+			})
+		})
+
+		Context("invalid page", func() {
+			It("returns a bad request status code", func() {
+				var err error
+				request, err = http.NewRequest(http.MethodGet, "/audit?limit=0", nil)
+				Expect(err).NotTo(HaveOccurred())
+
+				router.ServeHTTP(recorder, request)
+
+				Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+				Expect(mockAuditLog.FetchAllCalled).NotTo(Receive())
+			})
+		})
+
+		Context("persistence error", func() {
+			BeforeEach(func() {
+				var err error
+				request, err = http.NewRequest(http.MethodGet, "/audit", nil)
+				Expect(err).NotTo(HaveOccurred())
+
+				mockAuditLog.FetchAllOutput.Events <- nil
+				mockAuditLog.FetchAllOutput.More <- false
+				mockAuditLog.FetchAllOutput.Err <- errors.New("not good")
+
+				router.ServeHTTP(recorder, request)
+			})
+
+			It("returns an internal server error status code", func() {
+				Expect(recorder.Code).To(Equal(http.StatusInternalServerError))
+			})
+
+			It("returns a JSON error", func() {
+				Expect(recorder.Body).To(MatchJSON(`{
+					"code": 500,
+					"error": "Error fetching audit events",
+					"status": "Internal Server Error"
+				}`))
+			})
+		})
+	})
+
+	Describe("scopes", func() {
+		It("requires a scope", func() {
+			grantScopes = false
+			var err error
+			request, err = http.NewRequest(http.MethodGet, "/audit", nil)
+			Expect(err).NotTo(HaveOccurred())
+
+			router.ServeHTTP(recorder, request)
+
+			Expect(recorder.Code).To(Equal(http.StatusForbidden))
+			Expect(recorder.Header().Get("X-Required-Scope")).To(Equal("audit:read"))
+		})
+	})
+})
@@ -0,0 +1,46 @@
+// This file was generated by github.com/nelsam/hel.  Do not
+// edit this code by hand unless you *really* know what you're
+// doing.  Expect any changes made manually to be overwritten
+// the next time hel regenerates this file.
+
+package audit_test
+
+import (
+	"context"
+
+	"github.com/myshkin5/effective-octo-garbanzo/persistence"
+	"github.com/myshkin5/effective-octo-garbanzo/persistence/data"
+)
+
+type mockAuditLog struct {
+	FetchAllCalled chan bool
+	FetchAllInput  struct {
+		Ctx    chan context.Context
+		Filter chan persistence.AuditFilter
+		Page   chan persistence.Page
+	}
+	FetchAllOutput struct {
+		Events chan []data.AuditEvent
+		More   chan bool
+		Err    chan error
+	}
+}
+
+func newMockAuditLog() *mockAuditLog {
+	m := &mockAuditLog{}
+	m.FetchAllCalled = make(chan bool, 100)
+	m.FetchAllInput.Ctx = make(chan context.Context, 100)
+	m.FetchAllInput.Filter = make(chan persistence.AuditFilter, 100)
+	m.FetchAllInput.Page = make(chan persistence.Page, 100)
+	m.FetchAllOutput.Events = make(chan []data.AuditEvent, 100)
+	m.FetchAllOutput.More = make(chan bool, 100)
+	m.FetchAllOutput.Err = make(chan error, 100)
+	return m
+}
+func (m *mockAuditLog) FetchAll(ctx context.Context, filter persistence.AuditFilter, page persistence.Page) (events []data.AuditEvent, more bool, err error) {
+	m.FetchAllCalled <- true
+	m.FetchAllInput.Ctx <- ctx
+	m.FetchAllInput.Filter <- filter
+	m.FetchAllInput.Page <- page
+	return <-m.FetchAllOutput.Events, <-m.FetchAllOutput.More, <-m.FetchAllOutput.Err
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -10,40 +11,57 @@ import (
 )
 
 const (
-	InvalidJSON = "Body of request was not valid JSON"
-	InvalidUUID = "Invalid UUID"
+	InvalidJSON           = "Body of request was not valid JSON"
+	InvalidUUID           = "Invalid UUID"
+	InvalidIdempotencyKey = "Invalid Idempotency-Key"
 )
 
-func Error(w http.ResponseWriter, error string, code int, err error, mapping map[string]string) {
-	var validationErrors map[string][]string
-	validationError, ok := err.(services.ValidationError)
-	if ok {
-		code = http.StatusBadRequest
-		validationErrors = validationError.Errors()
-	}
+func Error(ctx context.Context, w http.ResponseWriter, error string, code int, err error, mapping map[string]string) {
+	ret := ErrorBody(error, code, err, mapping)
+	code = ret["code"].(int)
 
+	logger := logs.FromContext(ctx)
 	message := "Returning %d, message %s"
 	messageWithError := message + ", error %v"
 	if code >= http.StatusInternalServerError {
 		if err != nil {
-			logs.Logger.Errorf(messageWithError, code, error, err)
+			logger.Errorf(messageWithError, code, error, err)
 		} else {
-			logs.Logger.Errorf(message, code, error)
+			logger.Errorf(message, code, error)
 		}
 	} else if code >= http.StatusBadRequest {
 		if err != nil {
-			logs.Logger.Warnf(messageWithError, code, error, err)
+			logger.Warnf(messageWithError, code, error, err)
 		} else {
-			logs.Logger.Warnf(message, code, error)
+			logger.Warnf(message, code, error)
 		}
 	} else {
 		if err != nil {
-			logs.Logger.Infof(messageWithError, code, error, err)
+			logger.Infof(messageWithError, code, error, err)
 		} else {
-			logs.Logger.Infof(message, code, error)
+			logger.Infof(message, code, error)
 		}
 	}
 
+	bytes, err := json.Marshal(ret)
+	if err != nil {
+		logger.Panic("Unexpected JSON marshal err: ", err)
+	}
+
+	w.WriteHeader(code)
+	w.Write(bytes)
+}
+
+// ErrorBody returns the standard error body without responding with it, e.g.
+// for one item of a batch. The code of a ValidationError is always 400.
+func ErrorBody(error string, code int, err error, mapping map[string]string) JSONObject {
+	var validationErrors map[string][]string
+	validationError, ok := err.(services.ValidationError)
+	if ok {
+		code = http.StatusBadRequest
+		validationErrors = validationError.Errors()
+	}
+
 	ret := JSONObject{
 		"code":   code,
 		"error":  error,
@@ -64,11 +82,5 @@ func Error(w http.ResponseWriter, error string, code int, err error, mapping map
 		ret["errors"] = errorList
 	}
 
-	bytes, err := json.Marshal(ret)
-	if err != nil {
-		logs.Logger.Panic("Unexpected JSON marshal err: ", err)
-	}
-
-	w.WriteHeader(code)
-	w.Write(bytes)
+	return ret
 }
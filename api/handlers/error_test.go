@@ -1,14 +1,20 @@
 package handlers_test
 
 import (
+	"context"
 	"encoding/json"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"os"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
 
 	"github.com/myshkin5/effective-octo-garbanzo/api/handlers"
+	"github.com/myshkin5/effective-octo-garbanzo/logs"
 	"github.com/myshkin5/effective-octo-garbanzo/services"
 )
 
@@ -22,7 +28,7 @@ var _ = Describe("Error", func() {
 			recorder = httptest.NewRecorder()
 			recorder.Code = 0
 
-			handlers.Error(recorder, "bad stuff!", http.StatusInternalServerError, nil, nil)
+			handlers.Error(context.Background(), recorder, "bad stuff!", http.StatusInternalServerError, nil, nil)
 		})
 
 		It("writes the error to a JSON body", func() {
@@ -45,7 +51,7 @@ var _ = Describe("Error", func() {
 				"FieldC": {"5", "6"},
 			})
 			mapping := map[string]string{"FieldA": "field-a", "FieldB": "field-b"}
-			handlers.Error(recorder, "bad stuff!", http.StatusInternalServerError, err, mapping)
+			handlers.Error(context.Background(), recorder, "bad stuff!", http.StatusInternalServerError, err, mapping)
 		})
 
 		It("writes the error to a JSON body", func() {
@@ -82,4 +88,32 @@ var _ = Describe("Error", func() {
 			Expect(errors).To(ContainElement("FieldC 6"))
 		})
 	})
+
+	Context("logging", func() {
+		var hook *test.Hook
+
+		BeforeEach(func() {
+			hook = test.NewGlobal()
+			logrus.SetLevel(logrus.InfoLevel)
+			logrus.SetOutput(ioutil.Discard)
+		})
+
+		AfterEach(func() {
+			logrus.SetLevel(logrus.PanicLevel)
+			logrus.SetOutput(os.Stderr)
+			logrus.StandardLogger().ReplaceHooks(make(logrus.LevelHooks))
+		})
+
+		It("logs with the fields of the request", func() {
+			recorder = httptest.NewRecorder()
+			ctx := logs.WithFields(context.Background(), logs.Fields{"request_id": "abc123"})
+
+			handlers.Error(ctx, recorder, "bad stuff!", http.StatusNotFound, nil, nil)
+
+			Expect(hook.LastEntry()).NotTo(BeNil())
+			Expect(hook.LastEntry().Level).To(Equal(logrus.WarnLevel))
+			Expect(hook.LastEntry().Message).To(Equal("Returning 404, message bad stuff!"))
+			Expect(hook.LastEntry().Context).To(Equal(ctx))
+		})
+	})
 })
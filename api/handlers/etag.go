@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/myshkin5/effective-octo-garbanzo/persistence"
+)
+
+// VersionETag is the strong entity tag of a row version. The tag includes the
+// row's id so a resource deleted and created again, which starts over at
+// version 1, never matches the tags of the resource it replaced.
+func VersionETag(id, version int) string {
+	return `"` + strconv.Itoa(id) + "-" + strconv.Itoa(version) + `"`
+}
+
+// RespondWithVersion is Respond with an ETag header of the row id and version
+// of the response's resource. A successful GET whose If-None-Match header
+// already names the tag is answered with 304 Not Modified and no body.
+func RespondWithVersion(w http.ResponseWriter, req *http.Request, code int, v interface{}, id, version int) {
+	bytes := marshal(v)
+	respondWithETag(w, req, code, bytes, VersionETag(id, version))
+}
+
+// RespondWithContentETag is RespondWithVersion for collections, which have no
+// version of their own. The ETag is a hash of the response's body.
+func RespondWithContentETag(w http.ResponseWriter, req *http.Request, code int, v interface{}) {
+	bytes := marshal(v)
+	sum := sha256.Sum256(bytes)
+	respondWithETag(w, req, code, bytes, `"`+hex.EncodeToString(sum[:16])+`"`)
+}
+
+func respondWithETag(w http.ResponseWriter, req *http.Request, code int, bytes []byte, etag string) {
+	w.Header().Set("ETag", etag)
+
+	ifNoneMatch := req.Header.Get("If-None-Match")
+	if code == http.StatusOK && (req.Method == http.MethodGet || req.Method == http.MethodHead) &&
+		ifNoneMatch != "" && matchesETag(ifNoneMatch, etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.WriteHeader(code)
+	w.Write(bytes)
+}
+
+// IfMatchVersion returns the row id and version named by the request's
+// If-Match header for a versioned update or delete. Both are 0, meaning any
+// row and version, when there is no header or it is "*". Only the first
+// version tag of a list is used. A header naming no version tag at all, such
+// as a weak or collection tag, can never match so it is a version mismatch.
+func IfMatchVersion(req *http.Request) (id, version int, err error) {
+	ifMatch := req.Header.Get("If-Match")
+	if ifMatch == "" {
+		return 0, 0, nil
+	}
+
+	for _, tag := range strings.Split(ifMatch, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" {
+			return 0, 0, nil
+		}
+		if len(tag) < 2 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+			continue
+		}
+		parts := strings.Split(tag[1:len(tag)-1], "-")
+		if len(parts) != 2 {
+			continue
+		}
+		id, idErr := strconv.Atoi(parts[0])
+		version, versionErr := strconv.Atoi(parts[1])
+		if idErr == nil && versionErr == nil && id > 0 && version > 0 {
+			return id, version, nil
+		}
+	}
+
+	return 0, 0, persistence.ErrVersionMismatch
+}
+
+// matchesETag reports if an If-None-Match header names etag or is "*". As
+// If-None-Match uses the weak comparison, weak tags match too.
+func matchesETag(ifNoneMatch, etag string) bool {
+	for _, tag := range strings.Split(ifNoneMatch, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == etag {
+			return true
+		}
+	}
+
+	return false
+}
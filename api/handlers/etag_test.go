@@ -0,0 +1,118 @@
+package handlers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/myshkin5/effective-octo-garbanzo/api/handlers"
+	"github.com/myshkin5/effective-octo-garbanzo/persistence"
+)
+
+var _ = Describe("ETags", func() {
+	var (
+		recorder *httptest.ResponseRecorder
+		request  *http.Request
+	)
+
+	BeforeEach(func() {
+		recorder = httptest.NewRecorder()
+
+		var err error
+		request, err = http.NewRequest(http.MethodGet, "/", nil)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	Describe("RespondWithVersion", func() {
+		It("responds with the id and version as the ETag", func() {
+			handlers.RespondWithVersion(recorder, request, http.StatusOK, map[string]string{"name": "kraken"}, 7, 3)
+
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(recorder.Header().Get("ETag")).To(Equal(`"7-3"`))
+			Expect(recorder.Body).To(MatchJSON(`{"name": "kraken"}`))
+		})
+
+		It("responds with not modified when If-None-Match names the version", func() {
+			request.Header.Set("If-None-Match", `"7-2", W/"7-3"`)
+
+			handlers.RespondWithVersion(recorder, request, http.StatusOK, map[string]string{"name": "kraken"}, 7, 3)
+
+			Expect(recorder.Code).To(Equal(http.StatusNotModified))
+			Expect(recorder.Header().Get("ETag")).To(Equal(`"7-3"`))
+			Expect(recorder.Body.Len()).To(BeZero())
+		})
+
+		It("responds normally when If-None-Match names other versions", func() {
+			request.Header.Set("If-None-Match", `"7-2"`)
+
+			handlers.RespondWithVersion(recorder, request, http.StatusOK, map[string]string{"name": "kraken"}, 7, 3)
+
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+		})
+
+		It("responds normally when If-None-Match names the version of another row", func() {
+			request.Header.Set("If-None-Match", `"6-3"`)
+
+			handlers.RespondWithVersion(recorder, request, http.StatusOK, map[string]string{"name": "kraken"}, 7, 3)
+
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+		})
+
+		It("ignores If-None-Match on anything but a GET", func() {
+			request.Method = http.MethodPut
+			request.Header.Set("If-None-Match", "*")
+
+			handlers.RespondWithVersion(recorder, request, http.StatusOK, map[string]string{"name": "kraken"}, 7, 3)
+
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+		})
+	})
+
+	Describe("RespondWithContentETag", func() {
+		It("changes the ETag with the content", func() {
+			handlers.RespondWithContentETag(recorder, request, http.StatusOK, []string{"kraken"})
+			etag := recorder.Header().Get("ETag")
+
+			recorder = httptest.NewRecorder()
+			handlers.RespondWithContentETag(recorder, request, http.StatusOK, []string{"cthulhu"})
+
+			Expect(recorder.Header().Get("ETag")).NotTo(Equal(etag))
+		})
+	})
+
+	Describe("IfMatchVersion", func() {
+		It("returns any version without a header", func() {
+			id, version, err := handlers.IfMatchVersion(request)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(id).To(Equal(0))
+			Expect(version).To(Equal(0))
+		})
+
+		It("returns any version for *", func() {
+			request.Header.Set("If-Match", "*")
+
+			id, version, err := handlers.IfMatchVersion(request)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(id).To(Equal(0))
+			Expect(version).To(Equal(0))
+		})
+
+		It("returns the id and version of the first version tag named", func() {
+			request.Header.Set("If-Match", `W/"7-2", "abc", "5", "7-5", "7-6"`)
+
+			id, version, err := handlers.IfMatchVersion(request)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(id).To(Equal(7))
+			Expect(version).To(Equal(5))
+		})
+
+		It("returns a version mismatch when no version tag is named", func() {
+			request.Header.Set("If-Match", `W/"7-2", "abc", "5", "7-5-1"`)
+
+			_, _, err := handlers.IfMatchVersion(request)
+			Expect(err).To(Equal(persistence.ErrVersionMismatch))
+		})
+	})
+})
@@ -0,0 +1,96 @@
+package garbanzo
+
+import (
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/myshkin5/effective-octo-garbanzo/persistence"
+	"github.com/myshkin5/effective-octo-garbanzo/persistence/data"
+	"github.com/myshkin5/effective-octo-garbanzo/services"
+)
+
+var (
+	comparisonParam = regexp.MustCompile(`^(.+)\[(.*)\]$`)
+
+	operators = map[string]persistence.Operator{
+		"eq":  persistence.Equal,
+		"gt":  persistence.GreaterThan,
+		"gte": persistence.GreaterThanOrEqual,
+		"lt":  persistence.LessThan,
+		"lte": persistence.LessThanOrEqual,
+	}
+
+	// sortValues maps the fields garbanzos may be sorted by to the field's
+	// value as carried in page cursors, which for a type is its id.
+	sortValues = map[string]func(garbanzo data.Garbanzo) float64{
+		"GarbanzoType": func(garbanzo data.Garbanzo) float64 { return float64(garbanzo.GarbanzoType.Id) },
+		"DiameterMM":   func(garbanzo data.Garbanzo) float64 { return float64(garbanzo.DiameterMM) },
+	}
+)
+
+// parseQuery reads the filter and sort query parameters of a garbanzo
+// collection request. Other query parameters are ignored. Garbanzo types are
+// only identified by name as the service validates them.
+func parseQuery(query url.Values) (persistence.GarbanzoFilter, persistence.Sort, error) {
+	var filter persistence.GarbanzoFilter
+	var sort persistence.Sort
+	errors := map[string][]string{}
+
+	for param, values := range query {
+		if param == "type" {
+			for _, value := range values {
+				filter.GarbanzoTypes = append(filter.GarbanzoTypes, data.GarbanzoType{Name: value})
+			}
+			continue
+		}
+
+		matches := comparisonParam.FindStringSubmatch(param)
+		if matches == nil {
+			continue
+		}
+		operator, ok := operators[matches[2]]
+		if fieldFor(matches[1]) != "DiameterMM" || !ok {
+			errors[param] = append(errors[param], "is not a supported filter")
+			continue
+		}
+		for _, value := range values {
+			diameterMM, err := strconv.ParseFloat(value, 32)
+			if err != nil {
+				errors["DiameterMM"] = append(errors["DiameterMM"], "filter must be a number")
+				continue
+			}
+			filter.DiameterMM = append(filter.DiameterMM, persistence.Comparison{
+				Operator: operator,
+				Value:    float32(diameterMM),
+			})
+		}
+	}
+
+	if value := query.Get("sort"); value != "" {
+		sort.Descending = strings.HasPrefix(value, "-")
+		sort.Field = fieldFor(strings.TrimPrefix(value, "-"))
+		if _, ok := sortValues[sort.Field]; !ok {
+			errors["sort"] = append(errors["sort"], "must be type or diameter-mm, optionally prefixed with -")
+		}
+	}
+
+	if len(errors) > 0 {
+		return persistence.GarbanzoFilter{}, persistence.Sort{}, services.NewValidationError(errors)
+	}
+
+	return filter, sort, nil
+}
+
+// fieldFor returns the field which is mapped to name in the API or the empty
+// string if there is no such field.
+func fieldFor(name string) string {
+	for field, mappedName := range fieldMapping {
+		if mappedName == name {
+			return field
+		}
+	}
+
+	return ""
+}
@@ -2,6 +2,7 @@ package garbanzo
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
 
@@ -12,6 +13,7 @@ import (
 	"github.com/myshkin5/effective-octo-garbanzo/api/handlers"
 	"github.com/myshkin5/effective-octo-garbanzo/persistence"
 	"github.com/myshkin5/effective-octo-garbanzo/persistence/data"
+	"github.com/myshkin5/effective-octo-garbanzo/services"
 )
 
 type Garbanzo struct {
@@ -21,16 +23,25 @@ type Garbanzo struct {
 }
 
 var fieldMapping = map[string]string{
-	"Link":         "link",
-	"GarbanzoType": "type",
-	"DiameterMM":   "diameter-mm",
+	"Link":           "link",
+	"GarbanzoType":   "type",
+	"DiameterMM":     "diameter-mm",
+	"TargetOctoName": "octo",
+}
+
+type Move struct {
+	TargetOctoName string `json:"octo"`
 }
 
 type GarbanzoService interface {
-	FetchByOctoName(ctx context.Context, octoName string) (garbanzos []data.Garbanzo, err error)
+	FetchByOctoName(ctx context.Context, octoName string, filter persistence.GarbanzoFilter, page persistence.Page) (garbanzos []data.Garbanzo, more bool, err error)
 	FetchByAPIUUIDAndOctoName(ctx context.Context, apiUUID uuid.UUID, octoName string) (garbanzo data.Garbanzo, err error)
 	Create(ctx context.Context, octoName string, garbanzoIn data.Garbanzo) (garbanzoOut data.Garbanzo, err error)
-	DeleteByAPIUUIDAndOctoName(ctx context.Context, apiUUID uuid.UUID, octoName string) (err error)
+	CreateIdempotently(ctx context.Context, octoName string, garbanzoIn data.Garbanzo, request data.IdempotentRequest, respond func(garbanzo data.Garbanzo) []byte) (response []byte, replayed bool, err error)
+	CreateBatch(ctx context.Context, octoName string, garbanzosIn []data.Garbanzo, bestEffort bool) (results []services.BatchResult, err error)
+	UpdateByAPIUUIDAndOctoName(ctx context.Context, apiUUID uuid.UUID, octoName string, garbanzoIn data.Garbanzo) (garbanzoOut data.Garbanzo, err error)
+	MoveByAPIUUIDAndOctoName(ctx context.Context, apiUUID uuid.UUID, octoName, targetOctoName string, id, version int) (garbanzoOut data.Garbanzo, err error)
+	DeleteByAPIUUIDAndOctoName(ctx context.Context, apiUUID uuid.UUID, octoName string, id, version int) (err error)
 }
 
 type garbanzo struct {
@@ -38,52 +49,190 @@ type garbanzo struct {
 	baseURL         string
 }
 
-func MapRoutes(baseURL string, router *mux.Router, middleware alice.Chain, garbanzoService GarbanzoService) {
+func MapRoutes(baseURL string, router *mux.Router, middleware alice.Chain, authorize handlers.Authorizer, requireIfMatch alice.Constructor, garbanzoService GarbanzoService) {
 	handler := &garbanzo{
 		garbanzoService: garbanzoService,
 		baseURL:         baseURL,
 	}
 	methodHandler := make(handlers.MethodHandler)
-	methodHandler[http.MethodGet] = http.HandlerFunc(handler.get)
-	methodHandler[http.MethodDelete] = http.HandlerFunc(handler.delete)
+	methodHandler[http.MethodGet] = authorize(handlers.ScopeGarbanzosRead)(http.HandlerFunc(handler.get))
+	methodHandler[http.MethodPut] = authorize(handlers.ScopeGarbanzosWrite)(requireIfMatch(http.HandlerFunc(handler.put)))
+	methodHandler[http.MethodPatch] = authorize(handlers.ScopeGarbanzosWrite)(requireIfMatch(http.HandlerFunc(handler.patch)))
+	methodHandler[http.MethodDelete] = authorize(handlers.ScopeGarbanzosDelete)(requireIfMatch(http.HandlerFunc(handler.delete)))
 	router.Handle("/octos/{octoName}/garbanzos/{apiUUID}", middleware.Then(methodHandler))
+
+	moveMethodHandler := make(handlers.MethodHandler)
+	moveMethodHandler[http.MethodPost] = authorize(handlers.ScopeGarbanzosWrite)(requireIfMatch(http.HandlerFunc(handler.move)))
+	router.Handle("/octos/{octoName}/garbanzos/{apiUUID}/move", middleware.Then(moveMethodHandler))
 }
 
 func (g *garbanzo) get(w http.ResponseWriter, req *http.Request) {
 	vars := mux.Vars(req)
 	apiUUID, err := uuid.FromString(vars["apiUUID"])
 	if err != nil {
-		handlers.Error(w, handlers.InvalidUUID, http.StatusBadRequest, err, fieldMapping)
+		handlers.Error(req.Context(), w, handlers.InvalidUUID, http.StatusBadRequest, err, fieldMapping)
 		return
 	}
 
 	octoName := vars["octoName"]
 	garbanzo, err := g.garbanzoService.FetchByAPIUUIDAndOctoName(req.Context(), apiUUID, octoName)
 	if err == persistence.ErrNotFound {
-		handlers.Error(w, fmt.Sprintf("Garbanzo %s not found", apiUUID), http.StatusNotFound, err, fieldMapping)
+		handlers.Error(req.Context(), w, fmt.Sprintf("Garbanzo %s not found", apiUUID), http.StatusNotFound, err, fieldMapping)
+		return
+	} else if err != nil {
+		handlers.Error(req.Context(), w, "Error fetching garbanzo", http.StatusInternalServerError, err, fieldMapping)
+		return
+	}
+
+	handlers.RespondWithVersion(w, req, http.StatusOK, fromPersistence(garbanzo, g.baseURL, octoName), garbanzo.Id, garbanzo.Version)
+}
+
+func (g *garbanzo) put(w http.ResponseWriter, req *http.Request) {
+	vars := mux.Vars(req)
+	apiUUID, err := uuid.FromString(vars["apiUUID"])
+	if err != nil {
+		handlers.Error(req.Context(), w, handlers.InvalidUUID, http.StatusBadRequest, err, fieldMapping)
+		return
+	}
+
+	var dto Garbanzo
+	err = json.NewDecoder(req.Body).Decode(&dto)
+	if err != nil {
+		handlers.Error(req.Context(), w, handlers.InvalidJSON, http.StatusBadRequest, err, fieldMapping)
+		return
+	}
+
+	id, version, err := handlers.IfMatchVersion(req)
+	if err != nil {
+		handlers.Error(req.Context(), w, fmt.Sprintf("Garbanzo %s has been modified", apiUUID), http.StatusPreconditionFailed, err, fieldMapping)
+		return
+	}
+
+	g.update(w, req, apiUUID, vars["octoName"], dto, id, version)
+}
+
+func (g *garbanzo) patch(w http.ResponseWriter, req *http.Request) {
+	vars := mux.Vars(req)
+	apiUUID, err := uuid.FromString(vars["apiUUID"])
+	if err != nil {
+		handlers.Error(req.Context(), w, handlers.InvalidUUID, http.StatusBadRequest, err, fieldMapping)
+		return
+	}
+
+	id, version, err := handlers.IfMatchVersion(req)
+	if err != nil {
+		handlers.Error(req.Context(), w, fmt.Sprintf("Garbanzo %s has been modified", apiUUID), http.StatusPreconditionFailed, err, fieldMapping)
+		return
+	}
+
+	octoName := vars["octoName"]
+	garbanzo, err := g.garbanzoService.FetchByAPIUUIDAndOctoName(req.Context(), apiUUID, octoName)
+	if err == persistence.ErrNotFound {
+		handlers.Error(req.Context(), w, fmt.Sprintf("Garbanzo %s not found", apiUUID), http.StatusNotFound, err, fieldMapping)
+		return
+	} else if err != nil {
+		handlers.Error(req.Context(), w, "Error fetching garbanzo", http.StatusInternalServerError, err, fieldMapping)
+		return
+	}
+
+	dto := fromPersistence(garbanzo, g.baseURL, octoName)
+	err = handlers.MergePatch(&dto, req.Body)
+	if err != nil {
+		handlers.Error(req.Context(), w, handlers.InvalidJSON, http.StatusBadRequest, err, fieldMapping)
+		return
+	}
+
+	// The patch was merged onto the fetched version so only update that
+	// version, otherwise a concurrent update would be silently overwritten
+	if version == 0 {
+		id, version = garbanzo.Id, garbanzo.Version
+	}
+	g.update(w, req, apiUUID, octoName, dto, id, version)
+}
+
+func (g *garbanzo) update(w http.ResponseWriter, req *http.Request, apiUUID uuid.UUID, octoName string, dto Garbanzo, id, version int) {
+	garbanzo, err := g.garbanzoService.UpdateByAPIUUIDAndOctoName(req.Context(), apiUUID, octoName, data.Garbanzo{
+		Id:           id,
+		GarbanzoType: data.GarbanzoType{Name: dto.GarbanzoType},
+		DiameterMM:   dto.DiameterMM,
+		Version:      version,
+	})
+	if err == persistence.ErrNotFound {
+		handlers.Error(req.Context(), w, fmt.Sprintf("Garbanzo %s not found", apiUUID), http.StatusNotFound, err, fieldMapping)
+		return
+	} else if err == persistence.ErrVersionMismatch {
+		handlers.Error(req.Context(), w, fmt.Sprintf("Garbanzo %s has been modified", apiUUID), http.StatusPreconditionFailed, err, fieldMapping)
 		return
 	} else if err != nil {
-		handlers.Error(w, "Error fetching garbanzo", http.StatusInternalServerError, err, fieldMapping)
+		handlers.Error(req.Context(), w, "Error updating garbanzo", http.StatusInternalServerError, err, fieldMapping)
 		return
 	}
 
-	handlers.Respond(w, http.StatusOK, fromPersistence(garbanzo, g.baseURL, octoName))
+	handlers.RespondWithVersion(w, req, http.StatusOK, fromPersistence(garbanzo, g.baseURL, octoName), garbanzo.Id, garbanzo.Version)
+}
+
+func (g *garbanzo) move(w http.ResponseWriter, req *http.Request) {
+	vars := mux.Vars(req)
+	apiUUID, err := uuid.FromString(vars["apiUUID"])
+	if err != nil {
+		handlers.Error(req.Context(), w, handlers.InvalidUUID, http.StatusBadRequest, err, fieldMapping)
+		return
+	}
+
+	var dto Move
+	err = json.NewDecoder(req.Body).Decode(&dto)
+	if err != nil {
+		handlers.Error(req.Context(), w, handlers.InvalidJSON, http.StatusBadRequest, err, fieldMapping)
+		return
+	}
+
+	id, version, err := handlers.IfMatchVersion(req)
+	if err != nil {
+		handlers.Error(req.Context(), w, fmt.Sprintf("Garbanzo %s has been modified", apiUUID), http.StatusPreconditionFailed, err, fieldMapping)
+		return
+	}
+
+	garbanzo, err := g.garbanzoService.MoveByAPIUUIDAndOctoName(req.Context(), apiUUID, vars["octoName"], dto.TargetOctoName, id, version)
+	if err == persistence.ErrNotFound {
+		handlers.Error(req.Context(), w, fmt.Sprintf("Garbanzo %s not found", apiUUID), http.StatusNotFound, err, fieldMapping)
+		return
+	} else if err == persistence.ErrVersionMismatch {
+		handlers.Error(req.Context(), w, fmt.Sprintf("Garbanzo %s has been modified", apiUUID), http.StatusPreconditionFailed, err, fieldMapping)
+		return
+	} else if err == services.ErrTargetOctoNotFound {
+		handlers.Error(req.Context(), w, fmt.Sprintf("Target octo '%s' not found", dto.TargetOctoName), http.StatusConflict, err, fieldMapping)
+		return
+	} else if err != nil {
+		handlers.Error(req.Context(), w, "Error moving garbanzo", http.StatusInternalServerError, err, fieldMapping)
+		return
+	}
+
+	handlers.RespondWithVersion(w, req, http.StatusOK, fromPersistence(garbanzo, g.baseURL, dto.TargetOctoName), garbanzo.Id, garbanzo.Version)
 }
 
 func (g *garbanzo) delete(w http.ResponseWriter, req *http.Request) {
 	vars := mux.Vars(req)
 	apiUUID, err := uuid.FromString(vars["apiUUID"])
 	if err != nil {
-		handlers.Error(w, handlers.InvalidUUID, http.StatusBadRequest, err, fieldMapping)
+		handlers.Error(req.Context(), w, handlers.InvalidUUID, http.StatusBadRequest, err, fieldMapping)
 		return
 	}
 
-	err = g.garbanzoService.DeleteByAPIUUIDAndOctoName(req.Context(), apiUUID, vars["octoName"])
+	id, version, err := handlers.IfMatchVersion(req)
+	if err != nil {
+		handlers.Error(req.Context(), w, fmt.Sprintf("Garbanzo %s has been modified", apiUUID), http.StatusPreconditionFailed, err, fieldMapping)
+		return
+	}
+
+	err = g.garbanzoService.DeleteByAPIUUIDAndOctoName(req.Context(), apiUUID, vars["octoName"], id, version)
 	if err == persistence.ErrNotFound {
-		handlers.Error(w, fmt.Sprintf("Garbanzo %s not found", apiUUID), http.StatusNotFound, err, fieldMapping)
+		handlers.Error(req.Context(), w, fmt.Sprintf("Garbanzo %s not found", apiUUID), http.StatusNotFound, err, fieldMapping)
+		return
+	} else if err == persistence.ErrVersionMismatch {
+		handlers.Error(req.Context(), w, fmt.Sprintf("Garbanzo %s has been modified", apiUUID), http.StatusPreconditionFailed, err, fieldMapping)
 		return
 	} else if err != nil {
-		handlers.Error(w, "Error fetching garbanzo", http.StatusInternalServerError, err, fieldMapping)
+		handlers.Error(req.Context(), w, "Error fetching garbanzo", http.StatusInternalServerError, err, fieldMapping)
 		return
 	}
 
@@ -93,7 +242,7 @@ func (g *garbanzo) delete(w http.ResponseWriter, req *http.Request) {
 func fromPersistence(garbanzo data.Garbanzo, baseURL, octoName string) Garbanzo {
 	return Garbanzo{
 		Link:         fmt.Sprintf("%soctos/%s/garbanzos/%s", baseURL, octoName, garbanzo.APIUUID.String()),
-		GarbanzoType: garbanzo.GarbanzoType.String(),
+		GarbanzoType: garbanzo.GarbanzoType.Name,
 		DiameterMM:   garbanzo.DiameterMM,
 	}
 }
@@ -11,6 +11,32 @@ import (
 	"github.com/myshkin5/effective-octo-garbanzo/api/handlers"
 	"github.com/myshkin5/effective-octo-garbanzo/persistence"
 	"github.com/myshkin5/effective-octo-garbanzo/persistence/data"
+	"github.com/myshkin5/effective-octo-garbanzo/services"
+)
+
+type GarbanzoList struct {
+	Link      string     `json:"link"`
+	Next      string     `json:"next,omitempty"`
+	Prev      string     `json:"prev,omitempty"`
+	Garbanzos []Garbanzo `json:"garbanzos"`
+}
+
+// BatchResult is the outcome of creating one garbanzo of a batch, either the
+// new garbanzo or the standard error body.
+type BatchResult struct {
+	Code     int                 `json:"code"`
+	Garbanzo *Garbanzo           `json:"garbanzo,omitempty"`
+	Error    handlers.JSONObject `json:"error,omitempty"`
+}
+
+type BatchResponse struct {
+	Link    string        `json:"link"`
+	Results []BatchResult `json:"results"`
+}
+
+const (
+	allOrNothing = "all-or-nothing"
+	bestEffort   = "best-effort"
 )
 
 type garbanzoCollection struct {
@@ -18,60 +44,161 @@ type garbanzoCollection struct {
 	baseURL         string
 }
 
-func MapCollectionRoutes(baseURL string, router *mux.Router, middleware alice.Chain, garbanzoService GarbanzoService) {
+func MapCollectionRoutes(baseURL string, router *mux.Router, middleware alice.Chain, authorize handlers.Authorizer, garbanzoService GarbanzoService) {
 	handler := &garbanzoCollection{
 		garbanzoService: garbanzoService,
 		baseURL:         baseURL,
 	}
 	methodHandler := make(handlers.MethodHandler)
-	methodHandler[http.MethodGet] = http.HandlerFunc(handler.get)
-	methodHandler[http.MethodPost] = http.HandlerFunc(handler.post)
+	methodHandler[http.MethodGet] = authorize(handlers.ScopeGarbanzosRead)(http.HandlerFunc(handler.get))
+	methodHandler[http.MethodPost] = authorize(handlers.ScopeGarbanzosWrite)(http.HandlerFunc(handler.post))
 	router.Handle("/octos/{octoName}/garbanzos", middleware.Then(methodHandler))
+
+	batchMethodHandler := make(handlers.MethodHandler)
+	batchMethodHandler[http.MethodPost] = authorize(handlers.ScopeGarbanzosWrite)(http.HandlerFunc(handler.batch))
+	router.Handle("/octos/{octoName}/garbanzos:batch", middleware.Then(batchMethodHandler))
 }
 
 func (g *garbanzoCollection) get(w http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+	page, err := handlers.ParsePage(query)
+	if err != nil {
+		handlers.Error(req.Context(), w, "Invalid page", http.StatusBadRequest, err, fieldMapping)
+		return
+	}
+
+	filter, sort, err := parseQuery(query)
+	if err != nil {
+		handlers.Error(req.Context(), w, "Invalid query parameters", http.StatusBadRequest, err, fieldMapping)
+		return
+	}
+	page.Sort = sort
+
 	octoName := mux.Vars(req)["octoName"]
-	garbanzos, err := g.garbanzoService.FetchByOctoName(req.Context(), octoName)
+	garbanzos, more, err := g.garbanzoService.FetchByOctoName(req.Context(), octoName, filter, page)
 	if err != nil {
-		handlers.Error(w, "Error fetching garbanzos", http.StatusInternalServerError, err, fieldMapping)
+		handlers.Error(req.Context(), w, "Error fetching garbanzos", http.StatusInternalServerError, err, fieldMapping)
 		return
 	}
 
-	// Intentionally an empty slice so list is present in output even when empty
-	list := []Garbanzo{}
+	collectionURL := fmt.Sprintf("%soctos/%s/garbanzos", g.baseURL, octoName)
+	list := GarbanzoList{
+		Link: collectionURL,
+		// Intentionally an empty slice so list is present in output even when empty
+		Garbanzos: []Garbanzo{},
+	}
+	var keys []handlers.PageKey
 	for _, garbanzo := range garbanzos {
-		list = append(list, fromPersistence(garbanzo, g.baseURL, octoName))
+		list.Garbanzos = append(list.Garbanzos, fromPersistence(garbanzo, g.baseURL, octoName))
+		key := handlers.PageKey{Id: garbanzo.Id}
+		if sort.Field != "" {
+			key.SortValue = sortValues[sort.Field](garbanzo)
+		}
+		keys = append(keys, key)
 	}
+	list.Next, list.Prev = handlers.PageLinks(collectionURL, query, page, keys, more)
 
-	handlers.Respond(w, http.StatusOK, list)
+	handlers.RespondWithContentETag(w, req, http.StatusOK, list)
 }
 
 func (g *garbanzoCollection) post(w http.ResponseWriter, req *http.Request) {
-	var dto Garbanzo
-	err := json.NewDecoder(req.Body).Decode(&dto)
+	request, body, err := handlers.ReadIdempotentRequest(req)
 	if err != nil {
-		handlers.Error(w, handlers.InvalidJSON, http.StatusBadRequest, err, fieldMapping)
+		handlers.Error(req.Context(), w, handlers.InvalidIdempotencyKey, http.StatusBadRequest, err, fieldMapping)
 		return
 	}
 
-	garbanzoType, err := data.GarbanzoTypeFromString(dto.GarbanzoType)
+	var dto Garbanzo
+	err = json.NewDecoder(body).Decode(&dto)
 	if err != nil {
-		handlers.Error(w, err.Error(), http.StatusBadRequest, err, fieldMapping)
+		handlers.Error(req.Context(), w, handlers.InvalidJSON, http.StatusBadRequest, err, fieldMapping)
 		return
 	}
 
 	octoName := mux.Vars(req)["octoName"]
-	garbanzo, err := g.garbanzoService.Create(req.Context(), octoName, data.Garbanzo{
-		GarbanzoType: garbanzoType,
+	garbanzoIn := data.Garbanzo{
+		GarbanzoType: data.GarbanzoType{Name: dto.GarbanzoType},
 		DiameterMM:   dto.DiameterMM,
-	})
+	}
+	var garbanzo data.Garbanzo
+	var response []byte
+	var replayed bool
+	if request.Key == "" {
+		garbanzo, err = g.garbanzoService.Create(req.Context(), octoName, garbanzoIn)
+	} else {
+		response, replayed, err = g.garbanzoService.CreateIdempotently(req.Context(), octoName, garbanzoIn, request, func(garbanzo data.Garbanzo) []byte {
+			return handlers.StoredResponse(http.StatusCreated, fromPersistence(garbanzo, g.baseURL, octoName), garbanzo.Id, garbanzo.Version)
+		})
+	}
+	if err == persistence.ErrNotFound {
+		handlers.Error(req.Context(), w, fmt.Sprintf("Parent octo '%s' not found", octoName), http.StatusConflict, err, fieldMapping)
+		return
+	} else if err == services.ErrIdempotencyKeyReused {
+		handlers.Error(req.Context(), w, fmt.Sprintf("Idempotency-Key %s was used for a different request", request.Key), http.StatusUnprocessableEntity, err, fieldMapping)
+		return
+	} else if err == services.ErrIdempotencyKeyInUse {
+		handlers.Error(req.Context(), w, fmt.Sprintf("A request with Idempotency-Key %s is already in progress", request.Key), http.StatusConflict, err, fieldMapping)
+		return
+	} else if err != nil {
+		handlers.Error(req.Context(), w, "Error creating new garbanzo", http.StatusInternalServerError, err, fieldMapping)
+		return
+	}
+
+	if request.Key != "" {
+		handlers.RespondStored(w, response, replayed)
+		return
+	}
+	handlers.RespondWithVersion(w, req, http.StatusCreated, fromPersistence(garbanzo, g.baseURL, octoName), garbanzo.Id, garbanzo.Version)
+}
+
+func (g *garbanzoCollection) batch(w http.ResponseWriter, req *http.Request) {
+	mode := req.URL.Query().Get("mode")
+	if mode != "" && mode != allOrNothing && mode != bestEffort {
+		handlers.Error(req.Context(), w, fmt.Sprintf("Invalid mode, must be '%s' or '%s'", allOrNothing, bestEffort), http.StatusBadRequest, nil, fieldMapping)
+		return
+	}
+
+	var dtos []Garbanzo
+	err := json.NewDecoder(req.Body).Decode(&dtos)
+	if err != nil {
+		handlers.Error(req.Context(), w, handlers.InvalidJSON, http.StatusBadRequest, err, fieldMapping)
+		return
+	}
+
+	octoName := mux.Vars(req)["octoName"]
+	var garbanzosIn []data.Garbanzo
+	for _, dto := range dtos {
+		garbanzosIn = append(garbanzosIn, data.Garbanzo{
+			GarbanzoType: data.GarbanzoType{Name: dto.GarbanzoType},
+			DiameterMM:   dto.DiameterMM,
+		})
+	}
+	results, err := g.garbanzoService.CreateBatch(req.Context(), octoName, garbanzosIn, mode == bestEffort)
 	if err == persistence.ErrNotFound {
-		handlers.Error(w, fmt.Sprintf("Parent octo '%s' not found", octoName), http.StatusConflict, err, fieldMapping)
+		handlers.Error(req.Context(), w, fmt.Sprintf("Parent octo '%s' not found", octoName), http.StatusConflict, err, fieldMapping)
 		return
 	} else if err != nil {
-		handlers.Error(w, "Error creating new garbanzo", http.StatusInternalServerError, err, fieldMapping)
+		handlers.Error(req.Context(), w, "Error creating new garbanzos", http.StatusInternalServerError, err, fieldMapping)
 		return
 	}
 
-	handlers.Respond(w, http.StatusCreated, fromPersistence(garbanzo, g.baseURL, octoName))
+	response := BatchResponse{
+		Link:    fmt.Sprintf("%soctos/%s/garbanzos", g.baseURL, octoName),
+		Results: []BatchResult{},
+	}
+	for _, result := range results {
+		switch result.Err {
+		case nil:
+			garbanzo := fromPersistence(result.Garbanzo, g.baseURL, octoName)
+			response.Results = append(response.Results, BatchResult{Code: http.StatusCreated, Garbanzo: &garbanzo})
+		case services.ErrBatchInvalid:
+			error := handlers.ErrorBody("Not created because another garbanzo is invalid", http.StatusFailedDependency, result.Err, fieldMapping)
+			response.Results = append(response.Results, BatchResult{Code: http.StatusFailedDependency, Error: error})
+		default:
+			error := handlers.ErrorBody("Error creating new garbanzo", http.StatusBadRequest, result.Err, fieldMapping)
+			response.Results = append(response.Results, BatchResult{Code: http.StatusBadRequest, Error: error})
+		}
+	}
+
+	handlers.Respond(w, http.StatusMultiStatus, response)
 }
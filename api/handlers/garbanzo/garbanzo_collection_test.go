@@ -1,6 +1,7 @@
 package garbanzo_test
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
@@ -13,9 +14,11 @@ import (
 	. "github.com/onsi/gomega"
 	"github.com/satori/go.uuid"
 
+	"github.com/myshkin5/effective-octo-garbanzo/api/handlers"
 	"github.com/myshkin5/effective-octo-garbanzo/api/handlers/garbanzo"
 	"github.com/myshkin5/effective-octo-garbanzo/persistence"
 	"github.com/myshkin5/effective-octo-garbanzo/persistence/data"
+	"github.com/myshkin5/effective-octo-garbanzo/services"
 )
 
 var _ = Describe("GarbanzoCollection", func() {
@@ -28,6 +31,7 @@ var _ = Describe("GarbanzoCollection", func() {
 		request     *http.Request
 		mockService *mockGarbanzoService
 		router      *mux.Router
+		grantScopes bool
 	)
 
 	BeforeEach(func() {
@@ -36,8 +40,22 @@ var _ = Describe("GarbanzoCollection", func() {
 
 		mockService = newMockGarbanzoService()
 
+		grantScopes = true
+		authorize := func(scope string) alice.Constructor {
+			return func(h http.Handler) http.Handler {
+				return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					if !grantScopes {
+						w.Header().Set("X-Required-Scope", scope)
+						w.WriteHeader(http.StatusForbidden)
+						return
+					}
+					h.ServeHTTP(w, r)
+				})
+			}
+		}
+
 		router = mux.NewRouter()
-		garbanzo.MapCollectionRoutes("http://here/", router, alice.Chain{}, mockService)
+		garbanzo.MapCollectionRoutes("http://here/", router, alice.Chain{}, authorize, mockService)
 	})
 
 	Describe("GET", func() {
@@ -48,6 +66,7 @@ var _ = Describe("GarbanzoCollection", func() {
 				Expect(err).NotTo(HaveOccurred())
 
 				mockService.FetchByOctoNameOutput.Garbanzos <- []data.Garbanzo{}
+				mockService.FetchByOctoNameOutput.More <- false
 				mockService.FetchByOctoNameOutput.Err <- nil
 
 				router.ServeHTTP(recorder, request)
@@ -58,7 +77,10 @@ var _ = Describe("GarbanzoCollection", func() {
 			})
 
 			It("returns an empty list in the body", func() {
-				Expect(recorder.Body).To(MatchJSON(`[]`))
+				Expect(recorder.Body).To(MatchJSON(`{
+					"link":      "http://here/octos/kraken/garbanzos",
+					"garbanzos": []
+				}`))
 			})
 		})
 
@@ -79,15 +101,16 @@ var _ = Describe("GarbanzoCollection", func() {
 				mockService.FetchByOctoNameOutput.Garbanzos <- []data.Garbanzo{
 					{
 						APIUUID:      apiUUID1,
-						GarbanzoType: data.DESI,
+						GarbanzoType: desi,
 						DiameterMM:   4.2,
 					},
 					{
 						APIUUID:      apiUUID2,
-						GarbanzoType: data.KABULI,
+						GarbanzoType: kabuli,
 						DiameterMM:   6.4,
 					},
 				}
+				mockService.FetchByOctoNameOutput.More <- false
 				mockService.FetchByOctoNameOutput.Err <- nil
 
 				router.ServeHTTP(recorder, request)
@@ -103,19 +126,253 @@ var _ = Describe("GarbanzoCollection", func() {
 				Expect(recorder.Code).To(Equal(http.StatusOK))
 			})
 
+			It("returns an ETag of the collection's content", func() {
+				Expect(recorder.Header().Get("ETag")).To(MatchRegexp(`^"[0-9a-f]{32}"$`))
+			})
+
 			It("returns all garbanzos in the body", func() {
-				Expect(recorder.Body).To(MatchJSON(fmt.Sprintf(`[
+				Expect(recorder.Body).To(MatchJSON(fmt.Sprintf(`{
+					"link":      "http://here%s",
+					"garbanzos": [
+						{
+							"link":        "http://here%s/%s",
+							"type":        "DESI",
+							"diameter-mm": 4.2
+						},
+						{
+							"link":        "http://here%s/%s",
+							"type":        "KABULI",
+							"diameter-mm": 6.4
+						}
+					]
+				}`, url, url, apiUUID1, url, apiUUID2)))
+			})
+		})
+
+		Context("happy path - paging", func() {
+			var apiUUID uuid.UUID
+
+			BeforeEach(func() {
+				var err error
+				request, err = http.NewRequest(http.MethodGet, url+"?limit=1", nil)
+				Expect(err).NotTo(HaveOccurred())
+
+				apiUUID = uuid.NewV4()
+
+				mockService.FetchByOctoNameOutput.Garbanzos <- []data.Garbanzo{
 					{
-						"link":        "http://here%s/%s",
-						"type":        "DESI",
-						"diameter-mm": 4.2
+						Id:           8,
+						APIUUID:      apiUUID,
+						GarbanzoType: desi,
+						DiameterMM:   4.2,
 					},
+				}
+				mockService.FetchByOctoNameOutput.More <- true
+				mockService.FetchByOctoNameOutput.Err <- nil
+
+				router.ServeHTTP(recorder, request)
+			})
+
+			It("fetches the first page", func() {
+				Expect(mockService.FetchByOctoNameInput.Page).To(Receive(Equal(persistence.Page{
+					Limit: 1,
+				})))
+			})
+
+			It("returns a next link in the body", func() {
+				Expect(recorder.Body).To(MatchJSON(fmt.Sprintf(`{
+					"link":      "http://here%s",
+					"next":      "http://here%s?cursor=eyJhIjo4fQ&limit=1",
+					"garbanzos": [
+						{
+							"link":        "http://here%s/%s",
+							"type":        "DESI",
+							"diameter-mm": 4.2
+						}
+					]
+				}`, url, url, url, apiUUID)))
+			})
+		})
+
+		Context("happy path - filtering and sorting", func() {
+			var apiUUID uuid.UUID
+
+			BeforeEach(func() {
+				var err error
+				request, err = http.NewRequest(http.MethodGet,
+					url+"?type=KABULI&diameter-mm[gte]=9&diameter-mm[lt]=12&sort=-diameter-mm&limit=1", nil)
+				Expect(err).NotTo(HaveOccurred())
+
+				apiUUID = uuid.NewV4()
+
+				mockService.FetchByOctoNameOutput.Garbanzos <- []data.Garbanzo{
 					{
-						"link":        "http://here%s/%s",
-						"type":        "KABULI",
-						"diameter-mm": 6.4
-					}
-				]`, url, apiUUID1, url, apiUUID2)))
+						Id:           8,
+						APIUUID:      apiUUID,
+						GarbanzoType: kabuli,
+						DiameterMM:   9.5,
+					},
+				}
+				mockService.FetchByOctoNameOutput.More <- true
+				mockService.FetchByOctoNameOutput.Err <- nil
+
+				router.ServeHTTP(recorder, request)
+			})
+
+			It("filters via the service layer", func() {
+				var filter persistence.GarbanzoFilter
+				Expect(mockService.FetchByOctoNameInput.Filter).To(Receive(&filter))
+				Expect(filter.GarbanzoTypes).To(Equal([]data.GarbanzoType{{Name: "KABULI"}}))
+				Expect(filter.DiameterMM).To(ConsistOf(
+					persistence.Comparison{Operator: persistence.GreaterThanOrEqual, Value: 9},
+					persistence.Comparison{Operator: persistence.LessThan, Value: 12},
+				))
+			})
+
+			It("sorts via the service layer", func() {
+				Expect(mockService.FetchByOctoNameInput.Page).To(Receive(Equal(persistence.Page{
+					Limit: 1,
+					Sort: persistence.Sort{
+						Field:      "DiameterMM",
+						Descending: true,
+					},
+				})))
+			})
+
+			It("returns a next link which keeps the filter and sort", func() {
+				Expect(recorder.Body).To(MatchJSON(fmt.Sprintf(`{
+					"link":      "http://here%s",
+					"next":      "http://here%s?cursor=eyJhIjo4LCJ2Ijo5LjV9&diameter-mm%%5Bgte%%5D=9&diameter-mm%%5Blt%%5D=12&limit=1&sort=-diameter-mm&type=KABULI",
+					"garbanzos": [
+						{
+							"link":        "http://here%s/%s",
+							"type":        "KABULI",
+							"diameter-mm": 9.5
+						}
+					]
+				}`, url, url, url, apiUUID)))
+			})
+		})
+
+		Context("invalid filter", func() {
+			BeforeEach(func() {
+				var err error
+				request, err = http.NewRequest(http.MethodGet, url+"?diameter-mm[gte]=big", nil)
+				Expect(err).NotTo(HaveOccurred())
+
+				router.ServeHTTP(recorder, request)
+			})
+
+			It("does not call the service", func() {
+				Expect(mockService.FetchByOctoNameCalled).NotTo(Receive())
+			})
+
+			It("returns a JSON error", func() {
+				Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+				Expect(recorder.Body).To(MatchJSON(`{
+					"code": 400,
+					"error": "Invalid query parameters",
+					"errors": ["diameter-mm filter must be a number"],
+					"status": "Bad Request"
+				}`))
+			})
+		})
+
+		Context("unsupported filter", func() {
+			BeforeEach(func() {
+				var err error
+				request, err = http.NewRequest(http.MethodGet, url+"?type[gt]=DESI", nil)
+				Expect(err).NotTo(HaveOccurred())
+
+				router.ServeHTTP(recorder, request)
+			})
+
+			It("returns a JSON error", func() {
+				Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+				Expect(recorder.Body).To(MatchJSON(`{
+					"code": 400,
+					"error": "Invalid query parameters",
+					"errors": ["type[gt] is not a supported filter"],
+					"status": "Bad Request"
+				}`))
+			})
+		})
+
+		Context("invalid type filter", func() {
+			BeforeEach(func() {
+				var err error
+				request, err = http.NewRequest(http.MethodGet, url+"?type=PINTO", nil)
+				Expect(err).NotTo(HaveOccurred())
+
+				mockService.FetchByOctoNameOutput.Garbanzos <- nil
+				mockService.FetchByOctoNameOutput.More <- false
+				mockService.FetchByOctoNameOutput.Err <- services.NewValidationError(map[string][]string{
+					"GarbanzoType": {"filter must be one of 'DESI', 'KABULI'"},
+				})
+
+				router.ServeHTTP(recorder, request)
+			})
+
+			It("passes the type name to the service for validation", func() {
+				var filter persistence.GarbanzoFilter
+				Expect(mockService.FetchByOctoNameInput.Filter).To(Receive(&filter))
+				Expect(filter.GarbanzoTypes).To(Equal([]data.GarbanzoType{{Name: "PINTO"}}))
+			})
+
+			It("returns a JSON error", func() {
+				Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+				Expect(recorder.Body).To(MatchJSON(`{
+					"code": 400,
+					"error": "Error fetching garbanzos",
+					"errors": ["type filter must be one of 'DESI', 'KABULI'"],
+					"status": "Bad Request"
+				}`))
+			})
+		})
+
+		Context("invalid sort", func() {
+			BeforeEach(func() {
+				var err error
+				request, err = http.NewRequest(http.MethodGet, url+"?type=PINTO&sort=link", nil)
+				Expect(err).NotTo(HaveOccurred())
+
+				router.ServeHTTP(recorder, request)
+			})
+
+			It("returns a JSON error", func() {
+				Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+				var body struct {
+					Errors []string `json:"errors"`
+				}
+				Expect(json.Unmarshal(recorder.Body.Bytes(), &body)).To(Succeed())
+				Expect(body.Errors).To(ConsistOf(
+					"sort must be type or diameter-mm, optionally prefixed with -",
+				))
+				Expect(mockService.FetchByOctoNameCalled).To(BeEmpty())
+			})
+		})
+
+		Context("invalid page", func() {
+			BeforeEach(func() {
+				var err error
+				request, err = http.NewRequest(http.MethodGet, url+"?cursor=bogus", nil)
+				Expect(err).NotTo(HaveOccurred())
+
+				router.ServeHTTP(recorder, request)
+			})
+
+			It("does not call the service", func() {
+				Expect(mockService.FetchByOctoNameCalled).NotTo(Receive())
+			})
+
+			It("returns a JSON error", func() {
+				Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+				Expect(recorder.Body).To(MatchJSON(`{
+					"code": 400,
+					"error": "Invalid page",
+					"errors": ["cursor is invalid"],
+					"status": "Bad Request"
+				}`))
 			})
 		})
 
@@ -126,6 +383,7 @@ var _ = Describe("GarbanzoCollection", func() {
 				Expect(err).NotTo(HaveOccurred())
 
 				mockService.FetchByOctoNameOutput.Garbanzos <- nil
+				mockService.FetchByOctoNameOutput.More <- false
 				mockService.FetchByOctoNameOutput.Err <- errors.New("bad stuff")
 
 				router.ServeHTTP(recorder, request)
@@ -166,8 +424,9 @@ var _ = Describe("GarbanzoCollection", func() {
 				mockService.CreateOutput.GarbanzoOut <- data.Garbanzo{
 					Id:           234,
 					APIUUID:      apiUUID,
-					GarbanzoType: data.DESI,
+					GarbanzoType: desi,
 					DiameterMM:   4.2,
+					Version:      1,
 				}
 				mockService.CreateOutput.Err <- nil
 
@@ -182,7 +441,7 @@ var _ = Describe("GarbanzoCollection", func() {
 				var actualGarbanzo data.Garbanzo
 				Expect(mockService.CreateInput.GarbanzoIn).To(Receive(&actualGarbanzo))
 				Expect(actualGarbanzo).To(Equal(data.Garbanzo{
-					GarbanzoType: data.DESI,
+					GarbanzoType: data.GarbanzoType{Name: "DESI"},
 					DiameterMM:   4.2,
 				}))
 			})
@@ -198,6 +457,83 @@ var _ = Describe("GarbanzoCollection", func() {
 					"diameter-mm": 4.2
 				}`, url, apiUUID)))
 			})
+
+			It("returns the garbanzo's id and version as its ETag", func() {
+				Expect(recorder.Header().Get("ETag")).To(Equal(`"234-1"`))
+			})
+		})
+
+		Context("idempotently", func() {
+			var (
+				apiUUID uuid.UUID
+				stored  []byte
+			)
+
+			BeforeEach(func() {
+				apiUUID = uuid.NewV4()
+				stored = handlers.StoredResponse(http.StatusCreated, garbanzo.Garbanzo{
+					Link:         fmt.Sprintf("http://here%s/%s", url, apiUUID),
+					GarbanzoType: "DESI",
+					DiameterMM:   4.2,
+				}, 234, 1)
+			})
+
+			post := func(body string) {
+				var err error
+				request, err = http.NewRequest(http.MethodPost, url, strings.NewReader(body))
+				Expect(err).NotTo(HaveOccurred())
+				request.Header.Set("Idempotency-Key", "retry-me")
+
+				mockService.CreateIdempotentlyOutput.Response <- stored
+				mockService.CreateIdempotentlyOutput.Replayed <- false
+				mockService.CreateIdempotentlyOutput.Err <- nil
+
+				recorder = httptest.NewRecorder()
+				router.ServeHTTP(recorder, request)
+			}
+
+			It("creates the garbanzo via the service and responds with the recorded response", func() {
+				post(`{"type": "DESI", "diameter-mm": 4.2}`)
+
+				Expect(mockService.CreateIdempotentlyInput.OctoName).To(Receive(Equal(octoName)))
+				Expect(mockService.CreateIdempotentlyInput.GarbanzoIn).To(Receive(Equal(data.Garbanzo{
+					GarbanzoType: data.GarbanzoType{Name: "DESI"},
+					DiameterMM:   4.2,
+				})))
+				var respond func(garbanzo data.Garbanzo) []byte
+				Expect(mockService.CreateIdempotentlyInput.Respond).To(Receive(&respond))
+				Expect(respond(data.Garbanzo{
+					Id:           234,
+					APIUUID:      apiUUID,
+					GarbanzoType: desi,
+					DiameterMM:   4.2,
+					Version:      1,
+				})).To(Equal(stored))
+				Expect(mockService.CreateCalled).To(BeEmpty())
+
+				Expect(recorder.Code).To(Equal(http.StatusCreated))
+				Expect(recorder.Header().Get("ETag")).To(Equal(`"234-1"`))
+				Expect(recorder.Body).To(MatchJSON(fmt.Sprintf(`{
+					"link":        "http://here%s/%s",
+					"type":        "DESI",
+					"diameter-mm": 4.2
+				}`, url, apiUUID)))
+			})
+
+			It("hashes the body of the request", func() {
+				post(`{"type": "DESI", "diameter-mm": 4.2}`)
+				post(`{"type": "DESI", "diameter-mm": 4.2}`)
+				post(`{"type": "DESI", "diameter-mm": 5.3}`)
+
+				var hashes []string
+				for n := 0; n < 3; n++ {
+					var idempotentRequest data.IdempotentRequest
+					Expect(mockService.CreateIdempotentlyInput.Request).To(Receive(&idempotentRequest))
+					hashes = append(hashes, idempotentRequest.RequestHash)
+				}
+				Expect(hashes[1]).To(Equal(hashes[0]))
+				Expect(hashes[2]).NotTo(Equal(hashes[0]))
+			})
 		})
 
 		Context("unhappy path", func() {
@@ -234,17 +570,23 @@ var _ = Describe("GarbanzoCollection", func() {
 					request, err = http.NewRequest(http.MethodPost, url, body)
 					Expect(err).NotTo(HaveOccurred())
 
+					mockService.CreateOutput.GarbanzoOut <- data.Garbanzo{}
+					mockService.CreateOutput.Err <- services.NewValidationError(map[string][]string{
+						"GarbanzoType": {"must be one of 'DESI', 'KABULI'"},
+					})
+
 					router.ServeHTTP(recorder, request)
 				})
 
-				It("returns an internal server error status code", func() {
+				It("returns a bad request status code", func() {
 					Expect(recorder.Code).To(Equal(http.StatusBadRequest))
 				})
 
 				It("returns a JSON error", func() {
 					Expect(recorder.Body).To(MatchJSON(`{
 						"code": 400,
-						"error": "invalid garbanzo type: RED",
+						"error": "Error creating new garbanzo",
+						"errors": ["type must be one of 'DESI', 'KABULI'"],
 						"status": "Bad Request"
 					}`))
 				})
@@ -309,4 +651,153 @@ var _ = Describe("GarbanzoCollection", func() {
 			})
 		})
 	})
+
+	Describe("POST batch", func() {
+		post := func(query, body string) {
+			var err error
+			request, err = http.NewRequest(http.MethodPost, url+":batch"+query, strings.NewReader(body))
+			Expect(err).NotTo(HaveOccurred())
+
+			router.ServeHTTP(recorder, request)
+		}
+
+		It("creates the garbanzos via the service and responds with a result for each", func() {
+			apiUUID := uuid.NewV4()
+			mockService.CreateBatchOutput.Results <- []services.BatchResult{
+				{Garbanzo: data.Garbanzo{APIUUID: apiUUID, GarbanzoType: desi, DiameterMM: 4.2, Version: 1}},
+				{Garbanzo: data.Garbanzo{DiameterMM: -1.2}, Err: services.NewValidationError(map[string][]string{
+					"DiameterMM": {"must be a positive decimal value"},
+				})},
+				{Garbanzo: data.Garbanzo{GarbanzoType: kabuli, DiameterMM: 6.4}, Err: services.ErrBatchInvalid},
+			}
+			mockService.CreateBatchOutput.Err <- nil
+
+			post("", `[
+				{"type": "DESI", "diameter-mm": 4.2},
+				{"type": "DESI", "diameter-mm": -1.2},
+				{"type": "KABULI", "diameter-mm": 6.4}
+			]`)
+
+			Expect(mockService.CreateBatchInput.OctoName).To(Receive(Equal(octoName)))
+			Expect(mockService.CreateBatchInput.GarbanzosIn).To(Receive(Equal([]data.Garbanzo{
+				{GarbanzoType: data.GarbanzoType{Name: "DESI"}, DiameterMM: 4.2},
+				{GarbanzoType: data.GarbanzoType{Name: "DESI"}, DiameterMM: -1.2},
+				{GarbanzoType: data.GarbanzoType{Name: "KABULI"}, DiameterMM: 6.4},
+			})))
+			Expect(mockService.CreateBatchInput.BestEffort).To(Receive(BeFalse()))
+
+			Expect(recorder.Code).To(Equal(http.StatusMultiStatus))
+			Expect(recorder.Body).To(MatchJSON(fmt.Sprintf(`{
+				"link": "http://here%s",
+				"results": [
+					{
+						"code": 201,
+						"garbanzo": {
+							"link": "http://here%s/%s",
+							"type": "DESI",
+							"diameter-mm": 4.2
+						}
+					},
+					{
+						"code": 400,
+						"error": {
+							"code": 400,
+							"error": "Error creating new garbanzo",
+							"errors": ["diameter-mm must be a positive decimal value"],
+							"status": "Bad Request"
+						}
+					},
+					{
+						"code": 424,
+						"error": {
+							"code": 424,
+							"error": "Not created because another garbanzo is invalid",
+							"status": "Failed Dependency"
+						}
+					}
+				]
+			}`, url, url, apiUUID)))
+		})
+
+		It("creates the valid garbanzos in best effort mode", func() {
+			mockService.CreateBatchOutput.Results <- []services.BatchResult{}
+			mockService.CreateBatchOutput.Err <- nil
+
+			post("?mode=best-effort", `[{"type": "DESI", "diameter-mm": 4.2}]`)
+
+			Expect(mockService.CreateBatchInput.BestEffort).To(Receive(BeTrue()))
+			Expect(recorder.Code).To(Equal(http.StatusMultiStatus))
+		})
+
+		It("returns a bad request for an unknown mode", func() {
+			post("?mode=most", `[{"type": "DESI", "diameter-mm": 4.2}]`)
+
+			Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+			Expect(recorder.Body).To(MatchJSON(`{
+				"code": 400,
+				"error": "Invalid mode, must be 'all-or-nothing' or 'best-effort'",
+				"status": "Bad Request"
+			}`))
+			Expect(mockService.CreateBatchCalled).To(BeEmpty())
+		})
+
+		It("returns a bad request for a body that isn't an array", func() {
+			post("", `{"type": "DESI", "diameter-mm": 4.2}`)
+
+			Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+			Expect(mockService.CreateBatchCalled).To(BeEmpty())
+		})
+
+		It("returns a bad request when the batch is rejected", func() {
+			mockService.CreateBatchOutput.Results <- nil
+			mockService.CreateBatchOutput.Err <- services.NewValidationError(map[string][]string{
+				"Batch": {"must have between 1 and 1000 garbanzos"},
+			})
+
+			post("", `[]`)
+
+			Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+			Expect(recorder.Body).To(MatchJSON(`{
+				"code": 400,
+				"error": "Error creating new garbanzos",
+				"errors": ["Batch must have between 1 and 1000 garbanzos"],
+				"status": "Bad Request"
+			}`))
+		})
+
+		It("returns a conflict when the parent octo doesn't exist", func() {
+			mockService.CreateBatchOutput.Results <- nil
+			mockService.CreateBatchOutput.Err <- persistence.ErrNotFound
+
+			post("", `[{"type": "DESI", "diameter-mm": 4.2}]`)
+
+			Expect(recorder.Code).To(Equal(http.StatusConflict))
+			Expect(recorder.Body).To(MatchJSON(`{
+				"code": 409,
+				"error": "Parent octo 'kraken' not found",
+				"status": "Conflict"
+			}`))
+		})
+	})
+
+	Describe("scopes", func() {
+		It("requires a scope for each method", func() {
+			grantScopes = false
+			for _, route := range []struct{ method, path, scope string }{
+				{http.MethodGet, url, "garbanzos:read"},
+				{http.MethodPost, url, "garbanzos:write"},
+				{http.MethodPost, url + ":batch", "garbanzos:write"},
+			} {
+				recorder = httptest.NewRecorder()
+				var err error
+				request, err = http.NewRequest(route.method, route.path, nil)
+				Expect(err).NotTo(HaveOccurred())
+
+				router.ServeHTTP(recorder, request)
+
+				Expect(recorder.Code).To(Equal(http.StatusForbidden), route.method)
+				Expect(recorder.Header().Get("X-Required-Scope")).To(Equal(route.scope), route.method)
+			}
+		})
+	})
 })
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 
 	"github.com/gorilla/mux"
 	"github.com/justinas/alice"
@@ -17,6 +18,12 @@ import (
 	"github.com/myshkin5/effective-octo-garbanzo/api/handlers/garbanzo"
 	"github.com/myshkin5/effective-octo-garbanzo/persistence"
 	"github.com/myshkin5/effective-octo-garbanzo/persistence/data"
+	"github.com/myshkin5/effective-octo-garbanzo/services"
+)
+
+var (
+	desi   = data.GarbanzoType{Id: 1001, Name: "DESI"}
+	kabuli = data.GarbanzoType{Id: 1002, Name: "KABULI"}
 )
 
 var _ = Describe("Garbanzo", func() {
@@ -25,11 +32,13 @@ var _ = Describe("Garbanzo", func() {
 		url      = "/octos/" + octoName + "/garbanzos/"
 	)
 	var (
-		recorder    *httptest.ResponseRecorder
-		request     *http.Request
-		mockService *mockGarbanzoService
-		router      *mux.Router
-		apiUUID     uuid.UUID
+		recorder       *httptest.ResponseRecorder
+		request        *http.Request
+		mockService    *mockGarbanzoService
+		router         *mux.Router
+		apiUUID        uuid.UUID
+		grantScopes    bool
+		requireIfMatch bool
 	)
 
 	BeforeEach(func() {
@@ -38,8 +47,33 @@ var _ = Describe("Garbanzo", func() {
 
 		mockService = newMockGarbanzoService()
 
+		grantScopes = true
+		authorize := func(scope string) alice.Constructor {
+			return func(h http.Handler) http.Handler {
+				return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					if !grantScopes {
+						w.Header().Set("X-Required-Scope", scope)
+						w.WriteHeader(http.StatusForbidden)
+						return
+					}
+					h.ServeHTTP(w, r)
+				})
+			}
+		}
+
+		requireIfMatch = false
+		ifMatchRequired := func(h http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if requireIfMatch {
+					w.WriteHeader(http.StatusPreconditionRequired)
+					return
+				}
+				h.ServeHTTP(w, r)
+			})
+		}
+
 		router = mux.NewRouter()
-		garbanzo.MapRoutes("http://here/", router, alice.Chain{}, mockService)
+		garbanzo.MapRoutes("http://here/", router, alice.Chain{}, authorize, ifMatchRequired, mockService)
 		apiUUID = uuid.NewV4()
 	})
 
@@ -51,9 +85,11 @@ var _ = Describe("Garbanzo", func() {
 				Expect(err).NotTo(HaveOccurred())
 
 				mockService.FetchByAPIUUIDAndOctoNameOutput.Garbanzo <- data.Garbanzo{
+					Id:           42,
 					APIUUID:      apiUUID,
-					GarbanzoType: data.DESI,
+					GarbanzoType: desi,
 					DiameterMM:   4.2,
+					Version:      7,
 				}
 				mockService.FetchByAPIUUIDAndOctoNameOutput.Err <- nil
 
@@ -80,6 +116,33 @@ var _ = Describe("Garbanzo", func() {
 					"diameter-mm": 4.2
 				}`, url, apiUUID)))
 			})
+
+			It("returns the garbanzo's id and version as its ETag", func() {
+				Expect(recorder.Header().Get("ETag")).To(Equal(`"42-7"`))
+			})
+		})
+
+		Context("not modified", func() {
+			BeforeEach(func() {
+				var err error
+				request, err = http.NewRequest(http.MethodGet, url+apiUUID.String(), nil)
+				Expect(err).NotTo(HaveOccurred())
+				request.Header.Set("If-None-Match", `W/"42-7"`)
+
+				mockService.FetchByAPIUUIDAndOctoNameOutput.Garbanzo <- data.Garbanzo{
+					Id:      42,
+					APIUUID: apiUUID,
+					Version: 7,
+				}
+				mockService.FetchByAPIUUIDAndOctoNameOutput.Err <- nil
+
+				router.ServeHTTP(recorder, request)
+			})
+
+			It("returns a not modified status code and no content", func() {
+				Expect(recorder.Code).To(Equal(http.StatusNotModified))
+				Expect(recorder.Body.Len()).To(BeZero())
+			})
 		})
 
 		Context("unhappy path", func() {
@@ -157,18 +220,530 @@ var _ = Describe("Garbanzo", func() {
 		})
 	})
 
+	Describe("PUT", func() {
+		Context("happy path", func() {
+			BeforeEach(func() {
+				var err error
+				body := strings.NewReader(`{
+					"type":        "KABULI",
+					"diameter-mm": 5.3
+				}`)
+				request, err = http.NewRequest(http.MethodPut, url+apiUUID.String(), body)
+				Expect(err).NotTo(HaveOccurred())
+				request.Header.Set("If-Match", `"42-7"`)
+
+				mockService.UpdateByAPIUUIDAndOctoNameOutput.GarbanzoOut <- data.Garbanzo{
+					Id:           42,
+					APIUUID:      apiUUID,
+					GarbanzoType: kabuli,
+					DiameterMM:   5.3,
+					Version:      8,
+				}
+				mockService.UpdateByAPIUUIDAndOctoNameOutput.Err <- nil
+
+				router.ServeHTTP(recorder, request)
+			})
+
+			It("updates the garbanzo at the id and version of the If-Match header via the service", func() {
+				Expect(mockService.UpdateByAPIUUIDAndOctoNameInput.ApiUUID).To(Receive(Equal(apiUUID)))
+				Expect(mockService.UpdateByAPIUUIDAndOctoNameInput.OctoName).To(Receive(Equal(octoName)))
+				Expect(mockService.UpdateByAPIUUIDAndOctoNameInput.GarbanzoIn).To(Receive(Equal(data.Garbanzo{
+					Id:           42,
+					GarbanzoType: data.GarbanzoType{Name: "KABULI"},
+					DiameterMM:   5.3,
+					Version:      7,
+				})))
+			})
+
+			It("returns an ok status code", func() {
+				Expect(recorder.Code).To(Equal(http.StatusOK))
+			})
+
+			It("returns the garbanzo's id and new version as its ETag", func() {
+				Expect(recorder.Header().Get("ETag")).To(Equal(`"42-8"`))
+			})
+
+			It("returns the updated garbanzo in the body", func() {
+				Expect(recorder.Body).To(MatchJSON(fmt.Sprintf(`{
+					"link":        "http://here%s%s",
+					"type":        "KABULI",
+					"diameter-mm": 5.3
+				}`, url, apiUUID.String())))
+			})
+		})
+
+		Context("unhappy path", func() {
+			Context("invalid UUID", func() {
+				BeforeEach(func() {
+					var err error
+					request, err = http.NewRequest(http.MethodPut, url+"not-a-uuid", strings.NewReader("{}"))
+					Expect(err).NotTo(HaveOccurred())
+
+					router.ServeHTTP(recorder, request)
+				})
+
+				It("returns a bad request status code", func() {
+					Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+				})
+
+				It("returns a JSON error", func() {
+					Expect(recorder.Body).To(MatchJSON(`{
+						"code": 400,
+						"error": "Invalid UUID",
+						"status": "Bad Request"
+					}`))
+				})
+			})
+
+			Context("invalid json", func() {
+				BeforeEach(func() {
+					var err error
+					request, err = http.NewRequest(http.MethodPut, url+apiUUID.String(), strings.NewReader("not json"))
+					Expect(err).NotTo(HaveOccurred())
+
+					router.ServeHTTP(recorder, request)
+				})
+
+				It("returns a JSON error", func() {
+					Expect(recorder.Body).To(MatchJSON(`{
+						"code": 400,
+						"error": "Body of request was not valid JSON",
+						"status": "Bad Request"
+					}`))
+				})
+			})
+
+			Context("invalid type", func() {
+				BeforeEach(func() {
+					var err error
+					body := strings.NewReader(`{
+						"type":        "BOGUS",
+						"diameter-mm": 5.3
+					}`)
+					request, err = http.NewRequest(http.MethodPut, url+apiUUID.String(), body)
+					Expect(err).NotTo(HaveOccurred())
+
+					mockService.UpdateByAPIUUIDAndOctoNameOutput.GarbanzoOut <- data.Garbanzo{}
+					mockService.UpdateByAPIUUIDAndOctoNameOutput.Err <- services.NewValidationError(map[string][]string{
+						"GarbanzoType": {"must be one of 'DESI', 'KABULI'"},
+					})
+
+					router.ServeHTTP(recorder, request)
+				})
+
+				It("passes the type name to the service for validation", func() {
+					Expect(mockService.UpdateByAPIUUIDAndOctoNameInput.GarbanzoIn).To(Receive(Equal(data.Garbanzo{
+						GarbanzoType: data.GarbanzoType{Name: "BOGUS"},
+						DiameterMM:   5.3,
+					})))
+				})
+
+				It("returns a JSON error", func() {
+					Expect(recorder.Body).To(MatchJSON(`{
+						"code": 400,
+						"error": "Error updating garbanzo",
+						"errors": ["type must be one of 'DESI', 'KABULI'"],
+						"status": "Bad Request"
+					}`))
+				})
+			})
+
+			Context("version mismatch", func() {
+				BeforeEach(func() {
+					var err error
+					body := strings.NewReader(`{
+						"type":        "KABULI",
+						"diameter-mm": 5.3
+					}`)
+					request, err = http.NewRequest(http.MethodPut, url+apiUUID.String(), body)
+					Expect(err).NotTo(HaveOccurred())
+					request.Header.Set("If-Match", `"42-7"`)
+
+					mockService.UpdateByAPIUUIDAndOctoNameOutput.GarbanzoOut <- data.Garbanzo{}
+					mockService.UpdateByAPIUUIDAndOctoNameOutput.Err <- persistence.ErrVersionMismatch
+
+					router.ServeHTTP(recorder, request)
+				})
+
+				It("returns a precondition failed status code", func() {
+					Expect(recorder.Code).To(Equal(http.StatusPreconditionFailed))
+				})
+
+				It("returns a JSON error", func() {
+					Expect(recorder.Body).To(MatchJSON(fmt.Sprintf(`{
+						"code": 412,
+						"error": "Garbanzo %s has been modified",
+						"status": "Precondition Failed"
+					}`, apiUUID)))
+				})
+			})
+
+			Context("If-Match header naming no version", func() {
+				BeforeEach(func() {
+					var err error
+					body := strings.NewReader(`{
+						"type":        "KABULI",
+						"diameter-mm": 5.3
+					}`)
+					request, err = http.NewRequest(http.MethodPut, url+apiUUID.String(), body)
+					Expect(err).NotTo(HaveOccurred())
+					request.Header.Set("If-Match", `"not-a-version"`)
+
+					router.ServeHTTP(recorder, request)
+				})
+
+				It("returns a precondition failed status code without calling the service", func() {
+					Expect(recorder.Code).To(Equal(http.StatusPreconditionFailed))
+					Expect(mockService.UpdateByAPIUUIDAndOctoNameCalled).To(BeEmpty())
+				})
+			})
+
+			Context("not found error", func() {
+				BeforeEach(func() {
+					var err error
+					body := strings.NewReader(`{
+						"type":        "KABULI",
+						"diameter-mm": 5.3
+					}`)
+					request, err = http.NewRequest(http.MethodPut, url+apiUUID.String(), body)
+					Expect(err).NotTo(HaveOccurred())
+
+					mockService.UpdateByAPIUUIDAndOctoNameOutput.GarbanzoOut <- data.Garbanzo{}
+					mockService.UpdateByAPIUUIDAndOctoNameOutput.Err <- persistence.ErrNotFound
+
+					router.ServeHTTP(recorder, request)
+				})
+
+				It("returns a not found status code", func() {
+					Expect(recorder.Code).To(Equal(http.StatusNotFound))
+				})
+
+				It("returns a JSON error", func() {
+					Expect(recorder.Body).To(MatchJSON(fmt.Sprintf(`{
+						"code": 404,
+						"error": "Garbanzo %s not found",
+						"status": "Not Found"
+					}`, apiUUID)))
+				})
+			})
+
+			Context("persistence error", func() {
+				BeforeEach(func() {
+					var err error
+					body := strings.NewReader(`{
+						"type":        "KABULI",
+						"diameter-mm": 5.3
+					}`)
+					request, err = http.NewRequest(http.MethodPut, url+apiUUID.String(), body)
+					Expect(err).NotTo(HaveOccurred())
+
+					mockService.UpdateByAPIUUIDAndOctoNameOutput.GarbanzoOut <- data.Garbanzo{}
+					mockService.UpdateByAPIUUIDAndOctoNameOutput.Err <- errors.New("bad stuff")
+
+					router.ServeHTTP(recorder, request)
+				})
+
+				It("returns a JSON error", func() {
+					Expect(recorder.Body).To(MatchJSON(`{
+						"code": 500,
+						"error": "Error updating garbanzo",
+						"status": "Internal Server Error"
+					}`))
+				})
+			})
+		})
+	})
+
+	Describe("PATCH", func() {
+		BeforeEach(func() {
+			mockService.FetchByAPIUUIDAndOctoNameOutput.Garbanzo <- data.Garbanzo{
+				Id:           42,
+				APIUUID:      apiUUID,
+				GarbanzoType: desi,
+				DiameterMM:   4.2,
+				Version:      6,
+			}
+			mockService.FetchByAPIUUIDAndOctoNameOutput.Err <- nil
+		})
+
+		Context("happy path", func() {
+			BeforeEach(func() {
+				var err error
+				body := strings.NewReader(`{
+					"diameter-mm": 5.3
+				}`)
+				request, err = http.NewRequest(http.MethodPatch, url+apiUUID.String(), body)
+				Expect(err).NotTo(HaveOccurred())
+
+				mockService.UpdateByAPIUUIDAndOctoNameOutput.GarbanzoOut <- data.Garbanzo{
+					APIUUID:      apiUUID,
+					GarbanzoType: desi,
+					DiameterMM:   5.3,
+				}
+				mockService.UpdateByAPIUUIDAndOctoNameOutput.Err <- nil
+
+				router.ServeHTTP(recorder, request)
+			})
+
+			It("merges the patch into the current garbanzo and only updates its id and version", func() {
+				Expect(mockService.FetchByAPIUUIDAndOctoNameInput.ApiUUID).To(Receive(Equal(apiUUID)))
+				Expect(mockService.FetchByAPIUUIDAndOctoNameInput.OctoName).To(Receive(Equal(octoName)))
+
+				Expect(mockService.UpdateByAPIUUIDAndOctoNameInput.ApiUUID).To(Receive(Equal(apiUUID)))
+				Expect(mockService.UpdateByAPIUUIDAndOctoNameInput.GarbanzoIn).To(Receive(Equal(data.Garbanzo{
+					Id:           42,
+					GarbanzoType: data.GarbanzoType{Name: "DESI"},
+					DiameterMM:   5.3,
+					Version:      6,
+				})))
+			})
+
+			It("returns an ok status code", func() {
+				Expect(recorder.Code).To(Equal(http.StatusOK))
+			})
+
+			It("returns the updated garbanzo in the body", func() {
+				Expect(recorder.Body).To(MatchJSON(fmt.Sprintf(`{
+					"link":        "http://here%s%s",
+					"type":        "DESI",
+					"diameter-mm": 5.3
+				}`, url, apiUUID.String())))
+			})
+		})
+
+		Context("unhappy path", func() {
+			It("returns a bad request for an invalid merge patch", func() {
+				var err error
+				request, err = http.NewRequest(http.MethodPatch, url+apiUUID.String(), strings.NewReader("not json"))
+				Expect(err).NotTo(HaveOccurred())
+
+				router.ServeHTTP(recorder, request)
+
+				Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+				Expect(mockService.UpdateByAPIUUIDAndOctoNameCalled).To(BeEmpty())
+			})
+
+			It("passes removed members on as empty values", func() {
+				var err error
+				request, err = http.NewRequest(http.MethodPatch, url+apiUUID.String(), strings.NewReader(`{"diameter-mm": null}`))
+				Expect(err).NotTo(HaveOccurred())
+
+				mockService.UpdateByAPIUUIDAndOctoNameOutput.GarbanzoOut <- data.Garbanzo{}
+				mockService.UpdateByAPIUUIDAndOctoNameOutput.Err <- nil
+
+				router.ServeHTTP(recorder, request)
+
+				Expect(mockService.UpdateByAPIUUIDAndOctoNameInput.GarbanzoIn).To(Receive(Equal(data.Garbanzo{
+					Id:           42,
+					GarbanzoType: data.GarbanzoType{Name: "DESI"},
+					Version:      6,
+				})))
+			})
+
+			It("returns a precondition failed status code when the garbanzo changes concurrently", func() {
+				var err error
+				request, err = http.NewRequest(http.MethodPatch, url+apiUUID.String(), strings.NewReader(`{"diameter-mm": 5.3}`))
+				Expect(err).NotTo(HaveOccurred())
+
+				mockService.UpdateByAPIUUIDAndOctoNameOutput.GarbanzoOut <- data.Garbanzo{}
+				mockService.UpdateByAPIUUIDAndOctoNameOutput.Err <- persistence.ErrVersionMismatch
+
+				router.ServeHTTP(recorder, request)
+
+				Expect(recorder.Code).To(Equal(http.StatusPreconditionFailed))
+			})
+
+			It("updates the id and version of the If-Match header rather than those fetched", func() {
+				var err error
+				request, err = http.NewRequest(http.MethodPatch, url+apiUUID.String(), strings.NewReader(`{}`))
+				Expect(err).NotTo(HaveOccurred())
+				request.Header.Set("If-Match", `"41-5"`)
+
+				mockService.UpdateByAPIUUIDAndOctoNameOutput.GarbanzoOut <- data.Garbanzo{}
+				mockService.UpdateByAPIUUIDAndOctoNameOutput.Err <- persistence.ErrVersionMismatch
+
+				router.ServeHTTP(recorder, request)
+
+				Expect(recorder.Code).To(Equal(http.StatusPreconditionFailed))
+				var garbanzo data.Garbanzo
+				Expect(mockService.UpdateByAPIUUIDAndOctoNameInput.GarbanzoIn).To(Receive(&garbanzo))
+				Expect(garbanzo.Id).To(Equal(41))
+				Expect(garbanzo.Version).To(Equal(5))
+			})
+		})
+	})
+
+	Describe("PATCH of an unknown garbanzo", func() {
+		BeforeEach(func() {
+			var err error
+			request, err = http.NewRequest(http.MethodPatch, url+apiUUID.String(), strings.NewReader(`{}`))
+			Expect(err).NotTo(HaveOccurred())
+
+			mockService.FetchByAPIUUIDAndOctoNameOutput.Garbanzo <- data.Garbanzo{}
+			mockService.FetchByAPIUUIDAndOctoNameOutput.Err <- persistence.ErrNotFound
+
+			router.ServeHTTP(recorder, request)
+		})
+
+		It("returns a not found status code", func() {
+			Expect(recorder.Code).To(Equal(http.StatusNotFound))
+			Expect(mockService.UpdateByAPIUUIDAndOctoNameCalled).To(BeEmpty())
+		})
+	})
+
+	Describe("POST move", func() {
+		Context("happy path", func() {
+			BeforeEach(func() {
+				var err error
+				body := strings.NewReader(`{
+					"octo": "cthulhu"
+				}`)
+				request, err = http.NewRequest(http.MethodPost, url+apiUUID.String()+"/move", body)
+				Expect(err).NotTo(HaveOccurred())
+				request.Header.Set("If-Match", `"42-7"`)
+
+				mockService.MoveByAPIUUIDAndOctoNameOutput.GarbanzoOut <- data.Garbanzo{
+					Id:           42,
+					APIUUID:      apiUUID,
+					GarbanzoType: desi,
+					DiameterMM:   4.2,
+					Version:      8,
+				}
+				mockService.MoveByAPIUUIDAndOctoNameOutput.Err <- nil
+
+				router.ServeHTTP(recorder, request)
+			})
+
+			It("moves the garbanzo at the id and version of the If-Match header via the service", func() {
+				Expect(mockService.MoveByAPIUUIDAndOctoNameInput.ApiUUID).To(Receive(Equal(apiUUID)))
+				Expect(mockService.MoveByAPIUUIDAndOctoNameInput.OctoName).To(Receive(Equal(octoName)))
+				Expect(mockService.MoveByAPIUUIDAndOctoNameInput.TargetOctoName).To(Receive(Equal("cthulhu")))
+				Expect(mockService.MoveByAPIUUIDAndOctoNameInput.Id).To(Receive(Equal(42)))
+				Expect(mockService.MoveByAPIUUIDAndOctoNameInput.Version).To(Receive(Equal(7)))
+			})
+
+			It("returns an ok status code", func() {
+				Expect(recorder.Code).To(Equal(http.StatusOK))
+			})
+
+			It("returns the garbanzo's id and new version as its ETag", func() {
+				Expect(recorder.Header().Get("ETag")).To(Equal(`"42-8"`))
+			})
+
+			It("returns the garbanzo with its new link in the body", func() {
+				Expect(recorder.Body).To(MatchJSON(fmt.Sprintf(`{
+					"link":        "http://here/octos/cthulhu/garbanzos/%s",
+					"type":        "DESI",
+					"diameter-mm": 4.2
+				}`, apiUUID.String())))
+			})
+		})
+
+		Context("unhappy path", func() {
+			It("returns a bad request for an invalid UUID", func() {
+				var err error
+				request, err = http.NewRequest(http.MethodPost, url+"not-a-uuid/move", strings.NewReader(`{"octo": "cthulhu"}`))
+				Expect(err).NotTo(HaveOccurred())
+
+				router.ServeHTTP(recorder, request)
+
+				Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+			})
+
+			It("returns a bad request for invalid JSON", func() {
+				var err error
+				request, err = http.NewRequest(http.MethodPost, url+apiUUID.String()+"/move", strings.NewReader("not json"))
+				Expect(err).NotTo(HaveOccurred())
+
+				router.ServeHTTP(recorder, request)
+
+				Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+				Expect(mockService.MoveByAPIUUIDAndOctoNameCalled).To(BeEmpty())
+			})
+
+			It("returns validation errors with the API field names", func() {
+				var err error
+				request, err = http.NewRequest(http.MethodPost, url+apiUUID.String()+"/move", strings.NewReader(`{}`))
+				Expect(err).NotTo(HaveOccurred())
+
+				mockService.MoveByAPIUUIDAndOctoNameOutput.GarbanzoOut <- data.Garbanzo{}
+				mockService.MoveByAPIUUIDAndOctoNameOutput.Err <- services.NewValidationError(map[string][]string{
+					"TargetOctoName": {"must be present"},
+				})
+
+				router.ServeHTTP(recorder, request)
+
+				Expect(recorder.Body).To(MatchJSON(`{
+					"code": 400,
+					"error": "Error moving garbanzo",
+					"errors": ["octo must be present"],
+					"status": "Bad Request"
+				}`))
+			})
+
+			It("returns not found when the garbanzo doesn't exist", func() {
+				var err error
+				request, err = http.NewRequest(http.MethodPost, url+apiUUID.String()+"/move", strings.NewReader(`{"octo": "cthulhu"}`))
+				Expect(err).NotTo(HaveOccurred())
+
+				mockService.MoveByAPIUUIDAndOctoNameOutput.GarbanzoOut <- data.Garbanzo{}
+				mockService.MoveByAPIUUIDAndOctoNameOutput.Err <- persistence.ErrNotFound
+
+				router.ServeHTTP(recorder, request)
+
+				Expect(recorder.Code).To(Equal(http.StatusNotFound))
+			})
+
+			It("returns a conflict when the target octo doesn't exist", func() {
+				var err error
+				request, err = http.NewRequest(http.MethodPost, url+apiUUID.String()+"/move", strings.NewReader(`{"octo": "cthulhu"}`))
+				Expect(err).NotTo(HaveOccurred())
+
+				mockService.MoveByAPIUUIDAndOctoNameOutput.GarbanzoOut <- data.Garbanzo{}
+				mockService.MoveByAPIUUIDAndOctoNameOutput.Err <- services.ErrTargetOctoNotFound
+
+				router.ServeHTTP(recorder, request)
+
+				Expect(recorder.Body).To(MatchJSON(`{
+					"code": 409,
+					"error": "Target octo 'cthulhu' not found",
+					"status": "Conflict"
+				}`))
+			})
+
+			It("returns an internal server error on persistence errors", func() {
+				var err error
+				request, err = http.NewRequest(http.MethodPost, url+apiUUID.String()+"/move", strings.NewReader(`{"octo": "cthulhu"}`))
+				Expect(err).NotTo(HaveOccurred())
+
+				mockService.MoveByAPIUUIDAndOctoNameOutput.GarbanzoOut <- data.Garbanzo{}
+				mockService.MoveByAPIUUIDAndOctoNameOutput.Err <- errors.New("bad stuff")
+
+				router.ServeHTTP(recorder, request)
+
+				Expect(recorder.Code).To(Equal(http.StatusInternalServerError))
+			})
+		})
+	})
+
 	Describe("DELETE", func() {
 		Context("happy path", func() {
 			BeforeEach(func() {
 				var err error
 				request, err = http.NewRequest("DELETE", url+apiUUID.String(), nil)
 				Expect(err).NotTo(HaveOccurred())
+				request.Header.Set("If-Match", `"42-7"`)
 
 				mockService.DeleteByAPIUUIDAndOctoNameOutput.Err <- nil
 
 				router.ServeHTTP(recorder, request)
 			})
 
+			It("deletes at the id and version of the If-Match header", func() {
+				Expect(mockService.DeleteByAPIUUIDAndOctoNameInput.Id).To(Receive(Equal(42)))
+				Expect(mockService.DeleteByAPIUUIDAndOctoNameInput.Version).To(Receive(Equal(7)))
+			})
+
 			It("invokes the service layer", func() {
 				var actualAPIUUID uuid.UUID
 				Expect(mockService.DeleteByAPIUUIDAndOctoNameInput.ApiUUID).To(Receive(&actualAPIUUID))
@@ -257,6 +832,71 @@ var _ = Describe("Garbanzo", func() {
 					}`, apiUUID)))
 				})
 			})
+
+			Context("version mismatch", func() {
+				BeforeEach(func() {
+					var err error
+					request, err = http.NewRequest(http.MethodDelete, url+apiUUID.String(), nil)
+					Expect(err).NotTo(HaveOccurred())
+					request.Header.Set("If-Match", `"42-7"`)
+
+					mockService.DeleteByAPIUUIDAndOctoNameOutput.Err <- persistence.ErrVersionMismatch
+
+					router.ServeHTTP(recorder, request)
+				})
+
+				It("returns a precondition failed status code", func() {
+					Expect(recorder.Code).To(Equal(http.StatusPreconditionFailed))
+				})
+			})
+		})
+	})
+
+	Describe("If-Match", func() {
+		It("is required for each update, move and delete when configured", func() {
+			requireIfMatch = true
+			for _, route := range []struct{ method, path string }{
+				{http.MethodPut, url + apiUUID.String()},
+				{http.MethodPatch, url + apiUUID.String()},
+				{http.MethodDelete, url + apiUUID.String()},
+				{http.MethodPost, url + apiUUID.String() + "/move"},
+			} {
+				recorder = httptest.NewRecorder()
+				var err error
+				request, err = http.NewRequest(route.method, route.path, nil)
+				Expect(err).NotTo(HaveOccurred())
+
+				router.ServeHTTP(recorder, request)
+
+				Expect(recorder.Code).To(Equal(http.StatusPreconditionRequired), route.method+" "+route.path)
+			}
+			Expect(mockService.FetchByAPIUUIDAndOctoNameCalled).To(BeEmpty())
+			Expect(mockService.UpdateByAPIUUIDAndOctoNameCalled).To(BeEmpty())
+			Expect(mockService.MoveByAPIUUIDAndOctoNameCalled).To(BeEmpty())
+			Expect(mockService.DeleteByAPIUUIDAndOctoNameCalled).To(BeEmpty())
+		})
+	})
+
+	Describe("scopes", func() {
+		It("requires a scope for each method", func() {
+			grantScopes = false
+			for _, route := range []struct{ method, path, scope string }{
+				{http.MethodGet, url + apiUUID.String(), "garbanzos:read"},
+				{http.MethodPut, url + apiUUID.String(), "garbanzos:write"},
+				{http.MethodPatch, url + apiUUID.String(), "garbanzos:write"},
+				{http.MethodDelete, url + apiUUID.String(), "garbanzos:delete"},
+				{http.MethodPost, url + apiUUID.String() + "/move", "garbanzos:write"},
+			} {
+				recorder = httptest.NewRecorder()
+				var err error
+				request, err = http.NewRequest(route.method, route.path, nil)
+				Expect(err).NotTo(HaveOccurred())
+
+				router.ServeHTTP(recorder, request)
+
+				Expect(recorder.Code).To(Equal(http.StatusForbidden), route.method)
+				Expect(recorder.Header().Get("X-Required-Scope")).To(Equal(route.scope), route.method)
+			}
 		})
 	})
 })
@@ -9,7 +9,9 @@ import (
 	"context"
 	"time"
 
+	"github.com/myshkin5/effective-octo-garbanzo/persistence"
 	"github.com/myshkin5/effective-octo-garbanzo/persistence/data"
+	"github.com/myshkin5/effective-octo-garbanzo/services"
 	"github.com/satori/go.uuid"
 )
 
@@ -18,9 +20,12 @@ type mockGarbanzoService struct {
 	FetchByOctoNameInput  struct {
 		Ctx      chan context.Context
 		OctoName chan string
+		Filter   chan persistence.GarbanzoFilter
+		Page     chan persistence.Page
 	}
 	FetchByOctoNameOutput struct {
 		Garbanzos chan []data.Garbanzo
+		More      chan bool
 		Err       chan error
 	}
 	FetchByAPIUUIDAndOctoNameCalled chan bool
@@ -43,11 +48,61 @@ type mockGarbanzoService struct {
 		GarbanzoOut chan data.Garbanzo
 		Err         chan error
 	}
+	CreateIdempotentlyCalled chan bool
+	CreateIdempotentlyInput  struct {
+		Ctx        chan context.Context
+		OctoName   chan string
+		GarbanzoIn chan data.Garbanzo
+		Request    chan data.IdempotentRequest
+		Respond    chan func(garbanzo data.Garbanzo) []byte
+	}
+	CreateIdempotentlyOutput struct {
+		Response chan []byte
+		Replayed chan bool
+		Err      chan error
+	}
+	CreateBatchCalled chan bool
+	CreateBatchInput  struct {
+		Ctx         chan context.Context
+		OctoName    chan string
+		GarbanzosIn chan []data.Garbanzo
+		BestEffort  chan bool
+	}
+	CreateBatchOutput struct {
+		Results chan []services.BatchResult
+		Err     chan error
+	}
+	UpdateByAPIUUIDAndOctoNameCalled chan bool
+	UpdateByAPIUUIDAndOctoNameInput  struct {
+		Ctx        chan context.Context
+		ApiUUID    chan uuid.UUID
+		OctoName   chan string
+		GarbanzoIn chan data.Garbanzo
+	}
+	UpdateByAPIUUIDAndOctoNameOutput struct {
+		GarbanzoOut chan data.Garbanzo
+		Err         chan error
+	}
+	MoveByAPIUUIDAndOctoNameCalled chan bool
+	MoveByAPIUUIDAndOctoNameInput  struct {
+		Ctx            chan context.Context
+		ApiUUID        chan uuid.UUID
+		OctoName       chan string
+		TargetOctoName chan string
+		Id             chan int
+		Version        chan int
+	}
+	MoveByAPIUUIDAndOctoNameOutput struct {
+		GarbanzoOut chan data.Garbanzo
+		Err         chan error
+	}
 	DeleteByAPIUUIDAndOctoNameCalled chan bool
 	DeleteByAPIUUIDAndOctoNameInput  struct {
 		Ctx      chan context.Context
 		ApiUUID  chan uuid.UUID
 		OctoName chan string
+		Id       chan int
+		Version  chan int
 	}
 	DeleteByAPIUUIDAndOctoNameOutput struct {
 		Err chan error
@@ -59,7 +114,10 @@ func newMockGarbanzoService() *mockGarbanzoService {
 	m.FetchByOctoNameCalled = make(chan bool, 100)
 	m.FetchByOctoNameInput.Ctx = make(chan context.Context, 100)
 	m.FetchByOctoNameInput.OctoName = make(chan string, 100)
+	m.FetchByOctoNameInput.Filter = make(chan persistence.GarbanzoFilter, 100)
+	m.FetchByOctoNameInput.Page = make(chan persistence.Page, 100)
 	m.FetchByOctoNameOutput.Garbanzos = make(chan []data.Garbanzo, 100)
+	m.FetchByOctoNameOutput.More = make(chan bool, 100)
 	m.FetchByOctoNameOutput.Err = make(chan error, 100)
 	m.FetchByAPIUUIDAndOctoNameCalled = make(chan bool, 100)
 	m.FetchByAPIUUIDAndOctoNameInput.Ctx = make(chan context.Context, 100)
@@ -73,18 +131,54 @@ func newMockGarbanzoService() *mockGarbanzoService {
 	m.CreateInput.GarbanzoIn = make(chan data.Garbanzo, 100)
 	m.CreateOutput.GarbanzoOut = make(chan data.Garbanzo, 100)
 	m.CreateOutput.Err = make(chan error, 100)
+	m.CreateIdempotentlyCalled = make(chan bool, 100)
+	m.CreateIdempotentlyInput.Ctx = make(chan context.Context, 100)
+	m.CreateIdempotentlyInput.OctoName = make(chan string, 100)
+	m.CreateIdempotentlyInput.GarbanzoIn = make(chan data.Garbanzo, 100)
+	m.CreateIdempotentlyInput.Request = make(chan data.IdempotentRequest, 100)
+	m.CreateIdempotentlyInput.Respond = make(chan func(garbanzo data.Garbanzo) []byte, 100)
+	m.CreateIdempotentlyOutput.Response = make(chan []byte, 100)
+	m.CreateIdempotentlyOutput.Replayed = make(chan bool, 100)
+	m.CreateIdempotentlyOutput.Err = make(chan error, 100)
+	m.CreateBatchCalled = make(chan bool, 100)
+	m.CreateBatchInput.Ctx = make(chan context.Context, 100)
+	m.CreateBatchInput.OctoName = make(chan string, 100)
+	m.CreateBatchInput.GarbanzosIn = make(chan []data.Garbanzo, 100)
+	m.CreateBatchInput.BestEffort = make(chan bool, 100)
+	m.CreateBatchOutput.Results = make(chan []services.BatchResult, 100)
+	m.CreateBatchOutput.Err = make(chan error, 100)
+	m.UpdateByAPIUUIDAndOctoNameCalled = make(chan bool, 100)
+	m.UpdateByAPIUUIDAndOctoNameInput.Ctx = make(chan context.Context, 100)
+	m.UpdateByAPIUUIDAndOctoNameInput.ApiUUID = make(chan uuid.UUID, 100)
+	m.UpdateByAPIUUIDAndOctoNameInput.OctoName = make(chan string, 100)
+	m.UpdateByAPIUUIDAndOctoNameInput.GarbanzoIn = make(chan data.Garbanzo, 100)
+	m.UpdateByAPIUUIDAndOctoNameOutput.GarbanzoOut = make(chan data.Garbanzo, 100)
+	m.UpdateByAPIUUIDAndOctoNameOutput.Err = make(chan error, 100)
+	m.MoveByAPIUUIDAndOctoNameCalled = make(chan bool, 100)
+	m.MoveByAPIUUIDAndOctoNameInput.Ctx = make(chan context.Context, 100)
+	m.MoveByAPIUUIDAndOctoNameInput.ApiUUID = make(chan uuid.UUID, 100)
+	m.MoveByAPIUUIDAndOctoNameInput.OctoName = make(chan string, 100)
+	m.MoveByAPIUUIDAndOctoNameInput.TargetOctoName = make(chan string, 100)
+	m.MoveByAPIUUIDAndOctoNameInput.Id = make(chan int, 100)
+	m.MoveByAPIUUIDAndOctoNameInput.Version = make(chan int, 100)
+	m.MoveByAPIUUIDAndOctoNameOutput.GarbanzoOut = make(chan data.Garbanzo, 100)
+	m.MoveByAPIUUIDAndOctoNameOutput.Err = make(chan error, 100)
 	m.DeleteByAPIUUIDAndOctoNameCalled = make(chan bool, 100)
 	m.DeleteByAPIUUIDAndOctoNameInput.Ctx = make(chan context.Context, 100)
 	m.DeleteByAPIUUIDAndOctoNameInput.ApiUUID = make(chan uuid.UUID, 100)
 	m.DeleteByAPIUUIDAndOctoNameInput.OctoName = make(chan string, 100)
+	m.DeleteByAPIUUIDAndOctoNameInput.Id = make(chan int, 100)
+	m.DeleteByAPIUUIDAndOctoNameInput.Version = make(chan int, 100)
 	m.DeleteByAPIUUIDAndOctoNameOutput.Err = make(chan error, 100)
 	return m
 }
-func (m *mockGarbanzoService) FetchByOctoName(ctx context.Context, octoName string) (garbanzos []data.Garbanzo, err error) {
+func (m *mockGarbanzoService) FetchByOctoName(ctx context.Context, octoName string, filter persistence.GarbanzoFilter, page persistence.Page) (garbanzos []data.Garbanzo, more bool, err error) {
 	m.FetchByOctoNameCalled <- true
 	m.FetchByOctoNameInput.Ctx <- ctx
 	m.FetchByOctoNameInput.OctoName <- octoName
-	return <-m.FetchByOctoNameOutput.Garbanzos, <-m.FetchByOctoNameOutput.Err
+	m.FetchByOctoNameInput.Filter <- filter
+	m.FetchByOctoNameInput.Page <- page
+	return <-m.FetchByOctoNameOutput.Garbanzos, <-m.FetchByOctoNameOutput.More, <-m.FetchByOctoNameOutput.Err
 }
 func (m *mockGarbanzoService) FetchByAPIUUIDAndOctoName(ctx context.Context, apiUUID uuid.UUID, octoName string) (garbanzo data.Garbanzo, err error) {
 	m.FetchByAPIUUIDAndOctoNameCalled <- true
@@ -100,11 +194,48 @@ func (m *mockGarbanzoService) Create(ctx context.Context, octoName string, garba
 	m.CreateInput.GarbanzoIn <- garbanzoIn
 	return <-m.CreateOutput.GarbanzoOut, <-m.CreateOutput.Err
 }
-func (m *mockGarbanzoService) DeleteByAPIUUIDAndOctoName(ctx context.Context, apiUUID uuid.UUID, octoName string) (err error) {
+func (m *mockGarbanzoService) CreateIdempotently(ctx context.Context, octoName string, garbanzoIn data.Garbanzo, request data.IdempotentRequest, respond func(garbanzo data.Garbanzo) []byte) (response []byte, replayed bool, err error) {
+	m.CreateIdempotentlyCalled <- true
+	m.CreateIdempotentlyInput.Ctx <- ctx
+	m.CreateIdempotentlyInput.OctoName <- octoName
+	m.CreateIdempotentlyInput.GarbanzoIn <- garbanzoIn
+	m.CreateIdempotentlyInput.Request <- request
+	m.CreateIdempotentlyInput.Respond <- respond
+	return <-m.CreateIdempotentlyOutput.Response, <-m.CreateIdempotentlyOutput.Replayed, <-m.CreateIdempotentlyOutput.Err
+}
+func (m *mockGarbanzoService) CreateBatch(ctx context.Context, octoName string, garbanzosIn []data.Garbanzo, bestEffort bool) (results []services.BatchResult, err error) {
+	m.CreateBatchCalled <- true
+	m.CreateBatchInput.Ctx <- ctx
+	m.CreateBatchInput.OctoName <- octoName
+	m.CreateBatchInput.GarbanzosIn <- garbanzosIn
+	m.CreateBatchInput.BestEffort <- bestEffort
+	return <-m.CreateBatchOutput.Results, <-m.CreateBatchOutput.Err
+}
+func (m *mockGarbanzoService) UpdateByAPIUUIDAndOctoName(ctx context.Context, apiUUID uuid.UUID, octoName string, garbanzoIn data.Garbanzo) (garbanzoOut data.Garbanzo, err error) {
+	m.UpdateByAPIUUIDAndOctoNameCalled <- true
+	m.UpdateByAPIUUIDAndOctoNameInput.Ctx <- ctx
+	m.UpdateByAPIUUIDAndOctoNameInput.ApiUUID <- apiUUID
+	m.UpdateByAPIUUIDAndOctoNameInput.OctoName <- octoName
+	m.UpdateByAPIUUIDAndOctoNameInput.GarbanzoIn <- garbanzoIn
+	return <-m.UpdateByAPIUUIDAndOctoNameOutput.GarbanzoOut, <-m.UpdateByAPIUUIDAndOctoNameOutput.Err
+}
+func (m *mockGarbanzoService) MoveByAPIUUIDAndOctoName(ctx context.Context, apiUUID uuid.UUID, octoName string, targetOctoName string, id int, version int) (garbanzoOut data.Garbanzo, err error) {
+	m.MoveByAPIUUIDAndOctoNameCalled <- true
+	m.MoveByAPIUUIDAndOctoNameInput.Ctx <- ctx
+	m.MoveByAPIUUIDAndOctoNameInput.ApiUUID <- apiUUID
+	m.MoveByAPIUUIDAndOctoNameInput.OctoName <- octoName
+	m.MoveByAPIUUIDAndOctoNameInput.TargetOctoName <- targetOctoName
+	m.MoveByAPIUUIDAndOctoNameInput.Id <- id
+	m.MoveByAPIUUIDAndOctoNameInput.Version <- version
+	return <-m.MoveByAPIUUIDAndOctoNameOutput.GarbanzoOut, <-m.MoveByAPIUUIDAndOctoNameOutput.Err
+}
+func (m *mockGarbanzoService) DeleteByAPIUUIDAndOctoName(ctx context.Context, apiUUID uuid.UUID, octoName string, id int, version int) (err error) {
 	m.DeleteByAPIUUIDAndOctoNameCalled <- true
 	m.DeleteByAPIUUIDAndOctoNameInput.Ctx <- ctx
 	m.DeleteByAPIUUIDAndOctoNameInput.ApiUUID <- apiUUID
 	m.DeleteByAPIUUIDAndOctoNameInput.OctoName <- octoName
+	m.DeleteByAPIUUIDAndOctoNameInput.Id <- id
+	m.DeleteByAPIUUIDAndOctoNameInput.Version <- version
 	return <-m.DeleteByAPIUUIDAndOctoNameOutput.Err
 }
 
@@ -0,0 +1,67 @@
+package garbanzotype
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/justinas/alice"
+
+	"github.com/myshkin5/effective-octo-garbanzo/api/handlers"
+	"github.com/myshkin5/effective-octo-garbanzo/persistence"
+	"github.com/myshkin5/effective-octo-garbanzo/persistence/data"
+)
+
+type GarbanzoType struct {
+	Link string `json:"link"`
+	Name string `json:"name"`
+}
+
+var fieldMapping = map[string]string{
+	"Link": "link",
+	"Name": "name",
+}
+
+type GarbanzoTypeService interface {
+	FetchAll(ctx context.Context) (garbanzoTypes []data.GarbanzoType, err error)
+	FetchByName(ctx context.Context, name string) (garbanzoType data.GarbanzoType, err error)
+	Create(ctx context.Context, garbanzoTypeIn data.GarbanzoType) (garbanzoTypeOut data.GarbanzoType, err error)
+}
+
+type garbanzoType struct {
+	garbanzoTypeService GarbanzoTypeService
+	baseURL             string
+}
+
+func MapRoutes(baseURL string, router *mux.Router, middleware alice.Chain, authorize handlers.Authorizer, garbanzoTypeService GarbanzoTypeService) {
+	handler := &garbanzoType{
+		garbanzoTypeService: garbanzoTypeService,
+		baseURL:             baseURL + "garbanzo-types/",
+	}
+	methodHandler := make(handlers.MethodHandler)
+	methodHandler[http.MethodGet] = authorize(handlers.ScopeGarbanzoTypesRead)(http.HandlerFunc(handler.get))
+	router.Handle("/garbanzo-types/{name}", middleware.Then(methodHandler))
+}
+
+func (g *garbanzoType) get(w http.ResponseWriter, req *http.Request) {
+	name := mux.Vars(req)["name"]
+
+	garbanzoType, err := g.garbanzoTypeService.FetchByName(req.Context(), name)
+	if err == persistence.ErrNotFound {
+		handlers.Error(req.Context(), w, fmt.Sprintf("Garbanzo type %s not found", name), http.StatusNotFound, err, fieldMapping)
+		return
+	} else if err != nil {
+		handlers.Error(req.Context(), w, "Error fetching garbanzo type", http.StatusInternalServerError, err, fieldMapping)
+		return
+	}
+
+	handlers.Respond(w, http.StatusOK, fromPersistence(garbanzoType, g.baseURL))
+}
+
+func fromPersistence(garbanzoType data.GarbanzoType, baseURL string) GarbanzoType {
+	return GarbanzoType{
+		Link: baseURL + garbanzoType.Name,
+		Name: garbanzoType.Name,
+	}
+}
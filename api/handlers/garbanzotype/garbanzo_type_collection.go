@@ -0,0 +1,80 @@
+package garbanzotype
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/justinas/alice"
+
+	"github.com/myshkin5/effective-octo-garbanzo/api/handlers"
+	"github.com/myshkin5/effective-octo-garbanzo/persistence"
+	"github.com/myshkin5/effective-octo-garbanzo/persistence/data"
+)
+
+type GarbanzoTypeList struct {
+	Link          string         `json:"link"`
+	GarbanzoTypes []GarbanzoType `json:"garbanzo-types"`
+}
+
+type garbanzoTypeCollection struct {
+	garbanzoTypeService GarbanzoTypeService
+	baseURL             string
+}
+
+// MapCollectionRoutes maps the garbanzo type collection. Any authenticated
+// user may list the types but only requests passed by adminHandler may add
+// them.
+func MapCollectionRoutes(baseURL string, router *mux.Router, middleware alice.Chain, authorize handlers.Authorizer, adminHandler alice.Constructor, garbanzoTypeService GarbanzoTypeService) {
+	handler := &garbanzoTypeCollection{
+		garbanzoTypeService: garbanzoTypeService,
+		baseURL:             baseURL + "garbanzo-types/",
+	}
+	methodHandler := make(handlers.MethodHandler)
+	methodHandler[http.MethodGet] = authorize(handlers.ScopeGarbanzoTypesRead)(http.HandlerFunc(handler.get))
+	methodHandler[http.MethodPost] = adminHandler(authorize(handlers.ScopeGarbanzoTypesWrite)(http.HandlerFunc(handler.post)))
+	router.Handle("/garbanzo-types", middleware.Then(methodHandler))
+}
+
+func (g *garbanzoTypeCollection) get(w http.ResponseWriter, req *http.Request) {
+	garbanzoTypes, err := g.garbanzoTypeService.FetchAll(req.Context())
+	if err != nil {
+		handlers.Error(req.Context(), w, "Error fetching all garbanzo types", http.StatusInternalServerError, err, fieldMapping)
+		return
+	}
+
+	list := GarbanzoTypeList{
+		Link: strings.TrimSuffix(g.baseURL, "/"),
+		// Intentionally an empty slice so list is present in output even when empty
+		GarbanzoTypes: []GarbanzoType{},
+	}
+	for _, garbanzoType := range garbanzoTypes {
+		list.GarbanzoTypes = append(list.GarbanzoTypes, fromPersistence(garbanzoType, g.baseURL))
+	}
+
+	handlers.Respond(w, http.StatusOK, list)
+}
+
+func (g *garbanzoTypeCollection) post(w http.ResponseWriter, req *http.Request) {
+	var dto GarbanzoType
+	err := json.NewDecoder(req.Body).Decode(&dto)
+	if err != nil {
+		handlers.Error(req.Context(), w, handlers.InvalidJSON, http.StatusBadRequest, err, fieldMapping)
+		return
+	}
+
+	garbanzoType, err := g.garbanzoTypeService.Create(req.Context(), data.GarbanzoType{
+		Name: dto.Name,
+	})
+	if err == persistence.ErrDuplicate {
+		handlers.Error(req.Context(), w, fmt.Sprintf("Garbanzo type %s already exists", dto.Name), http.StatusConflict, err, fieldMapping)
+		return
+	} else if err != nil {
+		handlers.Error(req.Context(), w, "Error creating new garbanzo type", http.StatusInternalServerError, err, fieldMapping)
+		return
+	}
+
+	handlers.Respond(w, http.StatusCreated, fromPersistence(garbanzoType, g.baseURL))
+}
@@ -0,0 +1,271 @@
+package garbanzotype_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/justinas/alice"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/myshkin5/effective-octo-garbanzo/api/handlers/garbanzotype"
+	"github.com/myshkin5/effective-octo-garbanzo/persistence"
+	"github.com/myshkin5/effective-octo-garbanzo/persistence/data"
+	"github.com/myshkin5/effective-octo-garbanzo/services"
+)
+
+var _ = Describe("GarbanzoTypeCollection", func() {
+	var (
+		recorder    *httptest.ResponseRecorder
+		request     *http.Request
+		mockService *mockGarbanzoTypeService
+		router      *mux.Router
+		isAdmin     bool
+		grantScopes bool
+	)
+
+	BeforeEach(func() {
+		recorder = httptest.NewRecorder()
+		recorder.Code = 0
+
+		mockService = newMockGarbanzoTypeService()
+
+		isAdmin = true
+		adminHandler := func(h http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if !isAdmin {
+					w.WriteHeader(http.StatusForbidden)
+					return
+				}
+				h.ServeHTTP(w, r)
+			})
+		}
+
+		grantScopes = true
+		authorize := func(scope string) alice.Constructor {
+			return func(h http.Handler) http.Handler {
+				return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					if !grantScopes {
+						w.Header().Set("X-Required-Scope", scope)
+						w.WriteHeader(http.StatusForbidden)
+						return
+					}
+					h.ServeHTTP(w, r)
+				})
+			}
+		}
+
+		router = mux.NewRouter()
+		garbanzotype.MapCollectionRoutes("http://here/", router, alice.Chain{}, authorize, adminHandler, mockService)
+	})
+
+	Describe("GET", func() {
+		Context("happy path", func() {
+			BeforeEach(func() {
+				isAdmin = false
+
+				var err error
+				request, err = http.NewRequest(http.MethodGet, "/garbanzo-types", nil)
+				Expect(err).NotTo(HaveOccurred())
+
+				mockService.FetchAllOutput.GarbanzoTypes <- []data.GarbanzoType{
+					{
+						Id:   1001,
+						Name: "DESI",
+					},
+					{
+						Id:   1002,
+						Name: "KABULI",
+					},
+				}
+				mockService.FetchAllOutput.Err <- nil
+
+				router.ServeHTTP(recorder, request)
+			})
+
+			It("returns an ok status code without admin access", func() {
+				Expect(recorder.Code).To(Equal(http.StatusOK))
+			})
+
+			It("returns all garbanzo types in the body", func() {
+				Expect(recorder.Body).To(MatchJSON(`{
+					"link": "http://here/garbanzo-types",
+					"garbanzo-types": [
+						{
+							"link": "http://here/garbanzo-types/DESI",
+							"name": "DESI"
+						},
+						{
+							"link": "http://here/garbanzo-types/KABULI",
+							"name": "KABULI"
+						}
+					]
+				}`))
+			})
+		})
+
+		Context("unhappy path", func() {
+			BeforeEach(func() {
+				var err error
+				request, err = http.NewRequest(http.MethodGet, "/garbanzo-types", nil)
+				Expect(err).NotTo(HaveOccurred())
+
+				mockService.FetchAllOutput.GarbanzoTypes <- nil
+				mockService.FetchAllOutput.Err <- errors.New("bad stuff")
+
+				router.ServeHTTP(recorder, request)
+			})
+
+			It("returns a JSON error", func() {
+				Expect(recorder.Code).To(Equal(http.StatusInternalServerError))
+				Expect(recorder.Body).To(MatchJSON(`{
+					"code": 500,
+					"error": "Error fetching all garbanzo types",
+					"status": "Internal Server Error"
+				}`))
+			})
+		})
+	})
+
+	Describe("POST", func() {
+		Context("happy path", func() {
+			BeforeEach(func() {
+				var err error
+				request, err = http.NewRequest(http.MethodPost, "/garbanzo-types", strings.NewReader(`{
+					"name": "BAMBAI"
+				}`))
+				Expect(err).NotTo(HaveOccurred())
+
+				mockService.CreateOutput.GarbanzoTypeOut <- data.GarbanzoType{
+					Id:   1003,
+					Name: "BAMBAI",
+				}
+				mockService.CreateOutput.Err <- nil
+
+				router.ServeHTTP(recorder, request)
+			})
+
+			It("creates the garbanzo type via the service", func() {
+				Expect(mockService.CreateInput.GarbanzoTypeIn).To(Receive(Equal(data.GarbanzoType{
+					Name: "BAMBAI",
+				})))
+			})
+
+			It("returns a created status code", func() {
+				Expect(recorder.Code).To(Equal(http.StatusCreated))
+			})
+
+			It("returns the newly created garbanzo type in the body", func() {
+				Expect(recorder.Body).To(MatchJSON(`{
+					"link": "http://here/garbanzo-types/BAMBAI",
+					"name": "BAMBAI"
+				}`))
+			})
+		})
+
+		Context("not an admin", func() {
+			BeforeEach(func() {
+				isAdmin = false
+
+				var err error
+				request, err = http.NewRequest(http.MethodPost, "/garbanzo-types", strings.NewReader(`{
+					"name": "BAMBAI"
+				}`))
+				Expect(err).NotTo(HaveOccurred())
+
+				router.ServeHTTP(recorder, request)
+			})
+
+			It("is rejected by the admin handler", func() {
+				Expect(recorder.Code).To(Equal(http.StatusForbidden))
+				Expect(mockService.CreateCalled).To(BeEmpty())
+			})
+		})
+
+		Context("invalid json", func() {
+			BeforeEach(func() {
+				var err error
+				request, err = http.NewRequest(http.MethodPost, "/garbanzo-types", strings.NewReader("not json"))
+				Expect(err).NotTo(HaveOccurred())
+
+				router.ServeHTTP(recorder, request)
+			})
+
+			It("returns a bad request status code", func() {
+				Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+			})
+		})
+
+		Context("validation error", func() {
+			BeforeEach(func() {
+				var err error
+				request, err = http.NewRequest(http.MethodPost, "/garbanzo-types", strings.NewReader(`{}`))
+				Expect(err).NotTo(HaveOccurred())
+
+				mockService.CreateOutput.GarbanzoTypeOut <- data.GarbanzoType{}
+				mockService.CreateOutput.Err <- services.NewValidationError(map[string][]string{
+					"Name": {"must be present"},
+				})
+
+				router.ServeHTTP(recorder, request)
+			})
+
+			It("returns a JSON error", func() {
+				Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+				Expect(recorder.Body).To(MatchJSON(`{
+					"code": 400,
+					"error": "Error creating new garbanzo type",
+					"errors": ["name must be present"],
+					"status": "Bad Request"
+				}`))
+			})
+		})
+
+		Context("duplicate error", func() {
+			BeforeEach(func() {
+				var err error
+				request, err = http.NewRequest(http.MethodPost, "/garbanzo-types", strings.NewReader(`{
+					"name": "DESI"
+				}`))
+				Expect(err).NotTo(HaveOccurred())
+
+				mockService.CreateOutput.GarbanzoTypeOut <- data.GarbanzoType{}
+				mockService.CreateOutput.Err <- persistence.ErrDuplicate
+
+				router.ServeHTTP(recorder, request)
+			})
+
+			It("returns a JSON error", func() {
+				Expect(recorder.Code).To(Equal(http.StatusConflict))
+				Expect(recorder.Body).To(MatchJSON(`{
+					"code": 409,
+					"error": "Garbanzo type DESI already exists",
+					"status": "Conflict"
+				}`))
+			})
+		})
+	})
+
+	Describe("scopes", func() {
+		It("requires a scope for each method", func() {
+			grantScopes = false
+			for _, route := range []struct{ method, path, scope string }{
+				{http.MethodGet, "/garbanzo-types", "garbanzo-types:read"},
+				{http.MethodPost, "/garbanzo-types", "garbanzo-types:write"},
+			} {
+				recorder = httptest.NewRecorder()
+				var err error
+				request, err = http.NewRequest(route.method, route.path, nil)
+				Expect(err).NotTo(HaveOccurred())
+
+				router.ServeHTTP(recorder, request)
+
+				Expect(recorder.Code).To(Equal(http.StatusForbidden), route.method)
+				Expect(recorder.Header().Get("X-Required-Scope")).To(Equal(route.scope), route.method)
+			}
+		})
+	})
+})
@@ -0,0 +1,148 @@
+package garbanzotype_test
+
+//go:generate hel
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/gorilla/mux"
+	"github.com/justinas/alice"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/myshkin5/effective-octo-garbanzo/api/handlers/garbanzotype"
+	"github.com/myshkin5/effective-octo-garbanzo/persistence"
+	"github.com/myshkin5/effective-octo-garbanzo/persistence/data"
+)
+
+var _ = Describe("GarbanzoType", func() {
+	var (
+		recorder    *httptest.ResponseRecorder
+		request     *http.Request
+		mockService *mockGarbanzoTypeService
+		router      *mux.Router
+		grantScopes bool
+	)
+
+	BeforeEach(func() {
+		recorder = httptest.NewRecorder()
+		recorder.Code = 0
+
+		mockService = newMockGarbanzoTypeService()
+
+		grantScopes = true
+		authorize := func(scope string) alice.Constructor {
+			return func(h http.Handler) http.Handler {
+				return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					if !grantScopes {
+						w.Header().Set("X-Required-Scope", scope)
+						w.WriteHeader(http.StatusForbidden)
+						return
+					}
+					h.ServeHTTP(w, r)
+				})
+			}
+		}
+
+		router = mux.NewRouter()
+		garbanzotype.MapRoutes("http://here/", router, alice.Chain{}, authorize, mockService)
+	})
+
+	Describe("GET", func() {
+		Context("happy path", func() {
+			BeforeEach(func() {
+				var err error
+				request, err = http.NewRequest(http.MethodGet, "/garbanzo-types/DESI", nil)
+				Expect(err).NotTo(HaveOccurred())
+
+				mockService.FetchByNameOutput.GarbanzoType <- data.GarbanzoType{
+					Id:   1001,
+					Name: "DESI",
+				}
+				mockService.FetchByNameOutput.Err <- nil
+
+				router.ServeHTTP(recorder, request)
+			})
+
+			It("fetches the garbanzo type via the service", func() {
+				Expect(mockService.FetchByNameInput.Name).To(Receive(Equal("DESI")))
+			})
+
+			It("returns an ok status code", func() {
+				Expect(recorder.Code).To(Equal(http.StatusOK))
+			})
+
+			It("returns the garbanzo type in the body", func() {
+				Expect(recorder.Body).To(MatchJSON(`{
+					"link": "http://here/garbanzo-types/DESI",
+					"name": "DESI"
+				}`))
+			})
+		})
+
+		Context("not found", func() {
+			BeforeEach(func() {
+				var err error
+				request, err = http.NewRequest(http.MethodGet, "/garbanzo-types/PINTO", nil)
+				Expect(err).NotTo(HaveOccurred())
+
+				mockService.FetchByNameOutput.GarbanzoType <- data.GarbanzoType{}
+				mockService.FetchByNameOutput.Err <- persistence.ErrNotFound
+
+				router.ServeHTTP(recorder, request)
+			})
+
+			It("returns a JSON error", func() {
+				Expect(recorder.Code).To(Equal(http.StatusNotFound))
+				Expect(recorder.Body).To(MatchJSON(`{
+					"code": 404,
+					"error": "Garbanzo type PINTO not found",
+					"status": "Not Found"
+				}`))
+			})
+		})
+
+		Context("unhappy path", func() {
+			BeforeEach(func() {
+				var err error
+				request, err = http.NewRequest(http.MethodGet, "/garbanzo-types/DESI", nil)
+				Expect(err).NotTo(HaveOccurred())
+
+				mockService.FetchByNameOutput.GarbanzoType <- data.GarbanzoType{}
+				mockService.FetchByNameOutput.Err <- errors.New("bad stuff")
+
+				router.ServeHTTP(recorder, request)
+			})
+
+			It("returns a JSON error", func() {
+				Expect(recorder.Code).To(Equal(http.StatusInternalServerError))
+				Expect(recorder.Body).To(MatchJSON(`{
+					"code": 500,
+					"error": "Error fetching garbanzo type",
+					"status": "Internal Server Error"
+				}`))
+			})
+		})
+	})
+
+	Describe("scopes", func() {
+		It("requires a scope for each method", func() {
+			grantScopes = false
+			for _, route := range []struct{ method, path, scope string }{
+				{http.MethodGet, "/garbanzo-types/DESI", "garbanzo-types:read"},
+			} {
+				recorder = httptest.NewRecorder()
+				var err error
+				request, err = http.NewRequest(route.method, route.path, nil)
+				Expect(err).NotTo(HaveOccurred())
+
+				router.ServeHTTP(recorder, request)
+
+				Expect(recorder.Code).To(Equal(http.StatusForbidden), route.method)
+				Expect(recorder.Header().Get("X-Required-Scope")).To(Equal(route.scope), route.method)
+			}
+		})
+	})
+})
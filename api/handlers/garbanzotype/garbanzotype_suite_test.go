@@ -0,0 +1,13 @@
+package garbanzotype_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestGarbanzoType(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "API - Handlers - Garbanzo Type Suite")
+}
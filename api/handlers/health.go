@@ -1,20 +1,104 @@
 package handlers
 
 import (
+	"context"
 	"net/http"
+	"sync/atomic"
 
 	"github.com/gorilla/mux"
 	"github.com/justinas/alice"
+
+	"github.com/myshkin5/effective-octo-garbanzo/logs"
+)
+
+// HealthCheck returns an error when a dependency of the service is unusable.
+type HealthCheck func(ctx context.Context) error
+
+// Health reports whether the service is accepting work. It starts out good and
+// fails once the service begins shutting down so load balancers stop routing
+// new requests to it.
+type Health struct {
+	// Checks are run by readiness probes, keyed by the name reported for each
+	Checks map[string]HealthCheck
+
+	shuttingDown int32
+}
+
+const (
+	healthGood         = "GOOD"
+	healthBad          = "BAD"
+	healthShuttingDown = "SHUTTING_DOWN"
 )
 
-func MapHealthRoutes(router *mux.Router, middleware alice.Chain) {
+func (h *Health) ShutDown() {
+	atomic.StoreInt32(&h.shuttingDown, 1)
+}
+
+func (h *Health) isShuttingDown() bool {
+	return atomic.LoadInt32(&h.shuttingDown) == 1
+}
+
+// MapHealthRoutes maps /health with middleware and the liveness and readiness
+// probes with probeMiddleware which shouldn't require authentication.
+func MapHealthRoutes(router *mux.Router, middleware, probeMiddleware alice.Chain, health *Health) {
+	liveHandler := make(MethodHandler)
+	liveHandler[http.MethodGet] = http.HandlerFunc(getLive)
+	router.Path("/health/live").Handler(probeMiddleware.Then(liveHandler))
+
+	readyHandler := make(MethodHandler)
+	readyHandler[http.MethodGet] = http.HandlerFunc(health.getReady)
+	router.Path("/health/ready").Handler(probeMiddleware.Then(readyHandler))
+
 	methodHandler := make(MethodHandler)
-	methodHandler[http.MethodGet] = http.HandlerFunc(getHealth)
+	methodHandler[http.MethodGet] = http.HandlerFunc(health.getHealth)
 	router.PathPrefix("/health").Handler(middleware.Then(methodHandler))
 }
 
-func getHealth(w http.ResponseWriter, _ *http.Request) {
+func (h *Health) getHealth(w http.ResponseWriter, _ *http.Request) {
+	if h.isShuttingDown() {
+		Respond(w, http.StatusServiceUnavailable, JSONObject{
+			"health": healthShuttingDown,
+		})
+		return
+	}
+
+	Respond(w, http.StatusOK, JSONObject{
+		"health": healthGood,
+	})
+}
+
+// getLive only shows the service can respond. Dependencies are left to
+// readiness so an outage elsewhere doesn't get the service restarted.
+func getLive(w http.ResponseWriter, _ *http.Request) {
 	Respond(w, http.StatusOK, JSONObject{
-		"health": "GOOD",
+		"health": healthGood,
+	})
+}
+
+func (h *Health) getReady(w http.ResponseWriter, req *http.Request) {
+	if h.isShuttingDown() {
+		Respond(w, http.StatusServiceUnavailable, JSONObject{
+			"health": healthShuttingDown,
+		})
+		return
+	}
+
+	status, health := http.StatusOK, healthGood
+	checks := JSONObject{}
+	for name, check := range h.Checks {
+		err := check(req.Context())
+		if err != nil {
+			logs.FromContext(req.Context()).Warnf("Readiness check %s failed: %v", name, err)
+			status, health = http.StatusServiceUnavailable, healthBad
+			checks[name] = healthBad
+			continue
+		}
+
+		checks[name] = healthGood
+	}
+
+	Respond(w, status, JSONObject{
+		"health": health,
+		"checks": checks,
 	})
 }
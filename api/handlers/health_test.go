@@ -1,6 +1,8 @@
 package handlers_test
 
 import (
+	"context"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 
@@ -17,23 +19,50 @@ var _ = Describe("Health", func() {
 		recorder *httptest.ResponseRecorder
 		request  *http.Request
 		router   *mux.Router
+		health   *handlers.Health
+		dbErr    error
 	)
 
+	chain := func(name string) alice.Chain {
+		return alice.New(func(h http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				w.Header().Set("X-Chain", name)
+				h.ServeHTTP(w, req)
+			})
+		})
+	}
+
+	get := func(path string) {
+		var err error
+		request, err = http.NewRequest(http.MethodGet, path, nil)
+		Expect(err).NotTo(HaveOccurred())
+
+		router.ServeHTTP(recorder, request)
+	}
+
 	BeforeEach(func() {
 		recorder = httptest.NewRecorder()
 		recorder.Code = 0
 
+		dbErr = nil
+		health = &handlers.Health{
+			Checks: map[string]handlers.HealthCheck{
+				"database": func(context.Context) error {
+					return dbErr
+				},
+				"keys": func(context.Context) error {
+					return nil
+				},
+			},
+		}
+
 		router = mux.NewRouter()
-		handlers.MapHealthRoutes(router, alice.Chain{})
+		handlers.MapHealthRoutes(router, chain("middleware"), chain("probe"), health)
 	})
 
 	Describe("happy path", func() {
 		BeforeEach(func() {
-			var err error
-			request, err = http.NewRequest(http.MethodGet, "/health", nil)
-			Expect(err).NotTo(HaveOccurred())
-
-			router.ServeHTTP(recorder, request)
+			get("/health")
 		})
 
 		It("returns a good health body", func() {
@@ -45,5 +74,93 @@ var _ = Describe("Health", func() {
 		It("returns an ok status code", func() {
 			Expect(recorder.Code).To(Equal(http.StatusOK))
 		})
+
+		It("uses the standard middleware", func() {
+			Expect(recorder.Header().Get("X-Chain")).To(Equal("middleware"))
+		})
+	})
+
+	Describe("shutting down", func() {
+		BeforeEach(func() {
+			health.ShutDown()
+
+			get("/health")
+		})
+
+		It("returns a shutting down health body", func() {
+			Expect(recorder.Body).To(MatchJSON(`{
+				"health": "SHUTTING_DOWN"
+			}`))
+		})
+
+		It("returns a service unavailable status code", func() {
+			Expect(recorder.Code).To(Equal(http.StatusServiceUnavailable))
+		})
+	})
+
+	Describe("live", func() {
+		It("returns a good health body without running the checks", func() {
+			dbErr = errors.New("connection refused")
+
+			get("/health/live")
+
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(recorder.Body).To(MatchJSON(`{
+				"health": "GOOD"
+			}`))
+		})
+
+		It("uses the probe middleware", func() {
+			get("/health/live")
+
+			Expect(recorder.Header().Get("X-Chain")).To(Equal("probe"))
+		})
+	})
+
+	Describe("ready", func() {
+		It("returns a good health body when every check passes", func() {
+			get("/health/ready")
+
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(recorder.Body).To(MatchJSON(`{
+				"health": "GOOD",
+				"checks": {
+					"database": "GOOD",
+					"keys": "GOOD"
+				}
+			}`))
+		})
+
+		It("returns a bad health body when any check fails", func() {
+			dbErr = errors.New("connection refused")
+
+			get("/health/ready")
+
+			Expect(recorder.Code).To(Equal(http.StatusServiceUnavailable))
+			Expect(recorder.Body).To(MatchJSON(`{
+				"health": "BAD",
+				"checks": {
+					"database": "BAD",
+					"keys": "GOOD"
+				}
+			}`))
+		})
+
+		It("returns a shutting down health body when shutting down", func() {
+			health.ShutDown()
+
+			get("/health/ready")
+
+			Expect(recorder.Code).To(Equal(http.StatusServiceUnavailable))
+			Expect(recorder.Body).To(MatchJSON(`{
+				"health": "SHUTTING_DOWN"
+			}`))
+		})
+
+		It("uses the probe middleware", func() {
+			get("/health/ready")
+
+			Expect(recorder.Header().Get("X-Chain")).To(Equal("probe"))
+		})
 	})
 })
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/myshkin5/effective-octo-garbanzo/logs"
+	"github.com/myshkin5/effective-octo-garbanzo/persistence/data"
+)
+
+const (
+	IdempotencyKeyHeader     = "Idempotency-Key"
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+	maxIdempotencyKeyLength  = 255
+)
+
+var ErrInvalidIdempotencyKey = errors.New("idempotency key must be 1 to 255 printable ASCII characters")
+
+// storedResponse is the response recorded for an idempotent request.
+type storedResponse struct {
+	Code int             `json:"code"`
+	ETag string          `json:"etag"`
+	Body json.RawMessage `json:"body"`
+}
+
+// ReadIdempotentRequest reads the body of a create. When the request has an
+// Idempotency-Key header the key is returned along with a hash of the
+// request's method, path and body so that a retry can be told apart from a
+// different request reusing the key. The key is empty otherwise.
+func ReadIdempotentRequest(req *http.Request) (data.IdempotentRequest, io.Reader, error) {
+	key := req.Header.Get(IdempotencyKeyHeader)
+	if key == "" {
+		return data.IdempotentRequest{}, req.Body, nil
+	}
+	if !validIdempotencyKey(key) {
+		return data.IdempotentRequest{}, nil, ErrInvalidIdempotencyKey
+	}
+
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return data.IdempotentRequest{}, nil, err
+	}
+
+	hash := sha256.New()
+	hash.Write([]byte(req.Method + " " + req.URL.Path + "\n"))
+	hash.Write(body)
+
+	return data.IdempotentRequest{
+		Key:         key,
+		RequestHash: hex.EncodeToString(hash.Sum(nil)),
+	}, bytes.NewReader(body), nil
+}
+
+// StoredResponse renders the response RespondWithVersion would send so that
+// it can be recorded with an idempotent request.
+func StoredResponse(code int, v interface{}, id, version int) []byte {
+	return marshal(storedResponse{
+		Code: code,
+		ETag: VersionETag(id, version),
+		Body: marshal(v),
+	})
+}
+
+// RespondStored sends a response rendered by StoredResponse. Responses
+// replayed to a retried request have an Idempotent-Replayed header.
+func RespondStored(w http.ResponseWriter, response []byte, replayed bool) {
+	var stored storedResponse
+	err := json.Unmarshal(response, &stored)
+	if err != nil {
+		logs.Logger.Panic("Unexpected stored response err: ", err)
+	}
+
+	w.Header().Set("ETag", stored.ETag)
+	if replayed {
+		w.Header().Set(IdempotentReplayedHeader, "true")
+	}
+	w.WriteHeader(stored.Code)
+	w.Write(stored.Body)
+}
+
+func validIdempotencyKey(key string) bool {
+	if len(key) > maxIdempotencyKeyLength {
+		return false
+	}
+
+	for _, c := range key {
+		if c < ' ' || c > '~' {
+			return false
+		}
+	}
+
+	return true
+}
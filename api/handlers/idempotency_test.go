@@ -0,0 +1,91 @@
+package handlers_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/myshkin5/effective-octo-garbanzo/api/handlers"
+)
+
+var _ = Describe("Idempotency", func() {
+	newRequest := func(path, body string) *http.Request {
+		request, err := http.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		Expect(err).NotTo(HaveOccurred())
+		return request
+	}
+
+	Describe("ReadIdempotentRequest", func() {
+		It("returns no key and the body when there is no Idempotency-Key header", func() {
+			idempotentRequest, body, err := handlers.ReadIdempotentRequest(newRequest("/octos", `{"name": "kraken"}`))
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(idempotentRequest.Key).To(BeEmpty())
+			Expect(ioutil.ReadAll(body)).To(MatchJSON(`{"name": "kraken"}`))
+		})
+
+		It("returns the key, a hash of the request and the body", func() {
+			request := newRequest("/octos", `{"name": "kraken"}`)
+			request.Header.Set("Idempotency-Key", "retry-me")
+
+			idempotentRequest, body, err := handlers.ReadIdempotentRequest(request)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(idempotentRequest.Key).To(Equal("retry-me"))
+			Expect(idempotentRequest.RequestHash).To(MatchRegexp("^[0-9a-f]{64}$"))
+			Expect(ioutil.ReadAll(body)).To(MatchJSON(`{"name": "kraken"}`))
+		})
+
+		It("hashes the path along with the body", func() {
+			hash := func(path string) string {
+				request := newRequest(path, `{}`)
+				request.Header.Set("Idempotency-Key", "retry-me")
+				idempotentRequest, _, err := handlers.ReadIdempotentRequest(request)
+				Expect(err).NotTo(HaveOccurred())
+				return idempotentRequest.RequestHash
+			}
+
+			Expect(hash("/octos/kraken/garbanzos")).To(Equal(hash("/octos/kraken/garbanzos")))
+			Expect(hash("/octos/kraken/garbanzos")).NotTo(Equal(hash("/octos/cthulhu/garbanzos")))
+		})
+
+		It("rejects overly long keys", func() {
+			request := newRequest("/octos", `{}`)
+			request.Header.Set("Idempotency-Key", strings.Repeat("k", 256))
+
+			_, _, err := handlers.ReadIdempotentRequest(request)
+			Expect(err).To(Equal(handlers.ErrInvalidIdempotencyKey))
+		})
+	})
+
+	Describe("RespondStored", func() {
+		var (
+			recorder *httptest.ResponseRecorder
+			stored   []byte
+		)
+
+		BeforeEach(func() {
+			recorder = httptest.NewRecorder()
+			stored = handlers.StoredResponse(http.StatusCreated, map[string]string{"name": "kraken"}, 7, 1)
+		})
+
+		It("sends the stored response", func() {
+			handlers.RespondStored(recorder, stored, false)
+
+			Expect(recorder.Code).To(Equal(http.StatusCreated))
+			Expect(recorder.Header().Get("ETag")).To(Equal(`"7-1"`))
+			Expect(recorder.Header().Get("Idempotent-Replayed")).To(BeEmpty())
+			Expect(recorder.Body).To(MatchJSON(`{"name": "kraken"}`))
+		})
+
+		It("marks replays", func() {
+			handlers.RespondStored(recorder, stored, true)
+
+			Expect(recorder.Header().Get("Idempotent-Replayed")).To(Equal("true"))
+		})
+	})
+})
@@ -13,11 +13,15 @@ func Respond(w http.ResponseWriter, code int, v interface{}) {
 	w.WriteHeader(code)
 
 	if v != nil {
-		bytes, err := json.Marshal(v)
-		if err != nil {
-			logs.Logger.Panic("Unexpected JSON marshal err: ", err)
-		}
+		w.Write(marshal(v))
+	}
+}
 
-		w.Write(bytes)
+func marshal(v interface{}) []byte {
+	bytes, err := json.Marshal(v)
+	if err != nil {
+		logs.Logger.Panic("Unexpected JSON marshal err: ", err)
 	}
+
+	return bytes
 }
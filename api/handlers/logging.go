@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"context"
 	"net/http"
 
 	"github.com/gorilla/handlers"
@@ -8,14 +9,21 @@ import (
 	"github.com/myshkin5/effective-octo-garbanzo/logs"
 )
 
-type loggingWriter struct{}
+type loggingWriter struct {
+	ctx context.Context
+}
 
+// LoggingHandler logs each request in the Apache Common Log Format with the
+// fields of the request context so access logs can be correlated with the
+// rest of the request's log lines.
 func LoggingHandler(handler http.Handler) http.Handler {
-	return handlers.LoggingHandler(loggingWriter{}, handler)
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		handlers.LoggingHandler(loggingWriter{ctx: req.Context()}, handler).ServeHTTP(w, req)
+	})
 }
 
 func (w loggingWriter) Write(p []byte) (int, error) {
 	n := len(p)
-	logs.Logger.Info(string(p[:n-1]))
+	logs.FromContext(w.ctx).Info(string(p[:n-1]))
 	return n, nil
 }
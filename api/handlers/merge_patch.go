@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"reflect"
+)
+
+// MergePatch applies a JSON Merge Patch (RFC 7396) read from patch to the
+// JSON representation of target. Target must be a pointer to a value which
+// marshals to a JSON object. Members of the patch with a null value are
+// removed, which leaves the corresponding field of target with its zero value.
+func MergePatch(target interface{}, patch io.Reader) error {
+	var patchDoc interface{}
+	err := json.NewDecoder(patch).Decode(&patchDoc)
+	if err != nil {
+		return err
+	}
+
+	bytes, err := json.Marshal(target)
+	if err != nil {
+		return err
+	}
+
+	var targetDoc interface{}
+	err = json.Unmarshal(bytes, &targetDoc)
+	if err != nil {
+		return err
+	}
+
+	bytes, err = json.Marshal(mergePatch(targetDoc, patchDoc))
+	if err != nil {
+		return err
+	}
+
+	// Unmarshalling only sets fields that are present so start from scratch
+	value := reflect.ValueOf(target).Elem()
+	value.Set(reflect.Zero(value.Type()))
+
+	return json.Unmarshal(bytes, target)
+}
+
+func mergePatch(target, patch interface{}) interface{} {
+	patchObj, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	targetObj, ok := target.(map[string]interface{})
+	if !ok {
+		targetObj = map[string]interface{}{}
+	}
+
+	for key, value := range patchObj {
+		if value == nil {
+			delete(targetObj, key)
+		} else {
+			targetObj[key] = mergePatch(targetObj[key], value)
+		}
+	}
+
+	return targetObj
+}
@@ -0,0 +1,73 @@
+package handlers_test
+
+import (
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/myshkin5/effective-octo-garbanzo/api/handlers"
+)
+
+var _ = Describe("MergePatch", func() {
+	type inner struct {
+		A string `json:"a,omitempty"`
+		B string `json:"b,omitempty"`
+	}
+	type doc struct {
+		Name   string  `json:"name"`
+		Size   float32 `json:"size"`
+		Nested inner   `json:"nested"`
+	}
+
+	var target doc
+
+	BeforeEach(func() {
+		target = doc{
+			Name: "kraken",
+			Size: 4.2,
+			Nested: inner{
+				A: "a",
+				B: "b",
+			},
+		}
+	})
+
+	It("replaces members present in the patch and keeps the rest", func() {
+		err := handlers.MergePatch(&target, strings.NewReader(`{"size": 5.5}`))
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(target).To(Equal(doc{
+			Name: "kraken",
+			Size: 5.5,
+			Nested: inner{
+				A: "a",
+				B: "b",
+			},
+		}))
+	})
+
+	It("removes members with a null value", func() {
+		err := handlers.MergePatch(&target, strings.NewReader(`{"name": null}`))
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(target.Name).To(BeEmpty())
+		Expect(target.Size).To(Equal(float32(4.2)))
+	})
+
+	It("merges nested objects recursively", func() {
+		err := handlers.MergePatch(&target, strings.NewReader(`{"nested": {"a": "z", "b": null}}`))
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(target.Nested).To(Equal(inner{
+			A: "z",
+		}))
+	})
+
+	It("returns an error when the patch is not valid JSON", func() {
+		err := handlers.MergePatch(&target, strings.NewReader(`not json`))
+		Expect(err).To(HaveOccurred())
+
+		Expect(target.Name).To(Equal("kraken"))
+	})
+})
@@ -37,7 +37,7 @@ var _ = Describe("Health", func() {
 		recorder.Code = 0
 
 		router = mux.NewRouter()
-		handlers.MapHealthRoutes(router, alice.Chain{})
+		handlers.MapHealthRoutes(router, alice.Chain{}, alice.Chain{}, &handlers.Health{})
 	})
 
 	Describe("happy path", func() {
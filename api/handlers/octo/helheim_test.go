@@ -9,16 +9,19 @@ import (
 	"context"
 	"time"
 
+	"github.com/myshkin5/effective-octo-garbanzo/persistence"
 	"github.com/myshkin5/effective-octo-garbanzo/persistence/data"
 )
 
 type mockOctoService struct {
 	FetchAllCalled chan bool
 	FetchAllInput  struct {
-		Ctx chan context.Context
+		Ctx  chan context.Context
+		Page chan persistence.Page
 	}
 	FetchAllOutput struct {
 		Octos chan []data.Octo
+		More  chan bool
 		Err   chan error
 	}
 	FetchByNameCalled chan bool
@@ -39,10 +42,34 @@ type mockOctoService struct {
 		OctoOut chan data.Octo
 		Err     chan error
 	}
+	CreateIdempotentlyCalled chan bool
+	CreateIdempotentlyInput  struct {
+		Ctx     chan context.Context
+		OctoIn  chan data.Octo
+		Request chan data.IdempotentRequest
+		Respond chan func(octo data.Octo) []byte
+	}
+	CreateIdempotentlyOutput struct {
+		Response chan []byte
+		Replayed chan bool
+		Err      chan error
+	}
+	UpdateCalled chan bool
+	UpdateInput  struct {
+		Ctx    chan context.Context
+		Name   chan string
+		OctoIn chan data.Octo
+	}
+	UpdateOutput struct {
+		OctoOut chan data.Octo
+		Err     chan error
+	}
 	DeleteByNameCalled chan bool
 	DeleteByNameInput  struct {
-		Ctx  chan context.Context
-		Name chan string
+		Ctx     chan context.Context
+		Name    chan string
+		Id      chan int
+		Version chan int
 	}
 	DeleteByNameOutput struct {
 		Err chan error
@@ -53,7 +80,9 @@ func newMockOctoService() *mockOctoService {
 	m := &mockOctoService{}
 	m.FetchAllCalled = make(chan bool, 100)
 	m.FetchAllInput.Ctx = make(chan context.Context, 100)
+	m.FetchAllInput.Page = make(chan persistence.Page, 100)
 	m.FetchAllOutput.Octos = make(chan []data.Octo, 100)
+	m.FetchAllOutput.More = make(chan bool, 100)
 	m.FetchAllOutput.Err = make(chan error, 100)
 	m.FetchByNameCalled = make(chan bool, 100)
 	m.FetchByNameInput.Ctx = make(chan context.Context, 100)
@@ -65,16 +94,33 @@ func newMockOctoService() *mockOctoService {
 	m.CreateInput.OctoIn = make(chan data.Octo, 100)
 	m.CreateOutput.OctoOut = make(chan data.Octo, 100)
 	m.CreateOutput.Err = make(chan error, 100)
+	m.CreateIdempotentlyCalled = make(chan bool, 100)
+	m.CreateIdempotentlyInput.Ctx = make(chan context.Context, 100)
+	m.CreateIdempotentlyInput.OctoIn = make(chan data.Octo, 100)
+	m.CreateIdempotentlyInput.Request = make(chan data.IdempotentRequest, 100)
+	m.CreateIdempotentlyInput.Respond = make(chan func(octo data.Octo) []byte, 100)
+	m.CreateIdempotentlyOutput.Response = make(chan []byte, 100)
+	m.CreateIdempotentlyOutput.Replayed = make(chan bool, 100)
+	m.CreateIdempotentlyOutput.Err = make(chan error, 100)
+	m.UpdateCalled = make(chan bool, 100)
+	m.UpdateInput.Ctx = make(chan context.Context, 100)
+	m.UpdateInput.Name = make(chan string, 100)
+	m.UpdateInput.OctoIn = make(chan data.Octo, 100)
+	m.UpdateOutput.OctoOut = make(chan data.Octo, 100)
+	m.UpdateOutput.Err = make(chan error, 100)
 	m.DeleteByNameCalled = make(chan bool, 100)
 	m.DeleteByNameInput.Ctx = make(chan context.Context, 100)
 	m.DeleteByNameInput.Name = make(chan string, 100)
+	m.DeleteByNameInput.Id = make(chan int, 100)
+	m.DeleteByNameInput.Version = make(chan int, 100)
 	m.DeleteByNameOutput.Err = make(chan error, 100)
 	return m
 }
-func (m *mockOctoService) FetchAll(ctx context.Context) (octos []data.Octo, err error) {
+func (m *mockOctoService) FetchAll(ctx context.Context, page persistence.Page) (octos []data.Octo, more bool, err error) {
 	m.FetchAllCalled <- true
 	m.FetchAllInput.Ctx <- ctx
-	return <-m.FetchAllOutput.Octos, <-m.FetchAllOutput.Err
+	m.FetchAllInput.Page <- page
+	return <-m.FetchAllOutput.Octos, <-m.FetchAllOutput.More, <-m.FetchAllOutput.Err
 }
 func (m *mockOctoService) FetchByName(ctx context.Context, name string) (octo data.Octo, err error) {
 	m.FetchByNameCalled <- true
@@ -88,10 +134,27 @@ func (m *mockOctoService) Create(ctx context.Context, octoIn data.Octo) (octoOut
 	m.CreateInput.OctoIn <- octoIn
 	return <-m.CreateOutput.OctoOut, <-m.CreateOutput.Err
 }
-func (m *mockOctoService) DeleteByName(ctx context.Context, name string) (err error) {
+func (m *mockOctoService) CreateIdempotently(ctx context.Context, octoIn data.Octo, request data.IdempotentRequest, respond func(octo data.Octo) []byte) (response []byte, replayed bool, err error) {
+	m.CreateIdempotentlyCalled <- true
+	m.CreateIdempotentlyInput.Ctx <- ctx
+	m.CreateIdempotentlyInput.OctoIn <- octoIn
+	m.CreateIdempotentlyInput.Request <- request
+	m.CreateIdempotentlyInput.Respond <- respond
+	return <-m.CreateIdempotentlyOutput.Response, <-m.CreateIdempotentlyOutput.Replayed, <-m.CreateIdempotentlyOutput.Err
+}
+func (m *mockOctoService) Update(ctx context.Context, name string, octoIn data.Octo) (octoOut data.Octo, err error) {
+	m.UpdateCalled <- true
+	m.UpdateInput.Ctx <- ctx
+	m.UpdateInput.Name <- name
+	m.UpdateInput.OctoIn <- octoIn
+	return <-m.UpdateOutput.OctoOut, <-m.UpdateOutput.Err
+}
+func (m *mockOctoService) DeleteByName(ctx context.Context, name string, id int, version int) (err error) {
 	m.DeleteByNameCalled <- true
 	m.DeleteByNameInput.Ctx <- ctx
 	m.DeleteByNameInput.Name <- name
+	m.DeleteByNameInput.Id <- id
+	m.DeleteByNameInput.Version <- version
 	return <-m.DeleteByNameOutput.Err
 }
 
@@ -2,6 +2,7 @@ package octo
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
 
@@ -26,10 +27,12 @@ var fieldMapping = map[string]string{
 }
 
 type OctoService interface {
-	FetchAll(ctx context.Context) (octos []data.Octo, err error)
+	FetchAll(ctx context.Context, page persistence.Page) (octos []data.Octo, more bool, err error)
 	FetchByName(ctx context.Context, name string) (octo data.Octo, err error)
 	Create(ctx context.Context, octoIn data.Octo) (octoOut data.Octo, err error)
-	DeleteByName(ctx context.Context, name string) (err error)
+	CreateIdempotently(ctx context.Context, octoIn data.Octo, request data.IdempotentRequest, respond func(octo data.Octo) []byte) (response []byte, replayed bool, err error)
+	Update(ctx context.Context, name string, octoIn data.Octo) (octoOut data.Octo, err error)
+	DeleteByName(ctx context.Context, name string, id, version int) (err error)
 }
 
 type octo struct {
@@ -37,14 +40,16 @@ type octo struct {
 	baseURL     string
 }
 
-func MapRoutes(baseURL string, router *mux.Router, middleware alice.Chain, octoService OctoService) {
+func MapRoutes(baseURL string, router *mux.Router, middleware alice.Chain, authorize handlers.Authorizer, requireIfMatch alice.Constructor, octoService OctoService) {
 	handler := &octo{
 		octoService: octoService,
 		baseURL:     baseURL + "octos/",
 	}
 	methodHandler := make(handlers.MethodHandler)
-	methodHandler[http.MethodGet] = http.HandlerFunc(handler.get)
-	methodHandler[http.MethodDelete] = http.HandlerFunc(handler.delete)
+	methodHandler[http.MethodGet] = authorize(handlers.ScopeOctosRead)(http.HandlerFunc(handler.get))
+	methodHandler[http.MethodPut] = authorize(handlers.ScopeOctosWrite)(requireIfMatch(http.HandlerFunc(handler.put)))
+	methodHandler[http.MethodPatch] = authorize(handlers.ScopeOctosWrite)(requireIfMatch(http.HandlerFunc(handler.patch)))
+	methodHandler[http.MethodDelete] = authorize(handlers.ScopeOctosDelete)(requireIfMatch(http.HandlerFunc(handler.delete)))
 	router.Handle("/octos/{name}", middleware.Then(methodHandler))
 }
 
@@ -54,26 +59,110 @@ func (g *octo) get(w http.ResponseWriter, req *http.Request) {
 
 	octo, err := g.octoService.FetchByName(req.Context(), name)
 	if err == persistence.ErrNotFound {
-		handlers.Error(w, fmt.Sprintf("Octo %s not found", name), http.StatusNotFound, err, fieldMapping)
+		handlers.Error(req.Context(), w, fmt.Sprintf("Octo %s not found", name), http.StatusNotFound, err, fieldMapping)
 		return
 	} else if err != nil {
-		handlers.Error(w, "Error fetching octo", http.StatusInternalServerError, err, fieldMapping)
+		handlers.Error(req.Context(), w, "Error fetching octo", http.StatusInternalServerError, err, fieldMapping)
 		return
 	}
 
-	handlers.Respond(w, http.StatusOK, fromPersistence(octo, g.baseURL))
+	handlers.RespondWithVersion(w, req, http.StatusOK, fromPersistence(octo, g.baseURL), octo.Id, octo.Version)
+}
+
+func (g *octo) put(w http.ResponseWriter, req *http.Request) {
+	id, version, err := handlers.IfMatchVersion(req)
+	if err != nil {
+		handlers.Error(req.Context(), w, fmt.Sprintf("Octo %s has been modified", mux.Vars(req)["name"]), http.StatusPreconditionFailed, err, fieldMapping)
+		return
+	}
+
+	var dto Octo
+	err = json.NewDecoder(req.Body).Decode(&dto)
+	if err != nil {
+		handlers.Error(req.Context(), w, handlers.InvalidJSON, http.StatusBadRequest, err, fieldMapping)
+		return
+	}
+
+	g.update(w, req, dto, id, version)
+}
+
+func (g *octo) patch(w http.ResponseWriter, req *http.Request) {
+	name := mux.Vars(req)["name"]
+
+	id, version, err := handlers.IfMatchVersion(req)
+	if err != nil {
+		handlers.Error(req.Context(), w, fmt.Sprintf("Octo %s has been modified", name), http.StatusPreconditionFailed, err, fieldMapping)
+		return
+	}
+
+	octo, err := g.octoService.FetchByName(req.Context(), name)
+	if err == persistence.ErrNotFound {
+		handlers.Error(req.Context(), w, fmt.Sprintf("Octo %s not found", name), http.StatusNotFound, err, fieldMapping)
+		return
+	} else if err != nil {
+		handlers.Error(req.Context(), w, "Error fetching octo", http.StatusInternalServerError, err, fieldMapping)
+		return
+	}
+
+	dto := fromPersistence(octo, g.baseURL)
+	err = handlers.MergePatch(&dto, req.Body)
+	if err != nil {
+		handlers.Error(req.Context(), w, handlers.InvalidJSON, http.StatusBadRequest, err, fieldMapping)
+		return
+	}
+
+	// The patch was merged onto the fetched version so only update that
+	// version, otherwise a concurrent update would be silently overwritten
+	if version == 0 {
+		id, version = octo.Id, octo.Version
+	}
+	g.update(w, req, dto, id, version)
+}
+
+func (g *octo) update(w http.ResponseWriter, req *http.Request, dto Octo, id, version int) {
+	name := mux.Vars(req)["name"]
+
+	octo, err := g.octoService.Update(req.Context(), name, data.Octo{
+		Id:      id,
+		Name:    dto.Name,
+		Version: version,
+	})
+	if err == persistence.ErrNotFound {
+		handlers.Error(req.Context(), w, fmt.Sprintf("Octo %s not found", name), http.StatusNotFound, err, fieldMapping)
+		return
+	} else if err == persistence.ErrDuplicate {
+		handlers.Error(req.Context(), w, fmt.Sprintf("Octo %s already exists", dto.Name), http.StatusConflict, err, fieldMapping)
+		return
+	} else if err == persistence.ErrVersionMismatch {
+		handlers.Error(req.Context(), w, fmt.Sprintf("Octo %s has been modified", name), http.StatusPreconditionFailed, err, fieldMapping)
+		return
+	} else if err != nil {
+		handlers.Error(req.Context(), w, "Error updating octo", http.StatusInternalServerError, err, fieldMapping)
+		return
+	}
+
+	handlers.RespondWithVersion(w, req, http.StatusOK, fromPersistence(octo, g.baseURL), octo.Id, octo.Version)
 }
 
 func (g *octo) delete(w http.ResponseWriter, req *http.Request) {
 	vars := mux.Vars(req)
 	name := vars["name"]
 
-	err := g.octoService.DeleteByName(req.Context(), name)
+	id, version, err := handlers.IfMatchVersion(req)
+	if err != nil {
+		handlers.Error(req.Context(), w, fmt.Sprintf("Octo %s has been modified", name), http.StatusPreconditionFailed, err, fieldMapping)
+		return
+	}
+
+	err = g.octoService.DeleteByName(req.Context(), name, id, version)
 	if err == persistence.ErrNotFound {
-		handlers.Error(w, fmt.Sprintf("Octo %s not found", name), http.StatusNotFound, err, fieldMapping)
+		handlers.Error(req.Context(), w, fmt.Sprintf("Octo %s not found", name), http.StatusNotFound, err, fieldMapping)
+		return
+	} else if err == persistence.ErrVersionMismatch {
+		handlers.Error(req.Context(), w, fmt.Sprintf("Octo %s has been modified", name), http.StatusPreconditionFailed, err, fieldMapping)
 		return
 	} else if err != nil {
-		handlers.Error(w, "Error fetching octo", http.StatusInternalServerError, err, fieldMapping)
+		handlers.Error(req.Context(), w, "Error fetching octo", http.StatusInternalServerError, err, fieldMapping)
 		return
 	}
 
@@ -2,62 +2,116 @@ package octo
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"strings"
 
 	"github.com/gorilla/mux"
 	"github.com/justinas/alice"
 
 	"github.com/myshkin5/effective-octo-garbanzo/api/handlers"
+	"github.com/myshkin5/effective-octo-garbanzo/persistence"
 	"github.com/myshkin5/effective-octo-garbanzo/persistence/data"
+	"github.com/myshkin5/effective-octo-garbanzo/services"
 )
 
+type OctoList struct {
+	Link  string `json:"link"`
+	Next  string `json:"next,omitempty"`
+	Prev  string `json:"prev,omitempty"`
+	Octos []Octo `json:"octos"`
+}
+
 type octoCollection struct {
 	octoService OctoService
 	baseURL     string
 }
 
-func MapCollectionRoutes(baseURL string, router *mux.Router, middleware alice.Chain, octoService OctoService) {
+func MapCollectionRoutes(baseURL string, router *mux.Router, middleware alice.Chain, authorize handlers.Authorizer, octoService OctoService) {
 	handler := &octoCollection{
 		octoService: octoService,
 		baseURL:     baseURL + "octos/",
 	}
 	methodHandler := make(handlers.MethodHandler)
-	methodHandler[http.MethodGet] = http.HandlerFunc(handler.get)
-	methodHandler[http.MethodPost] = http.HandlerFunc(handler.post)
+	methodHandler[http.MethodGet] = authorize(handlers.ScopeOctosRead)(http.HandlerFunc(handler.get))
+	methodHandler[http.MethodPost] = authorize(handlers.ScopeOctosWrite)(http.HandlerFunc(handler.post))
 	router.Handle("/octos", middleware.Then(methodHandler))
 }
 
 func (g *octoCollection) get(w http.ResponseWriter, req *http.Request) {
-	octos, err := g.octoService.FetchAll(req.Context())
+	query := req.URL.Query()
+	page, err := handlers.ParsePage(query)
+	if err != nil {
+		handlers.Error(req.Context(), w, "Invalid page", http.StatusBadRequest, err, fieldMapping)
+		return
+	}
+
+	octos, more, err := g.octoService.FetchAll(req.Context(), page)
 	if err != nil {
-		handlers.Error(w, "Error fetching all octos", http.StatusInternalServerError, err, fieldMapping)
+		handlers.Error(req.Context(), w, "Error fetching all octos", http.StatusInternalServerError, err, fieldMapping)
 		return
 	}
 
-	// Intentionally an empty slice so list is present in output even when empty
-	list := []Octo{}
+	collectionURL := strings.TrimSuffix(g.baseURL, "/")
+	list := OctoList{
+		Link: collectionURL,
+		// Intentionally an empty slice so list is present in output even when empty
+		Octos: []Octo{},
+	}
+	var keys []handlers.PageKey
 	for _, octo := range octos {
-		list = append(list, fromPersistence(octo, g.baseURL))
+		list.Octos = append(list.Octos, fromPersistence(octo, g.baseURL))
+		keys = append(keys, handlers.PageKey{Id: octo.Id})
 	}
+	list.Next, list.Prev = handlers.PageLinks(collectionURL, query, page, keys, more)
 
-	handlers.Respond(w, http.StatusOK, list)
+	handlers.RespondWithContentETag(w, req, http.StatusOK, list)
 }
 
 func (g *octoCollection) post(w http.ResponseWriter, req *http.Request) {
+	request, body, err := handlers.ReadIdempotentRequest(req)
+	if err != nil {
+		handlers.Error(req.Context(), w, handlers.InvalidIdempotencyKey, http.StatusBadRequest, err, fieldMapping)
+		return
+	}
+
 	var dto Octo
-	err := json.NewDecoder(req.Body).Decode(&dto)
+	err = json.NewDecoder(body).Decode(&dto)
 	if err != nil {
-		handlers.Error(w, handlers.InvalidJSON, http.StatusBadRequest, err, fieldMapping)
+		handlers.Error(req.Context(), w, handlers.InvalidJSON, http.StatusBadRequest, err, fieldMapping)
 		return
 	}
 
-	octo, err := g.octoService.Create(req.Context(), data.Octo{
+	octoIn := data.Octo{
 		Name: dto.Name,
-	})
-	if err != nil {
-		handlers.Error(w, "Error creating new octo", http.StatusInternalServerError, err, fieldMapping)
+	}
+	var octo data.Octo
+	var response []byte
+	var replayed bool
+	if request.Key == "" {
+		octo, err = g.octoService.Create(req.Context(), octoIn)
+	} else {
+		response, replayed, err = g.octoService.CreateIdempotently(req.Context(), octoIn, request, func(octo data.Octo) []byte {
+			return handlers.StoredResponse(http.StatusCreated, fromPersistence(octo, g.baseURL), octo.Id, octo.Version)
+		})
+	}
+	if err == persistence.ErrOrgNotFound {
+		handlers.Error(req.Context(), w, "Org has not been provisioned", http.StatusForbidden, err, fieldMapping)
+		return
+	} else if err == services.ErrIdempotencyKeyReused {
+		handlers.Error(req.Context(), w, fmt.Sprintf("Idempotency-Key %s was used for a different request", request.Key), http.StatusUnprocessableEntity, err, fieldMapping)
+		return
+	} else if err == services.ErrIdempotencyKeyInUse {
+		handlers.Error(req.Context(), w, fmt.Sprintf("A request with Idempotency-Key %s is already in progress", request.Key), http.StatusConflict, err, fieldMapping)
+		return
+	} else if err != nil {
+		handlers.Error(req.Context(), w, "Error creating new octo", http.StatusInternalServerError, err, fieldMapping)
 		return
 	}
 
-	handlers.Respond(w, http.StatusCreated, fromPersistence(octo, g.baseURL))
+	if request.Key != "" {
+		handlers.RespondStored(w, response, replayed)
+		return
+	}
+	handlers.RespondWithVersion(w, req, http.StatusCreated, fromPersistence(octo, g.baseURL), octo.Id, octo.Version)
 }
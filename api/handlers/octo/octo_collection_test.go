@@ -11,8 +11,11 @@ import (
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
+	"github.com/myshkin5/effective-octo-garbanzo/api/handlers"
 	"github.com/myshkin5/effective-octo-garbanzo/api/handlers/octo"
+	"github.com/myshkin5/effective-octo-garbanzo/persistence"
 	"github.com/myshkin5/effective-octo-garbanzo/persistence/data"
+	"github.com/myshkin5/effective-octo-garbanzo/services"
 )
 
 var _ = Describe("OctoCollection", func() {
@@ -21,6 +24,7 @@ var _ = Describe("OctoCollection", func() {
 		request     *http.Request
 		mockService *mockOctoService
 		router      *mux.Router
+		grantScopes bool
 	)
 
 	BeforeEach(func() {
@@ -29,8 +33,22 @@ var _ = Describe("OctoCollection", func() {
 
 		mockService = newMockOctoService()
 
+		grantScopes = true
+		authorize := func(scope string) alice.Constructor {
+			return func(h http.Handler) http.Handler {
+				return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					if !grantScopes {
+						w.Header().Set("X-Required-Scope", scope)
+						w.WriteHeader(http.StatusForbidden)
+						return
+					}
+					h.ServeHTTP(w, r)
+				})
+			}
+		}
+
 		router = mux.NewRouter()
-		octo.MapCollectionRoutes("http://here/", router, alice.Chain{}, mockService)
+		octo.MapCollectionRoutes("http://here/", router, alice.Chain{}, authorize, mockService)
 	})
 
 	Describe("GET", func() {
@@ -41,6 +59,7 @@ var _ = Describe("OctoCollection", func() {
 				Expect(err).NotTo(HaveOccurred())
 
 				mockService.FetchAllOutput.Octos <- []data.Octo{}
+				mockService.FetchAllOutput.More <- false
 				mockService.FetchAllOutput.Err <- nil
 
 				router.ServeHTTP(recorder, request)
@@ -50,8 +69,17 @@ var _ = Describe("OctoCollection", func() {
 				Expect(recorder.Code).To(Equal(http.StatusOK))
 			})
 
+			It("fetches the first page", func() {
+				Expect(mockService.FetchAllInput.Page).To(Receive(Equal(persistence.Page{
+					Limit: 100,
+				})))
+			})
+
 			It("returns an empty list in the body", func() {
-				Expect(recorder.Body).To(MatchJSON(`[]`))
+				Expect(recorder.Body).To(MatchJSON(`{
+					"link":  "http://here/octos",
+					"octos": []
+				}`))
 			})
 		})
 
@@ -63,12 +91,15 @@ var _ = Describe("OctoCollection", func() {
 
 				mockService.FetchAllOutput.Octos <- []data.Octo{
 					{
+						Id:   3,
 						Name: "kraken",
 					},
 					{
+						Id:   5,
 						Name: "cthulhu",
 					},
 				}
+				mockService.FetchAllOutput.More <- false
 				mockService.FetchAllOutput.Err <- nil
 
 				router.ServeHTTP(recorder, request)
@@ -79,18 +110,132 @@ var _ = Describe("OctoCollection", func() {
 			})
 
 			It("returns all octos in the body", func() {
-				Expect(recorder.Body).To(MatchJSON(`[
+				Expect(recorder.Body).To(MatchJSON(`{
+					"link":  "http://here/octos",
+					"octos": [
+						{
+							"link":      "http://here/octos/kraken",
+							"name":      "kraken",
+							"garbanzos": "http://here/octos/kraken/garbanzos"
+						},
+						{
+							"link":      "http://here/octos/cthulhu",
+							"name":      "cthulhu",
+							"garbanzos": "http://here/octos/cthulhu/garbanzos"
+						}
+					]
+				}`))
+			})
+
+			It("returns an ETag of the collection's content", func() {
+				Expect(recorder.Header().Get("ETag")).To(MatchRegexp(`^"[0-9a-f]{32}"$`))
+			})
+		})
+
+		Context("not modified", func() {
+			var etag string
+
+			BeforeEach(func() {
+				for n := 0; n < 2; n++ {
+					var err error
+					request, err = http.NewRequest(http.MethodGet, "/octos", nil)
+					Expect(err).NotTo(HaveOccurred())
+					request.Header.Set("If-None-Match", etag)
+
+					mockService.FetchAllOutput.Octos <- []data.Octo{{Id: 3, Name: "kraken"}}
+					mockService.FetchAllOutput.More <- false
+					mockService.FetchAllOutput.Err <- nil
+
+					recorder = httptest.NewRecorder()
+					router.ServeHTTP(recorder, request)
+					etag = recorder.Header().Get("ETag")
+				}
+			})
+
+			It("returns a not modified status code when the content hasn't changed", func() {
+				Expect(recorder.Code).To(Equal(http.StatusNotModified))
+				Expect(recorder.Body.Len()).To(BeZero())
+			})
+		})
+
+		Context("happy path - paging", func() {
+			BeforeEach(func() {
+				var err error
+				request, err = http.NewRequest(http.MethodGet, "/octos?limit=2&cursor=eyJhIjozfQ", nil)
+				Expect(err).NotTo(HaveOccurred())
+
+				mockService.FetchAllOutput.Octos <- []data.Octo{
 					{
-						"link":      "http://here/octos/kraken",
-						"name":      "kraken",
-						"garbanzos": "http://here/octos/kraken/garbanzos"
+						Id:   5,
+						Name: "kraken",
 					},
 					{
-						"link":      "http://here/octos/cthulhu",
-						"name":      "cthulhu",
-						"garbanzos": "http://here/octos/cthulhu/garbanzos"
-					}
-				]`))
+						Id:   8,
+						Name: "cthulhu",
+					},
+				}
+				mockService.FetchAllOutput.More <- true
+				mockService.FetchAllOutput.Err <- nil
+
+				router.ServeHTTP(recorder, request)
+			})
+
+			It("fetches the page identified by the cursor", func() {
+				Expect(mockService.FetchAllInput.Page).To(Receive(Equal(persistence.Page{
+					AfterId: 3,
+					Limit:   2,
+				})))
+			})
+
+			It("returns an ok status code", func() {
+				Expect(recorder.Code).To(Equal(http.StatusOK))
+			})
+
+			It("returns next and prev links in the body", func() {
+				Expect(recorder.Body).To(MatchJSON(`{
+					"link":  "http://here/octos",
+					"next":  "http://here/octos?cursor=eyJhIjo4fQ&limit=2",
+					"prev":  "http://here/octos?cursor=eyJiIjo1fQ&limit=2",
+					"octos": [
+						{
+							"link":      "http://here/octos/kraken",
+							"name":      "kraken",
+							"garbanzos": "http://here/octos/kraken/garbanzos"
+						},
+						{
+							"link":      "http://here/octos/cthulhu",
+							"name":      "cthulhu",
+							"garbanzos": "http://here/octos/cthulhu/garbanzos"
+						}
+					]
+				}`))
+			})
+		})
+
+		Context("invalid page", func() {
+			BeforeEach(func() {
+				var err error
+				request, err = http.NewRequest(http.MethodGet, "/octos?limit=0", nil)
+				Expect(err).NotTo(HaveOccurred())
+
+				router.ServeHTTP(recorder, request)
+			})
+
+			It("does not call the service", func() {
+				Expect(mockService.FetchAllCalled).NotTo(Receive())
+			})
+
+			It("returns a bad request status code", func() {
+				Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+			})
+
+			It("returns a JSON error", func() {
+				Expect(recorder.Body).To(MatchJSON(`{
+					"code": 400,
+					"error": "Invalid page",
+					"errors": ["limit must be an integer between 1 and 1000"],
+					"status": "Bad Request"
+				}`))
 			})
 		})
 
@@ -101,6 +246,7 @@ var _ = Describe("OctoCollection", func() {
 				Expect(err).NotTo(HaveOccurred())
 
 				mockService.FetchAllOutput.Octos <- nil
+				mockService.FetchAllOutput.More <- false
 				mockService.FetchAllOutput.Err <- errors.New("bad stuff")
 
 				router.ServeHTTP(recorder, request)
@@ -131,8 +277,9 @@ var _ = Describe("OctoCollection", func() {
 				Expect(err).NotTo(HaveOccurred())
 
 				mockService.CreateOutput.OctoOut <- data.Octo{
-					Id:   234,
-					Name: "kraken",
+					Id:      234,
+					Name:    "kraken",
+					Version: 1,
 				}
 				mockService.CreateOutput.Err <- nil
 
@@ -158,6 +305,113 @@ var _ = Describe("OctoCollection", func() {
 					"garbanzos": "http://here/octos/kraken/garbanzos"
 				}`))
 			})
+
+			It("returns the octo's id and version as its ETag", func() {
+				Expect(recorder.Header().Get("ETag")).To(Equal(`"234-1"`))
+			})
+		})
+
+		Context("idempotently", func() {
+			var stored []byte
+
+			BeforeEach(func() {
+				var err error
+				body := strings.NewReader(`{
+					"name": "kraken"
+				}`)
+				request, err = http.NewRequest(http.MethodPost, "/octos", body)
+				Expect(err).NotTo(HaveOccurred())
+				request.Header.Set("Idempotency-Key", "retry-me")
+
+				stored = handlers.StoredResponse(http.StatusCreated, octo.Octo{
+					Link:      "http://here/octos/kraken",
+					Name:      "kraken",
+					Garbanzos: "http://here/octos/kraken/garbanzos",
+				}, 234, 1)
+			})
+
+			It("creates the octo via the service with the key and a hash of the request", func() {
+				mockService.CreateIdempotentlyOutput.Response <- stored
+				mockService.CreateIdempotentlyOutput.Replayed <- false
+				mockService.CreateIdempotentlyOutput.Err <- nil
+
+				router.ServeHTTP(recorder, request)
+
+				Expect(mockService.CreateIdempotentlyInput.OctoIn).To(Receive(Equal(data.Octo{
+					Name: "kraken",
+				})))
+				var idempotentRequest data.IdempotentRequest
+				Expect(mockService.CreateIdempotentlyInput.Request).To(Receive(&idempotentRequest))
+				Expect(idempotentRequest.Key).To(Equal("retry-me"))
+				Expect(idempotentRequest.RequestHash).To(MatchRegexp("^[0-9a-f]{64}$"))
+				Expect(mockService.CreateCalled).To(BeEmpty())
+
+				Expect(recorder.Code).To(Equal(http.StatusCreated))
+				Expect(recorder.Header().Get("ETag")).To(Equal(`"234-1"`))
+				Expect(recorder.Header().Get("Idempotent-Replayed")).To(BeEmpty())
+				Expect(recorder.Body).To(MatchJSON(`{
+					"link":      "http://here/octos/kraken",
+					"name":      "kraken",
+					"garbanzos": "http://here/octos/kraken/garbanzos"
+				}`))
+			})
+
+			It("renders the response to record with the created octo", func() {
+				mockService.CreateIdempotentlyOutput.Response <- stored
+				mockService.CreateIdempotentlyOutput.Replayed <- false
+				mockService.CreateIdempotentlyOutput.Err <- nil
+
+				router.ServeHTTP(recorder, request)
+
+				var respond func(octo data.Octo) []byte
+				Expect(mockService.CreateIdempotentlyInput.Respond).To(Receive(&respond))
+				Expect(respond(data.Octo{Id: 234, Name: "kraken", Version: 1})).To(Equal(stored))
+			})
+
+			It("marks replayed responses", func() {
+				mockService.CreateIdempotentlyOutput.Response <- stored
+				mockService.CreateIdempotentlyOutput.Replayed <- true
+				mockService.CreateIdempotentlyOutput.Err <- nil
+
+				router.ServeHTTP(recorder, request)
+
+				Expect(recorder.Code).To(Equal(http.StatusCreated))
+				Expect(recorder.Header().Get("Idempotent-Replayed")).To(Equal("true"))
+			})
+
+			It("returns an unprocessable entity when the key was used for a different request", func() {
+				mockService.CreateIdempotentlyOutput.Response <- nil
+				mockService.CreateIdempotentlyOutput.Replayed <- false
+				mockService.CreateIdempotentlyOutput.Err <- services.ErrIdempotencyKeyReused
+
+				router.ServeHTTP(recorder, request)
+
+				Expect(recorder.Code).To(Equal(http.StatusUnprocessableEntity))
+				Expect(recorder.Body).To(MatchJSON(`{
+					"code": 422,
+					"error": "Idempotency-Key retry-me was used for a different request",
+					"status": "Unprocessable Entity"
+				}`))
+			})
+
+			It("returns a conflict when a request with the key is in progress", func() {
+				mockService.CreateIdempotentlyOutput.Response <- nil
+				mockService.CreateIdempotentlyOutput.Replayed <- false
+				mockService.CreateIdempotentlyOutput.Err <- services.ErrIdempotencyKeyInUse
+
+				router.ServeHTTP(recorder, request)
+
+				Expect(recorder.Code).To(Equal(http.StatusConflict))
+			})
+
+			It("returns a bad request for an invalid key", func() {
+				request.Header.Set("Idempotency-Key", "not\tprintable")
+
+				router.ServeHTTP(recorder, request)
+
+				Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+				Expect(mockService.CreateIdempotentlyCalled).To(BeEmpty())
+			})
 		})
 
 		Context("unhappy path", func() {
@@ -184,6 +438,34 @@ var _ = Describe("OctoCollection", func() {
 				})
 			})
 
+			Context("org not provisioned", func() {
+				BeforeEach(func() {
+					var err error
+					body := strings.NewReader(`{
+						"name": "kraken"
+					}`)
+					request, err = http.NewRequest(http.MethodPost, "/octos", body)
+					Expect(err).NotTo(HaveOccurred())
+
+					mockService.CreateOutput.OctoOut <- data.Octo{}
+					mockService.CreateOutput.Err <- persistence.ErrOrgNotFound
+
+					router.ServeHTTP(recorder, request)
+				})
+
+				It("returns a forbidden status code", func() {
+					Expect(recorder.Code).To(Equal(http.StatusForbidden))
+				})
+
+				It("returns a JSON error", func() {
+					Expect(recorder.Body).To(MatchJSON(`{
+						"code": 403,
+						"error": "Org has not been provisioned",
+						"status": "Forbidden"
+					}`))
+				})
+			})
+
 			Context("persistence error", func() {
 				BeforeEach(func() {
 					var err error
@@ -213,4 +495,24 @@ var _ = Describe("OctoCollection", func() {
 			})
 		})
 	})
+
+	Describe("scopes", func() {
+		It("requires a scope for each method", func() {
+			grantScopes = false
+			for _, route := range []struct{ method, path, scope string }{
+				{http.MethodGet, "/octos", "octos:read"},
+				{http.MethodPost, "/octos", "octos:write"},
+			} {
+				recorder = httptest.NewRecorder()
+				var err error
+				request, err = http.NewRequest(route.method, route.path, nil)
+				Expect(err).NotTo(HaveOccurred())
+
+				router.ServeHTTP(recorder, request)
+
+				Expect(recorder.Code).To(Equal(http.StatusForbidden), route.method)
+				Expect(recorder.Header().Get("X-Required-Scope")).To(Equal(route.scope), route.method)
+			}
+		})
+	})
 })
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 
 	"github.com/gorilla/mux"
 	"github.com/justinas/alice"
@@ -15,14 +16,17 @@ import (
 	"github.com/myshkin5/effective-octo-garbanzo/api/handlers/octo"
 	"github.com/myshkin5/effective-octo-garbanzo/persistence"
 	"github.com/myshkin5/effective-octo-garbanzo/persistence/data"
+	"github.com/myshkin5/effective-octo-garbanzo/services"
 )
 
 var _ = Describe("Octo", func() {
 	var (
-		recorder    *httptest.ResponseRecorder
-		request     *http.Request
-		mockService *mockOctoService
-		router      *mux.Router
+		recorder       *httptest.ResponseRecorder
+		request        *http.Request
+		mockService    *mockOctoService
+		router         *mux.Router
+		grantScopes    bool
+		requireIfMatch bool
 	)
 
 	BeforeEach(func() {
@@ -31,8 +35,33 @@ var _ = Describe("Octo", func() {
 
 		mockService = newMockOctoService()
 
+		grantScopes = true
+		authorize := func(scope string) alice.Constructor {
+			return func(h http.Handler) http.Handler {
+				return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					if !grantScopes {
+						w.Header().Set("X-Required-Scope", scope)
+						w.WriteHeader(http.StatusForbidden)
+						return
+					}
+					h.ServeHTTP(w, r)
+				})
+			}
+		}
+
+		requireIfMatch = false
+		ifMatchRequired := func(h http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if requireIfMatch {
+					w.WriteHeader(http.StatusPreconditionRequired)
+					return
+				}
+				h.ServeHTTP(w, r)
+			})
+		}
+
 		router = mux.NewRouter()
-		octo.MapRoutes("http://here/", router, alice.Chain{}, mockService)
+		octo.MapRoutes("http://here/", router, alice.Chain{}, authorize, ifMatchRequired, mockService)
 	})
 
 	Describe("GET", func() {
@@ -43,7 +72,9 @@ var _ = Describe("Octo", func() {
 				Expect(err).NotTo(HaveOccurred())
 
 				mockService.FetchByNameOutput.Octo <- data.Octo{
-					Name: "kraken",
+					Id:      234,
+					Name:    "kraken",
+					Version: 3,
 				}
 				mockService.FetchByNameOutput.Err <- nil
 
@@ -61,6 +92,37 @@ var _ = Describe("Octo", func() {
 					"garbanzos": "http://here/octos/kraken/garbanzos"
 				}`))
 			})
+
+			It("returns the octo's id and version as its ETag", func() {
+				Expect(recorder.Header().Get("ETag")).To(Equal(`"234-3"`))
+			})
+		})
+
+		Context("not modified", func() {
+			BeforeEach(func() {
+				var err error
+				request, err = http.NewRequest(http.MethodGet, "/octos/kraken", nil)
+				Expect(err).NotTo(HaveOccurred())
+				request.Header.Set("If-None-Match", `"234-2", "234-3"`)
+
+				mockService.FetchByNameOutput.Octo <- data.Octo{
+					Id:      234,
+					Name:    "kraken",
+					Version: 3,
+				}
+				mockService.FetchByNameOutput.Err <- nil
+
+				router.ServeHTTP(recorder, request)
+			})
+
+			It("returns a not modified status code", func() {
+				Expect(recorder.Code).To(Equal(http.StatusNotModified))
+				Expect(recorder.Header().Get("ETag")).To(Equal(`"234-3"`))
+			})
+
+			It("returns no content", func() {
+				Expect(recorder.Body.Len()).To(BeZero())
+			})
 		})
 
 		Context("unhappy path", func() {
@@ -116,18 +178,358 @@ var _ = Describe("Octo", func() {
 		})
 	})
 
+	Describe("PUT", func() {
+		Context("happy path", func() {
+			BeforeEach(func() {
+				var err error
+				body := strings.NewReader(`{
+					"name": "cthulhu"
+				}`)
+				request, err = http.NewRequest(http.MethodPut, "/octos/kraken", body)
+				Expect(err).NotTo(HaveOccurred())
+				request.Header.Set("If-Match", `"234-3"`)
+
+				mockService.UpdateOutput.OctoOut <- data.Octo{
+					Id:      234,
+					Name:    "cthulhu",
+					Version: 4,
+				}
+				mockService.UpdateOutput.Err <- nil
+
+				router.ServeHTTP(recorder, request)
+			})
+
+			It("updates the octo at the version of the If-Match header via the service", func() {
+				Expect(mockService.UpdateInput.Name).To(Receive(Equal("kraken")))
+				var octo data.Octo
+				Expect(mockService.UpdateInput.OctoIn).To(Receive(&octo))
+				Expect(octo).To(Equal(data.Octo{
+					Id:      234,
+					Name:    "cthulhu",
+					Version: 3,
+				}))
+			})
+
+			It("returns an ok status code", func() {
+				Expect(recorder.Code).To(Equal(http.StatusOK))
+			})
+
+			It("returns the octo's id and new version as its ETag", func() {
+				Expect(recorder.Header().Get("ETag")).To(Equal(`"234-4"`))
+			})
+
+			It("returns the updated octo with its new links in the body", func() {
+				Expect(recorder.Body).To(MatchJSON(`{
+					"link":      "http://here/octos/cthulhu",
+					"name":      "cthulhu",
+					"garbanzos": "http://here/octos/cthulhu/garbanzos"
+				}`))
+			})
+		})
+
+		Context("unhappy path", func() {
+			Context("invalid json", func() {
+				BeforeEach(func() {
+					var err error
+					body := strings.NewReader("not json")
+					request, err = http.NewRequest(http.MethodPut, "/octos/kraken", body)
+					Expect(err).NotTo(HaveOccurred())
+
+					router.ServeHTTP(recorder, request)
+				})
+
+				It("returns a bad request status code", func() {
+					Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+				})
+
+				It("does not call the service", func() {
+					Expect(mockService.UpdateCalled).To(BeEmpty())
+				})
+			})
+
+			Context("persistence error", func() {
+				BeforeEach(func() {
+					var err error
+					body := strings.NewReader(`{
+						"name": "cthulhu"
+					}`)
+					request, err = http.NewRequest(http.MethodPut, "/octos/kraken", body)
+					Expect(err).NotTo(HaveOccurred())
+
+					mockService.UpdateOutput.OctoOut <- data.Octo{}
+					mockService.UpdateOutput.Err <- errors.New("bad stuff")
+
+					router.ServeHTTP(recorder, request)
+				})
+
+				It("returns an internal server error status code", func() {
+					Expect(recorder.Code).To(Equal(http.StatusInternalServerError))
+				})
+
+				It("returns a JSON error", func() {
+					Expect(recorder.Body).To(MatchJSON(`{
+						"code": 500,
+						"error": "Error updating octo",
+						"status": "Internal Server Error"
+					}`))
+				})
+			})
+
+			Context("not found error", func() {
+				BeforeEach(func() {
+					var err error
+					body := strings.NewReader(`{
+						"name": "cthulhu"
+					}`)
+					request, err = http.NewRequest(http.MethodPut, "/octos/squidward", body)
+					Expect(err).NotTo(HaveOccurred())
+
+					mockService.UpdateOutput.OctoOut <- data.Octo{}
+					mockService.UpdateOutput.Err <- persistence.ErrNotFound
+
+					router.ServeHTTP(recorder, request)
+				})
+
+				It("returns a not found status code", func() {
+					Expect(recorder.Code).To(Equal(http.StatusNotFound))
+				})
+
+				It("returns a JSON error", func() {
+					Expect(recorder.Body).To(MatchJSON(`{
+						"code": 404,
+						"error": "Octo squidward not found",
+						"status": "Not Found"
+					}`))
+				})
+			})
+
+			Context("duplicate error", func() {
+				BeforeEach(func() {
+					var err error
+					body := strings.NewReader(`{
+						"name": "cthulhu"
+					}`)
+					request, err = http.NewRequest(http.MethodPut, "/octos/kraken", body)
+					Expect(err).NotTo(HaveOccurred())
+
+					mockService.UpdateOutput.OctoOut <- data.Octo{}
+					mockService.UpdateOutput.Err <- persistence.ErrDuplicate
+
+					router.ServeHTTP(recorder, request)
+				})
+
+				It("returns a conflict status code", func() {
+					Expect(recorder.Code).To(Equal(http.StatusConflict))
+				})
+
+				It("returns a JSON error", func() {
+					Expect(recorder.Body).To(MatchJSON(`{
+						"code": 409,
+						"error": "Octo cthulhu already exists",
+						"status": "Conflict"
+					}`))
+				})
+			})
+
+			Context("version mismatch", func() {
+				BeforeEach(func() {
+					var err error
+					body := strings.NewReader(`{
+						"name": "cthulhu"
+					}`)
+					request, err = http.NewRequest(http.MethodPut, "/octos/kraken", body)
+					Expect(err).NotTo(HaveOccurred())
+					request.Header.Set("If-Match", `"234-3"`)
+
+					mockService.UpdateOutput.OctoOut <- data.Octo{}
+					mockService.UpdateOutput.Err <- persistence.ErrVersionMismatch
+
+					router.ServeHTTP(recorder, request)
+				})
+
+				It("returns a precondition failed status code", func() {
+					Expect(recorder.Code).To(Equal(http.StatusPreconditionFailed))
+				})
+
+				It("returns a JSON error", func() {
+					Expect(recorder.Body).To(MatchJSON(`{
+						"code": 412,
+						"error": "Octo kraken has been modified",
+						"status": "Precondition Failed"
+					}`))
+				})
+			})
+
+			Context("If-Match header naming no version", func() {
+				BeforeEach(func() {
+					var err error
+					body := strings.NewReader(`{
+						"name": "cthulhu"
+					}`)
+					request, err = http.NewRequest(http.MethodPut, "/octos/kraken", body)
+					Expect(err).NotTo(HaveOccurred())
+					request.Header.Set("If-Match", `W/"234-3"`)
+
+					router.ServeHTTP(recorder, request)
+				})
+
+				It("returns a precondition failed status code", func() {
+					Expect(recorder.Code).To(Equal(http.StatusPreconditionFailed))
+				})
+
+				It("does not call the service", func() {
+					Expect(mockService.UpdateCalled).To(BeEmpty())
+				})
+			})
+		})
+	})
+
+	Describe("PATCH", func() {
+		BeforeEach(func() {
+			mockService.FetchByNameOutput.Octo <- data.Octo{
+				Id:      234,
+				Name:    "kraken",
+				Version: 3,
+			}
+			mockService.FetchByNameOutput.Err <- nil
+		})
+
+		It("renames the octo when the name is present", func() {
+			body := strings.NewReader(`{
+				"name": "cthulhu"
+			}`)
+			var err error
+			request, err = http.NewRequest(http.MethodPatch, "/octos/kraken", body)
+			Expect(err).NotTo(HaveOccurred())
+
+			mockService.UpdateOutput.OctoOut <- data.Octo{
+				Name: "cthulhu",
+			}
+			mockService.UpdateOutput.Err <- nil
+
+			router.ServeHTTP(recorder, request)
+
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(mockService.FetchByNameInput.Name).To(Receive(Equal("kraken")))
+			Expect(mockService.UpdateInput.Name).To(Receive(Equal("kraken")))
+			Expect(mockService.UpdateInput.OctoIn).To(Receive(Equal(data.Octo{
+				Id:      234,
+				Name:    "cthulhu",
+				Version: 3,
+			})))
+		})
+
+		It("keeps the current name when the name is absent", func() {
+			body := strings.NewReader(`{}`)
+			var err error
+			request, err = http.NewRequest(http.MethodPatch, "/octos/kraken", body)
+			Expect(err).NotTo(HaveOccurred())
+
+			mockService.UpdateOutput.OctoOut <- data.Octo{
+				Name: "kraken",
+			}
+			mockService.UpdateOutput.Err <- nil
+
+			router.ServeHTTP(recorder, request)
+
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(mockService.UpdateInput.OctoIn).To(Receive(Equal(data.Octo{
+				Id:      234,
+				Name:    "kraken",
+				Version: 3,
+			})))
+		})
+
+		It("removes the name when it is null", func() {
+			body := strings.NewReader(`{
+				"name": null
+			}`)
+			var err error
+			request, err = http.NewRequest(http.MethodPatch, "/octos/kraken", body)
+			Expect(err).NotTo(HaveOccurred())
+
+			mockService.UpdateOutput.OctoOut <- data.Octo{}
+			mockService.UpdateOutput.Err <- services.NewValidationError(map[string][]string{
+				"Name": {"must be present"},
+			})
+
+			router.ServeHTTP(recorder, request)
+
+			Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+			Expect(mockService.UpdateInput.OctoIn).To(Receive(Equal(data.Octo{
+				Id:      234,
+				Version: 3,
+			})))
+		})
+
+		It("updates the id and version of the If-Match header rather than those fetched", func() {
+			body := strings.NewReader(`{}`)
+			var err error
+			request, err = http.NewRequest(http.MethodPatch, "/octos/kraken", body)
+			Expect(err).NotTo(HaveOccurred())
+			request.Header.Set("If-Match", `"233-2"`)
+
+			mockService.UpdateOutput.OctoOut <- data.Octo{}
+			mockService.UpdateOutput.Err <- persistence.ErrVersionMismatch
+
+			router.ServeHTTP(recorder, request)
+
+			Expect(recorder.Code).To(Equal(http.StatusPreconditionFailed))
+			Expect(mockService.UpdateInput.OctoIn).To(Receive(Equal(data.Octo{
+				Id:      233,
+				Name:    "kraken",
+				Version: 2,
+			})))
+		})
+
+		It("returns a bad request status code for invalid json", func() {
+			body := strings.NewReader(`{`)
+			var err error
+			request, err = http.NewRequest(http.MethodPatch, "/octos/kraken", body)
+			Expect(err).NotTo(HaveOccurred())
+
+			router.ServeHTTP(recorder, request)
+
+			Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+			Expect(mockService.UpdateCalled).NotTo(Receive())
+		})
+	})
+
+	Describe("PATCH of a missing octo", func() {
+		It("returns a not found status code", func() {
+			var err error
+			request, err = http.NewRequest(http.MethodPatch, "/octos/kraken", strings.NewReader(`{}`))
+			Expect(err).NotTo(HaveOccurred())
+
+			mockService.FetchByNameOutput.Octo <- data.Octo{}
+			mockService.FetchByNameOutput.Err <- persistence.ErrNotFound
+
+			router.ServeHTTP(recorder, request)
+
+			Expect(recorder.Code).To(Equal(http.StatusNotFound))
+			Expect(mockService.UpdateCalled).NotTo(Receive())
+		})
+	})
+
 	Describe("DELETE", func() {
 		Context("happy path", func() {
 			BeforeEach(func() {
 				var err error
 				request, err = http.NewRequest("DELETE", "/octos/kraken", nil)
 				Expect(err).NotTo(HaveOccurred())
+				request.Header.Set("If-Match", `"234-5"`)
 
 				mockService.DeleteByNameOutput.Err <- nil
 
 				router.ServeHTTP(recorder, request)
 			})
 
+			It("deletes the octo at the id and version of the If-Match header via the service", func() {
+				Expect(mockService.DeleteByNameInput.Name).To(Receive(Equal("kraken")))
+				Expect(mockService.DeleteByNameInput.Id).To(Receive(Equal(234)))
+				Expect(mockService.DeleteByNameInput.Version).To(Receive(Equal(5)))
+			})
+
 			It("returns a no content status code", func() {
 				Expect(recorder.Code).To(Equal(http.StatusNoContent))
 			})
@@ -185,6 +587,63 @@ var _ = Describe("Octo", func() {
 					}`))
 				})
 			})
+
+			Context("version mismatch", func() {
+				BeforeEach(func() {
+					var err error
+					request, err = http.NewRequest(http.MethodDelete, "/octos/kraken", nil)
+					Expect(err).NotTo(HaveOccurred())
+					request.Header.Set("If-Match", `"234-5"`)
+
+					mockService.DeleteByNameOutput.Err <- persistence.ErrVersionMismatch
+
+					router.ServeHTTP(recorder, request)
+				})
+
+				It("returns a precondition failed status code", func() {
+					Expect(recorder.Code).To(Equal(http.StatusPreconditionFailed))
+				})
+			})
+		})
+	})
+
+	Describe("If-Match", func() {
+		It("is required for each update and delete when configured", func() {
+			requireIfMatch = true
+			for _, method := range []string{http.MethodPut, http.MethodPatch, http.MethodDelete} {
+				recorder = httptest.NewRecorder()
+				var err error
+				request, err = http.NewRequest(method, "/octos/kraken", nil)
+				Expect(err).NotTo(HaveOccurred())
+
+				router.ServeHTTP(recorder, request)
+
+				Expect(recorder.Code).To(Equal(http.StatusPreconditionRequired), method)
+			}
+			Expect(mockService.UpdateCalled).To(BeEmpty())
+			Expect(mockService.DeleteByNameCalled).To(BeEmpty())
+		})
+	})
+
+	Describe("scopes", func() {
+		It("requires a scope for each method", func() {
+			grantScopes = false
+			for _, route := range []struct{ method, path, scope string }{
+				{http.MethodGet, "/octos/kraken", "octos:read"},
+				{http.MethodPut, "/octos/kraken", "octos:write"},
+				{http.MethodPatch, "/octos/kraken", "octos:write"},
+				{http.MethodDelete, "/octos/kraken", "octos:delete"},
+			} {
+				recorder = httptest.NewRecorder()
+				var err error
+				request, err = http.NewRequest(route.method, route.path, nil)
+				Expect(err).NotTo(HaveOccurred())
+
+				router.ServeHTTP(recorder, request)
+
+				Expect(recorder.Code).To(Equal(http.StatusForbidden), route.method)
+				Expect(recorder.Header().Get("X-Required-Scope")).To(Equal(route.scope), route.method)
+			}
 		})
 	})
 })
@@ -0,0 +1,87 @@
+package org
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/justinas/alice"
+
+	"github.com/myshkin5/effective-octo-garbanzo/api/handlers"
+	"github.com/myshkin5/effective-octo-garbanzo/persistence"
+	"github.com/myshkin5/effective-octo-garbanzo/persistence/data"
+)
+
+type Org struct {
+	Link string `json:"link"`
+	Name string `json:"name"`
+}
+
+var fieldMapping = map[string]string{
+	"Link": "link",
+	"Name": "name",
+}
+
+type OrgService interface {
+	FetchAll(ctx context.Context) (orgs []data.Org, err error)
+	FetchByName(ctx context.Context, name string) (org data.Org, err error)
+	Create(ctx context.Context, orgIn data.Org) (orgOut data.Org, err error)
+	DeleteByName(ctx context.Context, name string) (err error)
+}
+
+type org struct {
+	orgService OrgService
+	baseURL    string
+}
+
+func MapRoutes(baseURL string, router *mux.Router, middleware alice.Chain, authorize handlers.Authorizer, orgService OrgService) {
+	handler := &org{
+		orgService: orgService,
+		baseURL:    baseURL + "orgs/",
+	}
+	methodHandler := make(handlers.MethodHandler)
+	methodHandler[http.MethodGet] = authorize(handlers.ScopeOrgsRead)(http.HandlerFunc(handler.get))
+	methodHandler[http.MethodDelete] = authorize(handlers.ScopeOrgsDelete)(http.HandlerFunc(handler.delete))
+	router.Handle("/orgs/{name}", middleware.Then(methodHandler))
+}
+
+func (g *org) get(w http.ResponseWriter, req *http.Request) {
+	name := mux.Vars(req)["name"]
+
+	org, err := g.orgService.FetchByName(req.Context(), name)
+	if err == persistence.ErrNotFound {
+		handlers.Error(req.Context(), w, fmt.Sprintf("Org %s not found", name), http.StatusNotFound, err, fieldMapping)
+		return
+	} else if err != nil {
+		handlers.Error(req.Context(), w, "Error fetching org", http.StatusInternalServerError, err, fieldMapping)
+		return
+	}
+
+	handlers.Respond(w, http.StatusOK, fromPersistence(org, g.baseURL))
+}
+
+func (g *org) delete(w http.ResponseWriter, req *http.Request) {
+	name := mux.Vars(req)["name"]
+
+	err := g.orgService.DeleteByName(req.Context(), name)
+	if err == persistence.ErrNotFound {
+		handlers.Error(req.Context(), w, fmt.Sprintf("Org %s not found", name), http.StatusNotFound, err, fieldMapping)
+		return
+	} else if err == persistence.ErrInUse {
+		handlers.Error(req.Context(), w, fmt.Sprintf("Org %s still has octos", name), http.StatusConflict, err, fieldMapping)
+		return
+	} else if err != nil {
+		handlers.Error(req.Context(), w, "Error deleting org", http.StatusInternalServerError, err, fieldMapping)
+		return
+	}
+
+	handlers.Respond(w, http.StatusNoContent, nil)
+}
+
+func fromPersistence(org data.Org, baseURL string) Org {
+	return Org{
+		Link: baseURL + org.Name,
+		Name: org.Name,
+	}
+}
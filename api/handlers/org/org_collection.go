@@ -0,0 +1,77 @@
+package org
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/justinas/alice"
+
+	"github.com/myshkin5/effective-octo-garbanzo/api/handlers"
+	"github.com/myshkin5/effective-octo-garbanzo/persistence"
+	"github.com/myshkin5/effective-octo-garbanzo/persistence/data"
+)
+
+type OrgList struct {
+	Link string `json:"link"`
+	Orgs []Org  `json:"orgs"`
+}
+
+type orgCollection struct {
+	orgService OrgService
+	baseURL    string
+}
+
+func MapCollectionRoutes(baseURL string, router *mux.Router, middleware alice.Chain, authorize handlers.Authorizer, orgService OrgService) {
+	handler := &orgCollection{
+		orgService: orgService,
+		baseURL:    baseURL + "orgs/",
+	}
+	methodHandler := make(handlers.MethodHandler)
+	methodHandler[http.MethodGet] = authorize(handlers.ScopeOrgsRead)(http.HandlerFunc(handler.get))
+	methodHandler[http.MethodPost] = authorize(handlers.ScopeOrgsWrite)(http.HandlerFunc(handler.post))
+	router.Handle("/orgs", middleware.Then(methodHandler))
+}
+
+func (g *orgCollection) get(w http.ResponseWriter, req *http.Request) {
+	orgs, err := g.orgService.FetchAll(req.Context())
+	if err != nil {
+		handlers.Error(req.Context(), w, "Error fetching all orgs", http.StatusInternalServerError, err, fieldMapping)
+		return
+	}
+
+	list := OrgList{
+		Link: strings.TrimSuffix(g.baseURL, "/"),
+		// Intentionally an empty slice so list is present in output even when empty
+		Orgs: []Org{},
+	}
+	for _, org := range orgs {
+		list.Orgs = append(list.Orgs, fromPersistence(org, g.baseURL))
+	}
+
+	handlers.Respond(w, http.StatusOK, list)
+}
+
+func (g *orgCollection) post(w http.ResponseWriter, req *http.Request) {
+	var dto Org
+	err := json.NewDecoder(req.Body).Decode(&dto)
+	if err != nil {
+		handlers.Error(req.Context(), w, handlers.InvalidJSON, http.StatusBadRequest, err, fieldMapping)
+		return
+	}
+
+	org, err := g.orgService.Create(req.Context(), data.Org{
+		Name: dto.Name,
+	})
+	if err == persistence.ErrDuplicate {
+		handlers.Error(req.Context(), w, fmt.Sprintf("Org %s already exists", dto.Name), http.StatusConflict, err, fieldMapping)
+		return
+	} else if err != nil {
+		handlers.Error(req.Context(), w, "Error creating new org", http.StatusInternalServerError, err, fieldMapping)
+		return
+	}
+
+	handlers.Respond(w, http.StatusCreated, fromPersistence(org, g.baseURL))
+}
@@ -0,0 +1,238 @@
+package org_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/justinas/alice"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/myshkin5/effective-octo-garbanzo/api/handlers/org"
+	"github.com/myshkin5/effective-octo-garbanzo/persistence"
+	"github.com/myshkin5/effective-octo-garbanzo/persistence/data"
+	"github.com/myshkin5/effective-octo-garbanzo/services"
+)
+
+var _ = Describe("OrgCollection", func() {
+	var (
+		recorder    *httptest.ResponseRecorder
+		request     *http.Request
+		mockService *mockOrgService
+		router      *mux.Router
+		grantScopes bool
+	)
+
+	BeforeEach(func() {
+		recorder = httptest.NewRecorder()
+		recorder.Code = 0
+
+		mockService = newMockOrgService()
+
+		grantScopes = true
+		authorize := func(scope string) alice.Constructor {
+			return func(h http.Handler) http.Handler {
+				return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					if !grantScopes {
+						w.Header().Set("X-Required-Scope", scope)
+						w.WriteHeader(http.StatusForbidden)
+						return
+					}
+					h.ServeHTTP(w, r)
+				})
+			}
+		}
+
+		router = mux.NewRouter()
+		org.MapCollectionRoutes("http://here/", router, alice.Chain{}, authorize, mockService)
+	})
+
+	Describe("GET", func() {
+		Context("happy path", func() {
+			BeforeEach(func() {
+				var err error
+				request, err = http.NewRequest(http.MethodGet, "/orgs", nil)
+				Expect(err).NotTo(HaveOccurred())
+
+				mockService.FetchAllOutput.Orgs <- []data.Org{
+					{
+						Id:   3,
+						Name: "org1",
+					},
+					{
+						Id:   4,
+						Name: "org2",
+					},
+				}
+				mockService.FetchAllOutput.Err <- nil
+
+				router.ServeHTTP(recorder, request)
+			})
+
+			It("returns an ok status code", func() {
+				Expect(recorder.Code).To(Equal(http.StatusOK))
+			})
+
+			It("returns all orgs in the body", func() {
+				Expect(recorder.Body).To(MatchJSON(`{
+					"link": "http://here/orgs",
+					"orgs": [
+						{
+							"link": "http://here/orgs/org1",
+							"name": "org1"
+						},
+						{
+							"link": "http://here/orgs/org2",
+							"name": "org2"
+						}
+					]
+				}`))
+			})
+		})
+
+		Context("unhappy path", func() {
+			BeforeEach(func() {
+				var err error
+				request, err = http.NewRequest(http.MethodGet, "/orgs", nil)
+				Expect(err).NotTo(HaveOccurred())
+
+				mockService.FetchAllOutput.Orgs <- nil
+				mockService.FetchAllOutput.Err <- errors.New("bad stuff")
+
+				router.ServeHTTP(recorder, request)
+			})
+
+			It("returns a JSON error", func() {
+				Expect(recorder.Code).To(Equal(http.StatusInternalServerError))
+				Expect(recorder.Body).To(MatchJSON(`{
+					"code": 500,
+					"error": "Error fetching all orgs",
+					"status": "Internal Server Error"
+				}`))
+			})
+		})
+	})
+
+	Describe("POST", func() {
+		Context("happy path", func() {
+			BeforeEach(func() {
+				var err error
+				request, err = http.NewRequest(http.MethodPost, "/orgs", strings.NewReader(`{
+					"name": "org1"
+				}`))
+				Expect(err).NotTo(HaveOccurred())
+
+				mockService.CreateOutput.OrgOut <- data.Org{
+					Id:   3,
+					Name: "org1",
+				}
+				mockService.CreateOutput.Err <- nil
+
+				router.ServeHTTP(recorder, request)
+			})
+
+			It("creates the org via the service", func() {
+				Expect(mockService.CreateInput.OrgIn).To(Receive(Equal(data.Org{
+					Name: "org1",
+				})))
+			})
+
+			It("returns a created status code", func() {
+				Expect(recorder.Code).To(Equal(http.StatusCreated))
+			})
+
+			It("returns the newly created org in the body", func() {
+				Expect(recorder.Body).To(MatchJSON(`{
+					"link": "http://here/orgs/org1",
+					"name": "org1"
+				}`))
+			})
+		})
+
+		Context("invalid json", func() {
+			BeforeEach(func() {
+				var err error
+				request, err = http.NewRequest(http.MethodPost, "/orgs", strings.NewReader("not json"))
+				Expect(err).NotTo(HaveOccurred())
+
+				router.ServeHTTP(recorder, request)
+			})
+
+			It("returns a bad request status code", func() {
+				Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+			})
+		})
+
+		Context("validation error", func() {
+			BeforeEach(func() {
+				var err error
+				request, err = http.NewRequest(http.MethodPost, "/orgs", strings.NewReader(`{}`))
+				Expect(err).NotTo(HaveOccurred())
+
+				mockService.CreateOutput.OrgOut <- data.Org{}
+				mockService.CreateOutput.Err <- services.NewValidationError(map[string][]string{
+					"Name": {"must be present"},
+				})
+
+				router.ServeHTTP(recorder, request)
+			})
+
+			It("returns a JSON error", func() {
+				Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+				Expect(recorder.Body).To(MatchJSON(`{
+					"code": 400,
+					"error": "Error creating new org",
+					"errors": ["name must be present"],
+					"status": "Bad Request"
+				}`))
+			})
+		})
+
+		Context("duplicate error", func() {
+			BeforeEach(func() {
+				var err error
+				request, err = http.NewRequest(http.MethodPost, "/orgs", strings.NewReader(`{
+					"name": "org1"
+				}`))
+				Expect(err).NotTo(HaveOccurred())
+
+				mockService.CreateOutput.OrgOut <- data.Org{}
+				mockService.CreateOutput.Err <- persistence.ErrDuplicate
+
+				router.ServeHTTP(recorder, request)
+			})
+
+			It("returns a JSON error", func() {
+				Expect(recorder.Code).To(Equal(http.StatusConflict))
+				Expect(recorder.Body).To(MatchJSON(`{
+					"code": 409,
+					"error": "Org org1 already exists",
+					"status": "Conflict"
+				}`))
+			})
+		})
+	})
+
+	Describe("scopes", func() {
+		It("requires a scope for each method", func() {
+			grantScopes = false
+			for _, route := range []struct{ method, path, scope string }{
+				{http.MethodGet, "/orgs", "orgs:read"},
+				{http.MethodPost, "/orgs", "orgs:write"},
+			} {
+				recorder = httptest.NewRecorder()
+				var err error
+				request, err = http.NewRequest(route.method, route.path, nil)
+				Expect(err).NotTo(HaveOccurred())
+
+				router.ServeHTTP(recorder, request)
+
+				Expect(recorder.Code).To(Equal(http.StatusForbidden), route.method)
+				Expect(recorder.Header().Get("X-Required-Scope")).To(Equal(route.scope), route.method)
+			}
+		})
+	})
+})
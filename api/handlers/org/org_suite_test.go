@@ -1,4 +1,4 @@
-package data_test
+package org_test
 
 import (
 	"testing"
@@ -7,7 +7,7 @@ import (
 	. "github.com/onsi/gomega"
 )
 
-func TestData(t *testing.T) {
+func TestOrg(t *testing.T) {
 	RegisterFailHandler(Fail)
-	RunSpecs(t, "Persistence - Data Suite")
+	RunSpecs(t, "API - Handlers - Org Suite")
 }
@@ -0,0 +1,208 @@
+package org_test
+
+//go:generate hel
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/gorilla/mux"
+	"github.com/justinas/alice"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/myshkin5/effective-octo-garbanzo/api/handlers/org"
+	"github.com/myshkin5/effective-octo-garbanzo/persistence"
+	"github.com/myshkin5/effective-octo-garbanzo/persistence/data"
+)
+
+var _ = Describe("Org", func() {
+	var (
+		recorder    *httptest.ResponseRecorder
+		request     *http.Request
+		mockService *mockOrgService
+		router      *mux.Router
+		grantScopes bool
+	)
+
+	BeforeEach(func() {
+		recorder = httptest.NewRecorder()
+		recorder.Code = 0
+
+		mockService = newMockOrgService()
+
+		grantScopes = true
+		authorize := func(scope string) alice.Constructor {
+			return func(h http.Handler) http.Handler {
+				return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					if !grantScopes {
+						w.Header().Set("X-Required-Scope", scope)
+						w.WriteHeader(http.StatusForbidden)
+						return
+					}
+					h.ServeHTTP(w, r)
+				})
+			}
+		}
+
+		router = mux.NewRouter()
+		org.MapRoutes("http://here/", router, alice.Chain{}, authorize, mockService)
+	})
+
+	Describe("GET", func() {
+		Context("happy path", func() {
+			BeforeEach(func() {
+				var err error
+				request, err = http.NewRequest(http.MethodGet, "/orgs/org1", nil)
+				Expect(err).NotTo(HaveOccurred())
+
+				mockService.FetchByNameOutput.Org <- data.Org{
+					Id:   3,
+					Name: "org1",
+				}
+				mockService.FetchByNameOutput.Err <- nil
+
+				router.ServeHTTP(recorder, request)
+			})
+
+			It("fetches the org via the service", func() {
+				Expect(mockService.FetchByNameInput.Name).To(Receive(Equal("org1")))
+			})
+
+			It("returns an ok status code", func() {
+				Expect(recorder.Code).To(Equal(http.StatusOK))
+			})
+
+			It("returns the org in the body", func() {
+				Expect(recorder.Body).To(MatchJSON(`{
+					"link": "http://here/orgs/org1",
+					"name": "org1"
+				}`))
+			})
+		})
+
+		Context("not found error", func() {
+			BeforeEach(func() {
+				var err error
+				request, err = http.NewRequest(http.MethodGet, "/orgs/org2", nil)
+				Expect(err).NotTo(HaveOccurred())
+
+				mockService.FetchByNameOutput.Org <- data.Org{}
+				mockService.FetchByNameOutput.Err <- persistence.ErrNotFound
+
+				router.ServeHTTP(recorder, request)
+			})
+
+			It("returns a JSON error", func() {
+				Expect(recorder.Code).To(Equal(http.StatusNotFound))
+				Expect(recorder.Body).To(MatchJSON(`{
+					"code": 404,
+					"error": "Org org2 not found",
+					"status": "Not Found"
+				}`))
+			})
+		})
+	})
+
+	Describe("DELETE", func() {
+		Context("happy path", func() {
+			BeforeEach(func() {
+				var err error
+				request, err = http.NewRequest(http.MethodDelete, "/orgs/org1", nil)
+				Expect(err).NotTo(HaveOccurred())
+
+				mockService.DeleteByNameOutput.Err <- nil
+
+				router.ServeHTTP(recorder, request)
+			})
+
+			It("deletes the org via the service", func() {
+				Expect(mockService.DeleteByNameInput.Name).To(Receive(Equal("org1")))
+			})
+
+			It("returns a no content status code", func() {
+				Expect(recorder.Code).To(Equal(http.StatusNoContent))
+				Expect(recorder.Body.Len()).To(BeZero())
+			})
+		})
+
+		Context("in use error", func() {
+			BeforeEach(func() {
+				var err error
+				request, err = http.NewRequest(http.MethodDelete, "/orgs/org1", nil)
+				Expect(err).NotTo(HaveOccurred())
+
+				mockService.DeleteByNameOutput.Err <- persistence.ErrInUse
+
+				router.ServeHTTP(recorder, request)
+			})
+
+			It("returns a JSON error", func() {
+				Expect(recorder.Code).To(Equal(http.StatusConflict))
+				Expect(recorder.Body).To(MatchJSON(`{
+					"code": 409,
+					"error": "Org org1 still has octos",
+					"status": "Conflict"
+				}`))
+			})
+		})
+
+		Context("not found error", func() {
+			BeforeEach(func() {
+				var err error
+				request, err = http.NewRequest(http.MethodDelete, "/orgs/org2", nil)
+				Expect(err).NotTo(HaveOccurred())
+
+				mockService.DeleteByNameOutput.Err <- persistence.ErrNotFound
+
+				router.ServeHTTP(recorder, request)
+			})
+
+			It("returns a not found status code", func() {
+				Expect(recorder.Code).To(Equal(http.StatusNotFound))
+			})
+		})
+
+		Context("persistence error", func() {
+			BeforeEach(func() {
+				var err error
+				request, err = http.NewRequest(http.MethodDelete, "/orgs/org1", nil)
+				Expect(err).NotTo(HaveOccurred())
+
+				mockService.DeleteByNameOutput.Err <- errors.New("bad stuff")
+
+				router.ServeHTTP(recorder, request)
+			})
+
+			It("returns a JSON error", func() {
+				Expect(recorder.Code).To(Equal(http.StatusInternalServerError))
+				Expect(recorder.Body).To(MatchJSON(`{
+					"code": 500,
+					"error": "Error deleting org",
+					"status": "Internal Server Error"
+				}`))
+			})
+		})
+	})
+
+	Describe("scopes", func() {
+		It("requires a scope for each method", func() {
+			grantScopes = false
+			for _, route := range []struct{ method, path, scope string }{
+				{http.MethodGet, "/orgs/org1", "orgs:read"},
+				{http.MethodDelete, "/orgs/org1", "orgs:delete"},
+			} {
+				recorder = httptest.NewRecorder()
+				var err error
+				request, err = http.NewRequest(route.method, route.path, nil)
+				Expect(err).NotTo(HaveOccurred())
+
+				router.ServeHTTP(recorder, request)
+
+				Expect(recorder.Code).To(Equal(http.StatusForbidden), route.method)
+				Expect(recorder.Header().Get("X-Required-Scope")).To(Equal(route.scope), route.method)
+			}
+		})
+	})
+})
@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/url"
+	"strconv"
+
+	"github.com/myshkin5/effective-octo-garbanzo/persistence"
+	"github.com/myshkin5/effective-octo-garbanzo/services"
+)
+
+const (
+	DefaultPageLimit = 100
+	MaxPageLimit     = 1000
+)
+
+// cursor is the opaque value of the cursor query parameter. Clients are
+// expected to follow next and prev links rather than build cursors themselves.
+type cursor struct {
+	AfterId   int     `json:"a,omitempty"`
+	BeforeId  int     `json:"b,omitempty"`
+	SortValue float64 `json:"v,omitempty"`
+}
+
+// PageKey identifies an item of a page by its internal id and, when the page
+// is sorted by a field, the item's value of that field.
+type PageKey struct {
+	Id        int
+	SortValue float64
+}
+
+// ParsePage reads the limit and cursor query parameters of a collection
+// request.
+func ParsePage(query url.Values) (persistence.Page, error) {
+	page := persistence.Page{
+		Limit: DefaultPageLimit,
+	}
+
+	if limit := query.Get("limit"); limit != "" {
+		var err error
+		page.Limit, err = strconv.Atoi(limit)
+		if err != nil || page.Limit < 1 || page.Limit > MaxPageLimit {
+			return persistence.Page{}, services.NewValidationError(map[string][]string{
+				"limit": {"must be an integer between 1 and " + strconv.Itoa(MaxPageLimit)},
+			})
+		}
+	}
+
+	if value := query.Get("cursor"); value != "" {
+		c, ok := decodeCursor(value)
+		if !ok {
+			return persistence.Page{}, services.NewValidationError(map[string][]string{
+				"cursor": {"is invalid"},
+			})
+		}
+		page.AfterId = c.AfterId
+		page.BeforeId = c.BeforeId
+		page.SortValue = c.SortValue
+	}
+
+	return page, nil
+}
+
+// PageLinks returns the next and prev links of a page of a collection. The keys
+// identify the page's items in order and more reports if there are items
+// beyond the page in the direction it was fetched. A link is empty when there
+// is no page in that direction.
+func PageLinks(collectionURL string, query url.Values, page persistence.Page, keys []PageKey, more bool) (next, prev string) {
+	if len(keys) == 0 {
+		// Step the id past the cursor's item so the link includes it. Ties
+		// on the sort field are broken by id in the same direction.
+		step := 1
+		if page.Sort.Descending {
+			step = -1
+		}
+		if page.Backward() {
+			next = pageLink(collectionURL, query, page, cursor{AfterId: page.BeforeId - step, SortValue: page.SortValue})
+		} else if page.AfterId != 0 {
+			prev = pageLink(collectionURL, query, page, cursor{BeforeId: page.AfterId + step, SortValue: page.SortValue})
+		}
+		return next, prev
+	}
+
+	first, last := keys[0], keys[len(keys)-1]
+	if page.Backward() || more {
+		next = pageLink(collectionURL, query, page, cursor{AfterId: last.Id, SortValue: last.SortValue})
+	}
+	if (page.Backward() && more) || page.AfterId != 0 {
+		prev = pageLink(collectionURL, query, page, cursor{BeforeId: first.Id, SortValue: first.SortValue})
+	}
+
+	return next, prev
+}
+
+func pageLink(collectionURL string, query url.Values, page persistence.Page, c cursor) string {
+	values := url.Values{}
+	for key, value := range query {
+		values[key] = value
+	}
+	values.Set("limit", strconv.Itoa(page.Limit))
+	values.Set("cursor", encodeCursor(c))
+
+	return collectionURL + "?" + values.Encode()
+}
+
+func encodeCursor(c cursor) string {
+	bytes, err := json.Marshal(c)
+	if err != nil {
+		panic(err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(bytes)
+}
+
+func decodeCursor(value string) (cursor, bool) {
+	bytes, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return cursor{}, false
+	}
+
+	var c cursor
+	err = json.Unmarshal(bytes, &c)
+	if err != nil || c.AfterId < 0 || c.BeforeId < 0 || (c.AfterId != 0 && c.BeforeId != 0) {
+		return cursor{}, false
+	}
+
+	return c, true
+}
@@ -0,0 +1,153 @@
+package handlers_test
+
+import (
+	"net/url"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/myshkin5/effective-octo-garbanzo/api/handlers"
+	"github.com/myshkin5/effective-octo-garbanzo/persistence"
+	"github.com/myshkin5/effective-octo-garbanzo/services"
+)
+
+var _ = Describe("Pagination", func() {
+	pageOf := func(link string) persistence.Page {
+		parsed, err := url.Parse(link)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(parsed.Scheme + "://" + parsed.Host + parsed.Path).To(Equal("http://here/octos"))
+		page, err := handlers.ParsePage(parsed.Query())
+		Expect(err).NotTo(HaveOccurred())
+		return page
+	}
+
+	Describe("ParsePage", func() {
+		It("defaults to the first page", func() {
+			page, err := handlers.ParsePage(url.Values{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(page).To(Equal(persistence.Page{Limit: handlers.DefaultPageLimit}))
+		})
+
+		It("reads the limit", func() {
+			page, err := handlers.ParsePage(url.Values{"limit": {"7"}})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(page.Limit).To(Equal(7))
+		})
+
+		It("rejects invalid limits", func() {
+			for _, limit := range []string{"lots", "0", "-3", "1001"} {
+				_, err := handlers.ParsePage(url.Values{"limit": {limit}})
+				Expect(err).To(Equal(services.NewValidationError(map[string][]string{
+					"limit": {"must be an integer between 1 and 1000"},
+				})), limit)
+			}
+		})
+
+		It("rejects invalid cursors", func() {
+			// Not base64, not JSON, a negative id and both directions
+			for _, cursor := range []string{"!!!", "bm90IGpzb24", "eyJhIjotMX0", "eyJhIjoxLCJiIjoyfQ"} {
+				_, err := handlers.ParsePage(url.Values{"cursor": {cursor}})
+				Expect(err).To(Equal(services.NewValidationError(map[string][]string{
+					"cursor": {"is invalid"},
+				})), cursor)
+			}
+		})
+	})
+
+	Describe("PageLinks", func() {
+		keys := func(ids ...int) []handlers.PageKey {
+			var keys []handlers.PageKey
+			for _, id := range ids {
+				keys = append(keys, handlers.PageKey{Id: id})
+			}
+			return keys
+		}
+
+		It("returns no links when everything fits on the first page", func() {
+			next, prev := handlers.PageLinks("http://here/octos", url.Values{},
+				persistence.Page{Limit: 3}, keys(4, 5), false)
+			Expect(next).To(BeEmpty())
+			Expect(prev).To(BeEmpty())
+		})
+
+		It("links forward from the first page", func() {
+			next, prev := handlers.PageLinks("http://here/octos", url.Values{},
+				persistence.Page{Limit: 2}, keys(4, 5), true)
+			Expect(pageOf(next)).To(Equal(persistence.Page{AfterId: 5, Limit: 2}))
+			Expect(prev).To(BeEmpty())
+		})
+
+		It("links both ways from a middle page", func() {
+			next, prev := handlers.PageLinks("http://here/octos", url.Values{},
+				persistence.Page{AfterId: 5, Limit: 2}, keys(6, 9), true)
+			Expect(pageOf(next)).To(Equal(persistence.Page{AfterId: 9, Limit: 2}))
+			Expect(pageOf(prev)).To(Equal(persistence.Page{BeforeId: 6, Limit: 2}))
+		})
+
+		It("links back from the last page", func() {
+			next, prev := handlers.PageLinks("http://here/octos", url.Values{},
+				persistence.Page{AfterId: 9, Limit: 2}, keys(12), false)
+			Expect(next).To(BeEmpty())
+			Expect(pageOf(prev)).To(Equal(persistence.Page{BeforeId: 12, Limit: 2}))
+		})
+
+		It("links both ways from a page fetched backward", func() {
+			next, prev := handlers.PageLinks("http://here/octos", url.Values{},
+				persistence.Page{BeforeId: 12, Limit: 2}, keys(6, 9), true)
+			Expect(pageOf(next)).To(Equal(persistence.Page{AfterId: 9, Limit: 2}))
+			Expect(pageOf(prev)).To(Equal(persistence.Page{BeforeId: 6, Limit: 2}))
+		})
+
+		It("links only forward from the first page fetched backward", func() {
+			next, prev := handlers.PageLinks("http://here/octos", url.Values{},
+				persistence.Page{BeforeId: 6, Limit: 2}, keys(4, 5), false)
+			Expect(pageOf(next)).To(Equal(persistence.Page{AfterId: 5, Limit: 2}))
+			Expect(prev).To(BeEmpty())
+		})
+
+		It("links back from an empty page past the end", func() {
+			next, prev := handlers.PageLinks("http://here/octos", url.Values{},
+				persistence.Page{AfterId: 12, Limit: 2}, nil, false)
+			Expect(next).To(BeEmpty())
+			Expect(pageOf(prev)).To(Equal(persistence.Page{BeforeId: 13, Limit: 2}))
+		})
+
+		It("carries the sort values of sorted pages", func() {
+			page := persistence.Page{
+				AfterId:   5,
+				SortValue: 4.5,
+				Limit:     2,
+				Sort:      persistence.Sort{Field: "DiameterMM", Descending: true},
+			}
+			next, prev := handlers.PageLinks("http://here/octos", url.Values{}, page, []handlers.PageKey{
+				{Id: 9, SortValue: 4.25},
+				{Id: 6, SortValue: 3.5},
+			}, true)
+			Expect(pageOf(next)).To(Equal(persistence.Page{AfterId: 6, SortValue: 3.5, Limit: 2}))
+			Expect(pageOf(prev)).To(Equal(persistence.Page{BeforeId: 9, SortValue: 4.25, Limit: 2}))
+		})
+
+		It("links back from an empty page past the end of a descending sort", func() {
+			page := persistence.Page{
+				AfterId:   12,
+				SortValue: 1.5,
+				Limit:     2,
+				Sort:      persistence.Sort{Field: "DiameterMM", Descending: true},
+			}
+			next, prev := handlers.PageLinks("http://here/octos", url.Values{}, page, nil, false)
+			Expect(next).To(BeEmpty())
+			Expect(pageOf(prev)).To(Equal(persistence.Page{BeforeId: 11, SortValue: 1.5, Limit: 2}))
+		})
+
+		It("preserves other query parameters", func() {
+			next, _ := handlers.PageLinks("http://here/octos", url.Values{
+				"cursor": {"old"},
+				"other":  {"value"},
+			}, persistence.Page{Limit: 2}, keys(4, 5), true)
+			parsed, err := url.Parse(next)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(parsed.Query().Get("other")).To(Equal("value"))
+			Expect(parsed.Query()["cursor"]).To(HaveLen(1))
+		})
+	})
+})
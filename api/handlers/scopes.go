@@ -0,0 +1,31 @@
+package handlers
+
+import "github.com/justinas/alice"
+
+// Scopes a request's token must grant to use each route
+const (
+	ScopeOctosRead   = "octos:read"
+	ScopeOctosWrite  = "octos:write"
+	ScopeOctosDelete = "octos:delete"
+
+	ScopeGarbanzosRead   = "garbanzos:read"
+	ScopeGarbanzosWrite  = "garbanzos:write"
+	ScopeGarbanzosDelete = "garbanzos:delete"
+
+	ScopeGarbanzoTypesRead  = "garbanzo-types:read"
+	ScopeGarbanzoTypesWrite = "garbanzo-types:write"
+
+	ScopeOrgsRead   = "orgs:read"
+	ScopeOrgsWrite  = "orgs:write"
+	ScopeOrgsDelete = "orgs:delete"
+
+	ScopeAPIKeysRead   = "api-keys:read"
+	ScopeAPIKeysWrite  = "api-keys:write"
+	ScopeAPIKeysDelete = "api-keys:delete"
+
+	ScopeAuditRead = "audit:read"
+)
+
+// Authorizer returns the middleware requiring scope of each request it
+// handles. Routes wrap each MethodHandler entry with the scope of the method.
+type Authorizer func(scope string) alice.Constructor
@@ -0,0 +1,61 @@
+// This file was generated by github.com/nelsam/hel.  Do not
+// edit this code by hand unless you *really* know what you're
+// doing.  Expect any changes made manually to be overwritten
+// the next time hel regenerates this file.
+
+package transfer_test
+
+import (
+	"context"
+
+	"github.com/myshkin5/effective-octo-garbanzo/services"
+)
+
+type mockTransferService struct {
+	ExportCalled chan bool
+	ExportInput  struct {
+		Ctx chan context.Context
+		Fn  chan func(record services.Record) error
+	}
+	ExportOutput struct {
+		Err chan error
+	}
+	ImportCalled chan bool
+	ImportInput  struct {
+		Ctx      chan context.Context
+		Conflict chan services.ConflictStrategy
+		Next     chan func() (services.Record, error)
+	}
+	ImportOutput struct {
+		Summary chan services.ImportSummary
+		Err     chan error
+	}
+}
+
+func newMockTransferService() *mockTransferService {
+	m := &mockTransferService{}
+	m.ExportCalled = make(chan bool, 100)
+	m.ExportInput.Ctx = make(chan context.Context, 100)
+	m.ExportInput.Fn = make(chan func(record services.Record) error, 100)
+	m.ExportOutput.Err = make(chan error, 100)
+	m.ImportCalled = make(chan bool, 100)
+	m.ImportInput.Ctx = make(chan context.Context, 100)
+	m.ImportInput.Conflict = make(chan services.ConflictStrategy, 100)
+	m.ImportInput.Next = make(chan func() (services.Record, error), 100)
+	m.ImportOutput.Summary = make(chan services.ImportSummary, 100)
+	m.ImportOutput.Err = make(chan error, 100)
+	return m
+}
+func (m *mockTransferService) Export(ctx context.Context, fn func(record services.Record) error) (err error) {
+	m.ExportCalled <- true
+	m.ExportInput.Ctx <- ctx
+	m.ExportInput.Fn <- fn
+	return <-m.ExportOutput.Err
+}
+func (m *mockTransferService) Import(ctx context.Context, conflict services.ConflictStrategy, next func() (services.Record, error)) (summary services.ImportSummary, err error) {
+	m.ImportCalled <- true
+	m.ImportInput.Ctx <- ctx
+	m.ImportInput.Conflict <- conflict
+	m.ImportInput.Next <- next
+	return <-m.ImportOutput.Summary, <-m.ImportOutput.Err
+}
@@ -0,0 +1,222 @@
+package transfer
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/satori/go.uuid"
+
+	"github.com/myshkin5/effective-octo-garbanzo/persistence/data"
+	"github.com/myshkin5/effective-octo-garbanzo/services"
+)
+
+const (
+	NDJSONContentType = "application/x-ndjson"
+	CSVContentType    = "text/csv"
+
+	octoKind     = "octo"
+	garbanzoKind = "garbanzo"
+)
+
+// Record is one line of an export or import. Octo records only have a kind
+// and octo name.
+type Record struct {
+	Kind         string  `json:"kind"`
+	Octo         string  `json:"octo"`
+	APIUUID      string  `json:"api-uuid,omitempty"`
+	GarbanzoType string  `json:"type,omitempty"`
+	DiameterMM   float32 `json:"diameter-mm,omitempty"`
+}
+
+var csvHeader = []string{"kind", "octo", "api-uuid", "type", "diameter-mm"}
+
+// recordError is an import record that couldn't be read.
+type recordError struct {
+	err error
+}
+
+func (e recordError) Error() string {
+	return e.err.Error()
+}
+
+func (e recordError) Unwrap() error {
+	return e.err
+}
+
+func fromService(record services.Record) Record {
+	if record.Garbanzo == nil {
+		return Record{Kind: octoKind, Octo: record.Octo.Name}
+	}
+
+	return Record{
+		Kind:         garbanzoKind,
+		Octo:         record.Octo.Name,
+		APIUUID:      record.Garbanzo.APIUUID.String(),
+		GarbanzoType: record.Garbanzo.GarbanzoType.Name,
+		DiameterMM:   record.Garbanzo.DiameterMM,
+	}
+}
+
+func toService(record Record) (services.Record, error) {
+	switch record.Kind {
+	case octoKind:
+		return services.Record{Octo: data.Octo{Name: record.Octo}}, nil
+	case garbanzoKind:
+	default:
+		return services.Record{}, recordError{fmt.Errorf("kind must be '%s' or '%s'", octoKind, garbanzoKind)}
+	}
+
+	garbanzo := data.Garbanzo{
+		GarbanzoType: data.GarbanzoType{Name: record.GarbanzoType},
+		DiameterMM:   record.DiameterMM,
+	}
+	if record.APIUUID != "" {
+		var err error
+		garbanzo.APIUUID, err = uuid.FromString(record.APIUUID)
+		if err != nil {
+			return services.Record{}, recordError{err}
+		}
+	}
+
+	return services.Record{Octo: data.Octo{Name: record.Octo}, Garbanzo: &garbanzo}, nil
+}
+
+// recordWriter writes an export in either format. Start writes whatever
+// precedes the records, even when there are none.
+type recordWriter interface {
+	Start() error
+	Write(record Record) error
+	Flush() error
+}
+
+func newRecordWriter(contentType string, w io.Writer) recordWriter {
+	if contentType == CSVContentType {
+		return &csvRecordWriter{writer: csv.NewWriter(w)}
+	}
+
+	buffered := bufio.NewWriter(w)
+	return &ndjsonRecordWriter{buffered: buffered, encoder: json.NewEncoder(buffered)}
+}
+
+type ndjsonRecordWriter struct {
+	buffered *bufio.Writer
+	encoder  *json.Encoder
+}
+
+func (w *ndjsonRecordWriter) Start() error {
+	return nil
+}
+
+// Write writes the record as a line of JSON
+func (w *ndjsonRecordWriter) Write(record Record) error {
+	return w.encoder.Encode(record)
+}
+
+func (w *ndjsonRecordWriter) Flush() error {
+	return w.buffered.Flush()
+}
+
+type csvRecordWriter struct {
+	writer *csv.Writer
+}
+
+// Start writes the header row
+func (w *csvRecordWriter) Start() error {
+	return w.writer.Write(csvHeader)
+}
+
+func (w *csvRecordWriter) Write(record Record) error {
+	var diameterMM string
+	if record.Kind == garbanzoKind {
+		diameterMM = strconv.FormatFloat(float64(record.DiameterMM), 'f', -1, 32)
+	}
+	return w.writer.Write([]string{record.Kind, record.Octo, record.APIUUID, record.GarbanzoType, diameterMM})
+}
+
+func (w *csvRecordWriter) Flush() error {
+	w.writer.Flush()
+	return w.writer.Error()
+}
+
+// newRecordReader returns the next record of an import in either format until
+// io.EOF.
+func newRecordReader(contentType string, r io.Reader) func() (Record, error) {
+	if contentType == CSVContentType {
+		return csvRecordReader(r)
+	}
+
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+	return func() (Record, error) {
+		var record Record
+		err := decoder.Decode(&record)
+		if err == io.EOF {
+			return Record{}, err
+		} else if err != nil {
+			return Record{}, recordError{err}
+		}
+		return record, nil
+	}
+}
+
+// csvRecordReader reads CSV with a header row naming the columns in any order.
+// Empty columns may be omitted.
+func csvRecordReader(r io.Reader) func() (Record, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	var columns map[string]int
+	return func() (Record, error) {
+		if columns == nil {
+			header, err := reader.Read()
+			if err == io.EOF {
+				return Record{}, err
+			} else if err != nil {
+				return Record{}, recordError{err}
+			}
+			columns = make(map[string]int)
+			for i, name := range header {
+				columns[strings.TrimSpace(name)] = i
+			}
+			for _, name := range csvHeader {
+				if _, ok := columns[name]; !ok {
+					return Record{}, recordError{fmt.Errorf("header must have the columns %s", strings.Join(csvHeader, ", "))}
+				}
+			}
+		}
+
+		row, err := reader.Read()
+		if err == io.EOF {
+			return Record{}, err
+		} else if err != nil {
+			return Record{}, recordError{err}
+		}
+		column := func(name string) string {
+			i := columns[name]
+			if i >= len(row) {
+				return ""
+			}
+			return row[i]
+		}
+
+		record := Record{
+			Kind:         column("kind"),
+			Octo:         column("octo"),
+			APIUUID:      column("api-uuid"),
+			GarbanzoType: column("type"),
+		}
+		if diameterMM := column("diameter-mm"); diameterMM != "" {
+			value, err := strconv.ParseFloat(diameterMM, 32)
+			if err != nil {
+				return Record{}, recordError{errors.New("diameter-mm must be a decimal value")}
+			}
+			record.DiameterMM = float32(value)
+		}
+		return record, nil
+	}
+}
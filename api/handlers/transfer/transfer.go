@@ -0,0 +1,250 @@
+package transfer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/justinas/alice"
+
+	"github.com/myshkin5/effective-octo-garbanzo/api/handlers"
+	"github.com/myshkin5/effective-octo-garbanzo/logs"
+	"github.com/myshkin5/effective-octo-garbanzo/persistence"
+	"github.com/myshkin5/effective-octo-garbanzo/services"
+)
+
+// flushRecords is how many records of an export are written between flushes
+const flushRecords = 100
+
+type ImportCounts struct {
+	Created     int `json:"created"`
+	Skipped     int `json:"skipped"`
+	Overwritten int `json:"overwritten"`
+}
+
+type ImportSummary struct {
+	Octos     ImportCounts `json:"octos"`
+	Garbanzos ImportCounts `json:"garbanzos"`
+}
+
+var fieldMapping = map[string]string{
+	"Name":         "octo",
+	"GarbanzoType": "type",
+	"DiameterMM":   "diameter-mm",
+}
+
+type TransferService interface {
+	Export(ctx context.Context, fn func(record services.Record) error) (err error)
+	Import(ctx context.Context, conflict services.ConflictStrategy, next func() (services.Record, error)) (summary services.ImportSummary, err error)
+}
+
+type transfer struct {
+	transferService TransferService
+	maxImportBytes  int64
+}
+
+// MapRoutes maps the export and import of the org's octos and garbanzos. Both
+// require the scopes of the octo and garbanzo routes they stand in for. As an
+// import is validated in full before any of it is imported, its body is
+// limited to maxImportBytes.
+func MapRoutes(router *mux.Router, middleware alice.Chain, authorize handlers.Authorizer, maxImportBytes int64, transferService TransferService) {
+	handler := &transfer{
+		transferService: transferService,
+		maxImportBytes:  maxImportBytes,
+	}
+	exportMethodHandler := make(handlers.MethodHandler)
+	exportMethodHandler[http.MethodGet] = alice.New(authorize(handlers.ScopeOctosRead), authorize(handlers.ScopeGarbanzosRead)).
+		ThenFunc(handler.export)
+	router.Handle("/export", middleware.Then(exportMethodHandler))
+
+	importMethodHandler := make(handlers.MethodHandler)
+	importMethodHandler[http.MethodPost] = alice.New(authorize(handlers.ScopeOctosWrite), authorize(handlers.ScopeGarbanzosWrite)).
+		ThenFunc(handler.importRecords)
+	router.Handle("/import", middleware.Then(importMethodHandler))
+}
+
+func (t *transfer) export(w http.ResponseWriter, req *http.Request) {
+	contentType := exportContentType(req.Header.Get("Accept"))
+
+	writer := newRecordWriter(contentType, w)
+	// Buffered until the first record (or the end of an empty export) so an
+	// error before then can still be returned
+	err := writer.Start()
+	if err != nil {
+		handlers.Error(req.Context(), w, "Error exporting", http.StatusInternalServerError, err, fieldMapping)
+		return
+	}
+	started := false
+	count := 0
+	err = t.transferService.Export(req.Context(), func(record services.Record) error {
+		if !started {
+			w.Header().Set("Content-Type", contentType)
+			w.WriteHeader(http.StatusOK)
+			started = true
+		}
+		err := writer.Write(fromService(record))
+		if err != nil {
+			return err
+		}
+
+		count++
+		if count%flushRecords == 0 {
+			return flush(w, writer)
+		}
+		return nil
+	})
+	if err != nil {
+		if !started {
+			handlers.Error(req.Context(), w, "Error exporting", http.StatusInternalServerError, err, fieldMapping)
+			return
+		}
+		// Too late to change the status so cut the response short instead
+		logs.FromContext(req.Context()).Errorf("Error exporting, aborting response: %v", err)
+		panic(http.ErrAbortHandler)
+	}
+
+	if !started {
+		w.Header().Set("Content-Type", contentType)
+		w.WriteHeader(http.StatusOK)
+	}
+	err = writer.Flush()
+	if err != nil {
+		logs.FromContext(req.Context()).Errorf("Error flushing export: %v", err)
+	}
+}
+
+// flush sends the buffered records on to the client so a large export
+// arrives as it is read rather than all at the end.
+func flush(w http.ResponseWriter, writer recordWriter) error {
+	err := writer.Flush()
+	if err != nil {
+		return err
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if ok {
+		flusher.Flush()
+	}
+
+	return nil
+}
+
+// exportContentType returns the format the Accept header prefers, NDJSON
+// unless CSV has a higher quality.
+func exportContentType(accept string) string {
+	if quality(accept, CSVContentType) > quality(accept, NDJSONContentType) {
+		return CSVContentType
+	}
+
+	return NDJSONContentType
+}
+
+// quality returns the q-value the Accept header gives mediaType from the most
+// specific media range matching it, 0 when none do. Every type is acceptable
+// without an Accept header.
+func quality(accept, mediaType string) float64 {
+	if strings.TrimSpace(accept) == "" {
+		return 1
+	}
+
+	q := 0.0
+	mostSpecific := -1
+	for _, accepted := range strings.Split(accept, ",") {
+		acceptedType, params, err := mime.ParseMediaType(strings.TrimSpace(accepted))
+		if err != nil {
+			continue
+		}
+
+		var specificity int
+		switch {
+		case acceptedType == mediaType:
+			specificity = 2
+		case strings.HasSuffix(acceptedType, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(acceptedType, "*")):
+			specificity = 1
+		case acceptedType == "*/*":
+			specificity = 0
+		default:
+			continue
+		}
+		if specificity <= mostSpecific {
+			continue
+		}
+		mostSpecific = specificity
+
+		q = 1
+		if value, ok := params["q"]; ok {
+			q, err = strconv.ParseFloat(value, 64)
+			if err != nil {
+				q = 0
+			}
+		}
+	}
+
+	return q
+}
+
+func (t *transfer) importRecords(w http.ResponseWriter, req *http.Request) {
+	conflict := services.ConflictStrategy(req.URL.Query().Get("conflict"))
+	switch conflict {
+	case "":
+		conflict = services.ConflictFail
+	case services.ConflictFail, services.ConflictSkip, services.ConflictOverwrite:
+	default:
+		handlers.Error(req.Context(), w, fmt.Sprintf("Invalid conflict, must be '%s', '%s' or '%s'",
+			services.ConflictFail, services.ConflictSkip, services.ConflictOverwrite), http.StatusBadRequest, nil, fieldMapping)
+		return
+	}
+
+	contentType, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	read := newRecordReader(contentType, http.MaxBytesReader(w, req.Body, t.maxImportBytes))
+	summary, err := t.transferService.Import(req.Context(), conflict, func() (services.Record, error) {
+		record, err := read()
+		if err != nil {
+			return services.Record{}, err
+		}
+		return toService(record)
+	})
+	if importErr, ok := err.(services.ImportError); ok {
+		t.importError(w, req, importErr)
+		return
+	} else if err != nil {
+		handlers.Error(req.Context(), w, "Error importing", http.StatusInternalServerError, err, fieldMapping)
+		return
+	}
+
+	handlers.Respond(w, http.StatusOK, ImportSummary{
+		Octos:     ImportCounts(summary.Octos),
+		Garbanzos: ImportCounts(summary.Garbanzos),
+	})
+}
+
+func (t *transfer) importError(w http.ResponseWriter, req *http.Request, importErr services.ImportError) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(importErr.Err, &tooLarge) {
+		handlers.Error(req.Context(), w, fmt.Sprintf("Import must be at most %d bytes", tooLarge.Limit),
+			http.StatusRequestEntityTooLarge, importErr.Err, fieldMapping)
+		return
+	}
+
+	switch err := importErr.Err.(type) {
+	case recordError:
+		handlers.Error(req.Context(), w, fmt.Sprintf("Invalid record %d: %s", importErr.Index, err), http.StatusBadRequest, err, fieldMapping)
+	case services.ValidationError:
+		handlers.Error(req.Context(), w, fmt.Sprintf("Invalid record %d", importErr.Index), http.StatusBadRequest, err, fieldMapping)
+	default:
+		switch err {
+		case services.ErrRecordExists, persistence.ErrDuplicate:
+			// A duplicate is a garbanzo whose API UUID belongs to another org
+			handlers.Error(req.Context(), w, fmt.Sprintf("Record %d already exists", importErr.Index), http.StatusConflict, err, fieldMapping)
+		case persistence.ErrNotFound:
+			handlers.Error(req.Context(), w, fmt.Sprintf("Octo '%s' of record %d not found", importErr.Record.Octo.Name, importErr.Index), http.StatusConflict, err, fieldMapping)
+		default:
+			handlers.Error(req.Context(), w, fmt.Sprintf("Error importing record %d", importErr.Index), http.StatusInternalServerError, err, fieldMapping)
+		}
+	}
+}
@@ -0,0 +1,13 @@
+package transfer_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestTransfer(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "API - Handlers - Transfer Suite")
+}
@@ -0,0 +1,459 @@
+package transfer_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/justinas/alice"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/satori/go.uuid"
+
+	"github.com/myshkin5/effective-octo-garbanzo/api/handlers/transfer"
+	"github.com/myshkin5/effective-octo-garbanzo/persistence"
+	"github.com/myshkin5/effective-octo-garbanzo/persistence/data"
+	"github.com/myshkin5/effective-octo-garbanzo/services"
+)
+
+// exportingService streams records to the handler during Export, which a
+// channel based mock can't do.
+type exportingService struct {
+	*mockTransferService
+	records []services.Record
+}
+
+func (s exportingService) Export(ctx context.Context, fn func(record services.Record) error) error {
+	for _, record := range s.records {
+		err := fn(record)
+		if err != nil {
+			return err
+		}
+	}
+	return s.mockTransferService.Export(ctx, fn)
+}
+
+var _ = Describe("Transfer", func() {
+	var (
+		recorder    *httptest.ResponseRecorder
+		mockService *mockTransferService
+		service     exportingService
+		router      *mux.Router
+		grantScopes bool
+		scopes      []string
+		apiUUID     uuid.UUID
+	)
+
+	BeforeEach(func() {
+		recorder = httptest.NewRecorder()
+		recorder.Code = 0
+
+		mockService = newMockTransferService()
+		apiUUID = uuid.NewV4()
+		service = exportingService{mockTransferService: mockService}
+
+		grantScopes = true
+		scopes = nil
+		authorize := func(scope string) alice.Constructor {
+			return func(h http.Handler) http.Handler {
+				return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					scopes = append(scopes, scope)
+					if !grantScopes {
+						w.WriteHeader(http.StatusForbidden)
+						return
+					}
+					h.ServeHTTP(w, r)
+				})
+			}
+		}
+
+		router = mux.NewRouter()
+		transfer.MapRoutes(router, alice.Chain{}, authorize, 1024, &service)
+	})
+
+	Describe("GET /export", func() {
+		export := func(accept string) {
+			request, err := http.NewRequest(http.MethodGet, "/export", nil)
+			Expect(err).NotTo(HaveOccurred())
+			request.Header.Set("Accept", accept)
+
+			router.ServeHTTP(recorder, request)
+		}
+
+		BeforeEach(func() {
+			kraken := data.Octo{Id: 7, Name: "kraken"}
+			service.records = []services.Record{
+				{Octo: kraken},
+				{Octo: kraken, Garbanzo: &data.Garbanzo{APIUUID: apiUUID, GarbanzoType: data.GarbanzoType{Name: "DESI"}, DiameterMM: 4.2}},
+				{Octo: data.Octo{Id: 8, Name: "cthulhu"}},
+			}
+		})
+
+		It("streams the records as NDJSON by default", func() {
+			mockService.ExportOutput.Err <- nil
+
+			export("")
+
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(recorder.Header().Get("Content-Type")).To(Equal("application/x-ndjson"))
+			lines := strings.Split(strings.TrimSuffix(recorder.Body.String(), "\n"), "\n")
+			Expect(lines).To(HaveLen(3))
+			Expect(lines[0]).To(MatchJSON(`{"kind": "octo", "octo": "kraken"}`))
+			Expect(lines[1]).To(MatchJSON(fmt.Sprintf(`{
+				"kind":        "garbanzo",
+				"octo":        "kraken",
+				"api-uuid":    "%s",
+				"type":        "DESI",
+				"diameter-mm": 4.2
+			}`, apiUUID)))
+			Expect(lines[2]).To(MatchJSON(`{"kind": "octo", "octo": "cthulhu"}`))
+		})
+
+		It("streams the records as CSV when accepted", func() {
+			mockService.ExportOutput.Err <- nil
+
+			export("text/csv")
+
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(recorder.Header().Get("Content-Type")).To(Equal("text/csv"))
+			Expect(recorder.Body.String()).To(Equal(fmt.Sprintf("kind,octo,api-uuid,type,diameter-mm\n"+
+				"octo,kraken,,,\n"+
+				"garbanzo,kraken,%s,DESI,4.2\n"+
+				"octo,cthulhu,,,\n", apiUUID)))
+		})
+
+		It("writes only the CSV header for an empty org", func() {
+			service.records = nil
+			mockService.ExportOutput.Err <- nil
+
+			export("text/csv")
+
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(recorder.Header().Get("Content-Type")).To(Equal("text/csv"))
+			Expect(recorder.Body.String()).To(Equal("kind,octo,api-uuid,type,diameter-mm\n"))
+		})
+
+		It("flushes the records every hundred records", func() {
+			service.records = nil
+			for i := 0; i < 99; i++ {
+				service.records = append(service.records, services.Record{Octo: data.Octo{Name: fmt.Sprintf("octo-%d", i)}})
+			}
+			mockService.ExportOutput.Err <- nil
+
+			export("")
+
+			Expect(recorder.Flushed).To(BeFalse())
+
+			recorder = httptest.NewRecorder()
+			service.records = append(service.records, services.Record{Octo: data.Octo{Name: "octo-99"}})
+			mockService.ExportOutput.Err <- nil
+
+			export("")
+
+			Expect(recorder.Flushed).To(BeTrue())
+			Expect(strings.Count(recorder.Body.String(), "\n")).To(Equal(100))
+		})
+
+		It("streams the records as CSV when any text is accepted", func() {
+			mockService.ExportOutput.Err <- nil
+
+			export("text/*")
+
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(recorder.Header().Get("Content-Type")).To(Equal("text/csv"))
+		})
+
+		It("streams the records in the format with the highest quality", func() {
+			mockService.ExportOutput.Err <- nil
+
+			export("application/x-ndjson;q=0.5, text/csv; charset=utf-8")
+
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(recorder.Header().Get("Content-Type")).To(Equal("text/csv"))
+		})
+
+		It("streams the records as NDJSON when CSV has a lower quality than anything else", func() {
+			mockService.ExportOutput.Err <- nil
+
+			export("text/csv;q=0.5, */*")
+
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(recorder.Header().Get("Content-Type")).To(Equal("application/x-ndjson"))
+		})
+
+		It("doesn't mistake another type containing text/csv for CSV", func() {
+			mockService.ExportOutput.Err <- nil
+
+			export("text/csvx")
+
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(recorder.Header().Get("Content-Type")).To(Equal("application/x-ndjson"))
+		})
+
+		It("returns an internal server error when the export fails before it starts", func() {
+			service.records = nil
+			mockService.ExportOutput.Err <- errors.New("don't bother")
+
+			export("")
+
+			Expect(recorder.Code).To(Equal(http.StatusInternalServerError))
+			Expect(recorder.Body).To(MatchJSON(`{
+				"code": 500,
+				"error": "Error exporting",
+				"status": "Internal Server Error"
+			}`))
+		})
+
+		It("aborts the response when the export fails part way through", func() {
+			mockService.ExportOutput.Err <- errors.New("don't bother")
+
+			Expect(func() { export("") }).To(PanicWith(http.ErrAbortHandler))
+		})
+	})
+
+	Describe("POST /import", func() {
+		post := func(query, contentType, body string) {
+			request, err := http.NewRequest(http.MethodPost, "/import"+query, strings.NewReader(body))
+			Expect(err).NotTo(HaveOccurred())
+			request.Header.Set("Content-Type", contentType)
+
+			router.ServeHTTP(recorder, request)
+		}
+
+		readAll := func() []services.Record {
+			var next func() (services.Record, error)
+			Expect(mockService.ImportInput.Next).To(Receive(&next))
+			var records []services.Record
+			for {
+				record, err := next()
+				if err == io.EOF {
+					return records
+				}
+				Expect(err).NotTo(HaveOccurred())
+				records = append(records, record)
+			}
+		}
+
+		It("imports NDJSON records and responds with the summary", func() {
+			mockService.ImportOutput.Summary <- services.ImportSummary{
+				Octos:     services.ImportCounts{Created: 1},
+				Garbanzos: services.ImportCounts{Skipped: 2, Overwritten: 3},
+			}
+			mockService.ImportOutput.Err <- nil
+
+			post("?conflict=skip", "application/x-ndjson", fmt.Sprintf(`{"kind": "octo", "octo": "kraken"}
+				{"kind": "garbanzo", "octo": "kraken", "api-uuid": "%s", "type": "DESI", "diameter-mm": 4.2}
+				{"kind": "garbanzo", "octo": "kraken", "type": "KABULI", "diameter-mm": 6.4}`, apiUUID))
+
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(recorder.Body).To(MatchJSON(`{
+				"octos":     {"created": 1, "skipped": 0, "overwritten": 0},
+				"garbanzos": {"created": 0, "skipped": 2, "overwritten": 3}
+			}`))
+
+			Expect(mockService.ImportInput.Conflict).To(Receive(Equal(services.ConflictSkip)))
+			Expect(readAll()).To(Equal([]services.Record{
+				{Octo: data.Octo{Name: "kraken"}},
+				{Octo: data.Octo{Name: "kraken"}, Garbanzo: &data.Garbanzo{
+					APIUUID:      apiUUID,
+					GarbanzoType: data.GarbanzoType{Name: "DESI"},
+					DiameterMM:   4.2,
+				}},
+				{Octo: data.Octo{Name: "kraken"}, Garbanzo: &data.Garbanzo{
+					GarbanzoType: data.GarbanzoType{Name: "KABULI"},
+					DiameterMM:   6.4,
+				}},
+			}))
+		})
+
+		It("imports CSV records with the columns in any order, failing on conflicts by default", func() {
+			mockService.ImportOutput.Summary <- services.ImportSummary{}
+			mockService.ImportOutput.Err <- nil
+
+			post("", "text/csv; charset=utf-8", fmt.Sprintf("octo,kind,type,diameter-mm,api-uuid\n"+
+				"kraken,octo\n"+
+				"kraken,garbanzo,DESI,4.2,%s\n", apiUUID))
+
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(mockService.ImportInput.Conflict).To(Receive(Equal(services.ConflictFail)))
+			Expect(readAll()).To(Equal([]services.Record{
+				{Octo: data.Octo{Name: "kraken"}},
+				{Octo: data.Octo{Name: "kraken"}, Garbanzo: &data.Garbanzo{
+					APIUUID:      apiUUID,
+					GarbanzoType: data.GarbanzoType{Name: "DESI"},
+					DiameterMM:   4.2,
+				}},
+			}))
+		})
+
+		It("returns a bad request for an unknown conflict strategy", func() {
+			post("?conflict=sometimes", "application/x-ndjson", "")
+
+			Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+			Expect(recorder.Body).To(MatchJSON(`{
+				"code": 400,
+				"error": "Invalid conflict, must be 'fail', 'skip' or 'overwrite'",
+				"status": "Bad Request"
+			}`))
+			Expect(mockService.ImportCalled).To(BeEmpty())
+		})
+
+		It("fails to read a record of an unknown kind", func() {
+			mockService.ImportOutput.Summary <- services.ImportSummary{}
+			mockService.ImportOutput.Err <- nil
+
+			post("", "application/x-ndjson", `{"kind": "squid", "octo": "kraken"}`)
+
+			var next func() (services.Record, error)
+			Expect(mockService.ImportInput.Next).To(Receive(&next))
+			_, err := next()
+			Expect(err).To(MatchError("kind must be 'octo' or 'garbanzo'"))
+		})
+
+		It("fails to read past the import size limit", func() {
+			mockService.ImportOutput.Summary <- services.ImportSummary{}
+			mockService.ImportOutput.Err <- nil
+
+			post("", "application/x-ndjson", strings.Repeat(`{"kind": "octo", "octo": "kraken"}`+"\n", 100))
+
+			var next func() (services.Record, error)
+			Expect(mockService.ImportInput.Next).To(Receive(&next))
+			var err error
+			for err == nil {
+				_, err = next()
+			}
+			var tooLarge *http.MaxBytesError
+			Expect(errors.As(err, &tooLarge)).To(BeTrue())
+			Expect(tooLarge.Limit).To(Equal(int64(1024)))
+		})
+
+		Context("import errors", func() {
+			importError := func(err error) {
+				mockService.ImportOutput.Summary <- services.ImportSummary{}
+				mockService.ImportOutput.Err <- services.ImportError{
+					Index:  2,
+					Record: services.Record{Octo: data.Octo{Name: "kraken"}, Garbanzo: &data.Garbanzo{}},
+					Err:    err,
+				}
+
+				post("", "application/x-ndjson", "")
+			}
+
+			It("returns a request entity too large for an import over the size limit", func() {
+				importError(&http.MaxBytesError{Limit: 1024})
+
+				Expect(recorder.Code).To(Equal(http.StatusRequestEntityTooLarge))
+				Expect(recorder.Body).To(MatchJSON(`{
+					"code": 413,
+					"error": "Import must be at most 1024 bytes",
+					"status": "Request Entity Too Large"
+				}`))
+			})
+
+			It("returns a bad request for an unreadable record", func() {
+				mockService.ImportOutput.Summary <- services.ImportSummary{}
+				mockService.ImportOutput.Err <- nil
+				post("", "application/x-ndjson", "not json")
+				var next func() (services.Record, error)
+				Expect(mockService.ImportInput.Next).To(Receive(&next))
+				_, err := next()
+
+				recorder = httptest.NewRecorder()
+				importError(err)
+
+				Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+			})
+
+			It("returns a bad request for an invalid record", func() {
+				importError(services.NewValidationError(map[string][]string{
+					"DiameterMM": {"must be a positive decimal value"},
+				}))
+
+				Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+				Expect(recorder.Body).To(MatchJSON(`{
+					"code": 400,
+					"error": "Invalid record 2",
+					"errors": ["diameter-mm must be a positive decimal value"],
+					"status": "Bad Request"
+				}`))
+			})
+
+			It("returns a conflict for a record that exists", func() {
+				importError(services.ErrRecordExists)
+
+				Expect(recorder.Code).To(Equal(http.StatusConflict))
+				Expect(recorder.Body).To(MatchJSON(`{
+					"code": 409,
+					"error": "Record 2 already exists",
+					"status": "Conflict"
+				}`))
+			})
+
+			It("returns a conflict for a garbanzo whose API UUID is taken by another org", func() {
+				importError(persistence.ErrDuplicate)
+
+				Expect(recorder.Code).To(Equal(http.StatusConflict))
+				Expect(recorder.Body).To(MatchJSON(`{
+					"code": 409,
+					"error": "Record 2 already exists",
+					"status": "Conflict"
+				}`))
+			})
+
+			It("returns a conflict for a garbanzo of an unknown octo", func() {
+				importError(persistence.ErrNotFound)
+
+				Expect(recorder.Code).To(Equal(http.StatusConflict))
+				Expect(recorder.Body).To(MatchJSON(`{
+					"code": 409,
+					"error": "Octo 'kraken' of record 2 not found",
+					"status": "Conflict"
+				}`))
+			})
+
+			It("returns an internal server error for anything else", func() {
+				importError(errors.New("don't bother"))
+
+				Expect(recorder.Code).To(Equal(http.StatusInternalServerError))
+			})
+		})
+	})
+
+	Describe("scopes", func() {
+		It("requires the octo and garbanzo scopes", func() {
+			grantScopes = false
+			for _, route := range []struct {
+				method, path string
+				scope        string
+			}{
+				{http.MethodGet, "/export", "octos:read"},
+				{http.MethodPost, "/import", "octos:write"},
+			} {
+				recorder = httptest.NewRecorder()
+				scopes = nil
+				request, err := http.NewRequest(route.method, route.path, nil)
+				Expect(err).NotTo(HaveOccurred())
+
+				router.ServeHTTP(recorder, request)
+
+				Expect(recorder.Code).To(Equal(http.StatusForbidden), route.method)
+				Expect(scopes).To(Equal([]string{route.scope}), route.method)
+			}
+		})
+
+		It("checks the garbanzo scope after the octo scope", func() {
+			mockService.ExportOutput.Err <- nil
+			request, err := http.NewRequest(http.MethodGet, "/export", nil)
+			Expect(err).NotTo(HaveOccurred())
+
+			router.ServeHTTP(recorder, request)
+
+			Expect(scopes).To(Equal([]string{"octos:read", "garbanzos:read"}))
+		})
+	})
+})
@@ -17,8 +17,10 @@ import (
 	. "github.com/onsi/gomega"
 
 	"github.com/myshkin5/effective-octo-garbanzo/api/handlers"
+	"github.com/myshkin5/effective-octo-garbanzo/api/handlers/audit"
 	"github.com/myshkin5/effective-octo-garbanzo/api/handlers/garbanzo"
 	"github.com/myshkin5/effective-octo-garbanzo/api/handlers/octo"
+	"github.com/myshkin5/effective-octo-garbanzo/api/handlers/transfer"
 )
 
 const (
@@ -52,21 +54,188 @@ var _ = Describe("API", func() {
 	})
 
 	BeforeEach(func() {
-		response, err := http.Get("http://localhost:8081/token")
+		token = fetchToken("RS256")
+	})
+
+	It("accepts tokens signed with each supported algorithm", func() {
+		for _, alg := range []string{"RS256", "ES256", "ES384", "EdDSA"} {
+			response, err := do("GET", url+"octos", fetchToken(alg), nil)
+			Expect(err).NotTo(HaveOccurred())
+			response.Body.Close()
+
+			Expect(response.StatusCode).To(Equal(http.StatusOK), alg)
+		}
+	})
+
+	It("authenticates with API keys until they are revoked", func() {
+		response, err := do("POST", url+"orgs/org1/api-keys", token, strings.NewReader(`{
+			"name": "integration",
+			"scopes": ["octos:read"]
+		}`))
 		Expect(err).NotTo(HaveOccurred())
+		Expect(response.StatusCode).To(Equal(http.StatusCreated))
+		var apiKey handlers.JSONObject
+		Expect(json.NewDecoder(response.Body).Decode(&apiKey)).To(Succeed())
+		response.Body.Close()
+		key := "ApiKey " + apiKey["key"].(string)
 
-		defer response.Body.Close()
+		response, err = do("GET", url+"octos", key, nil)
+		Expect(err).NotTo(HaveOccurred())
+		response.Body.Close()
+		Expect(response.StatusCode).To(Equal(http.StatusOK))
+
+		response, err = do("POST", url+"octos", key, strings.NewReader(`{"name": "api_key"}`))
+		Expect(err).NotTo(HaveOccurred())
+		response.Body.Close()
+		Expect(response.StatusCode).To(Equal(http.StatusForbidden), "the key only grants octos:read")
+
+		response, err = do("DELETE", apiKey["link"].(string), token, nil)
+		Expect(err).NotTo(HaveOccurred())
+		response.Body.Close()
+		Expect(response.StatusCode).To(Equal(http.StatusNoContent))
+
+		response, err = do("GET", url+"octos", key, nil)
+		Expect(err).NotTo(HaveOccurred())
+		response.Body.Close()
+		Expect(response.StatusCode).To(Equal(http.StatusUnauthorized))
+	})
+
+	It("replays the response to a retried create with the same Idempotency-Key", func() {
+		key := fmt.Sprintf("integration-%d", time.Now().UnixNano())
+		post := func(body string) (*http.Response, string) {
+			request, err := http.NewRequest("POST", url+"octos", strings.NewReader(body))
+			Expect(err).NotTo(HaveOccurred())
+			request.Header.Set("Authorization", token)
+			request.Header.Set("Idempotency-Key", key)
+			response, err := http.DefaultClient.Do(request)
+			Expect(err).NotTo(HaveOccurred())
+			defer response.Body.Close()
+			responseBody, err := ioutil.ReadAll(response.Body)
+			Expect(err).NotTo(HaveOccurred())
+			return response, string(responseBody)
+		}
+		body := fmt.Sprintf(`{"name": "idempotent_%d"}`, time.Now().UnixNano())
+
+		first, firstBody := post(body)
+		Expect(first.StatusCode).To(Equal(http.StatusCreated))
+
+		retry, retryBody := post(body)
+		Expect(retry.StatusCode).To(Equal(http.StatusCreated))
+		Expect(retry.Header.Get("Idempotent-Replayed")).To(Equal("true"))
+		Expect(retryBody).To(MatchJSON(firstBody))
+
+		reused, _ := post(`{"name": "something_else"}`)
+		Expect(reused.StatusCode).To(Equal(http.StatusUnprocessableEntity))
+	})
+
+	It("creates a batch of garbanzos with a result for each", func() {
+		octoName := fmt.Sprintf("batch_%d", time.Now().UnixNano())
+		response, err := do("POST", url+"octos", token, strings.NewReader(fmt.Sprintf(`{"name": "%s"}`, octoName)))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(response.Body.Close()).To(Succeed())
+		Expect(response.StatusCode).To(Equal(http.StatusCreated))
+
+		batch := `[{"type": "DESI", "diameter-mm": 4.2}, {"type": "BOGUS", "diameter-mm": 4.2}]`
+		post := func(mode string) garbanzo.BatchResponse {
+			response, err := do("POST", url+"octos/"+octoName+"/garbanzos:batch?mode="+mode, token, strings.NewReader(batch))
+			Expect(err).NotTo(HaveOccurred())
+			defer response.Body.Close()
+			Expect(response.StatusCode).To(Equal(http.StatusMultiStatus))
+			var body garbanzo.BatchResponse
+			Expect(json.NewDecoder(response.Body).Decode(&body)).To(Succeed())
+			return body
+		}
+
+		allOrNothing := post("all-or-nothing")
+		Expect(allOrNothing.Results).To(HaveLen(2))
+		Expect(allOrNothing.Results[0].Code).To(Equal(http.StatusFailedDependency))
+		Expect(allOrNothing.Results[1].Code).To(Equal(http.StatusBadRequest))
 
+		bestEffort := post("best-effort")
+		Expect(bestEffort.Results[0].Code).To(Equal(http.StatusCreated))
+		Expect(bestEffort.Results[1].Code).To(Equal(http.StatusBadRequest))
+
+		response, err = do("GET", bestEffort.Results[0].Garbanzo.Link, token, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(response.Body.Close()).To(Succeed())
 		Expect(response.StatusCode).To(Equal(http.StatusOK))
+	})
 
-		bytes, err := ioutil.ReadAll(response.Body)
+	It("imports an export of the org without changing anything", func() {
+		octoName := fmt.Sprintf("export_%d", time.Now().UnixNano())
+		response, err := do("POST", url+"octos", token, strings.NewReader(fmt.Sprintf(`{"name": "%s"}`, octoName)))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(response.Body.Close()).To(Succeed())
+		Expect(response.StatusCode).To(Equal(http.StatusCreated))
+		response, err = do("POST", url+"octos/"+octoName+"/garbanzos", token, strings.NewReader(`{"type": "DESI", "diameter-mm": 4.2}`))
 		Expect(err).NotTo(HaveOccurred())
+		Expect(response.Body.Close()).To(Succeed())
+		Expect(response.StatusCode).To(Equal(http.StatusCreated))
+
+		response, err = do("GET", url+"export", token, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(response.StatusCode).To(Equal(http.StatusOK))
+		Expect(response.Header.Get("Content-Type")).To(Equal(transfer.NDJSONContentType))
+		export, err := ioutil.ReadAll(response.Body)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(response.Body.Close()).To(Succeed())
+		Expect(string(export)).To(ContainSubstring(fmt.Sprintf(`{"kind":"octo","octo":"%s"}`, octoName)))
+
+		request, err := http.NewRequest("POST", url+"import?conflict=skip", strings.NewReader(string(export)))
+		Expect(err).NotTo(HaveOccurred())
+		request.Header.Set("Authorization", token)
+		request.Header.Set("Content-Type", transfer.NDJSONContentType)
+		response, err = http.DefaultClient.Do(request)
+		Expect(err).NotTo(HaveOccurred())
+		defer response.Body.Close()
+		Expect(response.StatusCode).To(Equal(http.StatusOK))
+		var summary transfer.ImportSummary
+		Expect(json.NewDecoder(response.Body).Decode(&summary)).To(Succeed())
+		Expect(summary.Octos.Created).To(BeZero())
+		Expect(summary.Octos.Skipped).NotTo(BeZero())
+		Expect(summary.Garbanzos.Created).To(BeZero())
+		Expect(summary.Garbanzos.Skipped).NotTo(BeZero())
+	})
 
-		var body handlers.JSONObject
-		err = json.Unmarshal(bytes, &body)
+	It("records an audit event for each change of an octo", func() {
+		octoName := fmt.Sprintf("audit_%d", time.Now().UnixNano())
+		response, err := do("POST", url+"octos", token, strings.NewReader(fmt.Sprintf(`{"name": "%s"}`, octoName)))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(response.Body.Close()).To(Succeed())
+		Expect(response.StatusCode).To(Equal(http.StatusCreated))
+		response, err = do("POST", url+"octos/"+octoName+"/garbanzos", token, strings.NewReader(`{"type": "DESI", "diameter-mm": 4.2}`))
 		Expect(err).NotTo(HaveOccurred())
+		var created garbanzo.Garbanzo
+		Expect(json.NewDecoder(response.Body).Decode(&created)).To(Succeed())
+		Expect(response.Body.Close()).To(Succeed())
+		Expect(response.StatusCode).To(Equal(http.StatusCreated))
+		response, err = do("DELETE", url+"octos/"+octoName, token, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(response.Body.Close()).To(Succeed())
+		Expect(response.StatusCode).To(Equal(http.StatusNoContent))
 
-		token = "bearer " + body["token"].(string)
+		response, err = do("GET", url+"audit?resource=octos/"+octoName, token, nil)
+		Expect(err).NotTo(HaveOccurred())
+		defer response.Body.Close()
+		Expect(response.StatusCode).To(Equal(http.StatusOK))
+		var list audit.AuditEventList
+		Expect(json.NewDecoder(response.Body).Decode(&list)).To(Succeed())
+		Expect(list.Events).To(HaveLen(4))
+		Expect(list.Events[0].Action).To(Equal("delete"))
+		Expect(list.Events[0].Resource).To(Equal(url + "octos/" + octoName))
+		Expect(list.Events[0].Before).NotTo(BeEmpty())
+		Expect(list.Events[0].After).To(BeEmpty())
+		// The garbanzo deleted along with the octo is audited on its own
+		Expect(list.Events[1].Action).To(Equal("delete"))
+		Expect(list.Events[1].Resource).To(Equal(created.Link))
+		Expect(list.Events[1].Before).NotTo(BeEmpty())
+		Expect(list.Events[1].After).To(BeEmpty())
+		Expect(list.Events[2].Action).To(Equal("create"))
+		Expect(list.Events[2].Resource).To(Equal(created.Link))
+		Expect(list.Events[3].Action).To(Equal("create"))
+		Expect(list.Events[3].Resource).To(Equal(url + "octos/" + octoName))
+		Expect(list.Events[3].Actor).NotTo(BeEmpty())
+		Expect(list.Events[3].RequestID).NotTo(BeEmpty())
 	})
 
 	Measure("the standard suite of operations", func(b Benchmarker) {
@@ -272,8 +441,8 @@ func getRandomOctos(count int, url, token string, errs chan error) []octo.Octo {
 		return nil
 	}
 
-	var fullList []octo.Octo
-	err = json.NewDecoder(response.Body).Decode(&fullList)
+	var page octo.OctoList
+	err = json.NewDecoder(response.Body).Decode(&page)
 	if err != nil {
 		errs <- err
 		return nil
@@ -284,6 +453,8 @@ func getRandomOctos(count int, url, token string, errs chan error) []octo.Octo {
 		errs <- err
 	}
 
+	// Sampling from the first page is random enough
+	fullList := page.Octos
 	returnCount := min(count, len(fullList))
 
 	list := make([]octo.Octo, returnCount)
@@ -311,8 +482,8 @@ func getRandomGarbanzos(count int, url, token string, errs chan error) []garbanz
 			return nil
 		}
 
-		var fullList []garbanzo.Garbanzo
-		err = json.NewDecoder(response.Body).Decode(&fullList)
+		var page garbanzo.GarbanzoList
+		err = json.NewDecoder(response.Body).Decode(&page)
 		if err != nil {
 			errs <- err
 			return nil
@@ -323,7 +494,7 @@ func getRandomGarbanzos(count int, url, token string, errs chan error) []garbanz
 			errs <- err
 		}
 
-		if len(fullList) > 0 {
+		if fullList := page.Garbanzos; len(fullList) > 0 {
 			list = append(list, fullList[rand.Intn(len(fullList))])
 		}
 	}
@@ -354,6 +525,24 @@ func waitTimeout(wg *sync.WaitGroup, timeout time.Duration) bool {
 	}
 }
 
+func fetchToken(alg string) string {
+	response, err := http.Get("http://localhost:8081/token?alg=" + alg)
+	ExpectWithOffset(1, err).NotTo(HaveOccurred())
+
+	defer response.Body.Close()
+
+	ExpectWithOffset(1, response.StatusCode).To(Equal(http.StatusOK))
+
+	bytes, err := ioutil.ReadAll(response.Body)
+	ExpectWithOffset(1, err).NotTo(HaveOccurred())
+
+	var body handlers.JSONObject
+	err = json.Unmarshal(bytes, &body)
+	ExpectWithOffset(1, err).NotTo(HaveOccurred())
+
+	return "bearer " + body["token"].(string)
+}
+
 func do(method, url, token string, body io.Reader) (*http.Response, error) {
 	request, err := http.NewRequest(method, url, body)
 	if err != nil {
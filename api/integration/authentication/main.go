@@ -1,11 +1,15 @@
 package main
 
 import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
 	"encoding/base64"
 	"encoding/binary"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/dgrijalva/jwt-go"
@@ -40,13 +44,21 @@ func initRoutes() *mux.Router {
 
 	middleware := alice.New(handlers.LoggingHandler)
 
-	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
 	if err != nil {
 		logs.Logger.Panic("Could not generate key: ", err)
 	}
 
 	eBuf := make([]byte, 4)
-	binary.LittleEndian.PutUint32(eBuf, uint32(privateKey.PublicKey.E))
+	binary.LittleEndian.PutUint32(eBuf, uint32(rsaKey.PublicKey.E))
+
+	es256Key := mustGenerateECKey(elliptic.P256())
+	es384Key := mustGenerateECKey(elliptic.P384())
+
+	ed25519Public, ed25519Key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		logs.Logger.Panic("Could not generate key: ", err)
+	}
 
 	router.PathPrefix("/keys").Handler(middleware.ThenFunc(func(w http.ResponseWriter, _ *http.Request) {
 		handlers.Respond(w, http.StatusOK, handlers.JSONObject{
@@ -55,40 +67,110 @@ func initRoutes() *mux.Router {
 					"alg": "RS256",
 					"kid": "the-one-and-only",
 					"kty": "RSA",
-					"n":   base64.URLEncoding.EncodeToString(privateKey.PublicKey.N.Bytes()),
+					"n":   base64.URLEncoding.EncodeToString(rsaKey.PublicKey.N.Bytes()),
 					"e":   base64.URLEncoding.EncodeToString(eBuf)[:4],
 					"use": "sig",
 				},
+				ecJWK("ES256", es256Key),
+				ecJWK("ES384", es384Key),
+				{
+					"alg": "EdDSA",
+					"kid": "EdDSA",
+					"kty": "OKP",
+					"crv": "Ed25519",
+					"x":   base64.RawURLEncoding.EncodeToString(ed25519Public),
+					"use": "sig",
+				},
 			},
 		})
 	}))
 
+	tokens := map[string]string{
+		"RS256": signToken("the-one-and-only", jwt.SigningMethodRS256, rsaKey),
+		"ES256": signToken("ES256", jwt.SigningMethodES256, es256Key),
+		"ES384": signToken("ES384", jwt.SigningMethodES384, es384Key),
+		"EdDSA": signToken("EdDSA", identity.SigningMethodEdDSA, ed25519Key),
+	}
+
+	// The token is signed with the algorithm named by the alg query parameter,
+	// RS256 by default
+	router.PathPrefix("/token").Handler(middleware.ThenFunc(func(w http.ResponseWriter, req *http.Request) {
+		alg := req.URL.Query().Get("alg")
+		if alg == "" {
+			alg = "RS256"
+		}
+
+		signedString, ok := tokens[alg]
+		if !ok {
+			handlers.Error(req.Context(), w, "Unknown algorithm "+alg, http.StatusNotFound, nil, nil)
+			return
+		}
+
+		handlers.Respond(w, http.StatusOK, handlers.JSONObject{
+			"token": signedString,
+		})
+	}))
+
+	return router
+}
+
+func mustGenerateECKey(curve elliptic.Curve) *ecdsa.PrivateKey {
+	key, err := ecdsa.GenerateKey(curve, rand.Reader)
+	if err != nil {
+		logs.Logger.Panic("Could not generate key: ", err)
+	}
+
+	return key
+}
+
+func ecJWK(alg string, key *ecdsa.PrivateKey) handlers.JSONObject {
+	size := (key.Curve.Params().BitSize + 7) / 8
+	return handlers.JSONObject{
+		"alg": alg,
+		"kid": alg,
+		"kty": "EC",
+		"crv": key.Curve.Params().Name,
+		"x":   base64.RawURLEncoding.EncodeToString(key.X.FillBytes(make([]byte, size))),
+		"y":   base64.RawURLEncoding.EncodeToString(key.Y.FillBytes(make([]byte, size))),
+		"use": "sig",
+	}
+}
+
+// scopes grants the integration tests every scope
+var scopes = []string{
+	handlers.ScopeOctosRead, handlers.ScopeOctosWrite, handlers.ScopeOctosDelete,
+	handlers.ScopeGarbanzosRead, handlers.ScopeGarbanzosWrite, handlers.ScopeGarbanzosDelete,
+	handlers.ScopeGarbanzoTypesRead, handlers.ScopeGarbanzoTypesWrite,
+	handlers.ScopeOrgsRead, handlers.ScopeOrgsWrite, handlers.ScopeOrgsDelete,
+	handlers.ScopeAPIKeysRead, handlers.ScopeAPIKeysWrite, handlers.ScopeAPIKeysDelete,
+	handlers.ScopeAuditRead,
+}
+
+func signToken(keyId string, method jwt.SigningMethod, key interface{}) string {
 	claims := identity.CustomClaims{
-		Org: "org1",
+		Org:      "org1",
+		Audience: identity.Audience{"effective-octo-garbanzo"},
+		Scope:    strings.Join(scopes, " "),
 		StandardClaims: jwt.StandardClaims{
 			ExpiresAt: time.Now().Add(time.Hour).Unix(),
+			Issuer:    "http://auth:8081/",
+			Subject:   "integration",
 		},
 	}
 	token := &jwt.Token{
 		Header: map[string]interface{}{
-			"kid": "the-one-and-only",
-			"alg": jwt.SigningMethodRS256.Alg(),
+			"kid": keyId,
+			"alg": method.Alg(),
 		},
 		Claims: &claims,
-		Method: jwt.SigningMethodRS256,
+		Method: method,
 	}
-	signedString, err := token.SignedString(privateKey)
+	signedString, err := token.SignedString(key)
 	if err != nil {
 		logs.Logger.Panic("Could not sign token: ", err)
 	}
 
-	router.PathPrefix("/token").Handler(middleware.ThenFunc(func(w http.ResponseWriter, _ *http.Request) {
-		handlers.Respond(w, http.StatusOK, handlers.JSONObject{
-			"token": signedString,
-		})
-	}))
-
-	return router
+	return signedString
 }
 
 func listenAndServe(serverAddr, port string, router *mux.Router) {
@@ -1,23 +1,38 @@
 package main
 
 import (
+	"context"
 	"crypto/tls"
 	"fmt"
 	"net/http"
 	_ "net/http/pprof"
 	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/justinas/alice"
 	"github.com/myshkin5/effective-octo-garbanzo/identity"
 
 	"github.com/myshkin5/effective-octo-garbanzo/api/handlers"
+	"github.com/myshkin5/effective-octo-garbanzo/api/handlers/apikey"
+	"github.com/myshkin5/effective-octo-garbanzo/api/handlers/audit"
 	"github.com/myshkin5/effective-octo-garbanzo/api/handlers/garbanzo"
+	"github.com/myshkin5/effective-octo-garbanzo/api/handlers/garbanzotype"
 	"github.com/myshkin5/effective-octo-garbanzo/api/handlers/octo"
+	"github.com/myshkin5/effective-octo-garbanzo/api/handlers/org"
+	"github.com/myshkin5/effective-octo-garbanzo/api/handlers/transfer"
 	apiMiddleware "github.com/myshkin5/effective-octo-garbanzo/api/middleware"
 	"github.com/myshkin5/effective-octo-garbanzo/logs"
+	"github.com/myshkin5/effective-octo-garbanzo/metrics"
 	"github.com/myshkin5/effective-octo-garbanzo/persistence"
+	"github.com/myshkin5/effective-octo-garbanzo/persistence/memory"
 	"github.com/myshkin5/effective-octo-garbanzo/services"
+	"github.com/myshkin5/effective-octo-garbanzo/tracing"
 	"github.com/myshkin5/effective-octo-garbanzo/utils"
 )
 
@@ -26,19 +41,48 @@ func main() {
 
 	utils.InitStackTracer()
 
-	database := initDatabase()
+	tracerShutdown := initTracing()
 
-	garbanzoService := services.NewGarbanzoService(persistence.OctoStore{}, persistence.GarbanzoStore{}, database)
-	octoService := services.NewOctoService(persistence.OctoStore{}, persistence.GarbanzoStore{}, database)
+	database, stores, checks := initDatabase()
+
+	background := newBackgroundTasks()
+
+	garbanzoTypeService := initGarbanzoTypes(stores.garbanzoType, database)
+	idempotentRequests := services.NewIdempotentRequests(stores.idempotentRequest, database,
+		getEnvDuration("IDEMPOTENCY_KEY_TTL", "24h"), getEnvDuration("IDEMPOTENCY_KEY_SWEEP_INTERVAL", "1m"))
+	background.run(idempotentRequests.Run)
+	auditLog := services.NewAuditLog(stores.auditEvent, database)
+	garbanzoService := services.NewGarbanzoService(stores.octo, stores.garbanzo, garbanzoTypeService, idempotentRequests, auditLog, database)
+	octoService := services.NewOctoService(stores.octo, stores.garbanzo, idempotentRequests, auditLog, database)
+	orgService := services.NewOrgService(stores.org, database)
+	apiKeyService := services.NewAPIKeyService(stores.apiKey, stores.org, database)
+	transferService := services.NewTransferService(stores.octo, stores.garbanzo, garbanzoTypeService, auditLog, database)
+
+	validator := initValidator(background)
+	checks["keys"] = func(context.Context) error {
+		return validator.CheckKeys()
+	}
+
+	health := &handlers.Health{Checks: checks}
 
 	port := persistence.GetEnvWithDefault("PORT", "8080")
-	router := initRoutes(port, octoService, garbanzoService)
+	router := initRoutes(port, apiMiddleware.WithAPIKeys(validator, apiKeyService), health, octoService, garbanzoService, garbanzoTypeService, orgService, apiKeyService, transferService, auditLog)
 
 	serverAddr := persistence.GetEnvWithDefault("SERVER_ADDR", "localhost")
 
-	initPProf(serverAddr)
+	pprofServer := initPProf(serverAddr)
+	metricsServer := initMetrics(serverAddr)
+
+	server := &http.Server{
+		Addr:    serverAddr + ":" + port,
+		Handler: router,
+	}
+
+	shutdown := initShutdown()
+
+	go listenAndServe(server)
 
-	listenAndServe(serverAddr, port, router)
+	shutDown(shutdown, health, server, background, database, tracerShutdown, pprofServer, metricsServer)
 }
 
 func initLogging() {
@@ -48,7 +92,73 @@ func initLogging() {
 	}
 }
 
-func initDatabase() persistence.Database {
+func initTracing() func(context.Context) error {
+	tracerShutdown, err := tracing.Init(context.Background())
+	if err != nil {
+		logs.Logger.Panic("Could not initialize tracing: ", err)
+	}
+
+	return tracerShutdown
+}
+
+type stores struct {
+	octo              services.OctoStore
+	garbanzo          services.GarbanzoStore
+	garbanzoType      services.GarbanzoTypeStore
+	org               services.OrgStore
+	apiKey            services.APIKeyStore
+	idempotentRequest services.IdempotentRequestStore
+	auditEvent        services.AuditEventStore
+}
+
+// initDatabase returns the database selected by DB_BACKEND along with its
+// stores and the readiness checks for it. postgres, the backend's name before
+// DB_DRIVER was added, is still accepted for sql as long as DB_DRIVER doesn't
+// select another database.
+func initDatabase() (persistence.Database, stores, map[string]handlers.HealthCheck) {
+	backend := persistence.GetEnvWithDefault("DB_BACKEND", "sql")
+	if driver := persistence.GetEnvWithDefault("DB_DRIVER", "postgres"); backend == "postgres" && driver != "postgres" {
+		logs.Logger.Panicf("DB_BACKEND postgres conflicts with DB_DRIVER %s, use DB_BACKEND sql instead", driver)
+	}
+	switch backend {
+	case "sql", "postgres":
+		database, migrationsCheck := initSQL()
+		metrics.Registry.MustRegister(persistence.NewStatsCollector(database))
+		checks := map[string]handlers.HealthCheck{
+			"database":   database.Ping,
+			"migrations": migrationsCheck,
+		}
+		return database, stores{
+			octo:              persistence.OctoStore{},
+			garbanzo:          persistence.GarbanzoStore{},
+			garbanzoType:      persistence.GarbanzoTypeStore{},
+			org:               persistence.OrgStore{},
+			apiKey:            persistence.APIKeyStore{},
+			idempotentRequest: persistence.IdempotentRequestStore{},
+			auditEvent:        persistence.AuditEventStore{},
+		}, checks
+	case "memory":
+		logs.Logger.Warn("Using the in-memory database. All data will be lost on exit.")
+		database := memory.NewDatabase()
+		checks := map[string]handlers.HealthCheck{
+			"database": database.Ping,
+		}
+		return database, stores{
+			octo:              memory.OctoStore{},
+			garbanzo:          memory.GarbanzoStore{},
+			garbanzoType:      memory.GarbanzoTypeStore{},
+			org:               memory.OrgStore{},
+			apiKey:            memory.APIKeyStore{},
+			idempotentRequest: memory.IdempotentRequestStore{},
+			auditEvent:        memory.AuditEventStore{},
+		}, checks
+	default:
+		logs.Logger.Panicf("Unknown DB_BACKEND %s, must be sql (or postgres) or memory", backend)
+		return nil, stores{}, nil
+	}
+}
+
+func initSQL() (persistence.Database, handlers.HealthCheck) {
 	database, err := persistence.Open()
 	if err != nil {
 		logs.Logger.Panic("Could not open database: ", err)
@@ -59,15 +169,31 @@ func initDatabase() persistence.Database {
 		logs.Logger.Panic("Could not migrate database: ", err)
 	}
 
-	return database
+	version, err := persistence.LatestMigrationVersion()
+	if err != nil {
+		logs.Logger.Panic("Could not read the latest migration version: ", err)
+	}
+
+	return database, func(ctx context.Context) error {
+		return persistence.CheckMigrationVersion(ctx, database, version)
+	}
 }
 
-func initRoutes(port string, octoService *services.OctoService, garbanzoService *services.GarbanzoService) *mux.Router {
-	router := mux.NewRouter()
+func initGarbanzoTypes(garbanzoTypeStore services.GarbanzoTypeStore, database persistence.Database) *services.GarbanzoTypeService {
+	garbanzoTypeService := services.NewGarbanzoTypeService(garbanzoTypeStore, database,
+		getEnvDuration("GARBANZO_TYPE_MIN_RELOAD_INTERVAL", "10s"))
+	err := garbanzoTypeService.Load(context.Background())
+	if err != nil {
+		logs.Logger.Panic("Could not load garbanzo types: ", err)
+	}
 
-	headersHandler := apiMiddleware.StandardHeadersHandler
+	return garbanzoTypeService
+}
 
-	client := &http.Client{}
+func initValidator(background *backgroundTasks) *identity.Validator {
+	client := &http.Client{
+		Timeout: getEnvDuration("VERIFIER_KEY_TIMEOUT", "10s"),
+	}
 	verifierKeyInsecure := os.Getenv("VERIFIER_KEY_INSECURE")
 	if verifierKeyInsecure == "true" {
 		client.Transport = &http.Transport{
@@ -77,27 +203,82 @@ func initRoutes(port string, octoService *services.OctoService, garbanzoService
 
 	verifierKeyURI := os.Getenv("VERIFIER_KEY_URI")
 	publicKeys := identity.MustFetchKeys(verifierKeyURI, client)
-	validator := identity.NewValidator(publicKeys)
+	minRefreshInterval := getEnvDuration("VERIFIER_KEY_MIN_REFRESH_INTERVAL", "1m")
+	if minRefreshInterval <= 0 {
+		logs.Logger.Panicf("VERIFIER_KEY_MIN_REFRESH_INTERVAL must be positive, got %s", minRefreshInterval)
+	}
+	keySet := identity.NewKeySet(verifierKeyURI, client, publicKeys,
+		getEnvDuration("VERIFIER_KEY_REFRESH_INTERVAL", "1h"), minRefreshInterval)
+	background.run(keySet.Run)
+
+	return identity.NewValidator(keySet, identity.ValidatorConfig{
+		Issuers:    getEnvList("JWT_ISSUERS"),
+		Audiences:  getEnvList("JWT_AUDIENCES"),
+		Leeway:     getEnvDuration("JWT_LEEWAY", "0s"),
+		Algorithms: getEnvList("JWT_ALGORITHMS"),
+	})
+}
+
+func initRoutes(port string, validator apiMiddleware.Validator, health *handlers.Health, octoService *services.OctoService, garbanzoService *services.GarbanzoService, garbanzoTypeService *services.GarbanzoTypeService, orgService *services.OrgService, apiKeyService *services.APIKeyService, transferService *services.TransferService, auditLog *services.AuditLog) *mux.Router {
+	router := mux.NewRouter()
+
+	headersHandler := apiMiddleware.StandardHeadersHandler
 
 	loginURI := os.Getenv("LOGIN_URI")
 	authHandler := func(h http.Handler) http.Handler {
 		return apiMiddleware.AuthenticatedHandler(h, loginURI, validator)
 	}
 
-	middleware := alice.New(handlers.LoggingHandler, headersHandler, authHandler)
+	probeMiddleware := alice.New(apiMiddleware.MetricsHandler, apiMiddleware.RequestIDHandler, handlers.LoggingHandler, headersHandler)
+	middleware := probeMiddleware.Append(apiMiddleware.TracingHandler, authHandler)
+	if os.Getenv("AUTO_PROVISION_ORGS") == "true" {
+		middleware = middleware.Append(func(h http.Handler) http.Handler {
+			return apiMiddleware.OrgProvisioningHandler(h, orgService)
+		})
+	}
 
-	handlers.MapHealthRoutes(router, middleware)
+	adminOrg := os.Getenv("ADMIN_ORG")
+	adminHandler := func(h http.Handler) http.Handler {
+		return apiMiddleware.AdminHandler(h, adminOrg)
+	}
+	adminMiddleware := middleware.Append(adminHandler)
+
+	enforceScopes := os.Getenv("ENFORCE_SCOPES") == "true"
+	if !enforceScopes {
+		logs.Logger.Warn("Scopes are not enforced, any valid token may use every route. Set ENFORCE_SCOPES to true to enforce them.")
+	}
+	authorize := apiMiddleware.ScopeAuthorizer(enforceScopes)
+
+	requireIfMatch := func(h http.Handler) http.Handler { return h }
+	if os.Getenv("REQUIRE_IF_MATCH") == "true" {
+		requireIfMatch = apiMiddleware.IfMatchRequiredHandler
+	}
+
+	handlers.MapHealthRoutes(router, middleware, probeMiddleware, health)
 
 	baseURL := os.Getenv("BASE_URL")
 	if baseURL == "" {
 		baseURL = fmt.Sprintf("http://localhost:%v/", port)
 	}
 
-	octo.MapCollectionRoutes(baseURL, router, middleware, octoService)
-	octo.MapRoutes(baseURL, router, middleware, octoService)
+	octo.MapCollectionRoutes(baseURL, router, middleware, authorize, octoService)
+	octo.MapRoutes(baseURL, router, middleware, authorize, requireIfMatch, octoService)
+
+	garbanzo.MapCollectionRoutes(baseURL, router, middleware, authorize, garbanzoService)
+	garbanzo.MapRoutes(baseURL, router, middleware, authorize, requireIfMatch, garbanzoService)
+
+	garbanzotype.MapCollectionRoutes(baseURL, router, middleware, authorize, adminHandler, garbanzoTypeService)
+	garbanzotype.MapRoutes(baseURL, router, middleware, authorize, garbanzoTypeService)
+
+	org.MapCollectionRoutes(baseURL, router, adminMiddleware, authorize, orgService)
+	org.MapRoutes(baseURL, router, adminMiddleware, authorize, orgService)
 
-	garbanzo.MapCollectionRoutes(baseURL, router, middleware, garbanzoService)
-	garbanzo.MapRoutes(baseURL, router, middleware, garbanzoService)
+	apikey.MapCollectionRoutes(baseURL, router, adminMiddleware, authorize, apiKeyService)
+	apikey.MapRoutes(baseURL, router, adminMiddleware, authorize, apiKeyService)
+
+	transfer.MapRoutes(router, middleware, authorize, getEnvInt64("IMPORT_MAX_BYTES", "10485760"), transferService)
+
+	audit.MapRoutes(baseURL, router, middleware, authorize, auditLog)
 
 	// Must be last mapping
 	handlers.MapCatchAllRoutes(baseURL, router, middleware)
@@ -105,21 +286,182 @@ func initRoutes(port string, octoService *services.OctoService, garbanzoService
 	return router
 }
 
-func initPProf(serverAddr string) {
+func initPProf(serverAddr string) *http.Server {
 	// Typically 6060
 	pprofPort, ok := os.LookupEnv("PPROF_PORT")
-	if ok {
-		logs.Logger.Infof("PProf listening on %s:%s...", serverAddr, pprofPort)
-		go func() {
-			logs.Logger.Info(http.ListenAndServe(serverAddr+":"+pprofPort, nil))
-		}()
+	if !ok {
+		return nil
+	}
+
+	// The pprof handlers register themselves with the default serve mux
+	pprofServer := &http.Server{
+		Addr: serverAddr + ":" + pprofPort,
+	}
+
+	logs.Logger.Infof("PProf listening on %s...", pprofServer.Addr)
+	go func() {
+		logs.Logger.Info(pprofServer.ListenAndServe())
+	}()
+
+	return pprofServer
+}
+
+func initMetrics(serverAddr string) *http.Server {
+	// Typically 9090
+	metricsPort, ok := os.LookupEnv("METRICS_PORT")
+	if !ok {
+		return nil
 	}
+
+	router := http.NewServeMux()
+	router.Handle("/metrics", metrics.Handler())
+	metricsServer := &http.Server{
+		Addr:    serverAddr + ":" + metricsPort,
+		Handler: router,
+	}
+
+	logs.Logger.Infof("Metrics listening on %s...", metricsServer.Addr)
+	go func() {
+		logs.Logger.Info(metricsServer.ListenAndServe())
+	}()
+
+	return metricsServer
 }
 
-func listenAndServe(serverAddr, port string, router *mux.Router) {
-	logs.Logger.Infof("Listening on %s:%s...", serverAddr, port)
-	err := http.ListenAndServe(serverAddr+":"+port, router)
-	if err != nil {
+func listenAndServe(server *http.Server) {
+	logs.Logger.Infof("Listening on %s...", server.Addr)
+	err := server.ListenAndServe()
+	if err != nil && err != http.ErrServerClosed {
 		logs.Logger.Panic("ListenAndServe: ", err)
 	}
 }
+
+// backgroundTasks are the long-running tasks, e.g. sweeping expired
+// idempotency keys, which are stopped by shutDown before the database is
+// closed.
+type backgroundTasks struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func newBackgroundTasks() *backgroundTasks {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &backgroundTasks{ctx: ctx, cancel: cancel}
+}
+
+// run starts task in its own goroutine with a context canceled by stop.
+func (t *backgroundTasks) run(task func(ctx context.Context)) {
+	t.wg.Add(1)
+	go func() {
+		defer t.wg.Done()
+		task(t.ctx)
+	}()
+}
+
+// stop cancels the tasks and waits for them to return.
+func (t *backgroundTasks) stop() {
+	t.cancel()
+	t.wg.Wait()
+}
+
+type shutdownConfig struct {
+	signals    chan os.Signal
+	drainDelay time.Duration
+	timeout    time.Duration
+}
+
+// initShutdown reads the shutdown settings up front so a bad value fails at
+// startup rather than at shutdown. SIGQUIT is left to the stack tracer.
+func initShutdown() shutdownConfig {
+	config := shutdownConfig{
+		signals:    make(chan os.Signal, 1),
+		drainDelay: getEnvDuration("SHUTDOWN_DRAIN_DELAY", "5s"),
+		timeout:    getEnvDuration("SHUTDOWN_TIMEOUT", "30s"),
+	}
+
+	signal.Notify(config.signals, syscall.SIGTERM, syscall.SIGINT)
+
+	return config
+}
+
+// shutDown waits for SIGTERM or SIGINT then fails health checks so load
+// balancers drain the service. In-flight requests are then given until the
+// timeout to complete (committing or rolling back their transactions) and the
+// background tasks are stopped before the database and admin servers (pprof
+// and metrics) are closed and any buffered spans are flushed.
+func shutDown(config shutdownConfig, health *handlers.Health, server *http.Server, background *backgroundTasks, database persistence.Database, tracerShutdown func(context.Context) error, adminServers ...*http.Server) {
+	sig := <-config.signals
+
+	logs.Logger.Infof("Received %s, draining for %s...", sig, config.drainDelay)
+	health.ShutDown()
+	time.Sleep(config.drainDelay)
+
+	logs.Logger.Infof("Waiting up to %s for in-flight requests...", config.timeout)
+	ctx, cancel := context.WithTimeout(context.Background(), config.timeout)
+	defer cancel()
+
+	err := server.Shutdown(ctx)
+	if err != nil {
+		// Closing the connections cancels the requests' contexts which rolls
+		// back any transactions still open
+		logs.Logger.Warn("In-flight requests did not complete in time: ", err)
+		server.Close()
+	}
+
+	background.stop()
+
+	err = database.Close()
+	if err != nil {
+		logs.Logger.Warn("Could not close database: ", err)
+	}
+
+	for _, adminServer := range adminServers {
+		if adminServer != nil {
+			adminServer.Close()
+		}
+	}
+
+	flushCtx, flushCancel := context.WithTimeout(context.Background(), config.timeout)
+	defer flushCancel()
+	err = tracerShutdown(flushCtx)
+	if err != nil {
+		logs.Logger.Warn("Could not flush traces: ", err)
+	}
+
+	logs.Logger.Info("Shut down")
+}
+
+func getEnvDuration(key, defaultValue string) time.Duration {
+	value := persistence.GetEnvWithDefault(key, defaultValue)
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		logs.Logger.Panicf("Invalid %s %s: %v", key, value, err)
+	}
+
+	return duration
+}
+
+func getEnvInt64(key, defaultValue string) int64 {
+	value := persistence.GetEnvWithDefault(key, defaultValue)
+	number, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || number <= 0 {
+		logs.Logger.Panicf("Invalid %s %s, must be a positive integer", key, value)
+	}
+
+	return number
+}
+
+// getEnvList splits a comma separated environment variable, returning nil
+// when it isn't set.
+func getEnvList(key string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		value = strings.TrimSpace(value)
+		if value != "" {
+			values = append(values, value)
+		}
+	}
+
+	return values
+}
@@ -0,0 +1,23 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/myshkin5/effective-octo-garbanzo/api/handlers"
+	"github.com/myshkin5/effective-octo-garbanzo/persistence"
+)
+
+// AdminHandler only passes requests authenticated for the admin org to the
+// inner handler. No org is an admin when adminOrg is empty. Must follow
+// AuthenticatedHandler.
+func AdminHandler(h http.Handler, adminOrg string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		org, _ := r.Context().Value(persistence.OrgContextKey).(string)
+		if adminOrg == "" || org != adminOrg {
+			handlers.Error(r.Context(), w, "Admin access required", http.StatusForbidden, nil, nil)
+			return
+		}
+
+		h.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,74 @@
+package middleware_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/myshkin5/effective-octo-garbanzo/api/middleware"
+	"github.com/myshkin5/effective-octo-garbanzo/persistence"
+)
+
+var _ = Describe("Admin", func() {
+	var (
+		recorder      *httptest.ResponseRecorder
+		request       *http.Request
+		validRequests chan *http.Request
+		okFunc        http.HandlerFunc
+	)
+
+	BeforeEach(func() {
+		recorder = httptest.NewRecorder()
+		recorder.Code = 0
+
+		var err error
+		request, err = http.NewRequest("GET", "/orgs", nil)
+		Expect(err).NotTo(HaveOccurred())
+
+		validRequests = make(chan *http.Request, 100)
+
+		okFunc = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			validRequests <- r
+			w.WriteHeader(http.StatusOK)
+		})
+	})
+
+	withOrg := func(org string) {
+		request = request.WithContext(context.WithValue(request.Context(), persistence.OrgContextKey, org))
+	}
+
+	It("passes requests for the admin org to the inner handler", func() {
+		withOrg("admins")
+
+		middleware.AdminHandler(okFunc, "admins").ServeHTTP(recorder, request)
+
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(validRequests).To(Receive())
+	})
+
+	It("forbids requests for other orgs", func() {
+		withOrg("org1")
+
+		middleware.AdminHandler(okFunc, "admins").ServeHTTP(recorder, request)
+
+		Expect(recorder.Code).To(Equal(http.StatusForbidden))
+		Expect(recorder.Body).To(MatchJSON(`{
+			"code": 403,
+			"error": "Admin access required",
+			"status": "Forbidden"
+		}`))
+		Expect(validRequests).NotTo(Receive())
+	})
+
+	It("forbids all requests when there is no admin org", func() {
+		withOrg("")
+
+		middleware.AdminHandler(okFunc, "").ServeHTTP(recorder, request)
+
+		Expect(recorder.Code).To(Equal(http.StatusForbidden))
+		Expect(validRequests).NotTo(Receive())
+	})
+})
@@ -0,0 +1,32 @@
+package middleware
+
+import (
+	"context"
+
+	"github.com/myshkin5/effective-octo-garbanzo/identity"
+)
+
+// APIKeyHeader may hold an API key in place of an ApiKey Authorization header
+const APIKeyHeader = "X-API-Key"
+
+type apiKeyValidator struct {
+	tokens  Validator
+	apiKeys Validator
+}
+
+// WithAPIKeys validates auth headers with the ApiKey scheme using apiKeys and
+// every other auth header (bearer tokens) using tokens.
+func WithAPIKeys(tokens, apiKeys Validator) Validator {
+	return apiKeyValidator{
+		tokens:  tokens,
+		apiKeys: apiKeys,
+	}
+}
+
+func (v apiKeyValidator) Validate(ctx context.Context, authHeader string) (string, string, []string, error) {
+	if _, ok := identity.APIKeyFromHeader(authHeader); ok {
+		return v.apiKeys.Validate(ctx, authHeader)
+	}
+
+	return v.tokens.Validate(ctx, authHeader)
+}
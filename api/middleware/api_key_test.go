@@ -0,0 +1,56 @@
+package middleware_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/myshkin5/effective-octo-garbanzo/api/middleware"
+	"github.com/myshkin5/effective-octo-garbanzo/identity"
+)
+
+var _ = Describe("APIKey", func() {
+	var (
+		tokens    *mockValidator
+		apiKeys   *mockValidator
+		validator middleware.Validator
+		ctx       context.Context
+	)
+
+	BeforeEach(func() {
+		tokens = newMockValidator()
+		apiKeys = newMockValidator()
+		validator = middleware.WithAPIKeys(tokens, apiKeys)
+		ctx = context.Background()
+	})
+
+	It("validates API keys with the API key validator", func() {
+		apiKeys.ValidateOutput.Org <- "org1"
+		apiKeys.ValidateOutput.Subject <- "user1"
+		apiKeys.ValidateOutput.Scopes <- []string{"garbanzos:write"}
+		apiKeys.ValidateOutput.Err <- nil
+
+		org, subject, scopes, err := validator.Validate(ctx, "apikey abc.def")
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(org).To(Equal("org1"))
+		Expect(subject).To(Equal("user1"))
+		Expect(scopes).To(Equal([]string{"garbanzos:write"}))
+		Expect(apiKeys.ValidateInput.AuthHeader).To(Receive(Equal("apikey abc.def")))
+		Expect(tokens.ValidateCalled).NotTo(Receive())
+	})
+
+	It("validates everything else with the token validator", func() {
+		tokens.ValidateOutput.Org <- ""
+		tokens.ValidateOutput.Subject <- ""
+		tokens.ValidateOutput.Scopes <- nil
+		tokens.ValidateOutput.Err <- identity.ErrMissingBearer
+
+		_, _, _, err := validator.Validate(ctx, "")
+
+		Expect(err).To(Equal(identity.ErrMissingBearer))
+		Expect(tokens.ValidateInput.AuthHeader).To(Receive(Equal("")))
+		Expect(apiKeys.ValidateCalled).NotTo(Receive())
+	})
+})
@@ -2,23 +2,82 @@ package middleware
 
 import (
 	"context"
+	"fmt"
+	"mime"
 	"net/http"
+	"strings"
 
+	"github.com/myshkin5/effective-octo-garbanzo/api/handlers"
+	"github.com/myshkin5/effective-octo-garbanzo/identity"
+	"github.com/myshkin5/effective-octo-garbanzo/logs"
 	"github.com/myshkin5/effective-octo-garbanzo/persistence"
 )
 
 type Validator interface {
-	IsValid(authHeader string) (isValid bool, org string)
+	Validate(ctx context.Context, authHeader string) (org, subject string, scopes []string, err error)
 }
 
-func AuthenticatedHandler(h http.Handler, redirect string, validator Validator) http.Handler {
+// ScopesContextKey holds the scopes of the authenticated request's token.
+const ScopesContextKey = "scopes"
+
+// AuthenticatedHandler only passes requests with valid credentials (a bearer
+// token or an API key, see WithAPIKeys) to the inner handler. An API key may
+// also be sent in the X-API-Key header. Browsers (requests accepting HTML) are
+// redirected to loginURI when it isn't empty. Every other client receives
+// 401 - Unauthorized with a WWW-Authenticate header and the standard error
+// body.
+func AuthenticatedHandler(h http.Handler, loginURI string, validator Validator) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		ok, org := validator.IsValid(r.Header.Get("Authorization"))
-		if !ok {
-			http.Redirect(w, r, redirect, http.StatusTemporaryRedirect)
+		authHeader := r.Header.Get("Authorization")
+		if apiKey := r.Header.Get(APIKeyHeader); authHeader == "" && apiKey != "" {
+			authHeader = identity.APIKeyScheme + " " + apiKey
+		}
+
+		org, subject, scopes, err := validator.Validate(r.Context(), authHeader)
+		if err != nil {
+			if !identity.Rejected(err) {
+				handlers.Error(r.Context(), w, "Error authenticating request", http.StatusInternalServerError, err, nil)
+				return
+			}
+
+			if loginURI != "" && acceptsHTML(r) {
+				http.Redirect(w, r, loginURI, http.StatusTemporaryRedirect)
+				return
+			}
+
+			unauthorized(w, r, err)
 			return
 		}
 
-		h.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), persistence.OrgContextKey, org)))
+		ctx := context.WithValue(r.Context(), persistence.OrgContextKey, org)
+		ctx = context.WithValue(ctx, ScopesContextKey, scopes)
+		ctx = context.WithValue(ctx, persistence.SubjectContextKey, subject)
+		ctx = logs.WithFields(ctx, logs.Fields{"org": org})
+		h.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
+
+// unauthorized follows RFC 6750 which omits the error from the challenge when
+// no token was sent at all.
+func unauthorized(w http.ResponseWriter, r *http.Request, err error) {
+	challenge := "Bearer"
+	if err == identity.ErrInvalidAPIKey {
+		challenge = fmt.Sprintf(`%s error_description="%v"`, identity.APIKeyScheme, err)
+	} else if err != identity.ErrMissingBearer {
+		challenge = fmt.Sprintf(`Bearer error="invalid_token", error_description="%v"`, err)
+	}
+	w.Header().Set("WWW-Authenticate", challenge)
+
+	handlers.Error(r.Context(), w, fmt.Sprintf("Unauthorized, %v", err), http.StatusUnauthorized, err, nil)
+}
+
+func acceptsHTML(r *http.Request) bool {
+	for _, accepted := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(accepted))
+		if err == nil && mediaType == "text/html" {
+			return true
+		}
+	}
+
+	return false
+}
@@ -3,10 +3,12 @@ package middleware_test
 //go:generate hel
 
 import (
+	"errors"
 	"net/http"
 	"net/http/httptest"
 
 	"github.com/myshkin5/effective-octo-garbanzo/api/middleware"
+	"github.com/myshkin5/effective-octo-garbanzo/identity"
 	"github.com/myshkin5/effective-octo-garbanzo/persistence"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -42,29 +44,179 @@ var _ = Describe("Authenticated", func() {
 	})
 
 	It("passes the request to the inner handler when the validator says the auth header is valid", func() {
-		mockValidator.IsValidOutput.IsValid <- true
-		mockValidator.IsValidOutput.Org <- "org1"
+		mockValidator.ValidateOutput.Org <- "org1"
+		mockValidator.ValidateOutput.Subject <- "user1"
+		mockValidator.ValidateOutput.Scopes <- []string{"octos:read"}
+		mockValidator.ValidateOutput.Err <- nil
 
 		handler.ServeHTTP(recorder, request)
 
 		Expect(recorder.Code).To(Equal(http.StatusOK))
-		Expect(mockValidator.IsValidCalled).To(Receive())
-		Expect(mockValidator.IsValidInput.AuthHeader).To(Receive(Equal("")))
+		Expect(mockValidator.ValidateCalled).To(Receive())
+		Expect(mockValidator.ValidateInput.AuthHeader).To(Receive(Equal("")))
 		var validRequest *http.Request
 		Expect(validRequests).To(Receive(&validRequest))
 		Expect(validRequest.Context().Value(persistence.OrgContextKey)).To(Equal("org1"))
+		Expect(validRequest.Context().Value(persistence.SubjectContextKey)).To(Equal("user1"))
+		Expect(validRequest.Context().Value(middleware.ScopesContextKey)).To(Equal([]string{"octos:read"}))
+		Expect(mockValidator.ValidateInput.Ctx).To(Receive(Equal(request.Context())))
 	})
 
-	It("doesn't pass the request to the inner handler when the validator says the auth header is invalid", func() {
+	It("redirects browsers to the login URI when the validator says the auth header is invalid", func() {
 		request.Header.Add("Authorization", "bearer xyz123")
-		mockValidator.IsValidOutput.IsValid <- false
-		mockValidator.IsValidOutput.Org <- ""
+		request.Header.Add("Accept", "text/html,application/xhtml+xml,*/*;q=0.8")
+		mockValidator.ValidateOutput.Org <- ""
+		mockValidator.ValidateOutput.Subject <- ""
+		mockValidator.ValidateOutput.Scopes <- nil
+		mockValidator.ValidateOutput.Err <- identity.ErrExpiredToken
 
 		handler.ServeHTTP(recorder, request)
 
 		Expect(recorder.Code).To(Equal(http.StatusTemporaryRedirect))
 		Expect(recorder.Header().Get("Location")).To(Equal("http://auth-server/"))
-		Expect(mockValidator.IsValidCalled).To(Receive())
-		Expect(mockValidator.IsValidInput.AuthHeader).To(Receive(Equal("bearer xyz123")))
+		Expect(mockValidator.ValidateCalled).To(Receive())
+		Expect(mockValidator.ValidateInput.AuthHeader).To(Receive(Equal("bearer xyz123")))
+		Expect(validRequests).NotTo(Receive())
+	})
+
+	Context("API clients", func() {
+		BeforeEach(func() {
+			request.Header.Add("Accept", "application/json")
+		})
+
+		It("returns unauthorized when the auth header is missing", func() {
+			mockValidator.ValidateOutput.Org <- ""
+			mockValidator.ValidateOutput.Subject <- ""
+			mockValidator.ValidateOutput.Scopes <- nil
+			mockValidator.ValidateOutput.Err <- identity.ErrMissingBearer
+
+			handler.ServeHTTP(recorder, request)
+
+			Expect(recorder.Code).To(Equal(http.StatusUnauthorized))
+			Expect(recorder.Header().Get("WWW-Authenticate")).To(Equal("Bearer"))
+			Expect(recorder.Body).To(MatchJSON(`{
+				"code":   401,
+				"error":  "Unauthorized, authorization header lacks a bearer token",
+				"status": "Unauthorized"
+			}`))
+			Expect(validRequests).NotTo(Receive())
+		})
+
+		It("returns unauthorized when the token is expired", func() {
+			request.Header.Add("Authorization", "bearer xyz123")
+			mockValidator.ValidateOutput.Org <- ""
+			mockValidator.ValidateOutput.Subject <- ""
+			mockValidator.ValidateOutput.Scopes <- nil
+			mockValidator.ValidateOutput.Err <- identity.ErrExpiredToken
+
+			handler.ServeHTTP(recorder, request)
+
+			Expect(recorder.Code).To(Equal(http.StatusUnauthorized))
+			Expect(recorder.Header().Get("WWW-Authenticate")).To(Equal(
+				`Bearer error="invalid_token", error_description="token is expired"`))
+			Expect(recorder.Body).To(MatchJSON(`{
+				"code":   401,
+				"error":  "Unauthorized, token is expired",
+				"status": "Unauthorized"
+			}`))
+			Expect(validRequests).NotTo(Receive())
+		})
+
+		It("describes an unknown signing key", func() {
+			mockValidator.ValidateOutput.Org <- ""
+			mockValidator.ValidateOutput.Subject <- ""
+			mockValidator.ValidateOutput.Scopes <- nil
+			mockValidator.ValidateOutput.Err <- identity.ErrUnknownKey
+
+			handler.ServeHTTP(recorder, request)
+
+			Expect(recorder.Code).To(Equal(http.StatusUnauthorized))
+			Expect(recorder.Header().Get("WWW-Authenticate")).To(ContainSubstring(
+				`error_description="token signing key is unknown"`))
+		})
+
+		It("describes an invalid signature", func() {
+			mockValidator.ValidateOutput.Org <- ""
+			mockValidator.ValidateOutput.Subject <- ""
+			mockValidator.ValidateOutput.Scopes <- nil
+			mockValidator.ValidateOutput.Err <- identity.ErrInvalidSignature
+
+			handler.ServeHTTP(recorder, request)
+
+			Expect(recorder.Code).To(Equal(http.StatusUnauthorized))
+			Expect(recorder.Header().Get("WWW-Authenticate")).To(ContainSubstring(
+				`error_description="token signature is invalid"`))
+		})
+
+		It("passes API keys sent in the X-API-Key header as ApiKey auth headers", func() {
+			request.Header.Add("X-API-Key", "abc.def")
+			mockValidator.ValidateOutput.Org <- "org1"
+			mockValidator.ValidateOutput.Subject <- "user1"
+			mockValidator.ValidateOutput.Scopes <- nil
+			mockValidator.ValidateOutput.Err <- nil
+
+			handler.ServeHTTP(recorder, request)
+
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(mockValidator.ValidateInput.AuthHeader).To(Receive(Equal("ApiKey abc.def")))
+		})
+
+		It("prefers the auth header to the X-API-Key header", func() {
+			request.Header.Add("Authorization", "bearer xyz123")
+			request.Header.Add("X-API-Key", "abc.def")
+			mockValidator.ValidateOutput.Org <- "org1"
+			mockValidator.ValidateOutput.Subject <- "user1"
+			mockValidator.ValidateOutput.Scopes <- nil
+			mockValidator.ValidateOutput.Err <- nil
+
+			handler.ServeHTTP(recorder, request)
+
+			Expect(mockValidator.ValidateInput.AuthHeader).To(Receive(Equal("bearer xyz123")))
+		})
+
+		It("challenges invalid API keys with the ApiKey scheme", func() {
+			request.Header.Add("X-API-Key", "abc.def")
+			mockValidator.ValidateOutput.Org <- ""
+			mockValidator.ValidateOutput.Subject <- ""
+			mockValidator.ValidateOutput.Scopes <- nil
+			mockValidator.ValidateOutput.Err <- identity.ErrInvalidAPIKey
+
+			handler.ServeHTTP(recorder, request)
+
+			Expect(recorder.Code).To(Equal(http.StatusUnauthorized))
+			Expect(recorder.Header().Get("WWW-Authenticate")).To(Equal(`ApiKey error_description="API key is invalid"`))
+		})
+
+		It("returns an internal server error when the credentials can't be checked", func() {
+			request.Header.Add("X-API-Key", "abc.def")
+			mockValidator.ValidateOutput.Org <- ""
+			mockValidator.ValidateOutput.Subject <- ""
+			mockValidator.ValidateOutput.Scopes <- nil
+			mockValidator.ValidateOutput.Err <- errors.New("connection refused")
+
+			handler.ServeHTTP(recorder, request)
+
+			Expect(recorder.Code).To(Equal(http.StatusInternalServerError))
+			Expect(recorder.Header().Get("WWW-Authenticate")).To(BeEmpty())
+			Expect(recorder.Body).To(MatchJSON(`{
+				"code":   500,
+				"error":  "Error authenticating request",
+				"status": "Internal Server Error"
+			}`))
+			Expect(validRequests).NotTo(Receive())
+		})
+	})
+
+	It("returns unauthorized to browsers when there is no login URI", func() {
+		handler = middleware.AuthenticatedHandler(handler, "", mockValidator)
+		request.Header.Add("Accept", "text/html")
+		mockValidator.ValidateOutput.Org <- ""
+		mockValidator.ValidateOutput.Subject <- ""
+		mockValidator.ValidateOutput.Scopes <- nil
+		mockValidator.ValidateOutput.Err <- identity.ErrExpiredToken
+
+		handler.ServeHTTP(recorder, request)
+
+		Expect(recorder.Code).To(Equal(http.StatusUnauthorized))
 	})
 })
@@ -5,27 +5,62 @@
 
 package middleware_test
 
+import "context"
+
+type mockOrgProvisioner struct {
+	ProvisionCalled chan bool
+	ProvisionInput  struct {
+		Ctx  chan context.Context
+		Name chan string
+	}
+	ProvisionOutput struct {
+		Err chan error
+	}
+}
+
+func newMockOrgProvisioner() *mockOrgProvisioner {
+	m := &mockOrgProvisioner{}
+	m.ProvisionCalled = make(chan bool, 100)
+	m.ProvisionInput.Ctx = make(chan context.Context, 100)
+	m.ProvisionInput.Name = make(chan string, 100)
+	m.ProvisionOutput.Err = make(chan error, 100)
+	return m
+}
+func (m *mockOrgProvisioner) Provision(ctx context.Context, name string) (err error) {
+	m.ProvisionCalled <- true
+	m.ProvisionInput.Ctx <- ctx
+	m.ProvisionInput.Name <- name
+	return <-m.ProvisionOutput.Err
+}
+
 type mockValidator struct {
-	IsValidCalled chan bool
-	IsValidInput  struct {
+	ValidateCalled chan bool
+	ValidateInput  struct {
+		Ctx        chan context.Context
 		AuthHeader chan string
 	}
-	IsValidOutput struct {
-		IsValid chan bool
+	ValidateOutput struct {
 		Org     chan string
+		Subject chan string
+		Scopes  chan []string
+		Err     chan error
 	}
 }
 
 func newMockValidator() *mockValidator {
 	m := &mockValidator{}
-	m.IsValidCalled = make(chan bool, 100)
-	m.IsValidInput.AuthHeader = make(chan string, 100)
-	m.IsValidOutput.IsValid = make(chan bool, 100)
-	m.IsValidOutput.Org = make(chan string, 100)
+	m.ValidateCalled = make(chan bool, 100)
+	m.ValidateInput.Ctx = make(chan context.Context, 100)
+	m.ValidateInput.AuthHeader = make(chan string, 100)
+	m.ValidateOutput.Org = make(chan string, 100)
+	m.ValidateOutput.Subject = make(chan string, 100)
+	m.ValidateOutput.Scopes = make(chan []string, 100)
+	m.ValidateOutput.Err = make(chan error, 100)
 	return m
 }
-func (m *mockValidator) IsValid(authHeader string) (isValid bool, org string) {
-	m.IsValidCalled <- true
-	m.IsValidInput.AuthHeader <- authHeader
-	return <-m.IsValidOutput.IsValid, <-m.IsValidOutput.Org
+func (m *mockValidator) Validate(ctx context.Context, authHeader string) (org, subject string, scopes []string, err error) {
+	m.ValidateCalled <- true
+	m.ValidateInput.Ctx <- ctx
+	m.ValidateInput.AuthHeader <- authHeader
+	return <-m.ValidateOutput.Org, <-m.ValidateOutput.Subject, <-m.ValidateOutput.Scopes, <-m.ValidateOutput.Err
 }
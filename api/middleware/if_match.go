@@ -0,0 +1,21 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/myshkin5/effective-octo-garbanzo/api/handlers"
+)
+
+// IfMatchRequiredHandler only passes requests with an If-Match header to the
+// inner handler so that updates and deletes can't clobber changes the client
+// hasn't seen.
+func IfMatchRequiredHandler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-Match") == "" {
+			handlers.Error(r.Context(), w, "If-Match header required", http.StatusPreconditionRequired, nil, nil)
+			return
+		}
+
+		h.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,57 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/myshkin5/effective-octo-garbanzo/api/middleware"
+)
+
+var _ = Describe("IfMatchRequired", func() {
+	var (
+		recorder      *httptest.ResponseRecorder
+		request       *http.Request
+		validRequests chan *http.Request
+		handler       http.Handler
+	)
+
+	BeforeEach(func() {
+		recorder = httptest.NewRecorder()
+		recorder.Code = 0
+
+		var err error
+		request, err = http.NewRequest("PUT", "/octos/kraken", nil)
+		Expect(err).NotTo(HaveOccurred())
+
+		validRequests = make(chan *http.Request, 100)
+
+		handler = middleware.IfMatchRequiredHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			validRequests <- r
+			w.WriteHeader(http.StatusOK)
+		}))
+	})
+
+	It("passes requests with an If-Match header to the inner handler", func() {
+		request.Header.Set("If-Match", `"3"`)
+
+		handler.ServeHTTP(recorder, request)
+
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(validRequests).To(Receive())
+	})
+
+	It("requires an If-Match header", func() {
+		handler.ServeHTTP(recorder, request)
+
+		Expect(recorder.Code).To(Equal(http.StatusPreconditionRequired))
+		Expect(recorder.Body).To(MatchJSON(`{
+			"code": 428,
+			"error": "If-Match header required",
+			"status": "Precondition Required"
+		}`))
+		Expect(validRequests).NotTo(Receive())
+	})
+})
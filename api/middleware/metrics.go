@@ -0,0 +1,68 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/myshkin5/effective-octo-garbanzo/metrics"
+)
+
+// MetricsHandler records the count and latency of requests by route template
+// (not the request path, which would label every octo separately), method and
+// response status. Place it first so every other handler is measured.
+func MetricsHandler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		sw := &statusWriter{innerWriter: w, status: http.StatusOK}
+		h.ServeHTTP(sw, r)
+
+		labels := []string{routeTemplate(r), r.Method, strconv.Itoa(sw.status)}
+		metrics.HTTPRequests.WithLabelValues(labels...).Inc()
+		metrics.HTTPRequestDuration.WithLabelValues(labels...).Observe(time.Since(start).Seconds())
+	})
+}
+
+func routeTemplate(r *http.Request) string {
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return "unknown"
+	}
+
+	template, err := route.GetPathTemplate()
+	if err != nil {
+		return "unknown"
+	}
+
+	return template
+}
+
+type statusWriter struct {
+	innerWriter http.ResponseWriter
+	status      int
+}
+
+func (w *statusWriter) Header() http.Header {
+	return w.innerWriter.Header()
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	return w.innerWriter.Write(b)
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	w.status = code
+	w.innerWriter.WriteHeader(code)
+}
+
+// Flush lets streamed responses, e.g. an export, reach the client as they
+// are written.
+func (w *statusWriter) Flush() {
+	flusher, ok := w.innerWriter.(http.Flusher)
+	if ok {
+		flusher.Flush()
+	}
+}
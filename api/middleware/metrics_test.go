@@ -0,0 +1,86 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/gorilla/mux"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/myshkin5/effective-octo-garbanzo/api/middleware"
+	"github.com/myshkin5/effective-octo-garbanzo/metrics"
+)
+
+var _ = Describe("Metrics", func() {
+	var (
+		recorder *httptest.ResponseRecorder
+		router   *mux.Router
+		status   int
+	)
+
+	requests := func(route, method, status string) float64 {
+		return testutil.ToFloat64(metrics.HTTPRequests.WithLabelValues(route, method, status))
+	}
+
+	serve := func(method, path string) {
+		request, err := http.NewRequest(method, path, nil)
+		Expect(err).NotTo(HaveOccurred())
+
+		router.ServeHTTP(recorder, request)
+	}
+
+	BeforeEach(func() {
+		recorder = httptest.NewRecorder()
+		recorder.Code = 0
+
+		status = http.StatusOK
+		router = mux.NewRouter()
+		router.Path("/octos/{octoName}").Handler(middleware.MetricsHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(status)
+		})))
+	})
+
+	It("counts requests by route template rather than path", func() {
+		before := requests("/octos/{octoName}", http.MethodGet, "200")
+
+		serve(http.MethodGet, "/octos/kraken")
+		serve(http.MethodGet, "/octos/squid")
+
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(requests("/octos/{octoName}", http.MethodGet, "200")).To(Equal(before + 2))
+	})
+
+	It("counts requests by method and status", func() {
+		before := requests("/octos/{octoName}", http.MethodDelete, "404")
+
+		status = http.StatusNotFound
+		serve(http.MethodDelete, "/octos/kraken")
+
+		Expect(recorder.Code).To(Equal(http.StatusNotFound))
+		Expect(requests("/octos/{octoName}", http.MethodDelete, "404")).To(Equal(before + 1))
+	})
+
+	It("flushes the response", func() {
+		router.Path("/streamed").Handler(middleware.MetricsHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+			flusher, ok := w.(http.Flusher)
+			Expect(ok).To(BeTrue())
+			flusher.Flush()
+		})))
+
+		serve(http.MethodGet, "/streamed")
+
+		Expect(recorder.Flushed).To(BeTrue())
+	})
+
+	It("assumes an ok status when the header isn't written", func() {
+		router.Path("/quiet").Handler(middleware.MetricsHandler(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})))
+		before := requests("/quiet", http.MethodGet, "200")
+
+		serve(http.MethodGet, "/quiet")
+
+		Expect(requests("/quiet", http.MethodGet, "200")).To(Equal(before + 1))
+	})
+})
@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/myshkin5/effective-octo-garbanzo/api/handlers"
+	"github.com/myshkin5/effective-octo-garbanzo/persistence"
+	"github.com/myshkin5/effective-octo-garbanzo/services"
+)
+
+type OrgProvisioner interface {
+	Provision(ctx context.Context, name string) (err error)
+}
+
+// OrgProvisioningHandler creates the org of an authenticated request the first
+// time the org is seen. Orgs already provisioned are remembered so an org
+// deleted afterwards is only provisioned again after a restart. Requests for
+// an org whose name isn't valid are forbidden as it can never be provisioned.
+// Must follow AuthenticatedHandler.
+func OrgProvisioningHandler(h http.Handler, provisioner OrgProvisioner) http.Handler {
+	var provisioned sync.Map
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		org, _ := r.Context().Value(persistence.OrgContextKey).(string)
+		if _, ok := provisioned.Load(org); !ok {
+			err := provisioner.Provision(r.Context(), org)
+			if validationErr, ok := err.(services.ValidationError); ok {
+				message := fmt.Sprintf("Org %s can't be provisioned, its name %s",
+					org, strings.Join(validationErr.Errors()["Name"], " and "))
+				handlers.Error(r.Context(), w, message, http.StatusForbidden, nil, nil)
+				return
+			} else if err != nil {
+				handlers.Error(r.Context(), w, "Error provisioning org", http.StatusInternalServerError, err, nil)
+				return
+			}
+			provisioned.Store(org, true)
+		}
+
+		h.ServeHTTP(w, r)
+	})
+}
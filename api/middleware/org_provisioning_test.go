@@ -0,0 +1,106 @@
+package middleware_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/myshkin5/effective-octo-garbanzo/api/middleware"
+	"github.com/myshkin5/effective-octo-garbanzo/persistence"
+	"github.com/myshkin5/effective-octo-garbanzo/services"
+)
+
+var _ = Describe("OrgProvisioning", func() {
+	var (
+		recorder        *httptest.ResponseRecorder
+		mockProvisioner *mockOrgProvisioner
+		validRequests   chan *http.Request
+		handler         http.Handler
+	)
+
+	BeforeEach(func() {
+		recorder = httptest.NewRecorder()
+		recorder.Code = 0
+
+		mockProvisioner = newMockOrgProvisioner()
+
+		validRequests = make(chan *http.Request, 100)
+
+		okFunc := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			validRequests <- r
+			w.WriteHeader(http.StatusOK)
+		})
+
+		handler = middleware.OrgProvisioningHandler(okFunc, mockProvisioner)
+	})
+
+	requestFor := func(org string) *http.Request {
+		request, err := http.NewRequest("GET", "/octos", nil)
+		Expect(err).NotTo(HaveOccurred())
+		return request.WithContext(context.WithValue(request.Context(), persistence.OrgContextKey, org))
+	}
+
+	It("provisions an org the first time it is seen", func() {
+		mockProvisioner.ProvisionOutput.Err <- nil
+
+		handler.ServeHTTP(recorder, requestFor("org1"))
+
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(mockProvisioner.ProvisionInput.Name).To(Receive(Equal("org1")))
+		Expect(validRequests).To(Receive())
+	})
+
+	It("only provisions each org once", func() {
+		mockProvisioner.ProvisionOutput.Err <- nil
+		mockProvisioner.ProvisionOutput.Err <- nil
+
+		handler.ServeHTTP(httptest.NewRecorder(), requestFor("org1"))
+		handler.ServeHTTP(httptest.NewRecorder(), requestFor("org1"))
+		handler.ServeHTTP(httptest.NewRecorder(), requestFor("org2"))
+
+		Expect(mockProvisioner.ProvisionCalled).To(HaveLen(2))
+		Expect(mockProvisioner.ProvisionInput.Name).To(Receive(Equal("org1")))
+		Expect(mockProvisioner.ProvisionInput.Name).To(Receive(Equal("org2")))
+		Expect(validRequests).To(HaveLen(3))
+	})
+
+	It("tries again after a failure to provision", func() {
+		mockProvisioner.ProvisionOutput.Err <- errors.New("db down")
+		mockProvisioner.ProvisionOutput.Err <- nil
+
+		handler.ServeHTTP(recorder, requestFor("org1"))
+
+		Expect(recorder.Code).To(Equal(http.StatusInternalServerError))
+		Expect(recorder.Body).To(MatchJSON(`{
+			"code": 500,
+			"error": "Error provisioning org",
+			"status": "Internal Server Error"
+		}`))
+		Expect(validRequests).NotTo(Receive())
+
+		handler.ServeHTTP(httptest.NewRecorder(), requestFor("org1"))
+
+		Expect(mockProvisioner.ProvisionCalled).To(HaveLen(2))
+		Expect(validRequests).To(Receive())
+	})
+
+	It("forbids requests for an org which can't be provisioned", func() {
+		mockProvisioner.ProvisionOutput.Err <- services.NewValidationError(map[string][]string{
+			"Name": {`must match regular expression '^[\w-]+$'`},
+		})
+
+		handler.ServeHTTP(recorder, requestFor("acme.com"))
+
+		Expect(recorder.Code).To(Equal(http.StatusForbidden))
+		Expect(recorder.Body).To(MatchJSON(`{
+			"code": 403,
+			"error": "Org acme.com can't be provisioned, its name must match regular expression '^[\\w-]+$'",
+			"status": "Forbidden"
+		}`))
+		Expect(validRequests).NotTo(Receive())
+	})
+})
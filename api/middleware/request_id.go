@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/satori/go.uuid"
+
+	"github.com/myshkin5/effective-octo-garbanzo/logs"
+	"github.com/myshkin5/effective-octo-garbanzo/persistence"
+)
+
+const (
+	RequestIDHeader = "X-Request-ID"
+
+	maxRequestIDLength = 128
+)
+
+// RequestIDHandler identifies each request by the X-Request-ID header sent by
+// the client (or a proxy), or by a generated UUID when there isn't a usable
+// one. The id is echoed in the response, recorded with audit events and is
+// logged along with the route and method by loggers from logs.FromContext.
+// Must precede LoggingHandler.
+func RequestIDHandler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(RequestIDHeader)
+		if !validRequestID(requestID) {
+			requestID = uuid.NewV4().String()
+		}
+
+		w.Header().Set(RequestIDHeader, requestID)
+
+		ctx := context.WithValue(r.Context(), persistence.RequestIDContextKey, requestID)
+		ctx = logs.WithFields(ctx, logs.Fields{
+			"request_id": requestID,
+			"route":      routeTemplate(r),
+			"method":     r.Method,
+		})
+		h.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// validRequestID keeps arbitrary client data out of the logs and responses by
+// only accepting reasonably sized ids of printable ASCII.
+func validRequestID(requestID string) bool {
+	if len(requestID) == 0 || len(requestID) > maxRequestIDLength {
+		return false
+	}
+
+	for _, c := range requestID {
+		if c < '!' || c > '~' {
+			return false
+		}
+	}
+
+	return true
+}
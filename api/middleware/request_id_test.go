@@ -0,0 +1,93 @@
+package middleware_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/gorilla/mux"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/satori/go.uuid"
+	"github.com/sirupsen/logrus"
+
+	"github.com/myshkin5/effective-octo-garbanzo/api/middleware"
+	"github.com/myshkin5/effective-octo-garbanzo/logs"
+	"github.com/myshkin5/effective-octo-garbanzo/persistence"
+)
+
+var _ = Describe("RequestID", func() {
+	var (
+		recorder *httptest.ResponseRecorder
+		request  *http.Request
+		router   *mux.Router
+		logLine  map[string]interface{}
+		ctx      context.Context
+	)
+
+	BeforeEach(func() {
+		recorder = httptest.NewRecorder()
+		recorder.Code = 0
+
+		logLine = nil
+		router = mux.NewRouter()
+		router.Path("/octos/{octoName}").Handler(middleware.RequestIDHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx = r.Context()
+			formatter := &logs.JSONFormatter{}
+			b, err := formatter.Format(logrus.WithContext(r.Context()))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(json.Unmarshal(b, &logLine)).To(Succeed())
+
+			w.WriteHeader(http.StatusOK)
+		})))
+
+		var err error
+		request, err = http.NewRequest(http.MethodPatch, "/octos/kraken", nil)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("uses the request id sent by the client", func() {
+		request.Header.Set("X-Request-ID", "abc-123")
+
+		router.ServeHTTP(recorder, request)
+
+		Expect(recorder.Header().Get("X-Request-ID")).To(Equal("abc-123"))
+		Expect(logLine["request_id"]).To(Equal("abc-123"))
+		Expect(ctx.Value(persistence.RequestIDContextKey)).To(Equal("abc-123"))
+	})
+
+	It("logs the route template and method", func() {
+		router.ServeHTTP(recorder, request)
+
+		Expect(logLine["route"]).To(Equal("/octos/{octoName}"))
+		Expect(logLine["method"]).To(Equal(http.MethodPatch))
+	})
+
+	It("generates a request id when the client doesn't send one", func() {
+		router.ServeHTTP(recorder, request)
+
+		requestID := recorder.Header().Get("X-Request-ID")
+		_, err := uuid.FromString(requestID)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(logLine["request_id"]).To(Equal(requestID))
+	})
+
+	It("replaces a request id that is too long", func() {
+		request.Header.Set("X-Request-ID", strings.Repeat("a", 129))
+
+		router.ServeHTTP(recorder, request)
+
+		Expect(recorder.Header().Get("X-Request-ID")).To(HaveLen(36))
+	})
+
+	It("replaces a request id with unprintable characters", func() {
+		request.Header.Set("X-Request-ID", "abc 123")
+
+		router.ServeHTTP(recorder, request)
+
+		Expect(recorder.Header().Get("X-Request-ID")).NotTo(Equal("abc 123"))
+		Expect(recorder.Header().Get("X-Request-ID")).To(HaveLen(36))
+	})
+})
@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/justinas/alice"
+
+	"github.com/myshkin5/effective-octo-garbanzo/api/handlers"
+)
+
+// ScopeAuthorizer returns the Authorizer of the routes. Scopes are only
+// required when enforce is true so that tokens issued without scopes keep
+// working until enforcement is opted into.
+func ScopeAuthorizer(enforce bool) handlers.Authorizer {
+	if !enforce {
+		return func(string) alice.Constructor {
+			return func(h http.Handler) http.Handler { return h }
+		}
+	}
+
+	return func(scope string) alice.Constructor {
+		return func(h http.Handler) http.Handler {
+			return ScopeHandler(h, scope)
+		}
+	}
+}
+
+// ScopeHandler only passes requests whose token grants scope to the inner
+// handler. Must follow AuthenticatedHandler.
+func ScopeHandler(h http.Handler, scope string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		scopes, _ := r.Context().Value(ScopesContextKey).([]string)
+		for _, granted := range scopes {
+			if granted == scope {
+				h.ServeHTTP(w, r)
+				return
+			}
+		}
+
+		handlers.Error(r.Context(), w, fmt.Sprintf("Scope %s required", scope), http.StatusForbidden, nil, nil)
+	})
+}
@@ -0,0 +1,87 @@
+package middleware_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/myshkin5/effective-octo-garbanzo/api/middleware"
+)
+
+var _ = Describe("Scope", func() {
+	var (
+		recorder      *httptest.ResponseRecorder
+		request       *http.Request
+		validRequests chan *http.Request
+		okFunc        http.HandlerFunc
+	)
+
+	BeforeEach(func() {
+		recorder = httptest.NewRecorder()
+		recorder.Code = 0
+
+		var err error
+		request, err = http.NewRequest("GET", "/octos", nil)
+		Expect(err).NotTo(HaveOccurred())
+
+		validRequests = make(chan *http.Request, 100)
+
+		okFunc = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			validRequests <- r
+			w.WriteHeader(http.StatusOK)
+		})
+	})
+
+	withScopes := func(scopes ...string) {
+		request = request.WithContext(context.WithValue(request.Context(), middleware.ScopesContextKey, scopes))
+	}
+
+	It("passes requests granted the scope to the inner handler", func() {
+		withScopes("garbanzos:read", "octos:read")
+
+		middleware.ScopeHandler(okFunc, "octos:read").ServeHTTP(recorder, request)
+
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(validRequests).To(Receive())
+	})
+
+	It("forbids requests not granted the scope", func() {
+		withScopes("octos:read")
+
+		middleware.ScopeHandler(okFunc, "octos:write").ServeHTTP(recorder, request)
+
+		Expect(recorder.Code).To(Equal(http.StatusForbidden))
+		Expect(recorder.Body).To(MatchJSON(`{
+			"code": 403,
+			"error": "Scope octos:write required",
+			"status": "Forbidden"
+		}`))
+		Expect(validRequests).NotTo(Receive())
+	})
+
+	It("forbids requests without any scopes", func() {
+		middleware.ScopeHandler(okFunc, "octos:read").ServeHTTP(recorder, request)
+
+		Expect(recorder.Code).To(Equal(http.StatusForbidden))
+		Expect(validRequests).NotTo(Receive())
+	})
+
+	Describe("ScopeAuthorizer", func() {
+		It("doesn't require scopes unless enforced, as by default", func() {
+			middleware.ScopeAuthorizer(false)("octos:read")(okFunc).ServeHTTP(recorder, request)
+
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(validRequests).To(Receive())
+		})
+
+		It("requires the scope when enforced", func() {
+			middleware.ScopeAuthorizer(true)("octos:read")(okFunc).ServeHTTP(recorder, request)
+
+			Expect(recorder.Code).To(Equal(http.StatusForbidden))
+			Expect(validRequests).NotTo(Receive())
+		})
+	})
+})
@@ -31,7 +31,8 @@ func (w *hijackedWriter) Write(b []byte) (int, error) {
 }
 
 func (w *hijackedWriter) WriteHeader(code int) {
-	if code != http.StatusNoContent {
+	// Responses that aren't JSON, e.g. an export as CSV, set their own type
+	if code != http.StatusNoContent && code != http.StatusNotModified && w.Header().Get("Content-Type") == "" {
 		w.Header().Set("Content-Type", "application/json")
 	}
 
@@ -39,3 +40,14 @@ func (w *hijackedWriter) WriteHeader(code int) {
 
 	w.wroteHeader = true
 }
+
+func (w *hijackedWriter) Flush() {
+	if !w.wroteHeader {
+		logs.Logger.Panic("Call WriteHeader() prior to calling Flush(), 200 - Ok is not assumed")
+	}
+
+	flusher, ok := w.innerWriter.(http.Flusher)
+	if ok {
+		flusher.Flush()
+	}
+}
@@ -42,6 +42,21 @@ var _ = Describe("StandardHeaders", func() {
 		})
 	})
 
+	Context("content type set by the handler", func() {
+		BeforeEach(func() {
+			handler = middleware.StandardHeadersHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/csv")
+				w.WriteHeader(http.StatusOK)
+			}))
+		})
+
+		It("keeps the handler's content type", func() {
+			handler.ServeHTTP(recorder, request)
+
+			Expect(recorder.Header().Get("Content-Type")).To(Equal("text/csv"))
+		})
+	})
+
 	Context("no content status code", func() {
 		BeforeEach(func() {
 			handler = middleware.StandardHeadersHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -58,6 +73,22 @@ var _ = Describe("StandardHeaders", func() {
 		})
 	})
 
+	Context("not modified status code", func() {
+		BeforeEach(func() {
+			handler = middleware.StandardHeadersHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNotModified)
+			}))
+		})
+
+		It("does not add the standard header", func() {
+			handler.ServeHTTP(recorder, request)
+
+			Expect(recorder.Header().Get("Content-Type")).To(Equal(""))
+
+			Expect(recorder.Code).To(Equal(http.StatusNotModified))
+		})
+	})
+
 	Describe("writes data when the header has been written", func() {
 		BeforeEach(func() {
 			handler = middleware.StandardHeadersHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -72,4 +103,21 @@ var _ = Describe("StandardHeaders", func() {
 			Expect(recorder.Body).To(MatchJSON(`{ "heres": "some-json" }`))
 		})
 	})
+
+	Describe("flushes when the header has been written", func() {
+		BeforeEach(func() {
+			handler = middleware.StandardHeadersHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+				flusher, ok := w.(http.Flusher)
+				Expect(ok).To(BeTrue())
+				flusher.Flush()
+			}))
+		})
+
+		It("flushes the response", func() {
+			handler.ServeHTTP(recorder, request)
+
+			Expect(recorder.Flushed).To(BeTrue())
+		})
+	})
 })
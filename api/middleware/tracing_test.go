@@ -0,0 +1,116 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/gorilla/mux"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+
+	"github.com/myshkin5/effective-octo-garbanzo/api/middleware"
+)
+
+var _ = Describe("Tracing", func() {
+	var (
+		recorder     *httptest.ResponseRecorder
+		request      *http.Request
+		router       *mux.Router
+		spans        *tracetest.SpanRecorder
+		status       int
+		innerContext trace.SpanContext
+	)
+
+	BeforeEach(func() {
+		spans = tracetest.NewSpanRecorder()
+		otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans)))
+		otel.SetTextMapPropagator(propagation.TraceContext{})
+
+		recorder = httptest.NewRecorder()
+		recorder.Code = 0
+
+		status = http.StatusOK
+		router = mux.NewRouter()
+		router.Path("/octos/{octoName}").Handler(middleware.TracingHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			innerContext = trace.SpanContextFromContext(r.Context())
+			w.WriteHeader(status)
+		})))
+
+		var err error
+		request, err = http.NewRequest(http.MethodGet, "/octos/kraken", nil)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		otel.SetTracerProvider(noop.NewTracerProvider())
+	})
+
+	It("starts a server span named for the route template", func() {
+		router.ServeHTTP(recorder, request)
+
+		Expect(spans.Ended()).To(HaveLen(1))
+		span := spans.Ended()[0]
+		Expect(span.Name()).To(Equal("GET /octos/{octoName}"))
+		Expect(span.SpanKind()).To(Equal(trace.SpanKindServer))
+		Expect(span.Attributes()).To(ContainElement(attribute.String("url.path", "/octos/kraken")))
+		Expect(span.Attributes()).To(ContainElement(attribute.Int("http.response.status_code", http.StatusOK)))
+		Expect(span.Status().Code).To(Equal(codes.Unset))
+	})
+
+	It("passes the span to the inner handler", func() {
+		router.ServeHTTP(recorder, request)
+
+		Expect(innerContext.SpanID()).To(Equal(spans.Ended()[0].SpanContext().SpanID()))
+	})
+
+	It("continues the trace from the traceparent header", func() {
+		request.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+		router.ServeHTTP(recorder, request)
+
+		span := spans.Ended()[0]
+		Expect(span.SpanContext().TraceID().String()).To(Equal("4bf92f3577b34da6a3ce929d0e0e4736"))
+		Expect(span.Parent().SpanID().String()).To(Equal("00f067aa0ba902b7"))
+		Expect(span.Parent().IsRemote()).To(BeTrue())
+	})
+
+	It("marks server errors as failed", func() {
+		status = http.StatusInternalServerError
+
+		router.ServeHTTP(recorder, request)
+
+		Expect(spans.Ended()[0].Status().Code).To(Equal(codes.Error))
+	})
+
+	It("doesn't mark client errors as failed", func() {
+		status = http.StatusNotFound
+
+		router.ServeHTTP(recorder, request)
+
+		Expect(spans.Ended()[0].Status().Code).To(Equal(codes.Unset))
+	})
+
+	It("flushes the response", func() {
+		router.Path("/streamed").Handler(middleware.TracingHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+			flusher, ok := w.(http.Flusher)
+			Expect(ok).To(BeTrue())
+			flusher.Flush()
+		})))
+		var err error
+		request, err = http.NewRequest(http.MethodGet, "/streamed", nil)
+		Expect(err).NotTo(HaveOccurred())
+
+		router.ServeHTTP(recorder, request)
+
+		Expect(recorder.Flushed).To(BeTrue())
+	})
+})
@@ -0,0 +1,55 @@
+package identity
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+)
+
+// APIKeyScheme is the Authorization header scheme of API keys, eg:
+// Authorization: ApiKey 0123456789ab.secret
+const APIKeyScheme = "ApiKey"
+
+var ErrInvalidAPIKey = errors.New("API key is invalid")
+
+const (
+	apiKeyPrefixBytes = 6
+	apiKeySecretBytes = 32
+)
+
+// NewAPIKey returns a random API key along with its prefix, which identifies
+// the key without revealing it, and the hash the key is stored as. The key
+// itself is never stored.
+func NewAPIKey() (key, prefix, hash string, err error) {
+	random := make([]byte, apiKeyPrefixBytes+apiKeySecretBytes)
+	_, err = rand.Read(random)
+	if err != nil {
+		return "", "", "", err
+	}
+
+	prefix = hex.EncodeToString(random[:apiKeyPrefixBytes])
+	key = prefix + "." + base64.RawURLEncoding.EncodeToString(random[apiKeyPrefixBytes:])
+
+	return key, prefix, HashAPIKey(key), nil
+}
+
+// HashAPIKey returns the hash of key. A fast unsalted hash suffices as keys
+// are random and long enough to make guessing infeasible.
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// APIKeyFromHeader returns the API key of an auth header using the ApiKey
+// scheme (in any case).
+func APIKeyFromHeader(authHeader string) (string, bool) {
+	schemePrefix := APIKeyScheme + " "
+	if len(authHeader) <= len(schemePrefix) || !strings.EqualFold(authHeader[:len(schemePrefix)], schemePrefix) {
+		return "", false
+	}
+
+	return authHeader[len(schemePrefix):], true
+}
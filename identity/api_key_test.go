@@ -0,0 +1,62 @@
+package identity_test
+
+import (
+	"errors"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/myshkin5/effective-octo-garbanzo/identity"
+)
+
+var _ = Describe("APIKey", func() {
+	It("creates random keys starting with their prefix", func() {
+		key1, prefix1, hash1, err := identity.NewAPIKey()
+		Expect(err).NotTo(HaveOccurred())
+		key2, prefix2, hash2, err := identity.NewAPIKey()
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(key1).To(HavePrefix(prefix1 + "."))
+		Expect(prefix1).To(HaveLen(12))
+		Expect(key1).NotTo(Equal(key2))
+		Expect(prefix1).NotTo(Equal(prefix2))
+		Expect(hash1).NotTo(Equal(hash2))
+	})
+
+	It("hashes keys consistently without revealing them", func() {
+		key, _, hash, err := identity.NewAPIKey()
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(identity.HashAPIKey(key)).To(Equal(hash))
+		Expect(hash).To(HaveLen(64))
+		Expect(hash).NotTo(ContainSubstring(strings.SplitN(key, ".", 2)[1]))
+	})
+
+	It("reads keys from ApiKey auth headers in any case", func() {
+		key, ok := identity.APIKeyFromHeader("ApiKey abc.def")
+		Expect(ok).To(BeTrue())
+		Expect(key).To(Equal("abc.def"))
+
+		key, ok = identity.APIKeyFromHeader("apikey abc.def")
+		Expect(ok).To(BeTrue())
+		Expect(key).To(Equal("abc.def"))
+	})
+
+	It("rejects other auth headers", func() {
+		_, ok := identity.APIKeyFromHeader("Bearer abc.def")
+		Expect(ok).To(BeFalse())
+
+		_, ok = identity.APIKeyFromHeader("ApiKey ")
+		Expect(ok).To(BeFalse())
+
+		_, ok = identity.APIKeyFromHeader("")
+		Expect(ok).To(BeFalse())
+	})
+
+	It("treats invalid keys as rejections", func() {
+		Expect(identity.Rejected(identity.ErrInvalidAPIKey)).To(BeTrue())
+		Expect(identity.Rejected(identity.ErrExpiredToken)).To(BeTrue())
+		Expect(identity.Rejected(errors.New("connection refused"))).To(BeFalse())
+	})
+})
@@ -10,11 +10,11 @@ import (
 )
 
 type mockHTTPClient struct {
-	GetCalled chan bool
-	GetInput  struct {
-		Url chan string
+	DoCalled chan bool
+	DoInput  struct {
+		Req chan *http.Request
 	}
-	GetOutput struct {
+	DoOutput struct {
 		Resp chan *http.Response
 		Err  chan error
 	}
@@ -22,14 +22,14 @@ type mockHTTPClient struct {
 
 func newMockHTTPClient() *mockHTTPClient {
 	m := &mockHTTPClient{}
-	m.GetCalled = make(chan bool, 100)
-	m.GetInput.Url = make(chan string, 100)
-	m.GetOutput.Resp = make(chan *http.Response, 100)
-	m.GetOutput.Err = make(chan error, 100)
+	m.DoCalled = make(chan bool, 100)
+	m.DoInput.Req = make(chan *http.Request, 100)
+	m.DoOutput.Resp = make(chan *http.Response, 100)
+	m.DoOutput.Err = make(chan error, 100)
 	return m
 }
-func (m *mockHTTPClient) Get(url string) (resp *http.Response, err error) {
-	m.GetCalled <- true
-	m.GetInput.Url <- url
-	return <-m.GetOutput.Resp, <-m.GetOutput.Err
+func (m *mockHTTPClient) Do(req *http.Request) (resp *http.Response, err error) {
+	m.DoCalled <- true
+	m.DoInput.Req <- req
+	return <-m.DoOutput.Resp, <-m.DoOutput.Err
 }
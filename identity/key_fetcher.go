@@ -1,26 +1,33 @@
 package identity
 
 import (
-	"crypto/rsa"
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"encoding/base64"
 	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/mendsley/gojwk"
 	"github.com/myshkin5/effective-octo-garbanzo/logs"
 )
 
+// HTTPClient is satisfied by *http.Client, which should have a Timeout so a
+// slow auth server can't hold up key refreshes indefinitely.
 type HTTPClient interface {
-	Get(url string) (resp *http.Response, err error)
+	Do(req *http.Request) (resp *http.Response, err error)
 }
 
-func MustFetchKeys(verifierKeyURI string, client HTTPClient) map[string]*rsa.PublicKey {
+func MustFetchKeys(verifierKeyURI string, client HTTPClient) map[string]crypto.PublicKey {
 	for {
 		var err error
 		for i := 0; i < 20; i++ {
-			var keys map[string]*rsa.PublicKey
+			var keys map[string]crypto.PublicKey
 			keys, err = FetchKeys(verifierKeyURI, client)
 			if err == nil {
 				return keys
@@ -33,42 +40,102 @@ func MustFetchKeys(verifierKeyURI string, client HTTPClient) map[string]*rsa.Pub
 	}
 }
 
-func FetchKeys(verifierKeyURI string, client HTTPClient) (map[string]*rsa.PublicKey, error) {
-	response, err := client.Get(verifierKeyURI)
+func FetchKeys(verifierKeyURI string, client HTTPClient) (map[string]crypto.PublicKey, error) {
+	keys, _, err := fetchKeys(context.Background(), verifierKeyURI, client)
+	return keys, err
+}
+
+// fetchKeys also returns how long the keys may be cached according to the
+// Cache-Control header of the response, zero when not specified.
+func fetchKeys(ctx context.Context, verifierKeyURI string, client HTTPClient) (map[string]crypto.PublicKey, time.Duration, error) {
+	request, err := http.NewRequest(http.MethodGet, verifierKeyURI, nil)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
+	}
+
+	response, err := client.Do(request.WithContext(ctx))
+	if err != nil {
+		return nil, 0, err
 	}
 	defer response.Body.Close()
 
 	if response.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("auth server returned a non-200 response code, code was %d", response.StatusCode)
+		return nil, 0, fmt.Errorf("auth server returned a non-200 response code, code was %d", response.StatusCode)
 	}
 
 	logs.Logger.Infof("Successfully fetched public key from %s", verifierKeyURI)
 
 	bytes, err := ioutil.ReadAll(response.Body)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 
 	jwks, err := gojwk.Unmarshal(bytes)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 
 	if len(jwks.Keys) == 0 {
-		return nil, errors.New("auth server returned no keys")
+		return nil, 0, errors.New("auth server returned no keys")
 	}
 
-	keys := make(map[string]*rsa.PublicKey, len(jwks.Keys))
+	keys := make(map[string]crypto.PublicKey, len(jwks.Keys))
 	for _, key := range jwks.Keys {
-		publicKey, err := key.DecodePublicKey()
+		// Keys without a use may be used for anything
+		if key.Use != "" && key.Use != "sig" {
+			logs.Logger.Warnf("Skipping key %s with use %s, only signing keys are used", key.Kid, key.Use)
+			continue
+		}
+
+		publicKey, err := decodePublicKey(key)
 		if err != nil {
-			return nil, err
+			logs.Logger.Warnf("Skipping key %s which can't be decoded: %v", key.Kid, err)
+			continue
+		}
+
+		keys[key.Kid] = publicKey
+	}
+
+	if len(keys) == 0 {
+		return nil, 0, errors.New("auth server returned no signing keys")
+	}
+
+	return keys, maxAge(response.Header), nil
+}
+
+// decodePublicKey adds Ed25519 (octet key pair) keys to the RSA and EC keys
+// gojwk decodes.
+func decodePublicKey(key *gojwk.Key) (crypto.PublicKey, error) {
+	if key.Kty != "OKP" {
+		return key.DecodePublicKey()
+	}
+
+	if key.Crv != "Ed25519" {
+		return nil, fmt.Errorf("Unknown JWK OKP curve %s", key.Crv)
+	}
+
+	x, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(key.X, "="))
+	if err != nil || len(x) != ed25519.PublicKeySize {
+		return nil, errors.New("Malformed JWK OKP key")
+	}
+
+	return ed25519.PublicKey(x), nil
+}
+
+func maxAge(header http.Header) time.Duration {
+	for _, directive := range strings.Split(header.Get("Cache-Control"), ",") {
+		directive = strings.ToLower(strings.TrimSpace(directive))
+		if !strings.HasPrefix(directive, "max-age=") {
+			continue
+		}
+
+		seconds, err := strconv.Atoi(strings.TrimPrefix(directive, "max-age="))
+		if err != nil || seconds < 0 {
+			return 0
 		}
 
-		keys[key.Kid] = publicKey.(*rsa.PublicKey)
+		return time.Duration(seconds) * time.Second
 	}
 
-	return keys, nil
+	return 0
 }
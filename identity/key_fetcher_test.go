@@ -3,6 +3,10 @@ package identity_test
 //go:generate hel
 
 import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
 	"errors"
 	"io/ioutil"
 	"net/http"
@@ -24,33 +28,36 @@ var _ = Describe("KeyFetcher", func() {
 
 	Describe("MustFetchKeys", func() {
 		It("eventually succeeds", func() {
-			mockHTTPClient.GetOutput.Resp <- nil
-			mockHTTPClient.GetOutput.Err <- errors.New("bad things happened")
+			mockHTTPClient.DoOutput.Resp <- nil
+			mockHTTPClient.DoOutput.Err <- errors.New("bad things happened")
 
-			mockHTTPClient.GetOutput.Resp <- createValidResponse("RSA", validModulus)
-			mockHTTPClient.GetOutput.Err <- nil
+			mockHTTPClient.DoOutput.Resp <- createValidResponse("RSA", validModulus)
+			mockHTTPClient.DoOutput.Err <- nil
 
 			keys := identity.MustFetchKeys("http://somewhere.com", mockHTTPClient)
 
 			Expect(keys["key1"]).NotTo(BeNil())
-			Expect(keys["key1"].E).To(Equal(65537))
+			Expect(keys["key1"].(*rsa.PublicKey).E).To(Equal(65537))
 			Expect(keys["key2"]).NotTo(BeNil())
-			Expect(keys["key2"].E).To(Equal(65538))
+			Expect(keys["key2"].(*rsa.PublicKey).E).To(Equal(65538))
 		})
 	})
 
 	Describe("FetchKeys", func() {
 		Context("error response from client", func() {
 			BeforeEach(func() {
-				mockHTTPClient.GetOutput.Resp <- nil
-				mockHTTPClient.GetOutput.Err <- errors.New("bad things happened")
+				mockHTTPClient.DoOutput.Resp <- nil
+				mockHTTPClient.DoOutput.Err <- errors.New("bad things happened")
 			})
 
 			It("attempts to get the resource on invocation", func() {
 				identity.FetchKeys("http://somewhere.com", mockHTTPClient)
 
-				Expect(mockHTTPClient.GetCalled).To(Receive(Equal(true)))
-				Expect(mockHTTPClient.GetInput.Url).To(Receive(Equal("http://somewhere.com")))
+				Expect(mockHTTPClient.DoCalled).To(Receive(Equal(true)))
+				var request *http.Request
+				Expect(mockHTTPClient.DoInput.Req).To(Receive(&request))
+				Expect(request.Method).To(Equal(http.MethodGet))
+				Expect(request.URL.String()).To(Equal("http://somewhere.com"))
 			})
 
 			It("returns an error when the client returns an error", func() {
@@ -61,11 +68,11 @@ var _ = Describe("KeyFetcher", func() {
 		})
 
 		It("returns an error when the client returns a non-200 response code", func() {
-			mockHTTPClient.GetOutput.Resp <- &http.Response{
+			mockHTTPClient.DoOutput.Resp <- &http.Response{
 				StatusCode: http.StatusInternalServerError,
 				Body:       ioutil.NopCloser(nil),
 			}
-			mockHTTPClient.GetOutput.Err <- nil
+			mockHTTPClient.DoOutput.Err <- nil
 
 			_, err := identity.FetchKeys("http://somewhere.com", mockHTTPClient)
 
@@ -73,11 +80,11 @@ var _ = Describe("KeyFetcher", func() {
 		})
 
 		It("returns an error if the body of the response doesn't parse", func() {
-			mockHTTPClient.GetOutput.Resp <- &http.Response{
+			mockHTTPClient.DoOutput.Resp <- &http.Response{
 				StatusCode: http.StatusOK,
 				Body:       ioutil.NopCloser(strings.NewReader(`not-json`)),
 			}
-			mockHTTPClient.GetOutput.Err <- nil
+			mockHTTPClient.DoOutput.Err <- nil
 
 			_, err := identity.FetchKeys("http://somewhere.com", mockHTTPClient)
 
@@ -86,48 +93,148 @@ var _ = Describe("KeyFetcher", func() {
 		})
 
 		It("returns an error if there are no keys", func() {
-			mockHTTPClient.GetOutput.Resp <- &http.Response{
+			mockHTTPClient.DoOutput.Resp <- &http.Response{
 				StatusCode: http.StatusOK,
 				Body: ioutil.NopCloser(strings.NewReader(`{
 						"keys": []
 					}`)),
 			}
-			mockHTTPClient.GetOutput.Err <- nil
+			mockHTTPClient.DoOutput.Err <- nil
 
 			_, err := identity.FetchKeys("http://somewhere.com", mockHTTPClient)
 
 			Expect(err).To(MatchError("auth server returned no keys"))
 		})
 
-		It("returns an error if the key type is bogus", func() {
-			mockHTTPClient.GetOutput.Resp <- createValidResponse("BogusKty", validModulus)
-			mockHTTPClient.GetOutput.Err <- nil
+		It("returns an error if every key type is bogus", func() {
+			mockHTTPClient.DoOutput.Resp <- createValidResponse("BogusKty", validModulus)
+			mockHTTPClient.DoOutput.Err <- nil
 
 			_, err := identity.FetchKeys("http://somewhere.com", mockHTTPClient)
 
-			Expect(err).To(MatchError("Unknown JWK key type BogusKty"))
+			Expect(err).To(MatchError("auth server returned no signing keys"))
 		})
 
-		It("returns an error if there is a malformed modulus", func() {
-			mockHTTPClient.GetOutput.Resp <- createValidResponse("RSA", "bogus-modulus")
-			mockHTTPClient.GetOutput.Err <- nil
+		It("returns an error if every modulus is malformed", func() {
+			mockHTTPClient.DoOutput.Resp <- createValidResponse("RSA", "bogus-modulus")
+			mockHTTPClient.DoOutput.Err <- nil
 
 			_, err := identity.FetchKeys("http://somewhere.com", mockHTTPClient)
 
-			Expect(err).To(MatchError("Malformed JWK RSA key"))
+			Expect(err).To(MatchError("auth server returned no signing keys"))
+		})
+
+		It("returns EC and Ed25519 keys", func() {
+			mockHTTPClient.DoOutput.Resp <- &http.Response{
+				StatusCode: http.StatusOK,
+				Body: ioutil.NopCloser(strings.NewReader(`{
+						"keys": [
+							{
+								"kid": "ec",
+								"kty": "EC",
+								"crv": "P-256",
+								"x": "MKBCTNIcKUSDii11ySs3526iDZ8AiTo7Tu6KPAqv7D4",
+								"y": "4Etl6SRW2YiLUrN5vfvVHuhp7x8PxltmWWlbbM4IFyM",
+								"use": "sig"
+							},
+							{
+								"kid": "ed",
+								"kty": "OKP",
+								"crv": "Ed25519",
+								"x": "11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo"
+							}
+						]
+					}`)),
+			}
+			mockHTTPClient.DoOutput.Err <- nil
+
+			keys, err := identity.FetchKeys("http://somewhere.com", mockHTTPClient)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(keys["ec"]).To(BeAssignableToTypeOf(&ecdsa.PublicKey{}))
+			Expect(keys["ec"].(*ecdsa.PublicKey).Curve).To(Equal(elliptic.P256()))
+			Expect(keys["ed"]).To(BeAssignableToTypeOf(ed25519.PublicKey{}))
+			Expect(keys["ed"]).To(HaveLen(ed25519.PublicKeySize))
+		})
+
+		It("returns an error if the only key is a malformed Ed25519 key", func() {
+			mockHTTPClient.DoOutput.Resp <- &http.Response{
+				StatusCode: http.StatusOK,
+				Body: ioutil.NopCloser(strings.NewReader(`{
+						"keys": [{"kid": "ed", "kty": "OKP", "crv": "Ed25519", "x": "c2hvcnQ"}]
+					}`)),
+			}
+			mockHTTPClient.DoOutput.Err <- nil
+
+			_, err := identity.FetchKeys("http://somewhere.com", mockHTTPClient)
+
+			Expect(err).To(MatchError("auth server returned no signing keys"))
+		})
+
+		It("skips keys which can't be decoded", func() {
+			mockHTTPClient.DoOutput.Resp <- &http.Response{
+				StatusCode: http.StatusOK,
+				Body: ioutil.NopCloser(strings.NewReader(`{
+						"keys": [
+							{"kid": "bogus", "kty": "BogusKty", "use": "sig"},
+							{"kid": "ed", "kty": "OKP", "crv": "Ed25519", "x": "c2hvcnQ"},
+							{"kid": "sig", "kty": "RSA", "n": "` + validModulus + `", "e": "AQAB", "use": "sig"}
+						]
+					}`)),
+			}
+			mockHTTPClient.DoOutput.Err <- nil
+
+			keys, err := identity.FetchKeys("http://somewhere.com", mockHTTPClient)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(keys).To(HaveLen(1))
+			Expect(keys).To(HaveKey("sig"))
+		})
+
+		It("skips keys which aren't for signing", func() {
+			mockHTTPClient.DoOutput.Resp <- &http.Response{
+				StatusCode: http.StatusOK,
+				Body: ioutil.NopCloser(strings.NewReader(`{
+						"keys": [
+							{"kid": "enc", "kty": "BogusKty", "use": "enc"},
+							{"kid": "sig", "kty": "RSA", "n": "` + validModulus + `", "e": "AQAB", "use": "sig"}
+						]
+					}`)),
+			}
+			mockHTTPClient.DoOutput.Err <- nil
+
+			keys, err := identity.FetchKeys("http://somewhere.com", mockHTTPClient)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(keys).To(HaveLen(1))
+			Expect(keys).To(HaveKey("sig"))
+		})
+
+		It("returns an error if there are no signing keys", func() {
+			mockHTTPClient.DoOutput.Resp <- &http.Response{
+				StatusCode: http.StatusOK,
+				Body: ioutil.NopCloser(strings.NewReader(`{
+						"keys": [{"kid": "enc", "kty": "RSA", "n": "` + validModulus + `", "e": "AQAB", "use": "enc"}]
+					}`)),
+			}
+			mockHTTPClient.DoOutput.Err <- nil
+
+			_, err := identity.FetchKeys("http://somewhere.com", mockHTTPClient)
+
+			Expect(err).To(MatchError("auth server returned no signing keys"))
 		})
 
 		It("returns the public key", func() {
-			mockHTTPClient.GetOutput.Resp <- createValidResponse("RSA", validModulus)
-			mockHTTPClient.GetOutput.Err <- nil
+			mockHTTPClient.DoOutput.Resp <- createValidResponse("RSA", validModulus)
+			mockHTTPClient.DoOutput.Err <- nil
 
 			keys, err := identity.FetchKeys("http://somewhere.com", mockHTTPClient)
 
 			Expect(err).NotTo(HaveOccurred())
 			Expect(keys["key1"]).NotTo(BeNil())
-			Expect(keys["key1"].E).To(Equal(65537))
+			Expect(keys["key1"].(*rsa.PublicKey).E).To(Equal(65537))
 			Expect(keys["key2"]).NotTo(BeNil())
-			Expect(keys["key2"].E).To(Equal(65538))
+			Expect(keys["key2"].(*rsa.PublicKey).E).To(Equal(65538))
 		})
 	})
 })
@@ -0,0 +1,141 @@
+package identity
+
+import (
+	"context"
+	"crypto"
+	"sync"
+	"time"
+
+	"github.com/myshkin5/effective-octo-garbanzo/logs"
+	"github.com/myshkin5/effective-octo-garbanzo/metrics"
+)
+
+// KeySet holds the public keys tokens are verified with and keeps them current
+// as the auth server rotates its keys. Keys are refreshed once the max-age of
+// the auth server's Cache-Control header (or refreshInterval when there isn't
+// one) has passed and whenever a token names an unknown key id, although no
+// more often than minRefreshInterval. The last known good keys are kept when a
+// refresh fails.
+type KeySet struct {
+	verifierKeyURI     string
+	client             HTTPClient
+	refreshInterval    time.Duration
+	minRefreshInterval time.Duration
+
+	keysLock sync.RWMutex
+	keys     map[string]crypto.PublicKey
+
+	// refreshLock serializes refreshes and guards the refresh schedule
+	refreshLock  sync.Mutex
+	lastRefresh  time.Time
+	refreshAfter time.Duration
+}
+
+// Refresh triggers recorded by the key refreshes metric
+const (
+	triggerScheduled  = "scheduled"
+	triggerUnknownKid = "unknown_kid"
+)
+
+// NewKeySet starts with keys (typically from MustFetchKeys) as though they had
+// just been fetched. minRefreshInterval must be positive when Run is used.
+func NewKeySet(verifierKeyURI string, client HTTPClient, keys map[string]crypto.PublicKey, refreshInterval, minRefreshInterval time.Duration) *KeySet {
+	return &KeySet{
+		verifierKeyURI:     verifierKeyURI,
+		client:             client,
+		refreshInterval:    refreshInterval,
+		minRefreshInterval: minRefreshInterval,
+		keys:               keys,
+		lastRefresh:        time.Now(),
+		refreshAfter:       refreshInterval,
+	}
+}
+
+// Key returns the public key with keyId, refreshing the keys first when the
+// key id isn't known.
+func (k *KeySet) Key(ctx context.Context, keyId string) (crypto.PublicKey, bool) {
+	publicKey, ok := k.key(keyId)
+	if ok {
+		return publicKey, true
+	}
+
+	k.refreshLock.Lock()
+	defer k.refreshLock.Unlock()
+
+	// Another request may have fetched the key while this one was waiting
+	publicKey, ok = k.key(keyId)
+	if ok || time.Since(k.lastRefresh) < k.minRefreshInterval {
+		return publicKey, ok
+	}
+
+	k.refresh(ctx, triggerUnknownKid)
+	return k.key(keyId)
+}
+
+// Run refreshes the keys on schedule until ctx is done.
+func (k *KeySet) Run(ctx context.Context) {
+	for {
+		k.refreshLock.Lock()
+		wait := k.refreshAfter - time.Since(k.lastRefresh)
+		k.refreshLock.Unlock()
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		k.refreshLock.Lock()
+		// Skipped when an unknown key id refreshed the keys in the meantime
+		if time.Since(k.lastRefresh) >= k.refreshAfter {
+			k.refresh(ctx, triggerScheduled)
+		}
+		k.refreshLock.Unlock()
+	}
+}
+
+func (k *KeySet) key(keyId string) (crypto.PublicKey, bool) {
+	k.keysLock.RLock()
+	defer k.keysLock.RUnlock()
+
+	publicKey, ok := k.keys[keyId]
+	return publicKey, ok
+}
+
+func (k *KeySet) count() int {
+	k.keysLock.RLock()
+	defer k.keysLock.RUnlock()
+
+	return len(k.keys)
+}
+
+// refresh must be called with refreshLock held.
+func (k *KeySet) refresh(ctx context.Context, trigger string) {
+	k.lastRefresh = time.Now()
+
+	keys, maxAge, err := fetchKeys(ctx, k.verifierKeyURI, k.client)
+	if err != nil {
+		metrics.KeyRefreshes.WithLabelValues(trigger, "error").Inc()
+		logs.FromContext(ctx).Warnf("Could not refresh keys from %s, keeping the last known good keys, error %v",
+			k.verifierKeyURI, err)
+		k.refreshAfter = k.minRefreshInterval
+		return
+	}
+
+	metrics.KeyRefreshes.WithLabelValues(trigger, "ok").Inc()
+	metrics.KeyRefreshTimestamp.SetToCurrentTime()
+
+	k.keysLock.Lock()
+	k.keys = keys
+	k.keysLock.Unlock()
+
+	if maxAge == 0 {
+		maxAge = k.refreshInterval
+	}
+	if maxAge < k.minRefreshInterval {
+		maxAge = k.minRefreshInterval
+	}
+	k.refreshAfter = maxAge
+}
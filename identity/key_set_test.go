@@ -0,0 +1,138 @@
+package identity_test
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"errors"
+	"net/http"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/myshkin5/effective-octo-garbanzo/identity"
+	"github.com/myshkin5/effective-octo-garbanzo/metrics"
+)
+
+var _ = Describe("KeySet", func() {
+	var (
+		ctx            context.Context
+		cancel         context.CancelFunc
+		mockHTTPClient *mockHTTPClient
+		initialKey     *rsa.PublicKey
+	)
+
+	refreshes := func(trigger, result string) float64 {
+		return testutil.ToFloat64(metrics.KeyRefreshes.WithLabelValues(trigger, result))
+	}
+
+	BeforeEach(func() {
+		ctx, cancel = context.WithCancel(context.Background())
+		mockHTTPClient = newMockHTTPClient()
+		initialKey = &rsa.PublicKey{E: 3}
+	})
+
+	AfterEach(func() {
+		cancel()
+	})
+
+	newKeySet := func(refreshInterval, minRefreshInterval time.Duration) *identity.KeySet {
+		return identity.NewKeySet("http://somewhere.com", mockHTTPClient,
+			map[string]crypto.PublicKey{"key0": initialKey}, refreshInterval, minRefreshInterval)
+	}
+
+	It("returns known keys without refreshing", func() {
+		keySet := newKeySet(time.Hour, 0)
+
+		key, ok := keySet.Key(ctx, "key0")
+
+		Expect(ok).To(BeTrue())
+		Expect(key).To(Equal(initialKey))
+		Expect(mockHTTPClient.DoCalled).NotTo(Receive())
+	})
+
+	It("refreshes the keys when a key id is unknown", func() {
+		keySet := newKeySet(time.Hour, 0)
+		before := refreshes("unknown_kid", "ok")
+		mockHTTPClient.DoOutput.Resp <- createValidResponse("RSA", validModulus)
+		mockHTTPClient.DoOutput.Err <- nil
+
+		key, ok := keySet.Key(ctx, "key1")
+
+		Expect(ok).To(BeTrue())
+		Expect(key.(*rsa.PublicKey).E).To(Equal(65537))
+		var request *http.Request
+		Expect(mockHTTPClient.DoInput.Req).To(Receive(&request))
+		Expect(request.URL.String()).To(Equal("http://somewhere.com"))
+		Expect(request.Context()).To(Equal(ctx), "the fetch is cancelled with the request needing the key")
+		Expect(refreshes("unknown_kid", "ok")).To(Equal(before + 1))
+
+		// Unknown again so refreshed again (there's no minimum refresh interval)
+		mockHTTPClient.DoOutput.Resp <- createValidResponse("RSA", validModulus)
+		mockHTTPClient.DoOutput.Err <- nil
+		_, ok = keySet.Key(ctx, "key0")
+		Expect(ok).To(BeFalse(), "keys no longer served by the auth server are dropped")
+	})
+
+	It("doesn't refresh more often than the minimum refresh interval", func() {
+		keySet := newKeySet(time.Hour, time.Hour)
+
+		_, ok := keySet.Key(ctx, "key1")
+
+		Expect(ok).To(BeFalse())
+		Expect(mockHTTPClient.DoCalled).NotTo(Receive())
+	})
+
+	It("keeps the last known good keys when a refresh fails", func() {
+		keySet := newKeySet(time.Hour, 0)
+		before := refreshes("unknown_kid", "error")
+		mockHTTPClient.DoOutput.Resp <- nil
+		mockHTTPClient.DoOutput.Err <- errors.New("bad things happened")
+
+		_, ok := keySet.Key(ctx, "key1")
+		Expect(ok).To(BeFalse())
+		Expect(mockHTTPClient.DoCalled).To(Receive())
+		Expect(refreshes("unknown_kid", "error")).To(Equal(before + 1))
+
+		key, ok := keySet.Key(ctx, "key0")
+		Expect(ok).To(BeTrue())
+		Expect(key).To(Equal(initialKey))
+	})
+
+	It("refreshes on schedule", func() {
+		keySet := newKeySet(10*time.Millisecond, time.Hour)
+		before := refreshes("scheduled", "ok")
+		mockHTTPClient.DoOutput.Resp <- createValidResponse("RSA", validModulus)
+		mockHTTPClient.DoOutput.Err <- nil
+
+		go keySet.Run(ctx)
+
+		Eventually(mockHTTPClient.DoCalled).Should(Receive())
+		Eventually(func() float64 {
+			return refreshes("scheduled", "ok")
+		}).Should(Equal(before + 1))
+		_, ok := keySet.Key(ctx, "key1")
+		Expect(ok).To(BeTrue())
+		Consistently(mockHTTPClient.DoCalled).ShouldNot(Receive())
+	})
+
+	It("schedules the next refresh using the max-age of the response", func() {
+		keySet := newKeySet(time.Hour, 0)
+		for i := 0; i < 2; i++ {
+			response := createValidResponse("RSA", validModulus)
+			response.Header = http.Header{"Cache-Control": {"public, max-age=1"}}
+			mockHTTPClient.DoOutput.Resp <- response
+			mockHTTPClient.DoOutput.Err <- nil
+		}
+
+		_, ok := keySet.Key(ctx, "key1")
+		Expect(ok).To(BeTrue())
+		Expect(mockHTTPClient.DoCalled).To(Receive())
+
+		go keySet.Run(ctx)
+
+		Eventually(mockHTTPClient.DoCalled, 2*time.Second).Should(Receive())
+	})
+})
@@ -1,24 +1,74 @@
 package identity
 
 import (
-	"crypto/rsa"
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/dgrijalva/jwt-go"
 	"github.com/myshkin5/effective-octo-garbanzo/logs"
+	"github.com/myshkin5/effective-octo-garbanzo/metrics"
 )
 
 type Validator struct {
-	publicKeys map[string]*rsa.PublicKey
+	keys   *KeySet
+	config ValidatorConfig
+	parser *jwt.Parser
+}
+
+// ValidatorConfig restricts the tokens a Validator accepts.
+type ValidatorConfig struct {
+	// Issuers lists the accepted iss claims, any issuer is accepted when empty
+	Issuers []string
+	// Audiences lists the accepted aud claims of which a token must name at
+	// least one, any audience is accepted when empty
+	Audiences []string
+	// Leeway allows for clock skew when checking the exp, nbf and iat claims
+	Leeway time.Duration
+	// Algorithms lists the accepted signing algorithms, defaulting to RS256
+	Algorithms []string
 }
 
 type CustomClaims struct {
 	Org string `json:"custom:org"`
+	// Audience replaces the aud claim of StandardClaims which can't hold a list
+	Audience Audience `json:"aud,omitempty"`
+	// Scope is a space separated list of scopes (RFC 8693)
+	Scope string   `json:"scope,omitempty"`
+	Roles []string `json:"roles,omitempty"`
 	jwt.StandardClaims
 }
 
+// Scopes returns the scopes and roles granted by the token. Both claims are
+// treated alike as identity providers differ in which one they issue.
+func (c *CustomClaims) Scopes() []string {
+	return append(strings.Fields(c.Scope), c.Roles...)
+}
+
+// Audience is the aud claim which may be a single string or a list.
+type Audience []string
+
+func (a *Audience) UnmarshalJSON(b []byte) error {
+	var single string
+	err := json.Unmarshal(b, &single)
+	if err == nil {
+		*a = Audience{single}
+		return nil
+	}
+
+	var list []string
+	err = json.Unmarshal(b, &list)
+	if err != nil {
+		return err
+	}
+
+	*a = list
+	return nil
+}
+
 func (c *CustomClaims) Valid() error {
 	err := c.StandardClaims.Valid()
 	if err != nil {
@@ -34,27 +84,121 @@ func (c *CustomClaims) Valid() error {
 
 const bearerPrefix = "bearer "
 
-func NewValidator(publicKeys map[string]*rsa.PublicKey) *Validator {
+// Validation results recorded by the JWT validations metric
+const (
+	resultValid            = "valid"
+	resultMissingBearer    = "missing_bearer"
+	resultMalformed        = "malformed"
+	resultUnverifiable     = "unverifiable"
+	resultInvalidSignature = "invalid_signature"
+	resultExpired          = "expired"
+	resultNotValidYet      = "not_valid_yet"
+	resultInvalidClaims    = "invalid_claims"
+	resultInvalidIssuer    = "invalid_issuer"
+	resultInvalidAudience  = "invalid_audience"
+	resultInvalidAlgorithm = "invalid_algorithm"
+)
+
+// Errors returned by Validate describing why a token was rejected
+var (
+	ErrMissingBearer    = errors.New("authorization header lacks a bearer token")
+	ErrMalformedToken   = errors.New("token is malformed")
+	ErrUnknownKey       = errors.New("token signing key is unknown")
+	ErrInvalidSignature = errors.New("token signature is invalid")
+	ErrExpiredToken     = errors.New("token is expired")
+	ErrTokenNotValidYet = errors.New("token is not valid yet")
+	ErrInvalidClaims    = errors.New("token claims are invalid")
+	ErrInvalidIssuer    = errors.New("token issuer is not trusted")
+	ErrInvalidAudience  = errors.New("token audience is not accepted")
+	ErrInvalidAlgorithm = errors.New("token signing algorithm is not allowed")
+)
+
+var resultErrors = map[string]error{
+	resultMissingBearer:    ErrMissingBearer,
+	resultMalformed:        ErrMalformedToken,
+	resultUnverifiable:     ErrUnknownKey,
+	resultInvalidSignature: ErrInvalidSignature,
+	resultExpired:          ErrExpiredToken,
+	resultNotValidYet:      ErrTokenNotValidYet,
+	resultInvalidClaims:    ErrInvalidClaims,
+	resultInvalidIssuer:    ErrInvalidIssuer,
+	resultInvalidAudience:  ErrInvalidAudience,
+	resultInvalidAlgorithm: ErrInvalidAlgorithm,
+}
+
+// Rejected reports whether err rejects the credentials of a request rather
+// than reporting a failure to check them.
+func Rejected(err error) bool {
+	if err == ErrInvalidAPIKey {
+		return true
+	}
+	for _, resultErr := range resultErrors {
+		if err == resultErr {
+			return true
+		}
+	}
+
+	return false
+}
+
+var errInvalidAlgorithm = errors.New("signing algorithm is not allowed")
+
+func NewValidator(keys *KeySet, config ValidatorConfig) *Validator {
+	if len(config.Algorithms) == 0 {
+		config.Algorithms = []string{jwt.SigningMethodRS256.Alg()}
+	}
+
 	return &Validator{
-		publicKeys: publicKeys,
+		keys:   keys,
+		config: config,
+		// Claims are validated by validateClaims to apply the leeway
+		parser: &jwt.Parser{SkipClaimsValidation: true},
+	}
+}
+
+// CheckKeys returns an error when there are no public keys to validate tokens
+// with.
+func (v *Validator) CheckKeys() error {
+	if v.keys.count() == 0 {
+		return errors.New("no public keys loaded")
+	}
+
+	return nil
+}
+
+// Validate returns the org, subject (the sub claim) and scopes of a valid
+// bearer token or one of the Err* errors when the token is rejected.
+func (v *Validator) Validate(ctx context.Context, authHeader string) (org, subject string, scopes []string, err error) {
+	claims, result := v.validate(ctx, authHeader)
+	metrics.JWTValidations.WithLabelValues(result).Inc()
+
+	if result != resultValid {
+		return "", "", nil, resultErrors[result]
 	}
+
+	return claims.Org, claims.Subject, claims.Scopes(), nil
 }
 
-func (v *Validator) IsValid(authHeader string) (ok bool, org string) {
+func (v *Validator) validate(ctx context.Context, authHeader string) (claims CustomClaims, result string) {
 	if !strings.HasPrefix(strings.ToLower(authHeader), bearerPrefix) {
-		logs.Logger.Infof("Authentication header lacks %sprefix", bearerPrefix)
-		return false, ""
+		logs.FromContext(ctx).Infof("Authentication header lacks %sprefix", bearerPrefix)
+		return claims, resultMissingBearer
 	}
 
 	tokenString := authHeader[len(bearerPrefix):]
-	var claims CustomClaims
-	_, err := jwt.ParseWithClaims(tokenString, &claims, func(token *jwt.Token) (interface{}, error) {
+	_, err := v.parser.ParseWithClaims(tokenString, &claims, func(token *jwt.Token) (interface{}, error) {
+		// Checked before choosing a key so a token can't pick an algorithm
+		// that misuses the key (e.g. HS256 with an RSA public key)
+		if !contains(v.config.Algorithms, token.Method.Alg()) {
+			return nil, errInvalidAlgorithm
+		}
+
 		keyId, ok := token.Header["kid"].(string)
 		if !ok {
 			return nil, fmt.Errorf("key id (kid) is not a string in headers: %v", token.Header)
 		}
 
-		publicKey, ok := v.publicKeys[keyId]
+		publicKey, ok := v.keys.Key(ctx, keyId)
 		if !ok {
 			return nil, fmt.Errorf("no public key found for key id (kid) %v", keyId)
 		}
@@ -62,9 +206,86 @@ func (v *Validator) IsValid(authHeader string) (ok bool, org string) {
 		return publicKey, nil
 	})
 	if err != nil {
-		logs.Logger.Infof("Error parsing authentication header, %v", err)
-		return false, ""
+		logs.FromContext(ctx).Infof("Error parsing authentication header, %v", err)
+		return claims, failureResult(err)
+	}
+
+	result = v.validateClaims(claims)
+	if result != resultValid {
+		logs.FromContext(ctx).Infof("Rejecting token, %v", resultErrors[result])
+		return claims, result
+	}
+
+	return claims, resultValid
+}
+
+func (v *Validator) validateClaims(claims CustomClaims) string {
+	now := time.Now()
+	if !claims.VerifyExpiresAt(now.Add(-v.config.Leeway).Unix(), false) {
+		return resultExpired
+	}
+	if !claims.VerifyNotBefore(now.Add(v.config.Leeway).Unix(), false) {
+		return resultNotValidYet
+	}
+	if !claims.VerifyIssuedAt(now.Add(v.config.Leeway).Unix(), false) {
+		return resultInvalidClaims
+	}
+
+	if len(v.config.Issuers) > 0 && !contains(v.config.Issuers, claims.Issuer) {
+		return resultInvalidIssuer
+	}
+
+	if len(v.config.Audiences) > 0 && !containsAny(v.config.Audiences, claims.Audience) {
+		return resultInvalidAudience
+	}
+
+	if len(claims.Org) == 0 {
+		return resultInvalidClaims
+	}
+
+	return resultValid
+}
+
+func failureResult(err error) string {
+	validationErr, ok := err.(*jwt.ValidationError)
+	if !ok {
+		return resultInvalidClaims
+	}
+
+	switch {
+	case validationErr.Inner == errInvalidAlgorithm:
+		return resultInvalidAlgorithm
+	case validationErr.Errors&jwt.ValidationErrorMalformed != 0:
+		return resultMalformed
+	case validationErr.Errors&jwt.ValidationErrorUnverifiable != 0:
+		return resultUnverifiable
+	case validationErr.Errors&jwt.ValidationErrorSignatureInvalid != 0:
+		return resultInvalidSignature
+	case validationErr.Errors&jwt.ValidationErrorExpired != 0:
+		return resultExpired
+	case validationErr.Errors&jwt.ValidationErrorNotValidYet != 0:
+		return resultNotValidYet
+	default:
+		return resultInvalidClaims
+	}
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+func containsAny(values, candidates []string) bool {
+	for _, candidate := range candidates {
+		if contains(values, candidate) {
+			return true
+		}
 	}
 
-	return true, claims.Org
+	return false
 }
@@ -1,21 +1,36 @@
 package identity_test
 
 import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
+	"encoding/base64"
+	"io/ioutil"
+	"net/http"
+	"strings"
 	"time"
 
 	"github.com/dgrijalva/jwt-go"
 	"github.com/myshkin5/effective-octo-garbanzo/identity"
+	"github.com/myshkin5/effective-octo-garbanzo/metrics"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 )
 
 var _ = Describe("Validator", func() {
 	var (
 		privateKey *rsa.PrivateKey
-		publicKeys map[string]*rsa.PublicKey
+		publicKeys map[string]crypto.PublicKey
 		validator  *identity.Validator
+		ctx        context.Context
+		keySet     *identity.KeySet
+
+		mockHTTPClient *mockHTTPClient
 	)
 
 	BeforeSuite(func() {
@@ -23,12 +38,15 @@ var _ = Describe("Validator", func() {
 		privateKey, err = rsa.GenerateKey(rand.Reader, 2048)
 		Expect(err).NotTo(HaveOccurred())
 
-		publicKeys = make(map[string]*rsa.PublicKey)
+		publicKeys = make(map[string]crypto.PublicKey)
 		publicKeys["joe"] = &privateKey.PublicKey
 	})
 
 	BeforeEach(func() {
-		validator = identity.NewValidator(publicKeys)
+		mockHTTPClient = newMockHTTPClient()
+		keySet = identity.NewKeySet("http://somewhere.com", mockHTTPClient, publicKeys, time.Hour, time.Hour)
+		validator = identity.NewValidator(keySet, identity.ValidatorConfig{})
+		ctx = context.Background()
 	})
 
 	type jwtRequest struct {
@@ -36,15 +54,31 @@ var _ = Describe("Validator", func() {
 		algorithm    string
 		method       jwt.SigningMethod
 		validSeconds int64
+		notBefore    int64
+		issuer       string
+		audience     identity.Audience
 		org          string
+		subject      string
+		scope        string
+		roles        []string
 		signingKey   interface{}
 	}
 
+	validations := func(result string) float64 {
+		return testutil.ToFloat64(metrics.JWTValidations.WithLabelValues(result))
+	}
+
 	createJWT := func(request jwtRequest) string {
 		claims := identity.CustomClaims{
-			Org: request.org,
+			Org:      request.org,
+			Audience: request.audience,
+			Scope:    request.scope,
+			Roles:    request.roles,
 			StandardClaims: jwt.StandardClaims{
 				ExpiresAt: time.Now().Unix() + request.validSeconds,
+				NotBefore: request.notBefore,
+				Issuer:    request.issuer,
+				Subject:   request.subject,
 			},
 		}
 		token := &jwt.Token{
@@ -62,30 +96,71 @@ var _ = Describe("Validator", func() {
 	}
 
 	It("reports bogus headers as invalid", func() {
-		ok, _ := validator.IsValid("bogus")
-		Expect(ok).To(BeFalse())
+		before := validations("missing_bearer")
+
+		_, _, _, err := validator.Validate(ctx, "bogus")
+		Expect(err).To(Equal(identity.ErrMissingBearer))
+		Expect(validations("missing_bearer")).To(Equal(before + 1))
 	})
 
 	It("reports bogus tokens as invalid", func() {
-		ok, _ := validator.IsValid("bearer bogus")
-		Expect(ok).To(BeFalse())
+		before := validations("malformed")
+
+		_, _, _, err := validator.Validate(ctx, "bearer bogus")
+		Expect(err).To(Equal(identity.ErrMalformedToken))
+		Expect(validations("malformed")).To(Equal(before + 1))
 	})
 
 	It("reports good tokens as valid regardless of the prefix case", func() {
-		ok, org := validator.IsValid("Bearer " + createJWT(jwtRequest{
+		before := validations("valid")
+
+		org, subject, _, err := validator.Validate(ctx, "Bearer "+createJWT(jwtRequest{
 			keyId:        "joe",
 			algorithm:    "RS256",
 			method:       jwt.SigningMethodRS256,
 			validSeconds: 500,
 			org:          "org1",
+			subject:      "user1",
 			signingKey:   privateKey,
 		}))
-		Expect(ok).To(BeTrue())
+		Expect(err).NotTo(HaveOccurred())
 		Expect(org).To(Equal("org1"))
+		Expect(subject).To(Equal("user1"))
+		Expect(validations("valid")).To(Equal(before + 1))
+	})
+
+	It("returns the scopes and roles of valid tokens", func() {
+		_, _, scopes, err := validator.Validate(ctx, "Bearer "+createJWT(jwtRequest{
+			keyId:        "joe",
+			algorithm:    "RS256",
+			method:       jwt.SigningMethodRS256,
+			validSeconds: 5,
+			org:          "org1",
+			scope:        "octos:read  octos:write",
+			roles:        []string{"garbanzos:read"},
+			signingKey:   privateKey,
+		}))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(scopes).To(Equal([]string{"octos:read", "octos:write", "garbanzos:read"}))
+	})
+
+	It("returns no scopes for tokens without scope or roles claims", func() {
+		_, _, scopes, err := validator.Validate(ctx, "Bearer "+createJWT(jwtRequest{
+			keyId:        "joe",
+			algorithm:    "RS256",
+			method:       jwt.SigningMethodRS256,
+			validSeconds: 5,
+			org:          "org1",
+			signingKey:   privateKey,
+		}))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(scopes).To(BeEmpty())
 	})
 
 	It("reports non-string key ids as invalid", func() {
-		ok, _ := validator.IsValid("Bearer " + createJWT(jwtRequest{
+		before := validations("unverifiable")
+
+		_, _, _, err := validator.Validate(ctx, "Bearer "+createJWT(jwtRequest{
 			keyId:        22,
 			algorithm:    "RS256",
 			method:       jwt.SigningMethodRS256,
@@ -93,11 +168,14 @@ var _ = Describe("Validator", func() {
 			org:          "org1",
 			signingKey:   privateKey,
 		}))
-		Expect(ok).To(BeFalse())
+		Expect(err).To(Equal(identity.ErrUnknownKey))
+		Expect(validations("unverifiable")).To(Equal(before + 1))
 	})
 
 	It("reports expired tokens as invalid", func() {
-		ok, _ := validator.IsValid("Bearer " + createJWT(jwtRequest{
+		before := validations("expired")
+
+		_, _, _, err := validator.Validate(ctx, "Bearer "+createJWT(jwtRequest{
 			keyId:        "joe",
 			algorithm:    "RS256",
 			method:       jwt.SigningMethodRS256,
@@ -105,11 +183,43 @@ var _ = Describe("Validator", func() {
 			org:          "org1",
 			signingKey:   privateKey,
 		}))
-		Expect(ok).To(BeFalse())
+		Expect(err).To(Equal(identity.ErrExpiredToken))
+		Expect(validations("expired")).To(Equal(before + 1))
 	})
 
 	It("reports good tokens with no matching key as invalid", func() {
-		ok, _ := validator.IsValid("Bearer " + createJWT(jwtRequest{
+		before := validations("unverifiable")
+
+		_, _, _, err := validator.Validate(ctx, "Bearer "+createJWT(jwtRequest{
+			keyId:        "alice",
+			algorithm:    "RS256",
+			method:       jwt.SigningMethodRS256,
+			validSeconds: 5,
+			org:          "org1",
+			signingKey:   privateKey,
+		}))
+		Expect(err).To(Equal(identity.ErrUnknownKey))
+		Expect(validations("unverifiable")).To(Equal(before + 1))
+	})
+
+	It("accepts tokens signed with a rotated key once the keys are refreshed", func() {
+		validator = identity.NewValidator(identity.NewKeySet("http://somewhere.com", mockHTTPClient, publicKeys, time.Hour, 0), identity.ValidatorConfig{})
+		mockHTTPClient.DoOutput.Resp <- &http.Response{
+			StatusCode: http.StatusOK,
+			Body: ioutil.NopCloser(strings.NewReader(`{
+				"keys": [
+					{
+						"kid": "alice",
+						"kty": "RSA",
+						"n": "` + base64.RawURLEncoding.EncodeToString(privateKey.N.Bytes()) + `",
+						"e": "AQAB"
+					}
+				]
+			}`)),
+		}
+		mockHTTPClient.DoOutput.Err <- nil
+
+		org, _, _, err := validator.Validate(ctx, "Bearer "+createJWT(jwtRequest{
 			keyId:        "alice",
 			algorithm:    "RS256",
 			method:       jwt.SigningMethodRS256,
@@ -117,11 +227,13 @@ var _ = Describe("Validator", func() {
 			org:          "org1",
 			signingKey:   privateKey,
 		}))
-		Expect(ok).To(BeFalse())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(org).To(Equal("org1"))
+		Expect(mockHTTPClient.DoCalled).To(Receive())
 	})
 
 	It("reports tokens with bad algorithms as invalid", func() {
-		ok, _ := validator.IsValid("Bearer " + createJWT(jwtRequest{
+		_, _, _, err := validator.Validate(ctx, "Bearer "+createJWT(jwtRequest{
 			keyId:        "joe",
 			algorithm:    "none",
 			method:       jwt.SigningMethodRS256,
@@ -129,11 +241,11 @@ var _ = Describe("Validator", func() {
 			org:          "org1",
 			signingKey:   privateKey,
 		}))
-		Expect(ok).To(BeFalse())
+		Expect(err).To(Equal(identity.ErrInvalidAlgorithm))
 	})
 
 	It("reports tokens with bad signing methods as invalid", func() {
-		ok, _ := validator.IsValid("Bearer " + createJWT(jwtRequest{
+		_, _, _, err := validator.Validate(ctx, "Bearer "+createJWT(jwtRequest{
 			keyId:        "joe",
 			algorithm:    "RS256",
 			method:       jwt.SigningMethodNone,
@@ -141,11 +253,11 @@ var _ = Describe("Validator", func() {
 			org:          "org1",
 			signingKey:   jwt.UnsafeAllowNoneSignatureType,
 		}))
-		Expect(ok).To(BeFalse())
+		Expect(err).To(Equal(identity.ErrInvalidSignature))
 	})
 
 	It("reports tokens with bad signing methods and bad algorithms as invalid", func() {
-		ok, _ := validator.IsValid("Bearer " + createJWT(jwtRequest{
+		_, _, _, err := validator.Validate(ctx, "Bearer "+createJWT(jwtRequest{
 			keyId:        "joe",
 			algorithm:    "none",
 			method:       jwt.SigningMethodNone,
@@ -153,17 +265,262 @@ var _ = Describe("Validator", func() {
 			org:          "org1",
 			signingKey:   jwt.UnsafeAllowNoneSignatureType,
 		}))
-		Expect(ok).To(BeFalse())
+		Expect(err).To(Equal(identity.ErrInvalidAlgorithm))
 	})
 
 	It("reports good tokens with no org claim as invalid", func() {
-		ok, _ := validator.IsValid("Bearer " + createJWT(jwtRequest{
+		before := validations("invalid_claims")
+
+		_, _, _, err := validator.Validate(ctx, "Bearer "+createJWT(jwtRequest{
 			keyId:        "joe",
 			algorithm:    "RS256",
 			method:       jwt.SigningMethodRS256,
 			validSeconds: 5,
 			signingKey:   privateKey,
 		}))
-		Expect(ok).To(BeFalse())
+		Expect(err).To(Equal(identity.ErrInvalidClaims))
+		Expect(validations("invalid_claims")).To(Equal(before + 1))
+	})
+
+	Describe("configured restrictions", func() {
+		validToken := func(request jwtRequest) string {
+			request.keyId = "joe"
+			request.org = "org1"
+			request.signingKey = privateKey
+			if request.method == nil {
+				request.algorithm = "RS256"
+				request.method = jwt.SigningMethodRS256
+			}
+			if request.validSeconds == 0 {
+				request.validSeconds = 5
+			}
+			return "Bearer " + createJWT(request)
+		}
+
+		It("accepts tokens from an expected issuer", func() {
+			validator = identity.NewValidator(keySet, identity.ValidatorConfig{
+				Issuers: []string{"https://auth.example.com/", "https://other.example.com/"},
+			})
+
+			_, _, _, err := validator.Validate(ctx, validToken(jwtRequest{issuer: "https://other.example.com/"}))
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("rejects tokens from other issuers", func() {
+			validator = identity.NewValidator(keySet, identity.ValidatorConfig{
+				Issuers: []string{"https://auth.example.com/"},
+			})
+			before := validations("invalid_issuer")
+
+			_, _, _, err := validator.Validate(ctx, validToken(jwtRequest{issuer: "https://evil.example.com/"}))
+			Expect(err).To(Equal(identity.ErrInvalidIssuer))
+			Expect(validations("invalid_issuer")).To(Equal(before + 1))
+		})
+
+		It("accepts tokens naming an expected audience in a single string", func() {
+			validator = identity.NewValidator(keySet, identity.ValidatorConfig{
+				Audiences: []string{"garbanzo"},
+			})
+
+			// A single audience is marshaled as a string
+			token, err := (&jwt.Token{
+				Header: map[string]interface{}{"kid": "joe", "alg": "RS256"},
+				Claims: jwt.MapClaims{
+					"aud":        "garbanzo",
+					"exp":        time.Now().Unix() + 5,
+					"custom:org": "org1",
+				},
+				Method: jwt.SigningMethodRS256,
+			}).SignedString(privateKey)
+			Expect(err).NotTo(HaveOccurred())
+
+			org, _, _, err := validator.Validate(ctx, "Bearer "+token)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(org).To(Equal("org1"))
+		})
+
+		It("accepts tokens naming an expected audience among others", func() {
+			validator = identity.NewValidator(keySet, identity.ValidatorConfig{
+				Audiences: []string{"garbanzo"},
+			})
+
+			_, _, _, err := validator.Validate(ctx, validToken(jwtRequest{audience: identity.Audience{"other", "garbanzo"}}))
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("rejects tokens for other audiences", func() {
+			validator = identity.NewValidator(keySet, identity.ValidatorConfig{
+				Audiences: []string{"garbanzo"},
+			})
+			before := validations("invalid_audience")
+
+			_, _, _, err := validator.Validate(ctx, validToken(jwtRequest{audience: identity.Audience{"other"}}))
+			Expect(err).To(Equal(identity.ErrInvalidAudience))
+
+			_, _, _, err = validator.Validate(ctx, validToken(jwtRequest{}))
+			Expect(err).To(Equal(identity.ErrInvalidAudience))
+			Expect(validations("invalid_audience")).To(Equal(before + 2))
+		})
+
+		It("allows for clock skew", func() {
+			validator = identity.NewValidator(keySet, identity.ValidatorConfig{
+				Leeway: 30 * time.Second,
+			})
+
+			_, _, _, err := validator.Validate(ctx, validToken(jwtRequest{validSeconds: -10}))
+			Expect(err).NotTo(HaveOccurred())
+
+			_, _, _, err = validator.Validate(ctx, validToken(jwtRequest{notBefore: time.Now().Unix() + 10}))
+			Expect(err).NotTo(HaveOccurred())
+
+			_, _, _, err = validator.Validate(ctx, validToken(jwtRequest{validSeconds: -60}))
+			Expect(err).To(Equal(identity.ErrExpiredToken))
+
+			_, _, _, err = validator.Validate(ctx, validToken(jwtRequest{notBefore: time.Now().Unix() + 60}))
+			Expect(err).To(Equal(identity.ErrTokenNotValidYet))
+		})
+
+		It("rejects tokens not valid yet without leeway", func() {
+			before := validations("not_valid_yet")
+
+			_, _, _, err := validator.Validate(ctx, validToken(jwtRequest{notBefore: time.Now().Unix() + 10}))
+			Expect(err).To(Equal(identity.ErrTokenNotValidYet))
+			Expect(validations("not_valid_yet")).To(Equal(before + 1))
+		})
+
+		It("only accepts RS256 by default", func() {
+			before := validations("invalid_algorithm")
+
+			_, _, _, err := validator.Validate(ctx, validToken(jwtRequest{algorithm: "RS512", method: jwt.SigningMethodRS512}))
+			Expect(err).To(Equal(identity.ErrInvalidAlgorithm))
+			Expect(validations("invalid_algorithm")).To(Equal(before + 1))
+		})
+
+		It("accepts the configured algorithms", func() {
+			validator = identity.NewValidator(keySet, identity.ValidatorConfig{
+				Algorithms: []string{"RS512"},
+			})
+
+			_, _, _, err := validator.Validate(ctx, validToken(jwtRequest{algorithm: "RS512", method: jwt.SigningMethodRS512}))
+			Expect(err).NotTo(HaveOccurred())
+
+			_, _, _, err = validator.Validate(ctx, validToken(jwtRequest{}))
+			Expect(err).To(Equal(identity.ErrInvalidAlgorithm))
+		})
+
+		Context("EC and Ed25519 keys", func() {
+			var (
+				es256Key, es384Key *ecdsa.PrivateKey
+				ed25519Key         ed25519.PrivateKey
+			)
+
+			BeforeEach(func() {
+				var err error
+				es256Key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+				Expect(err).NotTo(HaveOccurred())
+				es384Key, err = ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+				Expect(err).NotTo(HaveOccurred())
+				var ed25519Public ed25519.PublicKey
+				ed25519Public, ed25519Key, err = ed25519.GenerateKey(rand.Reader)
+				Expect(err).NotTo(HaveOccurred())
+
+				keySet = identity.NewKeySet("http://somewhere.com", mockHTTPClient, map[string]crypto.PublicKey{
+					"joe":   &privateKey.PublicKey,
+					"es256": &es256Key.PublicKey,
+					"es384": &es384Key.PublicKey,
+					"ed":    ed25519Public,
+				}, time.Hour, time.Hour)
+				validator = identity.NewValidator(keySet, identity.ValidatorConfig{
+					Algorithms: []string{"RS256", "ES256", "ES384", "EdDSA"},
+				})
+			})
+
+			It("accepts tokens signed with each algorithm", func() {
+				for keyId, request := range map[string]jwtRequest{
+					"es256": {algorithm: "ES256", method: jwt.SigningMethodES256, signingKey: es256Key},
+					"es384": {algorithm: "ES384", method: jwt.SigningMethodES384, signingKey: es384Key},
+					"ed":    {algorithm: "EdDSA", method: identity.SigningMethodEdDSA, signingKey: ed25519Key},
+				} {
+					request.keyId = keyId
+					request.org = "org1"
+					request.validSeconds = 5
+
+					org, _, _, err := validator.Validate(ctx, "Bearer "+createJWT(request))
+					Expect(err).NotTo(HaveOccurred(), keyId)
+					Expect(org).To(Equal("org1"))
+				}
+			})
+
+			It("rejects tokens whose algorithm doesn't match the key", func() {
+				_, _, _, err := validator.Validate(ctx, "Bearer "+createJWT(jwtRequest{
+					keyId:        "joe",
+					algorithm:    "ES256",
+					method:       jwt.SigningMethodES256,
+					validSeconds: 5,
+					org:          "org1",
+					signingKey:   es256Key,
+				}))
+				Expect(err).To(Equal(identity.ErrInvalidSignature))
+
+				_, _, _, err = validator.Validate(ctx, "Bearer "+createJWT(jwtRequest{
+					keyId:        "es256",
+					algorithm:    "EdDSA",
+					method:       identity.SigningMethodEdDSA,
+					validSeconds: 5,
+					org:          "org1",
+					signingKey:   ed25519Key,
+				}))
+				Expect(err).To(Equal(identity.ErrInvalidSignature))
+			})
+
+			It("rejects Ed25519 tokens with a bad signature", func() {
+				token := createJWT(jwtRequest{
+					keyId:        "ed",
+					algorithm:    "EdDSA",
+					method:       identity.SigningMethodEdDSA,
+					validSeconds: 5,
+					org:          "org2",
+					signingKey:   ed25519Key,
+				})
+				parts := strings.Split(token, ".")
+				forged := createJWT(jwtRequest{
+					keyId:        "ed",
+					algorithm:    "EdDSA",
+					method:       identity.SigningMethodEdDSA,
+					validSeconds: 5,
+					org:          "org1",
+					signingKey:   ed25519Key,
+				})
+				forgedParts := strings.Split(forged, ".")
+
+				_, _, _, err := validator.Validate(ctx, "Bearer "+forgedParts[0]+"."+forgedParts[1]+"."+parts[2])
+				Expect(err).To(Equal(identity.ErrInvalidSignature))
+			})
+		})
+
+		It("rejects HMAC tokens keyed with the public key", func() {
+			validator = identity.NewValidator(keySet, identity.ValidatorConfig{
+				Algorithms: []string{"RS256", "RS512"},
+			})
+
+			token, err := (&jwt.Token{
+				Header: map[string]interface{}{"kid": "joe", "alg": "HS256"},
+				Claims: &identity.CustomClaims{Org: "org1"},
+				Method: jwt.SigningMethodHS256,
+			}).SignedString(privateKey.PublicKey.N.Bytes())
+			Expect(err).NotTo(HaveOccurred())
+
+			_, _, _, err = validator.Validate(ctx, "Bearer "+token)
+			Expect(err).To(Equal(identity.ErrInvalidAlgorithm))
+		})
+	})
+
+	It("has keys to check tokens with", func() {
+		Expect(validator.CheckKeys()).To(Succeed())
+	})
+
+	It("reports missing keys", func() {
+		validator = identity.NewValidator(identity.NewKeySet("http://somewhere.com", mockHTTPClient, map[string]crypto.PublicKey{}, time.Hour, time.Hour), identity.ValidatorConfig{})
+		Expect(validator.CheckKeys()).To(MatchError("no public keys loaded"))
 	})
 })
@@ -0,0 +1,41 @@
+package logs
+
+import (
+	"context"
+)
+
+// Fields are added to every line logged with a context carrying them.
+type Fields map[string]interface{}
+
+type contextKey int
+
+const fieldsContextKey contextKey = 0
+
+// WithFields returns a copy of ctx carrying fields along with any fields
+// already in ctx.
+func WithFields(ctx context.Context, fields Fields) context.Context {
+	merged := Fields{}
+	for k, v := range contextFields(ctx) {
+		merged[k] = v
+	}
+	for k, v := range fields {
+		merged[k] = v
+	}
+
+	return context.WithValue(ctx, fieldsContextKey, merged)
+}
+
+// FromContext returns a logger whose lines include the fields in ctx (e.g.
+// the request id, org, route and method of a request).
+func FromContext(ctx context.Context) ExternalLogger {
+	return entry.WithContext(ctx)
+}
+
+func contextFields(ctx context.Context) Fields {
+	if ctx == nil {
+		return nil
+	}
+
+	fields, _ := ctx.Value(fieldsContextKey).(Fields)
+	return fields
+}
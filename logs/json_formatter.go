@@ -64,6 +64,10 @@ type JSONFormatter struct {
 // Format renders a single log entry
 func (f *JSONFormatter) Format(entry *logrus.Entry) ([]byte, error) {
 	data := make(logrus.Fields, len(entry.Data)+3)
+	// Fields logged explicitly win over those from the context
+	for k, v := range contextFields(entry.Context) {
+		data[k] = v
+	}
 	for k, v := range entry.Data {
 		switch v := v.(type) {
 		case error:
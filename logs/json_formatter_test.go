@@ -1,6 +1,7 @@
 package logs_test
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 
@@ -166,4 +167,20 @@ var _ = Describe("Health", func() {
 		s := string(b)
 		Expect(s).To(ContainSubstring("\"level\":\"INFO\""))
 	})
+
+	It("JSON context fields", func() {
+		formatter := &logs.JSONFormatter{}
+
+		ctx := logs.WithFields(context.Background(), logs.Fields{"request_id": "abc123", "org": "my-org"})
+		ctx = logs.WithFields(ctx, logs.Fields{"org": "other-org"})
+		b, err := formatter.Format(logrus.WithContext(ctx).WithField("request_id", "explicit"))
+		Expect(err).NotTo(HaveOccurred())
+
+		entry := make(map[string]interface{})
+		err = json.Unmarshal(b, &entry)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(entry["org"]).To(Equal("other-org"))
+		Expect(entry["request_id"]).To(Equal("explicit"))
+	})
 })
@@ -8,6 +8,8 @@ import (
 
 var (
 	Logger ExternalLogger
+
+	entry *logrus.Entry
 )
 
 type ExternalLogger interface {
@@ -22,7 +24,8 @@ type ExternalLogger interface {
 }
 
 func init() {
-	Logger = logrus.WithField("service_name", "effective-octo-garbanzo")
+	entry = logrus.WithField("service_name", "effective-octo-garbanzo")
+	Logger = entry
 	logrus.SetFormatter(&JSONFormatter{
 		FieldMap: FieldMap{
 			FieldKeyTime:  "@timestamp",
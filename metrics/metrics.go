@@ -0,0 +1,81 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "garbanzo"
+
+// Registry holds every metric the service exposes. Metrics are registered with
+// it rather than the Prometheus default registry so only the service's own
+// metrics are exposed.
+var Registry = prometheus.NewRegistry()
+
+var (
+	HTTPRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by route template, method and response status.",
+	}, []string{"route", "method", "status"})
+
+	HTTPRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by route template, method and response status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method", "status"})
+
+	QueryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "db_query_duration_seconds",
+		Help:      "Store query latency by store and method.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"query"})
+
+	Transactions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "db_transactions_total",
+		Help:      "Finished transactions by outcome (commit or rollback) and whether finishing failed.",
+	}, []string{"outcome", "status"})
+
+	JWTValidations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "jwt_validations_total",
+		Help:      "JWT validations by result, either valid or the reason the token was rejected.",
+	}, []string{"result"})
+
+	KeyRefreshes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "jwks_refreshes_total",
+		Help:      "Verifier key set refreshes by trigger (scheduled or unknown_kid) and result (ok or error).",
+	}, []string{"trigger", "result"})
+
+	KeyRefreshTimestamp = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "jwks_last_refresh_timestamp_seconds",
+		Help:      "When the verifier keys were last refreshed successfully, in seconds since the epoch.",
+	})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HTTPRequests,
+		HTTPRequestDuration,
+		QueryDuration,
+		Transactions,
+		JWTValidations,
+		KeyRefreshes,
+		KeyRefreshTimestamp,
+	)
+}
+
+// Handler serves the registered metrics in the Prometheus exposition format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
@@ -0,0 +1,27 @@
+package metrics_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/myshkin5/effective-octo-garbanzo/metrics"
+)
+
+var _ = Describe("Metrics", func() {
+	It("serves the registered metrics", func() {
+		metrics.JWTValidations.WithLabelValues("valid").Inc()
+
+		recorder := httptest.NewRecorder()
+		request, err := http.NewRequest(http.MethodGet, "/metrics", nil)
+		Expect(err).NotTo(HaveOccurred())
+
+		metrics.Handler().ServeHTTP(recorder, request)
+
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(recorder.Body.String()).To(ContainSubstring(`garbanzo_jwt_validations_total{result="valid"} 1`))
+		Expect(recorder.Body.String()).To(ContainSubstring("go_goroutines"))
+	})
+})
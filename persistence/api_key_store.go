@@ -0,0 +1,112 @@
+package persistence
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/myshkin5/effective-octo-garbanzo/logs"
+	"github.com/myshkin5/effective-octo-garbanzo/persistence/data"
+)
+
+// APIKeyStore manages the API keys of every org. Like OrgStore, it is not
+// scoped by the org of the context as keys are managed by admins and looked
+// up before the org of a request is known. Scopes are stored space separated.
+type APIKeyStore struct{}
+
+func (APIKeyStore) FetchByOrgName(ctx context.Context, database Database, orgName string) ([]data.APIKey, error) {
+	defer observeQuery("APIKeyStore.FetchByOrgName")()
+
+	query := `select k.id, k.prefix, k.name, k.scopes, k.created_at, k.last_used_at from api_key k
+		join org on k.org_id = org.id
+		where org.name = $1
+		order by k.id`
+
+	rows, err := database.Query(ctx, query, orgName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var apiKeys []data.APIKey
+	for rows.Next() {
+		apiKey := data.APIKey{OrgName: orgName}
+		var scopes string
+		var lastUsedAt sql.NullTime
+		err = rows.Scan(&apiKey.Id, &apiKey.Prefix, &apiKey.Name, &scopes, &apiKey.CreatedAt, &lastUsedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		apiKey.Scopes = strings.Fields(scopes)
+		apiKey.LastUsedAt = lastUsedAt.Time
+		apiKeys = append(apiKeys, apiKey)
+	}
+
+	return apiKeys, nil
+}
+
+func (APIKeyStore) FetchByHash(ctx context.Context, database Database, hash string) (data.APIKey, error) {
+	defer observeQuery("APIKeyStore.FetchByHash")()
+
+	query := `select k.id, k.prefix, org.name, k.name, k.scopes, k.created_at, k.last_used_at from api_key k
+		join org on k.org_id = org.id
+		where k.key_hash = $1`
+
+	apiKey := data.APIKey{Hash: hash}
+	var scopes string
+	var lastUsedAt sql.NullTime
+	err := database.QueryRow(ctx, query, hash).Scan(
+		&apiKey.Id, &apiKey.Prefix, &apiKey.OrgName, &apiKey.Name, &scopes, &apiKey.CreatedAt, &lastUsedAt)
+	if err == sql.ErrNoRows {
+		return data.APIKey{}, ErrNotFound
+	} else if err != nil {
+		return data.APIKey{}, err
+	}
+
+	apiKey.Scopes = strings.Fields(scopes)
+	apiKey.LastUsedAt = lastUsedAt.Time
+
+	return apiKey, nil
+}
+
+func (APIKeyStore) Create(ctx context.Context, database Database, apiKey data.APIKey) (int, error) {
+	defer observeQuery("APIKeyStore.Create")()
+
+	query := `insert into api_key (prefix, key_hash, org_id, name, scopes, created_at)
+		select $1, $2, id, $3, $4, $5 from org where name = $6 returning id`
+	id, err := ExecInsert(ctx, database, query, apiKey.Prefix, apiKey.Hash, apiKey.Name,
+		strings.Join(apiKey.Scopes, " "), apiKey.CreatedAt, apiKey.OrgName)
+	if err == sql.ErrNoRows {
+		return 0, ErrOrgNotFound
+	}
+
+	return id, err
+}
+
+func (APIKeyStore) UpdateLastUsed(ctx context.Context, database Database, id int, lastUsedAt time.Time) error {
+	defer observeQuery("APIKeyStore.UpdateLastUsed")()
+
+	query := "update api_key set last_used_at = $1 where id = $2"
+	_, err := ExecUpdate(ctx, database, query, lastUsedAt, id)
+	return err
+}
+
+func (APIKeyStore) DeleteByPrefixAndOrgName(ctx context.Context, database Database, prefix, orgName string) error {
+	defer observeQuery("APIKeyStore.DeleteByPrefixAndOrgName")()
+
+	query := "delete from api_key where prefix = $1 and org_id = (select id from org where name = $2)"
+	rowsAffected, err := ExecDelete(ctx, database, query, prefix, orgName)
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrNotFound
+	} else if rowsAffected > 1 {
+		logs.FromContext(ctx).Panic("Deleted multiple rows when expecting only one")
+	}
+
+	return nil
+}
@@ -0,0 +1,179 @@
+package persistence_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/myshkin5/effective-octo-garbanzo/persistence"
+	"github.com/myshkin5/effective-octo-garbanzo/persistence/data"
+)
+
+var _ = Describe("APIKeyStore Integration", func() {
+	var (
+		database  persistence.Database
+		store     persistence.APIKeyStore
+		orgName   string
+		createdAt time.Time
+	)
+
+	BeforeEach(func() {
+		var err error
+		database, err = persistence.Open()
+		Expect(err).NotTo(HaveOccurred())
+
+		cleanDatabase(database)
+
+		store = persistence.APIKeyStore{}
+
+		_, orgName = createOrg("api_key_store", database)
+		createdAt = time.Now()
+	})
+
+	createAPIKey := func(prefix string) int {
+		id, err := store.Create(ctx, database, data.APIKey{
+			Prefix:    prefix,
+			Hash:      prefix + "-hash",
+			OrgName:   orgName,
+			Name:      "feeder",
+			Scopes:    []string{"garbanzos:read", "garbanzos:write"},
+			CreatedAt: createdAt,
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		return id
+	}
+
+	Describe("FetchByOrgName", func() {
+		It("fetches the keys of the org", func() {
+			id1 := createAPIKey("prefix1")
+			id2 := createAPIKey("prefix2")
+			_, otherOrgName := createOrg("api_key_store_other", database)
+			_, err := store.Create(ctx, database, data.APIKey{
+				Prefix:    "prefix3",
+				Hash:      "prefix3-hash",
+				OrgName:   otherOrgName,
+				Name:      "other",
+				CreatedAt: createdAt,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			apiKeys, err := store.FetchByOrgName(ctx, database, orgName)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(apiKeys).To(HaveLen(2))
+			Expect(apiKeys[0].Id).To(Equal(id1))
+			Expect(apiKeys[0].Prefix).To(Equal("prefix1"))
+			Expect(apiKeys[0].Hash).To(BeEmpty())
+			Expect(apiKeys[0].OrgName).To(Equal(orgName))
+			Expect(apiKeys[0].Name).To(Equal("feeder"))
+			Expect(apiKeys[0].Scopes).To(Equal([]string{"garbanzos:read", "garbanzos:write"}))
+			Expect(apiKeys[0].CreatedAt).To(BeTemporally("~", createdAt, time.Millisecond))
+			Expect(apiKeys[0].LastUsedAt.IsZero()).To(BeTrue())
+			Expect(apiKeys[1].Id).To(Equal(id2))
+		})
+
+		It("returns no keys for an org without any", func() {
+			apiKeys, err := store.FetchByOrgName(ctx, database, orgName)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(apiKeys).To(BeEmpty())
+		})
+	})
+
+	Describe("FetchByHash", func() {
+		It("returns not found for an unknown hash", func() {
+			_, err := store.FetchByHash(ctx, database, "unknown-hash")
+
+			Expect(err).To(Equal(persistence.ErrNotFound))
+		})
+
+		It("fetches a key along with its org", func() {
+			id := createAPIKey("prefix1")
+
+			apiKey, err := store.FetchByHash(ctx, database, "prefix1-hash")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(apiKey.Id).To(Equal(id))
+			Expect(apiKey.Prefix).To(Equal("prefix1"))
+			Expect(apiKey.OrgName).To(Equal(orgName))
+			Expect(apiKey.Scopes).To(Equal([]string{"garbanzos:read", "garbanzos:write"}))
+		})
+	})
+
+	Describe("Create", func() {
+		It("returns org not found when the org doesn't exist", func() {
+			_, err := store.Create(ctx, database, data.APIKey{
+				Prefix:    "prefix1",
+				Hash:      "prefix1-hash",
+				OrgName:   "int_test_org_unknown",
+				Name:      "feeder",
+				CreatedAt: createdAt,
+			})
+
+			Expect(err).To(Equal(persistence.ErrOrgNotFound))
+		})
+
+		It("returns a duplicate error when the prefix is already taken", func() {
+			createAPIKey("prefix1")
+
+			_, err := store.Create(ctx, database, data.APIKey{
+				Prefix:    "prefix1",
+				Hash:      "other-hash",
+				OrgName:   orgName,
+				Name:      "feeder",
+				CreatedAt: createdAt,
+			})
+			Expect(err).To(Equal(persistence.ErrDuplicate))
+		})
+	})
+
+	Describe("UpdateLastUsed", func() {
+		It("records when the key was last used", func() {
+			id := createAPIKey("prefix1")
+			lastUsedAt := time.Now().Add(time.Minute)
+
+			Expect(store.UpdateLastUsed(ctx, database, id, lastUsedAt)).To(Succeed())
+
+			apiKey, err := store.FetchByHash(ctx, database, "prefix1-hash")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(apiKey.LastUsedAt).To(BeTemporally("~", lastUsedAt, time.Millisecond))
+		})
+	})
+
+	Describe("DeleteByPrefixAndOrgName", func() {
+		It("returns not found when deleting an unknown key", func() {
+			err := store.DeleteByPrefixAndOrgName(ctx, database, "unknown", orgName)
+
+			Expect(err).To(Equal(persistence.ErrNotFound))
+		})
+
+		It("returns not found when deleting the key of another org", func() {
+			createAPIKey("prefix1")
+			_, otherOrgName := createOrg("api_key_store_other", database)
+
+			err := store.DeleteByPrefixAndOrgName(ctx, database, "prefix1", otherOrgName)
+			Expect(err).To(Equal(persistence.ErrNotFound))
+		})
+
+		It("deletes a key", func() {
+			createAPIKey("prefix1")
+
+			Expect(store.DeleteByPrefixAndOrgName(ctx, database, "prefix1", orgName)).To(Succeed())
+
+			_, err := store.FetchByHash(ctx, database, "prefix1-hash")
+			Expect(err).To(Equal(persistence.ErrNotFound))
+		})
+	})
+
+	It("deletes the keys of a deleted org", func() {
+		createAPIKey("prefix1")
+		org, err := persistence.OrgStore{}.FetchByName(ctx, database, orgName, false)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(persistence.OrgStore{}.DeleteById(ctx, database, org.Id)).To(Succeed())
+
+		_, err = store.FetchByHash(ctx, database, "prefix1-hash")
+		Expect(err).To(Equal(persistence.ErrNotFound))
+	})
+})
@@ -0,0 +1,90 @@
+package persistence
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"github.com/myshkin5/effective-octo-garbanzo/persistence/data"
+)
+
+// AuditEventStore records the changes made to an org's octos and garbanzos.
+// Events are never updated or deleted (short of deleting the org).
+type AuditEventStore struct{}
+
+// FetchAll fetches a page of the org's events matching the filter. Events
+// can't be sorted by a field, only by id (i.e. the order they were created).
+func (AuditEventStore) FetchAll(ctx context.Context, database Database, filter AuditFilter, page Page) ([]data.AuditEvent, bool, error) {
+	defer observeQuery("AuditEventStore.FetchAll")()
+
+	params := params{org(ctx)}
+	conditions := append([]string{"org.name = $1"}, filter.conditions(&params)...)
+	condition, orderBy, err := page.keyset("a.id", nil, &params)
+	if err != nil {
+		return nil, false, err
+	}
+	conditions = append(conditions, condition)
+	query := `select a.id, a.actor, a.action, a.resource, a.before_state, a.after_state, a.request_id, a.created_at
+		from audit_event a
+		join org on a.org_id = org.id
+		where ` + strings.Join(conditions, " and ") + `
+		` + orderBy
+
+	rows, err := database.Query(ctx, query, params...)
+	if err != nil {
+		return nil, false, err
+	}
+	defer rows.Close()
+
+	var events []data.AuditEvent
+	for rows.Next() {
+		var event data.AuditEvent
+		var before, after sql.NullString
+		err = rows.Scan(&event.Id, &event.Actor, &event.Action, &event.Resource, &before, &after,
+			&event.RequestID, &event.CreatedAt)
+		if err != nil {
+			return nil, false, err
+		}
+		if before.Valid {
+			event.Before = []byte(before.String)
+		}
+		if after.Valid {
+			event.After = []byte(after.String)
+		}
+
+		events = append(events, event)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, false, err
+	}
+
+	count, more := page.trim(len(events))
+	events = events[:count]
+	if page.Backward() {
+		for i, j := 0, len(events)-1; i < j; i, j = i+1, j-1 {
+			events[i], events[j] = events[j], events[i]
+		}
+	}
+
+	return events, more, nil
+}
+
+func (AuditEventStore) Create(ctx context.Context, database Database, event data.AuditEvent) (int, error) {
+	defer observeQuery("AuditEventStore.Create")()
+
+	query := `insert into audit_event (org_id, actor, action, resource, before_state, after_state, request_id, created_at)
+		select id, $1, $2, $3, $4, $5, $6, $7 from org where name = $8 returning id`
+	id, err := ExecInsert(ctx, database, query, event.Actor, event.Action, event.Resource, nullJSON(event.Before),
+		nullJSON(event.After), event.RequestID, event.CreatedAt.UTC(), org(ctx))
+	if err == sql.ErrNoRows {
+		return 0, ErrOrgNotFound
+	}
+
+	return id, err
+}
+
+// nullJSON stores a missing snapshot as null rather than an empty string.
+func nullJSON(snapshot []byte) sql.NullString {
+	return sql.NullString{String: string(snapshot), Valid: snapshot != nil}
+}
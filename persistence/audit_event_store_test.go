@@ -0,0 +1,117 @@
+package persistence_test
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/myshkin5/effective-octo-garbanzo/persistence"
+	"github.com/myshkin5/effective-octo-garbanzo/persistence/data"
+)
+
+var _ = Describe("AuditEventStore Integration", func() {
+	var (
+		database         persistence.Database
+		store            persistence.AuditEventStore
+		org1Ctx, org2Ctx context.Context
+		start            time.Time
+	)
+
+	BeforeEach(func() {
+		var err error
+		database, err = persistence.Open()
+		Expect(err).NotTo(HaveOccurred())
+
+		cleanDatabase(database)
+
+		_, orgName := createOrg("audit_event_store", database)
+		_, orgName2 := createOrg("audit_event_store2", database)
+
+		org1Ctx = context.WithValue(ctx, persistence.OrgContextKey, orgName)
+		org2Ctx = context.WithValue(ctx, persistence.OrgContextKey, orgName2)
+
+		store = persistence.AuditEventStore{}
+
+		start = time.Now().Add(-time.Hour)
+		for i, resource := range []string{"octos/kraken", "octos/kraken/garbanzos/abc", "octos/kraken2"} {
+			_, err = store.Create(org1Ctx, database, data.AuditEvent{
+				Actor:     "user1",
+				Action:    "create",
+				Resource:  resource,
+				After:     []byte(`{"name": "kraken"}`),
+				RequestID: "req1",
+				CreatedAt: start.Add(time.Duration(i) * time.Minute),
+			})
+			Expect(err).NotTo(HaveOccurred())
+		}
+	})
+
+	It("creates and fetches the org's events", func() {
+		events, more, err := store.FetchAll(org1Ctx, database, persistence.AuditFilter{}, persistence.Page{Limit: 10})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(more).To(BeFalse())
+		Expect(events).To(HaveLen(3))
+		Expect(events[0].Id).NotTo(BeZero())
+		Expect(events[0].Actor).To(Equal("user1"))
+		Expect(events[0].Action).To(Equal("create"))
+		Expect(events[0].Resource).To(Equal("octos/kraken"))
+		Expect(events[0].Before).To(BeNil())
+		Expect(events[0].After).To(MatchJSON(`{"name": "kraken"}`))
+		Expect(events[0].RequestID).To(Equal("req1"))
+		Expect(events[0].CreatedAt).To(BeTemporally("~", start, time.Second))
+
+		events, _, err = store.FetchAll(org2Ctx, database, persistence.AuditFilter{}, persistence.Page{Limit: 10})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(events).To(BeEmpty())
+	})
+
+	It("filters events by time", func() {
+		events, _, err := store.FetchAll(org1Ctx, database, persistence.AuditFilter{
+			From: start.Add(30 * time.Second),
+			To:   start.Add(90 * time.Second),
+		}, persistence.Page{Limit: 10})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(events).To(HaveLen(1))
+		Expect(events[0].Resource).To(Equal("octos/kraken/garbanzos/abc"))
+	})
+
+	It("filters events by a resource and the resources beneath it", func() {
+		events, _, err := store.FetchAll(org1Ctx, database, persistence.AuditFilter{
+			Resource: "octos/kraken",
+		}, persistence.Page{Limit: 10})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(events).To(HaveLen(2))
+		Expect(events[0].Resource).To(Equal("octos/kraken"))
+		Expect(events[1].Resource).To(Equal("octos/kraken/garbanzos/abc"))
+
+		events, _, err = store.FetchAll(org1Ctx, database, persistence.AuditFilter{
+			Resource: "octos/k_aken",
+		}, persistence.Page{Limit: 10})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(events).To(BeEmpty(), "underscores aren't wildcards")
+	})
+
+	It("fetches the newest events first when descending", func() {
+		page := persistence.Page{Limit: 2, Sort: persistence.Sort{Descending: true}}
+		events, more, err := store.FetchAll(org1Ctx, database, persistence.AuditFilter{}, page)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(more).To(BeTrue())
+		Expect(events).To(HaveLen(2))
+		Expect(events[0].Resource).To(Equal("octos/kraken2"))
+
+		page.AfterId = events[1].Id
+		events, more, err = store.FetchAll(org1Ctx, database, persistence.AuditFilter{}, page)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(more).To(BeFalse())
+		Expect(events).To(HaveLen(1))
+		Expect(events[0].Resource).To(Equal("octos/kraken"))
+	})
+
+	It("returns org not found for an unknown org", func() {
+		orgCtx := context.WithValue(ctx, persistence.OrgContextKey, "int_test_org_unknown")
+		_, err := store.Create(orgCtx, database, data.AuditEvent{})
+		Expect(err).To(Equal(persistence.ErrOrgNotFound))
+	})
+})
@@ -0,0 +1,35 @@
+package persistence
+
+import (
+	"strings"
+	"time"
+)
+
+// AuditFilter restricts the audit events fetched. Events must be created at or
+// after From and before To (each when not zero) and be of Resource or of a
+// resource beneath it, e.g. the garbanzos of an octo (when not empty).
+type AuditFilter struct {
+	From     time.Time
+	To       time.Time
+	Resource string
+}
+
+// likeEscaper escapes the wildcards of a like pattern
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+func (f AuditFilter) conditions(params *params) []string {
+	var conditions []string
+
+	if !f.From.IsZero() {
+		conditions = append(conditions, "a.created_at >= "+params.add(f.From.UTC()))
+	}
+	if !f.To.IsZero() {
+		conditions = append(conditions, "a.created_at < "+params.add(f.To.UTC()))
+	}
+	if f.Resource != "" {
+		conditions = append(conditions, "(a.resource = "+params.add(f.Resource)+
+			" or a.resource like "+params.add(likeEscaper.Replace(f.Resource)+"/%")+` escape '\')`)
+	}
+
+	return conditions
+}
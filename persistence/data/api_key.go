@@ -0,0 +1,15 @@
+package data
+
+import "time"
+
+type APIKey struct {
+	Id      int
+	Prefix  string
+	Hash    string
+	OrgName string
+	Name    string
+	Scopes  []string
+	// LastUsedAt is zero until the key is first used
+	CreatedAt  time.Time
+	LastUsedAt time.Time
+}
@@ -0,0 +1,17 @@
+package data
+
+import "time"
+
+// AuditEvent records a create, update, move or delete of an octo or garbanzo.
+// Resource is the path of the octo or garbanzo relative to the API's base URL.
+// Before and After are JSON snapshots of it, nil when it didn't exist.
+type AuditEvent struct {
+	Id        int
+	Actor     string
+	Action    string
+	Resource  string
+	Before    []byte
+	After     []byte
+	RequestID string
+	CreatedAt time.Time
+}
@@ -8,4 +8,5 @@ type Garbanzo struct {
 	GarbanzoType GarbanzoType
 	DiameterMM   float32
 	OctoId       int
+	Version      int
 }
@@ -0,0 +1,13 @@
+package data
+
+import "time"
+
+// IdempotentRequest records the response to a create sent with an
+// Idempotency-Key header so that retries of the request receive the same
+// response. The response is opaque to everything but the handlers.
+type IdempotentRequest struct {
+	Key         string
+	RequestHash string
+	Response    []byte
+	CreatedAt   time.Time
+}
@@ -1,6 +1,7 @@
 package data
 
 type Octo struct {
-	Id   int
-	Name string
+	Id      int
+	Name    string
+	Version int
 }
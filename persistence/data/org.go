@@ -0,0 +1,6 @@
+package data
+
+type Org struct {
+	Id   int
+	Name string
+}
@@ -9,18 +9,27 @@ import (
 	"strconv"
 	"time"
 
-	// Used by main.go and tests to import the proper database driver
-	_ "github.com/lib/pq"
 	"github.com/mattes/migrate"
+	"github.com/mattes/migrate/source"
 	// Used by main.go and tests to import the proper migration drivers
 	_ "github.com/mattes/migrate/database/postgres"
+	_ "github.com/mattes/migrate/database/sqlite3"
 	_ "github.com/mattes/migrate/source/file"
 
 	"github.com/myshkin5/effective-octo-garbanzo/logs"
+	"github.com/myshkin5/effective-octo-garbanzo/tracing"
 )
 
 var (
-	ErrNotFound = errors.New("identified data not found")
+	ErrNotFound    = errors.New("identified data not found")
+	ErrDuplicate   = errors.New("identified data already exists")
+	ErrInUse       = errors.New("identified data is still referenced")
+	ErrOrgNotFound = errors.New("org not found")
+	ErrCloseTx     = errors.New("transactions are committed or rolled back, not closed")
+	ErrDirtySchema = errors.New("a migration failed part way, the schema must be fixed by hand")
+	// ErrVersionMismatch is returned when a versioned update or delete names
+	// a version the row no longer has (or a row that no longer exists)
+	ErrVersionMismatch = errors.New("identified data has been modified")
 )
 
 type Database interface {
@@ -30,16 +39,34 @@ type Database interface {
 	BeginTx(ctx context.Context) (database Database, err error)
 	Commit() (err error)
 	Rollback() (err error)
+	// Ping verifies the database is still reachable.
+	Ping(ctx context.Context) (err error)
+	// Close releases the database once it is no longer needed, waiting for
+	// queries already underway to finish.
+	Close() (err error)
 }
 
 const OrgContextKey = "org"
 
+// Context keys of the request details recorded with each audit event
+const (
+	SubjectContextKey   = "subject"
+	RequestIDContextKey = "request_id"
+)
+
 func org(ctx context.Context) string {
 	return ctx.Value(OrgContextKey).(string)
 }
 
+// Open connects to the database selected by DB_DRIVER, either postgres (the
+// default) or sqlite3.
 func Open() (Database, error) {
-	db, err := sql.Open("postgres", getDatabaseURL())
+	dialect, err := newDialect()
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := sql.Open(dialect.driverName(), dialect.dataSourceName())
 	if err != nil {
 		return nil, err
 	}
@@ -56,28 +83,77 @@ func Open() (Database, error) {
 	return &database{
 		internalDB: db,
 		internalTx: nil,
+		dialect:    dialect,
 	}, nil
 }
 
 func ExecInsert(ctx context.Context, database Database, query string, args ...interface{}) (int, error) {
 	var id int
 	err := database.QueryRow(ctx, query, args...).Scan(&id)
-	if err != nil {
+	if isViolation(database, err, uniqueViolation) {
+		return 0, ErrDuplicate
+	} else if err != nil {
 		return 0, err
 	}
 
 	return id, nil
 }
 
+func ExecUpdate(ctx context.Context, database Database, query string, args ...interface{}) (int64, error) {
+	result, err := database.Exec(ctx, query, args...)
+	if isViolation(database, err, uniqueViolation) {
+		return 0, ErrDuplicate
+	} else if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// ExecVersionedUpdate executes an update returning the row's new version. When
+// no row is updated the error is ErrVersionMismatch if the update was
+// conditional on expectedVersion and ErrNotFound otherwise.
+func ExecVersionedUpdate(ctx context.Context, database Database, expectedVersion int, query string, args ...interface{}) (int, error) {
+	var version int
+	err := database.QueryRow(ctx, query, args...).Scan(&version)
+	if isViolation(database, err, uniqueViolation) {
+		return 0, ErrDuplicate
+	} else if err == sql.ErrNoRows {
+		return 0, notFoundOrMismatch(expectedVersion)
+	} else if err != nil {
+		return 0, err
+	}
+
+	return version, nil
+}
+
 func ExecDelete(ctx context.Context, database Database, query string, args ...interface{}) (int64, error) {
 	result, err := database.Exec(ctx, query, args...)
-	if err != nil {
+	if isViolation(database, err, foreignKeyViolation) {
+		return 0, ErrInUse
+	} else if err != nil {
 		return 0, err
 	}
 
 	return result.RowsAffected()
 }
 
+// notFoundOrMismatch is the error of a versioned update or delete that
+// affected no rows. Versions start at 1 so 0 means the statement was
+// unconditional.
+func notFoundOrMismatch(expectedVersion int) error {
+	if expectedVersion != 0 {
+		return ErrVersionMismatch
+	}
+
+	return ErrNotFound
+}
+
+func isViolation(db Database, err error, v violation) bool {
+	d, ok := db.(*database)
+	return ok && d.dialect.isViolation(err, v)
+}
+
 func verifyConnection(db *sql.DB) {
 	query := "select 1"
 	for {
@@ -104,16 +180,20 @@ func (l migrateLogger) Verbose() bool {
 }
 
 func Migrate() error {
-	sourceURL := GetEnvWithDefault("DB_SOURCE_URL", "file://./persistence/ddl")
-	databaseURL := getDatabaseURL()
+	dialect, err := newDialect()
+	if err != nil {
+		return err
+	}
+
+	sourceURL := getSourceURL(dialect)
 
 	// Open() verifies that the database is up and running
-	_, err := Open()
+	_, err = Open()
 	if err != nil {
 		return err
 	}
 
-	migrator, err := migrate.New(sourceURL, databaseURL)
+	migrator, err := migrate.New(sourceURL, dialect.migrationURL())
 	if err != nil {
 		return err
 	}
@@ -128,13 +208,58 @@ func Migrate() error {
 	return nil
 }
 
-func getDatabaseURL() string {
-	server := GetEnvWithDefault("DB_SERVER", "localhost")
-	port := GetEnvWithDefault("DB_PORT", "5432")
-	username := GetEnvWithDefault("DB_USERNAME", "garbanzo")
-	password := GetEnvWithDefault("DB_PASSWORD", "garbanzo-secret")
+// LatestMigrationVersion returns the version of the last migration in the
+// migration source, the version the schema is at once Migrate() succeeds.
+func LatestMigrationVersion() (uint, error) {
+	dialect, err := newDialect()
+	if err != nil {
+		return 0, err
+	}
+
+	migrations, err := source.Open(getSourceURL(dialect))
+	if err != nil {
+		return 0, err
+	}
+	defer migrations.Close()
+
+	version, err := migrations.First()
+	for err == nil {
+		var next uint
+		next, err = migrations.Next(version)
+		if os.IsNotExist(err) {
+			return version, nil
+		}
+		version = next
+	}
 
-	return fmt.Sprintf("postgres://%s:%s@%s:%s/garbanzo?sslmode=disable", username, password, server, port)
+	return 0, err
+}
+
+// CheckMigrationVersion returns an error unless the schema has been cleanly
+// migrated to version.
+func CheckMigrationVersion(ctx context.Context, database Database, version uint) error {
+	var current uint
+	var dirty bool
+	err := database.QueryRow(ctx, "select version, dirty from schema_migrations limit 1").Scan(&current, &dirty)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("schema is not migrated, expected version %d", version)
+	} else if err != nil {
+		return err
+	}
+
+	if dirty {
+		return ErrDirtySchema
+	}
+
+	if current != version {
+		return fmt.Errorf("schema is at version %d, expected version %d", current, version)
+	}
+
+	return nil
+}
+
+func getSourceURL(dialect dialect) string {
+	return GetEnvWithDefault("DB_SOURCE_URL", dialect.defaultSourceURL())
 }
 
 func GetEnvWithDefault(key, defaultValue string) string {
@@ -161,39 +286,63 @@ type internalTx interface {
 type internalDB interface {
 	internalDBAndTx
 	BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
+	PingContext(ctx context.Context) error
+	Close() error
 }
 
 type database struct {
 	internalDB internalDB
 	internalTx internalTx
+	dialect    dialect
+	// txCtx is the context the transaction began with, used to trace its end
+	txCtx context.Context
 }
 
-func (d *database) Exec(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+func (d *database) internal() internalDBAndTx {
 	if d.internalTx == nil {
-		return d.internalDB.ExecContext(ctx, query, args...)
+		return d.internalDB
 	}
 
-	return d.internalTx.ExecContext(ctx, query, args...)
+	return d.internalTx
+}
+
+func (d *database) Exec(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	query = d.dialect.rebind(query)
+	ctx, span := d.startSpan(ctx, "Exec", query)
+
+	result, err := d.internal().ExecContext(ctx, query, args...)
+	tracing.End(span, err)
+
+	return result, err
 }
 
 func (d *database) Query(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
-	if d.internalTx == nil {
-		return d.internalDB.QueryContext(ctx, query, args...)
-	}
+	query = d.dialect.rebind(query)
+	ctx, span := d.startSpan(ctx, "Query", query)
+
+	rows, err := d.internal().QueryContext(ctx, query, args...)
+	tracing.End(span, err)
 
-	return d.internalTx.QueryContext(ctx, query, args...)
+	return rows, err
 }
 
 func (d *database) QueryRow(ctx context.Context, query string, args ...interface{}) *sql.Row {
-	if d.internalTx == nil {
-		return d.internalDB.QueryRowContext(ctx, query, args...)
-	}
+	query = d.dialect.rebind(query)
+	ctx, span := d.startSpan(ctx, "QueryRow", query)
+
+	row := d.internal().QueryRowContext(ctx, query, args...)
+	// sql.ErrNoRows is only returned by Scan so finding nothing never fails
+	// the span
+	tracing.End(span, row.Err())
 
-	return d.internalTx.QueryRowContext(ctx, query, args...)
+	return row
 }
 
 func (d *database) BeginTx(ctx context.Context) (Database, error) {
-	tx, err := d.internalDB.BeginTx(ctx, nil)
+	spanCtx, span := d.startSpan(ctx, "BeginTx", "")
+
+	tx, err := d.internalDB.BeginTx(spanCtx, nil)
+	tracing.End(span, err)
 	if err != nil {
 		return nil, err
 	}
@@ -201,13 +350,42 @@ func (d *database) BeginTx(ctx context.Context) (Database, error) {
 	return &database{
 		internalDB: nil,
 		internalTx: tx,
+		dialect:    d.dialect,
+		txCtx:      ctx,
 	}, nil
 }
 
 func (d *database) Commit() error {
-	return d.internalTx.Commit()
+	_, span := d.startSpan(d.txCtx, "Commit", "")
+
+	err := d.internalTx.Commit()
+	tracing.End(span, err)
+
+	return countTransaction("commit", err)
 }
 
 func (d *database) Rollback() error {
-	return d.internalTx.Rollback()
+	_, span := d.startSpan(d.txCtx, "Rollback", "")
+
+	err := d.internalTx.Rollback()
+	tracing.End(span, err)
+
+	return countTransaction("rollback", err)
+}
+
+func (d *database) Ping(ctx context.Context) error {
+	if d.internalTx == nil {
+		return d.internalDB.PingContext(ctx)
+	}
+
+	_, err := d.internalTx.ExecContext(ctx, "select 1")
+	return err
+}
+
+func (d *database) Close() error {
+	if d.internalTx != nil {
+		return ErrCloseTx
+	}
+
+	return d.internalDB.Close()
 }
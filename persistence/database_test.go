@@ -0,0 +1,122 @@
+package persistence_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace/noop"
+
+	"github.com/myshkin5/effective-octo-garbanzo/metrics"
+	"github.com/myshkin5/effective-octo-garbanzo/persistence"
+)
+
+var _ = Describe("Database Integration", func() {
+	var database persistence.Database
+
+	BeforeEach(func() {
+		var err error
+		database, err = persistence.Open()
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		Expect(database.Close()).To(Succeed())
+	})
+
+	Describe("Ping", func() {
+		It("pings the database", func() {
+			Expect(database.Ping(ctx)).To(Succeed())
+		})
+
+		It("pings within a transaction", func() {
+			tx, err := database.BeginTx(ctx)
+			Expect(err).NotTo(HaveOccurred())
+			defer tx.Rollback()
+
+			Expect(tx.Ping(ctx)).To(Succeed())
+			Expect(tx.Close()).To(Equal(persistence.ErrCloseTx))
+		})
+	})
+
+	Describe("metrics", func() {
+		transactions := func(outcome string) float64 {
+			return testutil.ToFloat64(metrics.Transactions.WithLabelValues(outcome, "ok"))
+		}
+
+		It("counts commits and rollbacks", func() {
+			commits, rollbacks := transactions("commit"), transactions("rollback")
+
+			tx, err := database.BeginTx(ctx)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(tx.Commit()).To(Succeed())
+
+			tx, err = database.BeginTx(ctx)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(tx.Rollback()).To(Succeed())
+
+			Expect(transactions("commit")).To(Equal(commits + 1))
+			Expect(transactions("rollback")).To(Equal(rollbacks + 1))
+		})
+
+		It("collects the connection pool statistics", func() {
+			Expect(testutil.CollectAndCount(persistence.NewStatsCollector(database))).To(BeNumerically(">", 0))
+		})
+	})
+
+	Describe("tracing", func() {
+		var spans *tracetest.SpanRecorder
+
+		BeforeEach(func() {
+			spans = tracetest.NewSpanRecorder()
+			otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans)))
+		})
+
+		AfterEach(func() {
+			otel.SetTracerProvider(noop.NewTracerProvider())
+		})
+
+		It("traces statements within their transaction", func() {
+			tx, err := database.BeginTx(ctx)
+			Expect(err).NotTo(HaveOccurred())
+
+			var one int
+			Expect(tx.QueryRow(ctx, "select 1").Scan(&one)).To(Succeed())
+			Expect(tx.Commit()).To(Succeed())
+
+			ended := spans.Ended()
+			Expect(ended).To(HaveLen(3))
+			Expect(ended[0].Name()).To(Equal("db.BeginTx"))
+			Expect(ended[1].Name()).To(Equal("db.QueryRow"))
+			Expect(ended[1].Attributes()).To(ContainElement(attribute.String("db.operation", "QueryRow")))
+			Expect(ended[1].Attributes()).To(ContainElement(attribute.String("db.statement", "select 1")))
+			Expect(ended[2].Name()).To(Equal("db.Commit"))
+		})
+	})
+
+	Describe("CheckMigrationVersion", func() {
+		var version uint
+
+		BeforeEach(func() {
+			var err error
+			version, err = persistence.LatestMigrationVersion()
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("finds the latest migration", func() {
+			Expect(version).To(BeNumerically(">=", 2))
+		})
+
+		It("succeeds when the schema is at the latest migration", func() {
+			Expect(persistence.CheckMigrationVersion(ctx, database, version)).To(Succeed())
+		})
+
+		It("returns an error when the schema is at another version", func() {
+			err := persistence.CheckMigrationVersion(ctx, database, version+1)
+			Expect(err).To(MatchError(ContainSubstring("expected version")))
+		})
+	})
+})
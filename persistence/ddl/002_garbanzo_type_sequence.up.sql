@@ -0,0 +1,5 @@
+create sequence garbanzo_type_id_seq start 1003 owned by garbanzo_type.id;
+
+alter table garbanzo_type alter column id set default nextval('garbanzo_type_id_seq');
+
+alter table garbanzo_type alter column name set not null;
@@ -0,0 +1,10 @@
+create table api_key (
+  id           serial      primary key,
+  prefix       varchar(12) not null unique,
+  key_hash     char(64)    not null unique,
+  org_id       smallint    not null references org(id) on delete cascade,
+  name         varchar(40) not null,
+  scopes       text        not null,
+  created_at   timestamptz not null,
+  last_used_at timestamptz
+);
@@ -0,0 +1,3 @@
+alter table octo add column version integer not null default 1;
+
+alter table garbanzo add column version integer not null default 1;
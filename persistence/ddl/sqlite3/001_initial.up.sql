@@ -0,0 +1,28 @@
+create table org (
+  id   integer     primary key autoincrement,
+  name varchar(40) unique
+);
+
+create table octo (
+  id     integer     primary key autoincrement,
+  name   varchar(40),
+  org_id integer     not null references org(id),
+  unique(name, org_id)
+);
+
+create table garbanzo_type (
+  id   integer     primary key autoincrement,
+  name varchar(20) not null unique
+);
+
+insert into garbanzo_type (id, name) values
+  (1001, 'DESI'),
+  (1002, 'KABULI');
+
+create table garbanzo (
+  id               integer primary key autoincrement,
+  api_uuid         text    not null unique,
+  garbanzo_type_id integer not null references garbanzo_type(id),
+  octo_id          integer not null references octo(id),
+  diameter_mm      real    not null
+);
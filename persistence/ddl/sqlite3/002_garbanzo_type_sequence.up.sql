@@ -0,0 +1,4 @@
+-- Autoincrement keys already continue from the seeded garbanzo types (at 1003)
+-- and 001 declares the name not null, so there is nothing to change. This
+-- migration keeps the version numbers in step with Postgres.
+select 1;
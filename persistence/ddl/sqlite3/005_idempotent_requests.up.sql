@@ -0,0 +1,11 @@
+create table idempotent_request (
+  id              integer      primary key autoincrement,
+  org_id          integer      not null references org(id) on delete cascade,
+  idempotency_key varchar(255) not null,
+  request_hash    char(64)     not null,
+  response        text         not null,
+  created_at      timestamp    not null,
+  unique(org_id, idempotency_key)
+);
+
+create index idempotent_request_created_at on idempotent_request (org_id, created_at);
@@ -0,0 +1,14 @@
+create table audit_event (
+  id           integer      primary key autoincrement,
+  org_id       integer      not null references org(id) on delete cascade,
+  actor        varchar(255) not null,
+  action       varchar(16)  not null,
+  resource     text         not null,
+  before_state text,
+  after_state  text,
+  request_id   varchar(128) not null,
+  created_at   timestamp    not null
+);
+
+create index audit_event_created_at on audit_event (org_id, created_at);
+create index audit_event_resource on audit_event (org_id, resource);
@@ -0,0 +1,136 @@
+package persistence
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/lib/pq"
+	"github.com/mattn/go-sqlite3"
+)
+
+type violation int
+
+const (
+	foreignKeyViolation violation = iota
+	uniqueViolation
+)
+
+// dialect captures how the supported SQL databases differ. Store queries are
+// written for Postgres and the dialect rewrites them and interprets their
+// errors for the database selected by DB_DRIVER.
+type dialect interface {
+	driverName() string
+	// system identifies the database in traces
+	system() string
+	dataSourceName() string
+	migrationURL() string
+	defaultSourceURL() string
+	rebind(query string) string
+	isViolation(err error, v violation) bool
+}
+
+func newDialect() (dialect, error) {
+	driver := GetEnvWithDefault("DB_DRIVER", "postgres")
+	switch driver {
+	case "postgres":
+		return postgresDialect{}, nil
+	case "sqlite3":
+		return sqliteDialect{}, nil
+	default:
+		return nil, fmt.Errorf("unknown DB_DRIVER %s, must be postgres or sqlite3", driver)
+	}
+}
+
+type postgresDialect struct{}
+
+var postgresViolations = map[violation]pq.ErrorCode{
+	foreignKeyViolation: "23503",
+	uniqueViolation:     "23505",
+}
+
+func (postgresDialect) driverName() string {
+	return "postgres"
+}
+
+func (postgresDialect) system() string {
+	return "postgresql"
+}
+
+func (postgresDialect) dataSourceName() string {
+	server := GetEnvWithDefault("DB_SERVER", "localhost")
+	port := GetEnvWithDefault("DB_PORT", "5432")
+	username := GetEnvWithDefault("DB_USERNAME", "garbanzo")
+	password := GetEnvWithDefault("DB_PASSWORD", "garbanzo-secret")
+
+	return fmt.Sprintf("postgres://%s:%s@%s:%s/garbanzo?sslmode=disable", username, password, server, port)
+}
+
+func (d postgresDialect) migrationURL() string {
+	return d.dataSourceName()
+}
+
+func (postgresDialect) defaultSourceURL() string {
+	return "file://./persistence/ddl"
+}
+
+func (postgresDialect) rebind(query string) string {
+	return query
+}
+
+func (postgresDialect) isViolation(err error, v violation) bool {
+	pqErr, ok := err.(*pq.Error)
+	return ok && pqErr.Code == postgresViolations[v]
+}
+
+type sqliteDialect struct{}
+
+var (
+	sqliteViolations = map[violation]sqlite3.ErrNoExtended{
+		foreignKeyViolation: sqlite3.ErrConstraintForeignKey,
+		uniqueViolation:     sqlite3.ErrConstraintUnique,
+	}
+
+	postgresParam = regexp.MustCompile(`\$(\d+)`)
+	forUpdate     = regexp.MustCompile(`(?i)\s+for\s+update\b`)
+)
+
+func (sqliteDialect) driverName() string {
+	return "sqlite3"
+}
+
+func (sqliteDialect) system() string {
+	return "sqlite"
+}
+
+// SQLite has no row locks so transactions are begun immediately, taking the
+// database write lock up front in place of select ... for update. Foreign keys
+// aren't enforced unless asked for.
+func (d sqliteDialect) dataSourceName() string {
+	return "file:" + d.path() + "?_foreign_keys=on&_busy_timeout=5000&_journal_mode=WAL&_txlock=immediate"
+}
+
+func (d sqliteDialect) migrationURL() string {
+	return "sqlite3://" + d.path()
+}
+
+func (sqliteDialect) defaultSourceURL() string {
+	return "file://./persistence/ddl/sqlite3"
+}
+
+func (sqliteDialect) path() string {
+	return GetEnvWithDefault("DB_PATH", "garbanzo.db")
+}
+
+// rebind drops row locking (see dataSourceName) wherever it is in the query
+// and switches to SQLite's numbered parameters so a parameter may be
+// referenced out of order or more than once. Returning clauses are supported
+// natively as of SQLite 3.35.
+func (sqliteDialect) rebind(query string) string {
+	query = forUpdate.ReplaceAllString(query, "")
+	return postgresParam.ReplaceAllString(query, "?$1")
+}
+
+func (sqliteDialect) isViolation(err error, v violation) bool {
+	sqliteErr, ok := err.(sqlite3.Error)
+	return ok && sqliteErr.ExtendedCode == sqliteViolations[v]
+}
@@ -0,0 +1,71 @@
+package persistence
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/myshkin5/effective-octo-garbanzo/persistence/data"
+)
+
+var ErrInvalidOperator = errors.New("invalid comparison operator")
+
+type Operator int
+
+const (
+	Equal Operator = iota
+	GreaterThan
+	GreaterThanOrEqual
+	LessThan
+	LessThanOrEqual
+)
+
+var operators = map[Operator]string{
+	Equal:              "=",
+	GreaterThan:        ">",
+	GreaterThanOrEqual: ">=",
+	LessThan:           "<",
+	LessThanOrEqual:    "<=",
+}
+
+type Comparison struct {
+	Operator Operator
+	Value    float32
+}
+
+// GarbanzoFilter restricts the garbanzos fetched from an octo. Garbanzos must
+// have one of the GarbanzoTypes (when any are specified) and satisfy every
+// DiameterMM comparison.
+type GarbanzoFilter struct {
+	GarbanzoTypes []data.GarbanzoType
+	DiameterMM    []Comparison
+}
+
+// garbanzoSortColumns is the allow-list of fields garbanzos may be sorted by.
+// Page cursors carry numeric sort values so GarbanzoType sorts by the type's
+// id, the order the types were defined in, rather than by its name.
+var garbanzoSortColumns = map[string]string{
+	"GarbanzoType": "g.garbanzo_type_id",
+	"DiameterMM":   "g.diameter_mm",
+}
+
+func (f GarbanzoFilter) conditions(params *params) ([]string, error) {
+	var conditions []string
+
+	if len(f.GarbanzoTypes) > 0 {
+		var values []string
+		for _, garbanzoType := range f.GarbanzoTypes {
+			values = append(values, params.add(garbanzoType.Id))
+		}
+		conditions = append(conditions, "g.garbanzo_type_id in ("+strings.Join(values, ", ")+")")
+	}
+
+	for _, comparison := range f.DiameterMM {
+		operator, ok := operators[comparison.Operator]
+		if !ok {
+			return nil, ErrInvalidOperator
+		}
+		conditions = append(conditions, "g.diameter_mm "+operator+" "+params.add(comparison.Value))
+	}
+
+	return conditions, nil
+}
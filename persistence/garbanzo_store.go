@@ -3,6 +3,8 @@ package persistence
 import (
 	"context"
 	"database/sql"
+	"fmt"
+	"strings"
 
 	"github.com/satori/go.uuid"
 
@@ -12,16 +14,29 @@ import (
 
 type GarbanzoStore struct{}
 
-func (GarbanzoStore) FetchByOctoName(ctx context.Context, database Database, octoName string) ([]data.Garbanzo, error) {
-	query := `select g.id, g.api_uuid, g.garbanzo_type_id, g.octo_id, g.diameter_mm from garbanzo g
+func (GarbanzoStore) FetchByOctoName(ctx context.Context, database Database, octoName string, filter GarbanzoFilter, page Page) ([]data.Garbanzo, bool, error) {
+	defer observeQuery("GarbanzoStore.FetchByOctoName")()
+
+	params := params{octoName, org(ctx)}
+	conditions, err := filter.conditions(&params)
+	if err != nil {
+		return nil, false, err
+	}
+	condition, orderBy, err := page.keyset("g.id", garbanzoSortColumns, &params)
+	if err != nil {
+		return nil, false, err
+	}
+	conditions = append(conditions, condition)
+	query := `select g.id, g.api_uuid, gt.id, gt.name, g.octo_id, g.diameter_mm, g.version from garbanzo g
+		join garbanzo_type gt on g.garbanzo_type_id = gt.id
 		join octo o on g.octo_id = o.id
 		join org on o.org_id = org.id
-		where o.name = $1 and org.name = $2
-		order by g.id`
+		where o.name = $1 and org.name = $2 and ` + strings.Join(conditions, " and ") + `
+		` + orderBy
 
-	rows, err := database.Query(ctx, query, octoName, org(ctx))
+	rows, err := database.Query(ctx, query, params...)
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
 	defer rows.Close()
 
@@ -32,9 +47,10 @@ func (GarbanzoStore) FetchByOctoName(ctx context.Context, database Database, oct
 		var garbanzoType data.GarbanzoType
 		var octoId int
 		var diameterMM float32
-		err = rows.Scan(&id, &apiUUID, &garbanzoType, &octoId, &diameterMM)
+		var version int
+		err = rows.Scan(&id, &apiUUID, &garbanzoType.Id, &garbanzoType.Name, &octoId, &diameterMM, &version)
 		if err != nil {
-			return nil, err
+			return nil, false, err
 		}
 
 		garbanzo := data.Garbanzo{
@@ -43,15 +59,27 @@ func (GarbanzoStore) FetchByOctoName(ctx context.Context, database Database, oct
 			GarbanzoType: garbanzoType,
 			OctoId:       octoId,
 			DiameterMM:   diameterMM,
+			Version:      version,
 		}
 		garbanzos = append(garbanzos, garbanzo)
 	}
 
-	return garbanzos, nil
+	count, more := page.trim(len(garbanzos))
+	garbanzos = garbanzos[:count]
+	if page.Backward() {
+		for i, j := 0, len(garbanzos)-1; i < j; i, j = i+1, j-1 {
+			garbanzos[i], garbanzos[j] = garbanzos[j], garbanzos[i]
+		}
+	}
+
+	return garbanzos, more, nil
 }
 
 func (GarbanzoStore) FetchByAPIUUIDAndOctoName(ctx context.Context, database Database, apiUUID uuid.UUID, octoName string) (data.Garbanzo, error) {
-	query := `select g.id, g.garbanzo_type_id, g.octo_id, g.diameter_mm from garbanzo g
+	defer observeQuery("GarbanzoStore.FetchByAPIUUIDAndOctoName")()
+
+	query := `select g.id, gt.id, gt.name, g.octo_id, g.diameter_mm, g.version from garbanzo g
+		join garbanzo_type gt on g.garbanzo_type_id = gt.id
 		join octo o on g.octo_id = o.id
 		join org on o.org_id = org.id
 		where g.api_uuid = $1 and o.name = $2 and org.name = $3`
@@ -60,7 +88,8 @@ func (GarbanzoStore) FetchByAPIUUIDAndOctoName(ctx context.Context, database Dat
 	var garbanzoType data.GarbanzoType
 	var octoId int
 	var diameterMM float32
-	err := database.QueryRow(ctx, query, apiUUID, octoName, org(ctx)).Scan(&id, &garbanzoType, &octoId, &diameterMM)
+	var version int
+	err := database.QueryRow(ctx, query, apiUUID, octoName, org(ctx)).Scan(&id, &garbanzoType.Id, &garbanzoType.Name, &octoId, &diameterMM, &version)
 	if err == sql.ErrNoRows {
 		return data.Garbanzo{}, ErrNotFound
 	} else if err != nil {
@@ -73,40 +102,159 @@ func (GarbanzoStore) FetchByAPIUUIDAndOctoName(ctx context.Context, database Dat
 		GarbanzoType: garbanzoType,
 		OctoId:       octoId,
 		DiameterMM:   diameterMM,
+		Version:      version,
 	}, nil
 }
 
+// FetchByAPIUUID fetches a garbanzo in any of the org's octos along with the
+// name of its octo.
+func (GarbanzoStore) FetchByAPIUUID(ctx context.Context, database Database, apiUUID uuid.UUID) (data.Garbanzo, string, error) {
+	defer observeQuery("GarbanzoStore.FetchByAPIUUID")()
+
+	query := `select g.id, gt.id, gt.name, g.octo_id, o.name, g.diameter_mm, g.version from garbanzo g
+		join garbanzo_type gt on g.garbanzo_type_id = gt.id
+		join octo o on g.octo_id = o.id
+		join org on o.org_id = org.id
+		where g.api_uuid = $1 and org.name = $2`
+
+	garbanzo := data.Garbanzo{APIUUID: apiUUID}
+	var octoName string
+	err := database.QueryRow(ctx, query, apiUUID, org(ctx)).Scan(&garbanzo.Id, &garbanzo.GarbanzoType.Id, &garbanzo.GarbanzoType.Name,
+		&garbanzo.OctoId, &octoName, &garbanzo.DiameterMM, &garbanzo.Version)
+	if err == sql.ErrNoRows {
+		return data.Garbanzo{}, "", ErrNotFound
+	} else if err != nil {
+		return data.Garbanzo{}, "", err
+	}
+
+	return garbanzo, octoName, nil
+}
+
 func (GarbanzoStore) Create(ctx context.Context, database Database, garbanzo data.Garbanzo) (int, error) {
+	defer observeQuery("GarbanzoStore.Create")()
+
 	query := `insert into garbanzo (api_uuid, garbanzo_type_id, octo_id, diameter_mm)
 		values (
 			$1,
 			$2,
 			(select o.id from octo o join org on o.org_id = org.id where o.id = $3 and org.name = $4),
 			$5) returning id`
-	return ExecInsert(ctx, database, query, garbanzo.APIUUID, garbanzo.GarbanzoType, garbanzo.OctoId, org(ctx), garbanzo.DiameterMM)
+	return ExecInsert(ctx, database, query, garbanzo.APIUUID, garbanzo.GarbanzoType.Id, garbanzo.OctoId, org(ctx), garbanzo.DiameterMM)
+}
+
+// CreateBatch creates the garbanzos with a single statement and returns their
+// ids in the same order.
+func (GarbanzoStore) CreateBatch(ctx context.Context, database Database, garbanzos []data.Garbanzo) ([]int, error) {
+	defer observeQuery("GarbanzoStore.CreateBatch")()
+
+	params := params{org(ctx)}
+	var values []string
+	for _, garbanzo := range garbanzos {
+		values = append(values, fmt.Sprintf(`(
+			%s,
+			%s,
+			(select o.id from octo o join org on o.org_id = org.id where o.id = %s and org.name = $1),
+			%s)`, params.add(garbanzo.APIUUID), params.add(garbanzo.GarbanzoType.Id), params.add(garbanzo.OctoId),
+			params.add(garbanzo.DiameterMM)))
+	}
+	query := `insert into garbanzo (api_uuid, garbanzo_type_id, octo_id, diameter_mm)
+		values ` + strings.Join(values, ", ") + ` returning id, api_uuid`
+
+	rows, err := database.Query(ctx, query, params...)
+	if isViolation(database, err, uniqueViolation) {
+		return nil, ErrDuplicate
+	} else if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// The order of returned rows isn't guaranteed so match them up by API UUID
+	ids := make(map[uuid.UUID]int, len(garbanzos))
+	for rows.Next() {
+		var id int
+		var apiUUID uuid.UUID
+		err = rows.Scan(&id, &apiUUID)
+		if err != nil {
+			return nil, err
+		}
+		ids[apiUUID] = id
+	}
+	err = rows.Err()
+	if isViolation(database, err, uniqueViolation) {
+		return nil, ErrDuplicate
+	} else if err != nil {
+		return nil, err
+	}
+
+	garbanzoIds := make([]int, len(garbanzos))
+	for i, garbanzo := range garbanzos {
+		garbanzoIds[i] = ids[garbanzo.APIUUID]
+	}
+
+	return garbanzoIds, nil
+}
+
+// UpdateByAPIUUIDAndOctoName replaces the garbanzo's fields and returns its
+// new version. Unless garbanzo.Version is 0, the garbanzo is only updated while
+// it is still at that version.
+func (GarbanzoStore) UpdateByAPIUUIDAndOctoName(ctx context.Context, database Database, garbanzo data.Garbanzo, octoName string) (int, error) {
+	defer observeQuery("GarbanzoStore.UpdateByAPIUUIDAndOctoName")()
+
+	query := `update garbanzo set garbanzo_type_id = $1, diameter_mm = $2, version = version + 1
+		where api_uuid = $3 and ($4 = 0 or version = $4) and octo_id = (
+			select o.id from octo o
+			join org on o.org_id = org.id
+			where o.name = $5 and org.name = $6)
+		returning version`
+	return ExecVersionedUpdate(ctx, database, garbanzo.Version, query,
+		garbanzo.GarbanzoType.Id, garbanzo.DiameterMM, garbanzo.APIUUID, garbanzo.Version, octoName, org(ctx))
 }
 
-func (GarbanzoStore) DeleteByAPIUUIDAndOctoName(ctx context.Context, database Database, apiUUID uuid.UUID, octoName string) error {
+// MoveById moves the garbanzo to another octo and returns its new version.
+// Unless version is 0, the garbanzo is only moved while it is still at that
+// version.
+func (GarbanzoStore) MoveById(ctx context.Context, database Database, id int, octoId int, version int) (int, error) {
+	defer observeQuery("GarbanzoStore.MoveById")()
+
+	query := `update garbanzo set version = version + 1, octo_id = (
+			select o.id from octo o
+			join org on o.org_id = org.id
+			where o.id = $1 and org.name = $2)
+		where id = $3 and ($4 = 0 or version = $4) and octo_id in (
+			select o.id from octo o
+			join org on o.org_id = org.id
+			where org.name = $2)
+		returning version`
+	return ExecVersionedUpdate(ctx, database, version, query, octoId, org(ctx), id, version)
+}
+
+// DeleteByAPIUUIDAndOctoName deletes the garbanzo. Unless version is 0, the
+// garbanzo is only deleted while it is still at that version.
+func (GarbanzoStore) DeleteByAPIUUIDAndOctoName(ctx context.Context, database Database, apiUUID uuid.UUID, octoName string, version int) error {
+	defer observeQuery("GarbanzoStore.DeleteByAPIUUIDAndOctoName")()
+
 	query := `delete from garbanzo
-		where api_uuid = $1 and octo_id = (
+		where api_uuid = $1 and ($2 = 0 or version = $2) and octo_id = (
 			select o.id from octo o
 			join org on o.org_id = org.id
-			where o.name = $2 and org.name = $3)`
-	rowsAffected, err := ExecDelete(ctx, database, query, apiUUID, octoName, org(ctx))
+			where o.name = $3 and org.name = $4)`
+	rowsAffected, err := ExecDelete(ctx, database, query, apiUUID, version, octoName, org(ctx))
 	if err != nil {
 		return err
 	}
 
 	if rowsAffected == 0 {
-		return ErrNotFound
+		return notFoundOrMismatch(version)
 	} else if rowsAffected > 1 {
-		logs.Logger.Panic("Deleted multiple rows when expecting only one")
+		logs.FromContext(ctx).Panic("Deleted multiple rows when expecting only one")
 	}
 
 	return nil
 }
 
 func (GarbanzoStore) DeleteByOctoId(ctx context.Context, database Database, octoId int) error {
+	defer observeQuery("GarbanzoStore.DeleteByOctoId")()
+
 	query := `delete from garbanzo where octo_id = (
 			select o.id from octo o
 			join org on o.org_id = org.id
@@ -59,7 +59,7 @@ var _ = Describe("GarbanzoStore Integration", func() {
 
 		org1Octo1Garbanzo1 = data.Garbanzo{
 			APIUUID:      uuid.NewV4(),
-			GarbanzoType: data.DESI,
+			GarbanzoType: desi,
 			OctoId:       org1Octo1.Id,
 			DiameterMM:   4.2,
 		}
@@ -69,7 +69,7 @@ var _ = Describe("GarbanzoStore Integration", func() {
 
 		org1Octo1Garbanzo2 = data.Garbanzo{
 			APIUUID:      uuid.NewV4(),
-			GarbanzoType: data.KABULI,
+			GarbanzoType: kabuli,
 			OctoId:       org1Octo1.Id,
 			DiameterMM:   6.4,
 		}
@@ -79,7 +79,7 @@ var _ = Describe("GarbanzoStore Integration", func() {
 
 		org2Octo1Garbanzo1 = data.Garbanzo{
 			APIUUID:      uuid.NewV4(),
-			GarbanzoType: data.KABULI,
+			GarbanzoType: kabuli,
 			OctoId:       org2Octo1.Id,
 			DiameterMM:   6.4,
 		}
@@ -90,33 +90,136 @@ var _ = Describe("GarbanzoStore Integration", func() {
 
 	Describe("FetchByOctoName", func() {
 		It("fetches no garbanzos when there are none", func() {
-			garbanzos, err := store.FetchByOctoName(org1Ctx, database, org1Octo2.Name)
+			garbanzos, _, err := store.FetchByOctoName(org1Ctx, database, org1Octo2.Name, persistence.GarbanzoFilter{}, persistence.Page{Limit: 10})
 			Expect(err).NotTo(HaveOccurred())
 
 			Expect(garbanzos).To(HaveLen(0))
 		})
 
 		It("fetches all the garbanzos", func() {
-			garbanzos, err := store.FetchByOctoName(org1Ctx, database, org1Octo1.Name)
+			garbanzos, _, err := store.FetchByOctoName(org1Ctx, database, org1Octo1.Name, persistence.GarbanzoFilter{}, persistence.Page{Limit: 10})
 			Expect(err).NotTo(HaveOccurred())
 
 			Expect(garbanzos).To(HaveLen(2))
 
 			Expect(garbanzos[0].Id).To(Equal(org1Octo1Garbanzo1.Id))
 			Expect(garbanzos[0].APIUUID).To(Equal(org1Octo1Garbanzo1.APIUUID))
-			Expect(garbanzos[0].GarbanzoType).To(Equal(data.DESI))
+			Expect(garbanzos[0].GarbanzoType).To(Equal(desi))
 			Expect(garbanzos[0].OctoId).To(Equal(org1Octo1.Id))
 			Expect(garbanzos[0].DiameterMM).To(BeNumerically("~", 4.2, 0.000001))
 
 			Expect(garbanzos[1].Id).To(Equal(org1Octo1Garbanzo2.Id))
 			Expect(garbanzos[1].APIUUID).To(Equal(org1Octo1Garbanzo2.APIUUID))
-			Expect(garbanzos[1].GarbanzoType).To(Equal(data.KABULI))
+			Expect(garbanzos[1].GarbanzoType).To(Equal(kabuli))
 			Expect(garbanzos[1].OctoId).To(Equal(org1Octo1.Id))
 			Expect(garbanzos[1].DiameterMM).To(BeNumerically("~", 6.4, 0.000001))
 		})
 
+		It("fetches the garbanzos a page at a time", func() {
+			garbanzos, more, err := store.FetchByOctoName(org1Ctx, database, org1Octo1.Name, persistence.GarbanzoFilter{}, persistence.Page{Limit: 1})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(more).To(BeTrue())
+			Expect(garbanzos).To(HaveLen(1))
+			Expect(garbanzos[0].Id).To(Equal(org1Octo1Garbanzo1.Id))
+
+			garbanzos, more, err = store.FetchByOctoName(org1Ctx, database, org1Octo1.Name, persistence.GarbanzoFilter{}, persistence.Page{
+				AfterId: org1Octo1Garbanzo1.Id,
+				Limit:   1,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(more).To(BeFalse())
+			Expect(garbanzos).To(HaveLen(1))
+			Expect(garbanzos[0].Id).To(Equal(org1Octo1Garbanzo2.Id))
+
+			garbanzos, more, err = store.FetchByOctoName(org1Ctx, database, org1Octo1.Name, persistence.GarbanzoFilter{}, persistence.Page{
+				BeforeId: org1Octo1Garbanzo2.Id,
+				Limit:    1,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(more).To(BeFalse())
+			Expect(garbanzos).To(HaveLen(1))
+			Expect(garbanzos[0].Id).To(Equal(org1Octo1Garbanzo1.Id))
+		})
+
+		It("filters the garbanzos by type", func() {
+			garbanzos, _, err := store.FetchByOctoName(org1Ctx, database, org1Octo1.Name, persistence.GarbanzoFilter{
+				GarbanzoTypes: []data.GarbanzoType{kabuli},
+			}, persistence.Page{Limit: 10})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(garbanzos).To(HaveLen(1))
+			Expect(garbanzos[0].Id).To(Equal(org1Octo1Garbanzo2.Id))
+		})
+
+		It("filters the garbanzos by diameter", func() {
+			garbanzos, _, err := store.FetchByOctoName(org1Ctx, database, org1Octo1.Name, persistence.GarbanzoFilter{
+				DiameterMM: []persistence.Comparison{
+					{Operator: persistence.GreaterThanOrEqual, Value: 4.2},
+					{Operator: persistence.LessThan, Value: 5},
+				},
+			}, persistence.Page{Limit: 10})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(garbanzos).To(HaveLen(1))
+			Expect(garbanzos[0].Id).To(Equal(org1Octo1Garbanzo1.Id))
+		})
+
+		It("sorts the garbanzos", func() {
+			garbanzos, _, err := store.FetchByOctoName(org1Ctx, database, org1Octo1.Name, persistence.GarbanzoFilter{}, persistence.Page{
+				Limit: 10,
+				Sort:  persistence.Sort{Field: "DiameterMM", Descending: true},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(garbanzos).To(HaveLen(2))
+			Expect(garbanzos[0].Id).To(Equal(org1Octo1Garbanzo2.Id))
+			Expect(garbanzos[1].Id).To(Equal(org1Octo1Garbanzo1.Id))
+		})
+
+		It("fetches sorted garbanzos a page at a time", func() {
+			sort := persistence.Sort{Field: "DiameterMM", Descending: true}
+			garbanzos, more, err := store.FetchByOctoName(org1Ctx, database, org1Octo1.Name, persistence.GarbanzoFilter{}, persistence.Page{
+				Limit: 1,
+				Sort:  sort,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(more).To(BeTrue())
+			Expect(garbanzos).To(HaveLen(1))
+			Expect(garbanzos[0].Id).To(Equal(org1Octo1Garbanzo2.Id))
+
+			garbanzos, more, err = store.FetchByOctoName(org1Ctx, database, org1Octo1.Name, persistence.GarbanzoFilter{}, persistence.Page{
+				AfterId:   garbanzos[0].Id,
+				SortValue: float64(garbanzos[0].DiameterMM),
+				Limit:     1,
+				Sort:      sort,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(more).To(BeFalse())
+			Expect(garbanzos).To(HaveLen(1))
+			Expect(garbanzos[0].Id).To(Equal(org1Octo1Garbanzo1.Id))
+
+			garbanzos, more, err = store.FetchByOctoName(org1Ctx, database, org1Octo1.Name, persistence.GarbanzoFilter{}, persistence.Page{
+				BeforeId:  garbanzos[0].Id,
+				SortValue: float64(garbanzos[0].DiameterMM),
+				Limit:     1,
+				Sort:      sort,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(more).To(BeFalse())
+			Expect(garbanzos).To(HaveLen(1))
+			Expect(garbanzos[0].Id).To(Equal(org1Octo1Garbanzo2.Id))
+		})
+
+		It("returns an error when sorting by an unknown field", func() {
+			_, _, err := store.FetchByOctoName(org1Ctx, database, org1Octo1.Name, persistence.GarbanzoFilter{}, persistence.Page{
+				Limit: 10,
+				Sort:  persistence.Sort{Field: "OctoId"},
+			})
+			Expect(err).To(Equal(persistence.ErrInvalidSort))
+		})
+
 		It("does not find garbanzos for another org", func() {
-			garbanzos, err := store.FetchByOctoName(org2Ctx, database, org1Octo1.Name)
+			garbanzos, _, err := store.FetchByOctoName(org2Ctx, database, org1Octo1.Name, persistence.GarbanzoFilter{}, persistence.Page{Limit: 10})
 			Expect(err).NotTo(HaveOccurred())
 
 			Expect(garbanzos).To(BeEmpty())
@@ -141,7 +244,7 @@ var _ = Describe("GarbanzoStore Integration", func() {
 			Expect(err).NotTo(HaveOccurred())
 			Expect(fetchedGarbanzo.Id).To(Equal(org1Octo1Garbanzo1.Id))
 			Expect(fetchedGarbanzo.APIUUID).To(Equal(org1Octo1Garbanzo1.APIUUID))
-			Expect(fetchedGarbanzo.GarbanzoType).To(Equal(data.DESI))
+			Expect(fetchedGarbanzo.GarbanzoType).To(Equal(desi))
 			Expect(fetchedGarbanzo.OctoId).To(Equal(org1Octo1.Id))
 			Expect(fetchedGarbanzo.DiameterMM).To(BeNumerically("~", 4.2, 0.000001))
 		})
@@ -153,12 +256,29 @@ var _ = Describe("GarbanzoStore Integration", func() {
 		})
 	})
 
+	Describe("FetchByAPIUUID", func() {
+		It("fetches a garbanzo from any of the org's octos", func() {
+			garbanzo, octoName, err := store.FetchByAPIUUID(org1Ctx, database, org1Octo1Garbanzo2.APIUUID)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(octoName).To(Equal(org1Octo1.Name))
+			Expect(garbanzo.Id).To(Equal(org1Octo1Garbanzo2.Id))
+			Expect(garbanzo.GarbanzoType).To(Equal(kabuli))
+			Expect(garbanzo.OctoId).To(Equal(org1Octo1.Id))
+			Expect(garbanzo.Version).To(Equal(1))
+		})
+
+		It("doesn't fetch a garbanzo of another org", func() {
+			_, _, err := store.FetchByAPIUUID(org1Ctx, database, org2Octo1Garbanzo1.APIUUID)
+			Expect(err).To(Equal(persistence.ErrNotFound))
+		})
+	})
+
 	Describe("Create", func() {
 		It("creates a new garbanzo", func() {
 			apiUUID := uuid.NewV4()
 			garbanzo := data.Garbanzo{
 				APIUUID:      apiUUID,
-				GarbanzoType: data.DESI,
+				GarbanzoType: desi,
 				OctoId:       org1Octo1.Id,
 				DiameterMM:   4.2,
 			}
@@ -170,7 +290,7 @@ var _ = Describe("GarbanzoStore Integration", func() {
 			Expect(err).NotTo(HaveOccurred())
 			Expect(fetchedGarbanzo.Id).To(Equal(garbanzoId))
 			Expect(fetchedGarbanzo.APIUUID).To(Equal(apiUUID))
-			Expect(fetchedGarbanzo.GarbanzoType).To(Equal(data.DESI))
+			Expect(fetchedGarbanzo.GarbanzoType).To(Equal(desi))
 			Expect(fetchedGarbanzo.OctoId).To(Equal(org1Octo1.Id))
 			Expect(fetchedGarbanzo.DiameterMM).To(BeNumerically("~", 4.2, 0.000001))
 		})
@@ -180,7 +300,7 @@ var _ = Describe("GarbanzoStore Integration", func() {
 			garbanzo := data.Garbanzo{
 				Id:           ignoredId,
 				APIUUID:      uuid.NewV4(),
-				GarbanzoType: data.DESI,
+				GarbanzoType: desi,
 				OctoId:       org1Octo2.Id,
 				DiameterMM:   4.2,
 			}
@@ -189,7 +309,7 @@ var _ = Describe("GarbanzoStore Integration", func() {
 			Expect(err).NotTo(HaveOccurred())
 			Expect(garbanzoId).NotTo(Equal(ignoredId))
 
-			garbanzos, err := store.FetchByOctoName(org1Ctx, database, org1Octo2.Name)
+			garbanzos, _, err := store.FetchByOctoName(org1Ctx, database, org1Octo2.Name, persistence.GarbanzoFilter{}, persistence.Page{Limit: 10})
 			Expect(err).NotTo(HaveOccurred())
 			Expect(len(garbanzos)).To(Equal(1))
 			Expect(garbanzos[0].Id).To(Equal(garbanzoId))
@@ -199,7 +319,7 @@ var _ = Describe("GarbanzoStore Integration", func() {
 			apiUUID := uuid.NewV4()
 			garbanzo := data.Garbanzo{
 				APIUUID:      apiUUID,
-				GarbanzoType: data.DESI,
+				GarbanzoType: desi,
 				OctoId:       org1Octo1.Id,
 				DiameterMM:   4.2,
 			}
@@ -209,28 +329,189 @@ var _ = Describe("GarbanzoStore Integration", func() {
 		})
 	})
 
+	Describe("CreateBatch", func() {
+		It("creates all the garbanzos returning their ids in order", func() {
+			batch := []data.Garbanzo{
+				{APIUUID: uuid.NewV4(), GarbanzoType: desi, OctoId: org1Octo2.Id, DiameterMM: 3.3},
+				{APIUUID: uuid.NewV4(), GarbanzoType: kabuli, OctoId: org1Octo2.Id, DiameterMM: 7.7},
+				{APIUUID: uuid.NewV4(), GarbanzoType: desi, OctoId: org1Octo2.Id, DiameterMM: 5.5},
+			}
+
+			ids, err := store.CreateBatch(org1Ctx, database, batch)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ids).To(HaveLen(3))
+
+			for i, garbanzo := range batch {
+				fetchedGarbanzo, err := store.FetchByAPIUUIDAndOctoName(org1Ctx, database, garbanzo.APIUUID, org1Octo2.Name)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(fetchedGarbanzo.Id).To(Equal(ids[i]))
+				Expect(fetchedGarbanzo.GarbanzoType).To(Equal(garbanzo.GarbanzoType))
+				Expect(fetchedGarbanzo.DiameterMM).To(BeNumerically("~", garbanzo.DiameterMM, 0.000001))
+				Expect(fetchedGarbanzo.Version).To(Equal(1))
+			}
+		})
+
+		It("creates none of the garbanzos when one has a parent octo from another org", func() {
+			batch := []data.Garbanzo{
+				{APIUUID: uuid.NewV4(), GarbanzoType: desi, OctoId: org1Octo2.Id, DiameterMM: 3.3},
+				{APIUUID: uuid.NewV4(), GarbanzoType: kabuli, OctoId: org2Octo1.Id, DiameterMM: 7.7},
+			}
+
+			_, err := store.CreateBatch(org1Ctx, database, batch)
+			Expect(err).To(HaveOccurred())
+
+			garbanzos, _, err := store.FetchByOctoName(org1Ctx, database, org1Octo2.Name, persistence.GarbanzoFilter{}, persistence.Page{Limit: 10})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(garbanzos).To(BeEmpty())
+		})
+	})
+
+	Describe("UpdateByAPIUUIDAndOctoName", func() {
+		It("returns not found when updating an unknown garbanzo", func() {
+			_, err := store.UpdateByAPIUUIDAndOctoName(org1Ctx, database, data.Garbanzo{
+				APIUUID:      uuid.NewV4(),
+				GarbanzoType: desi,
+				DiameterMM:   1.1,
+			}, org1Octo1.Name)
+
+			Expect(err).To(Equal(persistence.ErrNotFound))
+		})
+
+		It("returns not found when updating a garbanzo with the wrong octo name", func() {
+			garbanzo := org1Octo1Garbanzo1
+			garbanzo.DiameterMM = 1.1
+			_, err := store.UpdateByAPIUUIDAndOctoName(org1Ctx, database, garbanzo, org1Octo2.Name)
+
+			Expect(err).To(Equal(persistence.ErrNotFound))
+		})
+
+		It("updates a garbanzo", func() {
+			garbanzo := org1Octo1Garbanzo1
+			garbanzo.GarbanzoType = kabuli
+			garbanzo.DiameterMM = 1.1
+			version, err := store.UpdateByAPIUUIDAndOctoName(org1Ctx, database, garbanzo, org1Octo1.Name)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(version).To(Equal(2))
+
+			fetchedGarbanzo, err := store.FetchByAPIUUIDAndOctoName(org1Ctx, database, org1Octo1Garbanzo1.APIUUID, org1Octo1.Name)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fetchedGarbanzo.Id).To(Equal(org1Octo1Garbanzo1.Id))
+			Expect(fetchedGarbanzo.GarbanzoType).To(Equal(kabuli))
+			Expect(fetchedGarbanzo.OctoId).To(Equal(org1Octo1.Id))
+			Expect(fetchedGarbanzo.DiameterMM).To(BeNumerically("~", 1.1, 0.000001))
+			Expect(fetchedGarbanzo.Version).To(Equal(2))
+		})
+
+		It("updates a garbanzo still at the expected version", func() {
+			garbanzo := org1Octo1Garbanzo1
+			garbanzo.DiameterMM = 1.1
+			garbanzo.Version = 1
+			version, err := store.UpdateByAPIUUIDAndOctoName(org1Ctx, database, garbanzo, org1Octo1.Name)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(version).To(Equal(2))
+		})
+
+		It("returns a version mismatch when the garbanzo has been modified", func() {
+			garbanzo := org1Octo1Garbanzo1
+			garbanzo.DiameterMM = 1.1
+			garbanzo.Version = 2
+			_, err := store.UpdateByAPIUUIDAndOctoName(org1Ctx, database, garbanzo, org1Octo1.Name)
+			Expect(err).To(Equal(persistence.ErrVersionMismatch))
+
+			fetchedGarbanzo, err := store.FetchByAPIUUIDAndOctoName(org1Ctx, database, org1Octo1Garbanzo1.APIUUID, org1Octo1.Name)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fetchedGarbanzo.DiameterMM).To(BeNumerically("~", 4.2, 0.000001))
+			Expect(fetchedGarbanzo.Version).To(Equal(1))
+		})
+
+		It("returns not found when updating a garbanzo with the wrong org", func() {
+			garbanzo := org1Octo1Garbanzo1
+			garbanzo.DiameterMM = 1.1
+			_, err := store.UpdateByAPIUUIDAndOctoName(org2Ctx, database, garbanzo, org1Octo1.Name)
+
+			Expect(err).To(Equal(persistence.ErrNotFound))
+		})
+	})
+
+	Describe("MoveById", func() {
+		It("returns not found when moving an unknown garbanzo", func() {
+			_, err := store.MoveById(org1Ctx, database, 82333455, org1Octo2.Id, 0)
+
+			Expect(err).To(Equal(persistence.ErrNotFound))
+		})
+
+		It("moves a garbanzo to another octo", func() {
+			version, err := store.MoveById(org1Ctx, database, org1Octo1Garbanzo1.Id, org1Octo2.Id, 0)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(version).To(Equal(2))
+
+			_, err = store.FetchByAPIUUIDAndOctoName(org1Ctx, database, org1Octo1Garbanzo1.APIUUID, org1Octo1.Name)
+			Expect(err).To(Equal(persistence.ErrNotFound))
+
+			fetchedGarbanzo, err := store.FetchByAPIUUIDAndOctoName(org1Ctx, database, org1Octo1Garbanzo1.APIUUID, org1Octo2.Name)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fetchedGarbanzo.Id).To(Equal(org1Octo1Garbanzo1.Id))
+			Expect(fetchedGarbanzo.OctoId).To(Equal(org1Octo2.Id))
+		})
+
+		It("returns a version mismatch when the garbanzo has been modified", func() {
+			_, err := store.MoveById(org1Ctx, database, org1Octo1Garbanzo1.Id, org1Octo2.Id, 2)
+			Expect(err).To(Equal(persistence.ErrVersionMismatch))
+
+			_, err = store.FetchByAPIUUIDAndOctoName(org1Ctx, database, org1Octo1Garbanzo1.APIUUID, org1Octo1.Name)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("returns not found when moving a garbanzo from another org", func() {
+			_, err := store.MoveById(org2Ctx, database, org1Octo1Garbanzo1.Id, org2Octo1.Id, 0)
+
+			Expect(err).To(Equal(persistence.ErrNotFound))
+		})
+
+		It("fails to move a garbanzo to an octo from another org", func() {
+			_, err := store.MoveById(org1Ctx, database, org1Octo1Garbanzo1.Id, org2Octo1.Id, 0)
+
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
 	Describe("DeleteByAPIUUIDAndOctoName", func() {
 		It("returns not found when deleting an unknown garbanzo", func() {
-			err := store.DeleteByAPIUUIDAndOctoName(org1Ctx, database, uuid.NewV4(), org1Octo1.Name)
+			err := store.DeleteByAPIUUIDAndOctoName(org1Ctx, database, uuid.NewV4(), org1Octo1.Name, 0)
 
 			Expect(err).To(Equal(persistence.ErrNotFound))
 		})
 
 		It("returns not found when deleting a garbanzo with the wrong octo name", func() {
-			err := store.DeleteByAPIUUIDAndOctoName(org1Ctx, database, org1Octo1Garbanzo1.APIUUID, org1Octo2.Name)
+			err := store.DeleteByAPIUUIDAndOctoName(org1Ctx, database, org1Octo1Garbanzo1.APIUUID, org1Octo2.Name, 0)
 
 			Expect(err).To(Equal(persistence.ErrNotFound))
 		})
 
 		It("deletes a garbanzo", func() {
-			Expect(store.DeleteByAPIUUIDAndOctoName(org1Ctx, database, org1Octo1Garbanzo1.APIUUID, org1Octo1.Name)).To(Succeed())
+			Expect(store.DeleteByAPIUUIDAndOctoName(org1Ctx, database, org1Octo1Garbanzo1.APIUUID, org1Octo1.Name, 0)).To(Succeed())
+
+			err := store.DeleteByAPIUUIDAndOctoName(org1Ctx, database, org1Octo1Garbanzo1.APIUUID, org1Octo1.Name, 0)
+			Expect(err).To(Equal(persistence.ErrNotFound))
+		})
 
-			err := store.DeleteByAPIUUIDAndOctoName(org1Ctx, database, org1Octo1Garbanzo1.APIUUID, org1Octo1.Name)
+		It("deletes a garbanzo still at the expected version", func() {
+			Expect(store.DeleteByAPIUUIDAndOctoName(org1Ctx, database, org1Octo1Garbanzo1.APIUUID, org1Octo1.Name, 1)).To(Succeed())
+
+			_, err := store.FetchByAPIUUIDAndOctoName(org1Ctx, database, org1Octo1Garbanzo1.APIUUID, org1Octo1.Name)
 			Expect(err).To(Equal(persistence.ErrNotFound))
 		})
 
+		It("returns a version mismatch when the garbanzo has been modified", func() {
+			err := store.DeleteByAPIUUIDAndOctoName(org1Ctx, database, org1Octo1Garbanzo1.APIUUID, org1Octo1.Name, 2)
+			Expect(err).To(Equal(persistence.ErrVersionMismatch))
+
+			_, err = store.FetchByAPIUUIDAndOctoName(org1Ctx, database, org1Octo1Garbanzo1.APIUUID, org1Octo1.Name)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
 		It("returns not found when deleting a garbanzo with the wrong org", func() {
-			err := store.DeleteByAPIUUIDAndOctoName(org2Ctx, database, org1Octo1Garbanzo1.APIUUID, org1Octo1.Name)
+			err := store.DeleteByAPIUUIDAndOctoName(org2Ctx, database, org1Octo1Garbanzo1.APIUUID, org1Octo1.Name, 0)
 
 			Expect(err).To(Equal(persistence.ErrNotFound))
 		})
@@ -248,7 +529,7 @@ var _ = Describe("GarbanzoStore Integration", func() {
 		It("deletes some garbanzos", func() {
 			org1Octo2Garbanzo1 := data.Garbanzo{
 				APIUUID:      uuid.NewV4(),
-				GarbanzoType: data.DESI,
+				GarbanzoType: desi,
 				OctoId:       org1Octo2.Id,
 				DiameterMM:   5.6,
 			}
@@ -256,14 +537,15 @@ var _ = Describe("GarbanzoStore Integration", func() {
 			id, err := store.Create(org1Ctx, database, org1Octo2Garbanzo1)
 			Expect(err).NotTo(HaveOccurred())
 			org1Octo2Garbanzo1.Id = id
+			org1Octo2Garbanzo1.Version = 1
 
 			Expect(store.DeleteByOctoId(org1Ctx, database, org1Octo1.Id)).To(Succeed())
 
-			garbanzos, err := store.FetchByOctoName(org1Ctx, database, org1Octo1.Name)
+			garbanzos, _, err := store.FetchByOctoName(org1Ctx, database, org1Octo1.Name, persistence.GarbanzoFilter{}, persistence.Page{Limit: 10})
 			Expect(err).NotTo(HaveOccurred())
 			Expect(len(garbanzos)).To(Equal(0))
 
-			garbanzos, err = store.FetchByOctoName(org1Ctx, database, org1Octo2.Name)
+			garbanzos, _, err = store.FetchByOctoName(org1Ctx, database, org1Octo2.Name, persistence.GarbanzoFilter{}, persistence.Page{Limit: 10})
 			Expect(err).NotTo(HaveOccurred())
 			Expect(garbanzos).To(Equal([]data.Garbanzo{org1Octo2Garbanzo1}))
 		})
@@ -271,7 +553,7 @@ var _ = Describe("GarbanzoStore Integration", func() {
 		It("returns no error when deleting garbanzos with the wrong org (but doesn't actually delete anything)", func() {
 			Expect(store.DeleteByOctoId(org2Ctx, database, org1Octo1.Id)).To(Succeed())
 
-			garbanzos, err := store.FetchByOctoName(org1Ctx, database, org1Octo1.Name)
+			garbanzos, _, err := store.FetchByOctoName(org1Ctx, database, org1Octo1.Name, persistence.GarbanzoFilter{}, persistence.Page{Limit: 10})
 			Expect(err).NotTo(HaveOccurred())
 			Expect(garbanzos).To(HaveLen(2))
 		})
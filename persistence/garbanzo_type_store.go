@@ -0,0 +1,48 @@
+package persistence
+
+import (
+	"context"
+
+	"github.com/myshkin5/effective-octo-garbanzo/persistence/data"
+)
+
+// GarbanzoTypeStore manages the garbanzo types which are shared by all orgs so
+// unlike the other stores, it is not scoped by the org of the context.
+type GarbanzoTypeStore struct{}
+
+func (GarbanzoTypeStore) FetchAll(ctx context.Context, database Database) ([]data.GarbanzoType, error) {
+	defer observeQuery("GarbanzoTypeStore.FetchAll")()
+
+	query := "select id, name from garbanzo_type order by id"
+
+	rows, err := database.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var garbanzoTypes []data.GarbanzoType
+	for rows.Next() {
+		var id int
+		var name string
+		err = rows.Scan(&id, &name)
+		if err != nil {
+			return nil, err
+		}
+
+		garbanzoType := data.GarbanzoType{
+			Id:   id,
+			Name: name,
+		}
+		garbanzoTypes = append(garbanzoTypes, garbanzoType)
+	}
+
+	return garbanzoTypes, nil
+}
+
+func (GarbanzoTypeStore) Create(ctx context.Context, database Database, garbanzoType data.GarbanzoType) (int, error) {
+	defer observeQuery("GarbanzoTypeStore.Create")()
+
+	query := "insert into garbanzo_type (name) values ($1) returning id"
+	return ExecInsert(ctx, database, query, garbanzoType.Name)
+}
@@ -0,0 +1,54 @@
+package persistence_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/myshkin5/effective-octo-garbanzo/persistence"
+	"github.com/myshkin5/effective-octo-garbanzo/persistence/data"
+)
+
+var _ = Describe("GarbanzoTypeStore Integration", func() {
+	var (
+		database persistence.Database
+		store    persistence.GarbanzoTypeStore
+	)
+
+	BeforeEach(func() {
+		var err error
+		database, err = persistence.Open()
+		Expect(err).NotTo(HaveOccurred())
+
+		cleanDatabase(database)
+
+		store = persistence.GarbanzoTypeStore{}
+	})
+
+	Describe("FetchAll", func() {
+		It("fetches the seeded garbanzo types in order", func() {
+			garbanzoTypes, err := store.FetchAll(ctx, database)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(len(garbanzoTypes)).To(BeNumerically(">=", 2))
+			Expect(garbanzoTypes[0]).To(Equal(desi))
+			Expect(garbanzoTypes[1]).To(Equal(kabuli))
+		})
+	})
+
+	Describe("Create", func() {
+		It("creates a new garbanzo type", func() {
+			id, err := store.Create(ctx, database, data.GarbanzoType{Name: "INT_TEST_BAMBAI"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(id).To(BeNumerically(">", kabuli.Id))
+
+			garbanzoTypes, err := store.FetchAll(ctx, database)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(garbanzoTypes).To(ContainElement(data.GarbanzoType{Id: id, Name: "INT_TEST_BAMBAI"}))
+		})
+
+		It("returns a duplicate error when the name is already taken", func() {
+			_, err := store.Create(ctx, database, data.GarbanzoType{Name: desi.Name})
+			Expect(err).To(Equal(persistence.ErrDuplicate))
+		})
+	})
+})
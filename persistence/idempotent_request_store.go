@@ -0,0 +1,56 @@
+package persistence
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/myshkin5/effective-octo-garbanzo/persistence/data"
+)
+
+// IdempotentRequestStore records the responses to creates sent with an
+// Idempotency-Key header. Keys are scoped by the org of the context.
+type IdempotentRequestStore struct{}
+
+func (IdempotentRequestStore) FetchByKey(ctx context.Context, database Database, key string) (data.IdempotentRequest, error) {
+	defer observeQuery("IdempotentRequestStore.FetchByKey")()
+
+	query := `select r.request_hash, r.response, r.created_at from idempotent_request r
+		join org on r.org_id = org.id
+		where r.idempotency_key = $1 and org.name = $2`
+
+	request := data.IdempotentRequest{Key: key}
+	var response string
+	err := database.QueryRow(ctx, query, key, org(ctx)).Scan(&request.RequestHash, &response, &request.CreatedAt)
+	if err == sql.ErrNoRows {
+		return data.IdempotentRequest{}, ErrNotFound
+	} else if err != nil {
+		return data.IdempotentRequest{}, err
+	}
+	request.Response = []byte(response)
+
+	return request, nil
+}
+
+func (IdempotentRequestStore) Create(ctx context.Context, database Database, request data.IdempotentRequest) error {
+	defer observeQuery("IdempotentRequestStore.Create")()
+
+	query := `insert into idempotent_request (org_id, idempotency_key, request_hash, response, created_at)
+		select id, $1, $2, $3, $4 from org where name = $5 returning id`
+	_, err := ExecInsert(ctx, database, query, request.Key, request.RequestHash, string(request.Response),
+		request.CreatedAt.UTC(), org(ctx))
+	if err == sql.ErrNoRows {
+		return ErrOrgNotFound
+	}
+
+	return err
+}
+
+// DeleteExpired deletes the requests of every org created before before.
+func (IdempotentRequestStore) DeleteExpired(ctx context.Context, database Database, before time.Time) error {
+	defer observeQuery("IdempotentRequestStore.DeleteExpired")()
+
+	query := "delete from idempotent_request where created_at < $1"
+	_, err := ExecDelete(ctx, database, query, before.UTC())
+	return err
+}
@@ -0,0 +1,94 @@
+package persistence_test
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/myshkin5/effective-octo-garbanzo/persistence"
+	"github.com/myshkin5/effective-octo-garbanzo/persistence/data"
+)
+
+var _ = Describe("IdempotentRequestStore Integration", func() {
+	var (
+		database         persistence.Database
+		store            persistence.IdempotentRequestStore
+		org1Ctx, org2Ctx context.Context
+		request          data.IdempotentRequest
+	)
+
+	BeforeEach(func() {
+		var err error
+		database, err = persistence.Open()
+		Expect(err).NotTo(HaveOccurred())
+
+		cleanDatabase(database)
+
+		_, orgName := createOrg("idempotent_request_store", database)
+		_, orgName2 := createOrg("idempotent_request_store2", database)
+
+		org1Ctx = context.WithValue(ctx, persistence.OrgContextKey, orgName)
+		org2Ctx = context.WithValue(ctx, persistence.OrgContextKey, orgName2)
+
+		store = persistence.IdempotentRequestStore{}
+
+		request = data.IdempotentRequest{
+			Key:         "retry-me",
+			RequestHash: "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef",
+			Response:    []byte(`{"code": 201}`),
+			CreatedAt:   time.Now().Add(-time.Minute),
+		}
+	})
+
+	It("creates and fetches a request", func() {
+		Expect(store.Create(org1Ctx, database, request)).To(Succeed())
+
+		fetched, err := store.FetchByKey(org1Ctx, database, "retry-me")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(fetched.Key).To(Equal(request.Key))
+		Expect(fetched.RequestHash).To(Equal(request.RequestHash))
+		Expect(fetched.Response).To(Equal(request.Response))
+		Expect(fetched.CreatedAt).To(BeTemporally("~", request.CreatedAt, time.Second))
+	})
+
+	It("scopes keys by org", func() {
+		Expect(store.Create(org1Ctx, database, request)).To(Succeed())
+
+		_, err := store.FetchByKey(org2Ctx, database, "retry-me")
+		Expect(err).To(Equal(persistence.ErrNotFound))
+
+		Expect(store.Create(org2Ctx, database, request)).To(Succeed())
+	})
+
+	It("returns a duplicate error when the org already used the key", func() {
+		Expect(store.Create(org1Ctx, database, request)).To(Succeed())
+
+		Expect(store.Create(org1Ctx, database, request)).To(Equal(persistence.ErrDuplicate))
+	})
+
+	It("returns org not found for an unknown org", func() {
+		orgCtx := context.WithValue(ctx, persistence.OrgContextKey, "int_test_org_unknown")
+		Expect(store.Create(orgCtx, database, request)).To(Equal(persistence.ErrOrgNotFound))
+	})
+
+	It("deletes the expired requests of every org", func() {
+		Expect(store.Create(org1Ctx, database, request)).To(Succeed())
+		request.Key = "recent"
+		request.CreatedAt = time.Now()
+		Expect(store.Create(org1Ctx, database, request)).To(Succeed())
+		request.Key = "other-org"
+		request.CreatedAt = time.Now().Add(-time.Hour)
+		Expect(store.Create(org2Ctx, database, request)).To(Succeed())
+
+		Expect(store.DeleteExpired(ctx, database, time.Now().Add(-time.Second))).To(Succeed())
+
+		_, err := store.FetchByKey(org1Ctx, database, "retry-me")
+		Expect(err).To(Equal(persistence.ErrNotFound))
+		_, err = store.FetchByKey(org1Ctx, database, "recent")
+		Expect(err).NotTo(HaveOccurred())
+		_, err = store.FetchByKey(org2Ctx, database, "other-org")
+		Expect(err).To(Equal(persistence.ErrNotFound))
+	})
+})
@@ -0,0 +1,86 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/myshkin5/effective-octo-garbanzo/persistence"
+	"github.com/myshkin5/effective-octo-garbanzo/persistence/data"
+)
+
+// APIKeyStore manages the API keys of every org. Like OrgStore, it is not
+// scoped by the org of the context.
+type APIKeyStore struct{}
+
+func (APIKeyStore) FetchByOrgName(ctx context.Context, database persistence.Database, orgName string) ([]data.APIKey, error) {
+	var apiKeys []data.APIKey
+	err := access(database, func(s *state) error {
+		i := s.orgIndex(orgName)
+		if i < 0 {
+			return nil
+		}
+		for _, stored := range s.apiKeys {
+			if stored.OrgId == s.orgs[i].Id {
+				// Like the SQL store, the hash isn't fetched
+				stored.Hash = ""
+				apiKeys = append(apiKeys, stored.APIKey)
+			}
+		}
+		return nil
+	})
+
+	return apiKeys, err
+}
+
+func (APIKeyStore) FetchByHash(ctx context.Context, database persistence.Database, hash string) (data.APIKey, error) {
+	var apiKey data.APIKey
+	err := access(database, func(s *state) error {
+		i := s.apiKeyIndex(func(k data.APIKey) bool { return k.Hash == hash })
+		if i < 0 {
+			return persistence.ErrNotFound
+		}
+		apiKey = s.apiKeys[i].APIKey
+		return nil
+	})
+
+	return apiKey, err
+}
+
+func (APIKeyStore) Create(ctx context.Context, database persistence.Database, apiKeyIn data.APIKey) (int, error) {
+	err := access(database, func(s *state) error {
+		i := s.orgIndex(apiKeyIn.OrgName)
+		if i < 0 {
+			return persistence.ErrOrgNotFound
+		}
+		if s.apiKeyIndex(func(k data.APIKey) bool { return k.Prefix == apiKeyIn.Prefix || k.Hash == apiKeyIn.Hash }) >= 0 {
+			return persistence.ErrDuplicate
+		}
+		apiKeyIn.Id = s.nextId(apiKeySequence)
+		apiKeyIn.Scopes = append([]string(nil), apiKeyIn.Scopes...)
+		s.apiKeys = append(s.apiKeys, apiKey{APIKey: apiKeyIn, OrgId: s.orgs[i].Id})
+		return nil
+	})
+
+	return apiKeyIn.Id, err
+}
+
+func (APIKeyStore) UpdateLastUsed(ctx context.Context, database persistence.Database, id int, lastUsedAt time.Time) error {
+	return access(database, func(s *state) error {
+		i := s.apiKeyIndex(func(k data.APIKey) bool { return k.Id == id })
+		if i >= 0 {
+			s.apiKeys[i].LastUsedAt = lastUsedAt
+		}
+		return nil
+	})
+}
+
+func (APIKeyStore) DeleteByPrefixAndOrgName(ctx context.Context, database persistence.Database, prefix, orgName string) error {
+	return access(database, func(s *state) error {
+		i := s.apiKeyIndex(func(k data.APIKey) bool { return k.Prefix == prefix && k.OrgName == orgName })
+		if i < 0 {
+			return persistence.ErrNotFound
+		}
+		s.apiKeys = append(s.apiKeys[:i], s.apiKeys[i+1:]...)
+		return nil
+	})
+}
@@ -0,0 +1,106 @@
+package memory_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/myshkin5/effective-octo-garbanzo/persistence"
+	"github.com/myshkin5/effective-octo-garbanzo/persistence/data"
+	"github.com/myshkin5/effective-octo-garbanzo/persistence/memory"
+)
+
+var _ = Describe("APIKeyStore", func() {
+	var (
+		database *memory.Database
+		store    memory.APIKeyStore
+		orgId    int
+		apiKey   data.APIKey
+	)
+
+	BeforeEach(func() {
+		database = memory.NewDatabase()
+		store = memory.APIKeyStore{}
+
+		var err error
+		orgId, err = memory.OrgStore{}.Create(ctx, database, data.Org{Name: "org1"})
+		Expect(err).NotTo(HaveOccurred())
+
+		apiKey = data.APIKey{
+			Prefix:    "prefix1",
+			Hash:      "hash1",
+			OrgName:   "org1",
+			Name:      "feeder",
+			Scopes:    []string{"garbanzos:write"},
+			CreatedAt: time.Now(),
+		}
+	})
+
+	It("creates and fetches keys", func() {
+		id, err := store.Create(ctx, database, apiKey)
+		Expect(err).NotTo(HaveOccurred())
+		apiKey.Id = id
+
+		fetched, err := store.FetchByHash(ctx, database, "hash1")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(fetched).To(Equal(apiKey))
+
+		apiKeys, err := store.FetchByOrgName(ctx, database, "org1")
+		Expect(err).NotTo(HaveOccurred())
+		apiKey.Hash = ""
+		Expect(apiKeys).To(Equal([]data.APIKey{apiKey}))
+	})
+
+	It("returns not found when fetching an unknown hash", func() {
+		_, err := store.FetchByHash(ctx, database, "hash1")
+		Expect(err).To(Equal(persistence.ErrNotFound))
+	})
+
+	It("returns org not found when creating a key for an unknown org", func() {
+		apiKey.OrgName = "org2"
+
+		_, err := store.Create(ctx, database, apiKey)
+		Expect(err).To(Equal(persistence.ErrOrgNotFound))
+	})
+
+	It("returns a duplicate error when the prefix is already taken", func() {
+		_, err := store.Create(ctx, database, apiKey)
+		Expect(err).NotTo(HaveOccurred())
+
+		apiKey.Hash = "hash2"
+		_, err = store.Create(ctx, database, apiKey)
+		Expect(err).To(Equal(persistence.ErrDuplicate))
+	})
+
+	It("records when a key was last used", func() {
+		id, err := store.Create(ctx, database, apiKey)
+		Expect(err).NotTo(HaveOccurred())
+		lastUsedAt := time.Now()
+
+		Expect(store.UpdateLastUsed(ctx, database, id, lastUsedAt)).To(Succeed())
+
+		fetched, err := store.FetchByHash(ctx, database, "hash1")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(fetched.LastUsedAt).To(Equal(lastUsedAt))
+	})
+
+	It("deletes a key", func() {
+		_, err := store.Create(ctx, database, apiKey)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(store.DeleteByPrefixAndOrgName(ctx, database, "prefix1", "org2")).To(Equal(persistence.ErrNotFound))
+		Expect(store.DeleteByPrefixAndOrgName(ctx, database, "prefix1", "org1")).To(Succeed())
+		Expect(store.DeleteByPrefixAndOrgName(ctx, database, "prefix1", "org1")).To(Equal(persistence.ErrNotFound))
+	})
+
+	It("deletes the keys of a deleted org", func() {
+		_, err := store.Create(ctx, database, apiKey)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(memory.OrgStore{}.DeleteById(ctx, database, orgId)).To(Succeed())
+
+		_, err = store.FetchByHash(ctx, database, "hash1")
+		Expect(err).To(Equal(persistence.ErrNotFound))
+	})
+})
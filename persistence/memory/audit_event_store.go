@@ -0,0 +1,79 @@
+package memory
+
+import (
+	"context"
+	"strings"
+
+	"github.com/myshkin5/effective-octo-garbanzo/persistence"
+	"github.com/myshkin5/effective-octo-garbanzo/persistence/data"
+)
+
+type AuditEventStore struct{}
+
+func (AuditEventStore) FetchAll(ctx context.Context, database persistence.Database, filter persistence.AuditFilter, page persistence.Page) ([]data.AuditEvent, bool, error) {
+	if page.Sort.Field != "" {
+		return nil, false, persistence.ErrInvalidSort
+	}
+
+	var events []data.AuditEvent
+	var more bool
+	err := access(database, func(s *state) error {
+		i := s.orgIndex(org(ctx))
+		if i < 0 {
+			return nil
+		}
+		orgId := s.orgs[i].Id
+
+		var candidates []data.AuditEvent
+		var keys []key
+		for _, event := range s.auditEvents {
+			if event.OrgId == orgId && matchesAudit(filter, event.AuditEvent) {
+				candidates = append(candidates, event.AuditEvent)
+				keys = append(keys, key{id: event.Id})
+			}
+		}
+
+		var indexes []int
+		indexes, more = paginate(keys, page)
+		for _, i := range indexes {
+			events = append(events, candidates[i])
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	return events, more, nil
+}
+
+func matchesAudit(filter persistence.AuditFilter, event data.AuditEvent) bool {
+	if !filter.From.IsZero() && event.CreatedAt.Before(filter.From) {
+		return false
+	}
+	if !filter.To.IsZero() && !event.CreatedAt.Before(filter.To) {
+		return false
+	}
+
+	return filter.Resource == "" || event.Resource == filter.Resource ||
+		strings.HasPrefix(event.Resource, filter.Resource+"/")
+}
+
+func (AuditEventStore) Create(ctx context.Context, database persistence.Database, event data.AuditEvent) (int, error) {
+	err := access(database, func(s *state) error {
+		i := s.orgIndex(org(ctx))
+		if i < 0 {
+			return persistence.ErrOrgNotFound
+		}
+		event.Id = s.nextId(auditEventSequence)
+		event.Before = append([]byte(nil), event.Before...)
+		event.After = append([]byte(nil), event.After...)
+		s.auditEvents = append(s.auditEvents, auditEvent{AuditEvent: event, OrgId: s.orgs[i].Id})
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return event.Id, nil
+}
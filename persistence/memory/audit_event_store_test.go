@@ -0,0 +1,102 @@
+package memory_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/myshkin5/effective-octo-garbanzo/persistence"
+	"github.com/myshkin5/effective-octo-garbanzo/persistence/data"
+	"github.com/myshkin5/effective-octo-garbanzo/persistence/memory"
+)
+
+var _ = Describe("AuditEventStore", func() {
+	var (
+		database *memory.Database
+		store    memory.AuditEventStore
+		start    time.Time
+	)
+
+	BeforeEach(func() {
+		database = memory.NewDatabase()
+		store = memory.AuditEventStore{}
+
+		for _, name := range []string{"org1", "org2"} {
+			_, err := memory.OrgStore{}.Create(ctx, database, data.Org{Name: name})
+			Expect(err).NotTo(HaveOccurred())
+		}
+
+		start = time.Now().Add(-time.Hour)
+		for i, resource := range []string{"octos/kraken", "octos/kraken/garbanzos/abc", "octos/kraken2"} {
+			_, err := store.Create(orgContext("org1"), database, data.AuditEvent{
+				Actor:     "user1",
+				Action:    "create",
+				Resource:  resource,
+				After:     []byte(`{"name": "kraken"}`),
+				RequestID: "req1",
+				CreatedAt: start.Add(time.Duration(i) * time.Minute),
+			})
+			Expect(err).NotTo(HaveOccurred())
+		}
+	})
+
+	It("creates and fetches the org's events", func() {
+		events, more, err := store.FetchAll(orgContext("org1"), database, persistence.AuditFilter{}, persistence.Page{Limit: 10})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(more).To(BeFalse())
+		Expect(events).To(HaveLen(3))
+		Expect(events[0]).To(Equal(data.AuditEvent{
+			Id:        events[0].Id,
+			Actor:     "user1",
+			Action:    "create",
+			Resource:  "octos/kraken",
+			After:     []byte(`{"name": "kraken"}`),
+			RequestID: "req1",
+			CreatedAt: start,
+		}))
+
+		events, _, err = store.FetchAll(orgContext("org2"), database, persistence.AuditFilter{}, persistence.Page{Limit: 10})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(events).To(BeEmpty())
+	})
+
+	It("filters events by time and resource", func() {
+		events, _, err := store.FetchAll(orgContext("org1"), database, persistence.AuditFilter{
+			From: start.Add(time.Minute),
+			To:   start.Add(2 * time.Minute),
+		}, persistence.Page{Limit: 10})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(events).To(HaveLen(1))
+		Expect(events[0].Resource).To(Equal("octos/kraken/garbanzos/abc"))
+
+		events, _, err = store.FetchAll(orgContext("org1"), database, persistence.AuditFilter{
+			Resource: "octos/kraken",
+		}, persistence.Page{Limit: 10})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(events).To(HaveLen(2))
+		Expect(events[0].Resource).To(Equal("octos/kraken"))
+		Expect(events[1].Resource).To(Equal("octos/kraken/garbanzos/abc"))
+	})
+
+	It("fetches the newest events first when descending", func() {
+		page := persistence.Page{Limit: 2, Sort: persistence.Sort{Descending: true}}
+		events, more, err := store.FetchAll(orgContext("org1"), database, persistence.AuditFilter{}, page)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(more).To(BeTrue())
+		Expect(events).To(HaveLen(2))
+		Expect(events[0].Resource).To(Equal("octos/kraken2"))
+
+		page.AfterId = events[1].Id
+		events, more, err = store.FetchAll(orgContext("org1"), database, persistence.AuditFilter{}, page)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(more).To(BeFalse())
+		Expect(events).To(HaveLen(1))
+		Expect(events[0].Resource).To(Equal("octos/kraken"))
+	})
+
+	It("returns org not found for an unknown org", func() {
+		_, err := store.Create(orgContext("org3"), database, data.AuditEvent{})
+		Expect(err).To(Equal(persistence.ErrOrgNotFound))
+	})
+})
@@ -0,0 +1,146 @@
+// Package memory implements the stores on an in-memory database so the
+// service can run without Postgres. Data is lost when the process exits.
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+
+	"github.com/myshkin5/effective-octo-garbanzo/logs"
+	"github.com/myshkin5/effective-octo-garbanzo/persistence"
+	"github.com/myshkin5/effective-octo-garbanzo/persistence/data"
+)
+
+var (
+	ErrSQLNotSupported      = errors.New("the memory database does not support SQL")
+	ErrNestedTxNotSupported = errors.New("the memory database does not support nested transactions")
+)
+
+// Database is an in-memory persistence.Database. Transactions are
+// serializable as only one may be open at a time: BeginTx blocks until the
+// open transaction is committed or rolled back. Access outside of a
+// transaction blocks while a transaction is open too so a transaction must
+// only be accessed through the Database returned by BeginTx.
+type Database struct {
+	shared *shared
+	tx     *state
+	done   bool
+}
+
+type shared struct {
+	mutex sync.Mutex
+	state *state
+}
+
+// NewDatabase returns an empty database seeded with the garbanzo types of the
+// initial migration.
+func NewDatabase() *Database {
+	return &Database{
+		shared: &shared{
+			state: &state{
+				garbanzoTypes: []data.GarbanzoType{
+					{Id: 1001, Name: "DESI"},
+					{Id: 1002, Name: "KABULI"},
+				},
+				lastIds: map[string]int{
+					garbanzoTypeSequence: 1002,
+				},
+			},
+		},
+	}
+}
+
+func (d *Database) Exec(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return nil, ErrSQLNotSupported
+}
+
+func (d *Database) Query(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return nil, ErrSQLNotSupported
+}
+
+func (d *Database) QueryRow(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	// A *sql.Row carrying an error can't be built outside of database/sql
+	logs.FromContext(ctx).Panic(ErrSQLNotSupported)
+	return nil
+}
+
+func (d *Database) BeginTx(ctx context.Context) (persistence.Database, error) {
+	if d.tx != nil {
+		return nil, ErrNestedTxNotSupported
+	}
+
+	d.shared.mutex.Lock()
+
+	return &Database{
+		shared: d.shared,
+		tx:     d.shared.state.clone(),
+	}, nil
+}
+
+func (d *Database) Commit() error {
+	if d.tx == nil || d.done {
+		return sql.ErrTxDone
+	}
+	d.done = true
+
+	d.shared.state = d.tx
+	d.shared.mutex.Unlock()
+
+	return nil
+}
+
+func (d *Database) Rollback() error {
+	if d.tx == nil || d.done {
+		return sql.ErrTxDone
+	}
+	d.done = true
+
+	d.shared.mutex.Unlock()
+
+	return nil
+}
+
+func (d *Database) Ping(ctx context.Context) error {
+	if d.tx != nil && d.done {
+		return sql.ErrTxDone
+	}
+
+	return nil
+}
+
+// Close has nothing to release; the data is simply dropped with the database.
+func (d *Database) Close() error {
+	if d.tx != nil {
+		return persistence.ErrCloseTx
+	}
+
+	return nil
+}
+
+// access calls fn with the state seen by database. The state is locked for
+// the duration of fn when database isn't a transaction. fn must not modify
+// the state when it returns an error.
+func access(database persistence.Database, fn func(s *state) error) error {
+	d, ok := database.(*Database)
+	if !ok {
+		logs.Logger.Panicf("Memory stores require a memory database, got %T", database)
+	}
+
+	if d.tx != nil {
+		if d.done {
+			return sql.ErrTxDone
+		}
+		return fn(d.tx)
+	}
+
+	d.shared.mutex.Lock()
+	defer d.shared.mutex.Unlock()
+
+	return fn(d.shared.state)
+}
+
+func org(ctx context.Context) string {
+	return ctx.Value(persistence.OrgContextKey).(string)
+}
@@ -0,0 +1,126 @@
+package memory_test
+
+import (
+	"database/sql"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/myshkin5/effective-octo-garbanzo/persistence"
+	"github.com/myshkin5/effective-octo-garbanzo/persistence/data"
+	"github.com/myshkin5/effective-octo-garbanzo/persistence/memory"
+)
+
+var _ = Describe("Database", func() {
+	var (
+		database *memory.Database
+		store    memory.OrgStore
+	)
+
+	BeforeEach(func() {
+		database = memory.NewDatabase()
+		store = memory.OrgStore{}
+	})
+
+	It("seeds the initial garbanzo types", func() {
+		garbanzoTypes, err := memory.GarbanzoTypeStore{}.FetchAll(ctx, database)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(garbanzoTypes).To(Equal([]data.GarbanzoType{
+			{Id: 1001, Name: "DESI"},
+			{Id: 1002, Name: "KABULI"},
+		}))
+	})
+
+	It("does not support SQL", func() {
+		_, err := database.Exec(ctx, "select 1")
+		Expect(err).To(Equal(memory.ErrSQLNotSupported))
+		_, err = database.Query(ctx, "select 1")
+		Expect(err).To(Equal(memory.ErrSQLNotSupported))
+	})
+
+	It("makes committed changes visible", func() {
+		tx, err := database.BeginTx(ctx)
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = store.Create(ctx, tx, data.Org{Name: "org1"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(tx.Commit()).To(Succeed())
+
+		_, err = store.FetchByName(ctx, database, "org1", false)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("discards rolled back changes", func() {
+		tx, err := database.BeginTx(ctx)
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = store.Create(ctx, tx, data.Org{Name: "org1"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(tx.Rollback()).To(Succeed())
+
+		orgs, err := store.FetchAll(ctx, database)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(orgs).To(BeEmpty())
+	})
+
+	It("doesn't reuse ids after a rollback", func() {
+		tx, err := database.BeginTx(ctx)
+		Expect(err).NotTo(HaveOccurred())
+		id1, err := store.Create(ctx, tx, data.Org{Name: "org1"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(tx.Rollback()).To(Succeed())
+
+		id2, err := store.Create(ctx, database, data.Org{Name: "org1"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(id2).To(BeNumerically(">", id1))
+	})
+
+	It("returns an error when a finished transaction is used", func() {
+		tx, err := database.BeginTx(ctx)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(tx.Commit()).To(Succeed())
+
+		Expect(tx.Commit()).To(Equal(sql.ErrTxDone))
+		Expect(tx.Rollback()).To(Equal(sql.ErrTxDone))
+		Expect(tx.Ping(ctx)).To(Equal(sql.ErrTxDone))
+		_, err = store.FetchAll(ctx, tx)
+		Expect(err).To(Equal(sql.ErrTxDone))
+	})
+
+	It("does not support nested transactions", func() {
+		tx, err := database.BeginTx(ctx)
+		Expect(err).NotTo(HaveOccurred())
+		defer tx.Rollback()
+
+		_, err = tx.BeginTx(ctx)
+		Expect(err).To(Equal(memory.ErrNestedTxNotSupported))
+	})
+
+	It("does not close transactions", func() {
+		tx, err := database.BeginTx(ctx)
+		Expect(err).NotTo(HaveOccurred())
+		defer tx.Rollback()
+
+		Expect(tx.Close()).To(Equal(persistence.ErrCloseTx))
+		Expect(database.Close()).To(Succeed())
+	})
+
+	It("serializes transactions", func() {
+		tx, err := database.BeginTx(ctx)
+		Expect(err).NotTo(HaveOccurred())
+
+		began := make(chan bool)
+		go func() {
+			defer GinkgoRecover()
+			tx2, err := database.BeginTx(ctx)
+			Expect(err).NotTo(HaveOccurred())
+			began <- true
+			Expect(tx2.Rollback()).To(Succeed())
+		}()
+
+		Consistently(began, 50*time.Millisecond).ShouldNot(Receive())
+		Expect(tx.Rollback()).To(Succeed())
+		Eventually(began).Should(Receive())
+	})
+})
@@ -0,0 +1,254 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/satori/go.uuid"
+
+	"github.com/myshkin5/effective-octo-garbanzo/persistence"
+	"github.com/myshkin5/effective-octo-garbanzo/persistence/data"
+)
+
+// garbanzoSortValues is the allow-list of fields garbanzos may be sorted by.
+// Like the SQL store, GarbanzoType sorts by the type's id.
+var garbanzoSortValues = map[string]func(garbanzo data.Garbanzo) float64{
+	"GarbanzoType": func(garbanzo data.Garbanzo) float64 { return float64(garbanzo.GarbanzoType.Id) },
+	"DiameterMM":   func(garbanzo data.Garbanzo) float64 { return float64(garbanzo.DiameterMM) },
+}
+
+var comparators = map[persistence.Operator]func(a, b float32) bool{
+	persistence.Equal:              func(a, b float32) bool { return a == b },
+	persistence.GreaterThan:        func(a, b float32) bool { return a > b },
+	persistence.GreaterThanOrEqual: func(a, b float32) bool { return a >= b },
+	persistence.LessThan:           func(a, b float32) bool { return a < b },
+	persistence.LessThanOrEqual:    func(a, b float32) bool { return a <= b },
+}
+
+type GarbanzoStore struct{}
+
+func (GarbanzoStore) FetchByOctoName(ctx context.Context, database persistence.Database, octoName string, filter persistence.GarbanzoFilter, page persistence.Page) ([]data.Garbanzo, bool, error) {
+	sortValue, ok := garbanzoSortValues[page.Sort.Field]
+	if page.Sort.Field == "" {
+		sortValue = func(data.Garbanzo) float64 { return 0 }
+	} else if !ok {
+		return nil, false, persistence.ErrInvalidSort
+	}
+	for _, comparison := range filter.DiameterMM {
+		if _, ok := comparators[comparison.Operator]; !ok {
+			return nil, false, persistence.ErrInvalidOperator
+		}
+	}
+
+	var garbanzos []data.Garbanzo
+	var more bool
+	err := access(database, func(s *state) error {
+		i := s.octoIndex(org(ctx), 0, octoName)
+		if i < 0 {
+			return nil
+		}
+		octoId := s.octos[i].Id
+
+		var candidates []data.Garbanzo
+		var keys []key
+		for _, garbanzo := range s.garbanzos {
+			if garbanzo.OctoId != octoId || !matches(filter, garbanzo) {
+				continue
+			}
+			garbanzo.GarbanzoType, _ = s.garbanzoType(garbanzo.GarbanzoType.Id)
+			candidates = append(candidates, garbanzo)
+			keys = append(keys, key{sortValue: sortValue(garbanzo), id: garbanzo.Id})
+		}
+
+		var indexes []int
+		indexes, more = paginate(keys, page)
+		for _, i := range indexes {
+			garbanzos = append(garbanzos, candidates[i])
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	return garbanzos, more, nil
+}
+
+func matches(filter persistence.GarbanzoFilter, garbanzo data.Garbanzo) bool {
+	if len(filter.GarbanzoTypes) > 0 {
+		found := false
+		for _, garbanzoType := range filter.GarbanzoTypes {
+			if garbanzoType.Id == garbanzo.GarbanzoType.Id {
+				found = true
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	for _, comparison := range filter.DiameterMM {
+		if !comparators[comparison.Operator](garbanzo.DiameterMM, comparison.Value) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (GarbanzoStore) FetchByAPIUUIDAndOctoName(ctx context.Context, database persistence.Database, apiUUID uuid.UUID, octoName string) (data.Garbanzo, error) {
+	var garbanzo data.Garbanzo
+	err := access(database, func(s *state) error {
+		i := s.garbanzoIndex(org(ctx), apiUUID, octoName)
+		if i < 0 {
+			return persistence.ErrNotFound
+		}
+		garbanzo = s.garbanzos[i]
+		garbanzo.GarbanzoType, _ = s.garbanzoType(garbanzo.GarbanzoType.Id)
+		return nil
+	})
+
+	return garbanzo, err
+}
+
+func (GarbanzoStore) FetchByAPIUUID(ctx context.Context, database persistence.Database, apiUUID uuid.UUID) (data.Garbanzo, string, error) {
+	var garbanzo data.Garbanzo
+	var octoName string
+	err := access(database, func(s *state) error {
+		for _, stored := range s.garbanzos {
+			if stored.APIUUID != apiUUID {
+				continue
+			}
+			i := s.octoIndex(org(ctx), stored.OctoId, "")
+			if i >= 0 {
+				garbanzo = stored
+				garbanzo.GarbanzoType, _ = s.garbanzoType(garbanzo.GarbanzoType.Id)
+				octoName = s.octos[i].Name
+				return nil
+			}
+		}
+		return persistence.ErrNotFound
+	})
+
+	return garbanzo, octoName, err
+}
+
+func (GarbanzoStore) Create(ctx context.Context, database persistence.Database, garbanzo data.Garbanzo) (int, error) {
+	var id int
+	err := access(database, func(s *state) error {
+		var err error
+		id, err = s.createGarbanzo(org(ctx), garbanzo)
+		return err
+	})
+
+	return id, err
+}
+
+func (GarbanzoStore) CreateBatch(ctx context.Context, database persistence.Database, garbanzos []data.Garbanzo) ([]int, error) {
+	var garbanzoIds []int
+	err := access(database, func(s *state) error {
+		// All or nothing like the single insert statement of the SQL store
+		c := s.clone()
+		for _, garbanzo := range garbanzos {
+			id, err := c.createGarbanzo(org(ctx), garbanzo)
+			if err != nil {
+				return err
+			}
+			garbanzoIds = append(garbanzoIds, id)
+		}
+		*s = *c
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return garbanzoIds, nil
+}
+
+func (GarbanzoStore) UpdateByAPIUUIDAndOctoName(ctx context.Context, database persistence.Database, garbanzo data.Garbanzo, octoName string) (int, error) {
+	var version int
+	err := access(database, func(s *state) error {
+		i := s.garbanzoIndex(org(ctx), garbanzo.APIUUID, octoName)
+		if i < 0 || !matchesVersion(s.garbanzos[i].Version, garbanzo.Version) {
+			return notFoundOrMismatch(garbanzo.Version)
+		}
+		if _, ok := s.garbanzoType(garbanzo.GarbanzoType.Id); !ok {
+			return persistence.ErrNotFound
+		}
+		s.garbanzos[i].GarbanzoType = data.GarbanzoType{Id: garbanzo.GarbanzoType.Id}
+		s.garbanzos[i].DiameterMM = garbanzo.DiameterMM
+		s.garbanzos[i].Version++
+		version = s.garbanzos[i].Version
+		return nil
+	})
+
+	return version, err
+}
+
+func (GarbanzoStore) MoveById(ctx context.Context, database persistence.Database, id int, octoId int, version int) (int, error) {
+	var newVersion int
+	err := access(database, func(s *state) error {
+		if s.octoIndex(org(ctx), octoId, "") < 0 {
+			return persistence.ErrNotFound
+		}
+		for i, garbanzo := range s.garbanzos {
+			if garbanzo.Id == id && s.octoIndex(org(ctx), garbanzo.OctoId, "") >= 0 && matchesVersion(garbanzo.Version, version) {
+				s.garbanzos[i].OctoId = octoId
+				s.garbanzos[i].Version++
+				newVersion = s.garbanzos[i].Version
+				return nil
+			}
+		}
+		return notFoundOrMismatch(version)
+	})
+
+	return newVersion, err
+}
+
+func (GarbanzoStore) DeleteByAPIUUIDAndOctoName(ctx context.Context, database persistence.Database, apiUUID uuid.UUID, octoName string, version int) error {
+	return access(database, func(s *state) error {
+		i := s.garbanzoIndex(org(ctx), apiUUID, octoName)
+		if i < 0 || !matchesVersion(s.garbanzos[i].Version, version) {
+			return notFoundOrMismatch(version)
+		}
+		s.garbanzos = append(s.garbanzos[:i], s.garbanzos[i+1:]...)
+		return nil
+	})
+}
+
+func (GarbanzoStore) DeleteByOctoId(ctx context.Context, database persistence.Database, octoId int) error {
+	return access(database, func(s *state) error {
+		if s.octoIndex(org(ctx), octoId, "") < 0 {
+			return nil
+		}
+		var remaining []data.Garbanzo
+		for _, garbanzo := range s.garbanzos {
+			if garbanzo.OctoId != octoId {
+				remaining = append(remaining, garbanzo)
+			}
+		}
+		s.garbanzos = remaining
+		return nil
+	})
+}
+
+// createGarbanzo inserts the garbanzo into the named org's octo.
+func (s *state) createGarbanzo(orgName string, garbanzo data.Garbanzo) (int, error) {
+	if s.octoIndex(orgName, garbanzo.OctoId, "") < 0 {
+		return 0, persistence.ErrNotFound
+	}
+	if _, ok := s.garbanzoType(garbanzo.GarbanzoType.Id); !ok {
+		return 0, persistence.ErrNotFound
+	}
+	for _, existing := range s.garbanzos {
+		if existing.APIUUID == garbanzo.APIUUID {
+			return 0, persistence.ErrDuplicate
+		}
+	}
+	garbanzo.Id = s.nextId(garbanzoSequence)
+	garbanzo.Version = 1
+	garbanzo.GarbanzoType = data.GarbanzoType{Id: garbanzo.GarbanzoType.Id}
+	s.garbanzos = append(s.garbanzos, garbanzo)
+
+	return garbanzo.Id, nil
+}
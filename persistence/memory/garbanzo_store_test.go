@@ -0,0 +1,264 @@
+package memory_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/satori/go.uuid"
+
+	"github.com/myshkin5/effective-octo-garbanzo/persistence"
+	"github.com/myshkin5/effective-octo-garbanzo/persistence/data"
+	"github.com/myshkin5/effective-octo-garbanzo/persistence/memory"
+)
+
+var _ = Describe("GarbanzoStore", func() {
+	var (
+		database  *memory.Database
+		store     memory.GarbanzoStore
+		org1Ctx   context.Context
+		org2Ctx   context.Context
+		octo1     data.Octo
+		octo2     data.Octo
+		garbanzos []data.Garbanzo
+
+		desi   = data.GarbanzoType{Id: 1001, Name: "DESI"}
+		kabuli = data.GarbanzoType{Id: 1002, Name: "KABULI"}
+	)
+
+	BeforeEach(func() {
+		database = memory.NewDatabase()
+		store = memory.GarbanzoStore{}
+
+		for _, org := range []string{"org1", "org2"} {
+			_, err := memory.OrgStore{}.Create(ctx, database, data.Org{Name: org})
+			Expect(err).NotTo(HaveOccurred())
+		}
+		org1Ctx = orgContext("org1")
+		org2Ctx = orgContext("org2")
+
+		octo1 = data.Octo{Name: "kraken"}
+		var err error
+		octo1.Id, err = memory.OctoStore{}.Create(org1Ctx, database, octo1)
+		Expect(err).NotTo(HaveOccurred())
+		octo2 = data.Octo{Name: "cthulhu"}
+		octo2.Id, err = memory.OctoStore{}.Create(org1Ctx, database, octo2)
+		Expect(err).NotTo(HaveOccurred())
+
+		garbanzos = []data.Garbanzo{
+			{GarbanzoType: kabuli, DiameterMM: 6.4},
+			{GarbanzoType: desi, DiameterMM: 4.2},
+			{GarbanzoType: kabuli, DiameterMM: 4.2},
+		}
+		for i := range garbanzos {
+			garbanzos[i].APIUUID = uuid.NewV4()
+			garbanzos[i].OctoId = octo1.Id
+			garbanzos[i].Id, err = store.Create(org1Ctx, database, garbanzos[i])
+			Expect(err).NotTo(HaveOccurred())
+			garbanzos[i].Version = 1
+		}
+	})
+
+	Describe("FetchByOctoName", func() {
+		fetch := func(filter persistence.GarbanzoFilter, page persistence.Page) ([]data.Garbanzo, bool) {
+			fetched, more, err := store.FetchByOctoName(org1Ctx, database, octo1.Name, filter, page)
+			Expect(err).NotTo(HaveOccurred())
+			return fetched, more
+		}
+
+		It("fetches all the garbanzos with their type names", func() {
+			fetched, more := fetch(persistence.GarbanzoFilter{}, persistence.Page{Limit: 10})
+			Expect(more).To(BeFalse())
+			Expect(fetched).To(Equal(garbanzos))
+		})
+
+		It("filters by type and diameter", func() {
+			fetched, _ := fetch(persistence.GarbanzoFilter{
+				GarbanzoTypes: []data.GarbanzoType{kabuli},
+				DiameterMM:    []persistence.Comparison{{Operator: persistence.LessThan, Value: 5}},
+			}, persistence.Page{Limit: 10})
+			Expect(fetched).To(Equal([]data.Garbanzo{garbanzos[2]}))
+		})
+
+		It("pages through garbanzos sorted by a field, ties broken by id", func() {
+			sort := persistence.Sort{Field: "DiameterMM", Descending: true}
+
+			fetched, more := fetch(persistence.GarbanzoFilter{}, persistence.Page{Limit: 2, Sort: sort})
+			Expect(more).To(BeTrue())
+			Expect(fetched).To(Equal([]data.Garbanzo{garbanzos[0], garbanzos[2]}))
+
+			fetched, more = fetch(persistence.GarbanzoFilter{}, persistence.Page{
+				AfterId:   garbanzos[2].Id,
+				SortValue: float64(garbanzos[2].DiameterMM),
+				Limit:     2,
+				Sort:      sort,
+			})
+			Expect(more).To(BeFalse())
+			Expect(fetched).To(Equal([]data.Garbanzo{garbanzos[1]}))
+
+			fetched, more = fetch(persistence.GarbanzoFilter{}, persistence.Page{
+				BeforeId:  garbanzos[1].Id,
+				SortValue: float64(garbanzos[1].DiameterMM),
+				Limit:     1,
+				Sort:      sort,
+			})
+			Expect(more).To(BeTrue())
+			Expect(fetched).To(Equal([]data.Garbanzo{garbanzos[2]}))
+		})
+
+		It("returns an error for an invalid sort field", func() {
+			_, _, err := store.FetchByOctoName(org1Ctx, database, octo1.Name, persistence.GarbanzoFilter{},
+				persistence.Page{Limit: 10, Sort: persistence.Sort{Field: "OctoId"}})
+			Expect(err).To(Equal(persistence.ErrInvalidSort))
+		})
+
+		It("does not fetch garbanzos of another org", func() {
+			fetched, _, err := store.FetchByOctoName(org2Ctx, database, octo1.Name, persistence.GarbanzoFilter{}, persistence.Page{Limit: 10})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fetched).To(BeEmpty())
+		})
+	})
+
+	Describe("FetchByAPIUUIDAndOctoName", func() {
+		It("fetches a garbanzo", func() {
+			garbanzo, err := store.FetchByAPIUUIDAndOctoName(org1Ctx, database, garbanzos[1].APIUUID, octo1.Name)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(garbanzo).To(Equal(garbanzos[1]))
+		})
+
+		It("does not find garbanzos for another org", func() {
+			_, err := store.FetchByAPIUUIDAndOctoName(org2Ctx, database, garbanzos[1].APIUUID, octo1.Name)
+			Expect(err).To(Equal(persistence.ErrNotFound))
+		})
+	})
+
+	Describe("FetchByAPIUUID", func() {
+		It("fetches a garbanzo from any of the org's octos", func() {
+			garbanzo, octoName, err := store.FetchByAPIUUID(org1Ctx, database, garbanzos[1].APIUUID)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(garbanzo).To(Equal(garbanzos[1]))
+			Expect(octoName).To(Equal(octo1.Name))
+		})
+
+		It("doesn't fetch a garbanzo of another org", func() {
+			_, _, err := store.FetchByAPIUUID(org2Ctx, database, garbanzos[1].APIUUID)
+			Expect(err).To(Equal(persistence.ErrNotFound))
+		})
+	})
+
+	Describe("Create", func() {
+		It("returns a duplicate error when the API UUID is taken", func() {
+			_, err := store.Create(org1Ctx, database, garbanzos[0])
+			Expect(err).To(Equal(persistence.ErrDuplicate))
+		})
+	})
+
+	Describe("CreateBatch", func() {
+		It("creates all the garbanzos", func() {
+			batch := []data.Garbanzo{
+				{APIUUID: uuid.NewV4(), GarbanzoType: desi, OctoId: octo2.Id, DiameterMM: 3.3},
+				{APIUUID: uuid.NewV4(), GarbanzoType: kabuli, OctoId: octo2.Id, DiameterMM: 7.7},
+			}
+
+			ids, err := store.CreateBatch(org1Ctx, database, batch)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ids).To(HaveLen(2))
+
+			for i := range batch {
+				batch[i].Id = ids[i]
+				batch[i].Version = 1
+			}
+			fetched, _, err := store.FetchByOctoName(org1Ctx, database, octo2.Name, persistence.GarbanzoFilter{}, persistence.Page{Limit: 10})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fetched).To(Equal(batch))
+		})
+
+		It("creates none of the garbanzos when one fails", func() {
+			batch := []data.Garbanzo{
+				{APIUUID: uuid.NewV4(), GarbanzoType: desi, OctoId: octo2.Id, DiameterMM: 3.3},
+				garbanzos[0],
+			}
+
+			_, err := store.CreateBatch(org1Ctx, database, batch)
+			Expect(err).To(Equal(persistence.ErrDuplicate))
+
+			fetched, _, err := store.FetchByOctoName(org1Ctx, database, octo2.Name, persistence.GarbanzoFilter{}, persistence.Page{Limit: 10})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fetched).To(BeEmpty())
+		})
+	})
+
+	Describe("UpdateByAPIUUIDAndOctoName", func() {
+		It("updates a garbanzo", func() {
+			garbanzo := garbanzos[0]
+			garbanzo.GarbanzoType = desi
+			garbanzo.DiameterMM = 5.5
+			version, err := store.UpdateByAPIUUIDAndOctoName(org1Ctx, database, garbanzo, octo1.Name)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(version).To(Equal(2))
+
+			fetched, err := store.FetchByAPIUUIDAndOctoName(org1Ctx, database, garbanzo.APIUUID, octo1.Name)
+			Expect(err).NotTo(HaveOccurred())
+			garbanzo.Version = 2
+			Expect(fetched).To(Equal(garbanzo))
+		})
+
+		It("returns a version mismatch when the garbanzo has been modified", func() {
+			garbanzo := garbanzos[0]
+			garbanzo.DiameterMM = 5.5
+			garbanzo.Version = 2
+			_, err := store.UpdateByAPIUUIDAndOctoName(org1Ctx, database, garbanzo, octo1.Name)
+			Expect(err).To(Equal(persistence.ErrVersionMismatch))
+
+			fetched, err := store.FetchByAPIUUIDAndOctoName(org1Ctx, database, garbanzo.APIUUID, octo1.Name)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fetched).To(Equal(garbanzos[0]))
+		})
+	})
+
+	Describe("MoveById", func() {
+		It("moves a garbanzo to another octo", func() {
+			version, err := store.MoveById(org1Ctx, database, garbanzos[0].Id, octo2.Id, 1)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(version).To(Equal(2))
+
+			fetched, err := store.FetchByAPIUUIDAndOctoName(org1Ctx, database, garbanzos[0].APIUUID, octo2.Name)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fetched.OctoId).To(Equal(octo2.Id))
+		})
+
+		It("returns a version mismatch when the garbanzo has been modified", func() {
+			_, err := store.MoveById(org1Ctx, database, garbanzos[0].Id, octo2.Id, 2)
+			Expect(err).To(Equal(persistence.ErrVersionMismatch))
+		})
+
+		It("does not move garbanzos of another org", func() {
+			_, err := store.MoveById(org2Ctx, database, garbanzos[0].Id, octo2.Id, 0)
+			Expect(err).To(Equal(persistence.ErrNotFound))
+		})
+	})
+
+	Describe("Delete", func() {
+		It("deletes a garbanzo", func() {
+			Expect(store.DeleteByAPIUUIDAndOctoName(org1Ctx, database, garbanzos[0].APIUUID, octo1.Name, 0)).To(Succeed())
+
+			err := store.DeleteByAPIUUIDAndOctoName(org1Ctx, database, garbanzos[0].APIUUID, octo1.Name, 0)
+			Expect(err).To(Equal(persistence.ErrNotFound))
+		})
+
+		It("only deletes a garbanzo still at the expected version", func() {
+			err := store.DeleteByAPIUUIDAndOctoName(org1Ctx, database, garbanzos[0].APIUUID, octo1.Name, 2)
+			Expect(err).To(Equal(persistence.ErrVersionMismatch))
+
+			Expect(store.DeleteByAPIUUIDAndOctoName(org1Ctx, database, garbanzos[0].APIUUID, octo1.Name, 1)).To(Succeed())
+		})
+
+		It("deletes all the garbanzos of an octo so it can be deleted", func() {
+			Expect(memory.OctoStore{}.DeleteById(org1Ctx, database, octo1.Id, 0)).To(Equal(persistence.ErrInUse))
+
+			Expect(store.DeleteByOctoId(org1Ctx, database, octo1.Id)).To(Succeed())
+
+			Expect(memory.OctoStore{}.DeleteById(org1Ctx, database, octo1.Id, 0)).To(Succeed())
+		})
+	})
+})
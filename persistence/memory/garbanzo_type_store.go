@@ -0,0 +1,37 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/myshkin5/effective-octo-garbanzo/persistence"
+	"github.com/myshkin5/effective-octo-garbanzo/persistence/data"
+)
+
+// GarbanzoTypeStore manages the garbanzo types which are shared by all orgs so
+// unlike the other stores, it is not scoped by the org of the context.
+type GarbanzoTypeStore struct{}
+
+func (GarbanzoTypeStore) FetchAll(ctx context.Context, database persistence.Database) ([]data.GarbanzoType, error) {
+	var garbanzoTypes []data.GarbanzoType
+	err := access(database, func(s *state) error {
+		garbanzoTypes = append(garbanzoTypes, s.garbanzoTypes...)
+		return nil
+	})
+
+	return garbanzoTypes, err
+}
+
+func (GarbanzoTypeStore) Create(ctx context.Context, database persistence.Database, garbanzoType data.GarbanzoType) (int, error) {
+	err := access(database, func(s *state) error {
+		for _, existing := range s.garbanzoTypes {
+			if existing.Name == garbanzoType.Name {
+				return persistence.ErrDuplicate
+			}
+		}
+		garbanzoType.Id = s.nextId(garbanzoTypeSequence)
+		s.garbanzoTypes = append(s.garbanzoTypes, garbanzoType)
+		return nil
+	})
+
+	return garbanzoType.Id, err
+}
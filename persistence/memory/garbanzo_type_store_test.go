@@ -0,0 +1,37 @@
+package memory_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/myshkin5/effective-octo-garbanzo/persistence"
+	"github.com/myshkin5/effective-octo-garbanzo/persistence/data"
+	"github.com/myshkin5/effective-octo-garbanzo/persistence/memory"
+)
+
+var _ = Describe("GarbanzoTypeStore", func() {
+	var (
+		database *memory.Database
+		store    memory.GarbanzoTypeStore
+	)
+
+	BeforeEach(func() {
+		database = memory.NewDatabase()
+		store = memory.GarbanzoTypeStore{}
+	})
+
+	It("creates a garbanzo type after the seeded types", func() {
+		id, err := store.Create(ctx, database, data.GarbanzoType{Name: "BAMBAI"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(id).To(Equal(1003))
+
+		garbanzoTypes, err := store.FetchAll(ctx, database)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(garbanzoTypes).To(ContainElement(data.GarbanzoType{Id: 1003, Name: "BAMBAI"}))
+	})
+
+	It("returns a duplicate error when the name is already taken", func() {
+		_, err := store.Create(ctx, database, data.GarbanzoType{Name: "DESI"})
+		Expect(err).To(Equal(persistence.ErrDuplicate))
+	})
+})
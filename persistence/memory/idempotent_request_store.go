@@ -0,0 +1,54 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/myshkin5/effective-octo-garbanzo/persistence"
+	"github.com/myshkin5/effective-octo-garbanzo/persistence/data"
+)
+
+type IdempotentRequestStore struct{}
+
+func (IdempotentRequestStore) FetchByKey(ctx context.Context, database persistence.Database, key string) (data.IdempotentRequest, error) {
+	var request data.IdempotentRequest
+	err := access(database, func(s *state) error {
+		i := s.idempotentRequestIndex(org(ctx), key)
+		if i < 0 {
+			return persistence.ErrNotFound
+		}
+		request = s.idempotentRequests[i].IdempotentRequest
+		return nil
+	})
+
+	return request, err
+}
+
+func (IdempotentRequestStore) Create(ctx context.Context, database persistence.Database, request data.IdempotentRequest) error {
+	return access(database, func(s *state) error {
+		i := s.orgIndex(org(ctx))
+		if i < 0 {
+			return persistence.ErrOrgNotFound
+		}
+		if s.idempotentRequestIndex(org(ctx), request.Key) >= 0 {
+			return persistence.ErrDuplicate
+		}
+		request.Response = append([]byte(nil), request.Response...)
+		s.idempotentRequests = append(s.idempotentRequests, idempotentRequest{IdempotentRequest: request, OrgId: s.orgs[i].Id})
+		return nil
+	})
+}
+
+// DeleteExpired deletes the requests of every org created before before.
+func (IdempotentRequestStore) DeleteExpired(ctx context.Context, database persistence.Database, before time.Time) error {
+	return access(database, func(s *state) error {
+		var requests []idempotentRequest
+		for _, stored := range s.idempotentRequests {
+			if !stored.CreatedAt.Before(before) {
+				requests = append(requests, stored)
+			}
+		}
+		s.idempotentRequests = requests
+		return nil
+	})
+}
@@ -0,0 +1,89 @@
+package memory_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/myshkin5/effective-octo-garbanzo/persistence"
+	"github.com/myshkin5/effective-octo-garbanzo/persistence/data"
+	"github.com/myshkin5/effective-octo-garbanzo/persistence/memory"
+)
+
+var _ = Describe("IdempotentRequestStore", func() {
+	var (
+		database *memory.Database
+		store    memory.IdempotentRequestStore
+		request  data.IdempotentRequest
+	)
+
+	BeforeEach(func() {
+		database = memory.NewDatabase()
+		store = memory.IdempotentRequestStore{}
+
+		for _, name := range []string{"org1", "org2"} {
+			_, err := memory.OrgStore{}.Create(ctx, database, data.Org{Name: name})
+			Expect(err).NotTo(HaveOccurred())
+		}
+
+		request = data.IdempotentRequest{
+			Key:         "retry-me",
+			RequestHash: "hash1",
+			Response:    []byte(`{"code": 201}`),
+			CreatedAt:   time.Now().Add(-time.Minute),
+		}
+	})
+
+	It("creates and fetches requests by org", func() {
+		Expect(store.Create(orgContext("org1"), database, request)).To(Succeed())
+
+		fetched, err := store.FetchByKey(orgContext("org1"), database, "retry-me")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(fetched).To(Equal(request))
+
+		_, err = store.FetchByKey(orgContext("org2"), database, "retry-me")
+		Expect(err).To(Equal(persistence.ErrNotFound))
+	})
+
+	It("returns a duplicate error when the org already used the key", func() {
+		Expect(store.Create(orgContext("org1"), database, request)).To(Succeed())
+
+		Expect(store.Create(orgContext("org1"), database, request)).To(Equal(persistence.ErrDuplicate))
+		Expect(store.Create(orgContext("org2"), database, request)).To(Succeed())
+	})
+
+	It("returns org not found for an unknown org", func() {
+		Expect(store.Create(orgContext("org3"), database, request)).To(Equal(persistence.ErrOrgNotFound))
+	})
+
+	It("deletes the expired requests of every org", func() {
+		Expect(store.Create(orgContext("org1"), database, request)).To(Succeed())
+		Expect(store.Create(orgContext("org2"), database, request)).To(Succeed())
+		request.Key = "recent"
+		request.CreatedAt = time.Now().Add(time.Minute)
+		Expect(store.Create(orgContext("org2"), database, request)).To(Succeed())
+
+		Expect(store.DeleteExpired(ctx, database, time.Now())).To(Succeed())
+
+		_, err := store.FetchByKey(orgContext("org1"), database, "retry-me")
+		Expect(err).To(Equal(persistence.ErrNotFound))
+		_, err = store.FetchByKey(orgContext("org2"), database, "retry-me")
+		Expect(err).To(Equal(persistence.ErrNotFound))
+		_, err = store.FetchByKey(orgContext("org2"), database, "recent")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("deletes an org's requests with the org", func() {
+		orgId, err := memory.OrgStore{}.Create(ctx, database, data.Org{Name: "org3"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(store.Create(orgContext("org3"), database, request)).To(Succeed())
+
+		Expect(memory.OrgStore{}.DeleteById(ctx, database, orgId)).To(Succeed())
+		_, err = memory.OrgStore{}.Create(ctx, database, data.Org{Name: "org3"})
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = store.FetchByKey(orgContext("org3"), database, "retry-me")
+		Expect(err).To(Equal(persistence.ErrNotFound))
+	})
+})
@@ -0,0 +1,22 @@
+package memory_test
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/myshkin5/effective-octo-garbanzo/persistence"
+)
+
+func TestMemory(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Persistence - Memory Suite")
+}
+
+var ctx = context.Background()
+
+func orgContext(org string) context.Context {
+	return context.WithValue(context.Background(), persistence.OrgContextKey, org)
+}
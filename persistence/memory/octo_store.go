@@ -0,0 +1,158 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/myshkin5/effective-octo-garbanzo/persistence"
+	"github.com/myshkin5/effective-octo-garbanzo/persistence/data"
+)
+
+type OctoStore struct{}
+
+func (OctoStore) FetchAll(ctx context.Context, database persistence.Database, page persistence.Page) ([]data.Octo, bool, error) {
+	if page.Sort.Field != "" {
+		return nil, false, persistence.ErrInvalidSort
+	}
+
+	var octos []data.Octo
+	var more bool
+	err := access(database, func(s *state) error {
+		i := s.orgIndex(org(ctx))
+		if i < 0 {
+			return nil
+		}
+		orgId := s.orgs[i].Id
+
+		var orgOctos []data.Octo
+		var keys []key
+		for _, octo := range s.octos {
+			if octo.OrgId == orgId {
+				orgOctos = append(orgOctos, octo.Octo)
+				keys = append(keys, key{id: octo.Id})
+			}
+		}
+
+		var indexes []int
+		indexes, more = paginate(keys, page)
+		for _, i := range indexes {
+			octos = append(octos, orgOctos[i])
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	return octos, more, nil
+}
+
+// FetchByName fetches the named octo. Transactions are serialized so
+// selectForUpdate has no effect.
+func (OctoStore) FetchByName(ctx context.Context, database persistence.Database, name string, selectForUpdate bool) (data.Octo, error) {
+	var octo data.Octo
+	err := access(database, func(s *state) error {
+		i := s.octoIndex(org(ctx), 0, name)
+		if i < 0 {
+			return persistence.ErrNotFound
+		}
+		octo = s.octos[i].Octo
+		return nil
+	})
+
+	return octo, err
+}
+
+// ForEachWithGarbanzos calls fn for each of the org's octos followed by its
+// garbanzos like the SQL store. fn is called outside of the store's lock on a
+// copy of the state so it may take its time.
+func (OctoStore) ForEachWithGarbanzos(ctx context.Context, database persistence.Database, fn func(octo data.Octo, garbanzo *data.Garbanzo) error) error {
+	var snapshot *state
+	err := access(database, func(s *state) error {
+		snapshot = s.clone()
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	i := snapshot.orgIndex(org(ctx))
+	if i < 0 {
+		return nil
+	}
+	orgId := snapshot.orgs[i].Id
+
+	for _, octo := range snapshot.octos {
+		if octo.OrgId != orgId {
+			continue
+		}
+		err = fn(octo.Octo, nil)
+		if err != nil {
+			return err
+		}
+		for _, garbanzo := range snapshot.garbanzos {
+			if garbanzo.OctoId != octo.Id {
+				continue
+			}
+			garbanzo.GarbanzoType, _ = snapshot.garbanzoType(garbanzo.GarbanzoType.Id)
+			err = fn(octo.Octo, &garbanzo)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (OctoStore) Create(ctx context.Context, database persistence.Database, octoIn data.Octo) (int, error) {
+	err := access(database, func(s *state) error {
+		i := s.orgIndex(org(ctx))
+		if i < 0 {
+			return persistence.ErrOrgNotFound
+		}
+		if s.octoIndex(org(ctx), 0, octoIn.Name) >= 0 {
+			return persistence.ErrDuplicate
+		}
+		octoIn.Id = s.nextId(octoSequence)
+		octoIn.Version = 1
+		s.octos = append(s.octos, octo{Octo: octoIn, OrgId: s.orgs[i].Id})
+		return nil
+	})
+
+	return octoIn.Id, err
+}
+
+func (OctoStore) Update(ctx context.Context, database persistence.Database, octo data.Octo) (int, error) {
+	var version int
+	err := access(database, func(s *state) error {
+		i := s.octoIndex(org(ctx), octo.Id, "")
+		if i < 0 || !matchesVersion(s.octos[i].Version, octo.Version) {
+			return notFoundOrMismatch(octo.Version)
+		}
+		if j := s.octoIndex(org(ctx), 0, octo.Name); j >= 0 && j != i {
+			return persistence.ErrDuplicate
+		}
+		s.octos[i].Name = octo.Name
+		s.octos[i].Version++
+		version = s.octos[i].Version
+		return nil
+	})
+
+	return version, err
+}
+
+func (OctoStore) DeleteById(ctx context.Context, database persistence.Database, id int, version int) error {
+	return access(database, func(s *state) error {
+		i := s.octoIndex(org(ctx), id, "")
+		if i < 0 || !matchesVersion(s.octos[i].Version, version) {
+			return notFoundOrMismatch(version)
+		}
+		for _, garbanzo := range s.garbanzos {
+			if garbanzo.OctoId == id {
+				return persistence.ErrInUse
+			}
+		}
+		s.octos = append(s.octos[:i], s.octos[i+1:]...)
+		return nil
+	})
+}
@@ -0,0 +1,165 @@
+package memory_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/satori/go.uuid"
+
+	"github.com/myshkin5/effective-octo-garbanzo/persistence"
+	"github.com/myshkin5/effective-octo-garbanzo/persistence/data"
+	"github.com/myshkin5/effective-octo-garbanzo/persistence/memory"
+)
+
+var _ = Describe("OctoStore", func() {
+	var (
+		database *memory.Database
+		store    memory.OctoStore
+		org1Ctx  context.Context
+		org2Ctx  context.Context
+		octoIds  []int
+	)
+
+	BeforeEach(func() {
+		database = memory.NewDatabase()
+		store = memory.OctoStore{}
+
+		for _, org := range []string{"org1", "org2"} {
+			_, err := memory.OrgStore{}.Create(ctx, database, data.Org{Name: org})
+			Expect(err).NotTo(HaveOccurred())
+		}
+		org1Ctx = orgContext("org1")
+		org2Ctx = orgContext("org2")
+
+		octoIds = nil
+		for _, name := range []string{"kraken", "cthulhu", "nessie"} {
+			id, err := store.Create(org1Ctx, database, data.Octo{Name: name})
+			Expect(err).NotTo(HaveOccurred())
+			octoIds = append(octoIds, id)
+		}
+	})
+
+	Describe("FetchAll", func() {
+		It("fetches the octos of the org a page at a time", func() {
+			octos, more, err := store.FetchAll(org1Ctx, database, persistence.Page{Limit: 2})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(more).To(BeTrue())
+			Expect(octos).To(Equal([]data.Octo{{Id: octoIds[0], Name: "kraken", Version: 1}, {Id: octoIds[1], Name: "cthulhu", Version: 1}}))
+
+			octos, more, err = store.FetchAll(org1Ctx, database, persistence.Page{AfterId: octoIds[1], Limit: 2})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(more).To(BeFalse())
+			Expect(octos).To(Equal([]data.Octo{{Id: octoIds[2], Name: "nessie", Version: 1}}))
+
+			octos, more, err = store.FetchAll(org1Ctx, database, persistence.Page{BeforeId: octoIds[2], Limit: 1})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(more).To(BeTrue())
+			Expect(octos).To(Equal([]data.Octo{{Id: octoIds[1], Name: "cthulhu", Version: 1}}))
+		})
+
+		It("does not fetch octos of another org", func() {
+			octos, more, err := store.FetchAll(org2Ctx, database, persistence.Page{Limit: 10})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(more).To(BeFalse())
+			Expect(octos).To(BeEmpty())
+		})
+
+		It("returns an error when sorting by a field", func() {
+			_, _, err := store.FetchAll(org1Ctx, database, persistence.Page{Limit: 10, Sort: persistence.Sort{Field: "Name"}})
+			Expect(err).To(Equal(persistence.ErrInvalidSort))
+		})
+	})
+
+	Describe("ForEachWithGarbanzos", func() {
+		It("calls fn for each octo of the org followed by its garbanzos", func() {
+			garbanzo := data.Garbanzo{APIUUID: uuid.NewV4(), GarbanzoType: data.GarbanzoType{Id: 1001}, OctoId: octoIds[1], DiameterMM: 4.2}
+			var err error
+			garbanzo.Id, err = memory.GarbanzoStore{}.Create(org1Ctx, database, garbanzo)
+			Expect(err).NotTo(HaveOccurred())
+			_, err = store.Create(org2Ctx, database, data.Octo{Name: "barry"})
+			Expect(err).NotTo(HaveOccurred())
+
+			var names []string
+			var garbanzos []data.Garbanzo
+			err = store.ForEachWithGarbanzos(org1Ctx, database, func(octo data.Octo, garbanzo *data.Garbanzo) error {
+				if garbanzo == nil {
+					names = append(names, octo.Name)
+				} else {
+					garbanzos = append(garbanzos, *garbanzo)
+				}
+				return nil
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(names).To(Equal([]string{"kraken", "cthulhu", "nessie"}))
+			garbanzo.GarbanzoType.Name = "DESI"
+			garbanzo.Version = 1
+			Expect(garbanzos).To(Equal([]data.Garbanzo{garbanzo}))
+		})
+	})
+
+	Describe("Create", func() {
+		It("returns a duplicate error when the name is taken in the org", func() {
+			_, err := store.Create(org1Ctx, database, data.Octo{Name: "kraken"})
+			Expect(err).To(Equal(persistence.ErrDuplicate))
+
+			_, err = store.Create(org2Ctx, database, data.Octo{Name: "kraken"})
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("returns org not found when the org hasn't been provisioned", func() {
+			_, err := store.Create(orgContext("org3"), database, data.Octo{Name: "kraken"})
+			Expect(err).To(Equal(persistence.ErrOrgNotFound))
+		})
+	})
+
+	Describe("Update", func() {
+		It("renames an octo", func() {
+			version, err := store.Update(org1Ctx, database, data.Octo{Id: octoIds[0], Name: "leviathan"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(version).To(Equal(2))
+
+			octo, err := store.FetchByName(org1Ctx, database, "leviathan", false)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(octo.Id).To(Equal(octoIds[0]))
+			Expect(octo.Version).To(Equal(2))
+		})
+
+		It("returns a version mismatch when the octo has been modified", func() {
+			_, err := store.Update(org1Ctx, database, data.Octo{Id: octoIds[0], Name: "leviathan", Version: 2})
+			Expect(err).To(Equal(persistence.ErrVersionMismatch))
+
+			_, err = store.FetchByName(org1Ctx, database, "kraken", false)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("returns a duplicate error when the name is taken", func() {
+			_, err := store.Update(org1Ctx, database, data.Octo{Id: octoIds[0], Name: "nessie"})
+			Expect(err).To(Equal(persistence.ErrDuplicate))
+		})
+
+		It("does not update octos of another org", func() {
+			_, err := store.Update(org2Ctx, database, data.Octo{Id: octoIds[0], Name: "leviathan"})
+			Expect(err).To(Equal(persistence.ErrNotFound))
+		})
+	})
+
+	Describe("DeleteById", func() {
+		It("deletes an octo", func() {
+			Expect(store.DeleteById(org1Ctx, database, octoIds[0], 0)).To(Succeed())
+
+			_, err := store.FetchByName(org1Ctx, database, "kraken", false)
+			Expect(err).To(Equal(persistence.ErrNotFound))
+		})
+
+		It("only deletes an octo still at the expected version", func() {
+			Expect(store.DeleteById(org1Ctx, database, octoIds[0], 2)).To(Equal(persistence.ErrVersionMismatch))
+
+			Expect(store.DeleteById(org1Ctx, database, octoIds[0], 1)).To(Succeed())
+		})
+
+		It("does not delete octos of another org", func() {
+			Expect(store.DeleteById(org2Ctx, database, octoIds[0], 0)).To(Equal(persistence.ErrNotFound))
+		})
+	})
+})
@@ -0,0 +1,101 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/myshkin5/effective-octo-garbanzo/persistence"
+	"github.com/myshkin5/effective-octo-garbanzo/persistence/data"
+)
+
+// OrgStore manages the orgs themselves so unlike the other stores, it is not
+// scoped by the org of the context.
+type OrgStore struct{}
+
+func (OrgStore) FetchAll(ctx context.Context, database persistence.Database) ([]data.Org, error) {
+	var orgs []data.Org
+	err := access(database, func(s *state) error {
+		orgs = append(orgs, s.orgs...)
+		return nil
+	})
+
+	return orgs, err
+}
+
+// FetchByName fetches the named org. Transactions are serialized so
+// selectForUpdate has no effect.
+func (OrgStore) FetchByName(ctx context.Context, database persistence.Database, name string, selectForUpdate bool) (data.Org, error) {
+	var org data.Org
+	err := access(database, func(s *state) error {
+		i := s.orgIndex(name)
+		if i < 0 {
+			return persistence.ErrNotFound
+		}
+		org = s.orgs[i]
+		return nil
+	})
+
+	return org, err
+}
+
+func (OrgStore) Create(ctx context.Context, database persistence.Database, org data.Org) (int, error) {
+	err := access(database, func(s *state) error {
+		if s.orgIndex(org.Name) >= 0 {
+			return persistence.ErrDuplicate
+		}
+		org.Id = s.nextId(orgSequence)
+		s.orgs = append(s.orgs, org)
+		return nil
+	})
+
+	return org.Id, err
+}
+
+func (OrgStore) Provision(ctx context.Context, database persistence.Database, name string) error {
+	return access(database, func(s *state) error {
+		if s.orgIndex(name) < 0 {
+			s.orgs = append(s.orgs, data.Org{Id: s.nextId(orgSequence), Name: name})
+		}
+		return nil
+	})
+}
+
+func (OrgStore) DeleteById(ctx context.Context, database persistence.Database, id int) error {
+	return access(database, func(s *state) error {
+		for i, org := range s.orgs {
+			if org.Id != id {
+				continue
+			}
+			for _, octo := range s.octos {
+				if octo.OrgId == id {
+					return persistence.ErrInUse
+				}
+			}
+			s.orgs = append(s.orgs[:i], s.orgs[i+1:]...)
+			// Like the cascading foreign keys of the api_key, idempotent_request
+			// and audit_event tables
+			var apiKeys []apiKey
+			for _, stored := range s.apiKeys {
+				if stored.OrgId != id {
+					apiKeys = append(apiKeys, stored)
+				}
+			}
+			s.apiKeys = apiKeys
+			var requests []idempotentRequest
+			for _, stored := range s.idempotentRequests {
+				if stored.OrgId != id {
+					requests = append(requests, stored)
+				}
+			}
+			s.idempotentRequests = requests
+			var events []auditEvent
+			for _, stored := range s.auditEvents {
+				if stored.OrgId != id {
+					events = append(events, stored)
+				}
+			}
+			s.auditEvents = events
+			return nil
+		}
+		return persistence.ErrNotFound
+	})
+}
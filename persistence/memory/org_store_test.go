@@ -0,0 +1,76 @@
+package memory_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/myshkin5/effective-octo-garbanzo/persistence"
+	"github.com/myshkin5/effective-octo-garbanzo/persistence/data"
+	"github.com/myshkin5/effective-octo-garbanzo/persistence/memory"
+)
+
+var _ = Describe("OrgStore", func() {
+	var (
+		database *memory.Database
+		store    memory.OrgStore
+	)
+
+	BeforeEach(func() {
+		database = memory.NewDatabase()
+		store = memory.OrgStore{}
+	})
+
+	It("creates and fetches orgs", func() {
+		id1, err := store.Create(ctx, database, data.Org{Name: "org1"})
+		Expect(err).NotTo(HaveOccurred())
+		id2, err := store.Create(ctx, database, data.Org{Name: "org2"})
+		Expect(err).NotTo(HaveOccurred())
+
+		orgs, err := store.FetchAll(ctx, database)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(orgs).To(Equal([]data.Org{{Id: id1, Name: "org1"}, {Id: id2, Name: "org2"}}))
+
+		org, err := store.FetchByName(ctx, database, "org2", false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(org).To(Equal(data.Org{Id: id2, Name: "org2"}))
+	})
+
+	It("returns not found when fetching an unknown org", func() {
+		_, err := store.FetchByName(ctx, database, "org1", false)
+		Expect(err).To(Equal(persistence.ErrNotFound))
+	})
+
+	It("returns a duplicate error when the name is already taken", func() {
+		_, err := store.Create(ctx, database, data.Org{Name: "org1"})
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = store.Create(ctx, database, data.Org{Name: "org1"})
+		Expect(err).To(Equal(persistence.ErrDuplicate))
+	})
+
+	It("provisions an org only once", func() {
+		Expect(store.Provision(ctx, database, "org1")).To(Succeed())
+		Expect(store.Provision(ctx, database, "org1")).To(Succeed())
+
+		orgs, err := store.FetchAll(ctx, database)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(orgs).To(HaveLen(1))
+	})
+
+	It("deletes an org", func() {
+		id, err := store.Create(ctx, database, data.Org{Name: "org1"})
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(store.DeleteById(ctx, database, id)).To(Succeed())
+		Expect(store.DeleteById(ctx, database, id)).To(Equal(persistence.ErrNotFound))
+	})
+
+	It("returns in use when the org still has octos", func() {
+		id, err := store.Create(ctx, database, data.Org{Name: "org1"})
+		Expect(err).NotTo(HaveOccurred())
+		_, err = memory.OctoStore{}.Create(orgContext("org1"), database, data.Octo{Name: "kraken"})
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(store.DeleteById(ctx, database, id)).To(Equal(persistence.ErrInUse))
+	})
+})
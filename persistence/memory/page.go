@@ -0,0 +1,61 @@
+package memory
+
+import (
+	"sort"
+
+	"github.com/myshkin5/effective-octo-garbanzo/persistence"
+)
+
+// key identifies a row for keyset pagination by its sort value (zero when not
+// sorting by a field) and then by its id.
+type key struct {
+	sortValue float64
+	id        int
+}
+
+func (k key) less(other key) bool {
+	if k.sortValue != other.sortValue {
+		return k.sortValue < other.sortValue
+	}
+
+	return k.id < other.id
+}
+
+// paginate returns the indexes of the keys on the page in page order and if
+// there are more rows beyond the page in the direction it was fetched. It
+// matches the keyset pagination of the Postgres stores.
+func paginate(keys []key, page persistence.Page) ([]int, bool) {
+	ascending := page.Backward() == page.Sort.Descending
+	boundary := key{sortValue: page.SortValue, id: page.AfterId}
+	if page.Backward() {
+		boundary.id = page.BeforeId
+	}
+	if page.Sort.Field == "" {
+		boundary.sortValue = 0
+	}
+
+	var indexes []int
+	for i, k := range keys {
+		if boundary.id == 0 || (ascending && boundary.less(k)) || (!ascending && k.less(boundary)) {
+			indexes = append(indexes, i)
+		}
+	}
+	sort.Slice(indexes, func(i, j int) bool {
+		if ascending {
+			return keys[indexes[i]].less(keys[indexes[j]])
+		}
+		return keys[indexes[j]].less(keys[indexes[i]])
+	})
+
+	more := len(indexes) > page.Limit
+	if more {
+		indexes = indexes[:page.Limit]
+	}
+	if page.Backward() {
+		for i, j := 0, len(indexes)-1; i < j; i, j = i+1, j-1 {
+			indexes[i], indexes[j] = indexes[j], indexes[i]
+		}
+	}
+
+	return indexes, more
+}
@@ -0,0 +1,174 @@
+package memory
+
+import (
+	"github.com/satori/go.uuid"
+
+	"github.com/myshkin5/effective-octo-garbanzo/persistence"
+	"github.com/myshkin5/effective-octo-garbanzo/persistence/data"
+)
+
+const (
+	orgSequence          = "org"
+	octoSequence         = "octo"
+	garbanzoTypeSequence = "garbanzo_type"
+	garbanzoSequence     = "garbanzo"
+	apiKeySequence       = "api_key"
+	auditEventSequence   = "audit_event"
+)
+
+// state holds the rows of every table. Garbanzos only reference their type
+// by id like the garbanzo table does.
+type state struct {
+	orgs               []data.Org
+	octos              []octo
+	garbanzoTypes      []data.GarbanzoType
+	garbanzos          []data.Garbanzo
+	apiKeys            []apiKey
+	idempotentRequests []idempotentRequest
+	auditEvents        []auditEvent
+	// Like Postgres sequences, lastIds aren't part of transactions
+	lastIds map[string]int
+}
+
+type octo struct {
+	data.Octo
+	OrgId int
+}
+
+type apiKey struct {
+	data.APIKey
+	OrgId int
+}
+
+type idempotentRequest struct {
+	data.IdempotentRequest
+	OrgId int
+}
+
+type auditEvent struct {
+	data.AuditEvent
+	OrgId int
+}
+
+func (s *state) clone() *state {
+	c := &state{
+		orgs:               append([]data.Org(nil), s.orgs...),
+		octos:              append([]octo(nil), s.octos...),
+		garbanzoTypes:      append([]data.GarbanzoType(nil), s.garbanzoTypes...),
+		garbanzos:          append([]data.Garbanzo(nil), s.garbanzos...),
+		apiKeys:            append([]apiKey(nil), s.apiKeys...),
+		idempotentRequests: append([]idempotentRequest(nil), s.idempotentRequests...),
+		auditEvents:        append([]auditEvent(nil), s.auditEvents...),
+		// Shared so ids aren't reused when a transaction is rolled back
+		lastIds: s.lastIds,
+	}
+
+	return c
+}
+
+// nextId returns the next id of a sequence.
+func (s *state) nextId(sequence string) int {
+	s.lastIds[sequence]++
+	return s.lastIds[sequence]
+}
+
+func (s *state) orgIndex(name string) int {
+	for i, org := range s.orgs {
+		if org.Name == name {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// octoIndex returns the index of the octo matching id (when non-zero) and
+// name (when non-empty) in the named org.
+func (s *state) octoIndex(orgName string, id int, name string) int {
+	i := s.orgIndex(orgName)
+	if i < 0 {
+		return -1
+	}
+	orgId := s.orgs[i].Id
+
+	for i, octo := range s.octos {
+		if octo.OrgId == orgId && (id == 0 || octo.Id == id) && (name == "" || octo.Name == name) {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func (s *state) garbanzoType(id int) (data.GarbanzoType, bool) {
+	for _, garbanzoType := range s.garbanzoTypes {
+		if garbanzoType.Id == id {
+			return garbanzoType, true
+		}
+	}
+
+	return data.GarbanzoType{}, false
+}
+
+// garbanzoIndex returns the index of the garbanzo with the API UUID in the
+// named octo of the named org.
+func (s *state) garbanzoIndex(orgName string, apiUUID uuid.UUID, octoName string) int {
+	i := s.octoIndex(orgName, 0, octoName)
+	if i < 0 {
+		return -1
+	}
+	octoId := s.octos[i].Id
+
+	for i, garbanzo := range s.garbanzos {
+		if garbanzo.OctoId == octoId && garbanzo.APIUUID == apiUUID {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// apiKeyIndex returns the index of the first API key matching match.
+func (s *state) apiKeyIndex(match func(apiKey data.APIKey) bool) int {
+	for i, stored := range s.apiKeys {
+		if match(stored.APIKey) {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// idempotentRequestIndex returns the index of the request with key in the named
+// org.
+func (s *state) idempotentRequestIndex(orgName string, key string) int {
+	i := s.orgIndex(orgName)
+	if i < 0 {
+		return -1
+	}
+	orgId := s.orgs[i].Id
+
+	for i, stored := range s.idempotentRequests {
+		if stored.OrgId == orgId && stored.Key == key {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// matchesVersion reports if a row at version satisfies a versioned update or
+// delete. An expected version of 0 matches any version.
+func matchesVersion(version, expectedVersion int) bool {
+	return expectedVersion == 0 || version == expectedVersion
+}
+
+// notFoundOrMismatch mirrors the SQL stores' error when a versioned update or
+// delete finds no row.
+func notFoundOrMismatch(expectedVersion int) error {
+	if expectedVersion != 0 {
+		return persistence.ErrVersionMismatch
+	}
+
+	return persistence.ErrNotFound
+}
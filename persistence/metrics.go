@@ -0,0 +1,38 @@
+package persistence
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+
+	"github.com/myshkin5/effective-octo-garbanzo/metrics"
+)
+
+// NewStatsCollector returns a collector of the connection pool statistics of
+// a database returned by Open().
+func NewStatsCollector(db Database) prometheus.Collector {
+	return collectors.NewDBStatsCollector(db.(*database).internalDB.(*sql.DB), "garbanzo")
+}
+
+// observeQuery starts timing the named store query. Defer calling the returned
+// func to record the duration:
+//
+//	defer observeQuery("OctoStore.FetchAll")()
+func observeQuery(name string) func() {
+	start := time.Now()
+	return func() {
+		metrics.QueryDuration.WithLabelValues(name).Observe(time.Since(start).Seconds())
+	}
+}
+
+func countTransaction(outcome string, err error) error {
+	status := "ok"
+	if err != nil {
+		status = "error"
+	}
+	metrics.Transactions.WithLabelValues(outcome, status).Inc()
+
+	return err
+}
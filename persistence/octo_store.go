@@ -4,21 +4,30 @@ import (
 	"context"
 	"database/sql"
 
+	"github.com/satori/go.uuid"
+
 	"github.com/myshkin5/effective-octo-garbanzo/logs"
 	"github.com/myshkin5/effective-octo-garbanzo/persistence/data"
 )
 
 type OctoStore struct{}
 
-func (OctoStore) FetchAll(ctx context.Context, database Database) ([]data.Octo, error) {
-	query := `select o.id, o.name from octo o
+func (OctoStore) FetchAll(ctx context.Context, database Database, page Page) ([]data.Octo, bool, error) {
+	defer observeQuery("OctoStore.FetchAll")()
+
+	params := params{org(ctx)}
+	condition, orderBy, err := page.keyset("o.id", nil, &params)
+	if err != nil {
+		return nil, false, err
+	}
+	query := `select o.id, o.name, o.version from octo o
 		join org on o.org_id = org.id
-		where org.name = $1
-		order by o.id`
+		where org.name = $1 and ` + condition + `
+		` + orderBy
 
-	rows, err := database.Query(ctx, query, org(ctx))
+	rows, err := database.Query(ctx, query, params...)
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
 	defer rows.Close()
 
@@ -26,23 +35,35 @@ func (OctoStore) FetchAll(ctx context.Context, database Database) ([]data.Octo,
 	for rows.Next() {
 		var id int
 		var name string
-		err = rows.Scan(&id, &name)
+		var version int
+		err = rows.Scan(&id, &name, &version)
 		if err != nil {
-			return nil, err
+			return nil, false, err
 		}
 
 		octo := data.Octo{
-			Id:   id,
-			Name: name,
+			Id:      id,
+			Name:    name,
+			Version: version,
 		}
 		octos = append(octos, octo)
 	}
 
-	return octos, nil
+	count, more := page.trim(len(octos))
+	octos = octos[:count]
+	if page.Backward() {
+		for i, j := 0, len(octos)-1; i < j; i, j = i+1, j-1 {
+			octos[i], octos[j] = octos[j], octos[i]
+		}
+	}
+
+	return octos, more, nil
 }
 
 func (OctoStore) FetchByName(ctx context.Context, database Database, name string, selectForUpdate bool) (data.Octo, error) {
-	query := `select o.id from octo o
+	defer observeQuery("OctoStore.FetchByName")()
+
+	query := `select o.id, o.version from octo o
 		join org on o.org_id = org.id
 		where o.name = $1 and org.name = $2`
 	if selectForUpdate {
@@ -50,7 +71,8 @@ func (OctoStore) FetchByName(ctx context.Context, database Database, name string
 	}
 
 	var id int
-	err := database.QueryRow(ctx, query, name, org(ctx)).Scan(&id)
+	var version int
+	err := database.QueryRow(ctx, query, name, org(ctx)).Scan(&id, &version)
 	if err == sql.ErrNoRows {
 		return data.Octo{}, ErrNotFound
 	} else if err != nil {
@@ -58,27 +80,121 @@ func (OctoStore) FetchByName(ctx context.Context, database Database, name string
 	}
 
 	return data.Octo{
-		Id:   id,
-		Name: name,
+		Id:      id,
+		Name:    name,
+		Version: version,
 	}, nil
 }
 
+// ForEachWithGarbanzos streams the org's octos, each followed by its
+// garbanzos, to fn from a single query rather than fetching them all into
+// memory. fn is first called for each octo with a nil garbanzo.
+func (OctoStore) ForEachWithGarbanzos(ctx context.Context, database Database, fn func(octo data.Octo, garbanzo *data.Garbanzo) error) error {
+	defer observeQuery("OctoStore.ForEachWithGarbanzos")()
+
+	query := `select o.id, o.name, o.version, g.id, g.api_uuid, gt.id, gt.name, g.diameter_mm, g.version from octo o
+		join org on o.org_id = org.id
+		left join garbanzo g on g.octo_id = o.id
+		left join garbanzo_type gt on g.garbanzo_type_id = gt.id
+		where org.name = $1
+		order by o.id, g.id`
+
+	rows, err := database.Query(ctx, query, org(ctx))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var octo data.Octo
+	for rows.Next() {
+		var id int
+		var name string
+		var version int
+		var garbanzoId, garbanzoTypeId, garbanzoVersion sql.NullInt64
+		var apiUUID, garbanzoTypeName sql.NullString
+		var diameterMM sql.NullFloat64
+		err = rows.Scan(&id, &name, &version, &garbanzoId, &apiUUID, &garbanzoTypeId, &garbanzoTypeName, &diameterMM, &garbanzoVersion)
+		if err != nil {
+			return err
+		}
+
+		if id != octo.Id {
+			octo = data.Octo{
+				Id:      id,
+				Name:    name,
+				Version: version,
+			}
+			err = fn(octo, nil)
+			if err != nil {
+				return err
+			}
+		}
+
+		// The outer join returns a row of nulls for an octo without garbanzos
+		if !garbanzoId.Valid {
+			continue
+		}
+		garbanzo := data.Garbanzo{
+			Id: int(garbanzoId.Int64),
+			GarbanzoType: data.GarbanzoType{
+				Id:   int(garbanzoTypeId.Int64),
+				Name: garbanzoTypeName.String,
+			},
+			OctoId:     id,
+			DiameterMM: float32(diameterMM.Float64),
+			Version:    int(garbanzoVersion.Int64),
+		}
+		garbanzo.APIUUID, err = uuid.FromString(apiUUID.String)
+		if err != nil {
+			return err
+		}
+		err = fn(octo, &garbanzo)
+		if err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
 func (OctoStore) Create(ctx context.Context, database Database, octo data.Octo) (int, error) {
-	query := "insert into octo (name, org_id) values ($1, (select id from org where name = $2)) returning id"
-	return ExecInsert(ctx, database, query, octo.Name, org(ctx))
+	defer observeQuery("OctoStore.Create")()
+
+	query := "insert into octo (name, org_id) select $1, id from org where name = $2 returning id"
+	id, err := ExecInsert(ctx, database, query, octo.Name, org(ctx))
+	if err == sql.ErrNoRows {
+		return 0, ErrOrgNotFound
+	}
+
+	return id, err
 }
 
-func (OctoStore) DeleteById(ctx context.Context, database Database, id int) error {
-	query := "delete from octo where id = $1 and org_id = (select id from org where name = $2)"
-	rowsAffected, err := ExecDelete(ctx, database, query, id, org(ctx))
+// Update renames the octo and returns its new version. Unless octo.Version is
+// 0, the octo is only updated while it is still at that version.
+func (OctoStore) Update(ctx context.Context, database Database, octo data.Octo) (int, error) {
+	defer observeQuery("OctoStore.Update")()
+
+	query := `update octo set name = $1, version = version + 1
+		where id = $2 and ($3 = 0 or version = $3) and org_id = (select id from org where name = $4)
+		returning version`
+	return ExecVersionedUpdate(ctx, database, octo.Version, query, octo.Name, octo.Id, octo.Version, org(ctx))
+}
+
+// DeleteById deletes the octo. Unless version is 0, the octo is only deleted
+// while it is still at that version.
+func (OctoStore) DeleteById(ctx context.Context, database Database, id int, version int) error {
+	defer observeQuery("OctoStore.DeleteById")()
+
+	query := "delete from octo where id = $1 and ($2 = 0 or version = $2) and org_id = (select id from org where name = $3)"
+	rowsAffected, err := ExecDelete(ctx, database, query, id, version, org(ctx))
 	if err != nil {
 		return err
 	}
 
 	if rowsAffected == 0 {
-		return ErrNotFound
+		return notFoundOrMismatch(version)
 	} else if rowsAffected > 1 {
-		logs.Logger.Panic("Deleted multiple rows when expecting only one")
+		logs.FromContext(ctx).Panic("Deleted multiple rows when expecting only one")
 	}
 
 	return nil
@@ -2,9 +2,11 @@ package persistence_test
 
 import (
 	"context"
+	"errors"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"github.com/satori/go.uuid"
 
 	"github.com/myshkin5/effective-octo-garbanzo/persistence"
 	"github.com/myshkin5/effective-octo-garbanzo/persistence/data"
@@ -35,7 +37,7 @@ var _ = Describe("OctoStore Integration", func() {
 
 	Describe("FetchAll", func() {
 		It("fetches no octos when there are none", func() {
-			octos, err := store.FetchAll(org1Ctx, database)
+			octos, _, err := store.FetchAll(org1Ctx, database, persistence.Page{Limit: 10})
 			Expect(err).NotTo(HaveOccurred())
 
 			Expect(octos).To(HaveLen(0))
@@ -60,7 +62,7 @@ var _ = Describe("OctoStore Integration", func() {
 			_, err = store.Create(org2Ctx, database, org2Octo1)
 			Expect(err).NotTo(HaveOccurred())
 
-			octos, err := store.FetchAll(org1Ctx, database)
+			octos, _, err := store.FetchAll(org1Ctx, database, persistence.Page{Limit: 10})
 			Expect(err).NotTo(HaveOccurred())
 
 			Expect(octos).To(HaveLen(2))
@@ -71,6 +73,41 @@ var _ = Describe("OctoStore Integration", func() {
 			Expect(octos[1].Id).To(Equal(org1Octo2Id))
 			Expect(octos[1].Name).To(Equal("cthulhu"))
 		})
+
+		It("fetches the octos a page at a time", func() {
+			var ids []int
+			for _, name := range []string{"kraken", "cthulhu", "barry"} {
+				id, err := store.Create(org1Ctx, database, data.Octo{Name: name})
+				Expect(err).NotTo(HaveOccurred())
+				ids = append(ids, id)
+			}
+
+			octos, more, err := store.FetchAll(org1Ctx, database, persistence.Page{Limit: 2})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(more).To(BeTrue())
+			Expect(octos).To(HaveLen(2))
+			Expect(octos[0].Id).To(Equal(ids[0]))
+			Expect(octos[1].Id).To(Equal(ids[1]))
+
+			octos, more, err = store.FetchAll(org1Ctx, database, persistence.Page{AfterId: ids[1], Limit: 2})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(more).To(BeFalse())
+			Expect(octos).To(HaveLen(1))
+			Expect(octos[0].Id).To(Equal(ids[2]))
+
+			octos, more, err = store.FetchAll(org1Ctx, database, persistence.Page{BeforeId: ids[2], Limit: 1})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(more).To(BeTrue())
+			Expect(octos).To(HaveLen(1))
+			Expect(octos[0].Id).To(Equal(ids[1]))
+
+			octos, more, err = store.FetchAll(org1Ctx, database, persistence.Page{BeforeId: ids[2], Limit: 2})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(more).To(BeFalse())
+			Expect(octos).To(HaveLen(2))
+			Expect(octos[0].Id).To(Equal(ids[0]))
+			Expect(octos[1].Id).To(Equal(ids[1]))
+		})
 	})
 
 	Describe("FetchByName", func() {
@@ -93,6 +130,7 @@ var _ = Describe("OctoStore Integration", func() {
 				Expect(err).NotTo(HaveOccurred())
 				Expect(fetchedOcto.Id).To(Equal(octoId))
 				Expect(fetchedOcto.Name).To(Equal("kraken"))
+				Expect(fetchedOcto.Version).To(Equal(1))
 			})
 
 			It("does not find octos for another org", func() {
@@ -123,14 +161,16 @@ var _ = Describe("OctoStore Integration", func() {
 				_, err = store.FetchByName(org1Ctx, tx1, "kraken", true)
 				Expect(err).NotTo(HaveOccurred())
 
-				tx2, err := database.BeginTx(ctx)
-				Expect(err).NotTo(HaveOccurred())
-
+				var tx2 persistence.Database
 				done := make(chan struct{}, 0)
 				// NB: 0
 				go func() {
 					// NB: 1 -- everything written to between 1s and 2s must be unique to avoid data race errors
-					_, err2 := persistence.OctoStore{}.FetchByName(org1Ctx, tx2, "kraken", true)
+					// SQLite locks the whole database when the transaction begins rather than on select
+					var err2 error
+					tx2, err2 = database.BeginTx(ctx)
+					Expect(err2).NotTo(HaveOccurred())
+					_, err2 = persistence.OctoStore{}.FetchByName(org1Ctx, tx2, "kraken", true)
 					Expect(err2).NotTo(HaveOccurred())
 					close(done)
 					// NB: 2
@@ -151,6 +191,64 @@ var _ = Describe("OctoStore Integration", func() {
 		})
 	})
 
+	Describe("ForEachWithGarbanzos", func() {
+		type record struct {
+			octo     data.Octo
+			garbanzo *data.Garbanzo
+		}
+
+		It("streams each octo of the org followed by its garbanzos", func() {
+			krakenId, err := store.Create(org1Ctx, database, data.Octo{Name: "kraken"})
+			Expect(err).NotTo(HaveOccurred())
+			cthulhuId, err := store.Create(org1Ctx, database, data.Octo{Name: "cthulhu"})
+			Expect(err).NotTo(HaveOccurred())
+			_, err = store.Create(org2Ctx, database, data.Octo{Name: "barry"})
+			Expect(err).NotTo(HaveOccurred())
+
+			garbanzo := data.Garbanzo{
+				APIUUID:      uuid.NewV4(),
+				GarbanzoType: kabuli,
+				OctoId:       krakenId,
+				DiameterMM:   6.4,
+			}
+			garbanzo.Id, err = persistence.GarbanzoStore{}.Create(org1Ctx, database, garbanzo)
+			Expect(err).NotTo(HaveOccurred())
+			garbanzo.Version = 1
+
+			var records []record
+			err = store.ForEachWithGarbanzos(org1Ctx, database, func(octo data.Octo, garbanzo *data.Garbanzo) error {
+				records = append(records, record{octo: octo, garbanzo: garbanzo})
+				return nil
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			kraken := data.Octo{Id: krakenId, Name: "kraken", Version: 1}
+			Expect(records).To(HaveLen(3))
+			Expect(records[0]).To(Equal(record{octo: kraken}))
+			Expect(records[1].octo).To(Equal(kraken))
+			Expect(records[1].garbanzo).NotTo(BeNil())
+			Expect(records[1].garbanzo.DiameterMM).To(BeNumerically("~", 6.4, 0.000001))
+			records[1].garbanzo.DiameterMM = garbanzo.DiameterMM
+			Expect(*records[1].garbanzo).To(Equal(garbanzo))
+			Expect(records[2]).To(Equal(record{octo: data.Octo{Id: cthulhuId, Name: "cthulhu", Version: 1}}))
+		})
+
+		It("stops at the first error", func() {
+			_, err := store.Create(org1Ctx, database, data.Octo{Name: "kraken"})
+			Expect(err).NotTo(HaveOccurred())
+			_, err = store.Create(org1Ctx, database, data.Octo{Name: "cthulhu"})
+			Expect(err).NotTo(HaveOccurred())
+
+			calls := 0
+			err = store.ForEachWithGarbanzos(org1Ctx, database, func(data.Octo, *data.Garbanzo) error {
+				calls++
+				return errors.New("don't bother")
+			})
+			Expect(err).To(MatchError("don't bother"))
+			Expect(calls).To(Equal(1))
+		})
+	})
+
 	Describe("Create", func() {
 		It("creates a new octo", func() {
 			octo := data.Octo{
@@ -178,6 +276,27 @@ var _ = Describe("OctoStore Integration", func() {
 			Expect(octoId).NotTo(Equal(ignoredId))
 		})
 
+		It("returns a duplicate error when the name is already taken", func() {
+			octo := data.Octo{
+				Name: "kraken",
+			}
+
+			_, err := store.Create(org1Ctx, database, octo)
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = store.Create(org1Ctx, database, octo)
+			Expect(err).To(Equal(persistence.ErrDuplicate))
+		})
+
+		It("returns org not found when the org has not been created", func() {
+			unknownOrgCtx := context.WithValue(ctx, persistence.OrgContextKey, "int_test_org_unknown")
+
+			_, err := store.Create(unknownOrgCtx, database, data.Octo{
+				Name: "kraken",
+			})
+			Expect(err).To(Equal(persistence.ErrOrgNotFound))
+		})
+
 		It("allows octos with the same name in different orgs", func() {
 			octo1 := data.Octo{
 				Name: "kraken",
@@ -195,9 +314,112 @@ var _ = Describe("OctoStore Integration", func() {
 		})
 	})
 
+	Describe("Update", func() {
+		It("returns not found when updating an unknown octo", func() {
+			_, err := store.Update(org1Ctx, database, data.Octo{
+				Id:   82333455,
+				Name: "kraken",
+			})
+
+			Expect(err).To(Equal(persistence.ErrNotFound))
+		})
+
+		It("renames an octo", func() {
+			id, err := store.Create(org1Ctx, database, data.Octo{
+				Name: "kraken",
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			version, err := store.Update(org1Ctx, database, data.Octo{
+				Id:   id,
+				Name: "cthulhu",
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(version).To(Equal(2))
+
+			_, err = store.FetchByName(org1Ctx, database, "kraken", false)
+			Expect(err).To(Equal(persistence.ErrNotFound))
+
+			fetchedOcto, err := store.FetchByName(org1Ctx, database, "cthulhu", false)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fetchedOcto.Id).To(Equal(id))
+			Expect(fetchedOcto.Version).To(Equal(2))
+		})
+
+		It("renames an octo still at the expected version", func() {
+			id, err := store.Create(org1Ctx, database, data.Octo{
+				Name: "kraken",
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			version, err := store.Update(org1Ctx, database, data.Octo{
+				Id:      id,
+				Name:    "cthulhu",
+				Version: 1,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(version).To(Equal(2))
+		})
+
+		It("returns a version mismatch when the octo has been modified", func() {
+			id, err := store.Create(org1Ctx, database, data.Octo{
+				Name: "kraken",
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = store.Update(org1Ctx, database, data.Octo{
+				Id:   id,
+				Name: "cthulhu",
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = store.Update(org1Ctx, database, data.Octo{
+				Id:      id,
+				Name:    "barry",
+				Version: 1,
+			})
+			Expect(err).To(Equal(persistence.ErrVersionMismatch))
+
+			fetchedOcto, err := store.FetchByName(org1Ctx, database, "cthulhu", false)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fetchedOcto.Version).To(Equal(2))
+		})
+
+		It("returns a duplicate error when the new name is already taken", func() {
+			id, err := store.Create(org1Ctx, database, data.Octo{
+				Name: "kraken",
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = store.Create(org1Ctx, database, data.Octo{
+				Name: "cthulhu",
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = store.Update(org1Ctx, database, data.Octo{
+				Id:   id,
+				Name: "cthulhu",
+			})
+			Expect(err).To(Equal(persistence.ErrDuplicate))
+		})
+
+		It("returns not found when updating an octo for another org", func() {
+			id, err := store.Create(org1Ctx, database, data.Octo{
+				Name: "kraken",
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = store.Update(org2Ctx, database, data.Octo{
+				Id:   id,
+				Name: "cthulhu",
+			})
+			Expect(err).To(Equal(persistence.ErrNotFound))
+		})
+	})
+
 	Describe("DeleteById", func() {
 		It("returns not found when deleting an unknown octo", func() {
-			err := store.DeleteById(org1Ctx, database, 82333455)
+			err := store.DeleteById(org1Ctx, database, 82333455, 0)
 
 			Expect(err).To(Equal(persistence.ErrNotFound))
 		})
@@ -210,12 +432,37 @@ var _ = Describe("OctoStore Integration", func() {
 			id, err := store.Create(org1Ctx, database, octo)
 			Expect(err).NotTo(HaveOccurred())
 
-			Expect(store.DeleteById(org1Ctx, database, id)).To(Succeed())
+			Expect(store.DeleteById(org1Ctx, database, id, 0)).To(Succeed())
 
-			err = store.DeleteById(org1Ctx, database, id)
+			err = store.DeleteById(org1Ctx, database, id, 0)
 			Expect(err).To(Equal(persistence.ErrNotFound))
 		})
 
+		It("deletes an octo still at the expected version", func() {
+			id, err := store.Create(org1Ctx, database, data.Octo{
+				Name: "kraken",
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(store.DeleteById(org1Ctx, database, id, 1)).To(Succeed())
+
+			_, err = store.FetchByName(org1Ctx, database, "kraken", false)
+			Expect(err).To(Equal(persistence.ErrNotFound))
+		})
+
+		It("returns a version mismatch when the octo has been modified", func() {
+			id, err := store.Create(org1Ctx, database, data.Octo{
+				Name: "kraken",
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			err = store.DeleteById(org1Ctx, database, id, 2)
+			Expect(err).To(Equal(persistence.ErrVersionMismatch))
+
+			_, err = store.FetchByName(org1Ctx, database, "kraken", false)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
 		It("returns not found when deleting an octo for another org", func() {
 			octo := data.Octo{
 				Name: "kraken",
@@ -224,7 +471,7 @@ var _ = Describe("OctoStore Integration", func() {
 			id, err := store.Create(org1Ctx, database, octo)
 			Expect(err).NotTo(HaveOccurred())
 
-			err = store.DeleteById(org2Ctx, database, id)
+			err = store.DeleteById(org2Ctx, database, id, 0)
 
 			Expect(err).To(Equal(persistence.ErrNotFound))
 		})
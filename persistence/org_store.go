@@ -0,0 +1,103 @@
+package persistence
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/myshkin5/effective-octo-garbanzo/logs"
+	"github.com/myshkin5/effective-octo-garbanzo/persistence/data"
+)
+
+// OrgStore manages the orgs themselves so unlike the other stores, it is not
+// scoped by the org of the context.
+type OrgStore struct{}
+
+func (OrgStore) FetchAll(ctx context.Context, database Database) ([]data.Org, error) {
+	defer observeQuery("OrgStore.FetchAll")()
+
+	query := "select id, name from org order by id"
+
+	rows, err := database.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var orgs []data.Org
+	for rows.Next() {
+		var id int
+		var name string
+		err = rows.Scan(&id, &name)
+		if err != nil {
+			return nil, err
+		}
+
+		org := data.Org{
+			Id:   id,
+			Name: name,
+		}
+		orgs = append(orgs, org)
+	}
+
+	return orgs, nil
+}
+
+func (OrgStore) FetchByName(ctx context.Context, database Database, name string, selectForUpdate bool) (data.Org, error) {
+	defer observeQuery("OrgStore.FetchByName")()
+
+	query := "select id from org where name = $1"
+	if selectForUpdate {
+		query += " for update"
+	}
+
+	var id int
+	err := database.QueryRow(ctx, query, name).Scan(&id)
+	if err == sql.ErrNoRows {
+		return data.Org{}, ErrNotFound
+	} else if err != nil {
+		return data.Org{}, err
+	}
+
+	return data.Org{
+		Id:   id,
+		Name: name,
+	}, nil
+}
+
+func (OrgStore) Create(ctx context.Context, database Database, org data.Org) (int, error) {
+	defer observeQuery("OrgStore.Create")()
+
+	query := "insert into org (name) values ($1) returning id"
+	return ExecInsert(ctx, database, query, org.Name)
+}
+
+// Provision creates the named org if it doesn't already exist. Unlike an
+// insert which conflicts, no id is consumed from the org sequence when the org
+// exists.
+func (OrgStore) Provision(ctx context.Context, database Database, name string) error {
+	defer observeQuery("OrgStore.Provision")()
+
+	query := `insert into org (name) select $1
+		where not exists (select 1 from org where name = $1)
+		on conflict (name) do nothing`
+	_, err := database.Exec(ctx, query, name)
+	return err
+}
+
+func (OrgStore) DeleteById(ctx context.Context, database Database, id int) error {
+	defer observeQuery("OrgStore.DeleteById")()
+
+	query := "delete from org where id = $1"
+	rowsAffected, err := ExecDelete(ctx, database, query, id)
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrNotFound
+	} else if rowsAffected > 1 {
+		logs.FromContext(ctx).Panic("Deleted multiple rows when expecting only one")
+	}
+
+	return nil
+}
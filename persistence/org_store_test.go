@@ -0,0 +1,121 @@
+package persistence_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/myshkin5/effective-octo-garbanzo/persistence"
+	"github.com/myshkin5/effective-octo-garbanzo/persistence/data"
+)
+
+var _ = Describe("OrgStore Integration", func() {
+	var (
+		database persistence.Database
+		store    persistence.OrgStore
+	)
+
+	BeforeEach(func() {
+		var err error
+		database, err = persistence.Open()
+		Expect(err).NotTo(HaveOccurred())
+
+		cleanDatabase(database)
+
+		store = persistence.OrgStore{}
+	})
+
+	Describe("FetchAll", func() {
+		It("fetches all the orgs", func() {
+			org1Id, org1Name := createOrg("org_store1", database)
+			org2Id, org2Name := createOrg("org_store2", database)
+
+			orgs, err := store.FetchAll(ctx, database)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(orgs).To(ContainElement(data.Org{Id: org1Id, Name: org1Name}))
+			Expect(orgs).To(ContainElement(data.Org{Id: org2Id, Name: org2Name}))
+		})
+	})
+
+	Describe("FetchByName", func() {
+		It("returns not found when fetching an unknown org", func() {
+			_, err := store.FetchByName(ctx, database, "int_test_org_unknown", false)
+
+			Expect(err).To(Equal(persistence.ErrNotFound))
+		})
+
+		It("fetches an org", func() {
+			id, name := createOrg("org_store", database)
+
+			org, err := store.FetchByName(ctx, database, name, true)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(org).To(Equal(data.Org{Id: id, Name: name}))
+		})
+	})
+
+	Describe("Create", func() {
+		It("creates a new org", func() {
+			id, err := store.Create(ctx, database, data.Org{Name: "int_test_org_created"})
+			Expect(err).NotTo(HaveOccurred())
+
+			org, err := store.FetchByName(ctx, database, "int_test_org_created", false)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(org.Id).To(Equal(id))
+		})
+
+		It("returns a duplicate error when the name is already taken", func() {
+			_, name := createOrg("org_store", database)
+
+			_, err := store.Create(ctx, database, data.Org{Name: name})
+			Expect(err).To(Equal(persistence.ErrDuplicate))
+		})
+	})
+
+	Describe("Provision", func() {
+		It("creates a new org", func() {
+			Expect(store.Provision(ctx, database, "int_test_org_provisioned")).To(Succeed())
+
+			_, err := store.FetchByName(ctx, database, "int_test_org_provisioned", false)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("leaves an existing org alone", func() {
+			id, name := createOrg("org_store", database)
+
+			Expect(store.Provision(ctx, database, name)).To(Succeed())
+
+			org, err := store.FetchByName(ctx, database, name, false)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(org.Id).To(Equal(id))
+		})
+	})
+
+	Describe("DeleteById", func() {
+		It("returns not found when deleting an unknown org", func() {
+			err := store.DeleteById(ctx, database, 32000)
+
+			Expect(err).To(Equal(persistence.ErrNotFound))
+		})
+
+		It("deletes an org", func() {
+			id, _ := createOrg("org_store", database)
+
+			Expect(store.DeleteById(ctx, database, id)).To(Succeed())
+
+			err := store.DeleteById(ctx, database, id)
+			Expect(err).To(Equal(persistence.ErrNotFound))
+		})
+
+		It("returns in use when the org still has octos", func() {
+			id, name := createOrg("org_store", database)
+			orgCtx := context.WithValue(ctx, persistence.OrgContextKey, name)
+			_, err := persistence.OctoStore{}.Create(orgCtx, database, data.Octo{Name: "kraken"})
+			Expect(err).NotTo(HaveOccurred())
+
+			err = store.DeleteById(ctx, database, id)
+			Expect(err).To(Equal(persistence.ErrInUse))
+		})
+	})
+})
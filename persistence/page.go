@@ -0,0 +1,90 @@
+package persistence
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrInvalidSort = errors.New("invalid sort field")
+
+// Sort orders the rows of a page by a field and then by the internal id. The
+// zero value orders by the internal id alone.
+type Sort struct {
+	Field      string
+	Descending bool
+}
+
+// Page identifies a page of rows using keyset pagination. When neither
+// AfterId nor BeforeId is set, the first page is fetched. When sorting by a
+// field, SortValue is the value of that field for the row identified by
+// AfterId or BeforeId.
+type Page struct {
+	AfterId   int
+	BeforeId  int
+	SortValue float64
+	Limit     int
+	Sort      Sort
+}
+
+func (p Page) Backward() bool {
+	return p.BeforeId != 0
+}
+
+// keyset returns the where condition and order by clause for the page. The
+// sort field must be one of the sortColumns which maps fields to columns. One
+// more row than the limit is fetched to determine if there are more rows
+// beyond the page.
+func (p Page) keyset(idColumn string, sortColumns map[string]string, params *params) (condition, orderBy string, err error) {
+	columns := idColumn
+	if p.Sort.Field != "" {
+		sortColumn, ok := sortColumns[p.Sort.Field]
+		if !ok {
+			return "", "", ErrInvalidSort
+		}
+		columns = "(" + sortColumn + ", " + idColumn + ")"
+	}
+
+	ascending := p.Backward() == p.Sort.Descending
+	operator, direction := "<", "desc"
+	if ascending {
+		operator, direction = ">", "asc"
+	}
+	if p.Sort.Field == "" {
+		orderBy = fmt.Sprintf("order by %s %s limit %d", idColumn, direction, p.Limit+1)
+	} else {
+		orderBy = fmt.Sprintf("order by %s %s, %s %s limit %d",
+			sortColumns[p.Sort.Field], direction, idColumn, direction, p.Limit+1)
+	}
+
+	boundary := p.AfterId
+	if p.Backward() {
+		boundary = p.BeforeId
+	}
+	if boundary == 0 {
+		return "true", orderBy, nil
+	}
+
+	if p.Sort.Field == "" {
+		return fmt.Sprintf("%s %s %s", columns, operator, params.add(boundary)), orderBy, nil
+	}
+
+	return fmt.Sprintf("%s %s (%s, %s)", columns, operator, params.add(p.SortValue), params.add(boundary)), orderBy, nil
+}
+
+// trim returns the number of fetched rows which belong to the page and if
+// there are more rows beyond the page.
+func (p Page) trim(fetched int) (count int, more bool) {
+	if fetched > p.Limit {
+		return p.Limit, true
+	}
+
+	return fetched, false
+}
+
+// params accumulates the positional parameters of a query.
+type params []interface{}
+
+func (p *params) add(value interface{}) string {
+	*p = append(*p, value)
+	return fmt.Sprintf("$%d", len(*p))
+}
@@ -1,11 +1,14 @@
 package persistence_test
 
 import (
+	"os"
 	"testing"
 	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+
+	"github.com/myshkin5/effective-octo-garbanzo/persistence"
 )
 
 var start time.Time
@@ -17,4 +20,13 @@ func TestPersistence(t *testing.T) {
 
 var _ = BeforeSuite(func() {
 	start = time.Now()
+
+	// The service migrates Postgres before the integration tests run but
+	// nothing else migrates a SQLite database file
+	if os.Getenv("DB_DRIVER") == "sqlite3" {
+		os.Setenv("DB_SOURCE_URL", "file://./ddl/sqlite3")
+		Expect(persistence.Migrate()).To(Succeed())
+	} else {
+		os.Setenv("DB_SOURCE_URL", "file://./ddl")
+	}
 })
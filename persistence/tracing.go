@@ -0,0 +1,26 @@
+package persistence
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/myshkin5/effective-octo-garbanzo/tracing"
+)
+
+// startSpan starts a client span for a database call. The statement is
+// recorded without its arguments so no garbanzo data ends up in traces.
+func (d *database) startSpan(ctx context.Context, operation, statement string) (context.Context, trace.Span) {
+	attributes := []attribute.KeyValue{
+		attribute.String("db.system", d.dialect.system()),
+		attribute.String("db.operation", operation),
+	}
+	if statement != "" {
+		attributes = append(attributes, attribute.String("db.statement", statement))
+	}
+
+	return tracing.Start(ctx, "db."+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attributes...))
+}
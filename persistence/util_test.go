@@ -7,14 +7,25 @@ import (
 
 	"github.com/myshkin5/effective-octo-garbanzo/logs"
 	"github.com/myshkin5/effective-octo-garbanzo/persistence"
+	"github.com/myshkin5/effective-octo-garbanzo/persistence/data"
 )
 
 var ctx = context.Background()
 
+// The garbanzo types seeded by the initial migration
+var (
+	desi   = data.GarbanzoType{Id: 1001, Name: "DESI"}
+	kabuli = data.GarbanzoType{Id: 1002, Name: "KABULI"}
+)
+
 func cleanDatabase(database persistence.Database) {
+	execute("delete from api_key", database)
+	execute("delete from idempotent_request", database)
+	execute("delete from audit_event", database)
 	execute("delete from garbanzo", database)
 	execute("delete from octo", database)
 	execute("delete from org where name like 'int_test_org_%'", database)
+	execute("delete from garbanzo_type where name like 'INT_TEST_%'", database)
 }
 
 func execute(query string, database persistence.Database) {
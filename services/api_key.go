@@ -0,0 +1,141 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/myshkin5/effective-octo-garbanzo/identity"
+	"github.com/myshkin5/effective-octo-garbanzo/logs"
+	"github.com/myshkin5/effective-octo-garbanzo/persistence"
+	"github.com/myshkin5/effective-octo-garbanzo/persistence/data"
+)
+
+const (
+	maxAPIKeyNameLength = 40
+
+	// lastUsedResolution limits how often the use of a key is written
+	lastUsedResolution = time.Minute
+
+	// APIKeySubjectPrefix precedes the prefix of an API key to identify the
+	// caller using it, e.g. in audit events
+	APIKeySubjectPrefix = "api-key:"
+)
+
+var validScope = regexp.MustCompile(`^[\w:-]+$`)
+
+type APIKeyStore interface {
+	FetchByOrgName(ctx context.Context, database persistence.Database, orgName string) (apiKeys []data.APIKey, err error)
+	FetchByHash(ctx context.Context, database persistence.Database, hash string) (apiKey data.APIKey, err error)
+	Create(ctx context.Context, database persistence.Database, apiKey data.APIKey) (apiKeyId int, err error)
+	UpdateLastUsed(ctx context.Context, database persistence.Database, id int, lastUsedAt time.Time) (err error)
+	DeleteByPrefixAndOrgName(ctx context.Context, database persistence.Database, prefix, orgName string) (err error)
+}
+
+// APIKeyService mints and revokes the API keys of orgs and authenticates
+// requests presenting them. Only the hash of a key is stored so a key can't
+// be recovered after it is minted.
+type APIKeyService struct {
+	apiKeyStore APIKeyStore
+	orgStore    OrgStore
+	database    persistence.Database
+}
+
+func NewAPIKeyService(apiKeyStore APIKeyStore, orgStore OrgStore, database persistence.Database) *APIKeyService {
+	return &APIKeyService{
+		apiKeyStore: apiKeyStore,
+		orgStore:    orgStore,
+		database:    database,
+	}
+}
+
+func (s *APIKeyService) FetchByOrgName(ctx context.Context, orgName string) ([]data.APIKey, error) {
+	_, err := s.orgStore.FetchByName(ctx, s.database, orgName, false)
+	if err == persistence.ErrNotFound {
+		return nil, persistence.ErrOrgNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	return s.apiKeyStore.FetchByOrgName(ctx, s.database, orgName)
+}
+
+// Create mints a key for the named org. The key is only ever returned here.
+func (s *APIKeyService) Create(ctx context.Context, orgName string, apiKey data.APIKey) (data.APIKey, string, error) {
+	err := s.validate(apiKey)
+	if err != nil {
+		return data.APIKey{}, "", err
+	}
+
+	key, prefix, hash, err := identity.NewAPIKey()
+	if err != nil {
+		return data.APIKey{}, "", err
+	}
+
+	apiKey.Prefix = prefix
+	apiKey.Hash = hash
+	apiKey.OrgName = orgName
+	apiKey.CreatedAt = time.Now()
+	apiKey.LastUsedAt = time.Time{}
+	apiKey.Id, err = s.apiKeyStore.Create(ctx, s.database, apiKey)
+	if err != nil {
+		return data.APIKey{}, "", err
+	}
+
+	return apiKey, key, nil
+}
+
+func (s *APIKeyService) validate(apiKey data.APIKey) error {
+	errors := make(map[string][]string)
+	if len(apiKey.Name) == 0 {
+		errors["Name"] = append(errors["Name"], "must be present")
+	}
+	if len(apiKey.Name) > maxAPIKeyNameLength {
+		errors["Name"] = append(errors["Name"], fmt.Sprintf("must be at most %d characters", maxAPIKeyNameLength))
+	}
+	for _, scope := range apiKey.Scopes {
+		if !validScope.MatchString(scope) {
+			errors["Scopes"] = append(errors["Scopes"], fmt.Sprintf("must match regular expression '%s'", validScope.String()))
+			break
+		}
+	}
+
+	if len(errors) > 0 {
+		return NewValidationError(errors)
+	}
+
+	return nil
+}
+
+func (s *APIKeyService) DeleteByPrefixAndOrgName(ctx context.Context, prefix, orgName string) error {
+	return s.apiKeyStore.DeleteByPrefixAndOrgName(ctx, s.database, prefix, orgName)
+}
+
+// Validate returns the org, subject and scopes of the key in an ApiKey auth
+// header or identity.ErrInvalidAPIKey when the key is unknown or has been revoked. It
+// implements middleware.Validator.
+func (s *APIKeyService) Validate(ctx context.Context, authHeader string) (org, subject string, scopes []string, err error) {
+	key, ok := identity.APIKeyFromHeader(authHeader)
+	if !ok {
+		return "", "", nil, identity.ErrInvalidAPIKey
+	}
+
+	apiKey, err := s.apiKeyStore.FetchByHash(ctx, s.database, identity.HashAPIKey(key))
+	if err == persistence.ErrNotFound {
+		return "", "", nil, identity.ErrInvalidAPIKey
+	} else if err != nil {
+		return "", "", nil, err
+	}
+
+	now := time.Now()
+	if now.Sub(apiKey.LastUsedAt) >= lastUsedResolution {
+		err = s.apiKeyStore.UpdateLastUsed(ctx, s.database, apiKey.Id, now)
+		if err != nil {
+			// Not worth failing the request over
+			logs.FromContext(ctx).Warnf("Could not record the use of API key %s, error %v", apiKey.Prefix, err)
+		}
+	}
+
+	return apiKey.OrgName, APIKeySubjectPrefix + apiKey.Prefix, apiKey.Scopes, nil
+}
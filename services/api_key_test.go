@@ -0,0 +1,203 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/myshkin5/effective-octo-garbanzo/identity"
+	"github.com/myshkin5/effective-octo-garbanzo/persistence"
+	"github.com/myshkin5/effective-octo-garbanzo/persistence/data"
+	"github.com/myshkin5/effective-octo-garbanzo/services"
+)
+
+var _ = Describe("APIKey", func() {
+	var (
+		mockAPIKeyStore *mockAPIKeyStore
+		mockOrgStore    *mockOrgStore
+		mockDB          *mockDatabase
+		service         *services.APIKeyService
+		ctx             context.Context
+	)
+
+	BeforeEach(func() {
+		mockAPIKeyStore = newMockAPIKeyStore()
+		mockOrgStore = newMockOrgStore()
+		mockDB = newMockDatabase()
+		ctx = context.Background()
+
+		service = services.NewAPIKeyService(mockAPIKeyStore, mockOrgStore, mockDB)
+	})
+
+	Describe("FetchByOrgName", func() {
+		It("fetches the keys of an org", func() {
+			mockOrgStore.FetchByNameOutput.Org <- data.Org{Id: 3, Name: "org1"}
+			mockOrgStore.FetchByNameOutput.Err <- nil
+			apiKeys := []data.APIKey{{Id: 4, Prefix: "abc", OrgName: "org1"}}
+			mockAPIKeyStore.FetchByOrgNameOutput.ApiKeys <- apiKeys
+			mockAPIKeyStore.FetchByOrgNameOutput.Err <- nil
+
+			actualAPIKeys, err := service.FetchByOrgName(ctx, "org1")
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(actualAPIKeys).To(Equal(apiKeys))
+			Expect(mockOrgStore.FetchByNameInput.Name).To(Receive(Equal("org1")))
+			Expect(mockAPIKeyStore.FetchByOrgNameInput.Database).To(Receive(Equal(mockDB)))
+			Expect(mockAPIKeyStore.FetchByOrgNameInput.OrgName).To(Receive(Equal("org1")))
+		})
+
+		It("returns org not found for an unknown org", func() {
+			mockOrgStore.FetchByNameOutput.Org <- data.Org{}
+			mockOrgStore.FetchByNameOutput.Err <- persistence.ErrNotFound
+
+			_, err := service.FetchByOrgName(ctx, "org1")
+
+			Expect(err).To(Equal(persistence.ErrOrgNotFound))
+			Expect(mockAPIKeyStore.FetchByOrgNameCalled).NotTo(Receive())
+		})
+	})
+
+	Describe("Create", func() {
+		It("mints a key storing only its hash", func() {
+			mockAPIKeyStore.CreateOutput.ApiKeyId <- 42
+			mockAPIKeyStore.CreateOutput.Err <- nil
+
+			apiKey, key, err := service.Create(ctx, "org1", data.APIKey{
+				Name:   "feeder",
+				Scopes: []string{"garbanzos:write"},
+			})
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(apiKey.Id).To(Equal(42))
+			Expect(key).To(HavePrefix(apiKey.Prefix + "."))
+			Expect(apiKey.CreatedAt).To(BeTemporally("~", time.Now(), time.Second))
+
+			var stored data.APIKey
+			Expect(mockAPIKeyStore.CreateInput.ApiKey).To(Receive(&stored))
+			Expect(stored.OrgName).To(Equal("org1"))
+			Expect(stored.Name).To(Equal("feeder"))
+			Expect(stored.Scopes).To(Equal([]string{"garbanzos:write"}))
+			Expect(stored.Prefix).To(Equal(apiKey.Prefix))
+			Expect(stored.Hash).To(Equal(identity.HashAPIKey(key)))
+		})
+
+		It("validates the key", func() {
+			_, _, err := service.Create(ctx, "org1", data.APIKey{
+				Scopes: []string{"garbanzos write"},
+			})
+
+			Expect(err).To(BeAssignableToTypeOf(services.ValidationError{}))
+			Expect(err.(services.ValidationError).Errors()).To(HaveKey("Name"))
+			Expect(err.(services.ValidationError).Errors()).To(HaveKey("Scopes"))
+			Expect(mockAPIKeyStore.CreateCalled).NotTo(Receive())
+		})
+
+		It("returns a store error", func() {
+			mockAPIKeyStore.CreateOutput.ApiKeyId <- 0
+			mockAPIKeyStore.CreateOutput.Err <- persistence.ErrOrgNotFound
+
+			_, _, err := service.Create(ctx, "org1", data.APIKey{Name: "feeder"})
+
+			Expect(err).To(Equal(persistence.ErrOrgNotFound))
+		})
+	})
+
+	It("deletes a key", func() {
+		mockAPIKeyStore.DeleteByPrefixAndOrgNameOutput.Err <- persistence.ErrNotFound
+
+		err := service.DeleteByPrefixAndOrgName(ctx, "abc", "org1")
+
+		Expect(err).To(Equal(persistence.ErrNotFound))
+		Expect(mockAPIKeyStore.DeleteByPrefixAndOrgNameInput.Prefix).To(Receive(Equal("abc")))
+		Expect(mockAPIKeyStore.DeleteByPrefixAndOrgNameInput.OrgName).To(Receive(Equal("org1")))
+	})
+
+	Describe("Validate", func() {
+		var (
+			key    string
+			apiKey data.APIKey
+		)
+
+		BeforeEach(func() {
+			var prefix, hash string
+			var err error
+			key, prefix, hash, err = identity.NewAPIKey()
+			Expect(err).NotTo(HaveOccurred())
+
+			apiKey = data.APIKey{
+				Id:      4,
+				Prefix:  prefix,
+				Hash:    hash,
+				OrgName: "org1",
+				Scopes:  []string{"garbanzos:write"},
+			}
+		})
+
+		It("returns the org, subject and scopes of a valid key and records its use", func() {
+			mockAPIKeyStore.FetchByHashOutput.ApiKey <- apiKey
+			mockAPIKeyStore.FetchByHashOutput.Err <- nil
+			mockAPIKeyStore.UpdateLastUsedOutput.Err <- nil
+
+			org, subject, scopes, err := service.Validate(ctx, "ApiKey "+key)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(org).To(Equal("org1"))
+			Expect(subject).To(Equal("api-key:" + apiKey.Prefix))
+			Expect(scopes).To(Equal([]string{"garbanzos:write"}))
+			Expect(mockAPIKeyStore.FetchByHashInput.Hash).To(Receive(Equal(apiKey.Hash)))
+			Expect(mockAPIKeyStore.UpdateLastUsedInput.Id).To(Receive(Equal(4)))
+			Expect(mockAPIKeyStore.UpdateLastUsedInput.LastUsedAt).To(Receive(BeTemporally("~", time.Now(), time.Second)))
+		})
+
+		It("only records the use of a key once a minute", func() {
+			apiKey.LastUsedAt = time.Now().Add(-30 * time.Second)
+			mockAPIKeyStore.FetchByHashOutput.ApiKey <- apiKey
+			mockAPIKeyStore.FetchByHashOutput.Err <- nil
+
+			_, _, _, err := service.Validate(ctx, "ApiKey "+key)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(mockAPIKeyStore.UpdateLastUsedCalled).NotTo(Receive())
+		})
+
+		It("accepts keys whose use can't be recorded", func() {
+			mockAPIKeyStore.FetchByHashOutput.ApiKey <- apiKey
+			mockAPIKeyStore.FetchByHashOutput.Err <- nil
+			mockAPIKeyStore.UpdateLastUsedOutput.Err <- errors.New("bad stuff")
+
+			org, _, _, err := service.Validate(ctx, "ApiKey "+key)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(org).To(Equal("org1"))
+		})
+
+		It("rejects unknown keys", func() {
+			mockAPIKeyStore.FetchByHashOutput.ApiKey <- data.APIKey{}
+			mockAPIKeyStore.FetchByHashOutput.Err <- persistence.ErrNotFound
+
+			_, _, _, err := service.Validate(ctx, "ApiKey "+key)
+
+			Expect(err).To(Equal(identity.ErrInvalidAPIKey))
+		})
+
+		It("rejects other auth headers", func() {
+			_, _, _, err := service.Validate(ctx, "Bearer "+key)
+
+			Expect(err).To(Equal(identity.ErrInvalidAPIKey))
+			Expect(mockAPIKeyStore.FetchByHashCalled).NotTo(Receive())
+		})
+
+		It("returns store errors", func() {
+			storeErr := errors.New("bad stuff")
+			mockAPIKeyStore.FetchByHashOutput.ApiKey <- data.APIKey{}
+			mockAPIKeyStore.FetchByHashOutput.Err <- storeErr
+
+			_, _, _, err := service.Validate(ctx, "ApiKey "+key)
+
+			Expect(err).To(Equal(storeErr))
+		})
+	})
+})
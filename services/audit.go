@@ -0,0 +1,137 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/satori/go.uuid"
+
+	"github.com/myshkin5/effective-octo-garbanzo/persistence"
+	"github.com/myshkin5/effective-octo-garbanzo/persistence/data"
+	"github.com/myshkin5/effective-octo-garbanzo/tracing"
+)
+
+// Audited actions
+const (
+	AuditCreate = "create"
+	AuditUpdate = "update"
+	AuditMove   = "move"
+	AuditDelete = "delete"
+)
+
+type AuditEventStore interface {
+	FetchAll(ctx context.Context, database persistence.Database, filter persistence.AuditFilter, page persistence.Page) (events []data.AuditEvent, more bool, err error)
+	Create(ctx context.Context, database persistence.Database, event data.AuditEvent) (eventId int, err error)
+}
+
+// AuditLog records who changed an org's octos and garbanzos, and how. Events
+// are recorded in the same transaction as the change so there is no change
+// without an event and no event without a change.
+type AuditLog struct {
+	store    AuditEventStore
+	database persistence.Database
+}
+
+func NewAuditLog(store AuditEventStore, database persistence.Database) *AuditLog {
+	return &AuditLog{
+		store:    store,
+		database: database,
+	}
+}
+
+// FetchAll fetches a page of the org's events matching the filter, newest
+// first.
+func (l *AuditLog) FetchAll(ctx context.Context, filter persistence.AuditFilter, page persistence.Page) ([]data.AuditEvent, bool, error) {
+	ctx, span := tracing.Start(ctx, "AuditLog.FetchAll")
+	defer span.End()
+
+	page.Sort = persistence.Sort{Descending: true}
+	return l.store.FetchAll(ctx, l.database, filter, page)
+}
+
+// record records an action on resource within database, a transaction. before
+// and after are snapshots of the resource, nil when it didn't exist.
+func (l *AuditLog) record(ctx context.Context, database persistence.Database, action, resource string, before, after interface{}) error {
+	event := data.AuditEvent{
+		Action:    action,
+		Resource:  resource,
+		CreatedAt: time.Now(),
+	}
+	event.Actor, _ = ctx.Value(persistence.SubjectContextKey).(string)
+	event.RequestID, _ = ctx.Value(persistence.RequestIDContextKey).(string)
+
+	var err error
+	event.Before, err = snapshot(before)
+	if err != nil {
+		return err
+	}
+	event.After, err = snapshot(after)
+	if err != nil {
+		return err
+	}
+
+	_, err = l.store.Create(ctx, database, event)
+	return err
+}
+
+func snapshot(resource interface{}) ([]byte, error) {
+	if resource == nil {
+		return nil, nil
+	}
+
+	return json.Marshal(resource)
+}
+
+// recordMove records an action that changes the path of a resource, i.e. a
+// garbanzo moving to another octo or an octo being renamed, under both the
+// resource's old and new paths so the history of either path includes it.
+func (l *AuditLog) recordMove(ctx context.Context, database persistence.Database, action, from, to string, before, after interface{}) error {
+	err := l.record(ctx, database, action, from, before, after)
+	if err != nil || to == from {
+		return err
+	}
+
+	return l.record(ctx, database, action, to, before, after)
+}
+
+// octoSnapshot and garbanzoSnapshot use the field names of the API
+type octoSnapshot struct {
+	Name    string `json:"name"`
+	Version int    `json:"version"`
+}
+
+type garbanzoSnapshot struct {
+	APIUUID      uuid.UUID `json:"api-uuid"`
+	Octo         string    `json:"octo"`
+	GarbanzoType string    `json:"type"`
+	DiameterMM   float32   `json:"diameter-mm"`
+	Version      int       `json:"version"`
+}
+
+func auditOcto(octo data.Octo) *octoSnapshot {
+	return &octoSnapshot{
+		Name:    octo.Name,
+		Version: octo.Version,
+	}
+}
+
+func auditGarbanzo(garbanzo data.Garbanzo, octoName string) *garbanzoSnapshot {
+	return &garbanzoSnapshot{
+		APIUUID:      garbanzo.APIUUID,
+		Octo:         octoName,
+		GarbanzoType: garbanzo.GarbanzoType.Name,
+		DiameterMM:   garbanzo.DiameterMM,
+		Version:      garbanzo.Version,
+	}
+}
+
+// octoResource and garbanzoResource are the paths of the API's octo and
+// garbanzo resources
+func octoResource(octoName string) string {
+	return "octos/" + octoName
+}
+
+func garbanzoResource(octoName string, apiUUID uuid.UUID) string {
+	return octoResource(octoName) + "/garbanzos/" + apiUUID.String()
+}
@@ -2,51 +2,117 @@ package services
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
 
 	"github.com/satori/go.uuid"
 
 	"github.com/myshkin5/effective-octo-garbanzo/persistence"
 	"github.com/myshkin5/effective-octo-garbanzo/persistence/data"
+	"github.com/myshkin5/effective-octo-garbanzo/tracing"
 )
 
+var (
+	ErrTargetOctoNotFound = errors.New("target octo not found")
+	ErrBatchInvalid       = errors.New("another garbanzo in the batch is invalid")
+)
+
+// MaxBatchSize is the most garbanzos CreateBatch creates at once.
+const MaxBatchSize = 1000
+
 type GarbanzoStore interface {
-	FetchByOctoName(ctx context.Context, database persistence.Database, octoName string) (garbanzos []data.Garbanzo, err error)
+	FetchByOctoName(ctx context.Context, database persistence.Database, octoName string, filter persistence.GarbanzoFilter, page persistence.Page) (garbanzos []data.Garbanzo, more bool, err error)
+	FetchByAPIUUID(ctx context.Context, database persistence.Database, apiUUID uuid.UUID) (garbanzo data.Garbanzo, octoName string, err error)
 	FetchByAPIUUIDAndOctoName(ctx context.Context, database persistence.Database, apiUUID uuid.UUID, octoName string) (garbanzo data.Garbanzo, err error)
 	Create(ctx context.Context, database persistence.Database, garbanzo data.Garbanzo) (garbanzoId int, err error)
-	DeleteByAPIUUIDAndOctoName(ctx context.Context, database persistence.Database, apiUUID uuid.UUID, octoName string) (err error)
+	CreateBatch(ctx context.Context, database persistence.Database, garbanzos []data.Garbanzo) (garbanzoIds []int, err error)
+	UpdateByAPIUUIDAndOctoName(ctx context.Context, database persistence.Database, garbanzo data.Garbanzo, octoName string) (version int, err error)
+	MoveById(ctx context.Context, database persistence.Database, id int, octoId int, version int) (newVersion int, err error)
+	DeleteByAPIUUIDAndOctoName(ctx context.Context, database persistence.Database, apiUUID uuid.UUID, octoName string, version int) (err error)
 	DeleteByOctoId(ctx context.Context, database persistence.Database, octoId int) (err error)
 }
 
+// GarbanzoTypes looks up garbanzo types by the names used in the API.
+type GarbanzoTypes interface {
+	FetchAll(ctx context.Context) (garbanzoTypes []data.GarbanzoType, err error)
+	FetchByName(ctx context.Context, name string) (garbanzoType data.GarbanzoType, err error)
+}
+
+// BatchResult is the outcome of creating one garbanzo of a batch. Err is a
+// ValidationError for an invalid garbanzo or ErrBatchInvalid for a valid one
+// that wasn't created because another was invalid.
+type BatchResult struct {
+	Garbanzo data.Garbanzo
+	Err      error
+}
+
 type GarbanzoService struct {
-	octoStore     OctoStore
-	garbanzoStore GarbanzoStore
-	database      persistence.Database
+	octoStore          OctoStore
+	garbanzoStore      GarbanzoStore
+	garbanzoTypes      GarbanzoTypes
+	idempotentRequests *IdempotentRequests
+	auditLog           *AuditLog
+	database           persistence.Database
 }
 
-func NewGarbanzoService(octoStore OctoStore, garbanzoStore GarbanzoStore, database persistence.Database) *GarbanzoService {
+func NewGarbanzoService(octoStore OctoStore, garbanzoStore GarbanzoStore, garbanzoTypes GarbanzoTypes, idempotentRequests *IdempotentRequests, auditLog *AuditLog, database persistence.Database) *GarbanzoService {
 	return &GarbanzoService{
-		octoStore:     octoStore,
-		garbanzoStore: garbanzoStore,
-		database:      database,
+		octoStore:          octoStore,
+		garbanzoStore:      garbanzoStore,
+		garbanzoTypes:      garbanzoTypes,
+		idempotentRequests: idempotentRequests,
+		auditLog:           auditLog,
+		database:           database,
 	}
 }
 
-func (s *GarbanzoService) FetchByOctoName(ctx context.Context, octoName string) ([]data.Garbanzo, error) {
-	return s.garbanzoStore.FetchByOctoName(ctx, s.database, octoName)
+// FetchByOctoName fetches a page of an octo's garbanzos. The garbanzo types of
+// the filter are identified by name only.
+func (s *GarbanzoService) FetchByOctoName(ctx context.Context, octoName string, filter persistence.GarbanzoFilter, page persistence.Page) ([]data.Garbanzo, bool, error) {
+	ctx, span := tracing.Start(ctx, "GarbanzoService.FetchByOctoName")
+	defer span.End()
+
+	var garbanzoTypes []data.GarbanzoType
+	for _, garbanzoType := range filter.GarbanzoTypes {
+		garbanzoType, err := s.garbanzoTypes.FetchByName(ctx, garbanzoType.Name)
+		if err == persistence.ErrNotFound {
+			names, err := garbanzoTypeNames(ctx, s.garbanzoTypes)
+			if err != nil {
+				return nil, false, err
+			}
+			return nil, false, NewValidationError(map[string][]string{
+				"GarbanzoType": {"filter must be one of " + names},
+			})
+		} else if err != nil {
+			return nil, false, err
+		}
+		garbanzoTypes = append(garbanzoTypes, garbanzoType)
+	}
+	filter.GarbanzoTypes = garbanzoTypes
+
+	return s.garbanzoStore.FetchByOctoName(ctx, s.database, octoName, filter, page)
 }
 
 func (s *GarbanzoService) FetchByAPIUUIDAndOctoName(ctx context.Context, apiUUID uuid.UUID, octoName string) (data.Garbanzo, error) {
+	ctx, span := tracing.Start(ctx, "GarbanzoService.FetchByAPIUUIDAndOctoName")
+	defer span.End()
+
 	return s.garbanzoStore.FetchByAPIUUIDAndOctoName(ctx, s.database, apiUUID, octoName)
 }
 
+// Create creates a garbanzo in the named octo. The garbanzo's type is
+// identified by name only.
 func (s *GarbanzoService) Create(ctx context.Context, octoName string, garbanzo data.Garbanzo) (garbanzoOut data.Garbanzo, err error) {
-	err = validate(garbanzo)
+	ctx, span := tracing.Start(ctx, "GarbanzoService.Create")
+	defer span.End()
+
+	garbanzo, err = validateGarbanzo(ctx, s.garbanzoTypes, garbanzo)
 	if err != nil {
 		return data.Garbanzo{}, err
 	}
 
-	garbanzo.APIUUID = uuid.NewV4()
-
 	database, err := s.database.BeginTx(ctx)
 	if err != nil {
 		return data.Garbanzo{}, err
@@ -59,6 +125,137 @@ func (s *GarbanzoService) Create(ctx context.Context, octoName string, garbanzo
 		err = database.Commit()
 	}()
 
+	garbanzo, err = s.create(ctx, database, octoName, garbanzo)
+	if err != nil {
+		return data.Garbanzo{}, err
+	}
+
+	return garbanzo, nil
+}
+
+// CreateIdempotently is Create for a request with an Idempotency-Key. The
+// response rendered by respond is recorded in the same transaction as the
+// garbanzo and is replayed, rather than creating another garbanzo, when the
+// request is retried.
+func (s *GarbanzoService) CreateIdempotently(ctx context.Context, octoName string, garbanzo data.Garbanzo, request data.IdempotentRequest, respond func(garbanzo data.Garbanzo) []byte) (response []byte, replayed bool, err error) {
+	ctx, span := tracing.Start(ctx, "GarbanzoService.CreateIdempotently")
+	defer span.End()
+
+	garbanzo, err = validateGarbanzo(ctx, s.garbanzoTypes, garbanzo)
+	if err != nil {
+		return nil, false, err
+	}
+
+	database, err := s.database.BeginTx(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	defer func() {
+		if err != nil {
+			database.Rollback()
+			return
+		}
+		err = database.Commit()
+	}()
+
+	response, replayed, err = s.idempotentRequests.create(ctx, database, request, func() ([]byte, error) {
+		garbanzo, err := s.create(ctx, database, octoName, garbanzo)
+		if err != nil {
+			return nil, err
+		}
+
+		return respond(garbanzo), nil
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	return response, replayed, nil
+}
+
+// CreateBatch validates the garbanzos and creates the valid ones in the named
+// octo with a single insert, returning a result for each garbanzo in order.
+// Unless bestEffort, no garbanzos are created when any are invalid.
+func (s *GarbanzoService) CreateBatch(ctx context.Context, octoName string, garbanzos []data.Garbanzo, bestEffort bool) (results []BatchResult, err error) {
+	ctx, span := tracing.Start(ctx, "GarbanzoService.CreateBatch")
+	defer span.End()
+
+	if len(garbanzos) == 0 || len(garbanzos) > MaxBatchSize {
+		return nil, NewValidationError(map[string][]string{
+			"Batch": {fmt.Sprintf("must have between 1 and %d garbanzos", MaxBatchSize)},
+		})
+	}
+
+	results = make([]BatchResult, len(garbanzos))
+	var valid []int
+	for i, garbanzo := range garbanzos {
+		results[i].Garbanzo, err = validateGarbanzo(ctx, s.garbanzoTypes, garbanzo)
+		if _, ok := err.(ValidationError); ok {
+			results[i] = BatchResult{Garbanzo: garbanzo, Err: err}
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		valid = append(valid, i)
+	}
+
+	if len(valid) < len(garbanzos) && !bestEffort {
+		for _, i := range valid {
+			results[i].Err = ErrBatchInvalid
+		}
+		return results, nil
+	} else if len(valid) == 0 {
+		return results, nil
+	}
+
+	database, err := s.database.BeginTx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			database.Rollback()
+			return
+		}
+		err = database.Commit()
+	}()
+
+	octo, err := s.octoStore.FetchByName(ctx, database, octoName, true)
+	if err != nil {
+		return nil, err
+	}
+
+	batch := make([]data.Garbanzo, len(valid))
+	for j, i := range valid {
+		results[i].Garbanzo.APIUUID = uuid.NewV4()
+		results[i].Garbanzo.OctoId = octo.Id
+		batch[j] = results[i].Garbanzo
+	}
+
+	ids, err := s.garbanzoStore.CreateBatch(ctx, database, batch)
+	if err != nil {
+		return nil, err
+	}
+	for j, i := range valid {
+		results[i].Garbanzo.Id = ids[j]
+		results[i].Garbanzo.Version = 1
+
+		garbanzo := results[i].Garbanzo
+		err = s.auditLog.record(ctx, database, AuditCreate, garbanzoResource(octoName, garbanzo.APIUUID),
+			nil, auditGarbanzo(garbanzo, octoName))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return results, nil
+}
+
+// create creates a validated garbanzo in the named octo within database, a
+// transaction.
+func (s *GarbanzoService) create(ctx context.Context, database persistence.Database, octoName string, garbanzo data.Garbanzo) (data.Garbanzo, error) {
+	garbanzo.APIUUID = uuid.NewV4()
+
 	octo, err := s.octoStore.FetchByName(ctx, database, octoName, true)
 	if err != nil {
 		return data.Garbanzo{}, err
@@ -69,17 +266,184 @@ func (s *GarbanzoService) Create(ctx context.Context, octoName string, garbanzo
 	if err != nil {
 		return data.Garbanzo{}, err
 	}
+	garbanzo.Version = 1
+
+	err = s.auditLog.record(ctx, database, AuditCreate, garbanzoResource(octoName, garbanzo.APIUUID),
+		nil, auditGarbanzo(garbanzo, octoName))
+	if err != nil {
+		return data.Garbanzo{}, err
+	}
 
 	return garbanzo, nil
 }
 
-func validate(garbanzo data.Garbanzo) error {
+// UpdateByAPIUUIDAndOctoName replaces a garbanzo's fields. The garbanzo's type
+// is identified by name only. Unless garbanzo.Version is 0, the garbanzo is only
+// updated while it is still the row of garbanzo.Id at that version.
+func (s *GarbanzoService) UpdateByAPIUUIDAndOctoName(ctx context.Context, apiUUID uuid.UUID, octoName string, garbanzo data.Garbanzo) (garbanzoOut data.Garbanzo, err error) {
+	ctx, span := tracing.Start(ctx, "GarbanzoService.UpdateByAPIUUIDAndOctoName")
+	defer span.End()
+
+	garbanzo, err = validateGarbanzo(ctx, s.garbanzoTypes, garbanzo)
+	if err != nil {
+		return data.Garbanzo{}, err
+	}
+
+	// The API UUID is the garbanzo's identity and never changes
+	garbanzo.APIUUID = apiUUID
+
+	database, err := s.database.BeginTx(ctx)
+	if err != nil {
+		return data.Garbanzo{}, err
+	}
+	defer func() {
+		if err != nil {
+			database.Rollback()
+			return
+		}
+		err = database.Commit()
+	}()
+
+	existing, err := s.fetchForUpdate(ctx, database, apiUUID, octoName, garbanzo.Id, garbanzo.Version)
+	if err != nil {
+		return data.Garbanzo{}, err
+	}
+
+	garbanzo.Version, err = s.garbanzoStore.UpdateByAPIUUIDAndOctoName(ctx, database, garbanzo, octoName)
+	if err != nil {
+		return data.Garbanzo{}, err
+	}
+	garbanzo.Id = existing.Id
+	garbanzo.OctoId = existing.OctoId
+
+	err = s.auditLog.record(ctx, database, AuditUpdate, garbanzoResource(octoName, apiUUID),
+		auditGarbanzo(existing, octoName), auditGarbanzo(garbanzo, octoName))
+	if err != nil {
+		return data.Garbanzo{}, err
+	}
+
+	return garbanzo, nil
+}
+
+// fetchForUpdate fetches a garbanzo within database, a transaction, after
+// locking its octo so that the garbanzo can't change until the transaction
+// ends. Like the store's versioned updates and deletes, a missing garbanzo is a
+// version mismatch unless version is 0, as is a garbanzo which isn't the row
+// of id, e.g. one deleted and created again with the same API UUID.
+func (s *GarbanzoService) fetchForUpdate(ctx context.Context, database persistence.Database, apiUUID uuid.UUID, octoName string, id, version int) (data.Garbanzo, error) {
+	_, err := s.octoStore.FetchByName(ctx, database, octoName, true)
+	if err == nil {
+		var garbanzo data.Garbanzo
+		garbanzo, err = s.garbanzoStore.FetchByAPIUUIDAndOctoName(ctx, database, apiUUID, octoName)
+		if err == nil && id != 0 && id != garbanzo.Id {
+			return data.Garbanzo{}, persistence.ErrVersionMismatch
+		} else if err == nil {
+			return garbanzo, nil
+		}
+	}
+	if err == persistence.ErrNotFound && version != 0 {
+		return data.Garbanzo{}, persistence.ErrVersionMismatch
+	}
+
+	return data.Garbanzo{}, err
+}
+
+// MoveByAPIUUIDAndOctoName moves a garbanzo to the target octo. Unless version
+// is 0, the garbanzo is only moved while it is still the row of id at that
+// version.
+func (s *GarbanzoService) MoveByAPIUUIDAndOctoName(ctx context.Context, apiUUID uuid.UUID, octoName, targetOctoName string, id, version int) (garbanzoOut data.Garbanzo, err error) {
+	ctx, span := tracing.Start(ctx, "GarbanzoService.MoveByAPIUUIDAndOctoName")
+	defer span.End()
+
+	if len(targetOctoName) == 0 {
+		return data.Garbanzo{}, NewValidationError(map[string][]string{
+			"TargetOctoName": {"must be present"},
+		})
+	}
+
+	database, err := s.database.BeginTx(ctx)
+	if err != nil {
+		return data.Garbanzo{}, err
+	}
+	defer func() {
+		if err != nil {
+			database.Rollback()
+			return
+		}
+		err = database.Commit()
+	}()
+
+	// Always lock the octos in the same order so that two concurrent moves in
+	// opposite directions can't deadlock
+	names := []string{octoName}
+	if targetOctoName != octoName {
+		names = append(names, targetOctoName)
+	}
+	sort.Strings(names)
+	octos := make(map[string]data.Octo, len(names))
+	for _, name := range names {
+		var octo data.Octo
+		octo, err = s.octoStore.FetchByName(ctx, database, name, true)
+		if err == persistence.ErrNotFound && name != octoName {
+			err = ErrTargetOctoNotFound
+			return data.Garbanzo{}, err
+		} else if err != nil {
+			return data.Garbanzo{}, err
+		}
+		octos[name] = octo
+	}
+
+	garbanzo, err := s.garbanzoStore.FetchByAPIUUIDAndOctoName(ctx, database, apiUUID, octoName)
+	if err != nil {
+		return data.Garbanzo{}, err
+	}
+	if id != 0 && id != garbanzo.Id {
+		err = persistence.ErrVersionMismatch
+		return data.Garbanzo{}, err
+	}
+
+	if targetOctoName == octoName {
+		if version != 0 && version != garbanzo.Version {
+			err = persistence.ErrVersionMismatch
+			return data.Garbanzo{}, err
+		}
+		return garbanzo, nil
+	}
+
+	before := auditGarbanzo(garbanzo, octoName)
+	garbanzo.OctoId = octos[targetOctoName].Id
+	garbanzo.Version, err = s.garbanzoStore.MoveById(ctx, database, garbanzo.Id, garbanzo.OctoId, version)
+	if err != nil {
+		return data.Garbanzo{}, err
+	}
+
+	err = s.auditLog.recordMove(ctx, database, AuditMove, garbanzoResource(octoName, apiUUID),
+		garbanzoResource(targetOctoName, apiUUID), before, auditGarbanzo(garbanzo, targetOctoName))
+	if err != nil {
+		return data.Garbanzo{}, err
+	}
+
+	return garbanzo, nil
+}
+
+// validateGarbanzo returns the garbanzo with its type resolved from the type's
+// name.
+func validateGarbanzo(ctx context.Context, garbanzoTypes GarbanzoTypes, garbanzo data.Garbanzo) (data.Garbanzo, error) {
 	errors := make(map[string][]string)
-	if garbanzo.GarbanzoType == 0 {
+	if len(garbanzo.GarbanzoType.Name) == 0 {
 		errors["GarbanzoType"] = append(errors["GarbanzoType"], "must be present")
-	}
-	if garbanzo.GarbanzoType != data.DESI && garbanzo.GarbanzoType != data.KABULI {
-		errors["GarbanzoType"] = append(errors["GarbanzoType"], "must be either 'DESI' or 'KABULI'")
+	} else {
+		garbanzoType, err := garbanzoTypes.FetchByName(ctx, garbanzo.GarbanzoType.Name)
+		if err == persistence.ErrNotFound {
+			names, err := garbanzoTypeNames(ctx, garbanzoTypes)
+			if err != nil {
+				return data.Garbanzo{}, err
+			}
+			errors["GarbanzoType"] = append(errors["GarbanzoType"], "must be one of "+names)
+		} else if err != nil {
+			return data.Garbanzo{}, err
+		}
+		garbanzo.GarbanzoType = garbanzoType
 	}
 	if garbanzo.DiameterMM == 0.0 {
 		errors["DiameterMM"] = append(errors["DiameterMM"], "must be present")
@@ -89,12 +453,61 @@ func validate(garbanzo data.Garbanzo) error {
 	}
 
 	if len(errors) > 0 {
-		return NewValidationError(errors)
+		return data.Garbanzo{}, NewValidationError(errors)
 	}
 
-	return nil
+	return garbanzo, nil
 }
 
-func (s *GarbanzoService) DeleteByAPIUUIDAndOctoName(ctx context.Context, apiUUID uuid.UUID, octoName string) error {
-	return s.garbanzoStore.DeleteByAPIUUIDAndOctoName(ctx, s.database, apiUUID, octoName)
+// garbanzoTypeNames lists the names of the current garbanzo types for
+// validation messages, e.g. 'DESI', 'KABULI'.
+func garbanzoTypeNames(ctx context.Context, garbanzoTypes GarbanzoTypes) (string, error) {
+	all, err := garbanzoTypes.FetchAll(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	var names []string
+	for _, garbanzoType := range all {
+		names = append(names, "'"+garbanzoType.Name+"'")
+	}
+
+	return strings.Join(names, ", "), nil
+}
+
+// DeleteByAPIUUIDAndOctoName deletes a garbanzo. Unless version is 0, the
+// garbanzo is only deleted while it is still the row of id at that version.
+func (s *GarbanzoService) DeleteByAPIUUIDAndOctoName(ctx context.Context, apiUUID uuid.UUID, octoName string, id, version int) (err error) {
+	ctx, span := tracing.Start(ctx, "GarbanzoService.DeleteByAPIUUIDAndOctoName")
+	defer span.End()
+
+	database, err := s.database.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			database.Rollback()
+			return
+		}
+		err = database.Commit()
+	}()
+
+	existing, err := s.fetchForUpdate(ctx, database, apiUUID, octoName, id, version)
+	if err != nil {
+		return err
+	}
+
+	err = s.garbanzoStore.DeleteByAPIUUIDAndOctoName(ctx, database, apiUUID, octoName, version)
+	if err != nil {
+		return err
+	}
+
+	err = s.auditLog.record(ctx, database, AuditDelete, garbanzoResource(octoName, apiUUID),
+		auditGarbanzo(existing, octoName), nil)
+	if err != nil {
+		return err
+	}
+
+	return nil
 }
@@ -5,20 +5,35 @@ package services_test
 import (
 	"context"
 	"errors"
+	"fmt"
+	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	"github.com/satori/go.uuid"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
 
 	"github.com/myshkin5/effective-octo-garbanzo/persistence"
 	"github.com/myshkin5/effective-octo-garbanzo/persistence/data"
 	"github.com/myshkin5/effective-octo-garbanzo/services"
 )
 
+var (
+	desi   = data.GarbanzoType{Id: 1001, Name: "DESI"}
+	kabuli = data.GarbanzoType{Id: 1002, Name: "KABULI"}
+)
+
 var _ = Describe("Garbanzo", func() {
 	var (
 		mockOctoStore     *mockOctoStore
 		mockGarbanzoStore *mockGarbanzoStore
+		mockGarbanzoTypes *mockGarbanzoTypes
+		mockRequestStore  *mockIdempotentRequestStore
+		mockAuditStore    *mockAuditEventStore
 		mockDB            *mockDatabase
 		mockTx            *mockDatabase
 		service           *services.GarbanzoService
@@ -28,22 +43,34 @@ var _ = Describe("Garbanzo", func() {
 	BeforeEach(func() {
 		mockOctoStore = newMockOctoStore()
 		mockGarbanzoStore = newMockGarbanzoStore()
+		mockGarbanzoTypes = newMockGarbanzoTypes()
+		mockRequestStore = newMockIdempotentRequestStore()
+		mockAuditStore = newMockAuditEventStore()
 		mockDB = newMockDatabase()
 		mockTx = newMockDatabase()
-		ctx = context.Background()
+		ctx = context.WithValue(context.Background(), persistence.OrgContextKey, "my-org")
+		ctx = context.WithValue(ctx, persistence.SubjectContextKey, "user1")
+		ctx = context.WithValue(ctx, persistence.RequestIDContextKey, "req1")
 
-		service = services.NewGarbanzoService(mockOctoStore, mockGarbanzoStore, mockDB)
+		service = services.NewGarbanzoService(mockOctoStore, mockGarbanzoStore, mockGarbanzoTypes,
+			services.NewIdempotentRequests(mockRequestStore, mockDB, time.Hour, time.Minute), services.NewAuditLog(mockAuditStore, mockDB), mockDB)
 	})
 
 	It("fetches garbanzos by octo name", func() {
+		mockGarbanzoTypes.FetchByNameOutput.GarbanzoType <- kabuli
+		mockGarbanzoTypes.FetchByNameOutput.Err <- nil
 		var garbanzos []data.Garbanzo
 		mockGarbanzoStore.FetchByOctoNameOutput.Garbanzos <- garbanzos
 		err := errors.New("some error")
+		mockGarbanzoStore.FetchByOctoNameOutput.More <- true
 		mockGarbanzoStore.FetchByOctoNameOutput.Err <- err
+		filter := persistence.GarbanzoFilter{GarbanzoTypes: []data.GarbanzoType{{Name: "KABULI"}}}
+		page := persistence.Page{AfterId: 3, Limit: 10}
 
-		actualGarbanzos, actualErr := service.FetchByOctoName(ctx, "my-octo")
+		actualGarbanzos, actualMore, actualErr := service.FetchByOctoName(ctx, "my-octo", filter, page)
 
 		Expect(actualGarbanzos).To(Equal(garbanzos))
+		Expect(actualMore).To(BeTrue())
 		Expect(actualErr).To(Equal(err))
 
 		Expect(mockGarbanzoStore.FetchByOctoNameCalled).To(HaveLen(1))
@@ -52,15 +79,36 @@ var _ = Describe("Garbanzo", func() {
 		Expect(actualDB).To(Equal(mockDB))
 		var actualCtx context.Context
 		Expect(mockGarbanzoStore.FetchByOctoNameInput.Ctx).To(Receive(&actualCtx))
-		Expect(actualCtx).To(Equal(ctx))
+		Expect(actualCtx.Value(persistence.OrgContextKey)).To(Equal("my-org"))
+		Expect(mockGarbanzoStore.FetchByOctoNameInput.Filter).To(Receive(Equal(persistence.GarbanzoFilter{
+			GarbanzoTypes: []data.GarbanzoType{kabuli},
+		})))
+		Expect(mockGarbanzoStore.FetchByOctoNameInput.Page).To(Receive(Equal(page)))
 		var actualOctoName string
 		Expect(mockGarbanzoStore.FetchByOctoNameInput.OctoName).To(Receive(&actualOctoName))
 		Expect(actualOctoName).To(Equal("my-octo"))
+
+		Expect(mockGarbanzoTypes.FetchByNameInput.Name).To(Receive(Equal("KABULI")))
+	})
+
+	It("returns a validation error listing the garbanzo types for an unknown type filter", func() {
+		mockGarbanzoTypes.FetchByNameOutput.GarbanzoType <- data.GarbanzoType{}
+		mockGarbanzoTypes.FetchByNameOutput.Err <- persistence.ErrNotFound
+		mockGarbanzoTypes.FetchAllOutput.GarbanzoTypes <- []data.GarbanzoType{desi, kabuli}
+		mockGarbanzoTypes.FetchAllOutput.Err <- nil
+		filter := persistence.GarbanzoFilter{GarbanzoTypes: []data.GarbanzoType{{Name: "BOGUS"}}}
+
+		_, _, err := service.FetchByOctoName(ctx, "my-octo", filter, persistence.Page{Limit: 10})
+		Expect(err).To(Equal(services.NewValidationError(map[string][]string{
+			"GarbanzoType": {"filter must be one of 'DESI', 'KABULI'"},
+		})))
+
+		Expect(mockGarbanzoStore.FetchByOctoNameCalled).To(BeEmpty())
 	})
 
 	It("fetches a garbanzo by API UUID", func() {
 		garbanzo := data.Garbanzo{
-			GarbanzoType: data.DESI,
+			GarbanzoType: desi,
 		}
 		mockGarbanzoStore.FetchByAPIUUIDAndOctoNameOutput.Garbanzo <- garbanzo
 		err := errors.New("some error")
@@ -78,7 +126,7 @@ var _ = Describe("Garbanzo", func() {
 		Expect(actualDB).To(Equal(mockDB))
 		var actualCtx context.Context
 		Expect(mockGarbanzoStore.FetchByAPIUUIDAndOctoNameInput.Ctx).To(Receive(&actualCtx))
-		Expect(actualCtx).To(Equal(ctx))
+		Expect(actualCtx.Value(persistence.OrgContextKey)).To(Equal("my-org"))
 		var actualAPIUUID uuid.UUID
 		Expect(mockGarbanzoStore.FetchByAPIUUIDAndOctoNameInput.ApiUUID).To(Receive(&actualAPIUUID))
 		Expect(actualAPIUUID).To(Equal(apiUUID))
@@ -102,10 +150,16 @@ var _ = Describe("Garbanzo", func() {
 			mockGarbanzoStore.CreateOutput.GarbanzoId <- garbanzoId
 			mockGarbanzoStore.CreateOutput.Err <- nil
 
+			mockAuditStore.CreateOutput.EventId <- 7
+			mockAuditStore.CreateOutput.Err <- nil
+
 			mockTx.CommitOutput.Err <- nil
 
+			mockGarbanzoTypes.FetchByNameOutput.GarbanzoType <- desi
+			mockGarbanzoTypes.FetchByNameOutput.Err <- nil
+
 			garbanzo := data.Garbanzo{
-				GarbanzoType: data.DESI,
+				GarbanzoType: data.GarbanzoType{Name: "DESI"},
 				DiameterMM:   0.1,
 			}
 			octoName := "kraken"
@@ -113,15 +167,16 @@ var _ = Describe("Garbanzo", func() {
 
 			Expect(actualErr).To(BeNil())
 			Expect(actualGarbanzo.Id).To(Equal(garbanzoId))
+			Expect(actualGarbanzo.Version).To(Equal(1))
 			Expect(actualGarbanzo.APIUUID).NotTo(Equal(uuid.UUID{}))
-			Expect(actualGarbanzo.GarbanzoType).To(Equal(data.DESI))
+			Expect(actualGarbanzo.GarbanzoType).To(Equal(desi))
 
 			Expect(mockDB.BeginTxCalled).To(HaveLen(1))
 
 			Expect(mockOctoStore.FetchByNameCalled).To(HaveLen(1))
 			var actualCtx context.Context
 			Expect(mockOctoStore.FetchByNameInput.Ctx).To(Receive(&actualCtx))
-			Expect(actualCtx).To(Equal(ctx))
+			Expect(actualCtx.Value(persistence.OrgContextKey)).To(Equal("my-org"))
 			var actualDB persistence.Database
 			Expect(mockOctoStore.FetchByNameInput.Database).To(Receive(&actualDB))
 			Expect(actualDB).To(Equal(mockTx))
@@ -134,23 +189,140 @@ var _ = Describe("Garbanzo", func() {
 
 			Expect(mockGarbanzoStore.CreateCalled).To(HaveLen(1))
 			Expect(mockGarbanzoStore.CreateInput.Ctx).To(Receive(&actualCtx))
-			Expect(actualCtx).To(Equal(ctx))
+			Expect(actualCtx.Value(persistence.OrgContextKey)).To(Equal("my-org"))
 			Expect(mockGarbanzoStore.CreateInput.Database).To(Receive(&actualDB))
 			Expect(actualDB).To(Equal(mockTx))
 			var persistedGarbanzo data.Garbanzo
 			Expect(mockGarbanzoStore.CreateInput.Garbanzo).To(Receive(&persistedGarbanzo))
 			Expect(persistedGarbanzo.APIUUID).To(Equal(actualGarbanzo.APIUUID))
-			Expect(persistedGarbanzo.GarbanzoType).To(Equal(garbanzo.GarbanzoType))
+			Expect(persistedGarbanzo.GarbanzoType).To(Equal(desi))
 			Expect(persistedGarbanzo.OctoId).To(Equal(octoId))
 
+			Expect(mockAuditStore.CreateInput.Database).To(Receive(Equal(mockTx)))
+			var event data.AuditEvent
+			Expect(mockAuditStore.CreateInput.Event).To(Receive(&event))
+			Expect(event.Actor).To(Equal("user1"))
+			Expect(event.Action).To(Equal(services.AuditCreate))
+			Expect(event.Resource).To(Equal("octos/kraken/garbanzos/" + actualGarbanzo.APIUUID.String()))
+			Expect(event.Before).To(BeNil())
+			Expect(event.After).To(MatchJSON(fmt.Sprintf(`{
+				"api-uuid":    "%s",
+				"octo":        "kraken",
+				"type":        "DESI",
+				"diameter-mm": 0.1,
+				"version":     1
+			}`, actualGarbanzo.APIUUID)))
+			Expect(event.RequestID).To(Equal("req1"))
+
 			Expect(mockTx.CommitCalled).To(HaveLen(1))
 		})
 
+		It("creates a garbanzo idempotently in the same transaction as the recorded response", func() {
+			mockDB.BeginTxOutput.Database <- mockTx
+			mockDB.BeginTxOutput.Err <- nil
+			mockGarbanzoTypes.FetchByNameOutput.GarbanzoType <- desi
+			mockGarbanzoTypes.FetchByNameOutput.Err <- nil
+			mockRequestStore.FetchByKeyOutput.Request <- data.IdempotentRequest{}
+			mockRequestStore.FetchByKeyOutput.Err <- persistence.ErrNotFound
+			mockOctoStore.FetchByNameOutput.Octo <- data.Octo{Id: 77}
+			mockOctoStore.FetchByNameOutput.Err <- nil
+			mockGarbanzoStore.CreateOutput.GarbanzoId <- 42
+			mockGarbanzoStore.CreateOutput.Err <- nil
+			mockAuditStore.CreateOutput.EventId <- 7
+			mockAuditStore.CreateOutput.Err <- nil
+			mockRequestStore.CreateOutput.Err <- nil
+			mockTx.CommitOutput.Err <- nil
+
+			var created data.Garbanzo
+			response, replayed, err := service.CreateIdempotently(ctx, "kraken", data.Garbanzo{
+				GarbanzoType: data.GarbanzoType{Name: "DESI"},
+				DiameterMM:   0.1,
+			}, data.IdempotentRequest{Key: "retry-me", RequestHash: "hash1"}, func(garbanzo data.Garbanzo) []byte {
+				created = garbanzo
+				return []byte(garbanzo.APIUUID.String())
+			})
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(replayed).To(BeFalse())
+			Expect(created.Id).To(Equal(42))
+			Expect(created.OctoId).To(Equal(77))
+			Expect(created.GarbanzoType).To(Equal(desi))
+			Expect(created.Version).To(Equal(1))
+			Expect(string(response)).To(Equal(created.APIUUID.String()))
+
+			Expect(mockGarbanzoStore.CreateInput.Database).To(Receive(Equal(mockTx)))
+			Expect(mockAuditStore.CreateInput.Database).To(Receive(Equal(mockTx)))
+			Expect(mockRequestStore.CreateInput.Database).To(Receive(Equal(mockTx)))
+			var recorded data.IdempotentRequest
+			Expect(mockRequestStore.CreateInput.Request).To(Receive(&recorded))
+			Expect(recorded.Response).To(Equal(response))
+		})
+
+		It("replays the recorded response of a retried idempotent create", func() {
+			mockDB.BeginTxOutput.Database <- mockTx
+			mockDB.BeginTxOutput.Err <- nil
+			mockGarbanzoTypes.FetchByNameOutput.GarbanzoType <- desi
+			mockGarbanzoTypes.FetchByNameOutput.Err <- nil
+			mockRequestStore.FetchByKeyOutput.Request <- data.IdempotentRequest{
+				Key:         "retry-me",
+				RequestHash: "hash1",
+				Response:    []byte("original"),
+			}
+			mockRequestStore.FetchByKeyOutput.Err <- nil
+			mockTx.CommitOutput.Err <- nil
+
+			response, replayed, err := service.CreateIdempotently(ctx, "kraken", data.Garbanzo{
+				GarbanzoType: data.GarbanzoType{Name: "DESI"},
+				DiameterMM:   0.1,
+			}, data.IdempotentRequest{Key: "retry-me", RequestHash: "hash1"}, func(data.Garbanzo) []byte {
+				Fail("the response should be replayed")
+				return nil
+			})
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(replayed).To(BeTrue())
+			Expect(string(response)).To(Equal("original"))
+			Expect(mockOctoStore.FetchByNameCalled).To(BeEmpty())
+			Expect(mockGarbanzoStore.CreateCalled).To(BeEmpty())
+		})
+
+		It("traces the creation with the store calls as children", func() {
+			spans := tracetest.NewSpanRecorder()
+			otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans)))
+			defer otel.SetTracerProvider(noop.NewTracerProvider())
+
+			mockDB.BeginTxOutput.Database <- mockTx
+			mockDB.BeginTxOutput.Err <- nil
+			mockOctoStore.FetchByNameOutput.Octo <- data.Octo{Id: 77}
+			mockOctoStore.FetchByNameOutput.Err <- nil
+			mockGarbanzoStore.CreateOutput.GarbanzoId <- 42
+			mockGarbanzoStore.CreateOutput.Err <- nil
+			mockAuditStore.CreateOutput.EventId <- 7
+			mockAuditStore.CreateOutput.Err <- nil
+			mockTx.CommitOutput.Err <- nil
+			mockGarbanzoTypes.FetchByNameOutput.GarbanzoType <- desi
+			mockGarbanzoTypes.FetchByNameOutput.Err <- nil
+
+			_, err := service.Create(ctx, "kraken", data.Garbanzo{
+				GarbanzoType: data.GarbanzoType{Name: "DESI"},
+				DiameterMM:   0.1,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(spans.Ended()).To(HaveLen(1))
+			Expect(spans.Ended()[0].Name()).To(Equal("GarbanzoService.Create"))
+			var actualCtx context.Context
+			Expect(mockGarbanzoStore.CreateInput.Ctx).To(Receive(&actualCtx))
+			Expect(trace.SpanContextFromContext(actualCtx)).To(Equal(spans.Ended()[0].SpanContext()))
+		})
+
 		It("returns an error if it can't start a transaction", func() {
 			mockDB.BeginTxOutput.Database <- nil
 			mockDB.BeginTxOutput.Err <- errors.New("don't bother")
+			mockGarbanzoTypes.FetchByNameOutput.GarbanzoType <- desi
+			mockGarbanzoTypes.FetchByNameOutput.Err <- nil
 			garbanzo := data.Garbanzo{
-				GarbanzoType: data.DESI,
+				GarbanzoType: data.GarbanzoType{Name: "DESI"},
 				DiameterMM:   0.1,
 			}
 
@@ -168,8 +340,11 @@ var _ = Describe("Garbanzo", func() {
 
 			mockTx.RollbackOutput.Err <- nil
 
+			mockGarbanzoTypes.FetchByNameOutput.GarbanzoType <- desi
+			mockGarbanzoTypes.FetchByNameOutput.Err <- nil
+
 			garbanzo := data.Garbanzo{
-				GarbanzoType: data.DESI,
+				GarbanzoType: data.GarbanzoType{Name: "DESI"},
 				DiameterMM:   0.1,
 			}
 
@@ -190,14 +365,21 @@ var _ = Describe("Garbanzo", func() {
 			errors := validationErr.Errors()
 			Expect(errors).To(HaveLen(2))
 			Expect(errors).To(Equal(map[string][]string{
-				"GarbanzoType": {"must be present", "must be either 'DESI' or 'KABULI'"},
+				"GarbanzoType": {"must be present"},
 				"DiameterMM":   {"must be present", "must be a positive decimal value"},
 			}))
+
+			Expect(mockGarbanzoTypes.FetchByNameCalled).To(BeEmpty())
 		})
 
-		It("returns a validation error for invalid values", func() {
+		It("returns a validation error listing the garbanzo types for invalid values", func() {
+			mockGarbanzoTypes.FetchByNameOutput.GarbanzoType <- data.GarbanzoType{}
+			mockGarbanzoTypes.FetchByNameOutput.Err <- persistence.ErrNotFound
+			mockGarbanzoTypes.FetchAllOutput.GarbanzoTypes <- []data.GarbanzoType{desi, kabuli, {Id: 1003, Name: "BAMBAI"}}
+			mockGarbanzoTypes.FetchAllOutput.Err <- nil
+
 			garbanzo := data.Garbanzo{
-				GarbanzoType: 1,
+				GarbanzoType: data.GarbanzoType{Name: "BOGUS"},
 				DiameterMM:   -1.2,
 			}
 
@@ -208,33 +390,685 @@ var _ = Describe("Garbanzo", func() {
 			errors := validationErr.Errors()
 			Expect(errors).To(HaveLen(2))
 			Expect(errors).To(Equal(map[string][]string{
-				"GarbanzoType": {"must be either 'DESI' or 'KABULI'"},
+				"GarbanzoType": {"must be one of 'DESI', 'KABULI', 'BAMBAI'"},
 				"DiameterMM":   {"must be a positive decimal value"},
 			}))
+
+			Expect(mockGarbanzoTypes.FetchByNameInput.Name).To(Receive(Equal("BOGUS")))
+		})
+
+		It("returns the error when the garbanzo type can't be fetched", func() {
+			mockGarbanzoTypes.FetchByNameOutput.GarbanzoType <- data.GarbanzoType{}
+			mockGarbanzoTypes.FetchByNameOutput.Err <- errors.New("don't bother")
+
+			_, err := service.Create(ctx, "kraken", data.Garbanzo{
+				GarbanzoType: data.GarbanzoType{Name: "DESI"},
+				DiameterMM:   0.1,
+			})
+			Expect(err).To(MatchError("don't bother"))
+
+			Expect(mockDB.BeginTxCalled).To(BeEmpty())
 		})
 	})
 
-	It("deletes a garbanzo by API UUID", func() {
-		err := errors.New("some error")
-		mockGarbanzoStore.DeleteByAPIUUIDAndOctoNameOutput.Err <- err
-		apiUUID := uuid.NewV4()
+	Describe("CreateBatch", func() {
+		var garbanzos []data.Garbanzo
 
-		actualErr := service.DeleteByAPIUUIDAndOctoName(ctx, apiUUID, "my-octo")
+		BeforeEach(func() {
+			garbanzos = []data.Garbanzo{
+				{GarbanzoType: data.GarbanzoType{Name: "DESI"}, DiameterMM: 4.2},
+				{GarbanzoType: data.GarbanzoType{Name: "KABULI"}, DiameterMM: -1.2},
+				{GarbanzoType: data.GarbanzoType{Name: "KABULI"}, DiameterMM: 6.4},
+			}
+			for _, garbanzoType := range []data.GarbanzoType{desi, kabuli, kabuli} {
+				mockGarbanzoTypes.FetchByNameOutput.GarbanzoType <- garbanzoType
+				mockGarbanzoTypes.FetchByNameOutput.Err <- nil
+			}
+		})
 
-		Expect(actualErr).To(Equal(err))
+		It("creates every garbanzo with one insert in one transaction", func() {
+			garbanzos[1].DiameterMM = 1.2
+			mockDB.BeginTxOutput.Database <- mockTx
+			mockDB.BeginTxOutput.Err <- nil
+			mockOctoStore.FetchByNameOutput.Octo <- data.Octo{Id: 77}
+			mockOctoStore.FetchByNameOutput.Err <- nil
+			mockGarbanzoStore.CreateBatchOutput.GarbanzoIds <- []int{42, 43, 44}
+			mockGarbanzoStore.CreateBatchOutput.Err <- nil
+			for i := 0; i < 3; i++ {
+				mockAuditStore.CreateOutput.EventId <- 7 + i
+				mockAuditStore.CreateOutput.Err <- nil
+			}
+			mockTx.CommitOutput.Err <- nil
 
-		Expect(mockGarbanzoStore.DeleteByAPIUUIDAndOctoNameCalled).To(HaveLen(1))
-		var actualDB persistence.Database
-		Expect(mockGarbanzoStore.DeleteByAPIUUIDAndOctoNameInput.Database).To(Receive(&actualDB))
-		Expect(actualDB).To(Equal(mockDB))
-		var actualCtx context.Context
-		Expect(mockGarbanzoStore.DeleteByAPIUUIDAndOctoNameInput.Ctx).To(Receive(&actualCtx))
-		Expect(actualCtx).To(Equal(ctx))
-		var actualAPIUUID uuid.UUID
-		Expect(mockGarbanzoStore.DeleteByAPIUUIDAndOctoNameInput.ApiUUID).To(Receive(&actualAPIUUID))
-		Expect(actualAPIUUID).To(Equal(apiUUID))
-		var actualOctoName string
-		Expect(mockGarbanzoStore.DeleteByAPIUUIDAndOctoNameInput.OctoName).To(Receive(&actualOctoName))
-		Expect(actualOctoName).To(Equal("my-octo"))
+			results, err := service.CreateBatch(ctx, "kraken", garbanzos, false)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(results).To(HaveLen(3))
+			for i, result := range results {
+				Expect(result.Err).NotTo(HaveOccurred())
+				Expect(result.Garbanzo.Id).To(Equal(42 + i))
+				Expect(result.Garbanzo.Version).To(Equal(1))
+				Expect(result.Garbanzo.OctoId).To(Equal(77))
+				Expect(result.Garbanzo.APIUUID).NotTo(Equal(uuid.UUID{}))
+			}
+			Expect(results[1].Garbanzo.GarbanzoType).To(Equal(kabuli))
+
+			Expect(mockOctoStore.FetchByNameInput.Database).To(Receive(Equal(mockTx)))
+			Expect(mockOctoStore.FetchByNameInput.Name).To(Receive(Equal("kraken")))
+			Expect(mockOctoStore.FetchByNameInput.SelectForUpdate).To(Receive(BeTrue()))
+			Expect(mockGarbanzoStore.CreateBatchCalled).To(HaveLen(1))
+			Expect(mockGarbanzoStore.CreateBatchInput.Database).To(Receive(Equal(mockTx)))
+			var batch []data.Garbanzo
+			Expect(mockGarbanzoStore.CreateBatchInput.Garbanzos).To(Receive(&batch))
+			Expect(batch).To(HaveLen(3))
+			Expect(batch[0].APIUUID).To(Equal(results[0].Garbanzo.APIUUID))
+			Expect(mockAuditStore.CreateCalled).To(HaveLen(3))
+			for _, result := range results {
+				Expect(mockAuditStore.CreateInput.Database).To(Receive(Equal(mockTx)))
+				var event data.AuditEvent
+				Expect(mockAuditStore.CreateInput.Event).To(Receive(&event))
+				Expect(event.Action).To(Equal(services.AuditCreate))
+				Expect(event.Resource).To(Equal("octos/kraken/garbanzos/" + result.Garbanzo.APIUUID.String()))
+			}
+			Expect(mockTx.CommitCalled).To(HaveLen(1))
+		})
+
+		It("creates none of the garbanzos when any is invalid", func() {
+			results, err := service.CreateBatch(ctx, "kraken", garbanzos, false)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(results).To(HaveLen(3))
+			Expect(results[0].Err).To(Equal(services.ErrBatchInvalid))
+			Expect(results[1].Err).To(Equal(services.NewValidationError(map[string][]string{
+				"DiameterMM": {"must be a positive decimal value"},
+			})))
+			Expect(results[1].Garbanzo).To(Equal(garbanzos[1]))
+			Expect(results[2].Err).To(Equal(services.ErrBatchInvalid))
+
+			Expect(mockDB.BeginTxCalled).To(BeEmpty())
+		})
+
+		It("creates the valid garbanzos in best effort mode", func() {
+			mockDB.BeginTxOutput.Database <- mockTx
+			mockDB.BeginTxOutput.Err <- nil
+			mockOctoStore.FetchByNameOutput.Octo <- data.Octo{Id: 77}
+			mockOctoStore.FetchByNameOutput.Err <- nil
+			mockGarbanzoStore.CreateBatchOutput.GarbanzoIds <- []int{42, 44}
+			mockGarbanzoStore.CreateBatchOutput.Err <- nil
+			for i := 0; i < 2; i++ {
+				mockAuditStore.CreateOutput.EventId <- 7 + i
+				mockAuditStore.CreateOutput.Err <- nil
+			}
+			mockTx.CommitOutput.Err <- nil
+
+			results, err := service.CreateBatch(ctx, "kraken", garbanzos, true)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(results[0].Err).NotTo(HaveOccurred())
+			Expect(results[0].Garbanzo.Id).To(Equal(42))
+			_, ok := results[1].Err.(services.ValidationError)
+			Expect(ok).To(BeTrue())
+			Expect(results[2].Err).NotTo(HaveOccurred())
+			Expect(results[2].Garbanzo.Id).To(Equal(44))
+
+			var batch []data.Garbanzo
+			Expect(mockGarbanzoStore.CreateBatchInput.Garbanzos).To(Receive(&batch))
+			Expect(batch).To(HaveLen(2))
+			Expect(batch[1].DiameterMM).To(Equal(float32(6.4)))
+		})
+
+		It("rolls back and returns the error when the octo can't be found", func() {
+			garbanzos[1].DiameterMM = 1.2
+			mockDB.BeginTxOutput.Database <- mockTx
+			mockDB.BeginTxOutput.Err <- nil
+			mockOctoStore.FetchByNameOutput.Octo <- data.Octo{}
+			mockOctoStore.FetchByNameOutput.Err <- persistence.ErrNotFound
+			mockTx.RollbackOutput.Err <- nil
+
+			_, err := service.CreateBatch(ctx, "kraken", garbanzos, false)
+			Expect(err).To(Equal(persistence.ErrNotFound))
+
+			Expect(mockGarbanzoStore.CreateBatchCalled).To(BeEmpty())
+			Expect(mockTx.RollbackCalled).To(HaveLen(1))
+		})
+
+		It("returns a validation error for an empty batch", func() {
+			_, err := service.CreateBatch(ctx, "kraken", nil, false)
+			Expect(err).To(Equal(services.NewValidationError(map[string][]string{
+				"Batch": {"must have between 1 and 1000 garbanzos"},
+			})))
+		})
+	})
+
+	Describe("UpdateByAPIUUIDAndOctoName", func() {
+		It("updates a garbanzo keeping its API UUID", func() {
+			apiUUID := uuid.NewV4()
+			mockGarbanzoTypes.FetchByNameOutput.GarbanzoType <- kabuli
+			mockGarbanzoTypes.FetchByNameOutput.Err <- nil
+			mockDB.BeginTxOutput.Database <- mockTx
+			mockDB.BeginTxOutput.Err <- nil
+			mockOctoStore.FetchByNameOutput.Octo <- data.Octo{Id: 77, Name: "my-octo"}
+			mockOctoStore.FetchByNameOutput.Err <- nil
+			mockGarbanzoStore.FetchByAPIUUIDAndOctoNameOutput.Garbanzo <- data.Garbanzo{
+				Id:           42,
+				APIUUID:      apiUUID,
+				GarbanzoType: desi,
+				OctoId:       77,
+				DiameterMM:   0.1,
+				Version:      5,
+			}
+			mockGarbanzoStore.FetchByAPIUUIDAndOctoNameOutput.Err <- nil
+			mockGarbanzoStore.UpdateByAPIUUIDAndOctoNameOutput.Version <- 6
+			mockGarbanzoStore.UpdateByAPIUUIDAndOctoNameOutput.Err <- nil
+			mockAuditStore.CreateOutput.EventId <- 7
+			mockAuditStore.CreateOutput.Err <- nil
+			mockTx.CommitOutput.Err <- nil
+
+			actualGarbanzo, actualErr := service.UpdateByAPIUUIDAndOctoName(ctx, apiUUID, "my-octo", data.Garbanzo{
+				APIUUID:      uuid.NewV4(),
+				GarbanzoType: data.GarbanzoType{Name: "KABULI"},
+				DiameterMM:   0.2,
+				Version:      5,
+			})
+			Expect(actualErr).NotTo(HaveOccurred())
+			Expect(actualGarbanzo).To(Equal(data.Garbanzo{
+				Id:           42,
+				APIUUID:      apiUUID,
+				GarbanzoType: kabuli,
+				OctoId:       77,
+				DiameterMM:   0.2,
+				Version:      6,
+			}))
+
+			Expect(mockOctoStore.FetchByNameInput.Database).To(Receive(Equal(mockTx)))
+			Expect(mockOctoStore.FetchByNameInput.Name).To(Receive(Equal("my-octo")))
+			Expect(mockOctoStore.FetchByNameInput.SelectForUpdate).To(Receive(BeTrue()))
+			Expect(mockGarbanzoStore.FetchByAPIUUIDAndOctoNameInput.Database).To(Receive(Equal(mockTx)))
+			Expect(mockGarbanzoStore.FetchByAPIUUIDAndOctoNameInput.ApiUUID).To(Receive(Equal(apiUUID)))
+
+			Expect(mockGarbanzoStore.UpdateByAPIUUIDAndOctoNameCalled).To(HaveLen(1))
+			var actualDB persistence.Database
+			Expect(mockGarbanzoStore.UpdateByAPIUUIDAndOctoNameInput.Database).To(Receive(&actualDB))
+			Expect(actualDB).To(Equal(mockTx))
+			var actualCtx context.Context
+			Expect(mockGarbanzoStore.UpdateByAPIUUIDAndOctoNameInput.Ctx).To(Receive(&actualCtx))
+			Expect(actualCtx.Value(persistence.OrgContextKey)).To(Equal("my-org"))
+			var persistedGarbanzo data.Garbanzo
+			Expect(mockGarbanzoStore.UpdateByAPIUUIDAndOctoNameInput.Garbanzo).To(Receive(&persistedGarbanzo))
+			Expect(persistedGarbanzo).To(Equal(data.Garbanzo{
+				APIUUID:      apiUUID,
+				GarbanzoType: kabuli,
+				DiameterMM:   0.2,
+				Version:      5,
+			}))
+			var actualOctoName string
+			Expect(mockGarbanzoStore.UpdateByAPIUUIDAndOctoNameInput.OctoName).To(Receive(&actualOctoName))
+			Expect(actualOctoName).To(Equal("my-octo"))
+
+			Expect(mockAuditStore.CreateInput.Database).To(Receive(Equal(mockTx)))
+			var event data.AuditEvent
+			Expect(mockAuditStore.CreateInput.Event).To(Receive(&event))
+			Expect(event.Action).To(Equal(services.AuditUpdate))
+			Expect(event.Resource).To(Equal("octos/my-octo/garbanzos/" + apiUUID.String()))
+			Expect(event.Before).To(MatchJSON(fmt.Sprintf(`{
+				"api-uuid":    "%s",
+				"octo":        "my-octo",
+				"type":        "DESI",
+				"diameter-mm": 0.1,
+				"version":     5
+			}`, apiUUID)))
+			Expect(event.After).To(MatchJSON(fmt.Sprintf(`{
+				"api-uuid":    "%s",
+				"octo":        "my-octo",
+				"type":        "KABULI",
+				"diameter-mm": 0.2,
+				"version":     6
+			}`, apiUUID)))
+			Expect(mockTx.CommitCalled).To(HaveLen(1))
+		})
+
+		It("rolls back and returns the store's error", func() {
+			err := errors.New("some error")
+			mockGarbanzoTypes.FetchByNameOutput.GarbanzoType <- kabuli
+			mockGarbanzoTypes.FetchByNameOutput.Err <- nil
+			mockDB.BeginTxOutput.Database <- mockTx
+			mockDB.BeginTxOutput.Err <- nil
+			mockOctoStore.FetchByNameOutput.Octo <- data.Octo{Id: 77, Name: "my-octo"}
+			mockOctoStore.FetchByNameOutput.Err <- nil
+			mockGarbanzoStore.FetchByAPIUUIDAndOctoNameOutput.Garbanzo <- data.Garbanzo{Id: 42}
+			mockGarbanzoStore.FetchByAPIUUIDAndOctoNameOutput.Err <- nil
+			mockGarbanzoStore.UpdateByAPIUUIDAndOctoNameOutput.Version <- 0
+			mockGarbanzoStore.UpdateByAPIUUIDAndOctoNameOutput.Err <- err
+			mockTx.RollbackOutput.Err <- nil
+
+			_, actualErr := service.UpdateByAPIUUIDAndOctoName(ctx, uuid.NewV4(), "my-octo", data.Garbanzo{
+				GarbanzoType: data.GarbanzoType{Name: "KABULI"},
+				DiameterMM:   0.2,
+			})
+			Expect(actualErr).To(Equal(err))
+
+			Expect(mockAuditStore.CreateCalled).To(BeEmpty())
+			Expect(mockTx.RollbackCalled).To(HaveLen(1))
+		})
+
+		It("returns a version mismatch for a versioned update of a missing garbanzo", func() {
+			mockGarbanzoTypes.FetchByNameOutput.GarbanzoType <- kabuli
+			mockGarbanzoTypes.FetchByNameOutput.Err <- nil
+			mockDB.BeginTxOutput.Database <- mockTx
+			mockDB.BeginTxOutput.Err <- nil
+			mockOctoStore.FetchByNameOutput.Octo <- data.Octo{Id: 77, Name: "my-octo"}
+			mockOctoStore.FetchByNameOutput.Err <- nil
+			mockGarbanzoStore.FetchByAPIUUIDAndOctoNameOutput.Garbanzo <- data.Garbanzo{}
+			mockGarbanzoStore.FetchByAPIUUIDAndOctoNameOutput.Err <- persistence.ErrNotFound
+			mockTx.RollbackOutput.Err <- nil
+
+			_, err := service.UpdateByAPIUUIDAndOctoName(ctx, uuid.NewV4(), "my-octo", data.Garbanzo{
+				GarbanzoType: data.GarbanzoType{Name: "KABULI"},
+				DiameterMM:   0.2,
+				Version:      5,
+			})
+			Expect(err).To(Equal(persistence.ErrVersionMismatch))
+
+			Expect(mockGarbanzoStore.UpdateByAPIUUIDAndOctoNameCalled).To(BeEmpty())
+			Expect(mockTx.RollbackCalled).To(HaveLen(1))
+		})
+
+		It("returns a validation error for invalid values", func() {
+			mockGarbanzoTypes.FetchByNameOutput.GarbanzoType <- kabuli
+			mockGarbanzoTypes.FetchByNameOutput.Err <- nil
+
+			_, err := service.UpdateByAPIUUIDAndOctoName(ctx, uuid.NewV4(), "my-octo", data.Garbanzo{
+				GarbanzoType: data.GarbanzoType{Name: "KABULI"},
+				DiameterMM:   -1.2,
+			})
+			Expect(err).To(HaveOccurred())
+			validationErr, ok := err.(services.ValidationError)
+			Expect(ok).To(BeTrue())
+			Expect(validationErr.Errors()).To(Equal(map[string][]string{
+				"DiameterMM": {"must be a positive decimal value"},
+			}))
+
+			Expect(mockGarbanzoStore.UpdateByAPIUUIDAndOctoNameCalled).To(BeEmpty())
+		})
+	})
+
+	Describe("MoveByAPIUUIDAndOctoName", func() {
+		var apiUUID uuid.UUID
+
+		BeforeEach(func() {
+			apiUUID = uuid.NewV4()
+		})
+
+		It("returns a validation error when the target octo is missing", func() {
+			_, err := service.MoveByAPIUUIDAndOctoName(ctx, apiUUID, "kraken", "", 0, 0)
+			Expect(err).To(HaveOccurred())
+			validationErr, ok := err.(services.ValidationError)
+			Expect(ok).To(BeTrue())
+			Expect(validationErr.Errors()).To(Equal(map[string][]string{
+				"TargetOctoName": {"must be present"},
+			}))
+
+			Expect(mockDB.BeginTxCalled).To(BeEmpty())
+		})
+
+		It("returns an error if it can't start a transaction", func() {
+			mockDB.BeginTxOutput.Database <- nil
+			mockDB.BeginTxOutput.Err <- errors.New("don't bother")
+
+			_, err := service.MoveByAPIUUIDAndOctoName(ctx, apiUUID, "kraken", "cthulhu", 0, 0)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("don't bother"))
+		})
+
+		It("locks both octos in name order", func() {
+			mockDB.BeginTxOutput.Database <- mockTx
+			mockDB.BeginTxOutput.Err <- nil
+
+			mockOctoStore.FetchByNameOutput.Octo <- data.Octo{Id: 2, Name: "cthulhu"}
+			mockOctoStore.FetchByNameOutput.Err <- nil
+			mockOctoStore.FetchByNameOutput.Octo <- data.Octo{Id: 1, Name: "kraken"}
+			mockOctoStore.FetchByNameOutput.Err <- nil
+
+			mockGarbanzoStore.FetchByAPIUUIDAndOctoNameOutput.Garbanzo <- data.Garbanzo{
+				Id:           42,
+				APIUUID:      apiUUID,
+				GarbanzoType: desi,
+				DiameterMM:   4.2,
+				OctoId:       1,
+				Version:      3,
+			}
+			mockGarbanzoStore.FetchByAPIUUIDAndOctoNameOutput.Err <- nil
+
+			mockGarbanzoStore.MoveByIdOutput.NewVersion <- 4
+			mockGarbanzoStore.MoveByIdOutput.Err <- nil
+
+			mockAuditStore.CreateOutput.EventId <- 7
+			mockAuditStore.CreateOutput.Err <- nil
+			mockAuditStore.CreateOutput.EventId <- 8
+			mockAuditStore.CreateOutput.Err <- nil
+
+			mockTx.CommitOutput.Err <- nil
+
+			actualGarbanzo, err := service.MoveByAPIUUIDAndOctoName(ctx, apiUUID, "kraken", "cthulhu", 42, 3)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(actualGarbanzo).To(Equal(data.Garbanzo{
+				Id:           42,
+				APIUUID:      apiUUID,
+				GarbanzoType: desi,
+				DiameterMM:   4.2,
+				OctoId:       2,
+				Version:      4,
+			}))
+
+			Expect(mockOctoStore.FetchByNameCalled).To(HaveLen(2))
+			var actualDB persistence.Database
+			var actualName string
+			var actualSelectForUpdate bool
+			for _, expectedName := range []string{"cthulhu", "kraken"} {
+				Expect(mockOctoStore.FetchByNameInput.Database).To(Receive(&actualDB))
+				Expect(actualDB).To(Equal(mockTx))
+				Expect(mockOctoStore.FetchByNameInput.Name).To(Receive(&actualName))
+				Expect(actualName).To(Equal(expectedName))
+				Expect(mockOctoStore.FetchByNameInput.SelectForUpdate).To(Receive(&actualSelectForUpdate))
+				Expect(actualSelectForUpdate).To(BeTrue())
+			}
+
+			Expect(mockGarbanzoStore.FetchByAPIUUIDAndOctoNameInput.Database).To(Receive(&actualDB))
+			Expect(actualDB).To(Equal(mockTx))
+			Expect(mockGarbanzoStore.FetchByAPIUUIDAndOctoNameInput.ApiUUID).To(Receive(Equal(apiUUID)))
+			Expect(mockGarbanzoStore.FetchByAPIUUIDAndOctoNameInput.OctoName).To(Receive(Equal("kraken")))
+
+			Expect(mockGarbanzoStore.MoveByIdCalled).To(HaveLen(1))
+			Expect(mockGarbanzoStore.MoveByIdInput.Database).To(Receive(&actualDB))
+			Expect(actualDB).To(Equal(mockTx))
+			Expect(mockGarbanzoStore.MoveByIdInput.Id).To(Receive(Equal(42)))
+			Expect(mockGarbanzoStore.MoveByIdInput.OctoId).To(Receive(Equal(2)))
+			Expect(mockGarbanzoStore.MoveByIdInput.Version).To(Receive(Equal(3)))
+
+			Expect(mockAuditStore.CreateInput.Database).To(Receive(Equal(mockTx)))
+			var event data.AuditEvent
+			Expect(mockAuditStore.CreateInput.Event).To(Receive(&event))
+			Expect(event.Action).To(Equal(services.AuditMove))
+			Expect(event.Resource).To(Equal("octos/kraken/garbanzos/" + apiUUID.String()))
+			Expect(event.Before).To(MatchJSON(fmt.Sprintf(`{
+				"api-uuid":    "%s",
+				"octo":        "kraken",
+				"type":        "DESI",
+				"diameter-mm": 4.2,
+				"version":     3
+			}`, apiUUID)))
+			Expect(event.After).To(MatchJSON(fmt.Sprintf(`{
+				"api-uuid":    "%s",
+				"octo":        "cthulhu",
+				"type":        "DESI",
+				"diameter-mm": 4.2,
+				"version":     4
+			}`, apiUUID)))
+
+			// Recorded under the new path too
+			Expect(mockAuditStore.CreateInput.Database).To(Receive(Equal(mockTx)))
+			var targetEvent data.AuditEvent
+			Expect(mockAuditStore.CreateInput.Event).To(Receive(&targetEvent))
+			Expect(targetEvent.Resource).To(Equal("octos/cthulhu/garbanzos/" + apiUUID.String()))
+			Expect(targetEvent.Action).To(Equal(services.AuditMove))
+			Expect(targetEvent.Before).To(Equal(event.Before))
+			Expect(targetEvent.After).To(Equal(event.After))
+
+			Expect(mockTx.CommitCalled).To(HaveLen(1))
+		})
+
+		It("rolls back and returns a target not found error when the target octo doesn't exist", func() {
+			mockDB.BeginTxOutput.Database <- mockTx
+			mockDB.BeginTxOutput.Err <- nil
+
+			mockOctoStore.FetchByNameOutput.Octo <- data.Octo{}
+			mockOctoStore.FetchByNameOutput.Err <- persistence.ErrNotFound
+
+			mockTx.RollbackOutput.Err <- nil
+
+			_, err := service.MoveByAPIUUIDAndOctoName(ctx, apiUUID, "kraken", "cthulhu", 0, 0)
+			Expect(err).To(Equal(services.ErrTargetOctoNotFound))
+
+			Expect(mockTx.RollbackCalled).To(HaveLen(1))
+		})
+
+		It("rolls back and returns not found when the source octo doesn't exist", func() {
+			mockDB.BeginTxOutput.Database <- mockTx
+			mockDB.BeginTxOutput.Err <- nil
+
+			mockOctoStore.FetchByNameOutput.Octo <- data.Octo{Id: 2, Name: "cthulhu"}
+			mockOctoStore.FetchByNameOutput.Err <- nil
+			mockOctoStore.FetchByNameOutput.Octo <- data.Octo{}
+			mockOctoStore.FetchByNameOutput.Err <- persistence.ErrNotFound
+
+			mockTx.RollbackOutput.Err <- nil
+
+			_, err := service.MoveByAPIUUIDAndOctoName(ctx, apiUUID, "kraken", "cthulhu", 0, 0)
+			Expect(err).To(Equal(persistence.ErrNotFound))
+
+			Expect(mockTx.RollbackCalled).To(HaveLen(1))
+		})
+
+		It("rolls back and returns not found when the garbanzo doesn't exist", func() {
+			mockDB.BeginTxOutput.Database <- mockTx
+			mockDB.BeginTxOutput.Err <- nil
+
+			mockOctoStore.FetchByNameOutput.Octo <- data.Octo{Id: 2, Name: "cthulhu"}
+			mockOctoStore.FetchByNameOutput.Err <- nil
+			mockOctoStore.FetchByNameOutput.Octo <- data.Octo{Id: 1, Name: "kraken"}
+			mockOctoStore.FetchByNameOutput.Err <- nil
+
+			mockGarbanzoStore.FetchByAPIUUIDAndOctoNameOutput.Garbanzo <- data.Garbanzo{}
+			mockGarbanzoStore.FetchByAPIUUIDAndOctoNameOutput.Err <- persistence.ErrNotFound
+
+			mockTx.RollbackOutput.Err <- nil
+
+			_, err := service.MoveByAPIUUIDAndOctoName(ctx, apiUUID, "kraken", "cthulhu", 0, 0)
+			Expect(err).To(Equal(persistence.ErrNotFound))
+
+			Expect(mockGarbanzoStore.MoveByIdCalled).To(BeEmpty())
+			Expect(mockTx.RollbackCalled).To(HaveLen(1))
+		})
+
+		It("rolls back and returns an error if it can't move the garbanzo", func() {
+			mockDB.BeginTxOutput.Database <- mockTx
+			mockDB.BeginTxOutput.Err <- nil
+
+			mockOctoStore.FetchByNameOutput.Octo <- data.Octo{Id: 2, Name: "cthulhu"}
+			mockOctoStore.FetchByNameOutput.Err <- nil
+			mockOctoStore.FetchByNameOutput.Octo <- data.Octo{Id: 1, Name: "kraken"}
+			mockOctoStore.FetchByNameOutput.Err <- nil
+
+			mockGarbanzoStore.FetchByAPIUUIDAndOctoNameOutput.Garbanzo <- data.Garbanzo{Id: 42}
+			mockGarbanzoStore.FetchByAPIUUIDAndOctoNameOutput.Err <- nil
+
+			mockGarbanzoStore.MoveByIdOutput.NewVersion <- 0
+			mockGarbanzoStore.MoveByIdOutput.Err <- errors.New("some error")
+
+			mockTx.RollbackOutput.Err <- nil
+
+			_, err := service.MoveByAPIUUIDAndOctoName(ctx, apiUUID, "kraken", "cthulhu", 0, 0)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("some error"))
+
+			Expect(mockTx.RollbackCalled).To(HaveLen(1))
+		})
+
+		It("leaves the garbanzo where it is when moving to the same octo", func() {
+			mockDB.BeginTxOutput.Database <- mockTx
+			mockDB.BeginTxOutput.Err <- nil
+
+			mockOctoStore.FetchByNameOutput.Octo <- data.Octo{Id: 1, Name: "kraken"}
+			mockOctoStore.FetchByNameOutput.Err <- nil
+
+			mockGarbanzoStore.FetchByAPIUUIDAndOctoNameOutput.Garbanzo <- data.Garbanzo{Id: 42, OctoId: 1}
+			mockGarbanzoStore.FetchByAPIUUIDAndOctoNameOutput.Err <- nil
+
+			mockTx.CommitOutput.Err <- nil
+
+			actualGarbanzo, err := service.MoveByAPIUUIDAndOctoName(ctx, apiUUID, "kraken", "kraken", 0, 0)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(actualGarbanzo.OctoId).To(Equal(1))
+
+			Expect(mockOctoStore.FetchByNameCalled).To(HaveLen(1))
+			Expect(mockGarbanzoStore.MoveByIdCalled).To(BeEmpty())
+			Expect(mockAuditStore.CreateCalled).To(BeEmpty())
+			Expect(mockTx.CommitCalled).To(HaveLen(1))
+		})
+
+		It("rolls back and returns a version mismatch when the garbanzo isn't the row of the id", func() {
+			mockDB.BeginTxOutput.Database <- mockTx
+			mockDB.BeginTxOutput.Err <- nil
+
+			mockOctoStore.FetchByNameOutput.Octo <- data.Octo{Id: 2, Name: "cthulhu"}
+			mockOctoStore.FetchByNameOutput.Err <- nil
+			mockOctoStore.FetchByNameOutput.Octo <- data.Octo{Id: 1, Name: "kraken"}
+			mockOctoStore.FetchByNameOutput.Err <- nil
+
+			mockGarbanzoStore.FetchByAPIUUIDAndOctoNameOutput.Garbanzo <- data.Garbanzo{Id: 43, OctoId: 1, Version: 3}
+			mockGarbanzoStore.FetchByAPIUUIDAndOctoNameOutput.Err <- nil
+
+			mockTx.RollbackOutput.Err <- nil
+
+			_, err := service.MoveByAPIUUIDAndOctoName(ctx, apiUUID, "kraken", "cthulhu", 42, 3)
+			Expect(err).To(Equal(persistence.ErrVersionMismatch))
+
+			Expect(mockGarbanzoStore.MoveByIdCalled).To(BeEmpty())
+			Expect(mockTx.RollbackCalled).To(HaveLen(1))
+		})
+
+		It("rolls back and returns a version mismatch when moving a modified garbanzo to the same octo", func() {
+			mockDB.BeginTxOutput.Database <- mockTx
+			mockDB.BeginTxOutput.Err <- nil
+
+			mockOctoStore.FetchByNameOutput.Octo <- data.Octo{Id: 1, Name: "kraken"}
+			mockOctoStore.FetchByNameOutput.Err <- nil
+
+			mockGarbanzoStore.FetchByAPIUUIDAndOctoNameOutput.Garbanzo <- data.Garbanzo{Id: 42, OctoId: 1, Version: 4}
+			mockGarbanzoStore.FetchByAPIUUIDAndOctoNameOutput.Err <- nil
+
+			mockTx.RollbackOutput.Err <- nil
+
+			_, err := service.MoveByAPIUUIDAndOctoName(ctx, apiUUID, "kraken", "kraken", 42, 3)
+			Expect(err).To(Equal(persistence.ErrVersionMismatch))
+
+			Expect(mockTx.RollbackCalled).To(HaveLen(1))
+		})
+	})
+
+	Describe("DeleteByAPIUUIDAndOctoName", func() {
+		var apiUUID uuid.UUID
+
+		BeforeEach(func() {
+			apiUUID = uuid.NewV4()
+			mockDB.BeginTxOutput.Database <- mockTx
+			mockDB.BeginTxOutput.Err <- nil
+			mockOctoStore.FetchByNameOutput.Octo <- data.Octo{Id: 77, Name: "my-octo"}
+			mockOctoStore.FetchByNameOutput.Err <- nil
+		})
+
+		It("deletes a garbanzo by API UUID", func() {
+			mockGarbanzoStore.FetchByAPIUUIDAndOctoNameOutput.Garbanzo <- data.Garbanzo{
+				Id:           42,
+				APIUUID:      apiUUID,
+				GarbanzoType: desi,
+				OctoId:       77,
+				DiameterMM:   0.1,
+				Version:      3,
+			}
+			mockGarbanzoStore.FetchByAPIUUIDAndOctoNameOutput.Err <- nil
+			mockGarbanzoStore.DeleteByAPIUUIDAndOctoNameOutput.Err <- nil
+			mockAuditStore.CreateOutput.EventId <- 7
+			mockAuditStore.CreateOutput.Err <- nil
+			mockTx.CommitOutput.Err <- nil
+
+			err := service.DeleteByAPIUUIDAndOctoName(ctx, apiUUID, "my-octo", 42, 3)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(mockOctoStore.FetchByNameInput.Database).To(Receive(Equal(mockTx)))
+			Expect(mockOctoStore.FetchByNameInput.SelectForUpdate).To(Receive(BeTrue()))
+
+			Expect(mockGarbanzoStore.DeleteByAPIUUIDAndOctoNameCalled).To(HaveLen(1))
+			var actualDB persistence.Database
+			Expect(mockGarbanzoStore.DeleteByAPIUUIDAndOctoNameInput.Database).To(Receive(&actualDB))
+			Expect(actualDB).To(Equal(mockTx))
+			var actualCtx context.Context
+			Expect(mockGarbanzoStore.DeleteByAPIUUIDAndOctoNameInput.Ctx).To(Receive(&actualCtx))
+			Expect(actualCtx.Value(persistence.OrgContextKey)).To(Equal("my-org"))
+			var actualAPIUUID uuid.UUID
+			Expect(mockGarbanzoStore.DeleteByAPIUUIDAndOctoNameInput.ApiUUID).To(Receive(&actualAPIUUID))
+			Expect(actualAPIUUID).To(Equal(apiUUID))
+			var actualOctoName string
+			Expect(mockGarbanzoStore.DeleteByAPIUUIDAndOctoNameInput.OctoName).To(Receive(&actualOctoName))
+			Expect(actualOctoName).To(Equal("my-octo"))
+			var actualVersion int
+			Expect(mockGarbanzoStore.DeleteByAPIUUIDAndOctoNameInput.Version).To(Receive(&actualVersion))
+			Expect(actualVersion).To(Equal(3))
+
+			Expect(mockAuditStore.CreateInput.Database).To(Receive(Equal(mockTx)))
+			var event data.AuditEvent
+			Expect(mockAuditStore.CreateInput.Event).To(Receive(&event))
+			Expect(event.Action).To(Equal(services.AuditDelete))
+			Expect(event.Resource).To(Equal("octos/my-octo/garbanzos/" + apiUUID.String()))
+			Expect(event.Before).To(MatchJSON(fmt.Sprintf(`{
+				"api-uuid":    "%s",
+				"octo":        "my-octo",
+				"type":        "DESI",
+				"diameter-mm": 0.1,
+				"version":     3
+			}`, apiUUID)))
+			Expect(event.After).To(BeNil())
+			Expect(mockTx.CommitCalled).To(HaveLen(1))
+		})
+
+		It("rolls back and returns the store's error", func() {
+			err := errors.New("some error")
+			mockGarbanzoStore.FetchByAPIUUIDAndOctoNameOutput.Garbanzo <- data.Garbanzo{Id: 42}
+			mockGarbanzoStore.FetchByAPIUUIDAndOctoNameOutput.Err <- nil
+			mockGarbanzoStore.DeleteByAPIUUIDAndOctoNameOutput.Err <- err
+			mockTx.RollbackOutput.Err <- nil
+
+			actualErr := service.DeleteByAPIUUIDAndOctoName(ctx, apiUUID, "my-octo", 42, 3)
+			Expect(actualErr).To(Equal(err))
+
+			Expect(mockAuditStore.CreateCalled).To(BeEmpty())
+			Expect(mockTx.RollbackCalled).To(HaveLen(1))
+		})
+
+		It("returns a version mismatch if the garbanzo isn't the row of the id", func() {
+			// The garbanzo was deleted and created again with the same API UUID
+			mockGarbanzoStore.FetchByAPIUUIDAndOctoNameOutput.Garbanzo <- data.Garbanzo{Id: 43, Version: 3}
+			mockGarbanzoStore.FetchByAPIUUIDAndOctoNameOutput.Err <- nil
+			mockTx.RollbackOutput.Err <- nil
+
+			err := service.DeleteByAPIUUIDAndOctoName(ctx, apiUUID, "my-octo", 42, 3)
+			Expect(err).To(Equal(persistence.ErrVersionMismatch))
+
+			Expect(mockGarbanzoStore.DeleteByAPIUUIDAndOctoNameCalled).To(BeEmpty())
+			Expect(mockTx.RollbackCalled).To(HaveLen(1))
+		})
+
+		It("returns not found for an unversioned delete of a missing garbanzo", func() {
+			mockGarbanzoStore.FetchByAPIUUIDAndOctoNameOutput.Garbanzo <- data.Garbanzo{}
+			mockGarbanzoStore.FetchByAPIUUIDAndOctoNameOutput.Err <- persistence.ErrNotFound
+			mockTx.RollbackOutput.Err <- nil
+
+			err := service.DeleteByAPIUUIDAndOctoName(ctx, apiUUID, "my-octo", 0, 0)
+			Expect(err).To(Equal(persistence.ErrNotFound))
+
+			Expect(mockGarbanzoStore.DeleteByAPIUUIDAndOctoNameCalled).To(BeEmpty())
+		})
+
+		It("rolls back the deletion when the audit event can't be recorded", func() {
+			mockGarbanzoStore.FetchByAPIUUIDAndOctoNameOutput.Garbanzo <- data.Garbanzo{Id: 42}
+			mockGarbanzoStore.FetchByAPIUUIDAndOctoNameOutput.Err <- nil
+			mockGarbanzoStore.DeleteByAPIUUIDAndOctoNameOutput.Err <- nil
+			mockAuditStore.CreateOutput.EventId <- 0
+			mockAuditStore.CreateOutput.Err <- errors.New("don't bother")
+			mockTx.RollbackOutput.Err <- nil
+
+			err := service.DeleteByAPIUUIDAndOctoName(ctx, apiUUID, "my-octo", 0, 0)
+			Expect(err).To(MatchError("don't bother"))
+
+			Expect(mockTx.RollbackCalled).To(HaveLen(1))
+			Expect(mockTx.CommitCalled).To(BeEmpty())
+		})
 	})
 })
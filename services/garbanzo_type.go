@@ -0,0 +1,160 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/myshkin5/effective-octo-garbanzo/persistence"
+	"github.com/myshkin5/effective-octo-garbanzo/persistence/data"
+)
+
+const maxGarbanzoTypeNameLength = 20
+
+type GarbanzoTypeStore interface {
+	FetchAll(ctx context.Context, database persistence.Database) (garbanzoTypes []data.GarbanzoType, err error)
+	Create(ctx context.Context, database persistence.Database, garbanzoType data.GarbanzoType) (garbanzoTypeId int, err error)
+}
+
+// GarbanzoTypeService caches the garbanzo types as they rarely change. The
+// cache is reloaded whenever a name isn't found in it so types added by other
+// instances are picked up without a restart, although no more often than
+// minReloadInterval so unknown names can't force a reload on every request.
+type GarbanzoTypeService struct {
+	garbanzoTypeStore GarbanzoTypeStore
+	database          persistence.Database
+	minReloadInterval time.Duration
+
+	mutex         sync.RWMutex
+	garbanzoTypes []data.GarbanzoType
+
+	// reloadLock serializes reloads and guards lastReload
+	reloadLock sync.Mutex
+	lastReload time.Time
+}
+
+func NewGarbanzoTypeService(garbanzoTypeStore GarbanzoTypeStore, database persistence.Database, minReloadInterval time.Duration) *GarbanzoTypeService {
+	return &GarbanzoTypeService{
+		garbanzoTypeStore: garbanzoTypeStore,
+		database:          database,
+		minReloadInterval: minReloadInterval,
+	}
+}
+
+// Load replaces the cached garbanzo types with those in the database.
+func (s *GarbanzoTypeService) Load(ctx context.Context) error {
+	s.reloadLock.Lock()
+	defer s.reloadLock.Unlock()
+
+	return s.load(ctx)
+}
+
+// load must be called with reloadLock held.
+func (s *GarbanzoTypeService) load(ctx context.Context) error {
+	s.lastReload = time.Now()
+
+	garbanzoTypes, err := s.garbanzoTypeStore.FetchAll(ctx, s.database)
+	if err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.garbanzoTypes = garbanzoTypes
+
+	return nil
+}
+
+func (s *GarbanzoTypeService) FetchAll(ctx context.Context) ([]data.GarbanzoType, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	garbanzoTypes := make([]data.GarbanzoType, len(s.garbanzoTypes))
+	copy(garbanzoTypes, s.garbanzoTypes)
+
+	return garbanzoTypes, nil
+}
+
+func (s *GarbanzoTypeService) FetchByName(ctx context.Context, name string) (data.GarbanzoType, error) {
+	garbanzoType, ok := s.cached(name)
+	if ok {
+		return garbanzoType, nil
+	}
+
+	s.reloadLock.Lock()
+	defer s.reloadLock.Unlock()
+
+	// Another request may have reloaded the cache while this one was waiting
+	garbanzoType, ok = s.cached(name)
+	if ok {
+		return garbanzoType, nil
+	}
+	if time.Since(s.lastReload) < s.minReloadInterval {
+		return data.GarbanzoType{}, persistence.ErrNotFound
+	}
+
+	err := s.load(ctx)
+	if err != nil {
+		return data.GarbanzoType{}, err
+	}
+
+	garbanzoType, ok = s.cached(name)
+	if !ok {
+		return data.GarbanzoType{}, persistence.ErrNotFound
+	}
+
+	return garbanzoType, nil
+}
+
+func (s *GarbanzoTypeService) cached(name string) (data.GarbanzoType, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	for _, garbanzoType := range s.garbanzoTypes {
+		if garbanzoType.Name == name {
+			return garbanzoType, true
+		}
+	}
+
+	return data.GarbanzoType{}, false
+}
+
+func (s *GarbanzoTypeService) Create(ctx context.Context, garbanzoType data.GarbanzoType) (data.GarbanzoType, error) {
+	err := s.validate(garbanzoType.Name)
+	if err != nil {
+		return data.GarbanzoType{}, err
+	}
+
+	garbanzoType.Id, err = s.garbanzoTypeStore.Create(ctx, s.database, garbanzoType)
+	if err != nil {
+		return data.GarbanzoType{}, err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.garbanzoTypes = append(s.garbanzoTypes, garbanzoType)
+
+	return garbanzoType, nil
+}
+
+func (s *GarbanzoTypeService) validate(name string) error {
+	errors := make(map[string][]string)
+	if len(name) == 0 {
+		errors["Name"] = append(errors["Name"], "must be present")
+	}
+	if len(name) > maxGarbanzoTypeNameLength {
+		errors["Name"] = append(errors["Name"], fmt.Sprintf("must be at most %d characters", maxGarbanzoTypeNameLength))
+	}
+	validName := regexp.MustCompile(`^[A-Z][A-Z0-9_]*$`)
+	if !validName.MatchString(name) {
+		errors["Name"] = append(errors["Name"], fmt.Sprintf("must match regular expression '%s'", validName.String()))
+	}
+
+	if len(errors) > 0 {
+		return NewValidationError(errors)
+	}
+
+	return nil
+}
@@ -0,0 +1,175 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/myshkin5/effective-octo-garbanzo/persistence"
+	"github.com/myshkin5/effective-octo-garbanzo/persistence/data"
+	"github.com/myshkin5/effective-octo-garbanzo/services"
+)
+
+var _ = Describe("GarbanzoType", func() {
+	var (
+		mockGarbanzoTypeStore *mockGarbanzoTypeStore
+		mockDB                *mockDatabase
+		service               *services.GarbanzoTypeService
+		ctx                   context.Context
+	)
+
+	BeforeEach(func() {
+		mockGarbanzoTypeStore = newMockGarbanzoTypeStore()
+		mockDB = newMockDatabase()
+		ctx = context.Background()
+
+		service = services.NewGarbanzoTypeService(mockGarbanzoTypeStore, mockDB, 0)
+	})
+
+	Describe("Load", func() {
+		It("caches the garbanzo types", func() {
+			mockGarbanzoTypeStore.FetchAllOutput.GarbanzoTypes <- []data.GarbanzoType{desi, kabuli}
+			mockGarbanzoTypeStore.FetchAllOutput.Err <- nil
+
+			Expect(service.Load(ctx)).To(Succeed())
+
+			Expect(mockGarbanzoTypeStore.FetchAllInput.Database).To(Receive(Equal(mockDB)))
+
+			garbanzoTypes, err := service.FetchAll(ctx)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(garbanzoTypes).To(Equal([]data.GarbanzoType{desi, kabuli}))
+			Expect(mockGarbanzoTypeStore.FetchAllCalled).To(HaveLen(1))
+		})
+
+		It("returns the store's error", func() {
+			mockGarbanzoTypeStore.FetchAllOutput.GarbanzoTypes <- nil
+			mockGarbanzoTypeStore.FetchAllOutput.Err <- errors.New("don't bother")
+
+			Expect(service.Load(ctx)).To(MatchError("don't bother"))
+		})
+	})
+
+	Describe("FetchByName", func() {
+		BeforeEach(func() {
+			mockGarbanzoTypeStore.FetchAllOutput.GarbanzoTypes <- []data.GarbanzoType{desi, kabuli}
+			mockGarbanzoTypeStore.FetchAllOutput.Err <- nil
+
+			Expect(service.Load(ctx)).To(Succeed())
+		})
+
+		It("fetches a cached garbanzo type without going to the store", func() {
+			garbanzoType, err := service.FetchByName(ctx, "KABULI")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(garbanzoType).To(Equal(kabuli))
+
+			Expect(mockGarbanzoTypeStore.FetchAllCalled).To(HaveLen(1))
+		})
+
+		It("reloads the cache to find a garbanzo type added elsewhere", func() {
+			bambai := data.GarbanzoType{Id: 1003, Name: "BAMBAI"}
+			mockGarbanzoTypeStore.FetchAllOutput.GarbanzoTypes <- []data.GarbanzoType{desi, kabuli, bambai}
+			mockGarbanzoTypeStore.FetchAllOutput.Err <- nil
+
+			garbanzoType, err := service.FetchByName(ctx, "BAMBAI")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(garbanzoType).To(Equal(bambai))
+
+			Expect(mockGarbanzoTypeStore.FetchAllCalled).To(HaveLen(2))
+		})
+
+		It("returns not found when the garbanzo type isn't found after reloading", func() {
+			mockGarbanzoTypeStore.FetchAllOutput.GarbanzoTypes <- []data.GarbanzoType{desi, kabuli}
+			mockGarbanzoTypeStore.FetchAllOutput.Err <- nil
+
+			_, err := service.FetchByName(ctx, "BOGUS")
+			Expect(err).To(Equal(persistence.ErrNotFound))
+		})
+
+		It("returns the store's error when reloading fails", func() {
+			mockGarbanzoTypeStore.FetchAllOutput.GarbanzoTypes <- nil
+			mockGarbanzoTypeStore.FetchAllOutput.Err <- errors.New("don't bother")
+
+			_, err := service.FetchByName(ctx, "BOGUS")
+			Expect(err).To(MatchError("don't bother"))
+		})
+
+		Context("reloaded recently", func() {
+			BeforeEach(func() {
+				service = services.NewGarbanzoTypeService(mockGarbanzoTypeStore, mockDB, time.Hour)
+				mockGarbanzoTypeStore.FetchAllOutput.GarbanzoTypes <- []data.GarbanzoType{desi, kabuli}
+				mockGarbanzoTypeStore.FetchAllOutput.Err <- nil
+
+				Expect(service.Load(ctx)).To(Succeed())
+			})
+
+			It("returns not found without reloading the cache", func() {
+				_, err := service.FetchByName(ctx, "BOGUS")
+				Expect(err).To(Equal(persistence.ErrNotFound))
+				_, err = service.FetchByName(ctx, "BOGUS")
+				Expect(err).To(Equal(persistence.ErrNotFound))
+
+				Expect(mockGarbanzoTypeStore.FetchAllCalled).To(HaveLen(2))
+			})
+
+			It("still fetches cached garbanzo types", func() {
+				garbanzoType, err := service.FetchByName(ctx, "DESI")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(garbanzoType).To(Equal(desi))
+			})
+		})
+	})
+
+	Describe("Create", func() {
+		It("creates a garbanzo type and caches it", func() {
+			mockGarbanzoTypeStore.CreateOutput.GarbanzoTypeId <- 1003
+			mockGarbanzoTypeStore.CreateOutput.Err <- nil
+
+			garbanzoType, err := service.Create(ctx, data.GarbanzoType{Name: "BAMBAI"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(garbanzoType).To(Equal(data.GarbanzoType{Id: 1003, Name: "BAMBAI"}))
+
+			Expect(mockGarbanzoTypeStore.CreateInput.Database).To(Receive(Equal(mockDB)))
+			Expect(mockGarbanzoTypeStore.CreateInput.GarbanzoType).To(Receive(Equal(data.GarbanzoType{Name: "BAMBAI"})))
+
+			garbanzoType, err = service.FetchByName(ctx, "BAMBAI")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(garbanzoType).To(Equal(data.GarbanzoType{Id: 1003, Name: "BAMBAI"}))
+			Expect(mockGarbanzoTypeStore.FetchAllCalled).To(BeEmpty())
+		})
+
+		It("returns the store's error", func() {
+			mockGarbanzoTypeStore.CreateOutput.GarbanzoTypeId <- 0
+			mockGarbanzoTypeStore.CreateOutput.Err <- persistence.ErrDuplicate
+
+			_, err := service.Create(ctx, data.GarbanzoType{Name: "DESI"})
+			Expect(err).To(Equal(persistence.ErrDuplicate))
+		})
+
+		It("returns a validation error for an empty name", func() {
+			_, err := service.Create(ctx, data.GarbanzoType{})
+			Expect(err).To(Equal(services.NewValidationError(map[string][]string{"Name": {
+				"must be present",
+				"must match regular expression '^[A-Z][A-Z0-9_]*$'",
+			}})))
+			Expect(mockGarbanzoTypeStore.CreateCalled).To(BeEmpty())
+		})
+
+		It("returns a validation error for a name which is too long", func() {
+			_, err := service.Create(ctx, data.GarbanzoType{Name: strings.Repeat("G", 21)})
+			Expect(err).To(Equal(services.NewValidationError(map[string][]string{"Name": {
+				"must be at most 20 characters",
+			}})))
+		})
+
+		It("returns a validation error for a name which isn't upper case", func() {
+			_, err := service.Create(ctx, data.GarbanzoType{Name: "green"})
+			Expect(err).To(Equal(services.NewValidationError(map[string][]string{"Name": {
+				"must match regular expression '^[A-Z][A-Z0-9_]*$'",
+			}})))
+		})
+	})
+})
@@ -15,17 +15,215 @@ import (
 	"github.com/satori/go.uuid"
 )
 
+type mockAPIKeyStore struct {
+	FetchByOrgNameCalled chan bool
+	FetchByOrgNameInput  struct {
+		Ctx      chan context.Context
+		Database chan persistence.Database
+		OrgName  chan string
+	}
+	FetchByOrgNameOutput struct {
+		ApiKeys chan []data.APIKey
+		Err     chan error
+	}
+	FetchByHashCalled chan bool
+	FetchByHashInput  struct {
+		Ctx      chan context.Context
+		Database chan persistence.Database
+		Hash     chan string
+	}
+	FetchByHashOutput struct {
+		ApiKey chan data.APIKey
+		Err    chan error
+	}
+	CreateCalled chan bool
+	CreateInput  struct {
+		Ctx      chan context.Context
+		Database chan persistence.Database
+		ApiKey   chan data.APIKey
+	}
+	CreateOutput struct {
+		ApiKeyId chan int
+		Err      chan error
+	}
+	UpdateLastUsedCalled chan bool
+	UpdateLastUsedInput  struct {
+		Ctx        chan context.Context
+		Database   chan persistence.Database
+		Id         chan int
+		LastUsedAt chan time.Time
+	}
+	UpdateLastUsedOutput struct {
+		Err chan error
+	}
+	DeleteByPrefixAndOrgNameCalled chan bool
+	DeleteByPrefixAndOrgNameInput  struct {
+		Ctx      chan context.Context
+		Database chan persistence.Database
+		Prefix   chan string
+		OrgName  chan string
+	}
+	DeleteByPrefixAndOrgNameOutput struct {
+		Err chan error
+	}
+}
+
+func newMockAPIKeyStore() *mockAPIKeyStore {
+	m := &mockAPIKeyStore{}
+	m.FetchByOrgNameCalled = make(chan bool, 100)
+	m.FetchByOrgNameInput.Ctx = make(chan context.Context, 100)
+	m.FetchByOrgNameInput.Database = make(chan persistence.Database, 100)
+	m.FetchByOrgNameInput.OrgName = make(chan string, 100)
+	m.FetchByOrgNameOutput.ApiKeys = make(chan []data.APIKey, 100)
+	m.FetchByOrgNameOutput.Err = make(chan error, 100)
+	m.FetchByHashCalled = make(chan bool, 100)
+	m.FetchByHashInput.Ctx = make(chan context.Context, 100)
+	m.FetchByHashInput.Database = make(chan persistence.Database, 100)
+	m.FetchByHashInput.Hash = make(chan string, 100)
+	m.FetchByHashOutput.ApiKey = make(chan data.APIKey, 100)
+	m.FetchByHashOutput.Err = make(chan error, 100)
+	m.CreateCalled = make(chan bool, 100)
+	m.CreateInput.Ctx = make(chan context.Context, 100)
+	m.CreateInput.Database = make(chan persistence.Database, 100)
+	m.CreateInput.ApiKey = make(chan data.APIKey, 100)
+	m.CreateOutput.ApiKeyId = make(chan int, 100)
+	m.CreateOutput.Err = make(chan error, 100)
+	m.UpdateLastUsedCalled = make(chan bool, 100)
+	m.UpdateLastUsedInput.Ctx = make(chan context.Context, 100)
+	m.UpdateLastUsedInput.Database = make(chan persistence.Database, 100)
+	m.UpdateLastUsedInput.Id = make(chan int, 100)
+	m.UpdateLastUsedInput.LastUsedAt = make(chan time.Time, 100)
+	m.UpdateLastUsedOutput.Err = make(chan error, 100)
+	m.DeleteByPrefixAndOrgNameCalled = make(chan bool, 100)
+	m.DeleteByPrefixAndOrgNameInput.Ctx = make(chan context.Context, 100)
+	m.DeleteByPrefixAndOrgNameInput.Database = make(chan persistence.Database, 100)
+	m.DeleteByPrefixAndOrgNameInput.Prefix = make(chan string, 100)
+	m.DeleteByPrefixAndOrgNameInput.OrgName = make(chan string, 100)
+	m.DeleteByPrefixAndOrgNameOutput.Err = make(chan error, 100)
+	return m
+}
+func (m *mockAPIKeyStore) FetchByOrgName(ctx context.Context, database persistence.Database, orgName string) (apiKeys []data.APIKey, err error) {
+	m.FetchByOrgNameCalled <- true
+	m.FetchByOrgNameInput.Ctx <- ctx
+	m.FetchByOrgNameInput.Database <- database
+	m.FetchByOrgNameInput.OrgName <- orgName
+	return <-m.FetchByOrgNameOutput.ApiKeys, <-m.FetchByOrgNameOutput.Err
+}
+func (m *mockAPIKeyStore) FetchByHash(ctx context.Context, database persistence.Database, hash string) (apiKey data.APIKey, err error) {
+	m.FetchByHashCalled <- true
+	m.FetchByHashInput.Ctx <- ctx
+	m.FetchByHashInput.Database <- database
+	m.FetchByHashInput.Hash <- hash
+	return <-m.FetchByHashOutput.ApiKey, <-m.FetchByHashOutput.Err
+}
+func (m *mockAPIKeyStore) Create(ctx context.Context, database persistence.Database, apiKey data.APIKey) (apiKeyId int, err error) {
+	m.CreateCalled <- true
+	m.CreateInput.Ctx <- ctx
+	m.CreateInput.Database <- database
+	m.CreateInput.ApiKey <- apiKey
+	return <-m.CreateOutput.ApiKeyId, <-m.CreateOutput.Err
+}
+func (m *mockAPIKeyStore) UpdateLastUsed(ctx context.Context, database persistence.Database, id int, lastUsedAt time.Time) (err error) {
+	m.UpdateLastUsedCalled <- true
+	m.UpdateLastUsedInput.Ctx <- ctx
+	m.UpdateLastUsedInput.Database <- database
+	m.UpdateLastUsedInput.Id <- id
+	m.UpdateLastUsedInput.LastUsedAt <- lastUsedAt
+	return <-m.UpdateLastUsedOutput.Err
+}
+func (m *mockAPIKeyStore) DeleteByPrefixAndOrgName(ctx context.Context, database persistence.Database, prefix, orgName string) (err error) {
+	m.DeleteByPrefixAndOrgNameCalled <- true
+	m.DeleteByPrefixAndOrgNameInput.Ctx <- ctx
+	m.DeleteByPrefixAndOrgNameInput.Database <- database
+	m.DeleteByPrefixAndOrgNameInput.Prefix <- prefix
+	m.DeleteByPrefixAndOrgNameInput.OrgName <- orgName
+	return <-m.DeleteByPrefixAndOrgNameOutput.Err
+}
+
+type mockAuditEventStore struct {
+	FetchAllCalled chan bool
+	FetchAllInput  struct {
+		Ctx      chan context.Context
+		Database chan persistence.Database
+		Filter   chan persistence.AuditFilter
+		Page     chan persistence.Page
+	}
+	FetchAllOutput struct {
+		Events chan []data.AuditEvent
+		More   chan bool
+		Err    chan error
+	}
+	CreateCalled chan bool
+	CreateInput  struct {
+		Ctx      chan context.Context
+		Database chan persistence.Database
+		Event    chan data.AuditEvent
+	}
+	CreateOutput struct {
+		EventId chan int
+		Err     chan error
+	}
+}
+
+func newMockAuditEventStore() *mockAuditEventStore {
+	m := &mockAuditEventStore{}
+	m.FetchAllCalled = make(chan bool, 100)
+	m.FetchAllInput.Ctx = make(chan context.Context, 100)
+	m.FetchAllInput.Database = make(chan persistence.Database, 100)
+	m.FetchAllInput.Filter = make(chan persistence.AuditFilter, 100)
+	m.FetchAllInput.Page = make(chan persistence.Page, 100)
+	m.FetchAllOutput.Events = make(chan []data.AuditEvent, 100)
+	m.FetchAllOutput.More = make(chan bool, 100)
+	m.FetchAllOutput.Err = make(chan error, 100)
+	m.CreateCalled = make(chan bool, 100)
+	m.CreateInput.Ctx = make(chan context.Context, 100)
+	m.CreateInput.Database = make(chan persistence.Database, 100)
+	m.CreateInput.Event = make(chan data.AuditEvent, 100)
+	m.CreateOutput.EventId = make(chan int, 100)
+	m.CreateOutput.Err = make(chan error, 100)
+	return m
+}
+func (m *mockAuditEventStore) FetchAll(ctx context.Context, database persistence.Database, filter persistence.AuditFilter, page persistence.Page) (events []data.AuditEvent, more bool, err error) {
+	m.FetchAllCalled <- true
+	m.FetchAllInput.Ctx <- ctx
+	m.FetchAllInput.Database <- database
+	m.FetchAllInput.Filter <- filter
+	m.FetchAllInput.Page <- page
+	return <-m.FetchAllOutput.Events, <-m.FetchAllOutput.More, <-m.FetchAllOutput.Err
+}
+func (m *mockAuditEventStore) Create(ctx context.Context, database persistence.Database, event data.AuditEvent) (eventId int, err error) {
+	m.CreateCalled <- true
+	m.CreateInput.Ctx <- ctx
+	m.CreateInput.Database <- database
+	m.CreateInput.Event <- event
+	return <-m.CreateOutput.EventId, <-m.CreateOutput.Err
+}
+
 type mockGarbanzoStore struct {
 	FetchByOctoNameCalled chan bool
 	FetchByOctoNameInput  struct {
 		Ctx      chan context.Context
 		Database chan persistence.Database
 		OctoName chan string
+		Filter   chan persistence.GarbanzoFilter
+		Page     chan persistence.Page
 	}
 	FetchByOctoNameOutput struct {
 		Garbanzos chan []data.Garbanzo
+		More      chan bool
 		Err       chan error
 	}
+	FetchByAPIUUIDCalled chan bool
+	FetchByAPIUUIDInput  struct {
+		Ctx      chan context.Context
+		Database chan persistence.Database
+		ApiUUID  chan uuid.UUID
+	}
+	FetchByAPIUUIDOutput struct {
+		Garbanzo chan data.Garbanzo
+		OctoName chan string
+		Err      chan error
+	}
 	FetchByAPIUUIDAndOctoNameCalled chan bool
 	FetchByAPIUUIDAndOctoNameInput  struct {
 		Ctx      chan context.Context
@@ -47,12 +245,46 @@ type mockGarbanzoStore struct {
 		GarbanzoId chan int
 		Err        chan error
 	}
+	CreateBatchCalled chan bool
+	CreateBatchInput  struct {
+		Ctx       chan context.Context
+		Database  chan persistence.Database
+		Garbanzos chan []data.Garbanzo
+	}
+	CreateBatchOutput struct {
+		GarbanzoIds chan []int
+		Err         chan error
+	}
+	UpdateByAPIUUIDAndOctoNameCalled chan bool
+	UpdateByAPIUUIDAndOctoNameInput  struct {
+		Ctx      chan context.Context
+		Database chan persistence.Database
+		Garbanzo chan data.Garbanzo
+		OctoName chan string
+	}
+	UpdateByAPIUUIDAndOctoNameOutput struct {
+		Version chan int
+		Err     chan error
+	}
+	MoveByIdCalled chan bool
+	MoveByIdInput  struct {
+		Ctx      chan context.Context
+		Database chan persistence.Database
+		Id       chan int
+		OctoId   chan int
+		Version  chan int
+	}
+	MoveByIdOutput struct {
+		NewVersion chan int
+		Err        chan error
+	}
 	DeleteByAPIUUIDAndOctoNameCalled chan bool
 	DeleteByAPIUUIDAndOctoNameInput  struct {
 		Ctx      chan context.Context
 		Database chan persistence.Database
 		ApiUUID  chan uuid.UUID
 		OctoName chan string
+		Version  chan int
 	}
 	DeleteByAPIUUIDAndOctoNameOutput struct {
 		Err chan error
@@ -74,8 +306,18 @@ func newMockGarbanzoStore() *mockGarbanzoStore {
 	m.FetchByOctoNameInput.Ctx = make(chan context.Context, 100)
 	m.FetchByOctoNameInput.Database = make(chan persistence.Database, 100)
 	m.FetchByOctoNameInput.OctoName = make(chan string, 100)
+	m.FetchByOctoNameInput.Filter = make(chan persistence.GarbanzoFilter, 100)
+	m.FetchByOctoNameInput.Page = make(chan persistence.Page, 100)
 	m.FetchByOctoNameOutput.Garbanzos = make(chan []data.Garbanzo, 100)
+	m.FetchByOctoNameOutput.More = make(chan bool, 100)
 	m.FetchByOctoNameOutput.Err = make(chan error, 100)
+	m.FetchByAPIUUIDCalled = make(chan bool, 100)
+	m.FetchByAPIUUIDInput.Ctx = make(chan context.Context, 100)
+	m.FetchByAPIUUIDInput.Database = make(chan persistence.Database, 100)
+	m.FetchByAPIUUIDInput.ApiUUID = make(chan uuid.UUID, 100)
+	m.FetchByAPIUUIDOutput.Garbanzo = make(chan data.Garbanzo, 100)
+	m.FetchByAPIUUIDOutput.OctoName = make(chan string, 100)
+	m.FetchByAPIUUIDOutput.Err = make(chan error, 100)
 	m.FetchByAPIUUIDAndOctoNameCalled = make(chan bool, 100)
 	m.FetchByAPIUUIDAndOctoNameInput.Ctx = make(chan context.Context, 100)
 	m.FetchByAPIUUIDAndOctoNameInput.Database = make(chan persistence.Database, 100)
@@ -89,11 +331,33 @@ func newMockGarbanzoStore() *mockGarbanzoStore {
 	m.CreateInput.Garbanzo = make(chan data.Garbanzo, 100)
 	m.CreateOutput.GarbanzoId = make(chan int, 100)
 	m.CreateOutput.Err = make(chan error, 100)
+	m.CreateBatchCalled = make(chan bool, 100)
+	m.CreateBatchInput.Ctx = make(chan context.Context, 100)
+	m.CreateBatchInput.Database = make(chan persistence.Database, 100)
+	m.CreateBatchInput.Garbanzos = make(chan []data.Garbanzo, 100)
+	m.CreateBatchOutput.GarbanzoIds = make(chan []int, 100)
+	m.CreateBatchOutput.Err = make(chan error, 100)
+	m.UpdateByAPIUUIDAndOctoNameCalled = make(chan bool, 100)
+	m.UpdateByAPIUUIDAndOctoNameInput.Ctx = make(chan context.Context, 100)
+	m.UpdateByAPIUUIDAndOctoNameInput.Database = make(chan persistence.Database, 100)
+	m.UpdateByAPIUUIDAndOctoNameInput.Garbanzo = make(chan data.Garbanzo, 100)
+	m.UpdateByAPIUUIDAndOctoNameInput.OctoName = make(chan string, 100)
+	m.UpdateByAPIUUIDAndOctoNameOutput.Version = make(chan int, 100)
+	m.UpdateByAPIUUIDAndOctoNameOutput.Err = make(chan error, 100)
+	m.MoveByIdCalled = make(chan bool, 100)
+	m.MoveByIdInput.Ctx = make(chan context.Context, 100)
+	m.MoveByIdInput.Database = make(chan persistence.Database, 100)
+	m.MoveByIdInput.Id = make(chan int, 100)
+	m.MoveByIdInput.OctoId = make(chan int, 100)
+	m.MoveByIdInput.Version = make(chan int, 100)
+	m.MoveByIdOutput.NewVersion = make(chan int, 100)
+	m.MoveByIdOutput.Err = make(chan error, 100)
 	m.DeleteByAPIUUIDAndOctoNameCalled = make(chan bool, 100)
 	m.DeleteByAPIUUIDAndOctoNameInput.Ctx = make(chan context.Context, 100)
 	m.DeleteByAPIUUIDAndOctoNameInput.Database = make(chan persistence.Database, 100)
 	m.DeleteByAPIUUIDAndOctoNameInput.ApiUUID = make(chan uuid.UUID, 100)
 	m.DeleteByAPIUUIDAndOctoNameInput.OctoName = make(chan string, 100)
+	m.DeleteByAPIUUIDAndOctoNameInput.Version = make(chan int, 100)
 	m.DeleteByAPIUUIDAndOctoNameOutput.Err = make(chan error, 100)
 	m.DeleteByOctoIdCalled = make(chan bool, 100)
 	m.DeleteByOctoIdInput.Ctx = make(chan context.Context, 100)
@@ -102,12 +366,21 @@ func newMockGarbanzoStore() *mockGarbanzoStore {
 	m.DeleteByOctoIdOutput.Err = make(chan error, 100)
 	return m
 }
-func (m *mockGarbanzoStore) FetchByOctoName(ctx context.Context, database persistence.Database, octoName string) (garbanzos []data.Garbanzo, err error) {
+func (m *mockGarbanzoStore) FetchByOctoName(ctx context.Context, database persistence.Database, octoName string, filter persistence.GarbanzoFilter, page persistence.Page) (garbanzos []data.Garbanzo, more bool, err error) {
 	m.FetchByOctoNameCalled <- true
 	m.FetchByOctoNameInput.Ctx <- ctx
 	m.FetchByOctoNameInput.Database <- database
 	m.FetchByOctoNameInput.OctoName <- octoName
-	return <-m.FetchByOctoNameOutput.Garbanzos, <-m.FetchByOctoNameOutput.Err
+	m.FetchByOctoNameInput.Filter <- filter
+	m.FetchByOctoNameInput.Page <- page
+	return <-m.FetchByOctoNameOutput.Garbanzos, <-m.FetchByOctoNameOutput.More, <-m.FetchByOctoNameOutput.Err
+}
+func (m *mockGarbanzoStore) FetchByAPIUUID(ctx context.Context, database persistence.Database, apiUUID uuid.UUID) (garbanzo data.Garbanzo, octoName string, err error) {
+	m.FetchByAPIUUIDCalled <- true
+	m.FetchByAPIUUIDInput.Ctx <- ctx
+	m.FetchByAPIUUIDInput.Database <- database
+	m.FetchByAPIUUIDInput.ApiUUID <- apiUUID
+	return <-m.FetchByAPIUUIDOutput.Garbanzo, <-m.FetchByAPIUUIDOutput.OctoName, <-m.FetchByAPIUUIDOutput.Err
 }
 func (m *mockGarbanzoStore) FetchByAPIUUIDAndOctoName(ctx context.Context, database persistence.Database, apiUUID uuid.UUID, octoName string) (garbanzo data.Garbanzo, err error) {
 	m.FetchByAPIUUIDAndOctoNameCalled <- true
@@ -124,12 +397,37 @@ func (m *mockGarbanzoStore) Create(ctx context.Context, database persistence.Dat
 	m.CreateInput.Garbanzo <- garbanzo
 	return <-m.CreateOutput.GarbanzoId, <-m.CreateOutput.Err
 }
-func (m *mockGarbanzoStore) DeleteByAPIUUIDAndOctoName(ctx context.Context, database persistence.Database, apiUUID uuid.UUID, octoName string) (err error) {
+func (m *mockGarbanzoStore) CreateBatch(ctx context.Context, database persistence.Database, garbanzos []data.Garbanzo) (garbanzoIds []int, err error) {
+	m.CreateBatchCalled <- true
+	m.CreateBatchInput.Ctx <- ctx
+	m.CreateBatchInput.Database <- database
+	m.CreateBatchInput.Garbanzos <- garbanzos
+	return <-m.CreateBatchOutput.GarbanzoIds, <-m.CreateBatchOutput.Err
+}
+func (m *mockGarbanzoStore) UpdateByAPIUUIDAndOctoName(ctx context.Context, database persistence.Database, garbanzo data.Garbanzo, octoName string) (version int, err error) {
+	m.UpdateByAPIUUIDAndOctoNameCalled <- true
+	m.UpdateByAPIUUIDAndOctoNameInput.Ctx <- ctx
+	m.UpdateByAPIUUIDAndOctoNameInput.Database <- database
+	m.UpdateByAPIUUIDAndOctoNameInput.Garbanzo <- garbanzo
+	m.UpdateByAPIUUIDAndOctoNameInput.OctoName <- octoName
+	return <-m.UpdateByAPIUUIDAndOctoNameOutput.Version, <-m.UpdateByAPIUUIDAndOctoNameOutput.Err
+}
+func (m *mockGarbanzoStore) MoveById(ctx context.Context, database persistence.Database, id int, octoId int, version int) (newVersion int, err error) {
+	m.MoveByIdCalled <- true
+	m.MoveByIdInput.Ctx <- ctx
+	m.MoveByIdInput.Database <- database
+	m.MoveByIdInput.Id <- id
+	m.MoveByIdInput.OctoId <- octoId
+	m.MoveByIdInput.Version <- version
+	return <-m.MoveByIdOutput.NewVersion, <-m.MoveByIdOutput.Err
+}
+func (m *mockGarbanzoStore) DeleteByAPIUUIDAndOctoName(ctx context.Context, database persistence.Database, apiUUID uuid.UUID, octoName string, version int) (err error) {
 	m.DeleteByAPIUUIDAndOctoNameCalled <- true
 	m.DeleteByAPIUUIDAndOctoNameInput.Ctx <- ctx
 	m.DeleteByAPIUUIDAndOctoNameInput.Database <- database
 	m.DeleteByAPIUUIDAndOctoNameInput.ApiUUID <- apiUUID
 	m.DeleteByAPIUUIDAndOctoNameInput.OctoName <- octoName
+	m.DeleteByAPIUUIDAndOctoNameInput.Version <- version
 	return <-m.DeleteByAPIUUIDAndOctoNameOutput.Err
 }
 func (m *mockGarbanzoStore) DeleteByOctoId(ctx context.Context, database persistence.Database, octoId int) (err error) {
@@ -140,14 +438,185 @@ func (m *mockGarbanzoStore) DeleteByOctoId(ctx context.Context, database persist
 	return <-m.DeleteByOctoIdOutput.Err
 }
 
+type mockGarbanzoTypeStore struct {
+	CreateCalled chan bool
+	CreateInput  struct {
+		Ctx          chan context.Context
+		Database     chan persistence.Database
+		GarbanzoType chan data.GarbanzoType
+	}
+	CreateOutput struct {
+		GarbanzoTypeId chan int
+		Err            chan error
+	}
+	FetchAllCalled chan bool
+	FetchAllInput  struct {
+		Ctx      chan context.Context
+		Database chan persistence.Database
+	}
+	FetchAllOutput struct {
+		GarbanzoTypes chan []data.GarbanzoType
+		Err           chan error
+	}
+}
+
+func newMockGarbanzoTypeStore() *mockGarbanzoTypeStore {
+	m := &mockGarbanzoTypeStore{}
+	m.CreateCalled = make(chan bool, 100)
+	m.CreateInput.Ctx = make(chan context.Context, 100)
+	m.CreateInput.Database = make(chan persistence.Database, 100)
+	m.CreateInput.GarbanzoType = make(chan data.GarbanzoType, 100)
+	m.CreateOutput.GarbanzoTypeId = make(chan int, 100)
+	m.CreateOutput.Err = make(chan error, 100)
+	m.FetchAllCalled = make(chan bool, 100)
+	m.FetchAllInput.Ctx = make(chan context.Context, 100)
+	m.FetchAllInput.Database = make(chan persistence.Database, 100)
+	m.FetchAllOutput.GarbanzoTypes = make(chan []data.GarbanzoType, 100)
+	m.FetchAllOutput.Err = make(chan error, 100)
+	return m
+}
+func (m *mockGarbanzoTypeStore) Create(ctx context.Context, database persistence.Database, garbanzoType data.GarbanzoType) (garbanzoTypeId int, err error) {
+	m.CreateCalled <- true
+	m.CreateInput.Ctx <- ctx
+	m.CreateInput.Database <- database
+	m.CreateInput.GarbanzoType <- garbanzoType
+	return <-m.CreateOutput.GarbanzoTypeId, <-m.CreateOutput.Err
+}
+func (m *mockGarbanzoTypeStore) FetchAll(ctx context.Context, database persistence.Database) (garbanzoTypes []data.GarbanzoType, err error) {
+	m.FetchAllCalled <- true
+	m.FetchAllInput.Ctx <- ctx
+	m.FetchAllInput.Database <- database
+	return <-m.FetchAllOutput.GarbanzoTypes, <-m.FetchAllOutput.Err
+}
+
+type mockGarbanzoTypes struct {
+	FetchAllCalled chan bool
+	FetchAllInput  struct {
+		Ctx chan context.Context
+	}
+	FetchAllOutput struct {
+		GarbanzoTypes chan []data.GarbanzoType
+		Err           chan error
+	}
+	FetchByNameCalled chan bool
+	FetchByNameInput  struct {
+		Ctx  chan context.Context
+		Name chan string
+	}
+	FetchByNameOutput struct {
+		GarbanzoType chan data.GarbanzoType
+		Err          chan error
+	}
+}
+
+func newMockGarbanzoTypes() *mockGarbanzoTypes {
+	m := &mockGarbanzoTypes{}
+	m.FetchAllCalled = make(chan bool, 100)
+	m.FetchAllInput.Ctx = make(chan context.Context, 100)
+	m.FetchAllOutput.GarbanzoTypes = make(chan []data.GarbanzoType, 100)
+	m.FetchAllOutput.Err = make(chan error, 100)
+	m.FetchByNameCalled = make(chan bool, 100)
+	m.FetchByNameInput.Ctx = make(chan context.Context, 100)
+	m.FetchByNameInput.Name = make(chan string, 100)
+	m.FetchByNameOutput.GarbanzoType = make(chan data.GarbanzoType, 100)
+	m.FetchByNameOutput.Err = make(chan error, 100)
+	return m
+}
+func (m *mockGarbanzoTypes) FetchAll(ctx context.Context) (garbanzoTypes []data.GarbanzoType, err error) {
+	m.FetchAllCalled <- true
+	m.FetchAllInput.Ctx <- ctx
+	return <-m.FetchAllOutput.GarbanzoTypes, <-m.FetchAllOutput.Err
+}
+func (m *mockGarbanzoTypes) FetchByName(ctx context.Context, name string) (garbanzoType data.GarbanzoType, err error) {
+	m.FetchByNameCalled <- true
+	m.FetchByNameInput.Ctx <- ctx
+	m.FetchByNameInput.Name <- name
+	return <-m.FetchByNameOutput.GarbanzoType, <-m.FetchByNameOutput.Err
+}
+
+type mockIdempotentRequestStore struct {
+	FetchByKeyCalled chan bool
+	FetchByKeyInput  struct {
+		Ctx      chan context.Context
+		Database chan persistence.Database
+		Key      chan string
+	}
+	FetchByKeyOutput struct {
+		Request chan data.IdempotentRequest
+		Err     chan error
+	}
+	CreateCalled chan bool
+	CreateInput  struct {
+		Ctx      chan context.Context
+		Database chan persistence.Database
+		Request  chan data.IdempotentRequest
+	}
+	CreateOutput struct {
+		Err chan error
+	}
+	DeleteExpiredCalled chan bool
+	DeleteExpiredInput  struct {
+		Ctx      chan context.Context
+		Database chan persistence.Database
+		Before   chan time.Time
+	}
+	DeleteExpiredOutput struct {
+		Err chan error
+	}
+}
+
+func newMockIdempotentRequestStore() *mockIdempotentRequestStore {
+	m := &mockIdempotentRequestStore{}
+	m.FetchByKeyCalled = make(chan bool, 100)
+	m.FetchByKeyInput.Ctx = make(chan context.Context, 100)
+	m.FetchByKeyInput.Database = make(chan persistence.Database, 100)
+	m.FetchByKeyInput.Key = make(chan string, 100)
+	m.FetchByKeyOutput.Request = make(chan data.IdempotentRequest, 100)
+	m.FetchByKeyOutput.Err = make(chan error, 100)
+	m.CreateCalled = make(chan bool, 100)
+	m.CreateInput.Ctx = make(chan context.Context, 100)
+	m.CreateInput.Database = make(chan persistence.Database, 100)
+	m.CreateInput.Request = make(chan data.IdempotentRequest, 100)
+	m.CreateOutput.Err = make(chan error, 100)
+	m.DeleteExpiredCalled = make(chan bool, 100)
+	m.DeleteExpiredInput.Ctx = make(chan context.Context, 100)
+	m.DeleteExpiredInput.Database = make(chan persistence.Database, 100)
+	m.DeleteExpiredInput.Before = make(chan time.Time, 100)
+	m.DeleteExpiredOutput.Err = make(chan error, 100)
+	return m
+}
+func (m *mockIdempotentRequestStore) FetchByKey(ctx context.Context, database persistence.Database, key string) (request data.IdempotentRequest, err error) {
+	m.FetchByKeyCalled <- true
+	m.FetchByKeyInput.Ctx <- ctx
+	m.FetchByKeyInput.Database <- database
+	m.FetchByKeyInput.Key <- key
+	return <-m.FetchByKeyOutput.Request, <-m.FetchByKeyOutput.Err
+}
+func (m *mockIdempotentRequestStore) Create(ctx context.Context, database persistence.Database, request data.IdempotentRequest) (err error) {
+	m.CreateCalled <- true
+	m.CreateInput.Ctx <- ctx
+	m.CreateInput.Database <- database
+	m.CreateInput.Request <- request
+	return <-m.CreateOutput.Err
+}
+func (m *mockIdempotentRequestStore) DeleteExpired(ctx context.Context, database persistence.Database, before time.Time) (err error) {
+	m.DeleteExpiredCalled <- true
+	m.DeleteExpiredInput.Ctx <- ctx
+	m.DeleteExpiredInput.Database <- database
+	m.DeleteExpiredInput.Before <- before
+	return <-m.DeleteExpiredOutput.Err
+}
+
 type mockOctoStore struct {
 	FetchAllCalled chan bool
 	FetchAllInput  struct {
 		Ctx      chan context.Context
 		Database chan persistence.Database
+		Page     chan persistence.Page
 	}
 	FetchAllOutput struct {
 		Octos chan []data.Octo
+		More  chan bool
 		Err   chan error
 	}
 	FetchByNameCalled chan bool
@@ -161,6 +630,15 @@ type mockOctoStore struct {
 		Octo chan data.Octo
 		Err  chan error
 	}
+	ForEachWithGarbanzosCalled chan bool
+	ForEachWithGarbanzosInput  struct {
+		Ctx      chan context.Context
+		Database chan persistence.Database
+		Fn       chan func(octo data.Octo, garbanzo *data.Garbanzo) error
+	}
+	ForEachWithGarbanzosOutput struct {
+		Err chan error
+	}
 	CreateCalled chan bool
 	CreateInput  struct {
 		Ctx      chan context.Context
@@ -171,11 +649,22 @@ type mockOctoStore struct {
 		OctoId chan int
 		Err    chan error
 	}
+	UpdateCalled chan bool
+	UpdateInput  struct {
+		Ctx      chan context.Context
+		Database chan persistence.Database
+		Octo     chan data.Octo
+	}
+	UpdateOutput struct {
+		Version chan int
+		Err     chan error
+	}
 	DeleteByIdCalled chan bool
 	DeleteByIdInput  struct {
 		Ctx      chan context.Context
 		Database chan persistence.Database
 		Id       chan int
+		Version  chan int
 	}
 	DeleteByIdOutput struct {
 		Err chan error
@@ -187,7 +676,9 @@ func newMockOctoStore() *mockOctoStore {
 	m.FetchAllCalled = make(chan bool, 100)
 	m.FetchAllInput.Ctx = make(chan context.Context, 100)
 	m.FetchAllInput.Database = make(chan persistence.Database, 100)
+	m.FetchAllInput.Page = make(chan persistence.Page, 100)
 	m.FetchAllOutput.Octos = make(chan []data.Octo, 100)
+	m.FetchAllOutput.More = make(chan bool, 100)
 	m.FetchAllOutput.Err = make(chan error, 100)
 	m.FetchByNameCalled = make(chan bool, 100)
 	m.FetchByNameInput.Ctx = make(chan context.Context, 100)
@@ -196,24 +687,37 @@ func newMockOctoStore() *mockOctoStore {
 	m.FetchByNameInput.SelectForUpdate = make(chan bool, 100)
 	m.FetchByNameOutput.Octo = make(chan data.Octo, 100)
 	m.FetchByNameOutput.Err = make(chan error, 100)
+	m.ForEachWithGarbanzosCalled = make(chan bool, 100)
+	m.ForEachWithGarbanzosInput.Ctx = make(chan context.Context, 100)
+	m.ForEachWithGarbanzosInput.Database = make(chan persistence.Database, 100)
+	m.ForEachWithGarbanzosInput.Fn = make(chan func(octo data.Octo, garbanzo *data.Garbanzo) error, 100)
+	m.ForEachWithGarbanzosOutput.Err = make(chan error, 100)
 	m.CreateCalled = make(chan bool, 100)
 	m.CreateInput.Ctx = make(chan context.Context, 100)
 	m.CreateInput.Database = make(chan persistence.Database, 100)
 	m.CreateInput.Octo = make(chan data.Octo, 100)
 	m.CreateOutput.OctoId = make(chan int, 100)
 	m.CreateOutput.Err = make(chan error, 100)
+	m.UpdateCalled = make(chan bool, 100)
+	m.UpdateInput.Ctx = make(chan context.Context, 100)
+	m.UpdateInput.Database = make(chan persistence.Database, 100)
+	m.UpdateInput.Octo = make(chan data.Octo, 100)
+	m.UpdateOutput.Version = make(chan int, 100)
+	m.UpdateOutput.Err = make(chan error, 100)
 	m.DeleteByIdCalled = make(chan bool, 100)
 	m.DeleteByIdInput.Ctx = make(chan context.Context, 100)
 	m.DeleteByIdInput.Database = make(chan persistence.Database, 100)
 	m.DeleteByIdInput.Id = make(chan int, 100)
+	m.DeleteByIdInput.Version = make(chan int, 100)
 	m.DeleteByIdOutput.Err = make(chan error, 100)
 	return m
 }
-func (m *mockOctoStore) FetchAll(ctx context.Context, database persistence.Database) (octos []data.Octo, err error) {
+func (m *mockOctoStore) FetchAll(ctx context.Context, database persistence.Database, page persistence.Page) (octos []data.Octo, more bool, err error) {
 	m.FetchAllCalled <- true
 	m.FetchAllInput.Ctx <- ctx
 	m.FetchAllInput.Database <- database
-	return <-m.FetchAllOutput.Octos, <-m.FetchAllOutput.Err
+	m.FetchAllInput.Page <- page
+	return <-m.FetchAllOutput.Octos, <-m.FetchAllOutput.More, <-m.FetchAllOutput.Err
 }
 func (m *mockOctoStore) FetchByName(ctx context.Context, database persistence.Database, name string, selectForUpdate bool) (octo data.Octo, err error) {
 	m.FetchByNameCalled <- true
@@ -223,6 +727,13 @@ func (m *mockOctoStore) FetchByName(ctx context.Context, database persistence.Da
 	m.FetchByNameInput.SelectForUpdate <- selectForUpdate
 	return <-m.FetchByNameOutput.Octo, <-m.FetchByNameOutput.Err
 }
+func (m *mockOctoStore) ForEachWithGarbanzos(ctx context.Context, database persistence.Database, fn func(octo data.Octo, garbanzo *data.Garbanzo) error) (err error) {
+	m.ForEachWithGarbanzosCalled <- true
+	m.ForEachWithGarbanzosInput.Ctx <- ctx
+	m.ForEachWithGarbanzosInput.Database <- database
+	m.ForEachWithGarbanzosInput.Fn <- fn
+	return <-m.ForEachWithGarbanzosOutput.Err
+}
 func (m *mockOctoStore) Create(ctx context.Context, database persistence.Database, octo data.Octo) (octoId int, err error) {
 	m.CreateCalled <- true
 	m.CreateInput.Ctx <- ctx
@@ -230,7 +741,134 @@ func (m *mockOctoStore) Create(ctx context.Context, database persistence.Databas
 	m.CreateInput.Octo <- octo
 	return <-m.CreateOutput.OctoId, <-m.CreateOutput.Err
 }
-func (m *mockOctoStore) DeleteById(ctx context.Context, database persistence.Database, id int) (err error) {
+func (m *mockOctoStore) Update(ctx context.Context, database persistence.Database, octo data.Octo) (version int, err error) {
+	m.UpdateCalled <- true
+	m.UpdateInput.Ctx <- ctx
+	m.UpdateInput.Database <- database
+	m.UpdateInput.Octo <- octo
+	return <-m.UpdateOutput.Version, <-m.UpdateOutput.Err
+}
+func (m *mockOctoStore) DeleteById(ctx context.Context, database persistence.Database, id int, version int) (err error) {
+	m.DeleteByIdCalled <- true
+	m.DeleteByIdInput.Ctx <- ctx
+	m.DeleteByIdInput.Database <- database
+	m.DeleteByIdInput.Id <- id
+	m.DeleteByIdInput.Version <- version
+	return <-m.DeleteByIdOutput.Err
+}
+
+type mockOrgStore struct {
+	FetchAllCalled chan bool
+	FetchAllInput  struct {
+		Ctx      chan context.Context
+		Database chan persistence.Database
+	}
+	FetchAllOutput struct {
+		Orgs chan []data.Org
+		Err  chan error
+	}
+	FetchByNameCalled chan bool
+	FetchByNameInput  struct {
+		Ctx             chan context.Context
+		Database        chan persistence.Database
+		Name            chan string
+		SelectForUpdate chan bool
+	}
+	FetchByNameOutput struct {
+		Org chan data.Org
+		Err chan error
+	}
+	CreateCalled chan bool
+	CreateInput  struct {
+		Ctx      chan context.Context
+		Database chan persistence.Database
+		Org      chan data.Org
+	}
+	CreateOutput struct {
+		OrgId chan int
+		Err   chan error
+	}
+	ProvisionCalled chan bool
+	ProvisionInput  struct {
+		Ctx      chan context.Context
+		Database chan persistence.Database
+		Name     chan string
+	}
+	ProvisionOutput struct {
+		Err chan error
+	}
+	DeleteByIdCalled chan bool
+	DeleteByIdInput  struct {
+		Ctx      chan context.Context
+		Database chan persistence.Database
+		Id       chan int
+	}
+	DeleteByIdOutput struct {
+		Err chan error
+	}
+}
+
+func newMockOrgStore() *mockOrgStore {
+	m := &mockOrgStore{}
+	m.FetchAllCalled = make(chan bool, 100)
+	m.FetchAllInput.Ctx = make(chan context.Context, 100)
+	m.FetchAllInput.Database = make(chan persistence.Database, 100)
+	m.FetchAllOutput.Orgs = make(chan []data.Org, 100)
+	m.FetchAllOutput.Err = make(chan error, 100)
+	m.FetchByNameCalled = make(chan bool, 100)
+	m.FetchByNameInput.Ctx = make(chan context.Context, 100)
+	m.FetchByNameInput.Database = make(chan persistence.Database, 100)
+	m.FetchByNameInput.Name = make(chan string, 100)
+	m.FetchByNameInput.SelectForUpdate = make(chan bool, 100)
+	m.FetchByNameOutput.Org = make(chan data.Org, 100)
+	m.FetchByNameOutput.Err = make(chan error, 100)
+	m.CreateCalled = make(chan bool, 100)
+	m.CreateInput.Ctx = make(chan context.Context, 100)
+	m.CreateInput.Database = make(chan persistence.Database, 100)
+	m.CreateInput.Org = make(chan data.Org, 100)
+	m.CreateOutput.OrgId = make(chan int, 100)
+	m.CreateOutput.Err = make(chan error, 100)
+	m.ProvisionCalled = make(chan bool, 100)
+	m.ProvisionInput.Ctx = make(chan context.Context, 100)
+	m.ProvisionInput.Database = make(chan persistence.Database, 100)
+	m.ProvisionInput.Name = make(chan string, 100)
+	m.ProvisionOutput.Err = make(chan error, 100)
+	m.DeleteByIdCalled = make(chan bool, 100)
+	m.DeleteByIdInput.Ctx = make(chan context.Context, 100)
+	m.DeleteByIdInput.Database = make(chan persistence.Database, 100)
+	m.DeleteByIdInput.Id = make(chan int, 100)
+	m.DeleteByIdOutput.Err = make(chan error, 100)
+	return m
+}
+func (m *mockOrgStore) FetchAll(ctx context.Context, database persistence.Database) (orgs []data.Org, err error) {
+	m.FetchAllCalled <- true
+	m.FetchAllInput.Ctx <- ctx
+	m.FetchAllInput.Database <- database
+	return <-m.FetchAllOutput.Orgs, <-m.FetchAllOutput.Err
+}
+func (m *mockOrgStore) FetchByName(ctx context.Context, database persistence.Database, name string, selectForUpdate bool) (org data.Org, err error) {
+	m.FetchByNameCalled <- true
+	m.FetchByNameInput.Ctx <- ctx
+	m.FetchByNameInput.Database <- database
+	m.FetchByNameInput.Name <- name
+	m.FetchByNameInput.SelectForUpdate <- selectForUpdate
+	return <-m.FetchByNameOutput.Org, <-m.FetchByNameOutput.Err
+}
+func (m *mockOrgStore) Create(ctx context.Context, database persistence.Database, org data.Org) (orgId int, err error) {
+	m.CreateCalled <- true
+	m.CreateInput.Ctx <- ctx
+	m.CreateInput.Database <- database
+	m.CreateInput.Org <- org
+	return <-m.CreateOutput.OrgId, <-m.CreateOutput.Err
+}
+func (m *mockOrgStore) Provision(ctx context.Context, database persistence.Database, name string) (err error) {
+	m.ProvisionCalled <- true
+	m.ProvisionInput.Ctx <- ctx
+	m.ProvisionInput.Database <- database
+	m.ProvisionInput.Name <- name
+	return <-m.ProvisionOutput.Err
+}
+func (m *mockOrgStore) DeleteById(ctx context.Context, database persistence.Database, id int) (err error) {
 	m.DeleteByIdCalled <- true
 	m.DeleteByIdInput.Ctx <- ctx
 	m.DeleteByIdInput.Database <- database
@@ -284,6 +922,17 @@ type mockDatabase struct {
 	RollbackOutput struct {
 		Err chan error
 	}
+	PingCalled chan bool
+	PingInput  struct {
+		Ctx chan context.Context
+	}
+	PingOutput struct {
+		Err chan error
+	}
+	CloseCalled chan bool
+	CloseOutput struct {
+		Err chan error
+	}
 }
 
 func newMockDatabase() *mockDatabase {
@@ -313,6 +962,11 @@ func newMockDatabase() *mockDatabase {
 	m.CommitOutput.Err = make(chan error, 100)
 	m.RollbackCalled = make(chan bool, 100)
 	m.RollbackOutput.Err = make(chan error, 100)
+	m.PingCalled = make(chan bool, 100)
+	m.PingInput.Ctx = make(chan context.Context, 100)
+	m.PingOutput.Err = make(chan error, 100)
+	m.CloseCalled = make(chan bool, 100)
+	m.CloseOutput.Err = make(chan error, 100)
 	return m
 }
 func (m *mockDatabase) Exec(ctx context.Context, query string, args ...interface{}) (result sql.Result, err error) {
@@ -349,6 +1003,15 @@ func (m *mockDatabase) Rollback() (err error) {
 	m.RollbackCalled <- true
 	return <-m.RollbackOutput.Err
 }
+func (m *mockDatabase) Ping(ctx context.Context) (err error) {
+	m.PingCalled <- true
+	m.PingInput.Ctx <- ctx
+	return <-m.PingOutput.Err
+}
+func (m *mockDatabase) Close() (err error) {
+	m.CloseCalled <- true
+	return <-m.CloseOutput.Err
+}
 
 type mockContext struct {
 	DeadlineCalled chan bool
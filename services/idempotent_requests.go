@@ -0,0 +1,100 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/myshkin5/effective-octo-garbanzo/logs"
+	"github.com/myshkin5/effective-octo-garbanzo/persistence"
+	"github.com/myshkin5/effective-octo-garbanzo/persistence/data"
+)
+
+var (
+	ErrIdempotencyKeyReused = errors.New("idempotency key was used for a different request")
+	ErrIdempotencyKeyInUse  = errors.New("idempotency key is in use by a concurrent request")
+)
+
+type IdempotentRequestStore interface {
+	FetchByKey(ctx context.Context, database persistence.Database, key string) (request data.IdempotentRequest, err error)
+	Create(ctx context.Context, database persistence.Database, request data.IdempotentRequest) (err error)
+	DeleteExpired(ctx context.Context, database persistence.Database, before time.Time) (err error)
+}
+
+// IdempotentRequests records the responses to creates sent with an
+// Idempotency-Key so that a retry of the request (after a timeout, say)
+// receives the original response rather than creating a duplicate. An org's
+// keys expire ttl after they are first used and are deleted by Run every
+// sweepInterval, so a key may be replayed for up to sweepInterval longer.
+type IdempotentRequests struct {
+	store         IdempotentRequestStore
+	database      persistence.Database
+	ttl           time.Duration
+	sweepInterval time.Duration
+}
+
+func NewIdempotentRequests(store IdempotentRequestStore, database persistence.Database, ttl, sweepInterval time.Duration) *IdempotentRequests {
+	return &IdempotentRequests{
+		store:         store,
+		database:      database,
+		ttl:           ttl,
+		sweepInterval: sweepInterval,
+	}
+}
+
+// Run calls DeleteExpired every sweepInterval until ctx is done.
+func (r *IdempotentRequests) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.sweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		err := r.DeleteExpired(ctx)
+		if err != nil {
+			// The next sweep will catch up
+			logs.FromContext(ctx).Warnf("Could not delete expired idempotent requests, error %v", err)
+		}
+	}
+}
+
+// DeleteExpired deletes the expired requests of every org.
+func (r *IdempotentRequests) DeleteExpired(ctx context.Context) error {
+	return r.store.DeleteExpired(ctx, r.database, time.Now().Add(-r.ttl))
+}
+
+// create runs create in database, which must be a transaction, and records the
+// response it renders with the request. When the org has already used the
+// request's key, the recorded response is replayed instead without running
+// create.
+func (r *IdempotentRequests) create(ctx context.Context, database persistence.Database, request data.IdempotentRequest, create func() ([]byte, error)) ([]byte, bool, error) {
+	existing, err := r.store.FetchByKey(ctx, database, request.Key)
+	if err == nil {
+		if existing.RequestHash != request.RequestHash {
+			return nil, false, ErrIdempotencyKeyReused
+		}
+		return existing.Response, true, nil
+	} else if err != persistence.ErrNotFound {
+		return nil, false, err
+	}
+
+	request.Response, err = create()
+	if err != nil {
+		return nil, false, err
+	}
+
+	request.CreatedAt = time.Now()
+	err = r.store.Create(ctx, database, request)
+	if err == persistence.ErrDuplicate {
+		// Another request with the key committed after this one looked
+		return nil, false, ErrIdempotencyKeyInUse
+	} else if err != nil {
+		return nil, false, err
+	}
+
+	return request.Response, false, nil
+}
@@ -0,0 +1,48 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/myshkin5/effective-octo-garbanzo/services"
+)
+
+var _ = Describe("IdempotentRequests", func() {
+	var (
+		mockRequestStore   *mockIdempotentRequestStore
+		mockDB             *mockDatabase
+		idempotentRequests *services.IdempotentRequests
+		ctx                context.Context
+	)
+
+	BeforeEach(func() {
+		mockRequestStore = newMockIdempotentRequestStore()
+		mockDB = newMockDatabase()
+		ctx = context.Background()
+
+		idempotentRequests = services.NewIdempotentRequests(mockRequestStore, mockDB, time.Hour, time.Minute)
+	})
+
+	Describe("DeleteExpired", func() {
+		It("deletes the requests of every org first used more than the ttl ago", func() {
+			mockRequestStore.DeleteExpiredOutput.Err <- nil
+
+			Expect(idempotentRequests.DeleteExpired(ctx)).To(Succeed())
+
+			Expect(mockRequestStore.DeleteExpiredInput.Database).To(Receive(Equal(mockDB)))
+			var before time.Time
+			Expect(mockRequestStore.DeleteExpiredInput.Before).To(Receive(&before))
+			Expect(before).To(BeTemporally("~", time.Now().Add(-time.Hour), time.Second))
+		})
+
+		It("returns the store's error", func() {
+			mockRequestStore.DeleteExpiredOutput.Err <- errors.New("not now")
+
+			Expect(idempotentRequests.DeleteExpired(ctx)).To(MatchError("not now"))
+		})
+	})
+})
@@ -7,44 +7,133 @@ import (
 
 	"github.com/myshkin5/effective-octo-garbanzo/persistence"
 	"github.com/myshkin5/effective-octo-garbanzo/persistence/data"
+	"github.com/myshkin5/effective-octo-garbanzo/tracing"
 )
 
+// auditDeletePageSize is how many of an octo's garbanzos are fetched at a
+// time when auditing their deletion along with the octo.
+const auditDeletePageSize = 100
+
 type OctoStore interface {
-	FetchAll(ctx context.Context, database persistence.Database) (octos []data.Octo, err error)
+	FetchAll(ctx context.Context, database persistence.Database, page persistence.Page) (octos []data.Octo, more bool, err error)
 	FetchByName(ctx context.Context, database persistence.Database, name string, selectForUpdate bool) (octo data.Octo, err error)
+	ForEachWithGarbanzos(ctx context.Context, database persistence.Database, fn func(octo data.Octo, garbanzo *data.Garbanzo) error) (err error)
 	Create(ctx context.Context, database persistence.Database, octo data.Octo) (octoId int, err error)
-	DeleteById(ctx context.Context, database persistence.Database, id int) (err error)
+	Update(ctx context.Context, database persistence.Database, octo data.Octo) (version int, err error)
+	DeleteById(ctx context.Context, database persistence.Database, id int, version int) (err error)
 }
 
 type OctoService struct {
-	octoStore     OctoStore
-	garbanzoStore GarbanzoStore
-	database      persistence.Database
+	octoStore          OctoStore
+	garbanzoStore      GarbanzoStore
+	idempotentRequests *IdempotentRequests
+	auditLog           *AuditLog
+	database           persistence.Database
 }
 
-func NewOctoService(octoStore OctoStore, garbanzoStore GarbanzoStore, database persistence.Database) *OctoService {
+func NewOctoService(octoStore OctoStore, garbanzoStore GarbanzoStore, idempotentRequests *IdempotentRequests, auditLog *AuditLog, database persistence.Database) *OctoService {
 	return &OctoService{
-		octoStore:     octoStore,
-		garbanzoStore: garbanzoStore,
-		database:      database,
+		octoStore:          octoStore,
+		garbanzoStore:      garbanzoStore,
+		idempotentRequests: idempotentRequests,
+		auditLog:           auditLog,
+		database:           database,
 	}
 }
 
-func (s *OctoService) FetchAll(ctx context.Context) ([]data.Octo, error) {
-	return s.octoStore.FetchAll(ctx, s.database)
+func (s *OctoService) FetchAll(ctx context.Context, page persistence.Page) ([]data.Octo, bool, error) {
+	ctx, span := tracing.Start(ctx, "OctoService.FetchAll")
+	defer span.End()
+
+	return s.octoStore.FetchAll(ctx, s.database, page)
 }
 
 func (s *OctoService) FetchByName(ctx context.Context, name string) (data.Octo, error) {
+	ctx, span := tracing.Start(ctx, "OctoService.FetchByName")
+	defer span.End()
+
 	return s.octoStore.FetchByName(ctx, s.database, name, false)
 }
 
-func (s *OctoService) Create(ctx context.Context, octo data.Octo) (data.Octo, error) {
-	err := s.validate(octo)
+func (s *OctoService) Create(ctx context.Context, octo data.Octo) (octoOut data.Octo, err error) {
+	ctx, span := tracing.Start(ctx, "OctoService.Create")
+	defer span.End()
+
+	err = validateOcto(octo)
+	if err != nil {
+		return data.Octo{}, err
+	}
+
+	database, err := s.database.BeginTx(ctx)
+	if err != nil {
+		return data.Octo{}, err
+	}
+	defer func() {
+		if err != nil {
+			database.Rollback()
+			return
+		}
+		err = database.Commit()
+	}()
+
+	octo, err = s.create(ctx, database, octo)
+	if err != nil {
+		return data.Octo{}, err
+	}
+
+	return octo, nil
+}
+
+// CreateIdempotently is Create for a request with an Idempotency-Key. The
+// response rendered by respond is recorded in the same transaction as the
+// octo and is replayed, rather than creating another octo, when the request
+// is retried.
+func (s *OctoService) CreateIdempotently(ctx context.Context, octo data.Octo, request data.IdempotentRequest, respond func(octo data.Octo) []byte) (response []byte, replayed bool, err error) {
+	ctx, span := tracing.Start(ctx, "OctoService.CreateIdempotently")
+	defer span.End()
+
+	err = validateOcto(octo)
+	if err != nil {
+		return nil, false, err
+	}
+
+	database, err := s.database.BeginTx(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	defer func() {
+		if err != nil {
+			database.Rollback()
+			return
+		}
+		err = database.Commit()
+	}()
+
+	response, replayed, err = s.idempotentRequests.create(ctx, database, request, func() ([]byte, error) {
+		octo, err := s.create(ctx, database, octo)
+		if err != nil {
+			return nil, err
+		}
+
+		return respond(octo), nil
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	return response, replayed, nil
+}
+
+// create creates a validated octo within database, a transaction.
+func (s *OctoService) create(ctx context.Context, database persistence.Database, octo data.Octo) (data.Octo, error) {
+	id, err := s.octoStore.Create(ctx, database, octo)
 	if err != nil {
 		return data.Octo{}, err
 	}
+	octo.Id = id
+	octo.Version = 1
 
-	octo.Id, err = s.octoStore.Create(ctx, s.database, octo)
+	err = s.auditLog.record(ctx, database, AuditCreate, octoResource(octo.Name), nil, auditOcto(octo))
 	if err != nil {
 		return data.Octo{}, err
 	}
@@ -52,7 +141,64 @@ func (s *OctoService) Create(ctx context.Context, octo data.Octo) (data.Octo, er
 	return octo, nil
 }
 
-func (s *OctoService) validate(octo data.Octo) error {
+// Update renames the named octo. Unless octo.Version is 0, the octo is only
+// updated while it is still the row of octo.Id at that version.
+func (s *OctoService) Update(ctx context.Context, name string, octo data.Octo) (octoOut data.Octo, err error) {
+	ctx, span := tracing.Start(ctx, "OctoService.Update")
+	defer span.End()
+
+	err = validateOcto(octo)
+	if err != nil {
+		return data.Octo{}, err
+	}
+
+	database, err := s.database.BeginTx(ctx)
+	if err != nil {
+		return data.Octo{}, err
+	}
+	defer func() {
+		if err != nil {
+			database.Rollback()
+			return
+		}
+		err = database.Commit()
+	}()
+
+	existing, err := s.octoStore.FetchByName(ctx, database, name, true)
+	if err != nil {
+		return data.Octo{}, err
+	}
+	if octo.Id != 0 && octo.Id != existing.Id {
+		err = persistence.ErrVersionMismatch
+		return data.Octo{}, err
+	}
+	octo.Id = existing.Id
+
+	if octo.Name != existing.Name {
+		_, err = s.octoStore.FetchByName(ctx, database, octo.Name, false)
+		if err == nil {
+			err = persistence.ErrDuplicate
+			return data.Octo{}, err
+		} else if err != persistence.ErrNotFound {
+			return data.Octo{}, err
+		}
+	}
+
+	octo.Version, err = s.octoStore.Update(ctx, database, octo)
+	if err != nil {
+		return data.Octo{}, err
+	}
+
+	err = s.auditLog.recordMove(ctx, database, AuditUpdate, octoResource(name), octoResource(octo.Name),
+		auditOcto(existing), auditOcto(octo))
+	if err != nil {
+		return data.Octo{}, err
+	}
+
+	return octo, nil
+}
+
+func validateOcto(octo data.Octo) error {
 	errors := make(map[string][]string)
 	if len(octo.Name) == 0 {
 		errors["Name"] = append(errors["Name"], "must be present")
@@ -69,7 +215,13 @@ func (s *OctoService) validate(octo data.Octo) error {
 	return nil
 }
 
-func (s *OctoService) DeleteByName(ctx context.Context, name string) error {
+// DeleteByName deletes the named octo and its garbanzos. Unless version is 0,
+// the octo is only deleted while it is still the row of id at that version.
+// Each garbanzo's deletion is audited on its own before the octo's.
+func (s *OctoService) DeleteByName(ctx context.Context, name string, id, version int) (err error) {
+	ctx, span := tracing.Start(ctx, "OctoService.DeleteByName")
+	defer span.End()
+
 	database, err := s.database.BeginTx(ctx)
 	if err != nil {
 		return err
@@ -86,16 +238,55 @@ func (s *OctoService) DeleteByName(ctx context.Context, name string) error {
 	if err != nil {
 		return err
 	}
+	if id != 0 && id != octo.Id {
+		err = persistence.ErrVersionMismatch
+		return err
+	}
+
+	err = s.auditGarbanzoDeletes(ctx, database, name)
+	if err != nil {
+		return err
+	}
 
 	err = s.garbanzoStore.DeleteByOctoId(ctx, database, octo.Id)
 	if err != nil {
 		return err
 	}
 
-	err = s.octoStore.DeleteById(ctx, database, octo.Id)
+	err = s.octoStore.DeleteById(ctx, database, octo.Id, version)
+	if err != nil {
+		return err
+	}
+
+	err = s.auditLog.record(ctx, database, AuditDelete, octoResource(name), auditOcto(octo), nil)
 	if err != nil {
 		return err
 	}
 
 	return nil
 }
+
+// auditGarbanzoDeletes records the deletion of each of the named octo's
+// garbanzos, a page at a time.
+func (s *OctoService) auditGarbanzoDeletes(ctx context.Context, database persistence.Database, name string) error {
+	page := persistence.Page{Limit: auditDeletePageSize}
+	for {
+		garbanzos, more, err := s.garbanzoStore.FetchByOctoName(ctx, database, name, persistence.GarbanzoFilter{}, page)
+		if err != nil {
+			return err
+		}
+
+		for _, garbanzo := range garbanzos {
+			err = s.auditLog.record(ctx, database, AuditDelete,
+				garbanzoResource(name, garbanzo.APIUUID), auditGarbanzo(garbanzo, name), nil)
+			if err != nil {
+				return err
+			}
+		}
+
+		if !more {
+			return nil
+		}
+		page.AfterId = garbanzos[len(garbanzos)-1].Id
+	}
+}
@@ -5,9 +5,12 @@ package services_test
 import (
 	"context"
 	"errors"
+	"fmt"
+	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"github.com/satori/go.uuid"
 
 	"github.com/myshkin5/effective-octo-garbanzo/persistence"
 	"github.com/myshkin5/effective-octo-garbanzo/persistence/data"
@@ -18,6 +21,8 @@ var _ = Describe("Octo", func() {
 	var (
 		mockOctoStore     *mockOctoStore
 		mockGarbanzoStore *mockGarbanzoStore
+		mockRequestStore  *mockIdempotentRequestStore
+		mockAuditStore    *mockAuditEventStore
 		mockDB            *mockDatabase
 		mockTx            *mockDatabase
 		service           *services.OctoService
@@ -27,22 +32,30 @@ var _ = Describe("Octo", func() {
 	BeforeEach(func() {
 		mockOctoStore = newMockOctoStore()
 		mockGarbanzoStore = newMockGarbanzoStore()
+		mockRequestStore = newMockIdempotentRequestStore()
+		mockAuditStore = newMockAuditEventStore()
 		mockDB = newMockDatabase()
 		mockTx = newMockDatabase()
-		ctx = context.Background()
+		ctx = context.WithValue(context.Background(), persistence.OrgContextKey, "my-org")
+		ctx = context.WithValue(ctx, persistence.SubjectContextKey, "user1")
+		ctx = context.WithValue(ctx, persistence.RequestIDContextKey, "req1")
 
-		service = services.NewOctoService(mockOctoStore, mockGarbanzoStore, mockDB)
+		service = services.NewOctoService(mockOctoStore, mockGarbanzoStore,
+			services.NewIdempotentRequests(mockRequestStore, mockDB, time.Hour, time.Minute), services.NewAuditLog(mockAuditStore, mockDB), mockDB)
 	})
 
 	It("fetches all octos", func() {
 		var octos []data.Octo
 		mockOctoStore.FetchAllOutput.Octos <- octos
 		err := errors.New("some error")
+		mockOctoStore.FetchAllOutput.More <- true
 		mockOctoStore.FetchAllOutput.Err <- err
+		page := persistence.Page{AfterId: 3, Limit: 10}
 
-		actualOctos, actualErr := service.FetchAll(ctx)
+		actualOctos, actualMore, actualErr := service.FetchAll(ctx, page)
 
 		Expect(actualOctos).To(Equal(octos))
+		Expect(actualMore).To(BeTrue())
 		Expect(actualErr).To(Equal(err))
 
 		Expect(mockOctoStore.FetchAllCalled).To(HaveLen(1))
@@ -51,7 +64,8 @@ var _ = Describe("Octo", func() {
 		Expect(actualDB).To(Equal(mockDB))
 		var actualCtx context.Context
 		Expect(mockOctoStore.FetchAllInput.Ctx).To(Receive(&actualCtx))
-		Expect(actualCtx).To(Equal(ctx))
+		Expect(actualCtx.Value(persistence.OrgContextKey)).To(Equal("my-org"))
+		Expect(mockOctoStore.FetchAllInput.Page).To(Receive(Equal(page)))
 	})
 
 	It("fetches a octo by name", func() {
@@ -73,7 +87,7 @@ var _ = Describe("Octo", func() {
 		Expect(actualDB).To(Equal(mockDB))
 		var actualCtx context.Context
 		Expect(mockOctoStore.FetchByNameInput.Ctx).To(Receive(&actualCtx))
-		Expect(actualCtx).To(Equal(ctx))
+		Expect(actualCtx.Value(persistence.OrgContextKey)).To(Equal("my-org"))
 		var actualName string
 		Expect(mockOctoStore.FetchByNameInput.Name).To(Receive(&actualName))
 		Expect(actualName).To(Equal("kraken"))
@@ -81,9 +95,14 @@ var _ = Describe("Octo", func() {
 
 	Describe("Create", func() {
 		It("creates a octo", func() {
+			mockDB.BeginTxOutput.Database <- mockTx
+			mockDB.BeginTxOutput.Err <- nil
 			octoId := 42
 			mockOctoStore.CreateOutput.OctoId <- octoId
 			mockOctoStore.CreateOutput.Err <- nil
+			mockAuditStore.CreateOutput.EventId <- 7
+			mockAuditStore.CreateOutput.Err <- nil
+			mockTx.CommitOutput.Err <- nil
 			octo := data.Octo{
 				Name: "kraken",
 			}
@@ -93,17 +112,46 @@ var _ = Describe("Octo", func() {
 			Expect(actualErr).NotTo(HaveOccurred())
 			Expect(actualOcto.Id).To(Equal(octoId))
 			Expect(actualOcto.Name).To(Equal("kraken"))
+			Expect(actualOcto.Version).To(Equal(1))
 
 			Expect(mockOctoStore.CreateCalled).To(HaveLen(1))
 			var actualDB persistence.Database
 			Expect(mockOctoStore.CreateInput.Database).To(Receive(&actualDB))
-			Expect(actualDB).To(Equal(mockDB))
+			Expect(actualDB).To(Equal(mockTx))
 			var actualCtx context.Context
 			Expect(mockOctoStore.CreateInput.Ctx).To(Receive(&actualCtx))
-			Expect(actualCtx).To(Equal(ctx))
+			Expect(actualCtx.Value(persistence.OrgContextKey)).To(Equal("my-org"))
 			var persistedOcto data.Octo
 			Expect(mockOctoStore.CreateInput.Octo).To(Receive(&persistedOcto))
 			Expect(persistedOcto.Name).To(Equal(actualOcto.Name))
+
+			Expect(mockAuditStore.CreateInput.Database).To(Receive(Equal(mockTx)))
+			var event data.AuditEvent
+			Expect(mockAuditStore.CreateInput.Event).To(Receive(&event))
+			Expect(event.Actor).To(Equal("user1"))
+			Expect(event.Action).To(Equal(services.AuditCreate))
+			Expect(event.Resource).To(Equal("octos/kraken"))
+			Expect(event.Before).To(BeNil())
+			Expect(event.After).To(MatchJSON(`{"name": "kraken", "version": 1}`))
+			Expect(event.RequestID).To(Equal("req1"))
+			Expect(event.CreatedAt).To(BeTemporally("~", time.Now(), time.Second))
+			Expect(mockTx.CommitCalled).To(HaveLen(1))
+		})
+
+		It("rolls back the octo when the audit event can't be recorded", func() {
+			mockDB.BeginTxOutput.Database <- mockTx
+			mockDB.BeginTxOutput.Err <- nil
+			mockOctoStore.CreateOutput.OctoId <- 42
+			mockOctoStore.CreateOutput.Err <- nil
+			mockAuditStore.CreateOutput.EventId <- 0
+			mockAuditStore.CreateOutput.Err <- errors.New("don't bother")
+			mockTx.RollbackOutput.Err <- nil
+
+			_, err := service.Create(ctx, data.Octo{Name: "kraken"})
+			Expect(err).To(MatchError("don't bother"))
+
+			Expect(mockTx.RollbackCalled).To(HaveLen(1))
+			Expect(mockTx.CommitCalled).To(BeEmpty())
 		})
 
 		It("returns a validation error for an empty octo name", func() {
@@ -136,12 +184,320 @@ var _ = Describe("Octo", func() {
 		})
 	})
 
+	Describe("CreateIdempotently", func() {
+		var (
+			request data.IdempotentRequest
+			respond func(octo data.Octo) []byte
+		)
+
+		BeforeEach(func() {
+			request = data.IdempotentRequest{
+				Key:         "retry-me",
+				RequestHash: "hash1",
+			}
+			respond = func(octo data.Octo) []byte {
+				return []byte(fmt.Sprintf("%s %d %d", octo.Name, octo.Id, octo.Version))
+			}
+
+			mockDB.BeginTxOutput.Database <- mockTx
+			mockDB.BeginTxOutput.Err <- nil
+		})
+
+		It("creates the octo and records the response", func() {
+			mockRequestStore.FetchByKeyOutput.Request <- data.IdempotentRequest{}
+			mockRequestStore.FetchByKeyOutput.Err <- persistence.ErrNotFound
+			mockOctoStore.CreateOutput.OctoId <- 42
+			mockOctoStore.CreateOutput.Err <- nil
+			mockAuditStore.CreateOutput.EventId <- 7
+			mockAuditStore.CreateOutput.Err <- nil
+			mockRequestStore.CreateOutput.Err <- nil
+			mockTx.CommitOutput.Err <- nil
+
+			response, replayed, err := service.CreateIdempotently(ctx, data.Octo{Name: "kraken"}, request, respond)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(replayed).To(BeFalse())
+			Expect(string(response)).To(Equal("kraken 42 1"))
+
+			Expect(mockRequestStore.DeleteExpiredCalled).To(BeEmpty())
+			Expect(mockRequestStore.FetchByKeyInput.Database).To(Receive(Equal(mockTx)))
+			Expect(mockRequestStore.FetchByKeyInput.Key).To(Receive(Equal("retry-me")))
+			Expect(mockOctoStore.CreateInput.Database).To(Receive(Equal(mockTx)))
+			Expect(mockAuditStore.CreateInput.Database).To(Receive(Equal(mockTx)))
+			Expect(mockRequestStore.CreateInput.Database).To(Receive(Equal(mockTx)))
+			var recorded data.IdempotentRequest
+			Expect(mockRequestStore.CreateInput.Request).To(Receive(&recorded))
+			Expect(recorded.Key).To(Equal("retry-me"))
+			Expect(recorded.RequestHash).To(Equal("hash1"))
+			Expect(string(recorded.Response)).To(Equal("kraken 42 1"))
+			Expect(recorded.CreatedAt).To(BeTemporally("~", time.Now(), time.Second))
+			Expect(mockTx.CommitCalled).To(HaveLen(1))
+		})
+
+		It("replays the recorded response of a retry", func() {
+			mockRequestStore.FetchByKeyOutput.Request <- data.IdempotentRequest{
+				Key:         "retry-me",
+				RequestHash: "hash1",
+				Response:    []byte("kraken 42 1"),
+			}
+			mockRequestStore.FetchByKeyOutput.Err <- nil
+			mockTx.CommitOutput.Err <- nil
+
+			response, replayed, err := service.CreateIdempotently(ctx, data.Octo{Name: "kraken"}, request, respond)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(replayed).To(BeTrue())
+			Expect(string(response)).To(Equal("kraken 42 1"))
+			Expect(mockOctoStore.CreateCalled).To(BeEmpty())
+			Expect(mockAuditStore.CreateCalled).To(BeEmpty())
+			Expect(mockRequestStore.CreateCalled).To(BeEmpty())
+		})
+
+		It("returns an error when the key was used for a different request", func() {
+			mockRequestStore.FetchByKeyOutput.Request <- data.IdempotentRequest{
+				Key:         "retry-me",
+				RequestHash: "hash2",
+			}
+			mockRequestStore.FetchByKeyOutput.Err <- nil
+			mockTx.RollbackOutput.Err <- nil
+
+			_, _, err := service.CreateIdempotently(ctx, data.Octo{Name: "kraken"}, request, respond)
+
+			Expect(err).To(Equal(services.ErrIdempotencyKeyReused))
+			Expect(mockOctoStore.CreateCalled).To(BeEmpty())
+			Expect(mockTx.RollbackCalled).To(HaveLen(1))
+		})
+
+		It("rolls back the octo when a concurrent request recorded the key first", func() {
+			mockRequestStore.FetchByKeyOutput.Request <- data.IdempotentRequest{}
+			mockRequestStore.FetchByKeyOutput.Err <- persistence.ErrNotFound
+			mockOctoStore.CreateOutput.OctoId <- 42
+			mockOctoStore.CreateOutput.Err <- nil
+			mockAuditStore.CreateOutput.EventId <- 7
+			mockAuditStore.CreateOutput.Err <- nil
+			mockRequestStore.CreateOutput.Err <- persistence.ErrDuplicate
+			mockTx.RollbackOutput.Err <- nil
+
+			_, _, err := service.CreateIdempotently(ctx, data.Octo{Name: "kraken"}, request, respond)
+
+			Expect(err).To(Equal(services.ErrIdempotencyKeyInUse))
+			Expect(mockTx.RollbackCalled).To(HaveLen(1))
+			Expect(mockTx.CommitCalled).To(BeEmpty())
+		})
+	})
+
+	Describe("Update", func() {
+		It("returns a validation error for an octo name with invalid characters", func() {
+			_, err := service.Update(ctx, "kraken", data.Octo{
+				Name: " 283",
+			})
+			Expect(err).To(HaveOccurred())
+			validationErr, ok := err.(services.ValidationError)
+			Expect(ok).To(BeTrue())
+			Expect(validationErr.Errors()).To(Equal(map[string][]string{"Name": {"must match regular expression '^[\\w-]+$'"}}))
+
+			Expect(mockDB.BeginTxCalled).To(BeEmpty())
+		})
+
+		It("returns an error if it can't start a transaction", func() {
+			mockDB.BeginTxOutput.Database <- nil
+			mockDB.BeginTxOutput.Err <- errors.New("don't bother")
+
+			_, err := service.Update(ctx, "kraken", data.Octo{Name: "cthulhu"})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("don't bother"))
+		})
+
+		It("rolls back and returns an error if it can't select the octo for update", func() {
+			mockDB.BeginTxOutput.Database <- mockTx
+			mockDB.BeginTxOutput.Err <- nil
+
+			mockOctoStore.FetchByNameOutput.Octo <- data.Octo{}
+			mockOctoStore.FetchByNameOutput.Err <- persistence.ErrNotFound
+
+			mockTx.RollbackOutput.Err <- nil
+
+			_, err := service.Update(ctx, "kraken", data.Octo{Name: "cthulhu"})
+			Expect(err).To(Equal(persistence.ErrNotFound))
+
+			Expect(mockTx.RollbackCalled).To(HaveLen(1))
+		})
+
+		It("rolls back and returns a version mismatch if the octo isn't the row of the id", func() {
+			mockDB.BeginTxOutput.Database <- mockTx
+			mockDB.BeginTxOutput.Err <- nil
+
+			// The octo was deleted and created again since the id was read
+			mockOctoStore.FetchByNameOutput.Octo <- data.Octo{Id: 283, Name: "kraken", Version: 1}
+			mockOctoStore.FetchByNameOutput.Err <- nil
+
+			mockTx.RollbackOutput.Err <- nil
+
+			_, err := service.Update(ctx, "kraken", data.Octo{Id: 282, Name: "cthulhu", Version: 1})
+			Expect(err).To(Equal(persistence.ErrVersionMismatch))
+
+			Expect(mockOctoStore.UpdateCalled).To(BeEmpty())
+			Expect(mockTx.RollbackCalled).To(HaveLen(1))
+		})
+
+		It("rolls back and returns a duplicate error if the new name is already taken", func() {
+			mockDB.BeginTxOutput.Database <- mockTx
+			mockDB.BeginTxOutput.Err <- nil
+
+			mockOctoStore.FetchByNameOutput.Octo <- data.Octo{
+				Id:   282,
+				Name: "kraken",
+			}
+			mockOctoStore.FetchByNameOutput.Err <- nil
+			mockOctoStore.FetchByNameOutput.Octo <- data.Octo{
+				Id:   283,
+				Name: "cthulhu",
+			}
+			mockOctoStore.FetchByNameOutput.Err <- nil
+
+			mockTx.RollbackOutput.Err <- nil
+
+			_, err := service.Update(ctx, "kraken", data.Octo{Name: "cthulhu"})
+			Expect(err).To(Equal(persistence.ErrDuplicate))
+
+			Expect(mockOctoStore.UpdateCalled).To(BeEmpty())
+			Expect(mockTx.RollbackCalled).To(HaveLen(1))
+		})
+
+		It("rolls back and returns an error if it can't update the octo", func() {
+			mockDB.BeginTxOutput.Database <- mockTx
+			mockDB.BeginTxOutput.Err <- nil
+
+			mockOctoStore.FetchByNameOutput.Octo <- data.Octo{
+				Id:   282,
+				Name: "kraken",
+			}
+			mockOctoStore.FetchByNameOutput.Err <- nil
+			mockOctoStore.FetchByNameOutput.Octo <- data.Octo{}
+			mockOctoStore.FetchByNameOutput.Err <- persistence.ErrNotFound
+
+			mockOctoStore.UpdateOutput.Version <- 0
+			mockOctoStore.UpdateOutput.Err <- errors.New("some error")
+
+			mockTx.RollbackOutput.Err <- nil
+
+			_, err := service.Update(ctx, "kraken", data.Octo{Name: "cthulhu"})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("some error"))
+
+			Expect(mockTx.RollbackCalled).To(HaveLen(1))
+		})
+
+		It("renames an octo", func() {
+			mockDB.BeginTxOutput.Database <- mockTx
+			mockDB.BeginTxOutput.Err <- nil
+
+			id := 282
+			mockOctoStore.FetchByNameOutput.Octo <- data.Octo{
+				Id:      id,
+				Name:    "kraken",
+				Version: 3,
+			}
+			mockOctoStore.FetchByNameOutput.Err <- nil
+			mockOctoStore.FetchByNameOutput.Octo <- data.Octo{}
+			mockOctoStore.FetchByNameOutput.Err <- persistence.ErrNotFound
+
+			mockOctoStore.UpdateOutput.Version <- 4
+			mockOctoStore.UpdateOutput.Err <- nil
+
+			mockAuditStore.CreateOutput.EventId <- 7
+			mockAuditStore.CreateOutput.Err <- nil
+			mockAuditStore.CreateOutput.EventId <- 8
+			mockAuditStore.CreateOutput.Err <- nil
+
+			mockTx.CommitOutput.Err <- nil
+
+			actualOcto, actualErr := service.Update(ctx, "kraken", data.Octo{Name: "cthulhu", Version: 3})
+			Expect(actualErr).NotTo(HaveOccurred())
+			Expect(actualOcto).To(Equal(data.Octo{
+				Id:      id,
+				Name:    "cthulhu",
+				Version: 4,
+			}))
+
+			Expect(mockOctoStore.FetchByNameCalled).To(HaveLen(2))
+			var actualName string
+			Expect(mockOctoStore.FetchByNameInput.Name).To(Receive(&actualName))
+			Expect(actualName).To(Equal("kraken"))
+			var actualSelectForUpdate bool
+			Expect(mockOctoStore.FetchByNameInput.SelectForUpdate).To(Receive(&actualSelectForUpdate))
+			Expect(actualSelectForUpdate).To(BeTrue())
+			Expect(mockOctoStore.FetchByNameInput.Name).To(Receive(&actualName))
+			Expect(actualName).To(Equal("cthulhu"))
+
+			Expect(mockOctoStore.UpdateCalled).To(HaveLen(1))
+			var actualDB persistence.Database
+			Expect(mockOctoStore.UpdateInput.Database).To(Receive(&actualDB))
+			Expect(actualDB).To(Equal(mockTx))
+			var actualCtx context.Context
+			Expect(mockOctoStore.UpdateInput.Ctx).To(Receive(&actualCtx))
+			Expect(actualCtx.Value(persistence.OrgContextKey)).To(Equal("my-org"))
+			var persistedOcto data.Octo
+			Expect(mockOctoStore.UpdateInput.Octo).To(Receive(&persistedOcto))
+			Expect(persistedOcto).To(Equal(data.Octo{
+				Id:      id,
+				Name:    "cthulhu",
+				Version: 3,
+			}))
+
+			Expect(mockAuditStore.CreateInput.Database).To(Receive(Equal(mockTx)))
+			var event data.AuditEvent
+			Expect(mockAuditStore.CreateInput.Event).To(Receive(&event))
+			Expect(event.Action).To(Equal(services.AuditUpdate))
+			Expect(event.Resource).To(Equal("octos/kraken"))
+			Expect(event.Before).To(MatchJSON(`{"name": "kraken", "version": 3}`))
+			Expect(event.After).To(MatchJSON(`{"name": "cthulhu", "version": 4}`))
+
+			// Recorded under the new name too
+			Expect(mockAuditStore.CreateInput.Database).To(Receive(Equal(mockTx)))
+			var renamedEvent data.AuditEvent
+			Expect(mockAuditStore.CreateInput.Event).To(Receive(&renamedEvent))
+			Expect(renamedEvent.Action).To(Equal(services.AuditUpdate))
+			Expect(renamedEvent.Resource).To(Equal("octos/cthulhu"))
+			Expect(renamedEvent.Before).To(Equal(event.Before))
+			Expect(renamedEvent.After).To(Equal(event.After))
+
+			Expect(mockTx.CommitCalled).To(HaveLen(1))
+		})
+
+		It("does not check uniqueness when the name is unchanged", func() {
+			mockDB.BeginTxOutput.Database <- mockTx
+			mockDB.BeginTxOutput.Err <- nil
+
+			mockOctoStore.FetchByNameOutput.Octo <- data.Octo{
+				Id:   282,
+				Name: "kraken",
+			}
+			mockOctoStore.FetchByNameOutput.Err <- nil
+
+			mockOctoStore.UpdateOutput.Version <- 2
+			mockOctoStore.UpdateOutput.Err <- nil
+
+			mockAuditStore.CreateOutput.EventId <- 7
+			mockAuditStore.CreateOutput.Err <- nil
+
+			mockTx.CommitOutput.Err <- nil
+
+			_, err := service.Update(ctx, "kraken", data.Octo{Name: "kraken"})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(mockOctoStore.FetchByNameCalled).To(HaveLen(1))
+			Expect(mockOctoStore.UpdateCalled).To(HaveLen(1))
+			Expect(mockAuditStore.CreateCalled).To(HaveLen(1))
+		})
+	})
+
 	Describe("DeleteByName", func() {
 		It("returns an error if it can't start a transaction", func() {
 			mockDB.BeginTxOutput.Database <- nil
 			mockDB.BeginTxOutput.Err <- errors.New("don't bother")
 
-			err := service.DeleteByName(ctx, "kraken")
+			err := service.DeleteByName(ctx, "kraken", 0, 0)
 			Expect(err).To(HaveOccurred())
 			Expect(err.Error()).To(Equal("don't bother"))
 		})
@@ -155,13 +511,50 @@ var _ = Describe("Octo", func() {
 
 			mockTx.RollbackOutput.Err <- nil
 
-			err := service.DeleteByName(ctx, "kraken")
+			err := service.DeleteByName(ctx, "kraken", 0, 0)
 			Expect(err).To(HaveOccurred())
 			Expect(err.Error()).To(Equal("don't bother"))
 
 			Expect(mockTx.RollbackCalled).To(HaveLen(1))
 		})
 
+		It("rolls back and returns a version mismatch if the octo isn't the row of the id", func() {
+			mockDB.BeginTxOutput.Database <- mockTx
+			mockDB.BeginTxOutput.Err <- nil
+
+			mockOctoStore.FetchByNameOutput.Octo <- data.Octo{Id: 283, Name: "kraken", Version: 1}
+			mockOctoStore.FetchByNameOutput.Err <- nil
+
+			mockTx.RollbackOutput.Err <- nil
+
+			err := service.DeleteByName(ctx, "kraken", 282, 1)
+			Expect(err).To(Equal(persistence.ErrVersionMismatch))
+
+			Expect(mockGarbanzoStore.DeleteByOctoIdCalled).To(BeEmpty())
+			Expect(mockOctoStore.DeleteByIdCalled).To(BeEmpty())
+			Expect(mockTx.RollbackCalled).To(HaveLen(1))
+		})
+
+		It("rolls back and returns an error if it can't fetch the child garbanzos to audit", func() {
+			mockDB.BeginTxOutput.Database <- mockTx
+			mockDB.BeginTxOutput.Err <- nil
+
+			mockOctoStore.FetchByNameOutput.Octo <- data.Octo{Id: 282, Name: "kraken"}
+			mockOctoStore.FetchByNameOutput.Err <- nil
+
+			mockGarbanzoStore.FetchByOctoNameOutput.Garbanzos <- nil
+			mockGarbanzoStore.FetchByOctoNameOutput.More <- false
+			mockGarbanzoStore.FetchByOctoNameOutput.Err <- errors.New("don't bother")
+
+			mockTx.RollbackOutput.Err <- nil
+
+			err := service.DeleteByName(ctx, "kraken", 0, 0)
+			Expect(err).To(MatchError("don't bother"))
+
+			Expect(mockGarbanzoStore.DeleteByOctoIdCalled).To(BeEmpty())
+			Expect(mockTx.RollbackCalled).To(HaveLen(1))
+		})
+
 		It("rolls back and returns an error if it can't delete the child garbanzos", func() {
 			mockDB.BeginTxOutput.Database <- mockTx
 			mockDB.BeginTxOutput.Err <- nil
@@ -172,11 +565,15 @@ var _ = Describe("Octo", func() {
 			}
 			mockOctoStore.FetchByNameOutput.Err <- nil
 
+			mockGarbanzoStore.FetchByOctoNameOutput.Garbanzos <- nil
+			mockGarbanzoStore.FetchByOctoNameOutput.More <- false
+			mockGarbanzoStore.FetchByOctoNameOutput.Err <- nil
+
 			mockGarbanzoStore.DeleteByOctoIdOutput.Err <- errors.New("don't bother")
 
 			mockTx.RollbackOutput.Err <- nil
 
-			err := service.DeleteByName(ctx, "kraken")
+			err := service.DeleteByName(ctx, "kraken", 0, 0)
 			Expect(err).To(HaveOccurred())
 			Expect(err.Error()).To(Equal("don't bother"))
 
@@ -193,13 +590,17 @@ var _ = Describe("Octo", func() {
 			}
 			mockOctoStore.FetchByNameOutput.Err <- nil
 
+			mockGarbanzoStore.FetchByOctoNameOutput.Garbanzos <- nil
+			mockGarbanzoStore.FetchByOctoNameOutput.More <- false
+			mockGarbanzoStore.FetchByOctoNameOutput.Err <- nil
+
 			mockGarbanzoStore.DeleteByOctoIdOutput.Err <- nil
 
 			mockOctoStore.DeleteByIdOutput.Err <- errors.New("some error")
 
 			mockTx.RollbackOutput.Err <- nil
 
-			err := service.DeleteByName(ctx, "kraken")
+			err := service.DeleteByName(ctx, "kraken", 0, 0)
 			Expect(err).To(HaveOccurred())
 			Expect(err.Error()).To(Equal("some error"))
 
@@ -212,18 +613,26 @@ var _ = Describe("Octo", func() {
 
 			id := 282
 			mockOctoStore.FetchByNameOutput.Octo <- data.Octo{
-				Id:   id,
-				Name: "kraken",
+				Id:      id,
+				Name:    "kraken",
+				Version: 3,
 			}
 			mockOctoStore.FetchByNameOutput.Err <- nil
 
+			mockGarbanzoStore.FetchByOctoNameOutput.Garbanzos <- nil
+			mockGarbanzoStore.FetchByOctoNameOutput.More <- false
+			mockGarbanzoStore.FetchByOctoNameOutput.Err <- nil
+
 			mockGarbanzoStore.DeleteByOctoIdOutput.Err <- nil
 
 			mockOctoStore.DeleteByIdOutput.Err <- nil
 
+			mockAuditStore.CreateOutput.EventId <- 7
+			mockAuditStore.CreateOutput.Err <- nil
+
 			mockTx.CommitOutput.Err <- nil
 
-			actualErr := service.DeleteByName(ctx, "kraken")
+			actualErr := service.DeleteByName(ctx, "kraken", 282, 3)
 			Expect(actualErr).NotTo(HaveOccurred())
 
 			Expect(mockOctoStore.FetchByNameCalled).To(HaveLen(1))
@@ -232,7 +641,7 @@ var _ = Describe("Octo", func() {
 			Expect(actualDB).To(Equal(mockTx))
 			var actualCtx context.Context
 			Expect(mockOctoStore.FetchByNameInput.Ctx).To(Receive(&actualCtx))
-			Expect(actualCtx).To(Equal(ctx))
+			Expect(actualCtx.Value(persistence.OrgContextKey)).To(Equal("my-org"))
 			var actualName string
 			Expect(mockOctoStore.FetchByNameInput.Name).To(Receive(&actualName))
 			Expect(actualName).To(Equal("kraken"))
@@ -244,7 +653,7 @@ var _ = Describe("Octo", func() {
 			Expect(mockGarbanzoStore.DeleteByOctoIdInput.Database).To(Receive(&actualDB))
 			Expect(actualDB).To(Equal(mockTx))
 			Expect(mockGarbanzoStore.DeleteByOctoIdInput.Ctx).To(Receive(&actualCtx))
-			Expect(actualCtx).To(Equal(ctx))
+			Expect(actualCtx.Value(persistence.OrgContextKey)).To(Equal("my-org"))
 			var actualId int
 			Expect(mockGarbanzoStore.DeleteByOctoIdInput.OctoId).To(Receive(&actualId))
 			Expect(actualId).To(Equal(id))
@@ -253,11 +662,107 @@ var _ = Describe("Octo", func() {
 			Expect(mockOctoStore.DeleteByIdInput.Database).To(Receive(&actualDB))
 			Expect(actualDB).To(Equal(mockTx))
 			Expect(mockOctoStore.DeleteByIdInput.Ctx).To(Receive(&actualCtx))
-			Expect(actualCtx).To(Equal(ctx))
+			Expect(actualCtx.Value(persistence.OrgContextKey)).To(Equal("my-org"))
 			Expect(mockOctoStore.DeleteByIdInput.Id).To(Receive(&actualId))
 			Expect(actualId).To(Equal(id))
+			var actualVersion int
+			Expect(mockOctoStore.DeleteByIdInput.Version).To(Receive(&actualVersion))
+			Expect(actualVersion).To(Equal(3))
+
+			Expect(mockAuditStore.CreateInput.Database).To(Receive(Equal(mockTx)))
+			var event data.AuditEvent
+			Expect(mockAuditStore.CreateInput.Event).To(Receive(&event))
+			Expect(event.Action).To(Equal(services.AuditDelete))
+			Expect(event.Resource).To(Equal("octos/kraken"))
+			Expect(event.Before).To(MatchJSON(`{"name": "kraken", "version": 3}`))
+			Expect(event.After).To(BeNil())
+
+			Expect(mockTx.CommitCalled).To(HaveLen(1))
+		})
+
+		It("audits the deletion of each of the octo's garbanzos before the octo's", func() {
+			mockDB.BeginTxOutput.Database <- mockTx
+			mockDB.BeginTxOutput.Err <- nil
+
+			mockOctoStore.FetchByNameOutput.Octo <- data.Octo{Id: 282, Name: "kraken", Version: 3}
+			mockOctoStore.FetchByNameOutput.Err <- nil
+
+			kabuli := data.GarbanzoType{Id: 1, Name: "kabuli"}
+			garbanzo1 := data.Garbanzo{Id: 10, APIUUID: uuid.NewV4(), GarbanzoType: kabuli, OctoId: 282, DiameterMM: 4.5, Version: 1}
+			garbanzo2 := data.Garbanzo{Id: 20, APIUUID: uuid.NewV4(), GarbanzoType: kabuli, OctoId: 282, DiameterMM: 5.5, Version: 2}
+			// The garbanzos are fetched a page at a time
+			mockGarbanzoStore.FetchByOctoNameOutput.Garbanzos <- []data.Garbanzo{garbanzo1}
+			mockGarbanzoStore.FetchByOctoNameOutput.More <- true
+			mockGarbanzoStore.FetchByOctoNameOutput.Err <- nil
+			mockGarbanzoStore.FetchByOctoNameOutput.Garbanzos <- []data.Garbanzo{garbanzo2}
+			mockGarbanzoStore.FetchByOctoNameOutput.More <- false
+			mockGarbanzoStore.FetchByOctoNameOutput.Err <- nil
+
+			mockGarbanzoStore.DeleteByOctoIdOutput.Err <- nil
+			mockOctoStore.DeleteByIdOutput.Err <- nil
+
+			for i := 0; i < 3; i++ {
+				mockAuditStore.CreateOutput.EventId <- i + 1
+				mockAuditStore.CreateOutput.Err <- nil
+			}
+
+			mockTx.CommitOutput.Err <- nil
+
+			err := service.DeleteByName(ctx, "kraken", 0, 0)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(mockGarbanzoStore.FetchByOctoNameCalled).To(HaveLen(2))
+			var page persistence.Page
+			Expect(mockGarbanzoStore.FetchByOctoNameInput.Page).To(Receive(&page))
+			Expect(page.AfterId).To(Equal(0))
+			Expect(mockGarbanzoStore.FetchByOctoNameInput.Page).To(Receive(&page))
+			Expect(page.AfterId).To(Equal(10))
+			var octoName string
+			Expect(mockGarbanzoStore.FetchByOctoNameInput.OctoName).To(Receive(&octoName))
+			Expect(octoName).To(Equal("kraken"))
+			var actualDB persistence.Database
+			Expect(mockGarbanzoStore.FetchByOctoNameInput.Database).To(Receive(&actualDB))
+			Expect(actualDB).To(Equal(mockTx))
+
+			Expect(mockAuditStore.CreateCalled).To(HaveLen(3))
+			var event data.AuditEvent
+			Expect(mockAuditStore.CreateInput.Event).To(Receive(&event))
+			Expect(event.Action).To(Equal(services.AuditDelete))
+			Expect(event.Resource).To(Equal("octos/kraken/garbanzos/" + garbanzo1.APIUUID.String()))
+			Expect(event.Before).To(MatchJSON(fmt.Sprintf(
+				`{"api-uuid": "%s", "octo": "kraken", "type": "kabuli", "diameter-mm": 4.5, "version": 1}`, garbanzo1.APIUUID)))
+			Expect(event.After).To(BeNil())
+			Expect(mockAuditStore.CreateInput.Event).To(Receive(&event))
+			Expect(event.Action).To(Equal(services.AuditDelete))
+			Expect(event.Resource).To(Equal("octos/kraken/garbanzos/" + garbanzo2.APIUUID.String()))
+			Expect(event.Before).To(MatchJSON(fmt.Sprintf(
+				`{"api-uuid": "%s", "octo": "kraken", "type": "kabuli", "diameter-mm": 5.5, "version": 2}`, garbanzo2.APIUUID)))
+			Expect(mockAuditStore.CreateInput.Event).To(Receive(&event))
+			Expect(event.Action).To(Equal(services.AuditDelete))
+			Expect(event.Resource).To(Equal("octos/kraken"))
 
 			Expect(mockTx.CommitCalled).To(HaveLen(1))
 		})
+
+		It("rolls back the deletion when the audit event can't be recorded", func() {
+			mockDB.BeginTxOutput.Database <- mockTx
+			mockDB.BeginTxOutput.Err <- nil
+			mockOctoStore.FetchByNameOutput.Octo <- data.Octo{Id: 282, Name: "kraken"}
+			mockOctoStore.FetchByNameOutput.Err <- nil
+			mockGarbanzoStore.FetchByOctoNameOutput.Garbanzos <- nil
+			mockGarbanzoStore.FetchByOctoNameOutput.More <- false
+			mockGarbanzoStore.FetchByOctoNameOutput.Err <- nil
+			mockGarbanzoStore.DeleteByOctoIdOutput.Err <- nil
+			mockOctoStore.DeleteByIdOutput.Err <- nil
+			mockAuditStore.CreateOutput.EventId <- 0
+			mockAuditStore.CreateOutput.Err <- errors.New("don't bother")
+			mockTx.RollbackOutput.Err <- nil
+
+			err := service.DeleteByName(ctx, "kraken", 0, 0)
+			Expect(err).To(MatchError("don't bother"))
+
+			Expect(mockTx.RollbackCalled).To(HaveLen(1))
+			Expect(mockTx.CommitCalled).To(BeEmpty())
+		})
 	})
 })
@@ -0,0 +1,105 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+
+	"github.com/myshkin5/effective-octo-garbanzo/persistence"
+	"github.com/myshkin5/effective-octo-garbanzo/persistence/data"
+)
+
+const maxOrgNameLength = 40
+
+type OrgStore interface {
+	FetchAll(ctx context.Context, database persistence.Database) (orgs []data.Org, err error)
+	FetchByName(ctx context.Context, database persistence.Database, name string, selectForUpdate bool) (org data.Org, err error)
+	Create(ctx context.Context, database persistence.Database, org data.Org) (orgId int, err error)
+	Provision(ctx context.Context, database persistence.Database, name string) (err error)
+	DeleteById(ctx context.Context, database persistence.Database, id int) (err error)
+}
+
+type OrgService struct {
+	orgStore OrgStore
+	database persistence.Database
+}
+
+func NewOrgService(orgStore OrgStore, database persistence.Database) *OrgService {
+	return &OrgService{
+		orgStore: orgStore,
+		database: database,
+	}
+}
+
+func (s *OrgService) FetchAll(ctx context.Context) ([]data.Org, error) {
+	return s.orgStore.FetchAll(ctx, s.database)
+}
+
+func (s *OrgService) FetchByName(ctx context.Context, name string) (data.Org, error) {
+	return s.orgStore.FetchByName(ctx, s.database, name, false)
+}
+
+func (s *OrgService) Create(ctx context.Context, org data.Org) (data.Org, error) {
+	err := s.validate(org.Name)
+	if err != nil {
+		return data.Org{}, err
+	}
+
+	org.Id, err = s.orgStore.Create(ctx, s.database, org)
+	if err != nil {
+		return data.Org{}, err
+	}
+
+	return org, nil
+}
+
+// Provision creates the named org if it doesn't already exist.
+func (s *OrgService) Provision(ctx context.Context, name string) error {
+	err := s.validate(name)
+	if err != nil {
+		return err
+	}
+
+	return s.orgStore.Provision(ctx, s.database, name)
+}
+
+func (s *OrgService) validate(name string) error {
+	errors := make(map[string][]string)
+	if len(name) == 0 {
+		errors["Name"] = append(errors["Name"], "must be present")
+	}
+	if len(name) > maxOrgNameLength {
+		errors["Name"] = append(errors["Name"], fmt.Sprintf("must be at most %d characters", maxOrgNameLength))
+	}
+	validName := regexp.MustCompile(`^[\w-]+$`)
+	if !validName.MatchString(name) {
+		errors["Name"] = append(errors["Name"], fmt.Sprintf("must match regular expression '%s'", validName.String()))
+	}
+
+	if len(errors) > 0 {
+		return NewValidationError(errors)
+	}
+
+	return nil
+}
+
+func (s *OrgService) DeleteByName(ctx context.Context, name string) (err error) {
+	database, err := s.database.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			database.Rollback()
+			return
+		}
+		err = database.Commit()
+	}()
+
+	org, err := s.orgStore.FetchByName(ctx, database, name, true)
+	if err != nil {
+		return err
+	}
+
+	return s.orgStore.DeleteById(ctx, database, org.Id)
+}
@@ -0,0 +1,184 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/myshkin5/effective-octo-garbanzo/persistence"
+	"github.com/myshkin5/effective-octo-garbanzo/persistence/data"
+	"github.com/myshkin5/effective-octo-garbanzo/services"
+)
+
+var _ = Describe("Org", func() {
+	var (
+		mockOrgStore *mockOrgStore
+		mockDB       *mockDatabase
+		mockTx       *mockDatabase
+		service      *services.OrgService
+		ctx          context.Context
+	)
+
+	BeforeEach(func() {
+		mockOrgStore = newMockOrgStore()
+		mockDB = newMockDatabase()
+		mockTx = newMockDatabase()
+		ctx = context.Background()
+
+		service = services.NewOrgService(mockOrgStore, mockDB)
+	})
+
+	It("fetches all orgs", func() {
+		orgs := []data.Org{{Id: 3, Name: "org1"}}
+		mockOrgStore.FetchAllOutput.Orgs <- orgs
+		err := errors.New("some error")
+		mockOrgStore.FetchAllOutput.Err <- err
+
+		actualOrgs, actualErr := service.FetchAll(ctx)
+
+		Expect(actualOrgs).To(Equal(orgs))
+		Expect(actualErr).To(Equal(err))
+
+		Expect(mockOrgStore.FetchAllInput.Database).To(Receive(Equal(mockDB)))
+		Expect(mockOrgStore.FetchAllInput.Ctx).To(Receive(Equal(ctx)))
+	})
+
+	It("fetches an org by name", func() {
+		org := data.Org{Id: 3, Name: "org1"}
+		mockOrgStore.FetchByNameOutput.Org <- org
+		mockOrgStore.FetchByNameOutput.Err <- nil
+
+		actualOrg, actualErr := service.FetchByName(ctx, "org1")
+
+		Expect(actualOrg).To(Equal(org))
+		Expect(actualErr).NotTo(HaveOccurred())
+
+		Expect(mockOrgStore.FetchByNameInput.Database).To(Receive(Equal(mockDB)))
+		Expect(mockOrgStore.FetchByNameInput.Name).To(Receive(Equal("org1")))
+		Expect(mockOrgStore.FetchByNameInput.SelectForUpdate).To(Receive(BeFalse()))
+	})
+
+	Describe("Create", func() {
+		It("creates an org", func() {
+			mockOrgStore.CreateOutput.OrgId <- 42
+			mockOrgStore.CreateOutput.Err <- nil
+
+			actualOrg, actualErr := service.Create(ctx, data.Org{Name: "org1"})
+
+			Expect(actualErr).NotTo(HaveOccurred())
+			Expect(actualOrg).To(Equal(data.Org{Id: 42, Name: "org1"}))
+
+			Expect(mockOrgStore.CreateInput.Database).To(Receive(Equal(mockDB)))
+			Expect(mockOrgStore.CreateInput.Org).To(Receive(Equal(data.Org{Name: "org1"})))
+		})
+
+		It("returns a store error", func() {
+			mockOrgStore.CreateOutput.OrgId <- 0
+			mockOrgStore.CreateOutput.Err <- persistence.ErrDuplicate
+
+			_, err := service.Create(ctx, data.Org{Name: "org1"})
+			Expect(err).To(Equal(persistence.ErrDuplicate))
+		})
+
+		It("returns a validation error for an empty org name", func() {
+			_, err := service.Create(ctx, data.Org{})
+			Expect(err).To(Equal(services.NewValidationError(map[string][]string{"Name": {
+				"must be present",
+				"must match regular expression '^[\\w-]+$'",
+			}})))
+			Expect(mockOrgStore.CreateCalled).To(BeEmpty())
+		})
+
+		It("returns a validation error for an org name which is too long", func() {
+			_, err := service.Create(ctx, data.Org{Name: strings.Repeat("o", 41)})
+			Expect(err).To(Equal(services.NewValidationError(map[string][]string{"Name": {
+				"must be at most 40 characters",
+			}})))
+		})
+	})
+
+	Describe("Provision", func() {
+		It("provisions an org", func() {
+			mockOrgStore.ProvisionOutput.Err <- nil
+
+			Expect(service.Provision(ctx, "org1")).To(Succeed())
+
+			Expect(mockOrgStore.ProvisionInput.Database).To(Receive(Equal(mockDB)))
+			Expect(mockOrgStore.ProvisionInput.Name).To(Receive(Equal("org1")))
+		})
+
+		It("returns a validation error for an org name with invalid characters", func() {
+			err := service.Provision(ctx, "org 1")
+			Expect(err).To(Equal(services.NewValidationError(map[string][]string{"Name": {
+				"must match regular expression '^[\\w-]+$'",
+			}})))
+			Expect(mockOrgStore.ProvisionCalled).To(BeEmpty())
+		})
+	})
+
+	Describe("DeleteByName", func() {
+		It("returns an error if it can't start a transaction", func() {
+			mockDB.BeginTxOutput.Database <- nil
+			mockDB.BeginTxOutput.Err <- errors.New("don't bother")
+
+			err := service.DeleteByName(ctx, "org1")
+			Expect(err).To(MatchError("don't bother"))
+		})
+
+		It("rolls back and returns an error if it can't select the org for update", func() {
+			mockDB.BeginTxOutput.Database <- mockTx
+			mockDB.BeginTxOutput.Err <- nil
+
+			mockOrgStore.FetchByNameOutput.Org <- data.Org{}
+			mockOrgStore.FetchByNameOutput.Err <- persistence.ErrNotFound
+
+			mockTx.RollbackOutput.Err <- nil
+
+			err := service.DeleteByName(ctx, "org1")
+			Expect(err).To(Equal(persistence.ErrNotFound))
+
+			Expect(mockTx.RollbackCalled).To(HaveLen(1))
+		})
+
+		It("rolls back and returns an error if it can't delete the org", func() {
+			mockDB.BeginTxOutput.Database <- mockTx
+			mockDB.BeginTxOutput.Err <- nil
+
+			mockOrgStore.FetchByNameOutput.Org <- data.Org{Id: 3, Name: "org1"}
+			mockOrgStore.FetchByNameOutput.Err <- nil
+
+			mockOrgStore.DeleteByIdOutput.Err <- persistence.ErrInUse
+
+			mockTx.RollbackOutput.Err <- nil
+
+			err := service.DeleteByName(ctx, "org1")
+			Expect(err).To(Equal(persistence.ErrInUse))
+
+			Expect(mockTx.RollbackCalled).To(HaveLen(1))
+		})
+
+		It("deletes an org by name", func() {
+			mockDB.BeginTxOutput.Database <- mockTx
+			mockDB.BeginTxOutput.Err <- nil
+
+			mockOrgStore.FetchByNameOutput.Org <- data.Org{Id: 3, Name: "org1"}
+			mockOrgStore.FetchByNameOutput.Err <- nil
+
+			mockOrgStore.DeleteByIdOutput.Err <- nil
+
+			mockTx.CommitOutput.Err <- nil
+
+			Expect(service.DeleteByName(ctx, "org1")).To(Succeed())
+
+			Expect(mockOrgStore.FetchByNameInput.Database).To(Receive(Equal(mockTx)))
+			Expect(mockOrgStore.FetchByNameInput.Name).To(Receive(Equal("org1")))
+			Expect(mockOrgStore.FetchByNameInput.SelectForUpdate).To(Receive(BeTrue()))
+			Expect(mockOrgStore.DeleteByIdInput.Database).To(Receive(Equal(mockTx)))
+			Expect(mockOrgStore.DeleteByIdInput.Id).To(Receive(Equal(3)))
+			Expect(mockTx.CommitCalled).To(HaveLen(1))
+		})
+	})
+})
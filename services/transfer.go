@@ -0,0 +1,278 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/satori/go.uuid"
+
+	"github.com/myshkin5/effective-octo-garbanzo/persistence"
+	"github.com/myshkin5/effective-octo-garbanzo/persistence/data"
+	"github.com/myshkin5/effective-octo-garbanzo/tracing"
+)
+
+var (
+	ErrRecordExists = errors.New("record already exists")
+)
+
+// ConflictStrategy decides what an import does with an octo or garbanzo that
+// already exists. Octos are matched by name and garbanzos by API UUID.
+type ConflictStrategy string
+
+const (
+	ConflictFail      ConflictStrategy = "fail"
+	ConflictSkip      ConflictStrategy = "skip"
+	ConflictOverwrite ConflictStrategy = "overwrite"
+)
+
+// Record is one octo or garbanzo of an export or import. A garbanzo record
+// names its octo with Octo.Name.
+type Record struct {
+	Octo     data.Octo
+	Garbanzo *data.Garbanzo
+}
+
+// ImportError is the error of the record at Index (from 1) of an import.
+type ImportError struct {
+	Index  int
+	Record Record
+	Err    error
+}
+
+func (e ImportError) Error() string {
+	return fmt.Sprintf("record %d: %v", e.Index, e.Err)
+}
+
+type ImportCounts struct {
+	Created     int
+	Skipped     int
+	Overwritten int
+}
+
+type ImportSummary struct {
+	Octos     ImportCounts
+	Garbanzos ImportCounts
+}
+
+// TransferService exports and imports all of an org's octos and garbanzos,
+// e.g. to back up an org or move it to another deployment. Imported changes
+// are audited like those made through the octo and garbanzo endpoints.
+type TransferService struct {
+	octoStore     OctoStore
+	garbanzoStore GarbanzoStore
+	garbanzoTypes GarbanzoTypes
+	auditLog      *AuditLog
+	database      persistence.Database
+}
+
+func NewTransferService(octoStore OctoStore, garbanzoStore GarbanzoStore, garbanzoTypes GarbanzoTypes, auditLog *AuditLog, database persistence.Database) *TransferService {
+	return &TransferService{
+		octoStore:     octoStore,
+		garbanzoStore: garbanzoStore,
+		garbanzoTypes: garbanzoTypes,
+		auditLog:      auditLog,
+		database:      database,
+	}
+}
+
+// Export streams a record of each of the org's octos to fn, each immediately
+// followed by a record of each of that octo's garbanzos, so every garbanzo
+// follows its octo as Import requires.
+func (s *TransferService) Export(ctx context.Context, fn func(record Record) error) error {
+	ctx, span := tracing.Start(ctx, "TransferService.Export")
+	defer span.End()
+
+	return s.octoStore.ForEachWithGarbanzos(ctx, s.database, func(octo data.Octo, garbanzo *data.Garbanzo) error {
+		return fn(Record{Octo: octo, Garbanzo: garbanzo})
+	})
+}
+
+// Import creates the records returned by next until it returns io.EOF, all in
+// one transaction. A garbanzo's octo must precede it or already exist. Any
+// error, including one from next, is returned as an ImportError and nothing
+// is imported.
+func (s *TransferService) Import(ctx context.Context, conflict ConflictStrategy, next func() (Record, error)) (summary ImportSummary, err error) {
+	ctx, span := tracing.Start(ctx, "TransferService.Import")
+	defer span.End()
+
+	// Validated before the transaction begins, like any other create, as
+	// resolving a garbanzo type may reload the types outside of it
+	records, err := s.validate(ctx, next)
+	if err != nil {
+		return ImportSummary{}, err
+	}
+
+	database, err := s.database.BeginTx(ctx)
+	if err != nil {
+		return ImportSummary{}, err
+	}
+	defer func() {
+		if err != nil {
+			database.Rollback()
+			return
+		}
+		err = database.Commit()
+	}()
+
+	octos := make(map[string]data.Octo)
+	for i, record := range records {
+		if record.Garbanzo == nil {
+			err = s.importOcto(ctx, database, conflict, record.Octo, octos, &summary.Octos)
+		} else {
+			err = s.importGarbanzo(ctx, database, conflict, record, octos, &summary.Garbanzos)
+		}
+		if err != nil {
+			err = ImportError{Index: i + 1, Record: record, Err: err}
+			return ImportSummary{}, err
+		}
+	}
+
+	return summary, nil
+}
+
+// validate reads the records returned by next until it returns io.EOF and
+// returns them with their garbanzo types resolved.
+func (s *TransferService) validate(ctx context.Context, next func() (Record, error)) ([]Record, error) {
+	var records []Record
+	for index := 1; ; index++ {
+		record, err := next()
+		if err == io.EOF {
+			return records, nil
+		} else if err != nil {
+			return nil, ImportError{Index: index, Err: err}
+		}
+
+		if record.Garbanzo == nil {
+			err = validateOcto(record.Octo)
+			if err != nil {
+				return nil, ImportError{Index: index, Record: record, Err: err}
+			}
+			records = append(records, record)
+			continue
+		}
+
+		garbanzo, err := validateGarbanzo(ctx, s.garbanzoTypes, *record.Garbanzo)
+		if err != nil {
+			return nil, ImportError{Index: index, Record: record, Err: err}
+		}
+		garbanzo.APIUUID = record.Garbanzo.APIUUID
+		records = append(records, Record{Octo: record.Octo, Garbanzo: &garbanzo})
+	}
+}
+
+func (s *TransferService) importOcto(ctx context.Context, database persistence.Database, conflict ConflictStrategy, octo data.Octo, octos map[string]data.Octo, counts *ImportCounts) error {
+	existing, err := s.octoStore.FetchByName(ctx, database, octo.Name, true)
+	if err == persistence.ErrNotFound {
+		octo.Id, err = s.octoStore.Create(ctx, database, octo)
+		if err != nil {
+			return err
+		}
+		octo.Version = 1
+
+		err = s.auditLog.record(ctx, database, AuditCreate, octoResource(octo.Name), nil, auditOcto(octo))
+		if err != nil {
+			return err
+		}
+		octos[octo.Name] = octo
+		counts.Created++
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	// An octo is only its name so overwriting one leaves it as it is and
+	// there is nothing to audit
+	switch conflict {
+	case ConflictSkip:
+		counts.Skipped++
+	case ConflictOverwrite:
+		counts.Overwritten++
+	default:
+		return ErrRecordExists
+	}
+	octos[octo.Name] = existing
+
+	return nil
+}
+
+func (s *TransferService) importGarbanzo(ctx context.Context, database persistence.Database, conflict ConflictStrategy, record Record, octos map[string]data.Octo, counts *ImportCounts) error {
+	garbanzo := *record.Garbanzo
+
+	var err error
+	octo, ok := octos[record.Octo.Name]
+	if !ok {
+		octo, err = s.octoStore.FetchByName(ctx, database, record.Octo.Name, true)
+		if err != nil {
+			return err
+		}
+		octos[octo.Name] = octo
+	}
+	garbanzo.OctoId = octo.Id
+
+	var existing data.Garbanzo
+	var existingOctoName string
+	if garbanzo.APIUUID == (uuid.UUID{}) {
+		garbanzo.APIUUID = uuid.NewV4()
+		err = persistence.ErrNotFound
+	} else {
+		existing, existingOctoName, err = s.garbanzoStore.FetchByAPIUUID(ctx, database, garbanzo.APIUUID)
+	}
+	if err == persistence.ErrNotFound {
+		garbanzo.Id, err = s.garbanzoStore.Create(ctx, database, garbanzo)
+		if err != nil {
+			return err
+		}
+		garbanzo.Version = 1
+
+		err = s.auditLog.record(ctx, database, AuditCreate, garbanzoResource(octo.Name, garbanzo.APIUUID),
+			nil, auditGarbanzo(garbanzo, octo.Name))
+		if err != nil {
+			return err
+		}
+		counts.Created++
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	switch conflict {
+	case ConflictSkip:
+		counts.Skipped++
+		return nil
+	case ConflictOverwrite:
+	default:
+		return ErrRecordExists
+	}
+
+	if existing.OctoId != octo.Id {
+		before := auditGarbanzo(existing, existingOctoName)
+		existing.OctoId = octo.Id
+		existing.Version, err = s.garbanzoStore.MoveById(ctx, database, existing.Id, octo.Id, 0)
+		if err != nil {
+			return err
+		}
+
+		err = s.auditLog.recordMove(ctx, database, AuditMove, garbanzoResource(existingOctoName, garbanzo.APIUUID),
+			garbanzoResource(octo.Name, garbanzo.APIUUID), before, auditGarbanzo(existing, octo.Name))
+		if err != nil {
+			return err
+		}
+	}
+
+	garbanzo.Id = existing.Id
+	garbanzo.Version, err = s.garbanzoStore.UpdateByAPIUUIDAndOctoName(ctx, database, garbanzo, octo.Name)
+	if err != nil {
+		return err
+	}
+
+	err = s.auditLog.record(ctx, database, AuditUpdate, garbanzoResource(octo.Name, garbanzo.APIUUID),
+		auditGarbanzo(existing, octo.Name), auditGarbanzo(garbanzo, octo.Name))
+	if err != nil {
+		return err
+	}
+	counts.Overwritten++
+
+	return nil
+}
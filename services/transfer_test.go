@@ -0,0 +1,460 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/satori/go.uuid"
+
+	"github.com/myshkin5/effective-octo-garbanzo/persistence"
+	"github.com/myshkin5/effective-octo-garbanzo/persistence/data"
+	"github.com/myshkin5/effective-octo-garbanzo/persistence/memory"
+	"github.com/myshkin5/effective-octo-garbanzo/services"
+)
+
+var _ = Describe("Transfer", func() {
+	var (
+		mockOctoStore     *mockOctoStore
+		mockGarbanzoStore *mockGarbanzoStore
+		mockGarbanzoTypes *mockGarbanzoTypes
+		mockAuditStore    *mockAuditEventStore
+		mockDB            *mockDatabase
+		mockTx            *mockDatabase
+		service           *services.TransferService
+		ctx               context.Context
+	)
+
+	BeforeEach(func() {
+		mockOctoStore = newMockOctoStore()
+		mockGarbanzoStore = newMockGarbanzoStore()
+		mockGarbanzoTypes = newMockGarbanzoTypes()
+		mockAuditStore = newMockAuditEventStore()
+		mockDB = newMockDatabase()
+		mockTx = newMockDatabase()
+		ctx = context.WithValue(context.Background(), persistence.OrgContextKey, "my-org")
+		ctx = context.WithValue(ctx, persistence.SubjectContextKey, "user1")
+		ctx = context.WithValue(ctx, persistence.RequestIDContextKey, "req1")
+
+		service = services.NewTransferService(mockOctoStore, mockGarbanzoStore, mockGarbanzoTypes,
+			services.NewAuditLog(mockAuditStore, mockDB), mockDB)
+	})
+
+	It("exports a record of each octo and garbanzo", func() {
+		mockOctoStore.ForEachWithGarbanzosOutput.Err <- nil
+
+		var records []services.Record
+		err := service.Export(ctx, func(record services.Record) error {
+			records = append(records, record)
+			return nil
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(mockOctoStore.ForEachWithGarbanzosInput.Database).To(Receive(Equal(mockDB)))
+		var fn func(octo data.Octo, garbanzo *data.Garbanzo) error
+		Expect(mockOctoStore.ForEachWithGarbanzosInput.Fn).To(Receive(&fn))
+		octo := data.Octo{Id: 7, Name: "kraken"}
+		garbanzo := data.Garbanzo{APIUUID: uuid.NewV4(), GarbanzoType: desi, DiameterMM: 4.2}
+		Expect(fn(octo, nil)).To(Succeed())
+		Expect(fn(octo, &garbanzo)).To(Succeed())
+
+		Expect(records).To(Equal([]services.Record{
+			{Octo: octo},
+			{Octo: octo, Garbanzo: &garbanzo},
+		}))
+	})
+
+	Describe("Import", func() {
+		var (
+			records  []services.Record
+			nextErr  error
+			next     func() (services.Record, error)
+			apiUUID  uuid.UUID
+			kraken   data.Octo
+			garbanzo data.Garbanzo
+		)
+
+		BeforeEach(func() {
+			apiUUID = uuid.NewV4()
+			kraken = data.Octo{Id: 77, Name: "kraken", Version: 1}
+			garbanzo = data.Garbanzo{APIUUID: apiUUID, GarbanzoType: data.GarbanzoType{Name: "DESI"}, DiameterMM: 4.2}
+			records = []services.Record{
+				{Octo: data.Octo{Name: "kraken"}},
+				{Octo: data.Octo{Name: "kraken"}, Garbanzo: &garbanzo},
+			}
+			nextErr = io.EOF
+			next = func() (services.Record, error) {
+				if len(records) == 0 {
+					return services.Record{}, nextErr
+				}
+				record := records[0]
+				records = records[1:]
+				return record, nil
+			}
+
+			mockDB.BeginTxOutput.Database <- mockTx
+			mockDB.BeginTxOutput.Err <- nil
+			mockGarbanzoTypes.FetchByNameOutput.GarbanzoType <- desi
+			mockGarbanzoTypes.FetchByNameOutput.Err <- nil
+		})
+
+		It("creates the octos and garbanzos that don't exist in one transaction", func() {
+			mockOctoStore.FetchByNameOutput.Octo <- data.Octo{}
+			mockOctoStore.FetchByNameOutput.Err <- persistence.ErrNotFound
+			mockOctoStore.CreateOutput.OctoId <- kraken.Id
+			mockOctoStore.CreateOutput.Err <- nil
+			mockGarbanzoStore.FetchByAPIUUIDOutput.Garbanzo <- data.Garbanzo{}
+			mockGarbanzoStore.FetchByAPIUUIDOutput.OctoName <- ""
+			mockGarbanzoStore.FetchByAPIUUIDOutput.Err <- persistence.ErrNotFound
+			mockGarbanzoStore.CreateOutput.GarbanzoId <- 42
+			mockGarbanzoStore.CreateOutput.Err <- nil
+			for i := 0; i < 2; i++ {
+				mockAuditStore.CreateOutput.EventId <- 7 + i
+				mockAuditStore.CreateOutput.Err <- nil
+			}
+			mockTx.CommitOutput.Err <- nil
+
+			summary, err := service.Import(ctx, services.ConflictFail, next)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(summary).To(Equal(services.ImportSummary{
+				Octos:     services.ImportCounts{Created: 1},
+				Garbanzos: services.ImportCounts{Created: 1},
+			}))
+
+			Expect(mockOctoStore.FetchByNameInput.Database).To(Receive(Equal(mockTx)))
+			Expect(mockOctoStore.FetchByNameInput.SelectForUpdate).To(Receive(BeTrue()))
+			Expect(mockOctoStore.CreateInput.Octo).To(Receive(Equal(data.Octo{Name: "kraken"})))
+			Expect(mockGarbanzoStore.FetchByAPIUUIDInput.ApiUUID).To(Receive(Equal(apiUUID)))
+			Expect(mockGarbanzoStore.CreateInput.Database).To(Receive(Equal(mockTx)))
+			Expect(mockGarbanzoStore.CreateInput.Garbanzo).To(Receive(Equal(data.Garbanzo{
+				APIUUID:      apiUUID,
+				GarbanzoType: desi,
+				OctoId:       kraken.Id,
+				DiameterMM:   4.2,
+			})))
+
+			Expect(mockAuditStore.CreateCalled).To(HaveLen(2))
+			Expect(mockAuditStore.CreateInput.Database).To(Receive(Equal(mockTx)))
+			var event data.AuditEvent
+			Expect(mockAuditStore.CreateInput.Event).To(Receive(&event))
+			Expect(event.Actor).To(Equal("user1"))
+			Expect(event.RequestID).To(Equal("req1"))
+			Expect(event.Action).To(Equal(services.AuditCreate))
+			Expect(event.Resource).To(Equal("octos/kraken"))
+			Expect(event.Before).To(BeNil())
+			Expect(event.After).To(MatchJSON(`{"name": "kraken", "version": 1}`))
+			Expect(mockAuditStore.CreateInput.Database).To(Receive(Equal(mockTx)))
+			Expect(mockAuditStore.CreateInput.Event).To(Receive(&event))
+			Expect(event.Action).To(Equal(services.AuditCreate))
+			Expect(event.Resource).To(Equal("octos/kraken/garbanzos/" + apiUUID.String()))
+			Expect(event.Before).To(BeNil())
+			Expect(event.After).To(MatchJSON(fmt.Sprintf(`{
+				"api-uuid":    "%s",
+				"octo":        "kraken",
+				"type":        "DESI",
+				"diameter-mm": 4.2,
+				"version":     1
+			}`, apiUUID)))
+
+			Expect(mockTx.CommitCalled).To(HaveLen(1))
+		})
+
+		It("rolls back the import when an audit event can't be recorded", func() {
+			mockOctoStore.FetchByNameOutput.Octo <- data.Octo{}
+			mockOctoStore.FetchByNameOutput.Err <- persistence.ErrNotFound
+			mockOctoStore.CreateOutput.OctoId <- kraken.Id
+			mockOctoStore.CreateOutput.Err <- nil
+			mockAuditStore.CreateOutput.EventId <- 0
+			mockAuditStore.CreateOutput.Err <- errors.New("no audit")
+			mockTx.RollbackOutput.Err <- nil
+
+			_, err := service.Import(ctx, services.ConflictFail, next)
+			Expect(err).To(Equal(services.ImportError{
+				Index:  1,
+				Record: services.Record{Octo: data.Octo{Name: "kraken"}},
+				Err:    errors.New("no audit"),
+			}))
+
+			Expect(mockGarbanzoStore.CreateCalled).To(BeEmpty())
+			Expect(mockTx.RollbackCalled).To(HaveLen(1))
+			Expect(mockTx.CommitCalled).To(BeEmpty())
+		})
+
+		It("rolls back and fails on the first record that exists", func() {
+			mockOctoStore.FetchByNameOutput.Octo <- kraken
+			mockOctoStore.FetchByNameOutput.Err <- nil
+			mockTx.RollbackOutput.Err <- nil
+
+			_, err := service.Import(ctx, services.ConflictFail, next)
+			Expect(err).To(Equal(services.ImportError{
+				Index:  1,
+				Record: services.Record{Octo: data.Octo{Name: "kraken"}},
+				Err:    services.ErrRecordExists,
+			}))
+
+			Expect(mockOctoStore.CreateCalled).To(BeEmpty())
+			Expect(mockTx.RollbackCalled).To(HaveLen(1))
+		})
+
+		It("skips the records that exist", func() {
+			mockOctoStore.FetchByNameOutput.Octo <- kraken
+			mockOctoStore.FetchByNameOutput.Err <- nil
+			mockGarbanzoStore.FetchByAPIUUIDOutput.Garbanzo <- data.Garbanzo{Id: 42, APIUUID: apiUUID, OctoId: kraken.Id}
+			mockGarbanzoStore.FetchByAPIUUIDOutput.OctoName <- kraken.Name
+			mockGarbanzoStore.FetchByAPIUUIDOutput.Err <- nil
+			mockTx.CommitOutput.Err <- nil
+
+			summary, err := service.Import(ctx, services.ConflictSkip, next)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(summary).To(Equal(services.ImportSummary{
+				Octos:     services.ImportCounts{Skipped: 1},
+				Garbanzos: services.ImportCounts{Skipped: 1},
+			}))
+
+			Expect(mockGarbanzoStore.CreateCalled).To(BeEmpty())
+			Expect(mockGarbanzoStore.UpdateByAPIUUIDAndOctoNameCalled).To(BeEmpty())
+			Expect(mockAuditStore.CreateCalled).To(BeEmpty())
+		})
+
+		It("overwrites an existing garbanzo moving it to the octo of the record", func() {
+			mockOctoStore.FetchByNameOutput.Octo <- kraken
+			mockOctoStore.FetchByNameOutput.Err <- nil
+			mockGarbanzoStore.FetchByAPIUUIDOutput.Garbanzo <- data.Garbanzo{
+				Id:           42,
+				APIUUID:      apiUUID,
+				GarbanzoType: kabuli,
+				OctoId:       78,
+				DiameterMM:   6.4,
+				Version:      1,
+			}
+			mockGarbanzoStore.FetchByAPIUUIDOutput.OctoName <- "cthulhu"
+			mockGarbanzoStore.FetchByAPIUUIDOutput.Err <- nil
+			mockGarbanzoStore.MoveByIdOutput.NewVersion <- 2
+			mockGarbanzoStore.MoveByIdOutput.Err <- nil
+			mockGarbanzoStore.UpdateByAPIUUIDAndOctoNameOutput.Version <- 3
+			mockGarbanzoStore.UpdateByAPIUUIDAndOctoNameOutput.Err <- nil
+			for i := 0; i < 3; i++ {
+				mockAuditStore.CreateOutput.EventId <- 7 + i
+				mockAuditStore.CreateOutput.Err <- nil
+			}
+			mockTx.CommitOutput.Err <- nil
+
+			summary, err := service.Import(ctx, services.ConflictOverwrite, next)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(summary).To(Equal(services.ImportSummary{
+				Octos:     services.ImportCounts{Overwritten: 1},
+				Garbanzos: services.ImportCounts{Overwritten: 1},
+			}))
+
+			Expect(mockGarbanzoStore.MoveByIdInput.Id).To(Receive(Equal(42)))
+			Expect(mockGarbanzoStore.MoveByIdInput.OctoId).To(Receive(Equal(kraken.Id)))
+			Expect(mockGarbanzoStore.MoveByIdInput.Version).To(Receive(Equal(0)))
+			Expect(mockGarbanzoStore.UpdateByAPIUUIDAndOctoNameInput.Garbanzo).To(Receive(Equal(data.Garbanzo{
+				Id:           42,
+				APIUUID:      apiUUID,
+				GarbanzoType: desi,
+				OctoId:       kraken.Id,
+				DiameterMM:   4.2,
+			})))
+			Expect(mockGarbanzoStore.UpdateByAPIUUIDAndOctoNameInput.OctoName).To(Receive(Equal("kraken")))
+
+			Expect(mockAuditStore.CreateCalled).To(HaveLen(3))
+			moved := fmt.Sprintf(`{
+				"api-uuid":    "%s",
+				"octo":        "kraken",
+				"type":        "KABULI",
+				"diameter-mm": 6.4,
+				"version":     2
+			}`, apiUUID)
+			for _, octoName := range []string{"cthulhu", "kraken"} {
+				Expect(mockAuditStore.CreateInput.Database).To(Receive(Equal(mockTx)))
+				var event data.AuditEvent
+				Expect(mockAuditStore.CreateInput.Event).To(Receive(&event))
+				Expect(event.Action).To(Equal(services.AuditMove))
+				Expect(event.Resource).To(Equal("octos/" + octoName + "/garbanzos/" + apiUUID.String()))
+				Expect(event.Before).To(MatchJSON(fmt.Sprintf(`{
+					"api-uuid":    "%s",
+					"octo":        "cthulhu",
+					"type":        "KABULI",
+					"diameter-mm": 6.4,
+					"version":     1
+				}`, apiUUID)))
+				Expect(event.After).To(MatchJSON(moved))
+			}
+			Expect(mockAuditStore.CreateInput.Database).To(Receive(Equal(mockTx)))
+			var event data.AuditEvent
+			Expect(mockAuditStore.CreateInput.Event).To(Receive(&event))
+			Expect(event.Action).To(Equal(services.AuditUpdate))
+			Expect(event.Resource).To(Equal("octos/kraken/garbanzos/" + apiUUID.String()))
+			Expect(event.Before).To(MatchJSON(moved))
+			Expect(event.After).To(MatchJSON(fmt.Sprintf(`{
+				"api-uuid":    "%s",
+				"octo":        "kraken",
+				"type":        "DESI",
+				"diameter-mm": 4.2,
+				"version":     3
+			}`, apiUUID)))
+		})
+
+		It("overwrites an existing garbanzo of the same octo without moving it", func() {
+			mockOctoStore.FetchByNameOutput.Octo <- kraken
+			mockOctoStore.FetchByNameOutput.Err <- nil
+			mockGarbanzoStore.FetchByAPIUUIDOutput.Garbanzo <- data.Garbanzo{
+				Id:           42,
+				APIUUID:      apiUUID,
+				GarbanzoType: desi,
+				OctoId:       kraken.Id,
+				DiameterMM:   3.9,
+				Version:      1,
+			}
+			mockGarbanzoStore.FetchByAPIUUIDOutput.OctoName <- kraken.Name
+			mockGarbanzoStore.FetchByAPIUUIDOutput.Err <- nil
+			mockGarbanzoStore.UpdateByAPIUUIDAndOctoNameOutput.Version <- 2
+			mockGarbanzoStore.UpdateByAPIUUIDAndOctoNameOutput.Err <- nil
+			mockAuditStore.CreateOutput.EventId <- 7
+			mockAuditStore.CreateOutput.Err <- nil
+			mockTx.CommitOutput.Err <- nil
+
+			_, err := service.Import(ctx, services.ConflictOverwrite, next)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(mockGarbanzoStore.MoveByIdCalled).To(BeEmpty())
+			Expect(mockAuditStore.CreateCalled).To(HaveLen(1))
+			var event data.AuditEvent
+			Expect(mockAuditStore.CreateInput.Event).To(Receive(&event))
+			Expect(event.Action).To(Equal(services.AuditUpdate))
+			Expect(event.Resource).To(Equal("octos/kraken/garbanzos/" + apiUUID.String()))
+			Expect(event.Before).To(MatchJSON(fmt.Sprintf(`{
+				"api-uuid":    "%s",
+				"octo":        "kraken",
+				"type":        "DESI",
+				"diameter-mm": 3.9,
+				"version":     1
+			}`, apiUUID)))
+			Expect(event.After).To(MatchJSON(fmt.Sprintf(`{
+				"api-uuid":    "%s",
+				"octo":        "kraken",
+				"type":        "DESI",
+				"diameter-mm": 4.2,
+				"version":     2
+			}`, apiUUID)))
+		})
+
+		It("returns an invalid record without beginning a transaction", func() {
+			garbanzo.DiameterMM = -1
+
+			_, err := service.Import(ctx, services.ConflictSkip, next)
+			importErr, ok := err.(services.ImportError)
+			Expect(ok).To(BeTrue())
+			Expect(importErr.Index).To(Equal(2))
+			Expect(importErr.Record.Garbanzo).To(Equal(&garbanzo))
+			Expect(importErr.Err).To(Equal(services.NewValidationError(map[string][]string{
+				"DiameterMM": {"must be a positive decimal value"},
+			})))
+
+			Expect(mockDB.BeginTxCalled).To(BeEmpty())
+		})
+
+		It("returns the error reading a record without beginning a transaction", func() {
+			nextErr = errors.New("bad record")
+
+			_, err := service.Import(ctx, services.ConflictSkip, next)
+			Expect(err).To(Equal(services.ImportError{Index: 3, Err: nextErr}))
+
+			Expect(mockDB.BeginTxCalled).To(BeEmpty())
+		})
+	})
+
+	Context("with the memory backend", func() {
+		var (
+			database *memory.Database
+			records  []services.Record
+		)
+
+		BeforeEach(func() {
+			database = memory.NewDatabase()
+			_, err := memory.OrgStore{}.Create(ctx, database, data.Org{Name: "my-org"})
+			Expect(err).NotTo(HaveOccurred())
+
+			garbanzoTypes := services.NewGarbanzoTypeService(memory.GarbanzoTypeStore{}, database, 0)
+			Expect(garbanzoTypes.Load(ctx)).To(Succeed())
+			service = services.NewTransferService(memory.OctoStore{}, memory.GarbanzoStore{}, garbanzoTypes,
+				services.NewAuditLog(memory.AuditEventStore{}, database), database)
+
+		})
+
+		next := func() (services.Record, error) {
+			if len(records) == 0 {
+				return services.Record{}, io.EOF
+			}
+			record := records[0]
+			records = records[1:]
+			return record, nil
+		}
+
+		It("rejects an unknown garbanzo type without deadlocking", func() {
+			records = []services.Record{
+				{Octo: data.Octo{Name: "kraken"}},
+				{Octo: data.Octo{Name: "kraken"}, Garbanzo: &data.Garbanzo{
+					GarbanzoType: data.GarbanzoType{Name: "UNKNOWN"},
+					DiameterMM:   4.2,
+				}},
+			}
+
+			done := make(chan error)
+			go func() {
+				_, err := service.Import(ctx, services.ConflictFail, next)
+				done <- err
+			}()
+
+			var err error
+			Eventually(done).Should(Receive(&err))
+			importErr, ok := err.(services.ImportError)
+			Expect(ok).To(BeTrue())
+			Expect(importErr.Index).To(Equal(2))
+			Expect(importErr.Err).To(Equal(services.NewValidationError(map[string][]string{
+				"GarbanzoType": {"must be one of 'DESI', 'KABULI'"},
+			})))
+
+			_, err = memory.OctoStore{}.FetchByName(ctx, database, "kraken", false)
+			Expect(err).To(Equal(persistence.ErrNotFound))
+		})
+
+		It("fails on a garbanzo whose API UUID belongs to another org", func() {
+			otherCtx := context.WithValue(context.Background(), persistence.OrgContextKey, "other-org")
+			_, err := memory.OrgStore{}.Create(otherCtx, database, data.Org{Name: "other-org"})
+			Expect(err).NotTo(HaveOccurred())
+			octoId, err := memory.OctoStore{}.Create(otherCtx, database, data.Octo{Name: "nessie"})
+			Expect(err).NotTo(HaveOccurred())
+			apiUUID := uuid.NewV4()
+			_, err = memory.GarbanzoStore{}.Create(otherCtx, database, data.Garbanzo{
+				APIUUID:      apiUUID,
+				GarbanzoType: desi,
+				OctoId:       octoId,
+				DiameterMM:   4.2,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			records = []services.Record{
+				{Octo: data.Octo{Name: "kraken"}},
+				{Octo: data.Octo{Name: "kraken"}, Garbanzo: &data.Garbanzo{
+					APIUUID:      apiUUID,
+					GarbanzoType: data.GarbanzoType{Name: "DESI"},
+					DiameterMM:   4.2,
+				}},
+			}
+
+			_, err = service.Import(ctx, services.ConflictOverwrite, next)
+			importErr, ok := err.(services.ImportError)
+			Expect(ok).To(BeTrue())
+			Expect(importErr.Index).To(Equal(2))
+			Expect(importErr.Err).To(Equal(persistence.ErrDuplicate))
+
+			_, err = memory.OctoStore{}.FetchByName(ctx, database, "kraken", false)
+			Expect(err).To(Equal(persistence.ErrNotFound))
+		})
+	})
+})
@@ -0,0 +1,75 @@
+package tracing_test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace/noop"
+
+	"github.com/myshkin5/effective-octo-garbanzo/tracing"
+)
+
+var _ = Describe("Tracing", func() {
+	var (
+		ctx     context.Context
+		tempDir string
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+
+		var err error
+		tempDir, err = ioutil.TempDir("", "tracing")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		os.Unsetenv("OTEL_TRACES_EXPORTER")
+		os.Unsetenv("TRACES_FILE")
+		otel.SetTracerProvider(noop.NewTracerProvider())
+		os.RemoveAll(tempDir)
+	})
+
+	It("doesn't export spans by default", func() {
+		shutdown, err := tracing.Init(ctx)
+		Expect(err).NotTo(HaveOccurred())
+
+		_, span := tracing.Start(ctx, "ignored")
+		tracing.End(span, nil)
+
+		Expect(span.SpanContext().IsValid()).To(BeFalse())
+		Expect(shutdown(ctx)).To(Succeed())
+	})
+
+	It("writes spans to TRACES_FILE with the stdout exporter", func() {
+		path := filepath.Join(tempDir, "traces.json")
+		os.Setenv("OTEL_TRACES_EXPORTER", "stdout")
+		os.Setenv("TRACES_FILE", path)
+
+		shutdown, err := tracing.Init(ctx)
+		Expect(err).NotTo(HaveOccurred())
+
+		_, span := tracing.Start(ctx, "OctoService.FetchByName")
+		tracing.End(span, errors.New("not today"))
+		Expect(shutdown(ctx)).To(Succeed())
+
+		traces, err := ioutil.ReadFile(path)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(traces)).To(ContainSubstring(`"Name":"OctoService.FetchByName"`))
+		Expect(string(traces)).To(ContainSubstring(`"Description":"not today"`))
+		Expect(string(traces)).To(ContainSubstring(`"effective-octo-garbanzo"`))
+	})
+
+	It("returns an error for an unknown exporter", func() {
+		os.Setenv("OTEL_TRACES_EXPORTER", "carrier-pigeon")
+
+		_, err := tracing.Init(ctx)
+		Expect(err).To(MatchError("unknown OTEL_TRACES_EXPORTER carrier-pigeon, must be none, otlp or stdout"))
+	})
+})